@@ -11,6 +11,8 @@ import (
 	"github.com/helixml/helix/api/pkg/cli/fs"
 	"github.com/helixml/helix/api/pkg/cli/knowledge"
 	"github.com/helixml/helix/api/pkg/cli/mcp"
+	"github.com/helixml/helix/api/pkg/cli/memory"
+	"github.com/helixml/helix/api/pkg/cli/runner"
 	"github.com/helixml/helix/api/pkg/cli/secret"
 )
 
@@ -34,7 +36,9 @@ func NewRootCmd() *cobra.Command {
 	RootCmd.AddCommand(fs.New())
 	RootCmd.AddCommand(fs.NewUploadCmd()) // Shortcut for upload
 	RootCmd.AddCommand(secret.New())
+	RootCmd.AddCommand(memory.New())
 	RootCmd.AddCommand(mcp.New())
+	RootCmd.AddCommand(runner.New())
 
 	// Commands available on all platforms
 	RootCmd.AddCommand(newServeCmd())
@@ -1,9 +1,11 @@
 package helix
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/helixml/helix/api/pkg/config"
@@ -295,7 +297,21 @@ func runnerCLI(cmd *cobra.Command, options *RunnerOptions) error {
 
 	go runnerController.Run()
 
-	<-ctx.Done()
+	// SIGTERM drains the runner first so that in-flight requests aren't dropped
+	termCh := make(chan os.Signal, 1)
+	signal.Notify(termCh, syscall.SIGTERM)
+	defer signal.Stop(termCh)
+
+	select {
+	case <-ctx.Done():
+	case <-termCh:
+		log.Info().Dur("timeout", options.Runner.Config.DrainTimeout).Msg("received SIGTERM, draining runner")
+		drainCtx, drainCancel := context.WithTimeout(ctx, options.Runner.Config.DrainTimeout)
+		defer drainCancel()
+		if err := runnerController.Drain(drainCtx); err != nil {
+			log.Error().Err(err).Msg("error draining runner, shutting down anyway")
+		}
+	}
 	return nil
 }
 
@@ -31,6 +31,8 @@ import (
 	"github.com/helixml/helix/api/pkg/stripe"
 	"github.com/helixml/helix/api/pkg/system"
 	"github.com/helixml/helix/api/pkg/trigger"
+	"github.com/helixml/helix/api/pkg/trigger/run"
+	"github.com/helixml/helix/api/pkg/trigger/sink"
 	"github.com/helixml/helix/api/pkg/types"
 
 	"github.com/rs/zerolog/log"
@@ -337,6 +339,7 @@ func serve(cmd *cobra.Command, cfg *config.ServerConfig) error {
 		Filestore:            fs,
 		Janitor:              janitor,
 		Notifier:             notifier,
+		Authenticator:        keycloakAuthenticator,
 		ProviderManager:      providerManager,
 		DataprepOpenAIClient: dataprepOpenAIClient,
 		Scheduler:            scheduler,
@@ -360,7 +363,7 @@ func serve(cmd *cobra.Command, cfg *config.ServerConfig) error {
 		return fmt.Errorf("failed to create browser pool: %w", err)
 	}
 
-	knowledgeReconciler, err := knowledge.New(cfg, store, fs, extractor, ragClient, browserPool)
+	knowledgeReconciler, err := knowledge.New(cfg, store, fs, extractor, ragClient, browserPool, ps)
 	if err != nil {
 		return err
 	}
@@ -375,6 +378,14 @@ func serve(cmd *cobra.Command, cfg *config.ServerConfig) error {
 	// Start integrations
 	go trigger.Start(ctx)
 
+	// Continue the workflow runs that were running when a server stopped, and finish and deliver
+	// the trigger runs that were waiting for them
+	sinks, err := sink.New(cfg, notifier, keycloakAuthenticator, fs)
+	if err != nil {
+		return fmt.Errorf("failed to create trigger sinks: %w", err)
+	}
+	go run.New(store, appController, sinks).ResumeInterrupted(ctx)
+
 	stripe := stripe.NewStripe(
 		cfg.Stripe,
 		func(eventType types.SubscriptionEventType, user types.StripeUser) error {
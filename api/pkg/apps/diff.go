@@ -0,0 +1,149 @@
+package apps
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+// DiffConfigs lists the differences between two versions of an app's helix config. The configs
+// are compared field by field as they are serialized, so paths use the JSON names, for example
+// assistants[0].system_prompt.
+func DiffConfigs(from, to *types.AppHelixConfig) ([]*types.AppConfigChange, error) {
+	fromValue, err := toJSONValue(from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	toValue, err := toJSONValue(to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	changes := []*types.AppConfigChange{}
+	diffValues("", fromValue, toValue, &changes)
+
+	return changes, nil
+}
+
+func toJSONValue(config *types.AppHelixConfig) (interface{}, error) {
+	if config == nil {
+		config = &types.AppHelixConfig{}
+	}
+
+	bts, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(bts, &value); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+func diffValues(path string, from, to interface{}, changes *[]*types.AppConfigChange) {
+	// Empty values are the same as missing ones, otherwise an added list of tools would
+	// show up as both a change from null and the added tools
+	if isEmptyJSONValue(from) && isEmptyJSONValue(to) {
+		return
+	}
+
+	switch {
+	case isEmptyJSONValue(from):
+		*changes = append(*changes, &types.AppConfigChange{Path: path, Type: types.AppConfigChangeAdded, To: to})
+		return
+	case isEmptyJSONValue(to):
+		*changes = append(*changes, &types.AppConfigChange{Path: path, Type: types.AppConfigChangeRemoved, From: from})
+		return
+	}
+
+	fromMap, fromIsMap := from.(map[string]interface{})
+	toMap, toIsMap := to.(map[string]interface{})
+	if fromIsMap && toIsMap {
+		keys := make(map[string]bool)
+		for key := range fromMap {
+			keys[key] = true
+		}
+		for key := range toMap {
+			keys[key] = true
+		}
+
+		sorted := make([]string, 0, len(keys))
+		for key := range keys {
+			sorted = append(sorted, key)
+		}
+		sort.Strings(sorted)
+
+		for _, key := range sorted {
+			keyPath := key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+			diffValues(keyPath, fromMap[key], toMap[key], changes)
+		}
+		return
+	}
+
+	fromList, fromIsList := from.([]interface{})
+	toList, toIsList := to.([]interface{})
+	if fromIsList && toIsList {
+		length := len(fromList)
+		if len(toList) > length {
+			length = len(toList)
+		}
+
+		for i := 0; i < length; i++ {
+			var fromItem, toItem interface{}
+			if i < len(fromList) {
+				fromItem = fromList[i]
+			}
+			if i < len(toList) {
+				toItem = toList[i]
+			}
+			diffValues(fmt.Sprintf("%s[%d]", path, i), fromItem, toItem, changes)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(from, to) {
+		*changes = append(*changes, &types.AppConfigChange{Path: path, Type: types.AppConfigChangeChanged, From: from, To: to})
+	}
+}
+
+// isEmptyJSONValue reports whether the value is missing, an empty string or list, or an object
+// that only has zero values. Booleans and numbers are compared as values instead, so that turning
+// a setting off is a change rather than a removal.
+func isEmptyJSONValue(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		for _, item := range v {
+			if !isZeroJSONValue(item) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func isZeroJSONValue(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return !v
+	case float64:
+		return v == 0
+	}
+	return isEmptyJSONValue(value)
+}
@@ -0,0 +1,76 @@
+package apps
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+func TestDiffConfigs(t *testing.T) {
+	from := &types.AppHelixConfig{
+		Name: "support-bot",
+		Assistants: []types.AssistantConfig{
+			{
+				Name:         "helper",
+				SystemPrompt: "Be polite to customers.",
+				Model:        "llama3:instruct",
+			},
+		},
+	}
+
+	to := &types.AppHelixConfig{
+		Name: "support-bot",
+		Assistants: []types.AssistantConfig{
+			{
+				Name:         "helper",
+				SystemPrompt: "Be rude to customers.",
+				Model:        "llama3:instruct",
+				Zapier: []types.AssistantZapier{
+					{Name: "zapier", Description: "Send emails"},
+				},
+			},
+		},
+	}
+
+	changes, err := DiffConfigs(from, to)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+
+	assert.Equal(t, "assistants[0].system_prompt", changes[0].Path)
+	assert.Equal(t, types.AppConfigChangeChanged, changes[0].Type)
+	assert.Equal(t, "Be polite to customers.", changes[0].From)
+	assert.Equal(t, "Be rude to customers.", changes[0].To)
+
+	assert.Equal(t, "assistants[0].zapier", changes[1].Path)
+	assert.Equal(t, types.AppConfigChangeAdded, changes[1].Type)
+	assert.Nil(t, changes[1].From)
+}
+
+func TestDiffConfigs_Same(t *testing.T) {
+	config := &types.AppHelixConfig{
+		Name:       "support-bot",
+		Assistants: []types.AssistantConfig{{Name: "helper"}},
+	}
+
+	changes, err := DiffConfigs(config, config)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestDiffConfigs_RemovedAssistant(t *testing.T) {
+	from := &types.AppHelixConfig{
+		Assistants: []types.AssistantConfig{{Name: "first"}, {Name: "second"}},
+	}
+	to := &types.AppHelixConfig{
+		Assistants: []types.AssistantConfig{{Name: "first"}},
+	}
+
+	changes, err := DiffConfigs(from, to)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, "assistants[1]", changes[0].Path)
+	assert.Equal(t, types.AppConfigChangeRemoved, changes[0].Type)
+}
@@ -244,10 +244,11 @@ func (k *KeycloakAuthenticator) GetUserByID(ctx context.Context, userID string)
 	}
 
 	return &types.User{
-		ID:       gocloak.PString(user.ID),
-		Username: gocloak.PString(user.Username),
-		Email:    gocloak.PString(user.Email),
-		FullName: fmt.Sprintf("%s %s", gocloak.PString(user.FirstName), gocloak.PString(user.LastName)),
+		ID:            gocloak.PString(user.ID),
+		Username:      gocloak.PString(user.Username),
+		Email:         gocloak.PString(user.Email),
+		EmailVerified: gocloak.PBool(user.EmailVerified),
+		FullName:      fmt.Sprintf("%s %s", gocloak.PString(user.FirstName), gocloak.PString(user.LastName)),
 	}, nil
 }
 
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"github.com/helixml/helix/api/pkg/client"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <app> <from revision> [to revision]",
+	Short: "Show the changes to a helix app between two revisions",
+	Long:  `Compares the app's config at the two revisions, the current revision is used if the second one isn't given.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 2 {
+			return fmt.Errorf("app name or ID and a revision are required")
+		}
+
+		from, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid revision %s", args[1])
+		}
+
+		var to int
+		if len(args) > 2 {
+			to, err = strconv.Atoi(args[2])
+			if err != nil {
+				return fmt.Errorf("invalid revision %s", args[2])
+			}
+		}
+
+		apiClient, err := client.NewClientFromEnv()
+		if err != nil {
+			return err
+		}
+
+		app, err := lookupApp(cmd.Context(), apiClient, args[0])
+		if err != nil {
+			return fmt.Errorf("failed to lookup app: %w", err)
+		}
+
+		diff, err := apiClient.DiffAppRevisions(cmd.Context(), app.ID, from, to)
+		if err != nil {
+			return fmt.Errorf("failed to diff app revisions: %w", err)
+		}
+
+		out := cmd.OutOrStdout()
+
+		fmt.Fprintf(out, "Revision %d -> %d\n", diff.From, diff.To)
+
+		if len(diff.Changes) == 0 {
+			fmt.Fprintln(out, "No changes")
+			return nil
+		}
+
+		for _, change := range diff.Changes {
+			switch change.Type {
+			case types.AppConfigChangeAdded:
+				fmt.Fprintf(out, "+ %s: %s\n", change.Path, formatDiffValue(change.To))
+			case types.AppConfigChangeRemoved:
+				fmt.Fprintf(out, "- %s: %s\n", change.Path, formatDiffValue(change.From))
+			default:
+				fmt.Fprintf(out, "~ %s: %s -> %s\n", change.Path, formatDiffValue(change.From), formatDiffValue(change.To))
+			}
+		}
+
+		return nil
+	},
+}
+
+func formatDiffValue(value interface{}) string {
+	bts, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(bts)
+}
@@ -0,0 +1,90 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/helixml/helix/api/pkg/client"
+)
+
+func init() {
+	rootCmd.AddCommand(environmentsCmd)
+}
+
+var environmentsCmd = &cobra.Command{
+	Use:     "environments",
+	Aliases: []string{"envs"},
+	Short:   "List the environments of a helix app",
+	Long:    ``,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return fmt.Errorf("app name or ID is required")
+		}
+
+		apiClient, err := client.NewClientFromEnv()
+		if err != nil {
+			return err
+		}
+
+		app, err := lookupApp(cmd.Context(), apiClient, args[0])
+		if err != nil {
+			return fmt.Errorf("failed to lookup app: %w", err)
+		}
+
+		environments, err := apiClient.ListAppEnvironments(cmd.Context(), app.ID)
+		if err != nil {
+			return fmt.Errorf("failed to list app environments: %w", err)
+		}
+
+		table := tablewriter.NewWriter(cmd.OutOrStdout())
+
+		header := []string{"Name", "Revision", "Promoted From", "Secrets", "Updated"}
+
+		table.SetHeader(header)
+
+		table.SetAutoWrapText(false)
+		table.SetAutoFormatHeaders(true)
+		table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		table.SetCenterSeparator("")
+		table.SetColumnSeparator("")
+		table.SetRowSeparator("")
+		table.SetHeaderLine(false)
+		table.SetBorder(false)
+		table.SetTablePadding(" ")
+		table.SetNoWhiteSpace(false)
+
+		for _, environment := range environments {
+			revision := fmt.Sprintf("latest (%d)", app.Revision)
+			if environment.Revision > 0 {
+				revision = strconv.Itoa(environment.Revision)
+			}
+
+			var secrets []string
+			for name := range environment.Secrets {
+				secrets = append(secrets, name)
+			}
+			sort.Strings(secrets)
+
+			row := []string{
+				environment.Name,
+				revision,
+				environment.PromotedFrom,
+				strings.Join(secrets, ", "),
+				environment.Updated.Format(time.DateTime),
+			}
+
+			table.Append(row)
+		}
+
+		table.Render()
+
+		return nil
+	},
+}
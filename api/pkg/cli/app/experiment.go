@@ -0,0 +1,86 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/helixml/helix/api/pkg/client"
+)
+
+func init() {
+	rootCmd.AddCommand(experimentCmd)
+}
+
+var experimentCmd = &cobra.Command{
+	Use:   "experiment <app> <experiment>",
+	Short: "Compare the variants of a helix app's experiment",
+	Long:  `Shows latency, token usage, thumbs up/down feedback and eval scores for each variant of the experiment.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 2 {
+			return fmt.Errorf("app name or ID and experiment name are required")
+		}
+
+		apiClient, err := client.NewClientFromEnv()
+		if err != nil {
+			return err
+		}
+
+		app, err := lookupApp(cmd.Context(), apiClient, args[0])
+		if err != nil {
+			return fmt.Errorf("failed to lookup app: %w", err)
+		}
+
+		reports, err := apiClient.GetExperimentReport(cmd.Context(), app.ID, args[1])
+		if err != nil {
+			return fmt.Errorf("failed to get experiment report: %w", err)
+		}
+
+		table := tablewriter.NewWriter(cmd.OutOrStdout())
+
+		header := []string{"Variant", "Sessions", "LLM Calls", "Avg Latency (ms)", "Avg Tokens", "Thumbs Up", "Thumbs Down", "Eval Manual", "Eval Automatic"}
+
+		table.SetHeader(header)
+
+		table.SetAutoWrapText(false)
+		table.SetAutoFormatHeaders(true)
+		table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		table.SetCenterSeparator("")
+		table.SetColumnSeparator("")
+		table.SetRowSeparator("")
+		table.SetHeaderLine(false)
+		table.SetBorder(false)
+		table.SetTablePadding(" ")
+		table.SetNoWhiteSpace(false)
+
+		for _, report := range reports {
+			row := []string{
+				report.Variant,
+				strconv.FormatInt(report.Sessions, 10),
+				strconv.FormatInt(report.LLMCalls, 10),
+				fmt.Sprintf("%.0f", report.AvgDurationMs),
+				fmt.Sprintf("%.0f", report.AvgTotalTokens),
+				strconv.FormatInt(report.ThumbsUp, 10),
+				strconv.FormatInt(report.ThumbsDown, 10),
+				formatScore(report.AvgEvalManualScore),
+				formatScore(report.AvgEvalAutomaticScore),
+			}
+
+			table.Append(row)
+		}
+
+		table.Render()
+
+		return nil
+	},
+}
+
+func formatScore(score *float64) string {
+	if score == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f", *score)
+}
@@ -0,0 +1,81 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/helixml/helix/api/pkg/client"
+)
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+}
+
+var historyCmd = &cobra.Command{
+	Use:     "history",
+	Aliases: []string{"revisions"},
+	Short:   "List the revisions of a helix app",
+	Long:    ``,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return fmt.Errorf("app name or ID is required")
+		}
+
+		apiClient, err := client.NewClientFromEnv()
+		if err != nil {
+			return err
+		}
+
+		app, err := lookupApp(cmd.Context(), apiClient, args[0])
+		if err != nil {
+			return fmt.Errorf("failed to lookup app: %w", err)
+		}
+
+		revisions, err := apiClient.ListAppRevisions(cmd.Context(), app.ID)
+		if err != nil {
+			return fmt.Errorf("failed to list app revisions: %w", err)
+		}
+
+		table := tablewriter.NewWriter(cmd.OutOrStdout())
+
+		header := []string{"Revision", "Created", "Author", ""}
+
+		table.SetHeader(header)
+
+		table.SetAutoWrapText(false)
+		table.SetAutoFormatHeaders(true)
+		table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		table.SetCenterSeparator("")
+		table.SetColumnSeparator("")
+		table.SetRowSeparator("")
+		table.SetHeaderLine(false)
+		table.SetBorder(false)
+		table.SetTablePadding(" ")
+		table.SetNoWhiteSpace(false)
+
+		for _, revision := range revisions {
+			current := ""
+			if revision.Revision == app.Revision {
+				current = "current"
+			}
+
+			row := []string{
+				strconv.Itoa(revision.Revision),
+				revision.Created.Format(time.DateTime),
+				revision.Author,
+				current,
+			}
+
+			table.Append(row)
+		}
+
+		table.Render()
+
+		return nil
+	},
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/helixml/helix/api/pkg/client"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+func init() {
+	rootCmd.AddCommand(promoteCmd)
+
+	promoteCmd.Flags().String("from", "", "Environment to promote from, defaults to the one before the target in dev, staging, prod")
+	promoteCmd.Flags().Int("revision", 0, "Revision to promote instead of the one another environment serves")
+}
+
+var promoteCmd = &cobra.Command{
+	Use:   "promote <app> <environment>",
+	Short: "Promote a tested revision of a helix app to an environment",
+	Long: `Copies the revision that one environment serves to the next one, for example from staging to prod.
+API keys that belong to the environment are served that revision from then on.
+
+To only promote revisions that pass their tests, run them first:
+
+  helix test && helix app promote my-app staging`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 2 {
+			return fmt.Errorf("app name or ID and an environment are required")
+		}
+
+		from, err := cmd.Flags().GetString("from")
+		if err != nil {
+			return err
+		}
+
+		revision, err := cmd.Flags().GetInt("revision")
+		if err != nil {
+			return err
+		}
+
+		apiClient, err := client.NewClientFromEnv()
+		if err != nil {
+			return err
+		}
+
+		app, err := lookupApp(cmd.Context(), apiClient, args[0])
+		if err != nil {
+			return fmt.Errorf("failed to lookup app: %w", err)
+		}
+
+		environment, err := apiClient.PromoteAppEnvironment(cmd.Context(), app.ID, args[1], &types.PromoteAppEnvironmentRequest{
+			From:     from,
+			Revision: revision,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to promote app: %w", err)
+		}
+
+		if environment.PromotedFrom != "" {
+			fmt.Printf("Promoted revision %d of app %s from %s to %s\n", environment.Revision, app.ID, environment.PromotedFrom, environment.Name)
+		} else {
+			fmt.Printf("Promoted revision %d of app %s to %s\n", environment.Revision, app.ID, environment.Name)
+		}
+
+		return nil
+	},
+}
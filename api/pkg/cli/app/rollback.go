@@ -0,0 +1,49 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"github.com/helixml/helix/api/pkg/client"
+)
+
+func init() {
+	rootCmd.AddCommand(rollbackCmd)
+}
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback <app> <revision>",
+	Short: "Restore a helix app to a previous revision",
+	Long:  `Restores the app's config from the revision. The rollback is stored as a new revision, so it can be undone.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 2 {
+			return fmt.Errorf("app name or ID and a revision are required")
+		}
+
+		revision, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid revision %s", args[1])
+		}
+
+		apiClient, err := client.NewClientFromEnv()
+		if err != nil {
+			return err
+		}
+
+		app, err := lookupApp(cmd.Context(), apiClient, args[0])
+		if err != nil {
+			return fmt.Errorf("failed to lookup app: %w", err)
+		}
+
+		updated, err := apiClient.RollbackApp(cmd.Context(), app.ID, revision)
+		if err != nil {
+			return fmt.Errorf("failed to roll back app: %w", err)
+		}
+
+		fmt.Printf("App %s rolled back to revision %d, now at revision %d\n", app.ID, revision, updated.Revision)
+
+		return nil
+	},
+}
@@ -0,0 +1,123 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/helixml/helix/api/pkg/client"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+func init() {
+	rootCmd.AddCommand(runsCmd)
+	runsCmd.Flags().String("type", "", "Only show runs of this trigger type, e.g. cron")
+	runsCmd.Flags().Int("limit", 20, "Number of runs to show")
+}
+
+var runsCmd = &cobra.Command{
+	Use:   "runs <app> [run ID]",
+	Short: "List the trigger runs of a helix app",
+	Long:  `Lists the runs of the app's triggers, newest first. With a run ID, prints the run along with the LLM calls it made as JSON.`,
+	Args:  cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		apiClient, err := client.NewClientFromEnv()
+		if err != nil {
+			return err
+		}
+
+		app, err := lookupApp(cmd.Context(), apiClient, args[0])
+		if err != nil {
+			return fmt.Errorf("failed to lookup app: %w", err)
+		}
+
+		if len(args) == 2 {
+			run, err := apiClient.GetTriggerRun(cmd.Context(), app.ID, args[1])
+			if err != nil {
+				return fmt.Errorf("failed to get trigger run: %w", err)
+			}
+
+			output, err := json.MarshalIndent(run, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to marshal trigger run: %w", err)
+			}
+
+			fmt.Fprintln(cmd.OutOrStdout(), string(output))
+			return nil
+		}
+
+		triggerType, _ := cmd.Flags().GetString("type")
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		runs, err := apiClient.ListTriggerRuns(cmd.Context(), app.ID, &client.TriggerRunsFilter{
+			Type:  types.TriggerType(triggerType),
+			Limit: limit,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list trigger runs: %w", err)
+		}
+
+		table := tablewriter.NewWriter(cmd.OutOrStdout())
+
+		header := []string{"ID", "Type", "Status", "Started", "Duration", "Session", "Deliveries"}
+
+		table.SetHeader(header)
+
+		table.SetAutoWrapText(false)
+		table.SetAutoFormatHeaders(true)
+		table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		table.SetCenterSeparator("")
+		table.SetColumnSeparator("")
+		table.SetRowSeparator("")
+		table.SetHeaderLine(false)
+		table.SetBorder(false)
+		table.SetTablePadding(" ")
+		table.SetNoWhiteSpace(false)
+
+		for _, run := range runs {
+			duration := "-"
+			if !run.Finished.IsZero() {
+				duration = run.Finished.Sub(run.Started).Round(time.Millisecond).String()
+			}
+
+			row := []string{
+				run.ID,
+				string(run.Type),
+				string(run.Status),
+				run.Started.Format(time.DateTime),
+				duration,
+				run.SessionID,
+				formatDeliveries(run.Deliveries),
+			}
+
+			table.Append(row)
+		}
+
+		table.Render()
+
+		return nil
+	},
+}
+
+// formatDeliveries lists the sinks, marking the ones that failed
+func formatDeliveries(deliveries types.TriggerRunDeliveries) string {
+	if len(deliveries) == 0 {
+		return "-"
+	}
+
+	parts := make([]string, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		if delivery.Error != "" {
+			parts = append(parts, delivery.Sink+" (failed)")
+			continue
+		}
+		parts = append(parts, delivery.Sink)
+	}
+
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,63 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/helixml/helix/api/pkg/client"
+)
+
+func init() {
+	rootCmd.AddCommand(webhooksCmd)
+}
+
+var webhooksCmd = &cobra.Command{
+	Use:   "webhooks <app>",
+	Short: "List the webhook trigger URLs of a helix app",
+	Long:  `Lists the signed URLs of the app's webhook triggers. Callers can either use the URL as it is or sign the request body with the trigger's secret.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		apiClient, err := client.NewClientFromEnv()
+		if err != nil {
+			return err
+		}
+
+		app, err := lookupApp(cmd.Context(), apiClient, args[0])
+		if err != nil {
+			return fmt.Errorf("failed to lookup app: %w", err)
+		}
+
+		urls, err := apiClient.ListAppWebhooks(cmd.Context(), app.ID)
+		if err != nil {
+			return fmt.Errorf("failed to list app webhooks: %w", err)
+		}
+
+		table := tablewriter.NewWriter(cmd.OutOrStdout())
+
+		header := []string{"Name", "URL"}
+
+		table.SetHeader(header)
+
+		table.SetAutoWrapText(false)
+		table.SetAutoFormatHeaders(true)
+		table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		table.SetCenterSeparator("")
+		table.SetColumnSeparator("")
+		table.SetRowSeparator("")
+		table.SetHeaderLine(false)
+		table.SetBorder(false)
+		table.SetTablePadding(" ")
+		table.SetNoWhiteSpace(false)
+
+		for _, u := range urls {
+			table.Append([]string{u.Name, u.URL})
+		}
+
+		table.Render()
+
+		return nil
+	},
+}
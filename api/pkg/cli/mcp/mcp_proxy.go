@@ -152,43 +152,12 @@ func (mcps *ModelContextProtocolServer) getModelContextProtocolTools(app *types.
 		}
 
 		// Each API tool has a list of actions, adding them separately
-		for _, action := range tool.Config.API.Actions {
-			parameters, err := tools.GetParametersFromSchema(tool.Config.API.Schema, action.Name)
-			if err != nil {
-				log.Error().
-					Err(err).
-					Str("tool", tool.Name).
-					Str("action", action.Name).
-					Msg("failed to get parameters from schema")
-				continue
-			}
-
-			var mcpParams []mcp.ToolOption
-
-			mcpParams = append(mcpParams, mcp.WithDescription(action.Description))
-
-			for _, param := range parameters {
-				if param.Required {
-					mcpParams = append(mcpParams, mcp.WithString(param.Name,
-						mcp.Required(),
-						mcp.Description(param.Description),
-					))
-				} else {
-					mcpParams = append(mcpParams, mcp.WithString(param.Name,
-						mcp.Description(param.Description),
-					))
-				}
-			}
-
-			mcpTool := mcp.NewTool(action.Name,
-				mcpParams...,
-			)
-
-			log.Info().Any("tool", action).Msg("adding tool")
+		for _, mcpTool := range tools.NewMCPToolsFromAPI(tool) {
+			log.Info().Str("action", mcpTool.Name).Msg("adding tool")
 
 			mcpTools = append(mcpTools, &helixMCPTool{
 				tool:    mcpTool,
-				handler: mcps.getAPIToolHandler(mcps.appID, tool, action.Name),
+				handler: mcps.getAPIToolHandler(mcps.appID, tool, mcpTool.Name),
 			})
 		}
 	}
@@ -248,23 +217,7 @@ func (mcps *ModelContextProtocolServer) getAPIToolHandler(appID string, tool *ty
 			Str("action", action).
 			Msg("api tool handler")
 
-		params := make(map[string]string)
-
-		for k, v := range request.Params.Arguments {
-			val, ok := v.(string)
-			if !ok {
-				log.Error().
-					Str("tool", tool.Name).
-					Str("action", action).
-					Str("param", k).
-					Any("value", v).
-					Msg("param is not a string")
-				continue
-			}
-			params[k] = val
-		}
-
-		resp, err := mcps.apiClient.RunAPIAction(ctx, appID, action, params)
+		resp, err := mcps.apiClient.RunAPIAction(ctx, appID, action, request.Params.Arguments)
 		if err != nil {
 			log.Error().Err(err).Msg("failed to run api action")
 			return mcp.NewToolResultError(err.Error()), nil
@@ -274,16 +227,18 @@ func (mcps *ModelContextProtocolServer) getAPIToolHandler(appID string, tool *ty
 	}
 }
 
+// GPTScript and Zapier tools run on the control plane, clients that need them connect to the
+// server's own MCP endpoint with an app API key instead of running this proxy
 func (mcps *ModelContextProtocolServer) gptScriptToolHandler(_ context.Context, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) { //nolint:unparam
-	// TODO: implement gpt script tool handler
-	return mcp.NewToolResultText("Hello, World!"), nil
+	return mcp.NewToolResultError(errProxyUnsupportedTool), nil
 }
 
 func (mcps *ModelContextProtocolServer) zapierToolHandler(_ context.Context, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) { //nolint:unparam
-	// TODO: implement zapier tool handler
-	return mcp.NewToolResultText("Hello, World!"), nil
+	return mcp.NewToolResultError(errProxyUnsupportedTool), nil
 }
 
+const errProxyUnsupportedTool = "this tool can't be run through the local proxy, connect to <helix url>/api/v1/mcp/sse with an app API key instead"
+
 func (mcps *ModelContextProtocolServer) getKnowledgeToolHandler(knowledgeID string) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		prompt, ok := request.Params.Arguments["prompt"]
@@ -0,0 +1,19 @@
+package memory
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var rootCmd = &cobra.Command{
+	Use:     "memory",
+	Short:   "Helix memory management",
+	Aliases: []string{"memories", "mem"},
+	Long:    `Manage what the assistants with memory remember about you.`,
+	Run: func(*cobra.Command, []string) {
+		// Do Stuff Here
+	},
+}
+
+func New() *cobra.Command {
+	return rootCmd
+}
@@ -0,0 +1,54 @@
+package memory
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/helixml/helix/api/pkg/client"
+)
+
+func init() {
+	rootCmd.AddCommand(deleteCmd)
+	deleteCmd.Flags().Bool("all", false, "Delete all of your memories")
+	deleteCmd.Flags().String("app", "", "With --all, only delete the memories of this app ID")
+}
+
+var deleteCmd = &cobra.Command{
+	Use:     "delete [memory ID]",
+	Aliases: []string{"rm"},
+	Short:   "Delete memories",
+	Long:    `Delete a memory by ID, or all of your memories with --all.`,
+	Args:    cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		all, _ := cmd.Flags().GetBool("all")
+		appID, _ := cmd.Flags().GetString("app")
+
+		if all == (len(args) == 1) {
+			return fmt.Errorf("specify either a memory ID or --all")
+		}
+
+		apiClient, err := client.NewClientFromEnv()
+		if err != nil {
+			return err
+		}
+
+		if all {
+			memories, err := apiClient.DeleteMemories(cmd.Context(), &client.MemoryFilter{AppID: appID})
+			if err != nil {
+				return fmt.Errorf("failed to delete memories: %w", err)
+			}
+
+			fmt.Printf("%d memories deleted successfully\n", len(memories))
+			return nil
+		}
+
+		err = apiClient.DeleteMemory(cmd.Context(), args[0])
+		if err != nil {
+			return fmt.Errorf("failed to delete memory: %w", err)
+		}
+
+		fmt.Printf("Memory '%s' deleted successfully\n", args[0])
+		return nil
+	},
+}
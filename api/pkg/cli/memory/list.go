@@ -0,0 +1,69 @@
+package memory
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/helixml/helix/api/pkg/client"
+)
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+	listCmd.Flags().String("app", "", "Only show the memories of this app ID")
+}
+
+var listCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List your memories",
+	Long:    ``,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		appID, _ := cmd.Flags().GetString("app")
+
+		apiClient, err := client.NewClientFromEnv()
+		if err != nil {
+			return err
+		}
+
+		memories, err := apiClient.ListMemories(cmd.Context(), &client.MemoryFilter{AppID: appID})
+		if err != nil {
+			return fmt.Errorf("failed to list memories: %w", err)
+		}
+
+		table := tablewriter.NewWriter(cmd.OutOrStdout())
+
+		header := []string{"ID", "App ID", "Content", "Created"}
+
+		table.SetHeader(header)
+
+		table.SetAutoWrapText(false)
+		table.SetAutoFormatHeaders(true)
+		table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		table.SetCenterSeparator("")
+		table.SetColumnSeparator("")
+		table.SetRowSeparator("")
+		table.SetHeaderLine(false)
+		table.SetBorder(false)
+		table.SetTablePadding(" ")
+		table.SetNoWhiteSpace(false)
+
+		for _, m := range memories {
+			row := []string{
+				m.ID,
+				m.AppID,
+				m.Content,
+				m.Created.Format(time.RFC3339),
+			}
+
+			table.Append(row)
+		}
+
+		table.Render()
+
+		return nil
+	},
+}
@@ -0,0 +1,40 @@
+package memory
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/helixml/helix/api/pkg/client"
+)
+
+func init() {
+	rootCmd.AddCommand(updateCmd)
+}
+
+var updateCmd = &cobra.Command{
+	Use:   "update <memory ID> <content>",
+	Short: "Change a memory",
+	Long:  `Change what the assistants remember, the memory's content is replaced.`,
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		content := strings.TrimSpace(args[1])
+		if content == "" {
+			return fmt.Errorf("memory content cannot be empty")
+		}
+
+		apiClient, err := client.NewClientFromEnv()
+		if err != nil {
+			return err
+		}
+
+		_, err = apiClient.UpdateMemory(cmd.Context(), args[0], content)
+		if err != nil {
+			return fmt.Errorf("failed to update memory: %w", err)
+		}
+
+		fmt.Printf("Memory updated successfully\n")
+		return nil
+	},
+}
@@ -0,0 +1,18 @@
+package runner
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var rootCmd = &cobra.Command{
+	Use:   "runners",
+	Short: "Helix runner management",
+	Long:  `Take runners in and out of rotation, e.g. for driver upgrades.`,
+	Run: func(*cobra.Command, []string) {
+		// Do Stuff Here
+	},
+}
+
+func New() *cobra.Command {
+	return rootCmd
+}
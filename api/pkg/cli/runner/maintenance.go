@@ -0,0 +1,68 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/helixml/helix/api/pkg/client"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+func init() {
+	rootCmd.AddCommand(cordonCmd)
+	rootCmd.AddCommand(drainCmd)
+	rootCmd.AddCommand(uncordonCmd)
+}
+
+var cordonCmd = &cobra.Command{
+	Use:   "cordon <runner id>",
+	Short: "Stop new models being loaded on a runner",
+	Long:  `Stop new slots being created on a runner. Models that are already loaded keep serving requests.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return updateMaintenance(cmd, args[0], func(ctx context.Context, c *client.HelixClient, id string) (*types.RunnerMaintenanceStatus, error) {
+			return c.CordonRunner(ctx, id)
+		})
+	},
+}
+
+var drainCmd = &cobra.Command{
+	Use:   "drain <runner id>",
+	Short: "Stop sending work to a runner and unload its models",
+	Long:  `Stop new work going to a runner. Active requests finish, then the runner's slots are released. Use "runners list" to see when the runner is drained.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return updateMaintenance(cmd, args[0], func(ctx context.Context, c *client.HelixClient, id string) (*types.RunnerMaintenanceStatus, error) {
+			return c.DrainRunner(ctx, id)
+		})
+	},
+}
+
+var uncordonCmd = &cobra.Command{
+	Use:   "uncordon <runner id>",
+	Short: "Put a runner back into rotation",
+	Long:  ``,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return updateMaintenance(cmd, args[0], func(ctx context.Context, c *client.HelixClient, id string) (*types.RunnerMaintenanceStatus, error) {
+			return c.UncordonRunner(ctx, id)
+		})
+	},
+}
+
+func updateMaintenance(cmd *cobra.Command, runnerID string, update func(context.Context, *client.HelixClient, string) (*types.RunnerMaintenanceStatus, error)) error {
+	apiClient, err := client.NewClientFromEnv()
+	if err != nil {
+		return err
+	}
+
+	status, err := update(cmd.Context(), apiClient, runnerID)
+	if err != nil {
+		return fmt.Errorf("failed to update runner %s: %w", runnerID, err)
+	}
+
+	fmt.Printf("Runner '%s' is %s\n", status.RunnerID, status.State)
+	return nil
+}
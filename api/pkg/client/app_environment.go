@@ -0,0 +1,57 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+func (c *HelixClient) ListAppEnvironments(ctx context.Context, appID string) ([]*types.AppEnvironment, error) {
+	var environments []*types.AppEnvironment
+	err := c.makeRequest(ctx, http.MethodGet, fmt.Sprintf("/apps/%s/environments", appID), nil, &environments)
+	if err != nil {
+		return nil, err
+	}
+	return environments, nil
+}
+
+// UpdateAppEnvironment creates or updates the environment, secrets without a value keep their
+// current value
+func (c *HelixClient) UpdateAppEnvironment(ctx context.Context, environment *types.AppEnvironment) (*types.AppEnvironment, error) {
+	bts, err := json.Marshal(environment)
+	if err != nil {
+		return nil, err
+	}
+
+	var updated types.AppEnvironment
+	err = c.makeRequest(ctx, http.MethodPut, fmt.Sprintf("/apps/%s/environments/%s", environment.AppID, environment.Name), bytes.NewBuffer(bts), &updated)
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// PromoteAppEnvironment copies a revision to the environment, from another environment or a
+// given revision. When neither is set the revision comes from the previous environment in
+// dev, staging, prod.
+func (c *HelixClient) PromoteAppEnvironment(ctx context.Context, appID, environment string, req *types.PromoteAppEnvironmentRequest) (*types.AppEnvironment, error) {
+	bts, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var promoted types.AppEnvironment
+	err = c.makeRequest(ctx, http.MethodPost, fmt.Sprintf("/apps/%s/environments/%s/promote", appID, environment), bytes.NewBuffer(bts), &promoted)
+	if err != nil {
+		return nil, err
+	}
+	return &promoted, nil
+}
+
+func (c *HelixClient) DeleteAppEnvironment(ctx context.Context, appID, environment string) error {
+	return c.makeRequest(ctx, http.MethodDelete, fmt.Sprintf("/apps/%s/environments/%s", appID, environment), nil, nil)
+}
@@ -0,0 +1,46 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+func (c *HelixClient) ListAppRevisions(ctx context.Context, appID string) ([]*types.AppRevision, error) {
+	var revisions []*types.AppRevision
+	err := c.makeRequest(ctx, http.MethodGet, fmt.Sprintf("/apps/%s/revisions", appID), nil, &revisions)
+	if err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// DiffAppRevisions compares two revisions of the app, when to is zero the current revision is used
+func (c *HelixClient) DiffAppRevisions(ctx context.Context, appID string, from, to int) (*types.AppRevisionDiff, error) {
+	query := url.Values{}
+	query.Add("from", strconv.Itoa(from))
+	if to > 0 {
+		query.Add("to", strconv.Itoa(to))
+	}
+
+	var diff types.AppRevisionDiff
+	err := c.makeRequest(ctx, http.MethodGet, fmt.Sprintf("/apps/%s/revisions/diff?%s", appID, query.Encode()), nil, &diff)
+	if err != nil {
+		return nil, err
+	}
+	return &diff, nil
+}
+
+// RollbackApp restores the app's config from the revision, the rollback is stored as a new revision
+func (c *HelixClient) RollbackApp(ctx context.Context, appID string, revision int) (*types.App, error) {
+	var app types.App
+	err := c.makeRequest(ctx, http.MethodPost, fmt.Sprintf("/apps/%s/revisions/%d/rollback", appID, revision), nil, &app)
+	if err != nil {
+		return nil, err
+	}
+	return &app, nil
+}
@@ -25,7 +25,22 @@ type Client interface {
 	DeleteApp(ctx context.Context, appID string, deleteKnowledge bool) error
 	ListApps(ctx context.Context, f *AppFilter) ([]*types.App, error)
 
-	RunAPIAction(ctx context.Context, appID string, action string, parameters map[string]string) (*types.RunAPIActionResponse, error)
+	ListAppRevisions(ctx context.Context, appID string) ([]*types.AppRevision, error)
+	DiffAppRevisions(ctx context.Context, appID string, from, to int) (*types.AppRevisionDiff, error)
+	RollbackApp(ctx context.Context, appID string, revision int) (*types.App, error)
+
+	ListAppEnvironments(ctx context.Context, appID string) ([]*types.AppEnvironment, error)
+	UpdateAppEnvironment(ctx context.Context, environment *types.AppEnvironment) (*types.AppEnvironment, error)
+	PromoteAppEnvironment(ctx context.Context, appID, environment string, req *types.PromoteAppEnvironmentRequest) (*types.AppEnvironment, error)
+	DeleteAppEnvironment(ctx context.Context, appID, environment string) error
+
+	GetExperimentReport(ctx context.Context, appID, experiment string) ([]*types.ExperimentVariantReport, error)
+
+	ListTriggerRuns(ctx context.Context, appID string, f *TriggerRunsFilter) ([]*types.TriggerRun, error)
+	GetTriggerRun(ctx context.Context, appID, runID string) (*types.TriggerRun, error)
+	ListAppWebhooks(ctx context.Context, appID string) ([]*types.WebhookTriggerURL, error)
+
+	RunAPIAction(ctx context.Context, appID string, action string, parameters map[string]interface{}) (*types.RunAPIActionResponse, error)
 
 	ListKnowledge(ctx context.Context, f *KnowledgeFilter) ([]*types.Knowledge, error)
 	GetKnowledge(ctx context.Context, id string) (*types.Knowledge, error)
@@ -38,11 +53,21 @@ type Client interface {
 	UpdateSecret(ctx context.Context, id string, secret *types.Secret) (*types.Secret, error)
 	DeleteSecret(ctx context.Context, id string) error
 
+	ListMemories(ctx context.Context, f *MemoryFilter) ([]*types.Memory, error)
+	UpdateMemory(ctx context.Context, id, content string) (*types.Memory, error)
+	DeleteMemory(ctx context.Context, id string) error
+	DeleteMemories(ctx context.Context, f *MemoryFilter) ([]*types.Memory, error)
+
 	ListKnowledgeVersions(ctx context.Context, f *KnowledgeVersionsFilter) ([]*types.KnowledgeVersion, error)
 
 	FilestoreList(ctx context.Context, path string) ([]filestore.Item, error)
 	FilestoreUpload(ctx context.Context, path string, file io.Reader) error
 	FilestoreDelete(ctx context.Context, path string) error
+
+	ListRunners(ctx context.Context) ([]*types.RunnerState, error)
+	CordonRunner(ctx context.Context, runnerID string) (*types.RunnerMaintenanceStatus, error)
+	DrainRunner(ctx context.Context, runnerID string) (*types.RunnerMaintenanceStatus, error)
+	UncordonRunner(ctx context.Context, runnerID string) (*types.RunnerMaintenanceStatus, error)
 }
 
 // HelixClient is the client for the helix api
@@ -0,0 +1,20 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+// GetExperimentReport compares the variants of the app's experiment
+func (c *HelixClient) GetExperimentReport(ctx context.Context, appID, experiment string) ([]*types.ExperimentVariantReport, error) {
+	var reports []*types.ExperimentVariantReport
+	err := c.makeRequest(ctx, http.MethodGet, fmt.Sprintf("/apps/%s/experiments/%s/report", appID, url.PathEscape(experiment)), nil, &reports)
+	if err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
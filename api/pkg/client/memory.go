@@ -0,0 +1,63 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+type MemoryFilter struct {
+	AppID string
+}
+
+func (f *MemoryFilter) path() string {
+	if f == nil || f.AppID == "" {
+		return "/memories"
+	}
+	return "/memories?" + url.Values{"app_id": []string{f.AppID}}.Encode()
+}
+
+// ListMemories lists what the assistants remember about the user, newest first
+func (c *HelixClient) ListMemories(ctx context.Context, f *MemoryFilter) ([]*types.Memory, error) {
+	var memories []*types.Memory
+	err := c.makeRequest(ctx, http.MethodGet, f.path(), nil, &memories)
+	if err != nil {
+		return nil, err
+	}
+	return memories, nil
+}
+
+// UpdateMemory changes the content of a memory
+func (c *HelixClient) UpdateMemory(ctx context.Context, id, content string) (*types.Memory, error) {
+	bts, err := json.Marshal(&types.UpdateMemoryRequest{Content: content})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal memory: %w", err)
+	}
+
+	var memory types.Memory
+	err = c.makeRequest(ctx, http.MethodPut, fmt.Sprintf("/memories/%s", id), bytes.NewBuffer(bts), &memory)
+	if err != nil {
+		return nil, err
+	}
+	return &memory, nil
+}
+
+// DeleteMemory deletes a memory by ID
+func (c *HelixClient) DeleteMemory(ctx context.Context, id string) error {
+	return c.makeRequest(ctx, http.MethodDelete, fmt.Sprintf("/memories/%s", id), nil, nil)
+}
+
+// DeleteMemories deletes all of the user's memories, of the app when the filter has one
+func (c *HelixClient) DeleteMemories(ctx context.Context, f *MemoryFilter) ([]*types.Memory, error) {
+	var memories []*types.Memory
+	err := c.makeRequest(ctx, http.MethodDelete, f.path(), nil, &memories)
+	if err != nil {
+		return nil, err
+	}
+	return memories, nil
+}
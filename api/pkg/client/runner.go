@@ -0,0 +1,43 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+// ListRunners retrieves the runners connected to the control plane. Requires an admin API key.
+func (c *HelixClient) ListRunners(ctx context.Context) ([]*types.RunnerState, error) {
+	var dashboard types.DashboardData
+	err := c.makeRequest(ctx, http.MethodGet, "/dashboard", nil, &dashboard)
+	if err != nil {
+		return nil, err
+	}
+	return dashboard.Runners, nil
+}
+
+// CordonRunner stops new slots being created on a runner
+func (c *HelixClient) CordonRunner(ctx context.Context, runnerID string) (*types.RunnerMaintenanceStatus, error) {
+	return c.updateRunnerMaintenance(ctx, runnerID, "cordon")
+}
+
+// DrainRunner stops new work going to a runner and releases its slots once their work has finished
+func (c *HelixClient) DrainRunner(ctx context.Context, runnerID string) (*types.RunnerMaintenanceStatus, error) {
+	return c.updateRunnerMaintenance(ctx, runnerID, "drain")
+}
+
+// UncordonRunner puts a runner back into rotation
+func (c *HelixClient) UncordonRunner(ctx context.Context, runnerID string) (*types.RunnerMaintenanceStatus, error) {
+	return c.updateRunnerMaintenance(ctx, runnerID, "uncordon")
+}
+
+func (c *HelixClient) updateRunnerMaintenance(ctx context.Context, runnerID, action string) (*types.RunnerMaintenanceStatus, error) {
+	var status types.RunnerMaintenanceStatus
+	err := c.makeRequest(ctx, http.MethodPost, fmt.Sprintf("/runners/%s/%s", runnerID, action), nil, &status)
+	if err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+type TriggerRunsFilter struct {
+	Type  types.TriggerType
+	Limit int
+}
+
+// ListTriggerRuns lists the app's trigger runs, newest first
+func (c *HelixClient) ListTriggerRuns(ctx context.Context, appID string, f *TriggerRunsFilter) ([]*types.TriggerRun, error) {
+	query := url.Values{}
+	if f != nil {
+		if f.Type != "" {
+			query.Set("type", string(f.Type))
+		}
+		if f.Limit > 0 {
+			query.Set("limit", strconv.Itoa(f.Limit))
+		}
+	}
+
+	path := fmt.Sprintf("/apps/%s/trigger-runs", appID)
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var runs []*types.TriggerRun
+	err := c.makeRequest(ctx, http.MethodGet, path, nil, &runs)
+	if err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// GetTriggerRun gets the trigger run along with the LLM calls it made
+func (c *HelixClient) GetTriggerRun(ctx context.Context, appID, runID string) (*types.TriggerRun, error) {
+	var run types.TriggerRun
+	err := c.makeRequest(ctx, http.MethodGet, fmt.Sprintf("/apps/%s/trigger-runs/%s", appID, runID), nil, &run)
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// ListAppWebhooks lists the signed URLs of the app's webhook triggers
+func (c *HelixClient) ListAppWebhooks(ctx context.Context, appID string) ([]*types.WebhookTriggerURL, error) {
+	var urls []*types.WebhookTriggerURL
+	err := c.makeRequest(ctx, http.MethodGet, fmt.Sprintf("/apps/%s/webhooks", appID), nil, &urls)
+	if err != nil {
+		return nil, err
+	}
+	return urls, nil
+}
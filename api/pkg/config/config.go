@@ -69,6 +69,20 @@ type Helix struct {
 	RunnerTTL          time.Duration `envconfig:"HELIX_RUNNER_TTL" default:"30s"`                         // How long before runners are considered dead
 	SchedulingStrategy string        `envconfig:"HELIX_SCHEDULING_STRATEGY" default:"max_spread" description:"The strategy to use for scheduling workloads."`
 	QueueSize          int           `envconfig:"HELIX_QUEUE_SIZE" default:"100" description:"The size of the queue when buffering workloads."`
+	MaxBatchSize       int           `envconfig:"HELIX_MAX_BATCH_SIZE" default:"1" description:"The maximum number of LLM inference requests that can share a single slot."`
+
+	Prewarm Prewarm
+}
+
+// Prewarm controls how the scheduler keeps models warm ahead of demand
+type Prewarm struct {
+	Enabled        bool          `envconfig:"HELIX_PREWARM_ENABLED" default:"false" description:"Keep warm slots for models based on demand history."`
+	Interval       time.Duration `envconfig:"HELIX_PREWARM_INTERVAL" default:"30s" description:"How often to check whether models need prewarming."`
+	DemandWindow   time.Duration `envconfig:"HELIX_PREWARM_DEMAND_WINDOW" default:"24h" description:"How much scheduling history to use when estimating per-model demand."`
+	CronLeadTime   time.Duration `envconfig:"HELIX_PREWARM_CRON_LEAD_TIME" default:"2m" description:"How long before a cron trigger fires to warm its model."`
+	DefaultCeiling int           `envconfig:"HELIX_PREWARM_DEFAULT_CEILING" default:"1" description:"Maximum number of warm slots per model unless overridden."`
+	Floors         []string      `envconfig:"HELIX_PREWARM_FLOORS" description:"Minimum warm slots per model, e.g. llama3:instruct=1,qwen2.5:7b=2."`
+	Ceilings       []string      `envconfig:"HELIX_PREWARM_CEILINGS" description:"Maximum warm slots per model, e.g. llama3:instruct=3."`
 }
 
 type Tools struct {
@@ -83,6 +97,14 @@ type Tools struct {
 	// IsActionableTemplate is used to determine whether Helix should
 	// use a tool or not. Leave empty for default
 	IsActionableTemplate string `envconfig:"TOOLS_IS_ACTIONABLE_TEMPLATE"` // Either plain text, base64 or path to a file
+
+	// OAuthEncryptionKey encrypts the tokens of users who link their accounts
+	// to API tools. Per-user OAuth2 is unavailable until it's set
+	OAuthEncryptionKey string `envconfig:"TOOLS_OAUTH_ENCRYPTION_KEY" description:"Key used to encrypt users' OAuth2 tokens for API tools."`
+
+	// MCPAllowStdio lets apps run MCP servers as commands on the control plane,
+	// only enable it if everyone who can create apps is trusted
+	MCPAllowStdio bool `envconfig:"TOOLS_MCP_ALLOW_STDIO" default:"false" description:"Allow apps to run MCP servers as commands on the control plane."`
 }
 
 // Keycloak is used for authentication. You can find keycloak documentation
@@ -324,7 +346,11 @@ type GPTScript struct {
 
 type Triggers struct {
 	Discord Discord
+	Slack   Slack
 	Cron    Cron
+	Email   EmailTrigger
+	Event   EventTrigger
+	Sinks   TriggerSinks
 }
 
 type Discord struct {
@@ -332,6 +358,39 @@ type Discord struct {
 	BotToken string `envconfig:"DISCORD_BOT_TOKEN"`
 }
 
+// Slack connects over Socket Mode, the bot token is used for the Web API and the app-level
+// token for the connection
+type Slack struct {
+	Enabled  bool   `envconfig:"SLACK_ENABLED" default:"false"`
+	BotToken string `envconfig:"SLACK_BOT_TOKEN"`
+	AppToken string `envconfig:"SLACK_APP_TOKEN"`
+}
+
 type Cron struct {
 	Enabled bool `envconfig:"CRON_ENABLED" default:"true"`
 }
+
+// EventTrigger runs apps on internal events, like knowledge that has been indexed
+type EventTrigger struct {
+	Enabled bool `envconfig:"EVENT_TRIGGER_ENABLED" default:"true"`
+}
+
+// TriggerSinks are where the output of trigger runs can be sent
+type TriggerSinks struct {
+	// EmailDomains are the domains that email sinks can send to, e.g. example.com. Without them
+	// apps can only email their owner's verified address.
+	EmailDomains []string `envconfig:"TRIGGER_SINK_EMAIL_DOMAINS"`
+}
+
+// EmailTrigger polls the IMAP mailboxes of apps with email triggers and, when SMTPListen is set,
+// accepts mail for them over SMTP. Replies are sent with the notification SMTP settings.
+type EmailTrigger struct {
+	Enabled      bool          `envconfig:"EMAIL_TRIGGER_ENABLED" default:"true"`
+	PollInterval time.Duration `envconfig:"EMAIL_TRIGGER_POLL_INTERVAL" default:"1m"`
+	// SMTPListen is the address to accept mail on, e.g. :2525, disabled when empty
+	SMTPListen string `envconfig:"EMAIL_TRIGGER_SMTP_LISTEN"`
+	// Domains are the domains that apps' inbox addresses can be in, e.g. support.example.com.
+	// Replies are sent from these addresses, so they should be domains the operator owns. Email
+	// triggers are disabled when empty.
+	Domains []string `envconfig:"EMAIL_TRIGGER_DOMAINS"`
+}
@@ -10,6 +10,17 @@ type RunnerConfig struct {
 	Models   Models
 	Runtimes Runtimes
 	CacheDir string `envconfig:"CACHE_DIR" default:"/root/.cache/huggingface"` // Used to download model weights. Ideally should be persistent
+
+	DrainTimeout time.Duration `envconfig:"RUNNER_DRAIN_TIMEOUT" default:"10m"` // How long to wait for active work to finish on SIGTERM
+
+	ArtifactCache ArtifactCache
+}
+
+// ArtifactCache holds files and LoRA dirs downloaded from the filestore
+type ArtifactCache struct {
+	Enabled bool   `envconfig:"ARTIFACT_CACHE_ENABLED" default:"true"`
+	Dir     string `envconfig:"ARTIFACT_CACHE_DIR"`                     // Defaults to helix-artifacts in the cache dir
+	MaxSize string `envconfig:"ARTIFACT_CACHE_MAX_SIZE" default:"20GB"` // Least recently used artifacts are evicted beyond this, 0 for no limit
 }
 
 func LoadRunnerConfig() (RunnerConfig, error) {
@@ -33,10 +44,20 @@ type Runtimes struct {
 		InstanceTTL  time.Duration `envconfig:"RUNTIME_AXOLOTL_INSTANCE_TTL" default:"10s"`
 	}
 	Ollama OllamaRuntimeConfig
+	VLLM   VLLMRuntimeConfig
 }
 
 type OllamaRuntimeConfig struct {
 	Enabled      bool          `envconfig:"RUNTIME_OLLAMA_ENABLED" default:"true"`
 	WarmupModels []string      `envconfig:"RUNTIME_OLLAMA_WARMUP_MODELS" default:"llama3.1:8b-instruct-q8_0"`
 	InstanceTTL  time.Duration `envconfig:"RUNTIME_OLLAMA_INSTANCE_TTL" default:"10s"`
+	NumParallel  int           `envconfig:"RUNTIME_OLLAMA_NUM_PARALLEL" default:"1"` // How many requests a model instance processes at once, should match HELIX_MAX_BATCH_SIZE
+}
+
+type VLLMRuntimeConfig struct {
+	PythonPath     string        `envconfig:"RUNTIME_VLLM_PYTHON" default:"python3"` // Python interpreter with vllm installed
+	InstanceTTL    time.Duration `envconfig:"RUNTIME_VLLM_INSTANCE_TTL" default:"10s"`
+	StartupTimeout time.Duration `envconfig:"RUNTIME_VLLM_STARTUP_TIMEOUT" default:"10m"` // Loading weights and capturing CUDA graphs is slow
+	NumParallel    int           `envconfig:"RUNTIME_VLLM_NUM_PARALLEL" default:"16"`     // How many requests are sent to vLLM at once, vLLM batches them
+	ExtraArgs      []string      `envconfig:"RUNTIME_VLLM_EXTRA_ARGS"`                    // Passed straight to the vLLM server, e.g. --tensor-parallel-size=2
 }
@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+// LoadApp loads the app with its tools. Users with an API key for one of the app's environments
+// get the revision that the environment is pinned to.
+func (c *Controller) LoadApp(ctx context.Context, user *types.User, appID string) (*types.App, error) {
+	environment, err := c.getUserAppEnvironment(ctx, user, appID)
+	if err != nil {
+		return nil, err
+	}
+
+	if environment != nil && environment.Revision > 0 {
+		app, err := c.Options.Store.GetAppWithToolsAtRevision(ctx, appID, environment.Revision)
+		if err != nil {
+			return nil, fmt.Errorf("error getting app revision %d for environment %s: %w", environment.Revision, environment.Name, err)
+		}
+		return app, nil
+	}
+
+	app, err := c.Options.Store.GetAppWithTools(ctx, appID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting app: %w", err)
+	}
+
+	return app, nil
+}
+
+// getUserAppEnvironment returns the environment of the app that the user's API key belongs to,
+// or nil if the key isn't for an environment of this app. The dev environment doesn't need to
+// exist and keys for an environment that was deleted keep working, without the environment the
+// latest revision is served.
+func (c *Controller) getUserAppEnvironment(ctx context.Context, user *types.User, appID string) (*types.AppEnvironment, error) {
+	if user == nil || user.AppEnvironment == "" || user.AppID != appID {
+		return nil, nil
+	}
+
+	environment, err := c.Options.Store.GetAppEnvironment(ctx, appID, user.AppEnvironment)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting app environment %s: %w", user.AppEnvironment, err)
+	}
+
+	return environment, nil
+}
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+func TestLoadApp_Environment(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	st := store.NewMockStore(ctrl)
+
+	c := &Controller{Options: Options{Store: st}}
+	user := &types.User{ID: "user_1", AppID: "app_1", AppEnvironment: types.AppEnvironmentProduction}
+
+	st.EXPECT().GetAppEnvironment(gomock.Any(), "app_1", types.AppEnvironmentProduction).Return(&types.AppEnvironment{
+		AppID:    "app_1",
+		Name:     types.AppEnvironmentProduction,
+		Revision: 3,
+		Secrets:  types.AppEnvironmentSecrets{"API_KEY": "prod-key"},
+	}, nil).Times(2)
+	st.EXPECT().GetAppWithToolsAtRevision(gomock.Any(), "app_1", 3).Return(&types.App{
+		ID:       "app_1",
+		Owner:    "user_1",
+		Revision: 3,
+		Config: types.AppConfig{
+			Helix: types.AppHelixConfig{
+				Assistants: []types.AssistantConfig{{SystemPrompt: "Use ${API_KEY}"}},
+			},
+		},
+	}, nil)
+	st.EXPECT().ListSecrets(gomock.Any(), &store.ListSecretsQuery{Owner: "user_1"}).Return([]*types.Secret{
+		{Name: "API_KEY", Value: []byte("dev-key")},
+	}, nil)
+
+	app, err := c.LoadApp(context.Background(), user, "app_1")
+	require.NoError(t, err)
+	assert.Equal(t, 3, app.Revision)
+
+	app, err = c.EvaluateSecrets(context.Background(), user, app)
+	require.NoError(t, err)
+	assert.Equal(t, "Use prod-key", app.Config.Helix.Assistants[0].SystemPrompt)
+}
+
+func TestLoadApp_DevFollowsLatest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	st := store.NewMockStore(ctrl)
+
+	c := &Controller{Options: Options{Store: st}}
+	user := &types.User{ID: "user_1", AppID: "app_1", AppEnvironment: types.AppEnvironmentDev}
+
+	st.EXPECT().GetAppEnvironment(gomock.Any(), "app_1", types.AppEnvironmentDev).Return(nil, store.ErrNotFound)
+	st.EXPECT().GetAppWithTools(gomock.Any(), "app_1").Return(&types.App{ID: "app_1", Revision: 5}, nil)
+
+	app, err := c.LoadApp(context.Background(), user, "app_1")
+	require.NoError(t, err)
+	assert.Equal(t, 5, app.Revision)
+}
+
+func TestLoadApp_DeletedEnvironmentFollowsLatest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	st := store.NewMockStore(ctrl)
+
+	c := &Controller{Options: Options{Store: st}}
+	user := &types.User{ID: "user_1", AppID: "app_1", AppEnvironment: types.AppEnvironmentStaging}
+
+	st.EXPECT().GetAppEnvironment(gomock.Any(), "app_1", types.AppEnvironmentStaging).Return(nil, store.ErrNotFound)
+	st.EXPECT().GetAppWithTools(gomock.Any(), "app_1").Return(&types.App{ID: "app_1", Revision: 5}, nil)
+
+	app, err := c.LoadApp(context.Background(), user, "app_1")
+	require.NoError(t, err)
+	assert.Equal(t, 5, app.Revision)
+}
@@ -0,0 +1,562 @@
+package controller
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	openai "github.com/sashabaranov/go-openai"
+
+	"github.com/helixml/helix/api/pkg/data"
+	"github.com/helixml/helix/api/pkg/guardrails"
+	"github.com/helixml/helix/api/pkg/model"
+	oai "github.com/helixml/helix/api/pkg/openai"
+	"github.com/helixml/helix/api/pkg/openai/transport"
+	"github.com/helixml/helix/api/pkg/prompts"
+	"github.com/helixml/helix/api/pkg/system"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+const defaultGuardrailMessage = "Sorry, I can't help with that."
+
+// guardrailClassifierSchema is what the model answers with when it classifies a message
+const guardrailClassifierSchema = `{
+  "type": "object",
+  "properties": {
+    "allowed": {"type": "boolean"},
+    "reason": {"type": "string"}
+  },
+  "required": ["allowed"]
+}`
+
+// guard runs the assistant's guardrails on a request and keeps what they caught until the request
+// is done. A nil guard lets everything through.
+type guard struct {
+	controller *Controller
+	user       *types.User
+	appID      string
+	guardrails []types.Guardrail
+
+	// provider and model answer for the assistant, classifiers use them unless they say otherwise
+	provider types.Provider
+	model    string
+
+	// llmCallID is the call that answers the request, once it's made
+	llmCallID string
+
+	mu       sync.Mutex
+	outcomes []*types.GuardrailOutcome
+}
+
+func (c *Controller) newGuard(user *types.User, assistant *types.AssistantConfig, opts *ChatCompletionOptions, modelName string) (*guard, error) {
+	if len(assistant.Guardrails) == 0 {
+		return nil, nil
+	}
+
+	provider, modelName, err := c.assistantModel(assistant, opts.Provider, modelName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &guard{
+		controller: c,
+		user:       user,
+		appID:      opts.AppID,
+		guardrails: assistant.Guardrails,
+		provider:   provider,
+		model:      modelName,
+	}, nil
+}
+
+// withContentCheck makes tool responses and knowledge go through the context guardrails
+func (g *guard) withContentCheck(ctx context.Context) context.Context {
+	if g == nil {
+		return ctx
+	}
+	return guardrails.WithContentCheck(ctx, g.checkContent)
+}
+
+// withLLMCallID makes the next LLM call the one that answers the request
+func (g *guard) withLLMCallID(ctx context.Context) context.Context {
+	if g == nil {
+		return ctx
+	}
+	g.llmCallID = system.GenerateLLMCallID()
+	return oai.SetContextLLMCallID(ctx, g.llmCallID)
+}
+
+func (g *guard) hasStage(stage types.GuardrailStage) bool {
+	if g == nil {
+		return false
+	}
+	for i := range g.guardrails {
+		if slices.Contains(data.GuardrailStages(&g.guardrails[i]), stage) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkInput checks the request's messages, it returns the answer instead when a guardrail blocks
+// them. When the earlier messages are the session's they were checked as they were sent, and only
+// the user's last message is. Otherwise the client sent the whole conversation, and all of it is
+// checked. Sessions send their messages as they were written so what's redacted from them is
+// redacted again.
+func (g *guard) checkInput(ctx context.Context, req *openai.ChatCompletionRequest, sessionHistory bool) (string, bool) {
+	if g == nil {
+		return "", false
+	}
+
+	checked := req.Messages
+	if sessionHistory {
+		last := -1
+		for i := len(req.Messages) - 1; i >= 0; i-- {
+			if req.Messages[i].Role == openai.ChatMessageRoleUser {
+				last = i
+				break
+			}
+		}
+		if last < 0 {
+			return "", false
+		}
+		checked = req.Messages[last : last+1]
+	}
+
+	var texts []string
+	for i := range checked {
+		for _, text := range messageTexts(&checked[i]) {
+			texts = append(texts, *text)
+		}
+	}
+
+	_, message, blocked := g.check(ctx, types.GuardrailStageInput, "", strings.Join(texts, "\n\n"))
+	if blocked {
+		return cmp.Or(message, defaultGuardrailMessage), true
+	}
+
+	for i := range req.Messages {
+		if sessionHistory && req.Messages[i].Role != openai.ChatMessageRoleUser {
+			continue
+		}
+		for _, text := range messageTexts(&req.Messages[i]) {
+			*text = g.redact(types.GuardrailStageInput, *text)
+		}
+	}
+
+	return "", false
+}
+
+// messageTexts are the message's content and its text parts
+func messageTexts(message *openai.ChatCompletionMessage) []*string {
+	texts := []*string{&message.Content}
+	for i := range message.MultiContent {
+		if message.MultiContent[i].Type == openai.ChatMessagePartTypeText {
+			texts = append(texts, &message.MultiContent[i].Text)
+		}
+	}
+	return texts
+}
+
+// redact removes what the stage's guardrails that redact detect from the text, it's been recorded
+// when the text was checked
+func (g *guard) redact(stage types.GuardrailStage, text string) string {
+	for i := range g.guardrails {
+		guardrail := &g.guardrails[i]
+		if data.GuardrailAction(guardrail) != types.GuardrailActionRedact ||
+			!slices.Contains(data.GuardrailStages(guardrail), stage) {
+			continue
+		}
+
+		switch guardrail.Type {
+		case types.GuardrailTypePII:
+			text, _ = guardrails.RedactPII(text, guardrail.PII)
+		case types.GuardrailTypePromptInjection:
+			text, _ = guardrails.RedactInjections(text)
+		}
+	}
+	return text
+}
+
+// checkContent checks a tool response or knowledge, blocked content is withheld from the model.
+// The model reads the guardrail's message in its place, or a neutral note when it doesn't have one.
+func (g *guard) checkContent(ctx context.Context, source, content string) (string, bool) {
+	content, message, blocked := g.check(ctx, types.GuardrailStageContext, source, content)
+	if blocked {
+		return cmp.Or(message, guardrails.Withheld), false
+	}
+	return content, true
+}
+
+// checkOutput checks the answer and the arguments of the tools it calls, a blocked answer is
+// replaced with the guardrail's message
+func (g *guard) checkOutput(ctx context.Context, resp *openai.ChatCompletionResponse) {
+	if g == nil || len(resp.Choices) == 0 {
+		return
+	}
+	choice := &resp.Choices[0]
+
+	texts := []*string{&choice.Message.Content}
+	for i := range choice.Message.ToolCalls {
+		texts = append(texts, &choice.Message.ToolCalls[i].Function.Arguments)
+	}
+
+	var joined []string
+	for _, text := range texts {
+		joined = append(joined, *text)
+	}
+
+	_, message, blocked := g.check(ctx, types.GuardrailStageOutput, "", strings.Join(joined, "\n\n"))
+	if blocked {
+		choice.Message.Content = cmp.Or(message, defaultGuardrailMessage)
+		choice.Message.ToolCalls = nil
+		if choice.FinishReason == openai.FinishReasonToolCalls {
+			choice.FinishReason = openai.FinishReasonStop
+		}
+		return
+	}
+
+	for _, text := range texts {
+		*text = g.redact(types.GuardrailStageOutput, *text)
+	}
+}
+
+// checkOutputStream checks the streamed answer, which means that it's only streamed once it's
+// complete. The chunks are streamed as they came, with what the guardrails changed.
+func (g *guard) checkOutputStream(ctx context.Context, stream *openai.ChatCompletionStream) (*openai.ChatCompletionStream, error) {
+	if !g.hasStage(types.GuardrailStageOutput) {
+		return stream, nil
+	}
+	defer stream.Close()
+
+	var chunks []openai.ChatCompletionStreamResponse
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	resp := streamedResponse(chunks)
+	g.checkOutput(ctx, resp)
+	chunks = replaceStreamed(chunks, resp.Choices[0])
+
+	downstream, downstreamWriter, err := transport.NewOpenAIStreamingAdapter(openai.ChatCompletionRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create streaming adapter: %w", err)
+	}
+
+	go func() {
+		defer downstreamWriter.Close()
+
+		for i := range chunks {
+			if err := transport.WriteChatCompletionStream(downstreamWriter, &chunks[i]); err != nil {
+				log.Error().Msgf("failed streaming message: %v", err)
+				return
+			}
+		}
+	}()
+
+	return downstream, nil
+}
+
+// streamedResponse puts the streamed answer back together, the guardrails check its first choice
+func streamedResponse(chunks []openai.ChatCompletionStreamResponse) *openai.ChatCompletionResponse {
+	choice := openai.ChatCompletionChoice{
+		Message: openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant},
+	}
+
+	var content strings.Builder
+	for _, chunk := range chunks {
+		for _, c := range chunk.Choices {
+			if c.Index != 0 {
+				continue
+			}
+			content.WriteString(c.Delta.Content)
+			if c.FinishReason != "" {
+				choice.FinishReason = c.FinishReason
+			}
+
+			for k, call := range c.Delta.ToolCalls {
+				index := toolCallIndex(call, k)
+				for len(choice.Message.ToolCalls) <= index {
+					choice.Message.ToolCalls = append(choice.Message.ToolCalls, openai.ToolCall{})
+				}
+				choice.Message.ToolCalls[index].Function.Arguments += call.Function.Arguments
+			}
+		}
+	}
+	choice.Message.Content = content.String()
+
+	return &openai.ChatCompletionResponse{Choices: []openai.ChatCompletionChoice{choice}}
+}
+
+// replaceStreamed puts the checked answer in the chunks of the first choice: its content goes in
+// the first chunk and each tool call's arguments in the tool call's first delta. The tool calls of
+// a blocked answer are left out.
+func replaceStreamed(chunks []openai.ChatCompletionStreamResponse, checked openai.ChatCompletionChoice) []openai.ChatCompletionStreamResponse {
+	contentSent := false
+	argumentsSent := make(map[int]bool)
+
+	for i := range chunks {
+		for j := range chunks[i].Choices {
+			c := &chunks[i].Choices[j]
+			if c.Index != 0 {
+				continue
+			}
+
+			c.Delta.Content = ""
+			if !contentSent {
+				c.Delta.Content = checked.Message.Content
+				contentSent = true
+			}
+
+			var calls []openai.ToolCall
+			for k, call := range c.Delta.ToolCalls {
+				index := toolCallIndex(call, k)
+				if index >= len(checked.Message.ToolCalls) {
+					continue
+				}
+				call.Function.Arguments = ""
+				if !argumentsSent[index] {
+					call.Function.Arguments = checked.Message.ToolCalls[index].Function.Arguments
+					argumentsSent[index] = true
+				}
+				calls = append(calls, call)
+			}
+			c.Delta.ToolCalls = calls
+
+			if c.FinishReason != "" {
+				c.FinishReason = checked.FinishReason
+			}
+		}
+	}
+
+	// The answer had no chunks of its own, e.g. the guardrail's message for an empty answer
+	if !contentSent && checked.Message.Content != "" {
+		chunk := openai.ChatCompletionStreamResponse{
+			Choices: []openai.ChatCompletionStreamChoice{
+				{Delta: openai.ChatCompletionStreamChoiceDelta{Content: checked.Message.Content}},
+			},
+		}
+		if len(chunks) > 0 {
+			last := chunks[len(chunks)-1]
+			chunk.ID, chunk.Object, chunk.Created, chunk.Model = last.ID, last.Object, last.Created, last.Model
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks
+}
+
+// toolCallIndex is which of the answer's tool calls the streamed tool call is part of, the
+// position of the delta when the provider doesn't say
+func toolCallIndex(call openai.ToolCall, position int) int {
+	if call.Index != nil {
+		return *call.Index
+	}
+	return position
+}
+
+// check runs the stage's guardrails on the content in order. It returns the content with what
+// was redacted from it, or the guardrail's message when it blocks it, empty when it doesn't have one.
+func (g *guard) check(ctx context.Context, stage types.GuardrailStage, source, content string) (string, string, bool) {
+	if g == nil {
+		return content, "", false
+	}
+
+	for i := range g.guardrails {
+		guardrail := &g.guardrails[i]
+		if !slices.Contains(data.GuardrailStages(guardrail), stage) {
+			continue
+		}
+
+		var (
+			findings []string
+			redacted = content
+		)
+
+		switch guardrail.Type {
+		case types.GuardrailTypePII:
+			var matches []guardrails.Match
+			redacted, matches = guardrails.RedactPII(content, guardrail.PII)
+			findings = guardrails.Kinds(matches)
+		case types.GuardrailTypePromptInjection:
+			var matches []guardrails.Match
+			redacted, matches = guardrails.RedactInjections(content)
+			findings = guardrails.Kinds(matches)
+		case types.GuardrailTypeTopics:
+			for _, topic := range guardrails.MatchTopics(content, guardrail.Deny) {
+				findings = append(findings, "denied topic: "+topic)
+			}
+			if len(guardrail.Allow) > 0 && len(guardrails.MatchTopics(content, guardrail.Allow)) == 0 {
+				findings = append(findings, "none of the allowed topics")
+			}
+		case types.GuardrailTypeClassifier:
+			allowed, reason, err := g.classify(ctx, guardrail, content)
+			if err != nil {
+				// Classifiers are best effort, the other guardrails still run
+				log.Warn().
+					Err(err).
+					Str("app_id", g.appID).
+					Msg("failed to classify message for guardrail")
+				continue
+			}
+			if !allowed {
+				if reason == "" {
+					reason = "against the policy"
+				}
+				findings = append(findings, reason)
+			}
+		}
+
+		if len(findings) == 0 {
+			continue
+		}
+
+		action := data.GuardrailAction(guardrail)
+		g.record(ctx, stage, guardrail.Type, action, source, findings)
+
+		switch action {
+		case types.GuardrailActionBlock:
+			return content, guardrail.Message, true
+		case types.GuardrailActionRedact:
+			content = redacted
+		}
+	}
+
+	return content, "", false
+}
+
+// classify asks the model whether the content follows the guardrail's policy
+func (g *guard) classify(ctx context.Context, guardrail *types.Guardrail, content string) (bool, string, error) {
+	prompt, err := prompts.GuardrailClassifierPrompt(guardrail.Policy, content)
+	if err != nil {
+		return false, "", fmt.Errorf("failed to build classifier prompt: %w", err)
+	}
+
+	provider := g.provider
+	if guardrail.Provider != "" {
+		provider = guardrail.Provider
+	}
+
+	modelName := g.model
+	if guardrail.Model != "" {
+		modelName, err = model.ProcessModelName(string(g.controller.Options.Config.Inference.Provider), guardrail.Model, types.SessionModeInference, types.SessionTypeText, false, false)
+		if err != nil {
+			return false, "", fmt.Errorf("invalid model name '%s': %w", guardrail.Model, err)
+		}
+	}
+
+	client, err := g.controller.getClient(ctx, provider)
+	if err != nil {
+		return false, "", fmt.Errorf("failed to get client: %w", err)
+	}
+
+	// The classifier's call is logged as a call of its own
+	ctx = oai.SetContextLLMCallID(ctx, "")
+	ctx = oai.SetStep(ctx, &oai.Step{
+		Step: types.LLMCallStepGuardrail,
+	})
+
+	resp, err := g.controller.structuredChatCompletion(ctx, client, provider, openai.ChatCompletionRequest{
+		Model: modelName,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: prompt,
+			},
+		},
+	}, &types.AssistantResponseSchema{Name: "guardrail", Schema: guardrailClassifierSchema})
+	if err != nil {
+		return false, "", err
+	}
+
+	var verdict struct {
+		Allowed bool   `json:"allowed"`
+		Reason  string `json:"reason"`
+	}
+	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &verdict); err != nil {
+		return false, "", fmt.Errorf("failed to parse classifier verdict: %w", err)
+	}
+
+	return verdict.Allowed, verdict.Reason, nil
+}
+
+func (g *guard) record(ctx context.Context, stage types.GuardrailStage, guardrailType types.GuardrailType, action types.GuardrailAction, source string, findings []string) {
+	vals, ok := oai.GetContextValues(ctx)
+	if !ok {
+		vals = &oai.ContextValues{}
+	}
+
+	log.Info().
+		Str("app_id", g.appID).
+		Str("session_id", vals.SessionID).
+		Str("stage", string(stage)).
+		Str("guardrail", string(guardrailType)).
+		Str("action", string(action)).
+		Strs("findings", findings).
+		Msg("guardrail caught a message")
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.outcomes = append(g.outcomes, &types.GuardrailOutcome{
+		AppID:         g.appID,
+		UserID:        g.user.ID,
+		SessionID:     vals.SessionID,
+		InteractionID: vals.InteractionID,
+		Stage:         stage,
+		Type:          guardrailType,
+		Action:        action,
+		Source:        source,
+		Findings:      findings,
+	})
+}
+
+// flush stores what the guardrails caught, pointing at the call that answered the request when
+// it was made
+func (g *guard) flush(ctx context.Context) {
+	if g == nil {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, outcome := range g.outcomes {
+		outcome.LLMCallID = g.llmCallID
+
+		if _, err := g.controller.Options.Store.CreateGuardrailOutcome(ctx, outcome); err != nil {
+			log.Error().
+				Err(err).
+				Str("app_id", g.appID).
+				Msg("failed to store guardrail outcome")
+		}
+	}
+
+	g.outcomes = nil
+}
+
+func guardrailResponse(message string) *openai.ChatCompletionResponse {
+	return &openai.ChatCompletionResponse{
+		Choices: []openai.ChatCompletionChoice{
+			{
+				Message: openai.ChatCompletionMessage{
+					Role:    openai.ChatMessageRoleAssistant,
+					Content: message,
+				},
+			},
+		},
+	}
+}
@@ -126,7 +126,9 @@ func (c *Controller) AddRunnerMetrics(_ context.Context, metrics *types.RunnerSt
 func (c *Controller) GetDashboardData(_ context.Context) (*types.DashboardData, error) {
 	runners := []*types.RunnerState{}
 	c.activeRunners.Range(func(_ string, metrics *types.RunnerState) bool {
-		runners = append(runners, metrics)
+		runner := *metrics
+		runner.MaintenanceState = c.scheduler.RunnerMaintenanceState(runner.ID)
+		runners = append(runners, &runner)
 		return true
 	})
 	summaryData, err := c.scheduler.DashboardData()
@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"github.com/helixml/helix/api/pkg/data"
+	"github.com/helixml/helix/api/pkg/guardrails"
 	"github.com/helixml/helix/api/pkg/model"
 	oai "github.com/helixml/helix/api/pkg/openai"
 	"github.com/helixml/helix/api/pkg/openai/manager"
@@ -26,8 +27,22 @@ type ChatCompletionOptions struct {
 	AssistantID string
 	RAGSourceID string
 	Provider    types.Provider
+	// AppRevision is set to the revision of the app that served the request
+	AppRevision int
+
+	// ExperimentSubject is who experiment variants are assigned to, defaults to the user
+	ExperimentSubject string
+	// Experiment and ExperimentVariant are set when the assistant runs an experiment
+	Experiment        string
+	ExperimentVariant string
 
 	QueryParams map[string]string
+
+	// ConversationSummary stands in for the older messages of the session, see SessionMessages
+	ConversationSummary string
+	// SessionHistory is set when the messages before the user's last one are the session's, see
+	// SessionMessages. The input guardrails checked them as they were sent.
+	SessionHistory bool
 }
 
 // ChatCompletion is used by the OpenAI compatible API. Doesn't handle any historical sessions, etc.
@@ -40,6 +55,26 @@ func (c *Controller) ChatCompletion(ctx context.Context, user *types.User, req o
 		return nil, nil, err
 	}
 
+	if opts.AppRevision > 0 {
+		ctx = oai.SetContextAppRevision(ctx, opts.AppRevision)
+	}
+
+	if opts.Experiment != "" {
+		ctx = oai.SetContextExperiment(ctx, opts.Experiment, opts.ExperimentVariant)
+	}
+
+	guard, err := c.newGuard(user, assistant, opts, req.Model)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer guard.flush(ctx)
+
+	ctx = guard.withContentCheck(ctx)
+
+	if message, blocked := guard.checkInput(ctx, &req, opts.SessionHistory); blocked {
+		return guardrailResponse(message), &req, nil
+	}
+
 	if len(assistant.Tools) > 0 {
 		// Check whether the app is configured for the call,
 		// if yes, execute the tools and return the response
@@ -49,12 +84,20 @@ func (c *Controller) ChatCompletion(ctx context.Context, user *types.User, req o
 		}
 
 		if ok {
+			guard.checkOutput(ctx, toolResp)
 			return toolResp, &req, nil
 		}
 	}
 
 	req = setSystemPrompt(&req, assistant.SystemPrompt)
 
+	enrichPromptWithSummary(&req, opts)
+
+	err = c.enrichPromptWithMemories(ctx, user, &req, assistant, opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to enrich prompt with memories: %w", err)
+	}
+
 	if assistant.Model != "" {
 		req.Model = assistant.Model
 
@@ -84,12 +127,26 @@ func (c *Controller) ChatCompletion(ctx context.Context, user *types.User, req o
 		return nil, nil, fmt.Errorf("failed to get client: %v", err)
 	}
 
+	ctx = guard.withLLMCallID(ctx)
+
+	if assistant.ResponseSchema != nil {
+		resp, err := c.structuredChatCompletion(ctx, client, opts.Provider, req, assistant.ResponseSchema)
+		if err != nil {
+			log.Err(err).Msg("error creating structured chat completion")
+			return nil, nil, err
+		}
+
+		guard.checkOutput(ctx, resp)
+		return resp, &req, nil
+	}
+
 	resp, err := client.CreateChatCompletion(ctx, req)
 	if err != nil {
 		log.Err(err).Msg("error creating chat completion")
 		return nil, nil, err
 	}
 
+	guard.checkOutput(ctx, &resp)
 	return &resp, &req, nil
 }
 
@@ -104,6 +161,30 @@ func (c *Controller) ChatCompletionStream(ctx context.Context, user *types.User,
 		return nil, nil, err
 	}
 
+	if opts.AppRevision > 0 {
+		ctx = oai.SetContextAppRevision(ctx, opts.AppRevision)
+	}
+
+	if opts.Experiment != "" {
+		ctx = oai.SetContextExperiment(ctx, opts.Experiment, opts.ExperimentVariant)
+	}
+
+	guard, err := c.newGuard(user, assistant, opts, req.Model)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer guard.flush(ctx)
+
+	ctx = guard.withContentCheck(ctx)
+
+	if message, blocked := guard.checkInput(ctx, &req, opts.SessionHistory); blocked {
+		stream, err := messageStream(message)
+		if err != nil {
+			return nil, nil, err
+		}
+		return stream, &req, nil
+	}
+
 	if len(assistant.Tools) > 0 {
 		// Check whether the app is configured for the call,
 		// if yes, execute the tools and return the response
@@ -113,12 +194,23 @@ func (c *Controller) ChatCompletionStream(ctx context.Context, user *types.User,
 		}
 
 		if ok {
+			toolRespStream, err = guard.checkOutputStream(ctx, toolRespStream)
+			if err != nil {
+				return nil, nil, err
+			}
 			return toolRespStream, &req, nil
 		}
 	}
 
 	req = setSystemPrompt(&req, assistant.SystemPrompt)
 
+	enrichPromptWithSummary(&req, opts)
+
+	err = c.enrichPromptWithMemories(ctx, user, &req, assistant, opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to enrich prompt with memories: %w", err)
+	}
+
 	if assistant.Model != "" {
 		req.Model = assistant.Model
 
@@ -149,12 +241,32 @@ func (c *Controller) ChatCompletionStream(ctx context.Context, user *types.User,
 		return nil, nil, fmt.Errorf("failed to get client: %v", err)
 	}
 
+	ctx = guard.withLLMCallID(ctx)
+
+	if assistant.ResponseSchema != nil {
+		stream, err := c.responseSchemaStream(ctx, client, opts.Provider, req, assistant.ResponseSchema)
+		if err != nil {
+			log.Err(err).Msg("error creating structured chat completion stream")
+			return nil, nil, err
+		}
+
+		stream, err = guard.checkOutputStream(ctx, stream)
+		if err != nil {
+			return nil, nil, err
+		}
+		return stream, &req, nil
+	}
+
 	stream, err := client.CreateChatCompletionStream(ctx, req)
 	if err != nil {
 		log.Err(err).Msg("error creating chat completion stream")
 		return nil, nil, err
 	}
 
+	stream, err = guard.checkOutputStream(ctx, stream)
+	if err != nil {
+		return nil, nil, err
+	}
 	return stream, &req, nil
 }
 
@@ -191,6 +303,23 @@ func (c *Controller) evaluateToolUsage(ctx context.Context, user *types.User, re
 
 	history := types.HistoryFromChatCompletionRequest(req)
 
+	message, stop, err := c.gateToolAction(ctx, user, vals.SessionID, vals.InteractionID, selectedTool, history, isActionable.API, appToolSource(opts))
+	if err != nil {
+		return nil, false, err
+	}
+	if stop {
+		return &openai.ChatCompletionResponse{
+			Choices: []openai.ChatCompletionChoice{
+				{
+					Message: openai.ChatCompletionMessage{
+						Role:    openai.ChatMessageRoleAssistant,
+						Content: message,
+					},
+				},
+			},
+		}, true, nil
+	}
+
 	if err := c.emitStepInfo(ctx, &types.StepInfo{
 		Name:    selectedTool.Name,
 		Type:    types.StepInfoTypeToolUse,
@@ -199,6 +328,11 @@ func (c *Controller) evaluateToolUsage(ctx context.Context, user *types.User, re
 		return nil, false, fmt.Errorf("failed to emit run action step info: %w", err)
 	}
 
+	// Tools that act on behalf of users get the accounts they linked for the app
+	if opts.AppID != "" {
+		ctx = oai.SetContextAppID(ctx, opts.AppID)
+	}
+
 	resp, err := c.ToolsPlanner.RunAction(ctx, vals.SessionID, vals.InteractionID, selectedTool, history, isActionable.API)
 	if err != nil {
 		if emitErr := c.emitStepInfo(ctx, &types.StepInfo{
@@ -248,6 +382,18 @@ func (c *Controller) evaluateToolUsageStream(ctx context.Context, user *types.Us
 
 	history := types.HistoryFromChatCompletionRequest(req)
 
+	message, stop, err := c.gateToolAction(ctx, user, vals.SessionID, vals.InteractionID, selectedTool, history, isActionable.API, appToolSource(opts))
+	if err != nil {
+		return nil, false, err
+	}
+	if stop {
+		stream, err := messageStream(message)
+		if err != nil {
+			return nil, false, err
+		}
+		return stream, true, nil
+	}
+
 	if err := c.emitStepInfo(ctx, &types.StepInfo{
 		Name:    selectedTool.Name,
 		Type:    types.StepInfoTypeToolUse,
@@ -256,6 +402,11 @@ func (c *Controller) evaluateToolUsageStream(ctx context.Context, user *types.Us
 		return nil, false, fmt.Errorf("failed to emit step info: %w", err)
 	}
 
+	// Tools that act on behalf of users get the accounts they linked for the app
+	if opts.AppID != "" {
+		ctx = oai.SetContextAppID(ctx, opts.AppID)
+	}
+
 	stream, err := c.ToolsPlanner.RunActionStream(ctx, vals.SessionID, vals.InteractionID, selectedTool, history, isActionable.API)
 	if err != nil {
 		log.Warn().
@@ -360,20 +511,35 @@ func (c *Controller) selectAndConfigureTool(ctx context.Context, user *types.Use
 				Str("assistant_model", assistant.Model).
 				Str("tool_name", selectedTool.Name).
 				Msg("assistant has configured a model, and tool has no model specified, using assistant model for tool")
-
-			selectedTool.Config.API.Model = assistant.Model
 		}
 	}
 
-	if len(opts.QueryParams) > 0 && selectedTool.Config.API != nil {
-		selectedTool.Config.API.Query = make(map[string]string)
+	configureTool(selectedTool, assistant.Model, opts.QueryParams)
 
-		for k, v := range opts.QueryParams {
-			selectedTool.Config.API.Query[k] = v
-		}
+	return selectedTool, isActionable, true, nil
+}
+
+// configureTool applies the assistant's model and the request's query parameters to a tool
+func configureTool(tool *types.Tool, model string, queryParams map[string]string) {
+	if tool.Config.MCP != nil && model != "" && tool.Config.MCP.Model == "" {
+		tool.Config.MCP.Model = model
 	}
 
-	return selectedTool, isActionable, true, nil
+	if tool.Config.API == nil {
+		return
+	}
+
+	if model != "" && tool.Config.API.Model == "" {
+		tool.Config.API.Model = model
+	}
+
+	if len(queryParams) > 0 {
+		tool.Config.API.Query = make(map[string]string)
+
+		for k, v := range queryParams {
+			tool.Config.API.Query[k] = v
+		}
+	}
 }
 
 func (c *Controller) loadAssistant(ctx context.Context, user *types.User, opts *ChatCompletionOptions) (*types.AssistantConfig, error) {
@@ -385,21 +551,38 @@ func (c *Controller) loadAssistant(ctx context.Context, user *types.User, opts *
 	// code to use apis, gptscripts, and zapier fields directly. Meanwhile, the
 	// flattened tools list is the internal only representation, and should not
 	// be exposed to the user.
-	app, err := c.Options.Store.GetAppWithTools(ctx, opts.AppID)
+	app, err := c.LoadApp(ctx, user, opts.AppID)
 	if err != nil {
-		return nil, fmt.Errorf("error getting app: %w", err)
+		return nil, err
 	}
 
 	if (!app.Global && !app.Shared) && app.Owner != user.ID {
 		return nil, fmt.Errorf("you do not have access to the app with the id: %s", app.ID)
 	}
 
+	opts.AppRevision = app.Revision
+
 	// Load secrets into the app
-	app, err = c.evaluateSecrets(ctx, user, app)
+	app, err = c.EvaluateSecrets(ctx, user, app)
 	if err != nil {
 		return nil, fmt.Errorf("failed to evaluate secrets: %w", err)
 	}
 
+	// Requests for an assistant that runs an experiment are served by the user's variant
+	if opts.Experiment == "" {
+		subject := opts.ExperimentSubject
+		if subject == "" {
+			subject = user.ID
+		}
+
+		experiment, variant := data.AssignExperimentVariant(app, opts.AssistantID, subject)
+		if variant != nil {
+			opts.AssistantID = variant.Assistant
+			opts.Experiment = experiment.Name
+			opts.ExperimentVariant = variant.ID
+		}
+	}
+
 	assistant := data.GetAssistant(app, opts.AssistantID)
 
 	if assistant == nil {
@@ -409,7 +592,8 @@ func (c *Controller) loadAssistant(ctx context.Context, user *types.User, opts *
 	return assistant, nil
 }
 
-func (c *Controller) evaluateSecrets(ctx context.Context, user *types.User, app *types.App) (*types.App, error) {
+// EvaluateSecrets substitutes the user's secrets into the app config
+func (c *Controller) EvaluateSecrets(ctx context.Context, user *types.User, app *types.App) (*types.App, error) {
 	secrets, err := c.Options.Store.ListSecrets(ctx, &store.ListSecretsQuery{
 		Owner: user.ID,
 	})
@@ -427,6 +611,21 @@ func (c *Controller) evaluateSecrets(ctx context.Context, user *types.User, app
 		filteredSecrets = append(filteredSecrets, secret)
 	}
 
+	// The environment's secrets come last so that they win over the owner's
+	environment, err := c.getUserAppEnvironment(ctx, user, app.ID)
+	if err != nil {
+		return nil, err
+	}
+	if environment != nil {
+		for name, value := range environment.Secrets {
+			filteredSecrets = append(filteredSecrets, &types.Secret{
+				Name:  name,
+				Value: []byte(value),
+				AppID: app.ID,
+			})
+		}
+	}
+
 	// Nothing to do
 	if len(filteredSecrets) == 0 {
 		return app, nil
@@ -473,6 +672,9 @@ func (c *Controller) enrichPromptWithKnowledge(ctx context.Context, user *types.
 		return fmt.Errorf("failed to load knowledge: %w", err)
 	}
 
+	ragResults = checkRAGContent(ctx, ragResults)
+	knowledgeResults = checkKnowledgeContent(ctx, knowledgeResults)
+
 	if len(ragResults) > 0 || len(knowledgeResults) > 0 {
 		// Extend last message with the RAG results
 		err := extendMessageWithKnowledge(req, ragResults, knowledge, knowledgeResults)
@@ -484,6 +686,39 @@ func (c *Controller) enrichPromptWithKnowledge(ctx context.Context, user *types.
 	return nil
 }
 
+// checkRAGContent runs the app's guardrails on the RAG results, blocked results are left out
+func checkRAGContent(ctx context.Context, results []*prompts.RagContent) []*prompts.RagContent {
+	checked := make([]*prompts.RagContent, 0, len(results))
+	for _, result := range results {
+		content, ok := guardrails.CheckContent(ctx, result.DocumentID, result.Content)
+		if !ok {
+			continue
+		}
+		result.Content = content
+		checked = append(checked, result)
+	}
+	return checked
+}
+
+// checkKnowledgeContent runs the app's guardrails on the knowledge, blocked knowledge is left out
+func checkKnowledgeContent(ctx context.Context, results []*prompts.BackgroundKnowledge) []*prompts.BackgroundKnowledge {
+	checked := make([]*prompts.BackgroundKnowledge, 0, len(results))
+	for _, result := range results {
+		source := result.Source
+		if source == "" {
+			source = result.DocumentID
+		}
+
+		content, ok := guardrails.CheckContent(ctx, source, result.Content)
+		if !ok {
+			continue
+		}
+		result.Content = content
+		checked = append(checked, result)
+	}
+	return checked
+}
+
 func (c *Controller) evaluateRAG(ctx context.Context, user *types.User, req openai.ChatCompletionRequest, opts *ChatCompletionOptions) ([]*prompts.RagContent, error) {
 	if opts.RAGSourceID == "" {
 		return []*prompts.RagContent{}, nil
@@ -61,9 +61,10 @@ func (suite *ControllerSuite) SetupTest() {
 	suite.rag = rag.NewMockRAG(ctrl)
 
 	suite.user = &types.User{
-		ID:       "user_id",
-		Email:    "foo@email.com",
-		FullName: "Foo Bar",
+		ID:        "user_id",
+		TokenType: types.TokenTypeKeycloak,
+		Email:     "foo@email.com",
+		FullName:  "Foo Bar",
 	}
 
 	cfg := &config.ServerConfig{}
@@ -234,7 +235,7 @@ func (suite *ControllerSuite) Test_EvaluateSecrets() {
 		},
 	}, nil)
 
-	app, err := suite.controller.evaluateSecrets(suite.ctx, suite.user, app)
+	app, err := suite.controller.EvaluateSecrets(suite.ctx, suite.user, app)
 	suite.NoError(err)
 
 	suite.Equal(app.Config.Helix.Assistants[0].Tools[0].Config.API.Headers["X-Secret-Key"], "secret_value")
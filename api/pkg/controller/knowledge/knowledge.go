@@ -15,6 +15,7 @@ import (
 	"github.com/helixml/helix/api/pkg/controller/knowledge/crawler"
 	"github.com/helixml/helix/api/pkg/extract"
 	"github.com/helixml/helix/api/pkg/filestore"
+	"github.com/helixml/helix/api/pkg/pubsub"
 	"github.com/helixml/helix/api/pkg/rag"
 	"github.com/helixml/helix/api/pkg/store"
 	"github.com/helixml/helix/api/pkg/types"
@@ -29,6 +30,7 @@ type Reconciler struct {
 	store        store.Store
 	filestore    filestore.FileStore
 	extractor    extract.Extractor // Unstructured.io or equivalent
+	publisher    pubsub.Publisher  // Publishes knowledge events, optional
 	httpClient   *http.Client
 	ragClient    rag.RAG                                   // Default server RAG client
 	newRagClient func(settings *types.RAGSettings) rag.RAG // Custom RAG server client constructor
@@ -37,7 +39,7 @@ type Reconciler struct {
 	wg           sync.WaitGroup
 }
 
-func New(config *config.ServerConfig, store store.Store, filestore filestore.FileStore, extractor extract.Extractor, ragClient rag.RAG, b *browser.Browser) (*Reconciler, error) {
+func New(config *config.ServerConfig, store store.Store, filestore filestore.FileStore, extractor extract.Extractor, ragClient rag.RAG, b *browser.Browser, publisher pubsub.Publisher) (*Reconciler, error) {
 	s, err := gocron.NewScheduler()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create scheduler: %w", err)
@@ -49,6 +51,7 @@ func New(config *config.ServerConfig, store store.Store, filestore filestore.Fil
 		filestore:  filestore,
 		cron:       s,
 		extractor:  extractor,
+		publisher:  publisher,
 		httpClient: http.DefaultClient,
 		ragClient:  ragClient,
 		newRagClient: func(settings *types.RAGSettings) rag.RAG {
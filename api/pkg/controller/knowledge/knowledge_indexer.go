@@ -15,6 +15,7 @@ import (
 	"github.com/sourcegraph/conc/pool"
 
 	"github.com/helixml/helix/api/pkg/dataprep/text"
+	"github.com/helixml/helix/api/pkg/pubsub"
 	"github.com/helixml/helix/api/pkg/rag"
 	"github.com/helixml/helix/api/pkg/store"
 	"github.com/helixml/helix/api/pkg/system"
@@ -79,6 +80,8 @@ func (r *Reconciler) index(ctx context.Context) error {
 				k.Message = err.Error()
 				_, _ = r.store.UpdateKnowledge(ctx, k)
 
+				r.publishEvent(ctx, types.EventTypeKnowledgeError, k)
+
 				// Create a failed version too just for logs
 				_, _ = r.store.CreateKnowledgeVersion(ctx, &types.KnowledgeVersion{
 					KnowledgeID: k.ID,
@@ -105,6 +108,8 @@ func (r *Reconciler) indexKnowledge(ctx context.Context, k *types.Knowledge, ver
 		if err != nil {
 			return fmt.Errorf("failed to update knowledge, error: %w", err)
 		}
+
+		r.publishEvent(ctx, types.EventTypeKnowledgeReady, k)
 		return nil
 	}
 
@@ -192,6 +197,8 @@ func (r *Reconciler) indexKnowledge(ctx context.Context, k *types.Knowledge, ver
 		Str("new_version", version).
 		Msg("knowledge indexed")
 
+	r.publishEvent(ctx, types.EventTypeKnowledgeReady, k)
+
 	// Delete old versions
 	err = r.deleteOldVersions(ctx, k)
 	if err != nil {
@@ -447,6 +454,22 @@ func (r *Reconciler) updateProgress(k *types.Knowledge, state types.KnowledgeSta
 	return r.store.UpdateKnowledgeState(context.Background(), k.ID, state, message, percent)
 }
 
+// publishEvent lets apps with event triggers react to the knowledge's state
+func (r *Reconciler) publishEvent(ctx context.Context, eventType types.EventType, k *types.Knowledge) {
+	if r.publisher == nil {
+		return
+	}
+
+	err := pubsub.PublishEvent(ctx, r.publisher, types.NewKnowledgeEvent(eventType, k))
+	if err != nil {
+		log.Warn().
+			Err(err).
+			Str("knowledge_id", k.ID).
+			Str("event_type", string(eventType)).
+			Msg("failed to publish knowledge event")
+	}
+}
+
 func getDocumentID(contents []byte) string {
 	hash := sha256.Sum256(contents)
 	hashString := hex.EncodeToString(hash[:])
@@ -2,6 +2,7 @@ package knowledge
 
 import (
 	"context"
+	"encoding/json"
 	"reflect"
 	"strings"
 	"testing"
@@ -13,6 +14,7 @@ import (
 	"github.com/helixml/helix/api/pkg/dataprep/text"
 	"github.com/helixml/helix/api/pkg/extract"
 	"github.com/helixml/helix/api/pkg/filestore"
+	"github.com/helixml/helix/api/pkg/pubsub"
 	"github.com/helixml/helix/api/pkg/rag"
 	"github.com/helixml/helix/api/pkg/store"
 	"github.com/helixml/helix/api/pkg/types"
@@ -57,7 +59,7 @@ func (suite *IndexerSuite) SetupTest() {
 
 	b := &browser.Browser{}
 
-	suite.reconciler, err = New(suite.cfg, suite.store, suite.filestore, suite.extractor, suite.rag, b)
+	suite.reconciler, err = New(suite.cfg, suite.store, suite.filestore, suite.extractor, suite.rag, b, nil)
 	suite.Require().NoError(err)
 
 	suite.reconciler.newRagClient = func(_ *types.RAGSettings) rag.RAG {
@@ -283,6 +285,47 @@ func (suite *IndexerSuite) TestIndex_UpdateLimitsWhenAbove() {
 	suite.reconciler.wg.Wait()
 }
 
+// recordingPublisher keeps the published payloads by topic
+type recordingPublisher struct {
+	published map[string][]byte
+}
+
+func (p *recordingPublisher) Publish(_ context.Context, topic string, payload []byte) error {
+	p.published[topic] = payload
+	return nil
+}
+
+func (suite *IndexerSuite) TestIndexKnowledge_PublishesReadyEvent() {
+	publisher := &recordingPublisher{published: make(map[string][]byte)}
+	suite.reconciler.publisher = publisher
+
+	content := "Helix is an AI platform"
+	knowledge := &types.Knowledge{
+		ID:    "knowledge_id",
+		Name:  "faq",
+		Owner: "user_id",
+		AppID: "app_id",
+		Source: types.KnowledgeSource{
+			Content: &content,
+		},
+	}
+
+	suite.store.EXPECT().UpdateKnowledge(gomock.Any(), gomock.Any()).Return(knowledge, nil)
+
+	err := suite.reconciler.indexKnowledge(suite.ctx, knowledge, "v1")
+	suite.Require().NoError(err)
+
+	payload, ok := publisher.published[pubsub.GetEventsQueue(types.EventTypeKnowledgeReady)]
+	suite.Require().True(ok, "knowledge.ready should be published")
+
+	var event types.Event
+	suite.Require().NoError(json.Unmarshal(payload, &event))
+	suite.Equal("user_id", event.Owner)
+	suite.Equal("app_id", event.AppID)
+	suite.Equal("faq", event.Knowledge.Name)
+	suite.Equal("v1", event.Knowledge.Version)
+}
+
 func (suite *IndexerSuite) Test_deleteOldVersions_LessThanMaxVersions() {
 	// Setup
 	knowledgeID := "test_knowledge_id"
@@ -0,0 +1,325 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	openai "github.com/sashabaranov/go-openai"
+
+	"github.com/helixml/helix/api/pkg/data"
+	oai "github.com/helixml/helix/api/pkg/openai"
+	"github.com/helixml/helix/api/pkg/prompts"
+	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+const defaultMemoryResultsCount = 5
+
+// memoriesSchema is what the model answers with when it extracts memories
+const memoriesSchema = `{
+  "type": "object",
+  "properties": {
+    "memories": {"type": "array", "items": {"type": "string"}}
+  },
+  "required": ["memories"]
+}`
+
+func memoryEnabled(assistant *types.AssistantConfig) bool {
+	return assistant != nil && assistant.Memory != nil && assistant.Memory.Enabled
+}
+
+// remembersUser is whether memories are kept for the user. Only users who signed in as themselves
+// have them: app API keys and triggers act as the app's owner on behalf of whoever is on the
+// other end, their memories would be everyone's.
+func remembersUser(user *types.User) bool {
+	if user.AppID != "" {
+		return false
+	}
+	return user.TokenType == types.TokenTypeKeycloak || user.TokenType == types.TokenTypeAPIKey
+}
+
+// memoryDataEntityID is the RAG index of the user's memories of the app
+func memoryDataEntityID(appID, userID string) string {
+	return fmt.Sprintf("memories_%s_%s", appID, userID)
+}
+
+// enrichPromptWithMemories adds what the assistant remembers about the user to the system prompt,
+// the memories that are relevant to the user's last message when they're indexed
+func (c *Controller) enrichPromptWithMemories(ctx context.Context, user *types.User, req *openai.ChatCompletionRequest, assistant *types.AssistantConfig, opts *ChatCompletionOptions) error {
+	if !memoryEnabled(assistant) || opts.AppID == "" || !remembersUser(user) {
+		return nil
+	}
+
+	limit := assistant.Memory.ResultsCount
+	if limit == 0 {
+		limit = defaultMemoryResultsCount
+	}
+
+	memories, err := c.recallMemories(ctx, opts.AppID, user.ID, lastUserMessage(req), limit)
+	if err != nil {
+		return err
+	}
+
+	if len(memories) == 0 {
+		return nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Here is what you remember about the user from earlier conversations:")
+	for _, memory := range memories {
+		sb.WriteString("\n- ")
+		sb.WriteString(memory)
+	}
+
+	if len(req.Messages) > 0 && req.Messages[0].Role == openai.ChatMessageRoleSystem {
+		req.Messages[0].Content = strings.TrimSpace(req.Messages[0].Content + "\n\n" + sb.String())
+		return nil
+	}
+
+	req.Messages = append([]openai.ChatCompletionMessage{
+		{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: sb.String(),
+		},
+	}, req.Messages...)
+
+	return nil
+}
+
+// recallMemories searches the user's memories for the query, without a RAG index the newest
+// memories are recalled
+func (c *Controller) recallMemories(ctx context.Context, appID, userID, query string, limit int) ([]string, error) {
+	if c.Options.RAG != nil && query != "" {
+		results, err := c.Options.RAG.Query(ctx, &types.SessionRAGQuery{
+			Prompt:       query,
+			DataEntityID: memoryDataEntityID(appID, userID),
+			MaxResults:   limit,
+		})
+		if err == nil {
+			var memories []string
+			for _, result := range results {
+				memories = append(memories, result.Content)
+			}
+			return memories, nil
+		}
+
+		log.Warn().
+			Err(err).
+			Str("app_id", appID).
+			Msg("failed to query memories, recalling the newest ones")
+	}
+
+	memories, err := c.Options.Store.ListMemories(ctx, &store.ListMemoriesQuery{
+		AppID:  appID,
+		UserID: userID,
+		Limit:  limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list memories: %w", err)
+	}
+
+	var contents []string
+	for _, memory := range memories {
+		contents = append(contents, memory.Content)
+	}
+	return contents, nil
+}
+
+// ExtractMemories asks the model what the assistant should remember about the user from their
+// last message and the answer to it, and remembers it. It does nothing for assistants without
+// memory, and for users that memories aren't kept for.
+func (c *Controller) ExtractMemories(ctx context.Context, user *types.User, req openai.ChatCompletionRequest, answer string, opts *ChatCompletionOptions) error {
+	if opts.AppID == "" || !remembersUser(user) {
+		return nil
+	}
+
+	app, err := c.LoadApp(ctx, user, opts.AppID)
+	if err != nil {
+		return err
+	}
+
+	assistant := data.GetAssistant(app, opts.AssistantID)
+	if !memoryEnabled(assistant) {
+		return nil
+	}
+
+	existing, err := c.Options.Store.ListMemories(ctx, &store.ListMemoriesQuery{
+		AppID:  app.ID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list memories: %w", err)
+	}
+
+	remembered := make(map[string]bool, len(existing))
+	var contents []string
+	for _, memory := range existing {
+		remembered[strings.ToLower(memory.Content)] = true
+		contents = append(contents, memory.Content)
+	}
+
+	prompt, err := prompts.MemoryPrompt(contents, []prompts.MemoryMessage{
+		{Role: openai.ChatMessageRoleUser, Content: lastUserMessage(&req)},
+		{Role: openai.ChatMessageRoleAssistant, Content: answer},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to build memory prompt: %w", err)
+	}
+
+	provider, modelName, err := c.assistantModel(assistant, opts.Provider, req.Model)
+	if err != nil {
+		return err
+	}
+
+	client, err := c.getClient(ctx, provider)
+	if err != nil {
+		return fmt.Errorf("failed to get client: %w", err)
+	}
+
+	resp, err := c.structuredChatCompletion(ctx, client, provider, openai.ChatCompletionRequest{
+		Model: modelName,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: prompt,
+			},
+		},
+	}, &types.AssistantResponseSchema{Name: "memories", Schema: memoriesSchema})
+	if err != nil {
+		return fmt.Errorf("failed to extract memories: %w", err)
+	}
+
+	var extracted struct {
+		Memories []string `json:"memories"`
+	}
+	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &extracted); err != nil {
+		return fmt.Errorf("failed to parse memories: %w", err)
+	}
+
+	var sessionID string
+	if values, ok := oai.GetContextValues(ctx); ok {
+		sessionID = values.SessionID
+	}
+
+	var chunks []*types.SessionRAGIndexChunk
+	for _, content := range extracted.Memories {
+		content = strings.TrimSpace(content)
+		if content == "" || remembered[strings.ToLower(content)] {
+			continue
+		}
+		remembered[strings.ToLower(content)] = true
+
+		memory, err := c.Options.Store.CreateMemory(ctx, &types.Memory{
+			AppID:     app.ID,
+			UserID:    user.ID,
+			SessionID: sessionID,
+			Content:   content,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create memory: %w", err)
+		}
+
+		chunks = append(chunks, memoryChunk(memory))
+	}
+
+	if len(chunks) == 0 || c.Options.RAG == nil {
+		return nil
+	}
+
+	if err := c.Options.RAG.Index(ctx, chunks...); err != nil {
+		return fmt.Errorf("failed to index memories: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateMemory changes what the assistant remembers
+func (c *Controller) UpdateMemory(ctx context.Context, memory *types.Memory) (*types.Memory, error) {
+	memory, err := c.Options.Store.UpdateMemory(ctx, memory)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.reindexMemories(ctx, memory.AppID, memory.UserID); err != nil {
+		return nil, err
+	}
+
+	return memory, nil
+}
+
+// DeleteMemory forgets the memory
+func (c *Controller) DeleteMemory(ctx context.Context, memory *types.Memory) error {
+	if err := c.Options.Store.DeleteMemory(ctx, memory.ID); err != nil {
+		return err
+	}
+
+	return c.reindexMemories(ctx, memory.AppID, memory.UserID)
+}
+
+// DeleteMemories forgets everything that the app's assistants remember about the user
+func (c *Controller) DeleteMemories(ctx context.Context, appID, userID string) error {
+	if err := c.Options.Store.DeleteMemories(ctx, &store.ListMemoriesQuery{AppID: appID, UserID: userID}); err != nil {
+		return err
+	}
+
+	return c.reindexMemories(ctx, appID, userID)
+}
+
+// reindexMemories indexes the user's memories of the app again, indexes can only be deleted as a
+// whole so that's how edited and deleted memories leave the index
+func (c *Controller) reindexMemories(ctx context.Context, appID, userID string) error {
+	if c.Options.RAG == nil {
+		return nil
+	}
+
+	err := c.Options.RAG.Delete(ctx, &types.DeleteIndexRequest{
+		DataEntityID: memoryDataEntityID(appID, userID),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete memory index: %w", err)
+	}
+
+	memories, err := c.Options.Store.ListMemories(ctx, &store.ListMemoriesQuery{
+		AppID:  appID,
+		UserID: userID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list memories: %w", err)
+	}
+
+	if len(memories) == 0 {
+		return nil
+	}
+
+	chunks := make([]*types.SessionRAGIndexChunk, 0, len(memories))
+	for _, memory := range memories {
+		chunks = append(chunks, memoryChunk(memory))
+	}
+
+	if err := c.Options.RAG.Index(ctx, chunks...); err != nil {
+		return fmt.Errorf("failed to index memories: %w", err)
+	}
+
+	return nil
+}
+
+func memoryChunk(memory *types.Memory) *types.SessionRAGIndexChunk {
+	return &types.SessionRAGIndexChunk{
+		DataEntityID: memoryDataEntityID(memory.AppID, memory.UserID),
+		Source:       memory.SessionID,
+		DocumentID:   memory.ID,
+		Content:      memory.Content,
+	}
+}
+
+func lastUserMessage(req *openai.ChatCompletionRequest) string {
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if req.Messages[i].Role == openai.ChatMessageRoleUser {
+			return req.Messages[i].Content
+		}
+	}
+	return ""
+}
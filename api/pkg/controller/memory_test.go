@@ -0,0 +1,163 @@
+package controller
+
+import (
+	"context"
+
+	openai "github.com/sashabaranov/go-openai"
+	"go.uber.org/mock/gomock"
+
+	oai "github.com/helixml/helix/api/pkg/openai"
+	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+func memoryApp() *types.App {
+	return &types.App{
+		ID:     "app_id",
+		Global: true,
+		Config: types.AppConfig{
+			Helix: types.AppHelixConfig{
+				Assistants: []types.AssistantConfig{
+					{
+						ID:           "0",
+						SystemPrompt: "You are a travel agent",
+						Memory:       &types.AssistantMemory{Enabled: true, ResultsCount: 2},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (suite *ControllerSuite) Test_InferenceWithMemories() {
+	suite.store.EXPECT().GetAppWithTools(suite.ctx, "app_id").Return(memoryApp(), nil)
+	suite.store.EXPECT().ListSecrets(gomock.Any(), gomock.Any()).Return([]*types.Secret{}, nil)
+
+	suite.rag.EXPECT().Query(suite.ctx, &types.SessionRAGQuery{
+		Prompt:       "Book me a flight to Paris",
+		DataEntityID: "memories_app_id_user_id",
+		MaxResults:   2,
+	}).Return([]*types.SessionRAGResult{
+		{DocumentID: "mem_1", Content: "Prefers window seats"},
+		{DocumentID: "mem_2", Content: "Lives in London"},
+	}, nil)
+
+	suite.openAiClient.EXPECT().CreateChatCompletion(suite.ctx, gomock.Any()).
+		DoAndReturn(func(_ context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+			suite.Equal(openai.ChatMessageRoleSystem, req.Messages[0].Role)
+			suite.Equal("You are a travel agent\n\nHere is what you remember about the user from earlier conversations:\n- Prefers window seats\n- Lives in London", req.Messages[0].Content)
+
+			return openai.ChatCompletionResponse{
+				Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: "Window seat from London it is"}}},
+			}, nil
+		})
+
+	_, _, err := suite.controller.ChatCompletion(suite.ctx, suite.user, openai.ChatCompletionRequest{
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleUser, Content: "Book me a flight to Paris"},
+		},
+	}, &ChatCompletionOptions{AppID: "app_id", AssistantID: "0"})
+	suite.NoError(err)
+}
+
+func (suite *ControllerSuite) Test_InferenceWithMemories_AppKey() {
+	suite.store.EXPECT().GetAppWithTools(suite.ctx, "app_id").Return(memoryApp(), nil)
+	suite.store.EXPECT().ListSecrets(gomock.Any(), gomock.Any()).Return([]*types.Secret{}, nil)
+
+	// Nothing is recalled, the memories would be the app owner's or another customer's
+	suite.openAiClient.EXPECT().CreateChatCompletion(suite.ctx, gomock.Any()).
+		DoAndReturn(func(_ context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+			suite.Equal("You are a travel agent", req.Messages[0].Content)
+
+			return openai.ChatCompletionResponse{
+				Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: "Where from?"}}},
+			}, nil
+		})
+
+	appKeyUser := &types.User{ID: "user_id", TokenType: types.TokenTypeAPIKey, AppID: "app_id"}
+	_, _, err := suite.controller.ChatCompletion(suite.ctx, appKeyUser, openai.ChatCompletionRequest{
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleUser, Content: "Book me a flight to Paris"},
+		},
+	}, &ChatCompletionOptions{AppID: "app_id", AssistantID: "0"})
+	suite.NoError(err)
+}
+
+func (suite *ControllerSuite) Test_ExtractMemories_NotForAppKeysOrTriggers() {
+	for _, user := range []*types.User{
+		{ID: "user_id", TokenType: types.TokenTypeAPIKey, AppID: "app_id"},
+		// Triggers run as the app's owner
+		{ID: "user_id"},
+	} {
+		err := suite.controller.ExtractMemories(suite.ctx, user, openai.ChatCompletionRequest{
+			Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "I'm vegetarian"}},
+		}, "Noted", &ChatCompletionOptions{AppID: "app_id", AssistantID: "0"})
+		suite.NoError(err)
+	}
+}
+
+func (suite *ControllerSuite) Test_ExtractMemories() {
+	ctx := oai.SetContextValues(suite.ctx, &oai.ContextValues{SessionID: "ses_1"})
+
+	suite.store.EXPECT().GetAppWithTools(ctx, "app_id").Return(memoryApp(), nil)
+	suite.store.EXPECT().ListMemories(ctx, &store.ListMemoriesQuery{AppID: "app_id", UserID: "user_id"}).
+		Return([]*types.Memory{{ID: "mem_1", Content: "Prefers window seats"}}, nil)
+
+	suite.openAiClient.EXPECT().CreateChatCompletion(ctx, gomock.Any()).
+		DoAndReturn(func(_ context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+			suite.Contains(req.Messages[0].Content, "- Prefers window seats")
+			suite.Contains(req.Messages[0].Content, "user: I'm vegetarian, book me a flight to Paris")
+
+			return openai.ChatCompletionResponse{
+				Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{
+					Content: "```json\n{\"memories\": [\"prefers window seats\", \"Is vegetarian\", \" \"]}\n```",
+				}}},
+			}, nil
+		})
+
+	suite.store.EXPECT().CreateMemory(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, memory *types.Memory) (*types.Memory, error) {
+		suite.Equal(&types.Memory{AppID: "app_id", UserID: "user_id", SessionID: "ses_1", Content: "Is vegetarian"}, memory)
+		memory.ID = "mem_2"
+		return memory, nil
+	})
+
+	suite.rag.EXPECT().Index(ctx, &types.SessionRAGIndexChunk{
+		DataEntityID: "memories_app_id_user_id",
+		Source:       "ses_1",
+		DocumentID:   "mem_2",
+		Content:      "Is vegetarian",
+	}).Return(nil)
+
+	err := suite.controller.ExtractMemories(ctx, suite.user, openai.ChatCompletionRequest{
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: "You are a helpful assistant."},
+			{Role: openai.ChatMessageRoleUser, Content: "I'm vegetarian, book me a flight to Paris"},
+		},
+	}, "Booked, with a vegetarian meal", &ChatCompletionOptions{AppID: "app_id", AssistantID: "0"})
+	suite.NoError(err)
+}
+
+func (suite *ControllerSuite) Test_ExtractMemories_Disabled() {
+	app := memoryApp()
+	app.Config.Helix.Assistants[0].Memory = nil
+
+	suite.store.EXPECT().GetAppWithTools(suite.ctx, "app_id").Return(app, nil)
+
+	err := suite.controller.ExtractMemories(suite.ctx, suite.user, openai.ChatCompletionRequest{}, "answer", &ChatCompletionOptions{AppID: "app_id", AssistantID: "0"})
+	suite.NoError(err)
+}
+
+func (suite *ControllerSuite) Test_DeleteMemory_Reindexes() {
+	suite.store.EXPECT().DeleteMemory(suite.ctx, "mem_1").Return(nil)
+	suite.rag.EXPECT().Delete(suite.ctx, &types.DeleteIndexRequest{DataEntityID: "memories_app_id_user_id"}).Return(nil)
+	suite.store.EXPECT().ListMemories(suite.ctx, &store.ListMemoriesQuery{AppID: "app_id", UserID: "user_id"}).
+		Return([]*types.Memory{{ID: "mem_2", AppID: "app_id", UserID: "user_id", Content: "Lives in London"}}, nil)
+	suite.rag.EXPECT().Index(suite.ctx, &types.SessionRAGIndexChunk{
+		DataEntityID: "memories_app_id_user_id",
+		DocumentID:   "mem_2",
+		Content:      "Lives in London",
+	}).Return(nil)
+
+	err := suite.controller.DeleteMemory(suite.ctx, &types.Memory{ID: "mem_1", AppID: "app_id", UserID: "user_id"})
+	suite.NoError(err)
+}
@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	openai "github.com/sashabaranov/go-openai"
+
+	"github.com/helixml/helix/api/pkg/data"
+	oai "github.com/helixml/helix/api/pkg/openai"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+const defaultResponseSchemaRetries = 2
+
+// ResponseSchemaError is returned when the assistant's response still doesn't match its response
+// schema after the retries
+type ResponseSchemaError struct {
+	// Content is the last response
+	Content string
+	// Problems are the ways the last response doesn't match the schema
+	Problems []string
+}
+
+func (e *ResponseSchemaError) Error() string {
+	return fmt.Sprintf("response doesn't match the response schema: %s", strings.Join(e.Problems, "; "))
+}
+
+// structuredChatCompletion runs the completion until the response matches the assistant's
+// response schema. Providers that support JSON Schema response formats are asked to stick to the
+// schema, the helix runners do this with grammar-constrained decoding. Other providers get the
+// schema in the system prompt. Responses that don't match are sent back to the model with the
+// problems, up to the schema's retries.
+func (c *Controller) structuredChatCompletion(ctx context.Context, client oai.Client, provider types.Provider, req openai.ChatCompletionRequest, responseSchema *types.AssistantResponseSchema) (*openai.ChatCompletionResponse, error) {
+	schema, err := data.ParseResponseSchema(responseSchema.Schema)
+	if err != nil {
+		return nil, err
+	}
+
+	if provider == "" {
+		provider = c.Options.Config.Inference.Provider
+	}
+
+	// Callers can ask for their own response format
+	if req.ResponseFormat == nil {
+		if supportsJSONSchema(provider) {
+			name := responseSchema.Name
+			if name == "" {
+				name = "response"
+			}
+
+			req.ResponseFormat = &openai.ChatCompletionResponseFormat{
+				Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+				JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+					Name:   name,
+					Schema: schema,
+				},
+			}
+		} else {
+			req.Messages = append(req.Messages, openai.ChatCompletionMessage{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: fmt.Sprintf("Respond only with JSON that matches this JSON Schema:\n%s", schema),
+			})
+		}
+	}
+
+	retries := responseSchema.Retries
+	if retries == 0 {
+		retries = defaultResponseSchemaRetries
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := client.CreateChatCompletion(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(resp.Choices) == 0 {
+			return nil, fmt.Errorf("no choices in the response")
+		}
+
+		content, problems, err := data.ValidateResponse(schema, resp.Choices[0].Message.Content)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(problems) == 0 {
+			resp.Choices[0].Message.Content = content
+			return &resp, nil
+		}
+
+		if attempt >= retries {
+			return nil, &ResponseSchemaError{
+				Content:  resp.Choices[0].Message.Content,
+				Problems: problems,
+			}
+		}
+
+		log.Info().
+			Int("attempt", attempt+1).
+			Strs("problems", problems).
+			Msg("response doesn't match the response schema, retrying")
+
+		// Retries are logged as calls of their own
+		ctx = oai.SetContextLLMCallID(ctx, "")
+
+		req.Messages = append(req.Messages,
+			resp.Choices[0].Message,
+			openai.ChatCompletionMessage{
+				Role:    openai.ChatMessageRoleUser,
+				Content: responseSchemaFeedback(problems),
+			},
+		)
+	}
+}
+
+func responseSchemaFeedback(problems []string) string {
+	var sb strings.Builder
+	sb.WriteString("Your response doesn't match the JSON Schema:\n")
+	for _, problem := range problems {
+		sb.WriteString("- ")
+		sb.WriteString(problem)
+		sb.WriteString("\n")
+	}
+	sb.WriteString("Respond again with only the corrected JSON.")
+	return sb.String()
+}
+
+// supportsJSONSchema is whether the provider can be asked for a JSON Schema response format
+func supportsJSONSchema(provider types.Provider) bool {
+	switch provider {
+	case types.ProviderOpenAI, types.ProviderHelix:
+		return true
+	default:
+		return false
+	}
+}
+
+// responseSchemaStream streams the response, which has to be validated in full before sending it
+func (c *Controller) responseSchemaStream(ctx context.Context, client oai.Client, provider types.Provider, req openai.ChatCompletionRequest, responseSchema *types.AssistantResponseSchema) (*openai.ChatCompletionStream, error) {
+	req.Stream = false
+
+	resp, err := c.structuredChatCompletion(ctx, client, provider, req, responseSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	return messageStream(resp.Choices[0].Message.Content)
+}
@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/helixml/helix/api/pkg/config"
+	oai "github.com/helixml/helix/api/pkg/openai"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+var testResponseSchema = &types.AssistantResponseSchema{
+	Name:   "person",
+	Schema: `{"type": "object", "properties": {"age": {"type": "integer"}}, "required": ["age"]}`,
+}
+
+func completion(content string) openai.ChatCompletionResponse {
+	return openai.ChatCompletionResponse{
+		Choices: []openai.ChatCompletionChoice{
+			{Message: openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: content}},
+		},
+	}
+}
+
+func TestStructuredChatCompletion_RetriesWithFeedback(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	client := oai.NewMockClient(ctrl)
+
+	c := &Controller{Options: Options{Config: &config.ServerConfig{}}}
+
+	gomock.InOrder(
+		client.EXPECT().CreateChatCompletion(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+				require.NotNil(t, req.ResponseFormat)
+				assert.Equal(t, openai.ChatCompletionResponseFormatTypeJSONSchema, req.ResponseFormat.Type)
+				assert.Equal(t, "person", req.ResponseFormat.JSONSchema.Name)
+				return completion(`{"age": "thirty"}`), nil
+			}),
+		client.EXPECT().CreateChatCompletion(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+				require.Len(t, req.Messages, 3)
+				assert.Equal(t, `{"age": "thirty"}`, req.Messages[1].Content)
+				assert.Contains(t, req.Messages[2].Content, "age")
+				return completion("```json\n{\"age\": 30}\n```"), nil
+			}),
+	)
+
+	resp, err := c.structuredChatCompletion(context.Background(), client, types.ProviderHelix, openai.ChatCompletionRequest{
+		Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "How old is Ada?"}},
+	}, testResponseSchema)
+	require.NoError(t, err)
+	assert.Equal(t, `{"age": 30}`, resp.Choices[0].Message.Content)
+}
+
+func TestStructuredChatCompletion_FailsAfterRetries(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	client := oai.NewMockClient(ctrl)
+
+	c := &Controller{Options: Options{Config: &config.ServerConfig{}}}
+
+	client.EXPECT().CreateChatCompletion(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+			// Without native support the schema goes in the prompt
+			assert.Nil(t, req.ResponseFormat)
+			assert.Contains(t, req.Messages[1].Content, "JSON Schema")
+			return completion("Ada is 30"), nil
+		}).Times(3)
+
+	_, err := c.structuredChatCompletion(context.Background(), client, types.ProviderTogetherAI, openai.ChatCompletionRequest{
+		Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "How old is Ada?"}},
+	}, testResponseSchema)
+
+	var schemaErr *ResponseSchemaError
+	require.ErrorAs(t, err, &schemaErr)
+	assert.Equal(t, "Ada is 30", schemaErr.Content)
+	assert.Equal(t, []string{"response is not valid JSON"}, schemaErr.Problems)
+}
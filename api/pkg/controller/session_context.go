@@ -0,0 +1,299 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	openai "github.com/sashabaranov/go-openai"
+
+	"github.com/helixml/helix/api/pkg/data"
+	"github.com/helixml/helix/api/pkg/model"
+	oai "github.com/helixml/helix/api/pkg/openai"
+	"github.com/helixml/helix/api/pkg/prompts"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+const defaultContextKeepMessages = 6
+
+// contextMessage is one of the session's interactions on its way to the model
+type contextMessage struct {
+	// interaction is the index of the interaction in the session
+	interaction int
+	pinned      bool
+	message     openai.ChatCompletionMessage
+	tokens      int
+}
+
+// SessionMessages returns the messages of the session's first n interactions to send to the model,
+// as the context strategy of the app's assistant decides. Failed and empty interactions are left
+// out. Summarising assistants fold the older messages into the session's conversation summary,
+// which the caller saves with the session, and the summary is added to the system prompt through
+// opts. opts also records that the messages are the session's, so that the input guardrails don't
+// check them again.
+func (c *Controller) SessionMessages(ctx context.Context, user *types.User, session *types.Session, n int, modelName string, opts *ChatCompletionOptions) ([]openai.ChatCompletionMessage, error) {
+	opts.SessionHistory = true
+
+	var history []*contextMessage
+	for i, interaction := range session.Interactions[:n] {
+		if interaction.State == types.InteractionStateError || interaction.Message == "" {
+			continue
+		}
+
+		history = append(history, &contextMessage{
+			interaction: i,
+			pinned:      interaction.Pinned,
+			message: openai.ChatCompletionMessage{
+				Role:    string(interaction.Creator),
+				Content: interaction.Message,
+			},
+		})
+	}
+
+	if opts.AppID == "" {
+		return chatMessages(history), nil
+	}
+
+	app, err := c.LoadApp(ctx, user, opts.AppID)
+	if err != nil {
+		return nil, err
+	}
+
+	assistant := data.GetAssistant(app, opts.AssistantID)
+	if assistant == nil || assistant.Context == nil {
+		return chatMessages(history), nil
+	}
+
+	strategy := assistant.Context.Strategy
+	if strategy == "" || strategy == types.ContextStrategyFull {
+		return chatMessages(history), nil
+	}
+
+	provider, modelName, err := c.assistantModel(assistant, opts.Provider, modelName)
+	if err != nil {
+		return nil, err
+	}
+
+	budget := assistant.Context.MaxTokens
+	if budget == 0 {
+		budget = model.GetContextLength(modelName) / 2
+	}
+
+	for _, m := range history {
+		m.tokens = model.CountMessageTokens(modelName, m.message)
+	}
+
+	if strategy == types.ContextStrategySummarize {
+		history = c.summarizeSession(ctx, session, history, assistant.Context, provider, modelName, budget)
+
+		if summary := session.Metadata.ConversationSummary; summary != nil {
+			opts.ConversationSummary = summary.Content
+			budget -= model.CountTokens(modelName, summary.Content)
+		}
+	}
+
+	return chatMessages(truncateMessages(history, budget)), nil
+}
+
+// assistantModel is the provider and the model that answer for the assistant
+func (c *Controller) assistantModel(assistant *types.AssistantConfig, provider types.Provider, modelName string) (types.Provider, string, error) {
+	if assistant.Provider != "" {
+		provider = assistant.Provider
+	}
+
+	if assistant.Model != "" {
+		var err error
+		modelName, err = model.ProcessModelName(string(c.Options.Config.Inference.Provider), assistant.Model, types.SessionModeInference, types.SessionTypeText, false, false)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid model name '%s': %w", assistant.Model, err)
+		}
+	}
+
+	return provider, modelName, nil
+}
+
+// summarizeSession leaves out the messages that the session's summary already covers, except for
+// pinned ones, and when the rest don't fit in the budget it folds all but the latest of them into
+// the summary. Without a new summary the messages are truncated instead.
+func (c *Controller) summarizeSession(ctx context.Context, session *types.Session, history []*contextMessage, cfg *types.AssistantContext, provider types.Provider, modelName string, budget int) []*contextMessage {
+	var (
+		covered int
+		summary string
+	)
+	if session.Metadata.ConversationSummary != nil {
+		covered = session.Metadata.ConversationSummary.Interactions
+		summary = session.Metadata.ConversationSummary.Content
+	}
+
+	var messages []*contextMessage
+	for _, m := range history {
+		if m.interaction >= covered || m.pinned {
+			messages = append(messages, m)
+		}
+	}
+
+	if countTokens(messages)+model.CountTokens(modelName, summary) <= budget {
+		return messages
+	}
+
+	keep := cfg.KeepMessages
+	if keep == 0 {
+		keep = defaultContextKeepMessages
+	}
+
+	cut := len(messages) - keep
+	if cut <= 0 {
+		return messages
+	}
+
+	var fold, rest []*contextMessage
+	for i, m := range messages {
+		if i < cut && !m.pinned && m.interaction >= covered {
+			fold = append(fold, m)
+		} else {
+			rest = append(rest, m)
+		}
+	}
+
+	if len(fold) == 0 {
+		return messages
+	}
+
+	content, err := c.summarizeMessages(ctx, provider, modelName, summary, fold)
+	if err != nil {
+		log.Warn().
+			Err(err).
+			Str("session_id", session.ID).
+			Msg("failed to summarise session, truncating it instead")
+		return messages
+	}
+
+	session.Metadata.ConversationSummary = &types.ConversationSummary{
+		Content:      content,
+		Interactions: messages[cut].interaction,
+		Updated:      time.Now(),
+	}
+
+	return rest
+}
+
+// summarizeMessages asks the model to fold the messages into the summary
+func (c *Controller) summarizeMessages(ctx context.Context, provider types.Provider, modelName, summary string, messages []*contextMessage) (string, error) {
+	var conversation []prompts.MemoryMessage
+	for _, m := range messages {
+		conversation = append(conversation, prompts.MemoryMessage{Role: m.message.Role, Content: m.message.Content})
+	}
+
+	prompt, err := prompts.ConversationSummaryPrompt(summary, conversation)
+	if err != nil {
+		return "", fmt.Errorf("failed to build summary prompt: %w", err)
+	}
+
+	client, err := c.getClient(ctx, provider)
+	if err != nil {
+		return "", fmt.Errorf("failed to get client: %w", err)
+	}
+
+	ctx = oai.SetStep(ctx, &oai.Step{
+		Step: types.LLMCallStepSummariseSession,
+	})
+
+	resp, err := client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model: modelName,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: prompt,
+			},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if len(resp.Choices) == 0 || strings.TrimSpace(resp.Choices[0].Message.Content) == "" {
+		return "", errors.New("no data in the LLM response")
+	}
+
+	return strings.TrimSpace(resp.Choices[0].Message.Content), nil
+}
+
+// enrichPromptWithSummary adds the summary of the session's earlier conversation to the system
+// prompt
+func enrichPromptWithSummary(req *openai.ChatCompletionRequest, opts *ChatCompletionOptions) {
+	if opts.ConversationSummary == "" {
+		return
+	}
+
+	summary := "Summary of the earlier conversation:\n" + opts.ConversationSummary
+
+	if len(req.Messages) > 0 && req.Messages[0].Role == openai.ChatMessageRoleSystem {
+		req.Messages[0].Content = strings.TrimSpace(req.Messages[0].Content + "\n\n" + summary)
+		return
+	}
+
+	req.Messages = append([]openai.ChatCompletionMessage{
+		{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: summary,
+		},
+	}, req.Messages...)
+}
+
+// truncateMessages leaves out the oldest messages that don't fit in the budget, the last message
+// and pinned messages are always kept
+func truncateMessages(messages []*contextMessage, budget int) []*contextMessage {
+	if len(messages) == 0 || countTokens(messages) <= budget {
+		return messages
+	}
+
+	last := len(messages) - 1
+	keep := make([]bool, len(messages))
+	keep[last] = true
+	budget -= messages[last].tokens
+
+	for i, m := range messages[:last] {
+		if m.pinned {
+			keep[i] = true
+			budget -= m.tokens
+		}
+	}
+
+	for i := last - 1; i >= 0; i-- {
+		if keep[i] {
+			continue
+		}
+		if messages[i].tokens > budget {
+			break
+		}
+		keep[i] = true
+		budget -= messages[i].tokens
+	}
+
+	var kept []*contextMessage
+	for i, m := range messages {
+		if keep[i] {
+			kept = append(kept, m)
+		}
+	}
+	return kept
+}
+
+func countTokens(messages []*contextMessage) int {
+	tokens := 0
+	for _, m := range messages {
+		tokens += m.tokens
+	}
+	return tokens
+}
+
+func chatMessages(messages []*contextMessage) []openai.ChatCompletionMessage {
+	chat := make([]openai.ChatCompletionMessage, 0, len(messages))
+	for _, m := range messages {
+		chat = append(chat, m.message)
+	}
+	return chat
+}
@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	openai "github.com/sashabaranov/go-openai"
+	"go.uber.org/mock/gomock"
+
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+func contextApp(context *types.AssistantContext) *types.App {
+	return &types.App{
+		ID:     "app_id",
+		Global: true,
+		Config: types.AppConfig{
+			Helix: types.AppHelixConfig{
+				Assistants: []types.AssistantConfig{
+					{
+						ID:           "0",
+						SystemPrompt: "You are a research partner",
+						Context:      context,
+					},
+				},
+			},
+		},
+	}
+}
+
+// contextSession has ten answered interactions, taking turns between the user and the assistant,
+// and the waiting answer to the last one
+func contextSession() *types.Session {
+	session := &types.Session{ID: "ses_1"}
+	for i := 0; i < 10; i++ {
+		creator := types.CreatorTypeUser
+		if i%2 == 1 {
+			creator = types.CreatorTypeAssistant
+		}
+		session.Interactions = append(session.Interactions, &types.Interaction{
+			Creator: creator,
+			State:   types.InteractionStateComplete,
+			Message: fmt.Sprintf("message %d about the research project", i),
+		})
+	}
+	session.Interactions = append(session.Interactions, &types.Interaction{
+		Creator: types.CreatorTypeAssistant,
+		State:   types.InteractionStateWaiting,
+	})
+	return session
+}
+
+func messageContents(messages []openai.ChatCompletionMessage) []string {
+	var contents []string
+	for _, message := range messages {
+		contents = append(contents, message.Content)
+	}
+	return contents
+}
+
+func (suite *ControllerSuite) Test_SessionMessages_Full() {
+	suite.store.EXPECT().GetAppWithTools(suite.ctx, "app_id").Return(contextApp(nil), nil)
+
+	session := contextSession()
+	session.Interactions[3].State = types.InteractionStateError
+
+	opts := &ChatCompletionOptions{AppID: "app_id", AssistantID: "0"}
+	messages, err := suite.controller.SessionMessages(suite.ctx, suite.user, session, len(session.Interactions)-1, "", opts)
+	suite.Require().NoError(err)
+
+	suite.Len(messages, 9)
+	suite.Equal(openai.ChatCompletionMessage{Role: "user", Content: "message 0 about the research project"}, messages[0])
+	suite.Equal("message 4 about the research project", messages[3].Content)
+}
+
+func (suite *ControllerSuite) Test_SessionMessages_Truncate() {
+	suite.store.EXPECT().GetAppWithTools(suite.ctx, "app_id").Return(contextApp(&types.AssistantContext{
+		Strategy:  types.ContextStrategyTruncate,
+		MaxTokens: 60,
+	}), nil)
+
+	session := contextSession()
+	session.Interactions[0].Pinned = true
+
+	opts := &ChatCompletionOptions{AppID: "app_id", AssistantID: "0"}
+	messages, err := suite.controller.SessionMessages(suite.ctx, suite.user, session, len(session.Interactions)-1, "", opts)
+	suite.Require().NoError(err)
+
+	suite.Equal([]string{
+		"message 0 about the research project",
+		"message 8 about the research project",
+		"message 9 about the research project",
+	}, messageContents(messages))
+	suite.Nil(session.Metadata.ConversationSummary)
+}
+
+func (suite *ControllerSuite) Test_SessionMessages_Summarize() {
+	suite.store.EXPECT().GetAppWithTools(suite.ctx, "app_id").Return(contextApp(&types.AssistantContext{
+		Strategy:     types.ContextStrategySummarize,
+		MaxTokens:    100,
+		KeepMessages: 4,
+	}), nil)
+
+	session := contextSession()
+	session.Interactions[2].Pinned = true
+
+	suite.openAiClient.EXPECT().CreateChatCompletion(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+			suite.Contains(req.Messages[0].Content, "user: message 0 about the research project\nassistant: message 1 about the research project\nassistant: message 3")
+			suite.NotContains(req.Messages[0].Content, "message 2")
+			suite.NotContains(req.Messages[0].Content, "message 6")
+
+			return openai.ChatCompletionResponse{
+				Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: " The user is researching coral reefs. "}}},
+			}, nil
+		})
+
+	opts := &ChatCompletionOptions{AppID: "app_id", AssistantID: "0"}
+	messages, err := suite.controller.SessionMessages(suite.ctx, suite.user, session, len(session.Interactions)-1, "", opts)
+	suite.Require().NoError(err)
+
+	suite.Equal([]string{
+		"message 2 about the research project",
+		"message 6 about the research project",
+		"message 7 about the research project",
+		"message 8 about the research project",
+		"message 9 about the research project",
+	}, messageContents(messages))
+
+	suite.Require().NotNil(session.Metadata.ConversationSummary)
+	suite.Equal("The user is researching coral reefs.", session.Metadata.ConversationSummary.Content)
+	suite.Equal(6, session.Metadata.ConversationSummary.Interactions)
+	suite.Equal("The user is researching coral reefs.", opts.ConversationSummary)
+}
+
+func (suite *ControllerSuite) Test_SessionMessages_SummaryCoversOlderMessages() {
+	suite.store.EXPECT().GetAppWithTools(suite.ctx, "app_id").Return(contextApp(&types.AssistantContext{
+		Strategy:  types.ContextStrategySummarize,
+		MaxTokens: 1000,
+	}), nil)
+
+	session := contextSession()
+	session.Metadata.ConversationSummary = &types.ConversationSummary{
+		Content:      "The user is researching coral reefs.",
+		Interactions: 8,
+	}
+
+	opts := &ChatCompletionOptions{AppID: "app_id", AssistantID: "0"}
+	messages, err := suite.controller.SessionMessages(suite.ctx, suite.user, session, len(session.Interactions)-1, "", opts)
+	suite.Require().NoError(err)
+
+	suite.Equal([]string{
+		"message 8 about the research project",
+		"message 9 about the research project",
+	}, messageContents(messages))
+	suite.Equal("The user is researching coral reefs.", opts.ConversationSummary)
+}
+
+func (suite *ControllerSuite) Test_InferenceWithConversationSummary() {
+	suite.store.EXPECT().GetAppWithTools(suite.ctx, "app_id").Return(contextApp(nil), nil)
+	suite.store.EXPECT().ListSecrets(gomock.Any(), gomock.Any()).Return([]*types.Secret{}, nil)
+
+	suite.openAiClient.EXPECT().CreateChatCompletion(suite.ctx, gomock.Any()).
+		DoAndReturn(func(_ context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+			suite.Equal("You are a research partner\n\nSummary of the earlier conversation:\nThe user is researching coral reefs.", req.Messages[0].Content)
+
+			return openai.ChatCompletionResponse{
+				Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: "Bleaching is caused by heat stress"}}},
+			}, nil
+		})
+
+	_, _, err := suite.controller.ChatCompletion(suite.ctx, suite.user, openai.ChatCompletionRequest{
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleUser, Content: "What causes bleaching?"},
+		},
+	}, &ChatCompletionOptions{AppID: "app_id", AssistantID: "0", ConversationSummary: "The user is researching coral reefs."})
+	suite.NoError(err)
+}
@@ -852,6 +852,23 @@ func (c *Controller) ErrorSession(ctx context.Context, session *types.Session, s
 	if err := c.Options.Janitor.WriteSessionError(session, sessionErr); err != nil {
 		log.Error().Err(err).Msg("failed to write janitor session error")
 	}
+
+	c.publishTriggerEvent(ctx, types.NewSessionEvent(types.EventTypeSessionError, session, sessionErr.Error()))
+}
+
+// publishTriggerEvent lets apps with event triggers react to the session
+func (c *Controller) publishTriggerEvent(ctx context.Context, event *types.Event) {
+	if c.Options.PubSub == nil {
+		return
+	}
+
+	err := pubsub.PublishEvent(ctx, c.Options.PubSub, event)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("event_type", string(event.Type)).
+			Msg("failed to publish event")
+	}
 }
 
 // add the given session onto the end of the queue
@@ -886,6 +903,8 @@ func (c *Controller) HandleRunnerResponse(ctx context.Context, taskResponse *typ
 		return nil, fmt.Errorf("session not found: %s", taskResponse.SessionID)
 	}
 
+	finetuneCompleted := false
+
 	session, err = data.UpdateAssistantInteraction(session, func(targetInteraction *types.Interaction) (*types.Interaction, error) {
 		// mark the interaction as complete if we are a fully finished response
 		if taskResponse.Type == types.WorkerTaskResponseTypeResult {
@@ -962,6 +981,8 @@ func (c *Controller) HandleRunnerResponse(ctx context.Context, taskResponse *typ
 				if err != nil {
 					log.Ctx(ctx).Error().Msgf("error notifying finetuning completed: %s", err.Error())
 				}
+
+				finetuneCompleted = true
 			}
 		}
 
@@ -976,7 +997,13 @@ func (c *Controller) HandleRunnerResponse(ctx context.Context, taskResponse *typ
 		return nil, err
 	}
 
+	if finetuneCompleted {
+		c.publishTriggerEvent(ctx, types.NewSessionEvent(types.EventTypeFinetuneCompleted, session, ""))
+	}
+
 	if taskResponse.Error != "" {
+		c.publishTriggerEvent(ctx, types.NewSessionEvent(types.EventTypeSessionError, session, taskResponse.Error))
+
 		// NOTE: we don't return here as
 		if err := c.Options.Janitor.WriteSessionError(session, errors.New(taskResponse.Error)); err != nil {
 			return nil, fmt.Errorf("failed writing session error: %s : %v", taskResponse.Error, err)
@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/helixml/helix/api/pkg/data"
+	oai "github.com/helixml/helix/api/pkg/openai"
 	"github.com/helixml/helix/api/pkg/types"
 	"github.com/rs/zerolog/log"
 )
@@ -83,6 +84,47 @@ func (c *Controller) runActionInteraction(ctx context.Context, session *types.Se
 		Str("history", fmt.Sprintf("%+v", messageHistory)).
 		Msg("Running tool action")
 
+	source := toolSource{QueryParams: session.Metadata.AppQueryParams}
+	if session.ParentApp != "" {
+		source.AppID = session.ParentApp
+		source.AssistantID = session.Metadata.AssistantID
+	} else {
+		source.ToolID = toolID
+	}
+
+	user := &types.User{ID: session.Owner, Type: session.OwnerType}
+
+	message, stop, err := c.gateToolAction(ctx, user, session.ID, assistantInteraction.ID, tool, messageHistory, action, source)
+	if err != nil {
+		return nil, err
+	}
+	if stop {
+		updated, err = data.UpdateAssistantInteraction(session, func(assistantInteraction *types.Interaction) (*types.Interaction, error) {
+			assistantInteraction.Finished = true
+			assistantInteraction.Message = message
+			assistantInteraction.Metadata["tool_id"] = toolID
+			assistantInteraction.Metadata["tool_app_id"] = session.ParentApp
+			assistantInteraction.Metadata["tool_action"] = action
+			assistantInteraction.State = types.InteractionStateComplete
+
+			return assistantInteraction, nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to update assistant interaction: %w", err)
+		}
+
+		if err := c.WriteSession(ctx, updated); err != nil {
+			log.Err(err).Msg("failed writing session")
+		}
+
+		return updated, nil
+	}
+
+	// Tools that act on behalf of users get the accounts they linked for the app
+	if session.ParentApp != "" {
+		ctx = oai.SetContextAppID(ctx, session.ParentApp)
+	}
+
 	resp, err := c.ToolsPlanner.RunAction(ctx, session.ID, assistantInteraction.ID, tool, messageHistory, action)
 	if err != nil {
 		return nil, fmt.Errorf("failed to perform action: %w", err)
@@ -0,0 +1,398 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	openai "github.com/sashabaranov/go-openai"
+
+	oai "github.com/helixml/helix/api/pkg/openai"
+	"github.com/helixml/helix/api/pkg/openai/transport"
+	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/tools"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+// toolApprovalTTL is how long an action waits for the user's decision
+const toolApprovalTTL = 24 * time.Hour
+
+// toolApprovalDecidedByPolicy marks decisions made by the action policy rather than a user
+const toolApprovalDecidedByPolicy = "policy"
+
+var (
+	ErrToolApprovalDecided = errors.New("the action has already been approved or denied")
+	ErrToolApprovalExpired = errors.New("the action waited too long for approval, ask again")
+)
+
+// toolSource is where a tool was loaded from, so that it can be loaded again once approved
+type toolSource struct {
+	AppID       string
+	AssistantID string
+	ToolID      string
+	QueryParams map[string]string
+}
+
+// gateToolAction applies the action's policy before it runs. When the action isn't allowed or
+// needs the user's approval, it returns the message to reply with instead of running the action.
+func (c *Controller) gateToolAction(ctx context.Context, user *types.User, sessionID, interactionID string, tool *types.Tool, history []*types.ToolHistoryMessage, action string, source toolSource) (string, bool, error) {
+	policy := tools.ActionPolicy(tool, action)
+	if policy == types.ToolActionPolicyAuto {
+		return "", false, nil
+	}
+
+	approval := newToolApproval(user, tool, action, source)
+	approval.SessionID = sessionID
+	approval.InteractionID = interactionID
+
+	if policy == types.ToolActionPolicyDeny {
+		if err := c.recordDeniedToolAction(ctx, approval); err != nil {
+			return "", false, err
+		}
+		return fmt.Sprintf("The %s action of %s is not allowed.", action, tool.Name), true, nil
+	}
+
+	// Anything but auto and deny needs approval, so that a typo in a policy fails safe
+	if err := c.emitStepInfo(ctx, &types.StepInfo{
+		Name:    tool.Name,
+		Type:    types.StepInfoTypeToolUse,
+		Message: "Preparing action for approval",
+	}); err != nil {
+		log.Warn().Err(err).Msg("failed to emit step info")
+	}
+
+	var request *types.ToolApprovalRequest
+	var err error
+	if tool.ToolType == types.ToolTypeMCP {
+		request, err = c.ToolsPlanner.PrepareMCPAction(ctx, sessionID, interactionID, tool, history, action)
+	} else {
+		request, err = c.ToolsPlanner.PrepareAPIAction(ctx, sessionID, interactionID, tool, history, action)
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to prepare action: %w", err)
+	}
+	request.QueryParams = source.QueryParams
+
+	approval, err = c.requestToolApproval(ctx, approval, request)
+	if err != nil {
+		return "", false, err
+	}
+
+	return toolApprovalMessage(approval), true, nil
+}
+
+// RunAPIActionWithParameters runs an API action with parameters given by the caller, such as an
+// MCP client, rather than worked out from a chat. The action's policy applies as it does in
+// chats: actions that need approval wait for the user to approve them and the response says
+// what they're approving.
+func (c *Controller) RunAPIActionWithParameters(ctx context.Context, user *types.User, appID, assistantID string, req *types.RunAPIActionRequest) (*types.RunAPIActionResponse, error) {
+	policy := tools.ActionPolicy(req.Tool, req.Action)
+	if policy == types.ToolActionPolicyAuto {
+		return c.ToolsPlanner.RunAPIActionWithParameters(ctx, req)
+	}
+
+	approval := newToolApproval(user, req.Tool, req.Action, toolSource{AppID: appID, AssistantID: assistantID})
+
+	if policy == types.ToolActionPolicyDeny {
+		if err := c.recordDeniedToolAction(ctx, approval); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %s", tools.ErrActionDenied, req.Action)
+	}
+
+	request, err := c.ToolsPlanner.PrepareAPIActionWithParameters(ctx, req.Tool, req.Action, req.Parameters)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare action: %w", err)
+	}
+
+	approval, err = c.requestToolApproval(ctx, approval, request)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.RunAPIActionResponse{
+		Response:   toolApprovalMessage(approval),
+		ApprovalID: approval.ID,
+	}, nil
+}
+
+func newToolApproval(user *types.User, tool *types.Tool, action string, source toolSource) *types.ToolApproval {
+	return &types.ToolApproval{
+		Owner:       user.ID,
+		OwnerType:   user.Type,
+		AppID:       source.AppID,
+		AssistantID: source.AssistantID,
+		ToolID:      source.ToolID,
+		ToolName:    tool.Name,
+		Action:      action,
+	}
+}
+
+// recordDeniedToolAction keeps actions the policy didn't allow in the audit trail
+func (c *Controller) recordDeniedToolAction(ctx context.Context, approval *types.ToolApproval) error {
+	approval.State = types.ToolApprovalStateDenied
+	approval.DecidedBy = toolApprovalDecidedByPolicy
+	approval.DecidedAt = time.Now()
+	approval.Reason = "denied by the tool's action policy"
+
+	if _, err := c.Options.Store.CreateToolApproval(ctx, approval); err != nil {
+		return fmt.Errorf("failed to record denied action: %w", err)
+	}
+
+	logToolApprovalDecision(approval)
+	return nil
+}
+
+// requestToolApproval creates a pending approval for the request and lets the user know
+func (c *Controller) requestToolApproval(ctx context.Context, approval *types.ToolApproval, request *types.ToolApprovalRequest) (*types.ToolApproval, error) {
+	approval.Request = *request
+	approval.State = types.ToolApprovalStatePending
+
+	approval, err := c.Options.Store.CreateToolApproval(ctx, approval)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tool approval: %w", err)
+	}
+
+	c.publishToolApproval(ctx, approval)
+	return approval, nil
+}
+
+// toolApprovalMessage tells the user exactly what they're approving
+func toolApprovalMessage(approval *types.ToolApproval) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "The %s action of %s needs your approval before it runs:\n\n", approval.Action, approval.ToolName)
+	// MCP tool calls have arguments but no request line
+	if approval.Request.Method != "" {
+		fmt.Fprintf(&sb, "%s %s\n", approval.Request.Method, approval.Request.URL)
+	}
+
+	if len(approval.Request.Parameters) > 0 {
+		params, err := json.MarshalIndent(approval.Request.Parameters, "", "  ")
+		if err == nil {
+			fmt.Fprintf(&sb, "\n```json\n%s\n```\n", params)
+		}
+	}
+
+	fmt.Fprintf(&sb, "\nApprove or deny it to continue (approval ID: %s).", approval.ID)
+	return sb.String()
+}
+
+func (c *Controller) publishToolApproval(ctx context.Context, approval *types.ToolApproval) {
+	_ = c.publishEvent(ctx, &types.WebsocketEvent{
+		Type:          types.WebsocketEventToolApproval,
+		SessionID:     approval.SessionID,
+		InteractionID: approval.InteractionID,
+		Owner:         approval.Owner,
+		ToolApproval:  approval,
+	})
+}
+
+func logToolApprovalDecision(approval *types.ToolApproval) {
+	log.Info().
+		Str("approval_id", approval.ID).
+		Str("owner", approval.Owner).
+		Str("app_id", approval.AppID).
+		Str("tool", approval.ToolName).
+		Str("action", approval.Action).
+		Str("method", approval.Request.Method).
+		Str("url", approval.Request.URL).
+		Str("state", string(approval.State)).
+		Str("decided_by", approval.DecidedBy).
+		Str("reason", approval.Reason).
+		Msg("tool action decision")
+}
+
+// DecideToolApproval records the user's decision on a pending action and, if they approved it,
+// runs the action exactly as it was shown to them
+func (c *Controller) DecideToolApproval(ctx context.Context, user *types.User, id string, decision *types.ToolApprovalDecision) (*types.ToolApproval, error) {
+	approval, err := c.Options.Store.GetToolApproval(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the user the action runs for can decide on it
+	if approval.Owner != user.ID {
+		return nil, store.ErrNotFound
+	}
+
+	if approval.State != types.ToolApprovalStatePending {
+		return nil, ErrToolApprovalDecided
+	}
+
+	approval.DecidedAt = time.Now()
+	approval.DecidedBy = user.ID
+	approval.Reason = decision.Reason
+
+	switch {
+	case time.Since(approval.Created) > toolApprovalTTL:
+		approval.State = types.ToolApprovalStateExpired
+	case decision.Approved:
+		approval.State = types.ToolApprovalStateApproved
+	default:
+		approval.State = types.ToolApprovalStateDenied
+	}
+
+	decided, err := c.Options.Store.DecideToolApproval(ctx, approval)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			// Someone else got there first
+			return nil, ErrToolApprovalDecided
+		}
+		return nil, fmt.Errorf("failed to record decision: %w", err)
+	}
+
+	logToolApprovalDecision(decided)
+
+	switch decided.State {
+	case types.ToolApprovalStateExpired:
+		c.publishToolApproval(ctx, decided)
+		return nil, ErrToolApprovalExpired
+	case types.ToolApprovalStateDenied:
+		c.updateToolApprovalInteraction(ctx, decided, fmt.Sprintf("You denied the %s action of %s.", decided.Action, decided.ToolName))
+		c.publishToolApproval(ctx, decided)
+		return decided, nil
+	}
+
+	resp, err := c.runApprovedToolAction(ctx, user, decided)
+	if err != nil {
+		decided.Error = err.Error()
+	} else {
+		decided.Response = resp.Message
+	}
+
+	updated, updateErr := c.Options.Store.UpdateToolApproval(ctx, decided)
+	if updateErr != nil {
+		log.Err(updateErr).Str("approval_id", decided.ID).Msg("failed to record tool action result")
+	} else {
+		decided = updated
+	}
+
+	message := decided.Response
+	if decided.Error != "" {
+		message = fmt.Sprintf("The %s action failed: %s", decided.Action, decided.Error)
+	}
+	c.updateToolApprovalInteraction(ctx, decided, message)
+	c.publishToolApproval(ctx, decided)
+
+	return decided, nil
+}
+
+func (c *Controller) runApprovedToolAction(ctx context.Context, user *types.User, approval *types.ToolApproval) (*tools.RunActionResponse, error) {
+	tool, err := c.loadApprovalTool(ctx, user, approval)
+	if err != nil {
+		return nil, err
+	}
+
+	configureTool(tool, approval.Request.Model, approval.Request.QueryParams)
+
+	ctx = oai.SetContextValues(ctx, &oai.ContextValues{
+		OwnerID:       user.ID,
+		SessionID:     approval.SessionID,
+		InteractionID: approval.InteractionID,
+	})
+	// Tools that act on behalf of users get the accounts they linked for the app
+	if approval.AppID != "" {
+		ctx = oai.SetContextAppID(ctx, approval.AppID)
+	}
+
+	if tool.ToolType == types.ToolTypeMCP {
+		return c.ToolsPlanner.RunApprovedMCPAction(ctx, approval.SessionID, approval.InteractionID, tool, approval.Action, &approval.Request)
+	}
+	return c.ToolsPlanner.RunApprovedAPIAction(ctx, approval.SessionID, approval.InteractionID, tool, approval.Action, &approval.Request)
+}
+
+// loadApprovalTool loads the tool again, with the user's secrets, rather than keeping credentials
+// in the approval
+func (c *Controller) loadApprovalTool(ctx context.Context, user *types.User, approval *types.ToolApproval) (*types.Tool, error) {
+	if approval.AppID == "" {
+		if approval.ToolID == "" {
+			return nil, fmt.Errorf("approval %s has no tool", approval.ID)
+		}
+		return c.Options.Store.GetTool(ctx, approval.ToolID)
+	}
+
+	assistant, err := c.loadAssistant(ctx, user, &ChatCompletionOptions{
+		AppID:       approval.AppID,
+		AssistantID: approval.AssistantID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tool := range assistant.Tools {
+		if tool.Name == approval.ToolName {
+			return tool, nil
+		}
+	}
+	return nil, fmt.Errorf("tool %s not found in app %s", approval.ToolName, approval.AppID)
+}
+
+// updateToolApprovalInteraction replaces the pending approval message in the session with the
+// outcome
+func (c *Controller) updateToolApprovalInteraction(ctx context.Context, approval *types.ToolApproval, message string) {
+	if approval.SessionID == "" || approval.InteractionID == "" {
+		return
+	}
+
+	session, err := c.Options.Store.GetSession(ctx, approval.SessionID)
+	if err != nil {
+		log.Err(err).Str("session_id", approval.SessionID).Msg("failed to get session for tool approval")
+		return
+	}
+
+	found := false
+	for _, interaction := range session.Interactions {
+		if interaction.ID == approval.InteractionID {
+			interaction.Message = message
+			interaction.Completed = time.Now()
+			interaction.Finished = true
+			interaction.State = types.InteractionStateComplete
+			found = true
+		}
+	}
+	if !found {
+		return
+	}
+
+	if err := c.WriteSession(ctx, session); err != nil {
+		log.Err(err).Str("session_id", approval.SessionID).Msg("failed to update session with tool action result")
+	}
+}
+
+// messageStream streams a single message, for replies that don't come from an LLM
+func messageStream(message string) (*openai.ChatCompletionStream, error) {
+	downstream, downstreamWriter, err := transport.NewOpenAIStreamingAdapter(openai.ChatCompletionRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create streaming adapter: %w", err)
+	}
+
+	go func() {
+		defer downstreamWriter.Close()
+
+		if err := transport.WriteChatCompletionStream(downstreamWriter, &openai.ChatCompletionStreamResponse{
+			Choices: []openai.ChatCompletionStreamChoice{
+				{
+					Delta: openai.ChatCompletionStreamChoiceDelta{
+						Content: message,
+					},
+				},
+			},
+		}); err != nil {
+			log.Error().Msgf("failed streaming message: %v", err)
+		}
+	}()
+
+	return downstream, nil
+}
+
+func appToolSource(opts *ChatCompletionOptions) toolSource {
+	return toolSource{
+		AppID:       opts.AppID,
+		AssistantID: opts.AssistantID,
+		QueryParams: opts.QueryParams,
+	}
+}
@@ -0,0 +1,38 @@
+package data
+
+import (
+	"fmt"
+
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+// ValidateContexts checks the context strategies of the app's assistants
+func ValidateContexts(config *types.AppHelixConfig) error {
+	for i, assistant := range config.Assistants {
+		if assistant.Context == nil {
+			continue
+		}
+
+		name := assistant.Name
+		if name == "" {
+			name = fmt.Sprintf("%d", i)
+		}
+
+		switch assistant.Context.Strategy {
+		case "", types.ContextStrategyFull, types.ContextStrategyTruncate, types.ContextStrategySummarize:
+		default:
+			return fmt.Errorf("assistant %s: unknown context strategy '%s', expected %s, %s or %s", name,
+				assistant.Context.Strategy, types.ContextStrategyFull, types.ContextStrategyTruncate, types.ContextStrategySummarize)
+		}
+
+		if assistant.Context.MaxTokens < 0 {
+			return fmt.Errorf("assistant %s: context max tokens can't be negative", name)
+		}
+
+		if assistant.Context.KeepMessages < 0 {
+			return fmt.Errorf("assistant %s: context keep messages can't be negative", name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+func TestValidateContexts(t *testing.T) {
+	tests := []struct {
+		name    string
+		context *types.AssistantContext
+		wantErr string
+	}{
+		{name: "no context"},
+		{name: "default strategy", context: &types.AssistantContext{MaxTokens: 2000}},
+		{name: "summarize", context: &types.AssistantContext{Strategy: types.ContextStrategySummarize, KeepMessages: 4}},
+		{
+			name:    "unknown strategy",
+			context: &types.AssistantContext{Strategy: "forget"},
+			wantErr: "assistant support: unknown context strategy 'forget', expected full, truncate or summarize",
+		},
+		{
+			name:    "negative max tokens",
+			context: &types.AssistantContext{Strategy: types.ContextStrategyTruncate, MaxTokens: -1},
+			wantErr: "assistant support: context max tokens can't be negative",
+		},
+		{
+			name:    "negative keep messages",
+			context: &types.AssistantContext{KeepMessages: -2},
+			wantErr: "assistant support: context keep messages can't be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateContexts(&types.AppHelixConfig{
+				Assistants: []types.AssistantConfig{{Name: "support", Context: tt.context}},
+			})
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package data
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+// AssignExperimentVariant returns the experiment running on the assistant and the variant that
+// the subject, usually the user, is assigned to. The assignment only depends on the experiment
+// and the subject, so the same user keeps getting the same variant. Returns nil when no experiment
+// runs on the assistant.
+func AssignExperimentVariant(app *types.App, assistantID, subject string) (*types.Experiment, *types.ExperimentVariant) {
+	requested := assistantIndex(app, assistantID)
+	if requested < 0 {
+		return nil, nil
+	}
+
+	for i := range app.Config.Helix.Experiments {
+		experiment := &app.Config.Helix.Experiments[i]
+		if len(experiment.Variants) == 0 || assistantIndex(app, experiment.Assistant) != requested {
+			continue
+		}
+
+		h := fnv.New32a()
+		fmt.Fprintf(h, "%s:%s", experiment.Name, subject)
+		bucket := int(h.Sum32() % 100)
+
+		total := 0
+		for j := range experiment.Variants {
+			total += experiment.Variants[j].Weight
+			if bucket < total {
+				return experiment, &experiment.Variants[j]
+			}
+		}
+
+		// Weights that don't add up to 100 leave the rest on the last variant
+		return experiment, &experiment.Variants[len(experiment.Variants)-1]
+	}
+
+	return nil, nil
+}
+
+// ValidateExperiments checks that the experiments' assistants exist, and that the variants have
+// unique IDs and weights adding up to 100
+func ValidateExperiments(config *types.AppHelixConfig) error {
+	app := &types.App{Config: types.AppConfig{Helix: *config}}
+
+	names := make(map[string]bool)
+	assistants := make(map[int]string)
+
+	for _, experiment := range config.Experiments {
+		if experiment.Name == "" {
+			return fmt.Errorf("experiment name is required")
+		}
+		if names[experiment.Name] {
+			return fmt.Errorf("experiment %s is defined more than once", experiment.Name)
+		}
+		names[experiment.Name] = true
+
+		assistant := assistantIndex(app, experiment.Assistant)
+		if assistant < 0 {
+			return fmt.Errorf("experiment %s: assistant %s not found", experiment.Name, experiment.Assistant)
+		}
+		if other, ok := assistants[assistant]; ok {
+			return fmt.Errorf("experiment %s: experiment %s already runs on the assistant", experiment.Name, other)
+		}
+		assistants[assistant] = experiment.Name
+
+		if len(experiment.Variants) == 0 {
+			return fmt.Errorf("experiment %s: at least one variant is required", experiment.Name)
+		}
+
+		variants := make(map[string]bool)
+		total := 0
+		for _, variant := range experiment.Variants {
+			if variant.ID == "" {
+				return fmt.Errorf("experiment %s: variant id is required", experiment.Name)
+			}
+			if variants[variant.ID] {
+				return fmt.Errorf("experiment %s: variant %s is defined more than once", experiment.Name, variant.ID)
+			}
+			variants[variant.ID] = true
+
+			if assistantIndex(app, variant.Assistant) < 0 {
+				return fmt.Errorf("experiment %s: assistant %s of variant %s not found", experiment.Name, variant.Assistant, variant.ID)
+			}
+			if variant.Weight < 0 {
+				return fmt.Errorf("experiment %s: weight of variant %s can't be negative", experiment.Name, variant.ID)
+			}
+			total += variant.Weight
+		}
+
+		if total != 100 {
+			return fmt.Errorf("experiment %s: variant weights add up to %d, not 100", experiment.Name, total)
+		}
+	}
+
+	return nil
+}
+
+// assistantIndex finds the assistant the same way as GetAssistant, returning its index or -1
+func assistantIndex(app *types.App, assistantID string) int {
+	if assistantID == "" {
+		assistantID = "0"
+	}
+
+	if IsInteger(assistantID) {
+		idx, _ := strconv.Atoi(assistantID)
+		if idx >= 0 && idx < len(app.Config.Helix.Assistants) {
+			return idx
+		}
+	}
+
+	for i, assistant := range app.Config.Helix.Assistants {
+		if assistant.ID == assistantID {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,74 @@
+package data
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+func experimentTestApp() *types.App {
+	return &types.App{
+		Config: types.AppConfig{
+			Helix: types.AppHelixConfig{
+				Assistants: []types.AssistantConfig{
+					{ID: "control", SystemPrompt: "Be helpful."},
+					{ID: "concise", SystemPrompt: "Be helpful and brief."},
+					{ID: "other"},
+				},
+				Experiments: []types.Experiment{
+					{
+						Name: "brevity",
+						Variants: []types.ExperimentVariant{
+							{ID: "a", Assistant: "control", Weight: 50},
+							{ID: "b", Assistant: "concise", Weight: 50},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestAssignExperimentVariant(t *testing.T) {
+	app := experimentTestApp()
+
+	counts := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		subject := fmt.Sprintf("user_%d", i)
+
+		experiment, variant := AssignExperimentVariant(app, "", subject)
+		require.NotNil(t, experiment)
+		require.NotNil(t, variant)
+		assert.Equal(t, "brevity", experiment.Name)
+		counts[variant.ID]++
+
+		// Users keep their variant
+		_, again := AssignExperimentVariant(app, "control", subject)
+		assert.Equal(t, variant.ID, again.ID)
+	}
+
+	assert.InDelta(t, 500, counts["a"], 100)
+	assert.InDelta(t, 500, counts["b"], 100)
+}
+
+func TestAssignExperimentVariant_OtherAssistant(t *testing.T) {
+	experiment, variant := AssignExperimentVariant(experimentTestApp(), "other", "user_1")
+	assert.Nil(t, experiment)
+	assert.Nil(t, variant)
+}
+
+func TestValidateExperiments(t *testing.T) {
+	app := experimentTestApp()
+	require.NoError(t, ValidateExperiments(&app.Config.Helix))
+
+	app.Config.Helix.Experiments[0].Variants[1].Weight = 40
+	assert.EqualError(t, ValidateExperiments(&app.Config.Helix), "experiment brevity: variant weights add up to 90, not 100")
+
+	app = experimentTestApp()
+	app.Config.Helix.Experiments[0].Variants[1].Assistant = "missing"
+	assert.EqualError(t, ValidateExperiments(&app.Config.Helix), "experiment brevity: assistant missing of variant b not found")
+}
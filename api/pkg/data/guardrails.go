@@ -0,0 +1,98 @@
+package data
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+// GuardrailAction is what the guardrail does when it catches something
+func GuardrailAction(guardrail *types.Guardrail) types.GuardrailAction {
+	if guardrail.Action != "" {
+		return guardrail.Action
+	}
+	if guardrail.Type == types.GuardrailTypePII {
+		return types.GuardrailActionRedact
+	}
+	return types.GuardrailActionBlock
+}
+
+// GuardrailStages are where the guardrail checks messages
+func GuardrailStages(guardrail *types.Guardrail) []types.GuardrailStage {
+	if len(guardrail.Stages) > 0 {
+		return guardrail.Stages
+	}
+
+	switch guardrail.Type {
+	case types.GuardrailTypePII:
+		return []types.GuardrailStage{types.GuardrailStageInput, types.GuardrailStageOutput}
+	case types.GuardrailTypePromptInjection:
+		return []types.GuardrailStage{types.GuardrailStageContext}
+	default:
+		return []types.GuardrailStage{types.GuardrailStageInput}
+	}
+}
+
+// ValidateGuardrails checks the guardrails of the app's assistants
+func ValidateGuardrails(config *types.AppHelixConfig) error {
+	for i, assistant := range config.Assistants {
+		name := assistant.Name
+		if name == "" {
+			name = fmt.Sprintf("%d", i)
+		}
+
+		for j := range assistant.Guardrails {
+			if err := validateGuardrail(&assistant.Guardrails[j]); err != nil {
+				return fmt.Errorf("assistant %s: guardrail %d: %w", name, j, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateGuardrail(guardrail *types.Guardrail) error {
+	switch guardrail.Type {
+	case types.GuardrailTypePII:
+		for _, pii := range guardrail.PII {
+			if !slices.Contains([]types.PIIType{types.PIITypeEmail, types.PIITypePhone, types.PIITypeCard}, pii) {
+				return fmt.Errorf("unknown pii '%s', expected %s, %s or %s", pii, types.PIITypeEmail, types.PIITypePhone, types.PIITypeCard)
+			}
+		}
+	case types.GuardrailTypePromptInjection:
+	case types.GuardrailTypeTopics:
+		if len(guardrail.Allow) == 0 && len(guardrail.Deny) == 0 {
+			return fmt.Errorf("topics guardrail needs allowed or denied topics")
+		}
+	case types.GuardrailTypeClassifier:
+		if guardrail.Policy == "" {
+			return fmt.Errorf("classifier guardrail needs a policy")
+		}
+	default:
+		return fmt.Errorf("unknown type '%s', expected %s, %s, %s or %s", guardrail.Type,
+			types.GuardrailTypePII, types.GuardrailTypePromptInjection, types.GuardrailTypeTopics, types.GuardrailTypeClassifier)
+	}
+
+	switch GuardrailAction(guardrail) {
+	case types.GuardrailActionBlock, types.GuardrailActionFlag:
+	case types.GuardrailActionRedact:
+		if guardrail.Type != types.GuardrailTypePII && guardrail.Type != types.GuardrailTypePromptInjection {
+			return fmt.Errorf("%s guardrail can't redact, only block or flag", guardrail.Type)
+		}
+	default:
+		return fmt.Errorf("unknown action '%s', expected %s, %s or %s", guardrail.Action,
+			types.GuardrailActionBlock, types.GuardrailActionRedact, types.GuardrailActionFlag)
+	}
+
+	for _, stage := range guardrail.Stages {
+		switch stage {
+		case types.GuardrailStageInput, types.GuardrailStageContext, types.GuardrailStageOutput:
+		default:
+			return fmt.Errorf("unknown stage '%s', expected %s, %s or %s", stage,
+				types.GuardrailStageInput, types.GuardrailStageContext, types.GuardrailStageOutput)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+func TestGuardrailDefaults(t *testing.T) {
+	pii := &types.Guardrail{Type: types.GuardrailTypePII}
+	assert.Equal(t, types.GuardrailActionRedact, GuardrailAction(pii))
+	assert.Equal(t, []types.GuardrailStage{types.GuardrailStageInput, types.GuardrailStageOutput}, GuardrailStages(pii))
+
+	injection := &types.Guardrail{Type: types.GuardrailTypePromptInjection}
+	assert.Equal(t, types.GuardrailActionBlock, GuardrailAction(injection))
+	assert.Equal(t, []types.GuardrailStage{types.GuardrailStageContext}, GuardrailStages(injection))
+
+	topics := &types.Guardrail{Type: types.GuardrailTypeTopics, Action: types.GuardrailActionFlag, Stages: []types.GuardrailStage{types.GuardrailStageOutput}}
+	assert.Equal(t, types.GuardrailActionFlag, GuardrailAction(topics))
+	assert.Equal(t, []types.GuardrailStage{types.GuardrailStageOutput}, GuardrailStages(topics))
+}
+
+func TestValidateGuardrails(t *testing.T) {
+	tests := []struct {
+		name      string
+		guardrail types.Guardrail
+		wantErr   string
+	}{
+		{name: "pii", guardrail: types.Guardrail{Type: types.GuardrailTypePII, PII: []types.PIIType{types.PIITypeCard}}},
+		{name: "prompt injection", guardrail: types.Guardrail{Type: types.GuardrailTypePromptInjection, Action: types.GuardrailActionRedact}},
+		{name: "topics", guardrail: types.Guardrail{Type: types.GuardrailTypeTopics, Deny: []string{"elections"}}},
+		{name: "classifier", guardrail: types.Guardrail{Type: types.GuardrailTypeClassifier, Policy: "No medical advice", Stages: []types.GuardrailStage{types.GuardrailStageOutput}}},
+		{
+			name:      "unknown type",
+			guardrail: types.Guardrail{Type: "profanity"},
+			wantErr:   "assistant support: guardrail 0: unknown type 'profanity', expected pii, prompt_injection, topics or classifier",
+		},
+		{
+			name:      "unknown pii",
+			guardrail: types.Guardrail{Type: types.GuardrailTypePII, PII: []types.PIIType{"address"}},
+			wantErr:   "assistant support: guardrail 0: unknown pii 'address', expected email, phone or card",
+		},
+		{
+			name:      "topics without topics",
+			guardrail: types.Guardrail{Type: types.GuardrailTypeTopics},
+			wantErr:   "assistant support: guardrail 0: topics guardrail needs allowed or denied topics",
+		},
+		{
+			name:      "classifier without policy",
+			guardrail: types.Guardrail{Type: types.GuardrailTypeClassifier},
+			wantErr:   "assistant support: guardrail 0: classifier guardrail needs a policy",
+		},
+		{
+			name:      "topics can't redact",
+			guardrail: types.Guardrail{Type: types.GuardrailTypeTopics, Deny: []string{"elections"}, Action: types.GuardrailActionRedact},
+			wantErr:   "assistant support: guardrail 0: topics guardrail can't redact, only block or flag",
+		},
+		{
+			name:      "unknown action",
+			guardrail: types.Guardrail{Type: types.GuardrailTypePII, Action: "warn"},
+			wantErr:   "assistant support: guardrail 0: unknown action 'warn', expected block, redact or flag",
+		},
+		{
+			name:      "unknown stage",
+			guardrail: types.Guardrail{Type: types.GuardrailTypePII, Stages: []types.GuardrailStage{"retrieval"}},
+			wantErr:   "assistant support: guardrail 0: unknown stage 'retrieval', expected input, context or output",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateGuardrails(&types.AppHelixConfig{
+				Assistants: []types.AssistantConfig{{Name: "support", Guardrails: []types.Guardrail{tt.guardrail}}},
+			})
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
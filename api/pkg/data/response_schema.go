@@ -0,0 +1,98 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+	"gopkg.in/yaml.v3"
+
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+// ParseResponseSchema reads an assistant's response schema, written as JSON or YAML, and returns
+// it as JSON
+func ParseResponseSchema(schema string) (json.RawMessage, error) {
+	var parsed interface{}
+	if err := yaml.Unmarshal([]byte(schema), &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse response schema: %w", err)
+	}
+
+	if _, ok := parsed.(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("response schema must be an object")
+	}
+
+	bts, err := json.Marshal(parsed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert response schema to JSON: %w", err)
+	}
+
+	if _, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(bts)); err != nil {
+		return nil, fmt.Errorf("invalid response schema: %w", err)
+	}
+
+	return bts, nil
+}
+
+// ValidateResponseSchemas checks that the assistants' response schemas are valid JSON Schemas
+func ValidateResponseSchemas(config *types.AppHelixConfig) error {
+	for i, assistant := range config.Assistants {
+		if assistant.ResponseSchema == nil {
+			continue
+		}
+
+		name := assistant.Name
+		if name == "" {
+			name = fmt.Sprintf("%d", i)
+		}
+
+		if assistant.ResponseSchema.Retries < 0 {
+			return fmt.Errorf("assistant %s: response schema retries can't be negative", name)
+		}
+
+		if _, err := ParseResponseSchema(assistant.ResponseSchema.Schema); err != nil {
+			return fmt.Errorf("assistant %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// ValidateResponse checks that the response is JSON matching the schema. Models like to wrap JSON
+// in a markdown code block, so the JSON is returned without it. The list of problems is empty
+// when the response matches.
+func ValidateResponse(schema json.RawMessage, content string) (string, []string, error) {
+	content = trimCodeBlock(content)
+
+	if !json.Valid([]byte(content)) {
+		return content, []string{"response is not valid JSON"}, nil
+	}
+
+	result, err := gojsonschema.Validate(gojsonschema.NewBytesLoader(schema), gojsonschema.NewStringLoader(content))
+	if err != nil {
+		return content, nil, fmt.Errorf("failed to validate response: %w", err)
+	}
+
+	var problems []string
+	for _, resultErr := range result.Errors() {
+		problems = append(problems, resultErr.String())
+	}
+
+	return content, problems, nil
+}
+
+func trimCodeBlock(content string) string {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, "```") || !strings.HasSuffix(content, "```") {
+		return content
+	}
+
+	content = strings.TrimSuffix(strings.TrimPrefix(content, "```"), "```")
+	// Drop the language, e.g. ```json
+	if newline := strings.Index(content, "\n"); newline >= 0 && !strings.ContainsAny(content[:newline], "{[") {
+		content = content[newline+1:]
+	}
+
+	return strings.TrimSpace(content)
+}
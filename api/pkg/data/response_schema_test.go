@@ -0,0 +1,63 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+const testResponseSchema = `
+type: object
+properties:
+  name:
+    type: string
+  age:
+    type: integer
+required: [name, age]
+`
+
+func TestParseResponseSchema(t *testing.T) {
+	schema, err := ParseResponseSchema(testResponseSchema)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type": "object", "properties": {"name": {"type": "string"}, "age": {"type": "integer"}}, "required": ["name", "age"]}`, string(schema))
+
+	_, err = ParseResponseSchema(`{"type": "object", "properties": {"name": {"type": "strung"}}}`)
+	assert.Error(t, err)
+
+	_, err = ParseResponseSchema(`just text`)
+	assert.Error(t, err)
+}
+
+func TestValidateResponse(t *testing.T) {
+	schema, err := ParseResponseSchema(testResponseSchema)
+	require.NoError(t, err)
+
+	content, problems, err := ValidateResponse(schema, "```json\n{\"name\": \"Ada\", \"age\": 36}\n```")
+	require.NoError(t, err)
+	assert.Empty(t, problems)
+	assert.Equal(t, `{"name": "Ada", "age": 36}`, content)
+
+	_, problems, err = ValidateResponse(schema, `{"name": "Ada", "age": "old"}`)
+	require.NoError(t, err)
+	require.Len(t, problems, 1)
+	assert.Contains(t, problems[0], "age")
+
+	_, problems, err = ValidateResponse(schema, `Ada is 36`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"response is not valid JSON"}, problems)
+}
+
+func TestValidateResponseSchemas(t *testing.T) {
+	config := &types.AppHelixConfig{
+		Assistants: []types.AssistantConfig{
+			{Name: "extractor", ResponseSchema: &types.AssistantResponseSchema{Schema: testResponseSchema}},
+		},
+	}
+	assert.NoError(t, ValidateResponseSchemas(config))
+
+	config.Assistants[0].ResponseSchema.Schema = `{"type": 5}`
+	assert.ErrorContains(t, ValidateResponseSchemas(config), "assistant extractor")
+}
@@ -307,6 +307,29 @@ func IsInteger(s string) bool {
 	return err == nil
 }
 
+// ResolveAssistant returns the ID of the assistant with the name, ID or index, as
+// GetAssistant looks it up
+func ResolveAssistant(app *types.App, ref string) (string, bool) {
+	if ref == "" {
+		return "", true
+	}
+
+	for idx, assistant := range app.Config.Helix.Assistants {
+		if assistant.Name == ref || (assistant.ID != "" && assistant.ID == ref) {
+			if assistant.ID != "" {
+				return assistant.ID, true
+			}
+			return strconv.Itoa(idx), true
+		}
+	}
+
+	if idx, err := strconv.Atoi(ref); err == nil && idx >= 0 && idx < len(app.Config.Helix.Assistants) {
+		return ref, true
+	}
+
+	return "", false
+}
+
 func GetAssistant(app *types.App, assistantID string) *types.AssistantConfig {
 	if assistantID == "" {
 		assistantID = "0"
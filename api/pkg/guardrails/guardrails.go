@@ -0,0 +1,84 @@
+// Package guardrails detects personal information, prompt injections and topics in the messages
+// that go to and come from the model. The controller decides what to do about them.
+package guardrails
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// Withheld replaces content that a guardrail blocked before the model could read it, when the
+// guardrail doesn't have a message of its own
+const Withheld = "[This content was withheld by a guardrail.]"
+
+// Match is something that was detected in a text
+type Match struct {
+	// Kind is what was detected, e.g. email or ignore_instructions
+	Kind  string
+	Start int
+	End   int
+}
+
+// Kinds lists what was detected once, in the order it was first detected
+func Kinds(matches []Match) []string {
+	var kinds []string
+	seen := make(map[string]bool)
+	for _, match := range matches {
+		if !seen[match.Kind] {
+			seen[match.Kind] = true
+			kinds = append(kinds, match.Kind)
+		}
+	}
+	return kinds
+}
+
+// sortMatches puts the matches in the order they're in the text
+func sortMatches(matches []Match) []Match {
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Start < matches[j].Start })
+	return matches
+}
+
+// redact replaces the matches, which don't overlap and are in order, with what replace returns for them
+func redact(text string, matches []Match, replace func(Match) string) string {
+	var sb strings.Builder
+	last := 0
+	for _, match := range matches {
+		sb.WriteString(text[last:match.Start])
+		sb.WriteString(replace(match))
+		last = match.End
+	}
+	sb.WriteString(text[last:])
+	return sb.String()
+}
+
+// overlaps is whether the match overlaps any of the matches
+func overlaps(match Match, matches []Match) bool {
+	for _, m := range matches {
+		if match.Start < m.End && m.Start < match.End {
+			return true
+		}
+	}
+	return false
+}
+
+// ContentCheck checks content that the model is about to read but that neither the user nor the app
+// wrote, like tool responses and knowledge. It returns the content to use instead, or the message
+// to use in its place and false when the content is withheld.
+type ContentCheck func(ctx context.Context, source, content string) (string, bool)
+
+type contentCheckKey struct{}
+
+// WithContentCheck makes CheckContent use the check
+func WithContentCheck(ctx context.Context, check ContentCheck) context.Context {
+	return context.WithValue(ctx, contentCheckKey{}, check)
+}
+
+// CheckContent checks the content with the context's check, content passes when there isn't one
+func CheckContent(ctx context.Context, source, content string) (string, bool) {
+	check, ok := ctx.Value(contentCheckKey{}).(ContentCheck)
+	if !ok || check == nil {
+		return content, true
+	}
+	return check(ctx, source, content)
+}
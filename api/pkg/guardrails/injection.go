@@ -0,0 +1,40 @@
+package guardrails
+
+import "regexp"
+
+// injectionPatterns are the phrasings that content uses to give the model instructions
+var injectionPatterns = []struct {
+	kind    string
+	pattern *regexp.Regexp
+}{
+	{"ignore_instructions", regexp.MustCompile(`(?i)\b(ignore|disregard|forget|override)\s+(all\s+|any\s+)?(of\s+)?(the\s+|your\s+)?(previous|prior|above|earlier|preceding|system)\s+(instructions|prompts?|messages|rules|context)`)},
+	{"reveal_prompt", regexp.MustCompile(`(?i)\b(reveal|show|print|repeat|output)\s+(me\s+)?(your|the)\s+(system\s+)?(prompt|instructions)`)},
+	{"role_override", regexp.MustCompile(`(?i)\byou\s+are\s+now\s+(a|an|in|the)\b|\bdo\s+anything\s+now\b|\bjailbr(oken|eak)\b`)},
+	{"new_instructions", regexp.MustCompile(`(?i)\b(new|updated|additional)\s+(system\s+)?instructions\s*:`)},
+	{"chat_markup", regexp.MustCompile(`(?i)<\|?/?(system|im_start|im_end)\|?>|(?m)^\s*system\s*:`)},
+}
+
+// DetectInjections finds the instructions aimed at the model in the text, in the order they're in
+// the text
+func DetectInjections(text string) []Match {
+	var matches []Match
+	for _, p := range injectionPatterns {
+		for _, loc := range p.pattern.FindAllStringIndex(text, -1) {
+			match := Match{Kind: p.kind, Start: loc[0], End: loc[1]}
+			if !overlaps(match, matches) {
+				matches = append(matches, match)
+			}
+		}
+	}
+	return sortMatches(matches)
+}
+
+// RedactInjections removes the instructions aimed at the model from the text
+func RedactInjections(text string) (string, []Match) {
+	matches := DetectInjections(text)
+	if len(matches) == 0 {
+		return text, nil
+	}
+
+	return redact(text, matches, func(Match) string { return "[REMOVED]" }), matches
+}
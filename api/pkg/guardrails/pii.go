@@ -0,0 +1,105 @@
+package guardrails
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	// cardPattern finds 13 to 19 digits, optionally grouped with spaces or dashes
+	cardPattern = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+	// phonePattern finds numbers that look like phone numbers, the digits are counted after
+	phonePattern = regexp.MustCompile(`\+?\(?\d[\d\s().-]{7,}\d`)
+)
+
+// AllPII is what's detected when a guardrail doesn't say
+var AllPII = []types.PIIType{types.PIITypeEmail, types.PIITypePhone, types.PIITypeCard}
+
+// DetectPII finds the kinds of personal information in the text, all of them when kinds is empty,
+// in the order they're in the text
+func DetectPII(text string, kinds []types.PIIType) []Match {
+	if len(kinds) == 0 {
+		kinds = AllPII
+	}
+
+	detect := make(map[types.PIIType]bool, len(kinds))
+	for _, kind := range kinds {
+		detect[kind] = true
+	}
+
+	var matches []Match
+
+	// Card numbers first, so that they aren't taken for phone numbers
+	if detect[types.PIITypeCard] {
+		for _, loc := range cardPattern.FindAllStringIndex(text, -1) {
+			if luhn(digits(text[loc[0]:loc[1]])) {
+				matches = append(matches, Match{Kind: string(types.PIITypeCard), Start: loc[0], End: loc[1]})
+			}
+		}
+	}
+
+	if detect[types.PIITypeEmail] {
+		for _, loc := range emailPattern.FindAllStringIndex(text, -1) {
+			match := Match{Kind: string(types.PIITypeEmail), Start: loc[0], End: loc[1]}
+			if overlaps(match, matches) {
+				continue
+			}
+			matches = append(matches, match)
+		}
+	}
+
+	if detect[types.PIITypePhone] {
+		for _, loc := range phonePattern.FindAllStringIndex(text, -1) {
+			match := Match{Kind: string(types.PIITypePhone), Start: loc[0], End: loc[1]}
+			if n := len(digits(text[loc[0]:loc[1]])); n < 9 || n > 15 || overlaps(match, matches) {
+				continue
+			}
+			matches = append(matches, match)
+		}
+	}
+
+	return sortMatches(matches)
+}
+
+// RedactPII replaces the personal information in the text with its kind, e.g. [EMAIL]
+func RedactPII(text string, kinds []types.PIIType) (string, []Match) {
+	matches := DetectPII(text, kinds)
+	if len(matches) == 0 {
+		return text, nil
+	}
+
+	return redact(text, matches, func(m Match) string {
+		return "[" + strings.ToUpper(m.Kind) + "]"
+	}), matches
+}
+
+func digits(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// luhn is whether the number passes the Luhn checksum that card numbers have
+func luhn(number string) bool {
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		d := int(number[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,23 @@
+package guardrails
+
+import (
+	"regexp"
+	"strings"
+)
+
+// MatchTopics returns the topics that the text mentions, as whole words and ignoring case
+func MatchTopics(text string, topics []string) []string {
+	var matched []string
+	for _, topic := range topics {
+		topic = strings.TrimSpace(topic)
+		if topic == "" {
+			continue
+		}
+
+		pattern := regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(topic) + `\b`)
+		if pattern.MatchString(text) {
+			matched = append(matched, topic)
+		}
+	}
+	return matched
+}
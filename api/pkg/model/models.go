@@ -53,6 +53,14 @@ func (m Name) InferenceRuntime() types.InferenceRuntime {
 	if m.String() == ModelCogSdxl {
 		return types.InferenceRuntimeCog
 	}
+	vllmModels, err := GetDefaultVLLMModels()
+	if err == nil {
+		for _, model := range vllmModels {
+			if m.String() == model.ID {
+				return types.InferenceRuntimeVLLM
+			}
+		}
+	}
 	diffusersModels, err := GetDefaultDiffusersModels()
 	if err != nil {
 		return types.InferenceRuntimeAxolotl
@@ -156,6 +164,13 @@ func GetModels() (map[string]Model, error) {
 	for _, model := range diffusersModels {
 		models[model.ID] = model
 	}
+	vllmModels, err := GetDefaultVLLMModels()
+	if err != nil {
+		return nil, err
+	}
+	for _, model := range vllmModels {
+		models[model.ID] = model
+	}
 	return models, nil
 }
 
@@ -187,6 +202,39 @@ func GetDefaultDiffusersModels() ([]*DiffusersGenericImage, error) {
 	}, nil
 }
 
+// GetDefaultVLLMModels returns the models served by vLLM. Architecture values come from each
+// model's config.json on Hugging Face and are used to work out how much GPU memory to reserve.
+func GetDefaultVLLMModels() ([]*VLLMGenericText, error) {
+	return []*VLLMGenericText{
+		{
+			ID:             "Qwen/Qwen2.5-7B-Instruct", // https://huggingface.co/Qwen/Qwen2.5-7B-Instruct
+			Name:           "Qwen 2.5 7B (vLLM)",
+			ContextLength:  32768,
+			Description:    "High throughput serving of Qwen 2.5 7B, from Alibaba - bf16, 32K context",
+			Hide:           false,
+			Parameters:     7_615_616_512,
+			BytesPerParam:  2,
+			Layers:         28,
+			HiddenSize:     3584,
+			AttentionHeads: 28,
+			KeyValueHeads:  4,
+		},
+		{
+			ID:             "meta-llama/Llama-3.1-8B-Instruct", // https://huggingface.co/meta-llama/Llama-3.1-8B-Instruct
+			Name:           "Llama 3.1 8B (vLLM)",
+			ContextLength:  32768,
+			Description:    "High throughput serving of Llama 3.1 8B, from Meta - bf16, 32K context",
+			Hide:           false,
+			Parameters:     8_030_261_248,
+			BytesPerParam:  2,
+			Layers:         32,
+			HiddenSize:     4096,
+			AttentionHeads: 32,
+			KeyValueHeads:  8,
+		},
+	}, nil
+}
+
 // See also types/models.go for model name constants
 func GetDefaultOllamaModels() ([]*OllamaGenericText, error) {
 	models := []*OllamaGenericText{
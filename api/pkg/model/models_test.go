@@ -107,3 +107,28 @@ func TestProcessModelName(t *testing.T) {
 		})
 	}
 }
+
+func TestVLLMModels(t *testing.T) {
+	models, err := GetDefaultVLLMModels()
+	if err != nil {
+		t.Fatalf("GetDefaultVLLMModels() error = %v", err)
+	}
+	if len(models) == 0 {
+		t.Fatal("expected at least one vLLM model")
+	}
+
+	for _, m := range models {
+		if got := NewModel(m.ID).InferenceRuntime(); got != types.InferenceRuntimeVLLM {
+			t.Errorf("InferenceRuntime() for %s = %v, want %v", m.ID, got, types.InferenceRuntimeVLLM)
+		}
+
+		// Memory must at least cover the weights and be rounded to whole GB
+		mem := m.GetMemoryRequirements(types.SessionModeInference)
+		if mem < m.Parameters*m.BytesPerParam {
+			t.Errorf("GetMemoryRequirements() for %s = %d, less than the weights", m.ID, mem)
+		}
+		if mem%GB != 0 {
+			t.Errorf("GetMemoryRequirements() for %s = %d, not rounded to GB", m.ID, mem)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package model
+
+import (
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkoukk/tiktoken-go"
+	"github.com/rs/zerolog/log"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// DefaultContextLength is used for models whose context length isn't known
+const DefaultContextLength = 8192
+
+// tiktokenEncodings are the tiktoken encodings of the OpenAI model families, the first matching
+// prefix wins. gpt-4o and o1 use o200k_base, which tiktoken-go doesn't have yet, cl100k_base
+// counts them much more closely than the estimate.
+var tiktokenEncodings = []struct {
+	prefix   string
+	encoding string
+}{
+	{"gpt-4o", tiktoken.MODEL_CL100K_BASE},
+	{"o1", tiktoken.MODEL_CL100K_BASE},
+	{"gpt-4", tiktoken.MODEL_CL100K_BASE},
+	{"gpt-3.5", tiktoken.MODEL_CL100K_BASE},
+	{"text-embedding", tiktoken.MODEL_CL100K_BASE},
+}
+
+// charsPerToken is roughly how many characters each model family's tokenizer fits in a token,
+// the first matching prefix wins. It's used for the models tiktoken doesn't cover, and for the
+// OpenAI models until their encoding has loaded.
+var charsPerToken = []struct {
+	prefix string
+	chars  float64
+}{
+	{"gpt-4o", 4.4},
+	{"o1", 4.4},
+	{"gpt", 4},
+	{"meta-llama-3", 4.2},
+	{"llama3", 4.2},
+	{"llama-3", 4.2},
+	{"qwen", 4},
+	{"claude", 3.5},
+	{"mistral", 3.5},
+	{"mixtral", 3.5},
+	{"phi", 3.5},
+}
+
+const defaultCharsPerToken = 3.5
+
+// contextLengths are the context lengths of the hosted model families, the first matching prefix
+// wins
+var contextLengths = []struct {
+	prefix string
+	length int
+}{
+	{"gpt-4o", 128000},
+	{"o1", 128000},
+	{"gpt-4-turbo", 128000},
+	{"gpt-4", 8192},
+	{"gpt-3.5", 16385},
+	{"claude", 200000},
+	{"meta-llama-3.1", 131072},
+	{"meta-llama-3.2", 131072},
+	{"meta-llama-3.3", 131072},
+	{"meta-llama-3", 8192},
+	{"qwen2.5", 32768},
+}
+
+// modelFamily is the lowercased model name without its organisation, e.g. meta-llama-3.1-8b-instruct-turbo
+// for meta-llama/Meta-Llama-3.1-8B-Instruct-Turbo
+func modelFamily(modelName string) string {
+	name := strings.ToLower(modelName)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// encodingRetryInterval is how long to wait before downloading an encoding again after it failed,
+// e.g. in air-gapped installs
+const encodingRetryInterval = 10 * time.Minute
+
+// loadEncoding loads a tiktoken encoding, downloading its vocabulary the first time. Tests replace it.
+var loadEncoding = tiktoken.GetEncoding
+
+var encodings = struct {
+	sync.Mutex
+	loaded  map[string]*tiktoken.Tiktoken
+	loading map[string]time.Time
+}{
+	loaded:  map[string]*tiktoken.Tiktoken{},
+	loading: map[string]time.Time{},
+}
+
+// encoding returns the loaded tiktoken encoding, or nil while it's loading in the background so
+// that counting never waits on the download
+func encoding(name string) *tiktoken.Tiktoken {
+	encodings.Lock()
+	defer encodings.Unlock()
+
+	if enc, ok := encodings.loaded[name]; ok {
+		return enc
+	}
+	if started, ok := encodings.loading[name]; ok && time.Since(started) < encodingRetryInterval {
+		return nil
+	}
+	encodings.loading[name] = time.Now()
+
+	load := loadEncoding
+	go func() {
+		enc, err := load(name)
+		if err != nil {
+			log.Warn().Err(err).Str("encoding", name).Msg("failed to load tiktoken encoding, estimating token counts")
+			return
+		}
+		encodings.Lock()
+		defer encodings.Unlock()
+		encodings.loaded[name] = enc
+		delete(encodings.loading, name)
+	}()
+	return nil
+}
+
+// CountTokens counts how many tokens the model's tokenizer splits the text into, with tiktoken
+// for the OpenAI models and estimating from the text's length for the rest
+func CountTokens(modelName, text string) int {
+	if text == "" {
+		return 0
+	}
+
+	family := modelFamily(modelName)
+	for _, e := range tiktokenEncodings {
+		if strings.HasPrefix(family, e.prefix) {
+			if enc := encoding(e.encoding); enc != nil {
+				return len(enc.EncodeOrdinary(text))
+			}
+			break
+		}
+	}
+
+	chars := defaultCharsPerToken
+	for _, c := range charsPerToken {
+		if strings.HasPrefix(family, c.prefix) {
+			chars = c.chars
+			break
+		}
+	}
+
+	tokens := int(float64(utf8.RuneCountInString(text))/chars + 0.5)
+	if tokens == 0 {
+		tokens = 1
+	}
+	return tokens
+}
+
+// CountMessageTokens counts how many tokens the messages take up in the model's context,
+// including the few tokens each message's role and delimiters take
+func CountMessageTokens(modelName string, messages ...openai.ChatCompletionMessage) int {
+	tokens := 0
+	for _, message := range messages {
+		tokens += 4 + CountTokens(modelName, message.Role) + CountTokens(modelName, message.Content)
+		for _, part := range message.MultiContent {
+			tokens += CountTokens(modelName, part.Text)
+		}
+	}
+	return tokens
+}
+
+// GetContextLength returns how many tokens fit in the model's context
+func GetContextLength(modelName string) int {
+	ollamaModels, err := GetDefaultOllamaModels()
+	if err == nil {
+		for _, model := range ollamaModels {
+			if model.ID == modelName {
+				return int(model.ContextLength)
+			}
+		}
+	}
+
+	vllmModels, err := GetDefaultVLLMModels()
+	if err == nil {
+		for _, model := range vllmModels {
+			if model.ID == modelName {
+				return int(model.ContextLength)
+			}
+		}
+	}
+
+	family := modelFamily(modelName)
+	for _, c := range contextLengths {
+		if strings.HasPrefix(family, c.prefix) {
+			return c.length
+		}
+	}
+
+	return DefaultContextLength
+}
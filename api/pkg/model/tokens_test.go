@@ -0,0 +1,108 @@
+package model
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkoukk/tiktoken-go"
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withEncodings makes the encodings load with load instead of downloading them
+func withEncodings(t *testing.T, load func(string) (*tiktoken.Tiktoken, error)) {
+	reset := func() {
+		encodings.Lock()
+		defer encodings.Unlock()
+		encodings.loaded = map[string]*tiktoken.Tiktoken{}
+		encodings.loading = map[string]time.Time{}
+	}
+	reset()
+	original := loadEncoding
+	loadEncoding = load
+	t.Cleanup(func() {
+		loadEncoding = original
+		reset()
+	})
+}
+
+func failingEncoding(string) (*tiktoken.Tiktoken, error) {
+	return nil, errors.New("offline")
+}
+
+// byteEncoding is an encoding with a token for every byte and no merges
+func byteEncoding(string) (*tiktoken.Tiktoken, error) {
+	ranks := map[string]int{}
+	for b := 0; b < 256; b++ {
+		ranks[string([]byte{byte(b)})] = b
+	}
+	enc := &tiktoken.Encoding{Name: "bytes", PatStr: `\S+|\s+`, MergeableRanks: ranks, SpecialTokens: map[string]int{}}
+	bpe, err := tiktoken.NewCoreBPE(enc.MergeableRanks, enc.SpecialTokens, enc.PatStr)
+	if err != nil {
+		return nil, err
+	}
+	return tiktoken.NewTiktoken(bpe, enc, map[string]any{}), nil
+}
+
+func TestCountTokens(t *testing.T) {
+	withEncodings(t, failingEncoding)
+	text := "The quick brown fox jumps over the lazy dog, twice." // 51 characters
+
+	assert.Equal(t, 0, CountTokens("gpt-4o", ""))
+	assert.Equal(t, 12, CountTokens("gpt-4o-mini", text))
+	assert.Equal(t, 12, CountTokens("meta-llama/Meta-Llama-3.1-8B-Instruct-Turbo", text))
+	assert.Equal(t, 15, CountTokens("unknown", text))
+	assert.Equal(t, 1, CountTokens("gpt-4o", "a"))
+}
+
+func TestCountTokens_Tiktoken(t *testing.T) {
+	withEncodings(t, byteEncoding)
+	text := "hello there"
+
+	// estimated until the encoding has loaded
+	assert.Equal(t, 3, CountTokens("gpt-4o", text))
+	require.Eventually(t, func() bool {
+		return CountTokens("gpt-4o", text) == len(text)
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, len(text), CountTokens("openai/gpt-3.5-turbo", text))
+	// models tiktoken doesn't cover are still estimated
+	assert.Equal(t, 3, CountTokens("llama3.1:8b-instruct-q8_0", text))
+}
+
+func TestCountTokens_EncodingFailsToLoad(t *testing.T) {
+	var loads atomic.Int32
+	withEncodings(t, func(string) (*tiktoken.Tiktoken, error) {
+		loads.Add(1)
+		return nil, errors.New("offline")
+	})
+
+	assert.Equal(t, 3, CountTokens("gpt-4", "hello there"))
+	require.Eventually(t, func() bool { return loads.Load() == 1 }, time.Second, 10*time.Millisecond)
+
+	// not downloaded again until the retry interval has passed
+	assert.Equal(t, 3, CountTokens("gpt-4", "hello there"))
+	assert.Equal(t, 3, CountTokens("gpt-3.5-turbo", "hello there"))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(1), loads.Load())
+}
+
+func TestCountMessageTokens(t *testing.T) {
+	withEncodings(t, failingEncoding)
+	tokens := CountMessageTokens("gpt-4",
+		openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: "hello there"},
+		openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: "hi"},
+	)
+	// 4 per message, "user" 1, "hello there" 3, "assistant" 2, "hi" 1
+	assert.Equal(t, 15, tokens)
+}
+
+func TestGetContextLength(t *testing.T) {
+	assert.Equal(t, 32768, GetContextLength("llama3.1:8b-instruct-q8_0"))
+	assert.Equal(t, 128000, GetContextLength("gpt-4o-mini"))
+	assert.Equal(t, 8192, GetContextLength("gpt-4"))
+	assert.Equal(t, 131072, GetContextLength("meta-llama/Meta-Llama-3.1-70B-Instruct-Turbo"))
+	assert.Equal(t, DefaultContextLength, GetContextLength("something-else"))
+}
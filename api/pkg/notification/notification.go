@@ -22,6 +22,8 @@ type Event int
 const (
 	EventFinetuningStarted  Event = 1
 	EventFinetuningComplete Event = 2
+	// EventTriggerRunOutput sends the output of an app's trigger run, e.g. a daily digest
+	EventTriggerRunOutput Event = 3
 )
 
 func (e Event) String() string {
@@ -30,6 +32,8 @@ func (e Event) String() string {
 		return "finetuning_started"
 	case EventFinetuningComplete:
 		return "finetuning_complete"
+	case EventTriggerRunOutput:
+		return "trigger_run_output"
 	default:
 		return "unknown_event"
 	}
@@ -39,6 +43,10 @@ type Notification struct {
 	Event   Event
 	Session *types.Session
 
+	// Title and Message are the content of trigger run outputs
+	Title   string
+	Message string
+
 	// Populated by the provider
 	Email     string
 	FirstName string
@@ -67,16 +75,19 @@ func New(cfg *config.Notifications, authenticator auth.Authenticator) (Notifier,
 }
 
 func (n *NotificationsProvider) Notify(ctx context.Context, notification *Notification) error {
-	user, err := n.authenticator.GetUserByID(ctx, notification.Session.Owner)
-	if err != nil {
-		return fmt.Errorf("failed to get user '%s' details: %w", notification.Session.Owner, err)
+	// Notifications go to the session owner unless they are addressed
+	if notification.Email == "" {
+		user, err := n.authenticator.GetUserByID(ctx, notification.Session.Owner)
+		if err != nil {
+			return fmt.Errorf("failed to get user '%s' details: %w", notification.Session.Owner, err)
+		}
+
+		notification.Email = user.Email
+		notification.FirstName = strings.Split(user.FullName, " ")[0]
 	}
 
 	log.Debug().
-		Str("email", user.Email).Str("notification", notification.Event.String()).Msg("sending notification")
-
-	notification.Email = user.Email
-	notification.FirstName = strings.Split(user.FullName, " ")[0]
+		Str("email", notification.Email).Str("notification", notification.Event.String()).Msg("sending notification")
 
 	if n.email.Enabled() {
 		err := n.email.Notify(ctx, notification)
@@ -41,7 +41,7 @@ func (e *Email) Enabled() bool {
 func (e *Email) Notify(ctx context.Context, n *Notification) error {
 	if n.Email == "" {
 		// Nothing to do
-		log.Ctx(ctx).Warn().Str("session_id", n.Session.ID).Msg("no email address provided for notification")
+		log.Ctx(ctx).Warn().Str("notification", n.Event.String()).Msg("no email address provided for notification")
 		return nil
 	}
 
@@ -114,6 +114,17 @@ func (e *Email) getEmailMessage(n *Notification) (title, message string, err err
 		}
 
 		return fmt.Sprintf("Finetuning Complete - Ready for Action [%s]", n.Session.Name), buf.String(), nil
+	case EventTriggerRunOutput:
+		var buf bytes.Buffer
+
+		err = triggerRunOutputTmpl.Execute(&buf, &templateData{
+			Message: n.Message,
+		})
+		if err != nil {
+			return "", "", fmt.Errorf("failed to execute template: %w", err)
+		}
+
+		return n.Title, buf.String(), nil
 	default:
 		return "", "", fmt.Errorf("unknown event '%s'", n.Event.String())
 	}
@@ -123,11 +134,13 @@ type templateData struct {
 	SessionURL  string
 	FirstName   string
 	SessionName string
+	Message     string
 }
 
 var (
 	finetuningStartedTmpl   = template.Must(template.New("").Parse(finetuningStartedTemplate))
 	finetuningCompletedTmpl = template.Must(template.New("").Parse(finetuningCompletedTemplate))
+	triggerRunOutputTmpl    = template.Must(template.New("").Parse(triggerRunOutputTemplate))
 )
 
 var finetuningStartedTemplate = `
@@ -155,3 +168,7 @@ We hope you enjoy the improvements. If you have any feedback or require assistan
 Best regards,<br/><br/>
 The Helix Team
 `
+
+var triggerRunOutputTemplate = `
+<div style="white-space: pre-wrap;">{{ .Message }}</div>
+`
@@ -9,13 +9,19 @@ import (
 type (
 	contextValuesKeyType int
 	contextAppIDKeyType  int
+	appRevisionKeyType   int
+	experimentKeyType    int
 	stepKeyType          int
+	llmCallIDKeyType     int
 )
 
 var (
 	contextValuesKey contextValuesKeyType
 	contextAppIDKey  contextAppIDKeyType
+	appRevisionKey   appRevisionKeyType
+	experimentKey    experimentKeyType
 	stepKey          stepKeyType
+	llmCallIDKey     llmCallIDKeyType
 )
 
 const (
@@ -46,6 +52,43 @@ func GetContextAppID(ctx context.Context) (string, bool) {
 	return appID, ok
 }
 
+// SetContextAppRevision records the revision of the app that serves the request, so that LLM
+// calls can be traced back to the config that made them
+func SetContextAppRevision(ctx context.Context, revision int) context.Context {
+	return context.WithValue(ctx, appRevisionKey, revision)
+}
+
+func GetContextAppRevision(ctx context.Context) (int, bool) {
+	revision, ok := ctx.Value(appRevisionKey).(int)
+	return revision, ok
+}
+
+type experimentValues struct {
+	experiment string
+	variant    string
+}
+
+// SetContextExperiment records the experiment and the variant of it that serves the request
+func SetContextExperiment(ctx context.Context, experiment, variant string) context.Context {
+	return context.WithValue(ctx, experimentKey, experimentValues{experiment: experiment, variant: variant})
+}
+
+func GetContextExperiment(ctx context.Context) (experiment, variant string, ok bool) {
+	values, ok := ctx.Value(experimentKey).(experimentValues)
+	return values.experiment, values.variant, ok
+}
+
+// SetContextLLMCallID sets the ID that the next LLM call is logged with, so that what's recorded
+// about the call before it's logged can point at it
+func SetContextLLMCallID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, llmCallIDKey, id)
+}
+
+func GetContextLLMCallID(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(llmCallIDKey).(string)
+	return id, ok
+}
+
 func SetContextValues(ctx context.Context, vals *ContextValues) context.Context {
 	// Check if the context already has values, if it does,
 	// preserve the OriginalRequest
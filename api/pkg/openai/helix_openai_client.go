@@ -45,6 +45,22 @@ func ListModels(_ context.Context) ([]model.OpenAIModel, error) {
 		})
 	}
 
+	vllmModels, err := model.GetDefaultVLLMModels()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get vLLM models: %w", err)
+	}
+	for _, m := range vllmModels {
+		helixModels = append(helixModels, model.OpenAIModel{
+			ID:          m.ModelName().String(),
+			Object:      "model",
+			OwnedBy:     "helix",
+			Name:        m.GetHumanReadableName(),
+			Description: m.GetDescription(),
+			Hide:        m.GetHidden(),
+			Type:        "text",
+		})
+	}
+
 	diffusersModels, err := model.GetDefaultDiffusersModels()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get Diffusers models: %w", err)
@@ -134,21 +150,15 @@ func (c *InternalHelixServer) CreateChatCompletion(requestCtx context.Context, r
 	select {
 	case <-doneCh:
 	case <-requestCtx.Done():
-		// If this happens, the request has been cancelled, release the allocation
-		err := c.scheduler.Release(requestID)
-		if err != nil {
-			log.Error().Err(err).Msg("error releasing allocation")
-		}
+		// If this happens, the request has been cancelled, tell the runner to stop working on it
+		c.cancelRequest(requestID)
 		return openai.ChatCompletionResponse{}, fmt.Errorf("request was cancelled")
 	case <-ctx.Done():
 		// If this happens, we have timed out
 		log.Warn().
 			Str("request_id", requestID).
-			Msg("timeout waiting for runner response, releasing allocation")
-		err := c.scheduler.Release(requestID)
-		if err != nil {
-			log.Error().Err(err).Msg("error releasing allocation")
-		}
+			Msg("timeout waiting for runner response, cancelling request")
+		c.cancelRequest(requestID)
 		return openai.ChatCompletionResponse{}, fmt.Errorf("timeout waiting for runner response")
 	}
 
@@ -260,6 +270,13 @@ func (c *InternalHelixServer) CreateChatCompletionStream(ctx context.Context, re
 		ctx, cancel := context.WithTimeout(ctx, chatCompletionTimeout)
 		defer cancel()
 
+		select {
+		case <-doneCh:
+		case <-ctx.Done():
+			// The client went away or we timed out before the runner finished, so stop the
+			// runner from generating tokens that nobody will read
+			c.cancelRequest(requestID)
+		}
 		<-ctx.Done()
 		_ = sub.Unsubscribe()
 	}()
@@ -277,6 +294,15 @@ func (c *InternalHelixServer) CreateChatCompletionStream(ctx context.Context, re
 	return stream, err
 }
 
+// cancelRequest removes the request from the queue or asks the runner to stop processing it. The
+// allocation is released when the runner acknowledges the cancellation.
+func (c *InternalHelixServer) cancelRequest(requestID string) {
+	err := c.scheduler.Cancel(requestID)
+	if err != nil {
+		log.Error().Err(err).Str("request_id", requestID).Msg("error cancelling request")
+	}
+}
+
 // NewOpenAIStreamingAdapter returns a new OpenAI streaming adapter which allows
 // to write into the io.Writer and read from the stream directly
 func NewOpenAIStreamingAdapter(req openai.ChatCompletionRequest) (*openai.ChatCompletionStream, *io.PipeWriter, error) {
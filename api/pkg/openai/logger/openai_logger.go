@@ -212,21 +212,32 @@ func (m *LoggingMiddleware) logLLMCall(ctx context.Context, req *openai.ChatComp
 		Int("total_tokens", resp.Usage.TotalTokens).
 		Msg("logging LLM call")
 
+	// Not set when the request isn't for an app
+	appRevision, _ := oai.GetContextAppRevision(ctx)
+	experiment, variant, _ := oai.GetContextExperiment(ctx)
+
+	// Not set unless something needs to point at the call
+	llmCallID, _ := oai.GetContextLLMCallID(ctx)
+
 	llmCall := &types.LLMCall{
-		AppID:            appID,
-		SessionID:        vals.SessionID,
-		InteractionID:    vals.InteractionID,
-		Model:            req.Model,
-		Step:             step.Step,
-		OriginalRequest:  vals.OriginalRequest,
-		Request:          reqBts,
-		Response:         respBts,
-		Provider:         string(m.provider),
-		DurationMs:       durationMs,
-		PromptTokens:     int64(resp.Usage.PromptTokens),
-		CompletionTokens: int64(resp.Usage.CompletionTokens),
-		TotalTokens:      int64(resp.Usage.TotalTokens),
-		UserID:           vals.OwnerID,
+		ID:                llmCallID,
+		AppID:             appID,
+		AppRevision:       appRevision,
+		Experiment:        experiment,
+		ExperimentVariant: variant,
+		SessionID:         vals.SessionID,
+		InteractionID:     vals.InteractionID,
+		Model:             req.Model,
+		Step:              step.Step,
+		OriginalRequest:   vals.OriginalRequest,
+		Request:           reqBts,
+		Response:          respBts,
+		Provider:          string(m.provider),
+		DurationMs:        durationMs,
+		PromptTokens:      int64(resp.Usage.PromptTokens),
+		CompletionTokens:  int64(resp.Usage.CompletionTokens),
+		TotalTokens:       int64(resp.Usage.TotalTokens),
+		UserID:            vals.OwnerID,
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), logCallTimeout)
 	defer cancel()
@@ -102,3 +102,67 @@ func KnowledgePrompt(req *KnowledgePromptRequest) (string, error) {
 	}
 	return buf.String(), nil
 }
+
+type MemoryMessage struct {
+	Role    string
+	Content string
+}
+
+// MemoryPrompt asks the model for the facts about the user in the conversation that aren't
+// remembered yet
+func MemoryPrompt(memories []string, messages []MemoryMessage) (string, error) {
+	tmplData := struct {
+		Memories []string
+		Messages []MemoryMessage
+	}{
+		Memories: memories,
+		Messages: messages,
+	}
+
+	tmpl := template.Must(template.New("MemoryPrompt").Parse(templates.MemoryTemplate))
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, tmplData)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// ConversationSummaryPrompt asks the model to fold the messages into the summary of the
+// conversation so far, which is empty for the first summary
+func ConversationSummaryPrompt(summary string, messages []MemoryMessage) (string, error) {
+	tmplData := struct {
+		Summary  string
+		Messages []MemoryMessage
+	}{
+		Summary:  summary,
+		Messages: messages,
+	}
+
+	tmpl := template.Must(template.New("ConversationSummaryPrompt").Parse(templates.ConversationSummaryTemplate))
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, tmplData)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// GuardrailClassifierPrompt asks the model whether the message follows the policy
+func GuardrailClassifierPrompt(policy, message string) (string, error) {
+	tmplData := struct {
+		Policy  string
+		Message string
+	}{
+		Policy:  policy,
+		Message: message,
+	}
+
+	tmpl := template.Must(template.New("GuardrailClassifierPrompt").Parse(templates.GuardrailClassifierTemplate))
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, tmplData)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -12,3 +12,12 @@ var RagTemplate string
 
 //go:embed finetuning.tmpl
 var FinetuningTemplate string
+
+//go:embed memory.tmpl
+var MemoryTemplate string
+
+//go:embed conversation_summary.tmpl
+var ConversationSummaryTemplate string
+
+//go:embed guardrail_classifier.tmpl
+var GuardrailClassifierTemplate string
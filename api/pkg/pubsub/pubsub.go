@@ -2,9 +2,13 @@ package pubsub
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/nats-io/nats.go"
+
+	"github.com/helixml/helix/api/pkg/types"
 )
 
 type Publisher interface {
@@ -86,3 +90,20 @@ func getStreamSub(stream, sub string) string {
 func GetRunnerResponsesQueue(ownerID, reqID string) string {
 	return "runner-responses." + ownerID + "." + reqID
 }
+
+// EventsQueue is subscribed to for all the events
+const EventsQueue = "events.>"
+
+func GetEventsQueue(eventType types.EventType) string {
+	return "events." + string(eventType)
+}
+
+// PublishEvent publishes the event to its queue
+func PublishEvent(ctx context.Context, p Publisher, event *types.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	return p.Publish(ctx, GetEventsQueue(event.Type), payload)
+}
@@ -0,0 +1,426 @@
+package runner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/helixml/helix/api/pkg/config"
+	"github.com/helixml/helix/api/pkg/system"
+	"github.com/rs/zerolog/log"
+)
+
+const artifactCacheIndexFile = "index.json"
+
+var errArtifactCorrupted = errors.New("cached artifact failed integrity check")
+
+// ArtifactCache is a content-addressed cache of files and folders downloaded from the filestore,
+// such as LoRA weights. Downloads are checksummed, blobs are verified before first use, and the
+// least recently used artifacts are evicted once the cache grows beyond its budget. Concurrent
+// fetches of the same artifact share a single download.
+//
+// Blobs are stored exactly as downloaded, so folders are kept as tarballs and expanded on use.
+// Artifacts are keyed by their filestore path, which never changes content for session files and
+// LoRA dirs.
+type ArtifactCache struct {
+	dir      string
+	maxBytes uint64
+
+	mu       sync.Mutex
+	entries  map[string]*artifactEntry // Filestore path to the blob holding it
+	inflight map[string]chan struct{}  // Keys being downloaded, closed when done
+	pinned   map[string]int            // Digests being copied out of the cache, not to be evicted
+}
+
+type artifactEntry struct {
+	Key      string    `json:"key"`
+	Digest   string    `json:"digest"` // sha256 of the downloaded bytes, also the blob's name
+	Size     uint64    `json:"size"`
+	Folder   bool      `json:"folder"`
+	LastUsed time.Time `json:"last_used"`
+
+	verified bool // Blob checked against its digest since the cache was loaded
+}
+
+// artifactDownloader writes the artifact to w. It returns the digest reported by the server, or
+// an empty string if the server didn't send one.
+type artifactDownloader func(w io.Writer) (string, error)
+
+// NewArtifactCache opens the cache in dir, creating it if needed. A maxBytes of 0 means no limit.
+func NewArtifactCache(dir string, maxBytes uint64) (*ArtifactCache, error) {
+	for _, d := range []string{dir, filepath.Join(dir, "blobs"), filepath.Join(dir, "tmp")} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create artifact cache dir: %w", err)
+		}
+	}
+
+	c := &ArtifactCache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		entries:  make(map[string]*artifactEntry),
+		inflight: make(map[string]chan struct{}),
+		pinned:   make(map[string]int),
+	}
+
+	if err := c.load(); err != nil {
+		log.Warn().Err(err).Str("dir", dir).Msg("unable to load artifact cache index, starting empty")
+		c.entries = make(map[string]*artifactEntry)
+	}
+
+	// Partial downloads from a previous run are useless
+	if err := os.RemoveAll(filepath.Join(dir, "tmp")); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "tmp"), 0755); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.evict("")
+	return c, c.save()
+}
+
+func newArtifactCacheFromConfig(cacheDir string, cfg config.ArtifactCache) (*ArtifactCache, error) {
+	maxBytes, err := humanize.ParseBytes(cfg.MaxSize)
+	if err != nil {
+		return nil, fmt.Errorf("invalid artifact cache max size %q: %w", cfg.MaxSize, err)
+	}
+	dir := cfg.Dir
+	if dir == "" {
+		if cacheDir == "" {
+			return nil, fmt.Errorf("artifact cache dir is required")
+		}
+		dir = filepath.Join(cacheDir, "helix-artifacts")
+	}
+	return NewArtifactCache(dir, maxBytes)
+}
+
+// Fetch places the artifact at localPath, downloading it into the cache first if needed.
+func (c *ArtifactCache) Fetch(key string, folder bool, localPath string, download artifactDownloader) error {
+	for {
+		c.mu.Lock()
+		if done, ok := c.inflight[key]; ok {
+			// Someone else is downloading it, wait and look again
+			c.mu.Unlock()
+			<-done
+			continue
+		}
+
+		entry, ok := c.entries[key]
+		if ok {
+			entry.LastUsed = time.Now()
+			c.pinned[entry.Digest]++
+			c.mu.Unlock()
+
+			err := c.materialize(entry, localPath)
+			c.unpin(entry.Digest)
+			if errors.Is(err, errArtifactCorrupted) {
+				log.Warn().Str("key", key).Str("digest", entry.Digest).Msg("cached artifact is corrupted, downloading again")
+				c.remove(key)
+				continue
+			}
+			return err
+		}
+
+		done := make(chan struct{})
+		c.inflight[key] = done
+		c.mu.Unlock()
+
+		err := c.download(key, folder, download)
+
+		c.mu.Lock()
+		delete(c.inflight, key)
+		close(done)
+		c.mu.Unlock()
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// download fetches an artifact into a blob and records it in the index
+func (c *ArtifactCache) download(key string, folder bool, download artifactDownloader) error {
+	tmp, err := os.CreateTemp(filepath.Join(c.dir, "tmp"), "download-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	counter := &countingWriter{}
+	expected, err := download(io.MultiWriter(tmp, hash, counter))
+	if err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	digest := hex.EncodeToString(hash.Sum(nil))
+	if expected != "" && expected != digest {
+		return fmt.Errorf("checksum mismatch downloading %s: expected %s, got %s", key, expected, digest)
+	}
+
+	if err := os.Rename(tmp.Name(), c.blobPath(digest)); err != nil {
+		return fmt.Errorf("failed to store artifact: %w", err)
+	}
+
+	log.Debug().
+		Str("key", key).
+		Str("digest", digest).
+		Uint64("size", counter.n).
+		Msg("🟠 artifact cached")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = &artifactEntry{
+		Key:      key,
+		Digest:   digest,
+		Size:     counter.n,
+		Folder:   folder,
+		LastUsed: time.Now(),
+		verified: true,
+	}
+	c.evict(key)
+	return c.save()
+}
+
+// materialize copies an artifact out of the cache
+func (c *ArtifactCache) materialize(entry *artifactEntry, localPath string) error {
+	blobPath := c.blobPath(entry.Digest)
+
+	c.mu.Lock()
+	verified := entry.verified
+	c.mu.Unlock()
+	if !verified {
+		if err := verifyBlob(blobPath, entry.Digest, entry.Size); err != nil {
+			log.Warn().Err(err).Str("key", entry.Key).Msg("artifact failed verification")
+			return errArtifactCorrupted
+		}
+		c.mu.Lock()
+		entry.verified = true
+		c.mu.Unlock()
+	}
+
+	if entry.Folder {
+		f, err := os.Open(blobPath)
+		if err != nil {
+			return errArtifactCorrupted
+		}
+		defer f.Close()
+		return system.ExpandTarReader(f, localPath)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(localPath), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create folder: %w", err)
+	}
+	// Copy rather than link so that whatever uses the file can't modify the blob
+	return copyFile(blobPath, localPath)
+}
+
+// evict removes least recently used artifacts until the cache fits in its budget. The artifact
+// being added and pinned blobs are kept. Must hold the lock.
+func (c *ArtifactCache) evict(keep string) {
+	if c.maxBytes == 0 {
+		return
+	}
+
+	entries := make([]*artifactEntry, 0, len(c.entries))
+	for _, entry := range c.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].LastUsed.Before(entries[j].LastUsed)
+	})
+
+	for _, entry := range entries {
+		if c.sizeLocked() <= c.maxBytes {
+			return
+		}
+		if entry.Key == keep || c.pinned[entry.Digest] > 0 {
+			continue
+		}
+		log.Info().
+			Str("key", entry.Key).
+			Uint64("size", entry.Size).
+			Msg("evicting artifact from cache")
+		c.removeLocked(entry.Key)
+	}
+}
+
+// Keys returns the filestore paths of cached artifacts, sorted.
+func (c *ArtifactCache) Keys() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys := make([]string, 0, len(c.entries))
+	for key := range c.entries {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Size returns the number of bytes held by the cache.
+func (c *ArtifactCache) Size() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sizeLocked()
+}
+
+// sizeLocked counts each blob once, even if several keys share it. Must hold the lock.
+func (c *ArtifactCache) sizeLocked() uint64 {
+	var total uint64
+	seen := make(map[string]bool, len(c.entries))
+	for _, entry := range c.entries {
+		if seen[entry.Digest] {
+			continue
+		}
+		seen[entry.Digest] = true
+		total += entry.Size
+	}
+	return total
+}
+
+func (c *ArtifactCache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeLocked(key)
+	if err := c.save(); err != nil {
+		log.Error().Err(err).Msg("failed to save artifact cache index")
+	}
+}
+
+// removeLocked drops a key, and its blob if no other key uses it. Must hold the lock.
+func (c *ArtifactCache) removeLocked(key string) {
+	entry, ok := c.entries[key]
+	if !ok {
+		return
+	}
+	delete(c.entries, key)
+
+	for _, other := range c.entries {
+		if other.Digest == entry.Digest {
+			return
+		}
+	}
+	if err := os.Remove(c.blobPath(entry.Digest)); err != nil && !os.IsNotExist(err) {
+		log.Error().Err(err).Str("digest", entry.Digest).Msg("failed to remove artifact blob")
+	}
+}
+
+func (c *ArtifactCache) unpin(digest string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pinned[digest]--
+	if c.pinned[digest] <= 0 {
+		delete(c.pinned, digest)
+	}
+}
+
+func (c *ArtifactCache) blobPath(digest string) string {
+	return filepath.Join(c.dir, "blobs", digest)
+}
+
+// load reads the index, dropping entries whose blob has gone missing
+func (c *ArtifactCache) load() error {
+	bts, err := os.ReadFile(filepath.Join(c.dir, artifactCacheIndexFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var entries []*artifactEntry
+	if err := json.Unmarshal(bts, &entries); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if _, err := os.Stat(c.blobPath(entry.Digest)); err != nil {
+			continue
+		}
+		c.entries[entry.Key] = entry
+	}
+	return nil
+}
+
+// save writes the index atomically. Must hold the lock.
+func (c *ArtifactCache) save() error {
+	entries := make([]*artifactEntry, 0, len(c.entries))
+	for _, entry := range c.entries {
+		entries = append(entries, entry)
+	}
+	bts, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := filepath.Join(c.dir, artifactCacheIndexFile+".tmp")
+	if err := os.WriteFile(tmpPath, bts, 0644); err != nil {
+		return fmt.Errorf("failed to write artifact cache index: %w", err)
+	}
+	return os.Rename(tmpPath, filepath.Join(c.dir, artifactCacheIndexFile))
+}
+
+func verifyBlob(path string, digest string, size uint64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	n, err := io.Copy(hash, f)
+	if err != nil {
+		return err
+	}
+	if uint64(n) != size {
+		return fmt.Errorf("expected %d bytes, got %d", size, n)
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != digest {
+		return fmt.Errorf("expected digest %s, got %s", digest, got)
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+type countingWriter struct {
+	n uint64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += uint64(len(p))
+	return len(p), nil
+}
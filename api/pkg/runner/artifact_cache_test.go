@@ -0,0 +1,151 @@
+package runner
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func staticDownloader(content []byte, downloads *atomic.Int32) artifactDownloader {
+	return func(w io.Writer) (string, error) {
+		downloads.Add(1)
+		_, err := w.Write(content)
+		sum := sha256.Sum256(content)
+		return hex.EncodeToString(sum[:]), err
+	}
+}
+
+func TestArtifactCache_FetchFile(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewArtifactCache(filepath.Join(dir, "cache"), 0)
+	require.NoError(t, err)
+
+	var downloads atomic.Int32
+	download := staticDownloader([]byte("hello"), &downloads)
+
+	for _, name := range []string{"a.txt", "b.txt"} {
+		localPath := filepath.Join(dir, "out", name)
+		require.NoError(t, cache.Fetch("sessions/1/a.txt", false, localPath, download))
+		content, err := os.ReadFile(localPath)
+		require.NoError(t, err)
+		assert.Equal(t, "hello", string(content))
+	}
+	assert.Equal(t, int32(1), downloads.Load())
+	assert.Equal(t, []string{"sessions/1/a.txt"}, cache.Keys())
+
+	// The index survives a restart
+	cache, err = NewArtifactCache(filepath.Join(dir, "cache"), 0)
+	require.NoError(t, err)
+	require.NoError(t, cache.Fetch("sessions/1/a.txt", false, filepath.Join(dir, "out", "c.txt"), download))
+	assert.Equal(t, int32(1), downloads.Load())
+}
+
+func TestArtifactCache_FetchFolder(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "adapter.bin", Mode: 0644, Size: 4, Typeflag: tar.TypeReg}))
+	_, err := tw.Write([]byte("lora"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+
+	dir := t.TempDir()
+	cache, err := NewArtifactCache(filepath.Join(dir, "cache"), 0)
+	require.NoError(t, err)
+
+	var downloads atomic.Int32
+	localPath := filepath.Join(dir, "lora_dir")
+	require.NoError(t, cache.Fetch("lora/1", true, localPath, staticDownloader(buf.Bytes(), &downloads)))
+
+	content, err := os.ReadFile(filepath.Join(localPath, "adapter.bin"))
+	require.NoError(t, err)
+	assert.Equal(t, "lora", string(content))
+}
+
+func TestArtifactCache_ConcurrentFetchDownloadsOnce(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewArtifactCache(filepath.Join(dir, "cache"), 0)
+	require.NoError(t, err)
+
+	release := make(chan struct{})
+	var downloads atomic.Int32
+	download := func(w io.Writer) (string, error) {
+		downloads.Add(1)
+		<-release
+		_, err := w.Write([]byte("weights"))
+		return "", err
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, cache.Fetch("lora/1", false, filepath.Join(dir, "out", string(rune('a'+i))), download))
+		}(i)
+	}
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), downloads.Load())
+}
+
+func TestArtifactCache_ChecksumMismatch(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewArtifactCache(filepath.Join(dir, "cache"), 0)
+	require.NoError(t, err)
+
+	err = cache.Fetch("lora/1", false, filepath.Join(dir, "out"), func(w io.Writer) (string, error) {
+		_, err := w.Write([]byte("truncated"))
+		return "0000", err
+	})
+	assert.ErrorContains(t, err, "checksum mismatch")
+	assert.Empty(t, cache.Keys())
+}
+
+func TestArtifactCache_CorruptedBlobIsDownloadedAgain(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewArtifactCache(filepath.Join(dir, "cache"), 0)
+	require.NoError(t, err)
+
+	var downloads atomic.Int32
+	download := staticDownloader([]byte("hello"), &downloads)
+	require.NoError(t, cache.Fetch("a", false, filepath.Join(dir, "out", "1"), download))
+
+	// Blobs are verified the first time they're used after a restart
+	sum := sha256.Sum256([]byte("hello"))
+	require.NoError(t, os.WriteFile(cache.blobPath(hex.EncodeToString(sum[:])), []byte("HELLO"), 0644))
+	cache, err = NewArtifactCache(filepath.Join(dir, "cache"), 0)
+	require.NoError(t, err)
+
+	require.NoError(t, cache.Fetch("a", false, filepath.Join(dir, "out", "2"), download))
+	content, err := os.ReadFile(filepath.Join(dir, "out", "2"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(content))
+	assert.Equal(t, int32(2), downloads.Load())
+}
+
+func TestArtifactCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewArtifactCache(filepath.Join(dir, "cache"), 10)
+	require.NoError(t, err)
+
+	var downloads atomic.Int32
+	require.NoError(t, cache.Fetch("a", false, filepath.Join(dir, "out", "a1"), staticDownloader([]byte("aaaa"), &downloads)))
+	require.NoError(t, cache.Fetch("b", false, filepath.Join(dir, "out", "b1"), staticDownloader([]byte("bbbb"), &downloads)))
+	// Using a makes b the least recently used
+	require.NoError(t, cache.Fetch("a", false, filepath.Join(dir, "out", "a2"), staticDownloader([]byte("aaaa"), &downloads)))
+	require.NoError(t, cache.Fetch("c", false, filepath.Join(dir, "out", "c1"), staticDownloader([]byte("cccc"), &downloads)))
+
+	assert.Equal(t, []string{"a", "c"}, cache.Keys())
+	assert.LessOrEqual(t, cache.Size(), uint64(10))
+}
@@ -175,7 +175,7 @@ func NewAxolotlModelInstance(ctx context.Context, cfg *ModelInstanceConfig) (*Ax
 		httpClientOptions: httpClientOptions,
 	}
 
-	fileHandler := NewFileHandler(cfg.RunnerOptions.ID, httpClientOptions, modelInstance.taskResponseHandler)
+	fileHandler := NewFileHandler(cfg.RunnerOptions.ID, httpClientOptions, modelInstance.taskResponseHandler, cfg.RunnerOptions.ArtifactCache)
 	modelInstance.fileHandler = fileHandler
 
 	modelInstance.workCh <- cfg.InitialSession
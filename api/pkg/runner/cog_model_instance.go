@@ -156,7 +156,7 @@ func NewCogModelInstance(ctx context.Context, cfg *ModelInstanceConfig) (*CogMod
 		httpClientOptions: httpClientOptions,
 	}
 
-	fileHandler := NewFileHandler(cfg.RunnerOptions.ID, httpClientOptions, modelInstance.taskResponseHandler)
+	fileHandler := NewFileHandler(cfg.RunnerOptions.ID, httpClientOptions, modelInstance.taskResponseHandler, cfg.RunnerOptions.ArtifactCache)
 	modelInstance.fileHandler = fileHandler
 
 	modelInstance.workCh <- cfg.InitialSession
@@ -8,6 +8,7 @@ import (
 	"io"
 	"net/url"
 	"runtime/debug"
+	"slices"
 	"strings"
 	"time"
 
@@ -89,6 +90,9 @@ type Options struct {
 	MaxModelInstances int
 
 	RuntimeFactory SlotFactory
+
+	// set by NewRunner from Config.ArtifactCache, nil if disabled
+	ArtifactCache *ArtifactCache
 }
 
 type Runner struct {
@@ -133,6 +137,13 @@ func NewRunner(
 	if options.MemoryBytes == 0 {
 		return nil, fmt.Errorf("memory is required")
 	}
+	if options.Config != nil && options.Config.ArtifactCache.Enabled && options.ArtifactCache == nil {
+		cache, err := newArtifactCacheFromConfig(options.CacheDir, options.Config.ArtifactCache)
+		if err != nil {
+			return nil, fmt.Errorf("error creating artifact cache: %w", err)
+		}
+		options.ArtifactCache = cache
+	}
 	runner := &Runner{
 		Ctx:     ctx,
 		Options: options,
@@ -242,6 +253,11 @@ func (r *Runner) pollSlots(_ context.Context) error {
 	}
 
 	for _, slot := range desiredSlots.Data {
+		// Requests the API has released from the slot are done with
+		if runtime, ok := r.slots[slot.ID]; ok {
+			runtime.ForgetReleased(slot.Attributes.Workloads)
+		}
+
 		// If there's no work, then we don't need to do anything for now
 		if slot.Attributes.Workload == nil {
 			continue
@@ -282,6 +298,41 @@ func (r *Runner) pollSlots(_ context.Context) error {
 			continue
 		}
 
+		if work.WorkloadType == scheduler.WorkloadTypeLLMInferenceRequest {
+			// Stop anything the API has given up on
+			for _, workload := range slot.Attributes.Workloads {
+				if workload.LLMInferenceRequest != nil && slices.Contains(slot.Attributes.CancelledRequestIDs, workload.LLMInferenceRequest.RequestID) {
+					l.Debug().Str("request_id", workload.LLMInferenceRequest.RequestID).Msg("cancelling LLM inference request")
+					runtime.CancelLLMInferenceRequest(workload.LLMInferenceRequest)
+				}
+			}
+
+			// If the scheduler has batched several requests onto the slot, hand over as many as the
+			// model instance will take, the rest are picked up on the next poll
+			if len(slot.Attributes.Workloads) > 1 {
+				for _, workload := range slot.Attributes.Workloads {
+					if workload.LLMInferenceRequest == nil || runtime.IsDispatched(workload.LLMInferenceRequest.RequestID) {
+						continue
+					}
+					batchWork, err := scheduler.NewLLMWorkload(workload.LLMInferenceRequest)
+					if err != nil {
+						return err
+					}
+					log.Debug().Str("workload_id", batchWork.ID()).Msg("enqueuing batched LLM inference request")
+					if !runtime.TrySetLLMInferenceRequest(batchWork) {
+						break
+					}
+				}
+				continue
+			}
+
+			// Don't run the same request twice
+			if runtime.IsDispatched(work.ID()) {
+				l.Trace().Str("slot_id", slot.ID.String()).Msg("work already dispatched")
+				continue
+			}
+		}
+
 		// If the runtime already has work scheduled, then we don't need to do anything
 		if runtime.IsScheduled() {
 			l.Trace().Str("slot_id", slot.ID.String()).Msg("runtime already scheduled")
@@ -308,6 +359,54 @@ func (r *Runner) pollSlots(_ context.Context) error {
 	return nil
 }
 
+// Drain asks the control plane to stop sending work to this runner and waits until all of the
+// runner's slots have been released, or the context is done.
+func (r *Runner) Drain(ctx context.Context) error {
+	parsedURL, err := url.Parse(system.URL(r.httpClientOptions, system.GetAPIPath(fmt.Sprintf("/runner/%s/drain", r.Options.ID))))
+	if err != nil {
+		return err
+	}
+
+	req, err := retryablehttp.NewRequestWithContext(ctx, "POST", parsedURL.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	err = system.AddAuthHeadersRetryable(req, r.httpClientOptions.Token)
+	if err != nil {
+		return err
+	}
+
+	client := system.NewRetryClient(3)
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error requesting drain: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("error requesting drain: status code %d", resp.StatusCode)
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for runner to drain: %w", ctx.Err())
+		case <-ticker.C:
+			slots, err := r.getSlots()
+			if err != nil {
+				log.Warn().Err(err).Msg("error checking drain status")
+				continue
+			}
+			if slots != nil && slots.MaintenanceState == types.RunnerMaintenanceStateDrained {
+				log.Info().Msg("🟢 runner drained")
+				return nil
+			}
+		}
+	}
+}
+
 func (r *Runner) getSlots() (*types.GetDesiredRunnerSlotsResponse, error) {
 	parsedURL, err := url.Parse(system.URL(r.httpClientOptions, system.GetAPIPath(fmt.Sprintf("/runner/%s/slots", r.Options.ID))))
 	if err != nil {
@@ -533,9 +632,18 @@ func (r *Runner) getState() (*types.RunnerState, error) {
 		SchedulingDecisions: []string{"[Deprecated] Runners no longer make scheduling decisions. This will be removed shortly"},
 		Version:             data.GetHelixVersion(),
 		Slots:               r.getRunnerSlots(),
+		CachedModels:        cachedModels(r.Options.CacheDir),
+		CachedArtifacts:     r.cachedArtifacts(),
 	}, nil
 }
 
+func (r *Runner) cachedArtifacts() []string {
+	if r.Options.ArtifactCache == nil {
+		return nil
+	}
+	return r.Options.ArtifactCache.Keys()
+}
+
 func (r *Runner) getRunnerSlots() []types.RunnerActualSlot {
 	slots := []types.RunnerActualSlot{}
 	for slotID, runtime := range r.slots {
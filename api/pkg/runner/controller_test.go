@@ -188,3 +188,99 @@ func TestController_SlotLifecycle(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Len(t, runner.slots, 2)
 }
+
+func TestController_BatchedSlot(t *testing.T) {
+	testSlotID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	newWorkload := func(id string) *types.RunnerWorkload {
+		return &types.RunnerWorkload{
+			LLMInferenceRequest: &types.RunnerLLMInferenceRequest{
+				RequestID: id,
+				Request: &openai.ChatCompletionRequest{
+					Model: model.ModelOllamaLlama38b,
+				},
+			},
+		}
+	}
+	apiSlots := &types.GetDesiredRunnerSlotsResponse{
+		Data: []types.DesiredRunnerSlot{
+			{
+				ID: testSlotID,
+				Attributes: types.DesiredRunnerSlotAttributes{
+					Workload:  newWorkload("test-1"),
+					Workloads: []*types.RunnerWorkload{newWorkload("test-1")},
+				},
+			},
+		},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		data, err := json.Marshal(apiSlots)
+		assert.NoError(t, err)
+		if _, err := w.Write(data); err != nil {
+			t.Fatalf("error writing api slots: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctrl := gomock.NewController(t)
+	m := NewMockModelInstance(ctrl)
+	m.EXPECT().ID().Return("test").AnyTimes()
+	mockLLMWorkChan := make(chan *types.RunnerLLMInferenceRequest, 1)
+	var responses []*types.RunnerLLMInferenceResponse
+	runner, err := NewRunner(ctx, Options{
+		APIHost:     server.URL,
+		ID:          "test",
+		APIToken:    "test",
+		MemoryBytes: 1,
+		RuntimeFactory: &mockRuntimeFactory{
+			getRuntimeFunc: func() *Slot {
+				return &Slot{
+					modelInstance: m,
+					llmWorkChan:   mockLLMWorkChan,
+					dispatched:    map[string]bool{"test-1": true},
+					inferenceResponseHandler: func(res *types.RunnerLLMInferenceResponse) error {
+						responses = append(responses, res)
+						return nil
+					},
+				}
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	err = runner.pollSlots(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, runner.slots, 1)
+
+	// The scheduler batches two more requests on the slot, only one fits right now
+	apiSlots.Data[0].Attributes.Workloads = []*types.RunnerWorkload{newWorkload("test-1"), newWorkload("test-2"), newWorkload("test-3")}
+	err = runner.pollSlots(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, mockLLMWorkChan, 1)
+	assert.Equal(t, "test-2", (<-mockLLMWorkChan).RequestID)
+
+	// The API cancels the third request before it is dispatched
+	apiSlots.Data[0].Attributes.CancelledRequestIDs = []string{"test-3"}
+	err = runner.pollSlots(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, mockLLMWorkChan, 0)
+	assert.Len(t, responses, 1)
+	assert.Equal(t, "test-3", responses[0].RequestID)
+	assert.True(t, responses[0].Done)
+	assert.NotEmpty(t, responses[0].Error)
+
+	// Cancellations are only reported once
+	err = runner.pollSlots(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, responses, 1)
+
+	// The slot forgets the requests the API releases
+	apiSlots.Data[0].Attributes.Workloads = []*types.RunnerWorkload{newWorkload("test-1")}
+	apiSlots.Data[0].Attributes.CancelledRequestIDs = nil
+	err = runner.pollSlots(context.Background())
+	assert.NoError(t, err)
+	slot := runner.slots[testSlotID]
+	assert.Equal(t, map[string]bool{"test-1": true}, slot.dispatched)
+	assert.Empty(t, slot.cancelled)
+}
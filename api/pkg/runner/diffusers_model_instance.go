@@ -160,7 +160,7 @@ func NewDiffusersModelInstance(ctx context.Context, cfg *ModelInstanceConfig) (*
 		httpClientOptions: httpClientOptions,
 	}
 
-	fileHandler := NewFileHandler(cfg.RunnerOptions.ID, httpClientOptions, modelInstance.taskResponseHandler)
+	fileHandler := NewFileHandler(cfg.RunnerOptions.ID, httpClientOptions, modelInstance.taskResponseHandler, cfg.RunnerOptions.ArtifactCache)
 	modelInstance.fileHandler = fileHandler
 
 	modelInstance.workCh <- cfg.InitialSession
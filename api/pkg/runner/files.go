@@ -3,6 +3,8 @@ package runner
 import (
 	"archive/tar"
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -48,17 +50,20 @@ type FileHandler struct {
 	runnerID          string
 	httpClientOptions system.ClientOptions
 	eventHandler      func(res *types.RunnerTaskResponse)
+	cache             *ArtifactCache // optional, downloads go straight to disk without it
 }
 
 func NewFileHandler(
 	runnerID string,
 	clientOptions system.ClientOptions,
 	eventHandler func(res *types.RunnerTaskResponse),
+	cache *ArtifactCache,
 ) *FileHandler {
 	return &FileHandler{
 		runnerID:          runnerID,
 		httpClientOptions: clientOptions,
 		eventHandler:      eventHandler,
+		cache:             cache,
 	}
 }
 
@@ -102,31 +107,18 @@ func (handler *FileHandler) downloadFile(sessionID string, remotePath string, lo
 		return nil
 	}
 
-	url := system.URL(handler.httpClientOptions, system.GetAPIPath(fmt.Sprintf("/runner/%s/session/%s/download/file", handler.runnerID, sessionID)))
-	urlValues := urllib.Values{}
-	urlValues.Add("path", remotePath)
-
-	fullURL := fmt.Sprintf("%s?%s", url, urlValues.Encode())
-
-	log.Debug().
-		Msgf("🔵 runner downloading interaction file: %s", fullURL)
-
-	req, err := http.NewRequest("GET", fullURL, nil)
-	if err != nil {
-		return err
+	download := func(w io.Writer) (string, error) {
+		return handler.download(sessionID, "file", remotePath, w)
 	}
-	if err := system.AddAutheaders(req, handler.httpClientOptions.Token); err != nil {
-		return err
-	}
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code for file download: %d %s", resp.StatusCode, fullURL)
+	if handler.cache != nil {
+		err := handler.cache.Fetch(remotePath, false, localPath, download)
+		if err != nil {
+			return err
+		}
+		log.Debug().
+			Msgf("🔵 runner fetched interaction file: %s -> %s", remotePath, localPath)
+		return nil
 	}
 
 	file, err := os.Create(localPath)
@@ -135,8 +127,14 @@ func (handler *FileHandler) downloadFile(sessionID string, remotePath string, lo
 	}
 	defer file.Close()
 
-	_, err = io.Copy(file, resp.Body)
+	hash := sha256.New()
+	digest, err := download(io.MultiWriter(file, hash))
+	if err == nil && digest != "" && digest != hex.EncodeToString(hash.Sum(nil)) {
+		err = fmt.Errorf("downloading file %s: checksum mismatch", remotePath)
+	}
 	if err != nil {
+		// Don't leave a partial file behind, it would be mistaken for a complete download
+		os.Remove(localPath)
 		return err
 	}
 
@@ -153,55 +151,89 @@ func (handler *FileHandler) downloadFolder(sessionID string, remotePath string,
 		return nil
 	}
 
+	download := func(w io.Writer) (string, error) {
+		return handler.download(sessionID, "folder", remotePath, w)
+	}
+
+	if handler.cache != nil {
+		err := handler.cache.Fetch(remotePath, true, localPath, download)
+		if err != nil {
+			return err
+		}
+		log.Debug().Msgf("🟠 runner fetched folder: %s %s", sessionID, localPath)
+		return nil
+	}
+
+	var buffer bytes.Buffer
+	digest, err := download(&buffer)
+	if err != nil {
+		return err
+	}
+	if err := checkDigest(buffer.Bytes(), digest); err != nil {
+		return fmt.Errorf("downloading folder %s: %w", remotePath, err)
+	}
+
 	if err := os.MkdirAll(localPath, os.ModePerm); err != nil {
 		return fmt.Errorf("failed to create folder: %w", err)
 	}
-	url := system.URL(handler.httpClientOptions, system.GetAPIPath(fmt.Sprintf("/runner/%s/session/%s/download/folder", handler.runnerID, sessionID)))
+
+	log.Debug().Msgf("🟠 runner expanding tar buffer folder: %s %s", sessionID, localPath)
+
+	err = system.ExpandTarBuffer(&buffer, localPath)
+	if err != nil {
+		return err
+	}
+
+	log.Debug().Msgf("🟠 runner downloaded folder: %s %s", sessionID, localPath)
+
+	return nil
+}
+
+// download streams a session file or folder (as a tar) from the API into w. It returns the
+// digest the API sent in the trailer, which is only available once the body has been read.
+func (handler *FileHandler) download(sessionID string, kind string, remotePath string, w io.Writer) (string, error) {
+	url := system.URL(handler.httpClientOptions, system.GetAPIPath(fmt.Sprintf("/runner/%s/session/%s/download/%s", handler.runnerID, sessionID, kind)))
 	urlValues := urllib.Values{}
 	urlValues.Add("path", remotePath)
 	fullURL := fmt.Sprintf("%s?%s", url, urlValues.Encode())
 
 	log.Debug().
-		Msgf("🔵 runner downloading folder: %s %s", sessionID, remotePath)
+		Msgf("🔵 runner downloading %s: %s", kind, fullURL)
 
 	req, err := http.NewRequest("GET", fullURL, nil)
 	if err != nil {
-		return err
+		return "", err
 	}
 	if err := system.AddAutheaders(req, handler.httpClientOptions.Token); err != nil {
-		return err
+		return "", err
 	}
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code for file download: %d %s", resp.StatusCode, fullURL)
+		return "", fmt.Errorf("unexpected status code for %s download: %d %s", kind, resp.StatusCode, fullURL)
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	_, err = io.Copy(w, resp.Body)
 	if err != nil {
-		return err
-	}
-
-	var buffer bytes.Buffer
-	_, err = buffer.Write(body)
-	if err != nil {
-		return err
+		return "", err
 	}
 
-	log.Debug().Msgf("🟠 runner expanding tar buffer folder: %s %s", sessionID, localPath)
+	return resp.Trailer.Get(types.RunnerContentDigestTrailer), nil
+}
 
-	err = system.ExpandTarBuffer(&buffer, localPath)
-	if err != nil {
-		return err
+func checkDigest(data []byte, expected string) error {
+	if expected == "" {
+		return nil
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); got != expected {
+		return fmt.Errorf("checksum mismatch: expected %s, got %s", expected, got)
 	}
-
-	log.Debug().Msgf("🟠 runner downloaded folder: %s %s", sessionID, localPath)
-
 	return nil
 }
 
@@ -3,6 +3,7 @@ package runner
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -26,6 +27,9 @@ const (
 	defaultMaxTokens = 8192
 )
 
+// errRequestCancelled is reported to the API when a request was stopped before it finished
+var errRequestCancelled = errors.New("request cancelled")
+
 type InferenceModelInstanceConfig struct {
 	RunnerOptions Options
 
@@ -49,6 +53,8 @@ func NewOllamaInferenceModelInstance(ctx context.Context, cfg *InferenceModelIns
 		return nil, err
 	}
 
+	numParallel := max(1, cfg.RunnerOptions.Config.Runtimes.Ollama.NumParallel)
+
 	ctx, cancel := context.WithCancel(ctx)
 	i := &OllamaInferenceModelInstance{
 		ctx:             ctx,
@@ -66,6 +72,9 @@ func NewOllamaInferenceModelInstance(ctx context.Context, cfg *InferenceModelIns
 		lastActivity:    time.Now(),
 		commander:       ollamaCommander,
 		freePortFinder:  freePortFinder,
+		numParallel:     numParallel,
+		sem:             make(chan struct{}, numParallel),
+		requests:        newRequestTracker(),
 	}
 
 	// Enqueue the first request
@@ -93,9 +102,15 @@ type OllamaInferenceModelInstance struct {
 
 	workCh chan *types.RunnerLLMInferenceRequest
 
-	inUse    atomic.Bool // If we are currently processing a request
 	fetching atomic.Bool // If we are fetching the next request
 
+	// numParallel is how many requests are processed at once, sem enforces it
+	numParallel int
+	sem         chan struct{}
+
+	// requests being processed, so that they can be cancelled
+	requests *requestTracker
+
 	// client is the model client
 	client *api.Client
 
@@ -118,6 +133,9 @@ type OllamaInferenceModelInstance struct {
 	// the request that meant this model booted in the first place
 	initialRequest *types.RunnerLLMInferenceRequest
 
+	// activityMu guards currentRequest and lastActivity, which the parallel requests update
+	activityMu sync.Mutex
+
 	// // the session currently running on this model
 	currentRequest *types.RunnerLLMInferenceRequest
 
@@ -180,36 +198,27 @@ func (i *OllamaInferenceModelInstance) Start(_ context.Context) error {
 					log.Info().Msg("🟢 workCh closed, exiting")
 					return
 				}
-				log.Info().Str("session_id", req.SessionID).Msg("🟢 processing request")
-
-				i.currentRequest = req
-				i.lastActivity = time.Now()
+				// Wait for a free parallel slot in ollama
+				select {
+				case <-i.ctx.Done():
+					return
+				case i.sem <- struct{}{}:
+				}
 
-				err := i.processInteraction(req)
-				if err != nil {
-					// If context is cancelled, no error
-					if i.ctx.Err() != nil {
-						log.Error().Msg("context cancelled, exiting")
-						return
-					}
-
-					log.Error().
-						Str("session_id", req.SessionID).
-						Err(err).
-						Msg("error processing request")
-					i.errorResponse(req, err)
-					if strings.Contains(err.Error(), "connection refused") {
-						log.Error().Msg("detected connection refused, exiting and hoping we get restarted - see https://github.com/helixml/helix/issues/242")
-						os.Exit(1)
-					}
-				} else {
-					log.Info().
-						Str("session_id", req.SessionID).
-						Bool("stream", req.Request.Stream).
-						Msg("🟢 request processed")
+				reqCtx, ok := i.beginRequest(req)
+				if !ok {
+					log.Info().Str("request_id", req.RequestID).Msg("🟢 skipping cancelled request")
+					<-i.sem
+					continue
 				}
 
-				i.currentRequest = nil
+				log.Info().Str("session_id", req.SessionID).Msg("🟢 processing request")
+
+				go func() {
+					defer func() { <-i.sem }()
+					defer i.endRequest(req)
+					i.handleRequest(reqCtx, req)
+				}()
 			default:
 				// Get next chat request
 				req, err := i.fetchNextRequest()
@@ -237,6 +246,78 @@ func (i *OllamaInferenceModelInstance) Start(_ context.Context) error {
 	return nil
 }
 
+// handleRequest processes a single request and reports any error to the API
+func (i *OllamaInferenceModelInstance) handleRequest(ctx context.Context, req *types.RunnerLLMInferenceRequest) {
+	err := i.processInteraction(ctx, req)
+	if err != nil {
+		// If the instance is stopping, no error
+		if i.ctx.Err() != nil {
+			log.Error().Msg("context cancelled, exiting")
+			return
+		}
+
+		// The API asked us to stop this request
+		if ctx.Err() != nil {
+			log.Info().Str("request_id", req.RequestID).Msg("🟢 request cancelled")
+			i.errorResponse(req, errRequestCancelled)
+			return
+		}
+
+		log.Error().
+			Str("session_id", req.SessionID).
+			Err(err).
+			Msg("error processing request")
+		i.errorResponse(req, err)
+		if strings.Contains(err.Error(), "connection refused") {
+			log.Error().Msg("detected connection refused, exiting and hoping we get restarted - see https://github.com/helixml/helix/issues/242")
+			os.Exit(1)
+		}
+		return
+	}
+
+	log.Info().
+		Str("session_id", req.SessionID).
+		Bool("stream", req.Request.Stream).
+		Msg("🟢 request processed")
+}
+
+func (i *OllamaInferenceModelInstance) beginRequest(req *types.RunnerLLMInferenceRequest) (context.Context, bool) {
+	i.activityMu.Lock()
+	defer i.activityMu.Unlock()
+
+	ctx, ok := i.requests.Begin(i.ctx, req.RequestID)
+	if !ok {
+		return nil, false
+	}
+
+	i.currentRequest = req
+	i.lastActivity = time.Now()
+	return ctx, true
+}
+
+func (i *OllamaInferenceModelInstance) endRequest(req *types.RunnerLLMInferenceRequest) {
+	i.activityMu.Lock()
+	defer i.activityMu.Unlock()
+
+	if i.requests.End(req.RequestID) == 0 {
+		i.currentRequest = nil
+	}
+	i.lastActivity = time.Now()
+}
+
+// activity returns the request currently running and when the instance was last active
+func (i *OllamaInferenceModelInstance) activity() (*types.RunnerLLMInferenceRequest, time.Time) {
+	i.activityMu.Lock()
+	defer i.activityMu.Unlock()
+
+	return i.currentRequest, i.lastActivity
+}
+
+// CancelRequest stops a request, see requestTracker.Cancel
+func (i *OllamaInferenceModelInstance) CancelRequest(requestID string) bool {
+	return i.requests.Cancel(requestID)
+}
+
 func (i *OllamaInferenceModelInstance) fetchNextRequest() (*types.RunnerLLMInferenceRequest, error) {
 	i.fetching.Store(true)
 	defer i.fetching.Store(false)
@@ -322,7 +403,7 @@ func (i *OllamaInferenceModelInstance) startOllamaServer(_ context.Context) erro
 	cmd.Env = append(cmd.Env,
 		"OLLAMA_KEEP_ALIVE=-1",
 		"OLLAMA_MAX_LOADED_MODELS=1",
-		"OLLAMA_NUM_PARALLEL=1",
+		fmt.Sprintf("OLLAMA_NUM_PARALLEL=%d", i.numParallel),
 		"OLLAMA_FLASH_ATTENTION=1",
 		"OLLAMA_KV_CACHE_TYPE=q8_0",
 		"HTTP_PROXY="+os.Getenv("HTTP_PROXY"),
@@ -365,8 +446,8 @@ func (i *OllamaInferenceModelInstance) startOllamaServer(_ context.Context) erro
 			log.Error().Msgf("Ollama model instance exited with error: %s", err.Error())
 
 			errMsg := string(stderrBuf.Bytes())
-			if i.currentRequest != nil {
-				i.errorResponse(i.currentRequest, fmt.Errorf("%s from cmd - %s", err.Error(), errMsg))
+			if req, _ := i.activity(); req != nil {
+				i.errorResponse(req, fmt.Errorf("%s from cmd - %s", err.Error(), errMsg))
 			}
 
 			return
@@ -444,11 +525,12 @@ func (i *OllamaInferenceModelInstance) Filter() types.SessionFilter {
 
 func (i *OllamaInferenceModelInstance) Stale() bool {
 	// If in use, we don't want to mark it as stale
-	if i.inUse.Load() {
+	if i.requests.Active() > 0 {
 		return false
 	}
 
-	return time.Since(i.lastActivity) > i.runnerOptions.Config.Runtimes.Ollama.InstanceTTL
+	_, lastActivity := i.activity()
+	return time.Since(lastActivity) > i.runnerOptions.Config.Runtimes.Ollama.InstanceTTL
 }
 
 func (i *OllamaInferenceModelInstance) Model() model.Model {
@@ -464,31 +546,32 @@ func (i *OllamaInferenceModelInstance) GetState() (*types.ModelInstanceState, er
 		sessionSummary *types.SessionSummary
 	)
 
-	if i.currentRequest != nil {
+	currentRequest, lastActivity := i.activity()
+	if currentRequest != nil {
 		var summary string
 
 		// Get last message
-		if len(i.currentRequest.Request.Messages) > 0 {
-			summary = i.currentRequest.Request.Messages[len(i.currentRequest.Request.Messages)-1].Content
+		if len(currentRequest.Request.Messages) > 0 {
+			summary = currentRequest.Request.Messages[len(currentRequest.Request.Messages)-1].Content
 		}
 
 		sessionSummary = &types.SessionSummary{
-			SessionID:     i.currentRequest.SessionID,
+			SessionID:     currentRequest.SessionID,
 			Name:          "",
-			InteractionID: i.currentRequest.InteractionID,
+			InteractionID: currentRequest.InteractionID,
 			Mode:          types.SessionModeInference,
 			Type:          types.SessionTypeText,
 			ModelName:     string(i.modelName),
-			Owner:         i.currentRequest.OwnerID,
+			Owner:         currentRequest.OwnerID,
 			LoraDir:       "",
 			Summary:       summary,
 		}
 	}
 
 	stale := false
-	if i.lastActivity.IsZero() {
+	if lastActivity.IsZero() {
 		stale = false
-	} else if time.Since(i.lastActivity) > i.runnerOptions.Config.Runtimes.Ollama.InstanceTTL {
+	} else if time.Since(lastActivity) > i.runnerOptions.Config.Runtimes.Ollama.InstanceTTL {
 		stale = true
 	}
 
@@ -502,7 +585,7 @@ func (i *OllamaInferenceModelInstance) GetState() (*types.ModelInstanceState, er
 		CurrentSession:   sessionSummary,
 		JobHistory:       i.jobHistory,
 		Timeout:          int(i.runnerOptions.Config.Runtimes.Ollama.InstanceTTL.Seconds()),
-		LastActivity:     int(i.lastActivity.Unix()),
+		LastActivity:     int(lastActivity.Unix()),
 		Stale:            stale,
 		MemoryUsage:      i.model.GetMemoryRequirements(types.SessionModeInference),
 		Status:           status,
@@ -541,10 +624,7 @@ func (i *OllamaInferenceModelInstance) getOllamaStatus() string {
 	return status
 }
 
-func (i *OllamaInferenceModelInstance) processInteraction(inferenceReq *types.RunnerLLMInferenceRequest) error {
-	i.inUse.Store(true)
-	defer i.inUse.Store(false)
-
+func (i *OllamaInferenceModelInstance) processInteraction(ctx context.Context, inferenceReq *types.RunnerLLMInferenceRequest) error {
 	// Get the default Ollama models
 	defaultModels, err := model.GetDefaultOllamaModels()
 	if err != nil {
@@ -584,9 +664,15 @@ func (i *OllamaInferenceModelInstance) processInteraction(inferenceReq *types.Ru
 		tools = append(tools, *tool)
 	}
 
+	format, err := oai2ApiFormat(inferenceReq.Request.ResponseFormat)
+	if err != nil {
+		return fmt.Errorf("failed to get response format: %w", err)
+	}
+
 	req := api.ChatRequest{
 		Model:    inferenceReq.Request.Model,
 		Messages: messages,
+		Format:   format,
 		Stream:   &inferenceReq.Request.Stream,
 		Options: map[string]interface{}{
 			"temperature": inferenceReq.Request.Temperature,
@@ -610,12 +696,15 @@ func (i *OllamaInferenceModelInstance) processInteraction(inferenceReq *types.Ru
 		if time.Since(startTime) > timeout {
 			return fmt.Errorf("timeout waiting for Ollama to be ready")
 		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		time.Sleep(100 * time.Millisecond)
 	}
 	log.Info().Msgf("ready in %.4f seconds", time.Since(startTime).Seconds())
 
 	// If the request takes longer than 10 minutes, cancel it
-	timeoutCtx, cancel := context.WithTimeout(i.ctx, 600*time.Second)
+	timeoutCtx, cancel := context.WithTimeout(ctx, 600*time.Second)
 	defer cancel()
 
 	switch {
@@ -787,7 +876,7 @@ func (i *OllamaInferenceModelInstance) errorResponse(req *types.RunnerLLMInferen
 func (i *OllamaInferenceModelInstance) QueueSession(*types.Session, bool) {}
 
 func (i *OllamaInferenceModelInstance) IsActive() bool {
-	return i.currentRequest != nil
+	return i.requests.Active() > 0
 }
 
 func oai2ApiTool(tool openai.Tool) (*api.Tool, error) {
@@ -825,6 +914,26 @@ func oai2ApiTool(tool openai.Tool) (*api.Tool, error) {
 	return apiTool, nil
 }
 
+// oai2ApiFormat converts the response format to Ollama's, a JSON Schema makes Ollama constrain the
+// output to the schema's grammar
+func oai2ApiFormat(responseFormat *openai.ChatCompletionResponseFormat) (json.RawMessage, error) {
+	if responseFormat == nil {
+		return nil, nil
+	}
+
+	switch responseFormat.Type {
+	case openai.ChatCompletionResponseFormatTypeJSONObject:
+		return json.RawMessage(`"json"`), nil
+	case openai.ChatCompletionResponseFormatTypeJSONSchema:
+		if responseFormat.JSONSchema == nil || responseFormat.JSONSchema.Schema == nil {
+			return json.RawMessage(`"json"`), nil
+		}
+		return json.Marshal(responseFormat.JSONSchema.Schema)
+	default:
+		return nil, nil
+	}
+}
+
 func api2OaiTool(tool api.ToolCall) (*openai.ToolCall, error) {
 	tc := &openai.ToolCall{
 		Index: &tool.Function.Index,
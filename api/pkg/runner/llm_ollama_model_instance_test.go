@@ -0,0 +1,66 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+func TestOai2ApiFormat(t *testing.T) {
+	format, err := oai2ApiFormat(nil)
+	require.NoError(t, err)
+	assert.Nil(t, format)
+
+	format, err = oai2ApiFormat(&openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject})
+	require.NoError(t, err)
+	assert.Equal(t, `"json"`, string(format))
+
+	format, err = oai2ApiFormat(&openai.ChatCompletionResponseFormat{
+		Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+		JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+			Name:   "person",
+			Schema: json.RawMessage(`{"type": "object", "properties": {"age": {"type": "integer"}}}`),
+		},
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type": "object", "properties": {"age": {"type": "integer"}}}`, string(format))
+
+	format, err = oai2ApiFormat(&openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeText})
+	require.NoError(t, err)
+	assert.Nil(t, format)
+}
+
+func TestOllamaInferenceModelInstance_ParallelActivity(t *testing.T) {
+	instance := &OllamaInferenceModelInstance{
+		ctx:      context.Background(),
+		requests: newRequestTracker(),
+	}
+
+	var wg sync.WaitGroup
+	for n := 0; n < 8; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			req := &types.RunnerLLMInferenceRequest{RequestID: fmt.Sprintf("req_%d", n)}
+			_, ok := instance.beginRequest(req)
+			assert.True(t, ok)
+
+			instance.Stale()
+			instance.endRequest(req)
+		}()
+	}
+	wg.Wait()
+
+	current, lastActivity := instance.activity()
+	assert.Nil(t, current)
+	assert.False(t, lastActivity.IsZero())
+}
@@ -0,0 +1,72 @@
+package runner
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// requestTracker keeps track of the requests an LLM model instance is processing in parallel so
+// that they can be cancelled individually.
+type requestTracker struct {
+	mu        sync.Mutex
+	inFlight  map[string]context.CancelFunc // Requests being processed
+	cancelled map[string]bool               // Requests that were cancelled before they arrived
+	active    atomic.Int32
+}
+
+func newRequestTracker() *requestTracker {
+	return &requestTracker{
+		inFlight:  make(map[string]context.CancelFunc),
+		cancelled: make(map[string]bool),
+	}
+}
+
+// Begin registers a request as in flight. It returns false if the request was cancelled before it
+// got here.
+func (t *requestTracker) Begin(ctx context.Context, requestID string) (context.Context, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.cancelled[requestID] {
+		delete(t.cancelled, requestID)
+		return nil, false
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	t.inFlight[requestID] = cancel
+	t.active.Add(1)
+	return ctx, true
+}
+
+// End marks a request as finished and returns how many requests are still in flight.
+func (t *requestTracker) End(requestID string) int32 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if cancel, ok := t.inFlight[requestID]; ok {
+		cancel()
+		delete(t.inFlight, requestID)
+	}
+	return t.active.Add(-1)
+}
+
+// Cancel stops a request. It returns true if the request was in flight, in which case the model
+// instance reports the cancellation once it has stopped. Otherwise the request is skipped if it
+// arrives later and it is up to the caller to report the cancellation.
+func (t *requestTracker) Cancel(requestID string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if cancel, ok := t.inFlight[requestID]; ok {
+		cancel()
+		return true
+	}
+	t.cancelled[requestID] = true
+	return false
+}
+
+// Active returns the number of requests in flight.
+func (t *requestTracker) Active() int32 {
+	return t.active.Load()
+}
@@ -0,0 +1,597 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/helixml/helix/api/pkg/model"
+	"github.com/helixml/helix/api/pkg/system"
+	"github.com/helixml/helix/api/pkg/types"
+	openai "github.com/sashabaranov/go-openai"
+
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	vllmCommander Commander     = &RealCommander{}
+	_             ModelInstance = &VLLMInferenceModelInstance{}
+)
+
+// VLLMInferenceModelInstance serves LLM inference requests with a vLLM OpenAI-compatible server
+// running as a subprocess. vLLM batches concurrent requests itself, so many requests are sent to
+// it at once.
+func NewVLLMInferenceModelInstance(ctx context.Context, cfg *InferenceModelInstanceConfig, request *types.RunnerLLMInferenceRequest) (*VLLMInferenceModelInstance, error) {
+	modelName := model.Name(request.Request.Model)
+
+	aiModel, err := model.GetModel(string(modelName))
+	if err != nil {
+		return nil, err
+	}
+
+	numParallel := max(1, cfg.RunnerOptions.Config.Runtimes.VLLM.NumParallel)
+
+	ctx, cancel := context.WithCancel(ctx)
+	i := &VLLMInferenceModelInstance{
+		ctx:             ctx,
+		cancel:          cancel,
+		id:              system.GenerateUUID(),
+		finishCh:        make(chan bool),
+		workCh:          make(chan *types.RunnerLLMInferenceRequest, 1),
+		model:           aiModel,
+		modelName:       modelName,
+		initialRequest:  request,
+		responseHandler: cfg.ResponseHandler,
+		getNextRequest:  cfg.GetNextRequest,
+		runnerOptions:   cfg.RunnerOptions,
+		lastActivity:    time.Now(),
+		commander:       vllmCommander,
+		freePortFinder:  freePortFinder,
+		sem:             make(chan struct{}, numParallel),
+		requests:        newRequestTracker(),
+	}
+
+	// Enqueue the first request
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Error().Msgf("Recovered from panic in VLLMInferenceModelInstance.Start: %v, work probably lost", r)
+			}
+		}()
+		i.workCh <- request
+	}()
+
+	return i, nil
+}
+
+type VLLMInferenceModelInstance struct {
+	id string
+
+	model     model.Model
+	modelName model.Name
+
+	runnerOptions Options
+
+	finishCh chan bool
+
+	workCh chan *types.RunnerLLMInferenceRequest
+
+	fetching atomic.Bool // If we are fetching the next request
+
+	// sem limits how many requests are sent to vLLM at once
+	sem chan struct{}
+
+	// requests being processed, so that they can be cancelled
+	requests *requestTracker
+
+	// client talks to the vLLM OpenAI-compatible server
+	client *openai.Client
+
+	// Streaming response handler
+	responseHandler func(res *types.RunnerLLMInferenceResponse) error
+
+	// Pulls the next request from the API
+	getNextRequest func() (*types.RunnerLLMInferenceRequest, error)
+
+	// we create a cancel context for the running process
+	// which is derived from the main runner context
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// the command we are currently executing, nil when using the mock server
+	currentCommand *exec.Cmd
+
+	// the mock server used instead of vLLM when MockRunner is set
+	mockServer *http.Server
+
+	// the request that meant this model booted in the first place
+	initialRequest *types.RunnerLLMInferenceRequest
+
+	// activityMu guards currentRequest and lastActivity, which the parallel requests update
+	activityMu sync.Mutex
+
+	// the most recent request, used for reporting state
+	currentRequest *types.RunnerLLMInferenceRequest
+
+	// the timestamp of when this model instance either completed a job
+	// or a new job was pulled and allocated
+	// we use this timestamp to cleanup non-active model instances
+	lastActivity time.Time
+
+	// Interface to run commands
+	commander Commander
+
+	// Interface to find free ports
+	freePortFinder FreePortFinder
+
+	// port is the port that the vLLM server is running on
+	port int
+}
+
+func (i *VLLMInferenceModelInstance) Start(_ context.Context) error {
+	port, err := i.freePortFinder.GetFreePort()
+	if err != nil {
+		return fmt.Errorf("error getting free port: %s", err.Error())
+	}
+	i.port = port
+
+	if i.runnerOptions.MockRunner {
+		err = i.startMockServer()
+	} else {
+		err = i.startVLLMServer()
+	}
+	if err != nil {
+		return err
+	}
+
+	config := openai.DefaultConfig("helix")
+	config.BaseURL = fmt.Sprintf("http://127.0.0.1:%d/v1", i.port)
+	i.client = openai.NewClientWithConfig(config)
+
+	err = i.waitUntilHealthy()
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Error().Msgf("Recovered from panic in VLLMInferenceModelInstance.Start: %v, work probably lost", r)
+			}
+		}()
+
+		for {
+			select {
+			case <-i.ctx.Done():
+				log.Info().Msgf("🟢 vLLM model instance has stopped, closing channel listener")
+				return
+			case req, ok := <-i.workCh:
+				if !ok {
+					log.Info().Msg("🟢 workCh closed, exiting")
+					return
+				}
+
+				// Wait until vLLM can take another request
+				select {
+				case <-i.ctx.Done():
+					return
+				case i.sem <- struct{}{}:
+				}
+
+				reqCtx, ok := i.beginRequest(req)
+				if !ok {
+					log.Info().Str("request_id", req.RequestID).Msg("🟢 skipping cancelled request")
+					<-i.sem
+					continue
+				}
+
+				log.Info().Str("session_id", req.SessionID).Msg("🟢 processing request")
+
+				go func() {
+					defer func() { <-i.sem }()
+					defer i.endRequest(req)
+					i.handleRequest(reqCtx, req)
+				}()
+			default:
+				req, err := i.fetchNextRequest()
+				if err != nil {
+					log.Error().Err(err).Msg("error getting next request")
+					time.Sleep(300 * time.Millisecond)
+					continue
+				}
+
+				if req == nil {
+					log.Trace().Msg("no next request")
+					time.Sleep(300 * time.Millisecond)
+					continue
+				}
+
+				log.Info().Str("session_id", req.SessionID).Msg("🟢 enqueuing request")
+
+				// this can fail because workCh is closed, in which case we
+				// recover above
+				i.workCh <- req
+			}
+		}
+	}()
+
+	return nil
+}
+
+// gpuMemoryUtilization is the fraction of the runner's GPU memory that vLLM may use. vLLM
+// pre-allocates it, so it must be limited to what the scheduler reserved for this model.
+func (i *VLLMInferenceModelInstance) gpuMemoryUtilization() float64 {
+	if i.runnerOptions.MemoryBytes == 0 {
+		return 0.9
+	}
+	fraction := float64(i.model.GetMemoryRequirements(types.SessionModeInference)) / float64(i.runnerOptions.MemoryBytes)
+	return min(max(fraction, 0.05), 0.95)
+}
+
+func (i *VLLMInferenceModelInstance) startVLLMServer() error {
+	cfg := i.runnerOptions.Config.Runtimes.VLLM
+
+	pythonPath, err := i.commander.LookPath(cfg.PythonPath)
+	if err != nil {
+		return fmt.Errorf("%s not found in PATH", cfg.PythonPath)
+	}
+
+	args := []string{
+		"-m", "vllm.entrypoints.openai.api_server",
+		"--model", i.modelName.String(),
+		"--served-model-name", i.modelName.String(),
+		"--host", "127.0.0.1",
+		"--port", strconv.Itoa(i.port),
+		"--gpu-memory-utilization", strconv.FormatFloat(i.gpuMemoryUtilization(), 'f', 2, 64),
+		"--max-num-seqs", strconv.Itoa(cap(i.sem)),
+		"--download-dir", i.runnerOptions.CacheDir,
+	}
+	if m, ok := i.model.(*model.VLLMGenericText); ok && m.ContextLength > 0 {
+		args = append(args, "--max-model-len", strconv.FormatInt(m.ContextLength, 10))
+	}
+	args = append(args, cfg.ExtraArgs...)
+
+	cmd := i.commander.CommandContext(i.ctx, pythonPath, args...)
+	// Getting base env (HOME, HF_TOKEN, etc)
+	cmd.Env = append(cmd.Env, os.Environ()...)
+	cmd.Env = append(cmd.Env,
+		"PYTHONUNBUFFERED=1",
+		"HF_HOME="+i.runnerOptions.CacheDir,
+	)
+
+	cmd.Stdout = os.Stdout
+
+	// keep the last 10kb of stderr so if there is an error we can send it to the api
+	stderrBuf := system.NewLimitedBuffer(1024 * 10)
+	cmd.Stderr = io.MultiWriter(os.Stderr, stderrBuf)
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error starting vLLM model instance: %s", err.Error())
+	}
+
+	i.currentCommand = cmd
+
+	go func() {
+		defer close(i.finishCh)
+		if err := cmd.Wait(); err != nil {
+			log.Error().Msgf("vLLM model instance exited with error: %s", err.Error())
+
+			errMsg := string(stderrBuf.Bytes())
+			if req, _ := i.activity(); req != nil {
+				i.errorResponse(req, fmt.Errorf("%s from cmd - %s", err.Error(), errMsg))
+			}
+
+			return
+		}
+
+		log.Info().Msgf("🟢 vLLM model instance stopped, exit code=%d", cmd.ProcessState.ExitCode())
+	}()
+
+	return nil
+}
+
+// waitUntilHealthy waits for the server to load the model. This can take minutes for vLLM.
+func (i *VLLMInferenceModelInstance) waitUntilHealthy() error {
+	timeout := i.runnerOptions.Config.Runtimes.VLLM.StartupTimeout
+	if timeout == 0 {
+		timeout = 10 * time.Minute
+	}
+	startCtx, cancel := context.WithTimeout(i.ctx, timeout)
+	defer cancel()
+
+	for {
+		select {
+		case <-startCtx.Done():
+			return fmt.Errorf("timeout waiting for vLLM model instance to start")
+		case <-i.finishCh:
+			return fmt.Errorf("vLLM model instance exited before it was ready")
+		default:
+			resp, err := http.DefaultClient.Get(fmt.Sprintf("http://127.0.0.1:%d/health", i.port))
+			if err != nil {
+				time.Sleep(500 * time.Millisecond)
+				continue
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+			time.Sleep(500 * time.Millisecond)
+		}
+	}
+}
+
+func (i *VLLMInferenceModelInstance) fetchNextRequest() (*types.RunnerLLMInferenceRequest, error) {
+	i.fetching.Store(true)
+	defer i.fetching.Store(false)
+
+	return i.getNextRequest()
+}
+
+// handleRequest processes a single request and reports any error to the API
+func (i *VLLMInferenceModelInstance) handleRequest(ctx context.Context, req *types.RunnerLLMInferenceRequest) {
+	err := i.processInteraction(ctx, req)
+	if err != nil {
+		// If the instance is stopping, no error
+		if i.ctx.Err() != nil {
+			log.Error().Msg("context cancelled, exiting")
+			return
+		}
+
+		// The API asked us to stop this request
+		if ctx.Err() != nil {
+			log.Info().Str("request_id", req.RequestID).Msg("🟢 request cancelled")
+			i.errorResponse(req, errRequestCancelled)
+			return
+		}
+
+		log.Error().
+			Str("session_id", req.SessionID).
+			Err(err).
+			Msg("error processing request")
+		i.errorResponse(req, err)
+		return
+	}
+
+	log.Info().
+		Str("session_id", req.SessionID).
+		Bool("stream", req.Request.Stream).
+		Msg("🟢 request processed")
+}
+
+func (i *VLLMInferenceModelInstance) beginRequest(req *types.RunnerLLMInferenceRequest) (context.Context, bool) {
+	i.activityMu.Lock()
+	defer i.activityMu.Unlock()
+
+	ctx, ok := i.requests.Begin(i.ctx, req.RequestID)
+	if !ok {
+		return nil, false
+	}
+
+	i.currentRequest = req
+	i.lastActivity = time.Now()
+	return ctx, true
+}
+
+func (i *VLLMInferenceModelInstance) endRequest(req *types.RunnerLLMInferenceRequest) {
+	i.activityMu.Lock()
+	defer i.activityMu.Unlock()
+
+	if i.requests.End(req.RequestID) == 0 {
+		i.currentRequest = nil
+	}
+	i.lastActivity = time.Now()
+}
+
+// activity returns the request currently running and when the instance was last active
+func (i *VLLMInferenceModelInstance) activity() (*types.RunnerLLMInferenceRequest, time.Time) {
+	i.activityMu.Lock()
+	defer i.activityMu.Unlock()
+
+	return i.currentRequest, i.lastActivity
+}
+
+// CancelRequest stops a request, see requestTracker.Cancel
+func (i *VLLMInferenceModelInstance) CancelRequest(requestID string) bool {
+	return i.requests.Cancel(requestID)
+}
+
+func (i *VLLMInferenceModelInstance) processInteraction(ctx context.Context, inferenceReq *types.RunnerLLMInferenceRequest) error {
+	// vLLM speaks OpenAI, so the request is passed straight through
+	request := *inferenceReq.Request
+	request.Model = i.modelName.String()
+
+	// If the request takes longer than 10 minutes, cancel it
+	timeoutCtx, cancel := context.WithTimeout(ctx, 600*time.Second)
+	defer cancel()
+
+	start := time.Now()
+
+	if !request.Stream {
+		resp, err := i.client.CreateChatCompletion(timeoutCtx, request)
+		if err != nil {
+			return fmt.Errorf("failed to get response from inference API: %w", err)
+		}
+		i.responseProcessor(inferenceReq, &resp, time.Since(start).Milliseconds())
+		return nil
+	}
+
+	stream, err := i.client.CreateChatCompletionStream(timeoutCtx, request)
+	if err != nil {
+		return fmt.Errorf("failed to get response from inference API: %w", err)
+	}
+	defer stream.Close()
+
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			i.responseStreamProcessor(inferenceReq, nil, true, time.Since(start).Milliseconds())
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read response stream from inference API: %w", err)
+		}
+		i.responseStreamProcessor(inferenceReq, &resp, false, time.Since(start).Milliseconds())
+	}
+}
+
+func (i *VLLMInferenceModelInstance) responseStreamProcessor(req *types.RunnerLLMInferenceRequest, resp *openai.ChatCompletionStreamResponse, done bool, durationMs int64) {
+	if resp == nil {
+		// Stub response for the last "done" entry
+		resp = &openai.ChatCompletionStreamResponse{}
+	}
+
+	err := i.responseHandler(&types.RunnerLLMInferenceResponse{
+		RequestID:      req.RequestID,
+		OwnerID:        req.OwnerID,
+		SessionID:      req.SessionID,
+		InteractionID:  req.InteractionID,
+		StreamResponse: resp,
+		DurationMs:     durationMs,
+		Done:           done,
+	})
+	if err != nil {
+		log.Error().Msgf("error writing event: %s", err.Error())
+	}
+}
+
+func (i *VLLMInferenceModelInstance) responseProcessor(req *types.RunnerLLMInferenceRequest, resp *openai.ChatCompletionResponse, durationMs int64) {
+	err := i.responseHandler(&types.RunnerLLMInferenceResponse{
+		RequestID:     req.RequestID,
+		OwnerID:       req.OwnerID,
+		SessionID:     req.SessionID,
+		InteractionID: req.InteractionID,
+		Response:      resp,
+		DurationMs:    durationMs,
+		Done:          true,
+	})
+	if err != nil {
+		log.Error().Msgf("error writing event: %s", err.Error())
+	}
+}
+
+func (i *VLLMInferenceModelInstance) errorResponse(req *types.RunnerLLMInferenceRequest, err error) {
+	apiUpdateErr := i.responseHandler(&types.RunnerLLMInferenceResponse{
+		RequestID:     req.RequestID,
+		OwnerID:       req.OwnerID,
+		SessionID:     req.SessionID,
+		InteractionID: req.InteractionID,
+		Error:         err.Error(),
+	})
+
+	if apiUpdateErr != nil {
+		log.Error().Msgf("Error reporting error to api: %v\n", apiUpdateErr.Error())
+	}
+}
+
+func (i *VLLMInferenceModelInstance) Stop() error {
+	switch {
+	case i.mockServer != nil:
+		if err := i.mockServer.Close(); err != nil {
+			log.Error().Msgf("error stopping mock vLLM server: %s", err.Error())
+		}
+	case i.currentCommand != nil:
+		log.Info().Msgf("🟢 stop vLLM model instance tree")
+		if err := killProcessTree(i.currentCommand.Process.Pid); err != nil {
+			log.Error().Msgf("error stopping vLLM model process: %s", err.Error())
+			return err
+		}
+		log.Info().Msgf("🟢 stopped vLLM instance")
+	default:
+		return fmt.Errorf("no vLLM process to stop")
+	}
+	close(i.workCh)
+	// Only cancel the context once the child processes are gone, otherwise they can keep holding
+	// GPU memory
+	i.cancel()
+
+	return nil
+}
+
+func (i *VLLMInferenceModelInstance) ID() string {
+	return i.id
+}
+
+func (i *VLLMInferenceModelInstance) Filter() types.SessionFilter {
+	return types.SessionFilter{
+		ModelName: string(i.modelName),
+		Mode:      types.SessionModeInference,
+	}
+}
+
+func (i *VLLMInferenceModelInstance) Stale() bool {
+	// If in use, we don't want to mark it as stale
+	if i.requests.Active() > 0 {
+		return false
+	}
+
+	_, lastActivity := i.activity()
+	return time.Since(lastActivity) > i.runnerOptions.Config.Runtimes.VLLM.InstanceTTL
+}
+
+func (i *VLLMInferenceModelInstance) Model() model.Model {
+	return i.model
+}
+
+func (i *VLLMInferenceModelInstance) GetState() (*types.ModelInstanceState, error) {
+	if i.initialRequest == nil {
+		return nil, fmt.Errorf("no initial session")
+	}
+
+	currentRequest, lastActivity := i.activity()
+
+	var sessionSummary *types.SessionSummary
+	if req := currentRequest; req != nil {
+		var summary string
+		if len(req.Request.Messages) > 0 {
+			summary = req.Request.Messages[len(req.Request.Messages)-1].Content
+		}
+
+		sessionSummary = &types.SessionSummary{
+			SessionID:     req.SessionID,
+			InteractionID: req.InteractionID,
+			Mode:          types.SessionModeInference,
+			Type:          types.SessionTypeText,
+			ModelName:     string(i.modelName),
+			Owner:         req.OwnerID,
+			Summary:       summary,
+		}
+	}
+
+	status := "Running"
+	if i.requests.Active() > 0 {
+		status = fmt.Sprintf("Running, %d active requests", i.requests.Active())
+	}
+
+	return &types.ModelInstanceState{
+		ID:               i.id,
+		ModelName:        string(i.modelName),
+		Mode:             types.SessionModeInference,
+		InitialSessionID: i.initialRequest.SessionID,
+		CurrentSession:   sessionSummary,
+		JobHistory:       []*types.SessionSummary{},
+		Timeout:          int(i.runnerOptions.Config.Runtimes.VLLM.InstanceTTL.Seconds()),
+		LastActivity:     int(lastActivity.Unix()),
+		Stale:            i.Stale(),
+		MemoryUsage:      i.model.GetMemoryRequirements(types.SessionModeInference),
+		Status:           status,
+	}, nil
+}
+
+func (i *VLLMInferenceModelInstance) Done() <-chan bool {
+	return i.finishCh
+}
+
+func (i *VLLMInferenceModelInstance) QueueSession(*types.Session, bool) {}
+
+func (i *VLLMInferenceModelInstance) IsActive() bool {
+	return i.requests.Active() > 0
+}
@@ -0,0 +1,90 @@
+package runner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/helixml/helix/api/pkg/config"
+	"github.com/helixml/helix/api/pkg/model"
+	"github.com/helixml/helix/api/pkg/types"
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVLLMInferenceModelInstance_Mock(t *testing.T) {
+	models, err := model.GetDefaultVLLMModels()
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	runnerCfg := &config.RunnerConfig{}
+	runnerCfg.Runtimes.VLLM.NumParallel = 2
+	runnerCfg.Runtimes.VLLM.StartupTimeout = 10 * time.Second
+
+	responses := make(chan *types.RunnerLLMInferenceResponse, 10)
+	newRequest := func(id string, stream bool) *types.RunnerLLMInferenceRequest {
+		return &types.RunnerLLMInferenceRequest{
+			RequestID: id,
+			Request: &openai.ChatCompletionRequest{
+				Model:    models[0].ID,
+				Stream:   stream,
+				Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hi"}},
+			},
+		}
+	}
+	workCh := make(chan *types.RunnerLLMInferenceRequest, 1)
+
+	instance, err := NewVLLMInferenceModelInstance(ctx, &InferenceModelInstanceConfig{
+		ResponseHandler: func(res *types.RunnerLLMInferenceResponse) error {
+			responses <- res
+			return nil
+		},
+		GetNextRequest: func() (*types.RunnerLLMInferenceRequest, error) {
+			select {
+			case req := <-workCh:
+				return req, nil
+			case <-time.After(100 * time.Millisecond):
+				return nil, nil
+			}
+		},
+		RunnerOptions: Options{
+			MockRunner: true,
+			Config:     runnerCfg,
+		},
+	}, newRequest("request-1", false))
+	require.NoError(t, err)
+	require.NoError(t, instance.Start(ctx))
+	defer func() { _ = instance.Stop() }()
+
+	// The initial request is answered in one go
+	res := waitForResponse(t, responses)
+	assert.Equal(t, "request-1", res.RequestID)
+	assert.Empty(t, res.Error)
+	assert.True(t, res.Done)
+	require.NotNil(t, res.Response)
+	assert.Equal(t, vllmMockResponse, res.Response.Choices[0].Message.Content)
+
+	// A streaming request produces a chunk and then a done message
+	workCh <- newRequest("request-2", true)
+	res = waitForResponse(t, responses)
+	assert.Equal(t, "request-2", res.RequestID)
+	assert.False(t, res.Done)
+	require.NotNil(t, res.StreamResponse)
+	assert.Equal(t, vllmMockResponse, res.StreamResponse.Choices[0].Delta.Content)
+	res = waitForResponse(t, responses)
+	assert.True(t, res.Done)
+}
+
+func waitForResponse(t *testing.T, responses chan *types.RunnerLLMInferenceResponse) *types.RunnerLLMInferenceResponse {
+	t.Helper()
+	select {
+	case res := <-responses:
+		return res
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for response")
+		return nil
+	}
+}
@@ -0,0 +1,73 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/helixml/helix/api/pkg/model"
+	"github.com/rs/zerolog/log"
+)
+
+// cachedModels returns the models whose weights are already in the cache dir. The runtimes
+// download weights themselves, so this looks for the layout each of them leaves behind.
+func cachedModels(cacheDir string) []string {
+	if cacheDir == "" {
+		return nil
+	}
+
+	var cached []string
+
+	ollamaModels, err := model.GetDefaultOllamaModels()
+	if err != nil {
+		log.Error().Err(err).Msg("error getting ollama models")
+	}
+	for _, m := range ollamaModels {
+		if pathExists(ollamaManifestPath(cacheDir, m.ID)) {
+			cached = append(cached, m.ID)
+		}
+	}
+
+	var hfModels []string
+	vllmModels, err := model.GetDefaultVLLMModels()
+	if err != nil {
+		log.Error().Err(err).Msg("error getting vLLM models")
+	}
+	for _, m := range vllmModels {
+		hfModels = append(hfModels, m.ID)
+	}
+	diffusersModels, err := model.GetDefaultDiffusersModels()
+	if err != nil {
+		log.Error().Err(err).Msg("error getting diffusers models")
+	}
+	for _, m := range diffusersModels {
+		hfModels = append(hfModels, m.ID)
+	}
+	for _, id := range hfModels {
+		// vLLM downloads into the cache dir, diffusers into its hub subfolder
+		dir := "models--" + strings.ReplaceAll(id, "/", "--")
+		if pathExists(filepath.Join(cacheDir, dir)) || pathExists(filepath.Join(cacheDir, "hub", dir)) {
+			cached = append(cached, id)
+		}
+	}
+
+	return cached
+}
+
+// ollamaManifestPath returns where ollama keeps the manifest for a model, e.g.
+// manifests/registry.ollama.ai/library/llama3.1/8b-instruct-q8_0
+func ollamaManifestPath(cacheDir string, id string) string {
+	name, tag, ok := strings.Cut(id, ":")
+	if !ok {
+		tag = "latest"
+	}
+	if !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+	return filepath.Join(cacheDir, "manifests", "registry.ollama.ai", name, tag)
+}
+
+func pathExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -34,6 +34,17 @@ type Slot struct {
 	sessionWorkChan        chan *types.Session
 	currentWork            *scheduler.Workload
 	sessionResponseHandler func(res *types.RunnerTaskResponse) error
+
+	// LLM inference requests can be batched on a slot, so keep track of which requests have been
+	// handed to the model instance and which have been cancelled by the API
+	inferenceResponseHandler func(res *types.RunnerLLMInferenceResponse) error
+	dispatched               map[string]bool
+	cancelled                map[string]bool
+}
+
+// requestCanceller is implemented by model instances that can stop an individual request
+type requestCanceller interface {
+	CancelRequest(requestID string) bool
 }
 
 func (r *Slot) Stop() {
@@ -65,9 +76,86 @@ func (r *Slot) OriginalWorkload() *types.RunnerWorkload {
 
 func (r *Slot) SetLLMInferenceRequest(work *scheduler.Workload) {
 	r.currentWork = work
+	r.markDispatched(work.ID())
 	r.llmWorkChan <- work.LLMInferenceRequest()
 }
 
+// TrySetLLMInferenceRequest hands an extra request to a model instance that is already busy. It
+// returns false if the instance can't accept more work right now.
+func (r *Slot) TrySetLLMInferenceRequest(work *scheduler.Workload) bool {
+	select {
+	case r.llmWorkChan <- work.LLMInferenceRequest():
+		r.currentWork = work
+		r.markDispatched(work.ID())
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *Slot) IsDispatched(requestID string) bool {
+	return r.dispatched[requestID]
+}
+
+func (r *Slot) markDispatched(requestID string) {
+	if r.dispatched == nil {
+		r.dispatched = make(map[string]bool)
+	}
+	r.dispatched[requestID] = true
+}
+
+// ForgetReleased drops the requests that the API no longer schedules on the slot. They have
+// finished or their cancellation has been reported, so they won't be handed out again.
+func (r *Slot) ForgetReleased(workloads []*types.RunnerWorkload) {
+	scheduled := make(map[string]bool, len(workloads))
+	for _, workload := range workloads {
+		if workload.LLMInferenceRequest != nil {
+			scheduled[workload.LLMInferenceRequest.RequestID] = true
+		}
+	}
+	for requestID := range r.dispatched {
+		if !scheduled[requestID] {
+			delete(r.dispatched, requestID)
+		}
+	}
+	for requestID := range r.cancelled {
+		if !scheduled[requestID] {
+			delete(r.cancelled, requestID)
+		}
+	}
+}
+
+// CancelLLMInferenceRequest stops a request that the API no longer wants. If the model instance
+// isn't working on it, the cancellation is reported straight away so that the API can release it.
+func (r *Slot) CancelLLMInferenceRequest(req *types.RunnerLLMInferenceRequest) {
+	if r.cancelled[req.RequestID] {
+		return
+	}
+	if r.cancelled == nil {
+		r.cancelled = make(map[string]bool)
+	}
+	r.cancelled[req.RequestID] = true
+	r.markDispatched(req.RequestID) // Never dispatch cancelled work
+
+	if canceller, ok := r.modelInstance.(requestCanceller); ok && canceller.CancelRequest(req.RequestID) {
+		return
+	}
+	if r.inferenceResponseHandler == nil {
+		return
+	}
+	err := r.inferenceResponseHandler(&types.RunnerLLMInferenceResponse{
+		RequestID:     req.RequestID,
+		OwnerID:       req.OwnerID,
+		SessionID:     req.SessionID,
+		InteractionID: req.InteractionID,
+		Error:         errRequestCancelled.Error(),
+		Done:          true,
+	})
+	if err != nil {
+		log.Error().Err(err).Str("request_id", req.RequestID).Msg("failed reporting cancelled request")
+	}
+}
+
 func (r *Slot) SetSessionRequest(work *scheduler.Workload) {
 	r.currentWork = work
 	r.sessionWorkChan <- work.Session()
@@ -105,36 +193,47 @@ func (f *runtimeFactory) NewSlot(ctx context.Context,
 	runnerOptions Options,
 ) (*Slot, error) {
 	slot := &Slot{
-		ID:                     slotID,
-		RunnerID:               runnerOptions.ID,
-		originalWork:           work,
-		currentWork:            work,
-		sessionResponseHandler: sessionResponseHandler,
+		ID:                       slotID,
+		RunnerID:                 runnerOptions.ID,
+		originalWork:             work,
+		currentWork:              work,
+		sessionResponseHandler:   sessionResponseHandler,
+		inferenceResponseHandler: inferenceResponseHandler,
 	}
 	switch work.WorkloadType {
 	case scheduler.WorkloadTypeLLMInferenceRequest:
-		log.Debug().Str("workload_id", work.ID()).Msg("starting new ollama runtime")
 		workCh := make(chan *types.RunnerLLMInferenceRequest, 1)
-		ollama, err := NewOllamaInferenceModelInstance(
-			ctx,
-			&InferenceModelInstanceConfig{
-				ResponseHandler: inferenceResponseHandler,
-				GetNextRequest: func() (*types.RunnerLLMInferenceRequest, error) {
-					return <-workCh, nil
-				},
-				RunnerOptions: runnerOptions,
+		instanceCfg := &InferenceModelInstanceConfig{
+			ResponseHandler: inferenceResponseHandler,
+			GetNextRequest: func() (*types.RunnerLLMInferenceRequest, error) {
+				return <-workCh, nil
 			},
-			work.LLMInferenceRequest(),
+			RunnerOptions: runnerOptions,
+		}
+		var (
+			modelInstance ModelInstance
+			err           error
 		)
+		runtimeName := work.ModelName().InferenceRuntime()
+		switch runtimeName {
+		case types.InferenceRuntimeVLLM:
+			log.Debug().Str("workload_id", work.ID()).Msg("starting new vllm runtime")
+			modelInstance, err = NewVLLMInferenceModelInstance(ctx, instanceCfg, work.LLMInferenceRequest())
+		default:
+			runtimeName = types.InferenceRuntimeOllama
+			log.Debug().Str("workload_id", work.ID()).Msg("starting new ollama runtime")
+			modelInstance, err = NewOllamaInferenceModelInstance(ctx, instanceCfg, work.LLMInferenceRequest())
+		}
 		if err != nil {
-			return nil, fmt.Errorf("error creating ollama runtime: %s", err.Error())
+			return nil, fmt.Errorf("error creating %s runtime: %s", runtimeName, err.Error())
 		}
-		err = ollama.Start(ctx)
+		err = modelInstance.Start(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("error starting ollama runtime: %s", err.Error())
+			return nil, fmt.Errorf("error starting %s runtime: %s", runtimeName, err.Error())
 		}
-		slot.modelInstance = ollama
+		slot.modelInstance = modelInstance
 		slot.llmWorkChan = workCh
+		slot.markDispatched(work.ID()) // The instance starts with the original request
 		return slot, nil
 	case scheduler.WorkloadTypeSession:
 		log.Debug().Str("workload_id", work.ID()).Msg("starting new session runtime")
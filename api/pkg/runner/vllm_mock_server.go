@@ -0,0 +1,105 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+
+	"github.com/rs/zerolog/log"
+)
+
+const vllmMockResponse = "This is a mock response from the vLLM runtime."
+
+// startMockServer serves a fake OpenAI-compatible API in place of vLLM, so that the platform can
+// be developed without a GPU
+func (i *VLLMInferenceModelInstance) startMockServer() error {
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", i.port))
+	if err != nil {
+		return fmt.Errorf("error starting mock vLLM server: %s", err.Error())
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/v1/chat/completions", i.mockChatCompletion)
+
+	i.mockServer = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		defer close(i.finishCh)
+		if err := i.mockServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Error().Msgf("mock vLLM server exited with error: %s", err.Error())
+		}
+	}()
+
+	return nil
+}
+
+func (i *VLLMInferenceModelInstance) mockChatCompletion(w http.ResponseWriter, r *http.Request) {
+	var req openai.ChatCompletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if i.runnerOptions.MockRunnerError != "" {
+		http.Error(w, i.runnerOptions.MockRunnerError, http.StatusInternalServerError)
+		return
+	}
+
+	select {
+	case <-r.Context().Done():
+		return
+	case <-time.After(time.Duration(i.runnerOptions.MockRunnerDelay) * time.Second):
+	}
+
+	if !req.Stream {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			ID:      "mock",
+			Object:  "chat.completion",
+			Created: time.Now().Unix(),
+			Model:   req.Model,
+			Choices: []openai.ChatCompletionChoice{
+				{
+					Message: openai.ChatCompletionMessage{
+						Role:    openai.ChatMessageRoleAssistant,
+						Content: vllmMockResponse,
+					},
+					FinishReason: openai.FinishReasonStop,
+				},
+			},
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	chunk, _ := json.Marshal(openai.ChatCompletionStreamResponse{
+		ID:      "mock",
+		Object:  "chat.completion.chunk",
+		Created: time.Now().Unix(),
+		Model:   req.Model,
+		Choices: []openai.ChatCompletionStreamChoice{
+			{
+				Delta: openai.ChatCompletionStreamChoiceDelta{
+					Role:    openai.ChatMessageRoleAssistant,
+					Content: vllmMockResponse,
+				},
+				FinishReason: openai.FinishReasonStop,
+			},
+		},
+	})
+	fmt.Fprintf(w, "data: %s\n\n", chunk)
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -15,14 +15,15 @@ import (
 type WorkloadAllocator interface {
 	AllocateNewSlot(runnerID string, req *Workload) (*Slot, error)
 	AllocateSlot(slotID uuid.UUID, req *Workload) error
-	ReleaseSlot(slotID uuid.UUID) error
+	ReleaseSlot(slotID uuid.UUID, workID string) error
 	DeadSlots(deadRunnerIDs []string) []*Slot
 	WarmSlots(req *Workload) []*Slot
 	RunnerSlots(id string) []*Slot
 	ReconcileSlots(props *types.RunnerState) error
 
 	// New queuing scheduler methods
-	StartSlot(slotID uuid.UUID) error
+	StartSlot(slotID uuid.UUID, workID string) error
+	CancelSlot(slotID uuid.UUID, workID string) error
 	DeleteSlot(slotID uuid.UUID)
 }
 
@@ -34,6 +35,7 @@ type workloadAllocator struct {
 	slots           *xsync.MapOf[uuid.UUID, *Slot] // Maps slot ID to Slot details.
 	modelStaleFunc  TimeoutFunc                    // Function to check if models are stale
 	slotTimeoutFunc TimeoutFunc                    // Function to check if slots have timed out due to error
+	maxBatchSize    int                            // How many LLM inference requests can share a slot
 }
 
 var _ WorkloadAllocator = &workloadAllocator{}
@@ -48,6 +50,7 @@ func NewWorkloadAllocator(staleFunc TimeoutFunc, slotTimeoutFunc TimeoutFunc) *w
 		slots:           xsync.NewMapOf[uuid.UUID, *Slot](),
 		modelStaleFunc:  staleFunc,
 		slotTimeoutFunc: slotTimeoutFunc,
+		maxBatchSize:    1,
 	}
 }
 
@@ -64,12 +67,9 @@ func (a *workloadAllocator) AllocateSlot(slotID uuid.UUID, req *Workload) error
 		return fmt.Errorf("slot not found: %s", slot.ID.String())
 	}
 
-	// Ensure the slot is not already scheduled or active.
-	if slot.IsScheduled() {
-		return fmt.Errorf("slot has scheduled work: %s", slot.ID.String())
-	}
-	if slot.IsActive() {
-		return fmt.Errorf("slot already active: %s", slot.ID.String())
+	// Ensure the slot has room for more work.
+	if slot.IsFull() {
+		return fmt.Errorf("slot is full: %s", slot.ID.String())
 	}
 
 	log.Trace().
@@ -81,7 +81,7 @@ func (a *workloadAllocator) AllocateSlot(slotID uuid.UUID, req *Workload) error
 		Msg("allocating slot")
 
 	// Schedule the slot.
-	slot.Schedule()
+	slot.Schedule(req.ID())
 
 	return nil
 }
@@ -90,6 +90,10 @@ func (a *workloadAllocator) AllocateSlot(slotID uuid.UUID, req *Workload) error
 func (a *workloadAllocator) AllocateNewSlot(runnerID string, req *Workload) (*Slot, error) {
 	// Create a new slot and schedule the workload.
 	slot := NewSlot(runnerID, req, a.modelStaleFunc, a.slotTimeoutFunc)
+	// Only LLM inference requests can be batched by the runtime
+	if req.WorkloadType == WorkloadTypeLLMInferenceRequest {
+		slot.SetCapacity(a.maxBatchSize)
+	}
 	log.Trace().
 		Str("runner_id", slot.RunnerID).
 		Str("slot_id", slot.ID.String()).
@@ -105,8 +109,8 @@ func (a *workloadAllocator) AllocateNewSlot(runnerID string, req *Workload) (*Sl
 	return slot, a.AllocateSlot(slot.ID, req)
 }
 
-// ReleaseSlot frees the resources allocated to a specific slot.
-func (a *workloadAllocator) ReleaseSlot(slotID uuid.UUID) error {
+// ReleaseSlot frees the resources allocated to a workload on a specific slot.
+func (a *workloadAllocator) ReleaseSlot(slotID uuid.UUID, workID string) error {
 	// Find the slot.
 	slot, ok := a.slots.Load(slotID)
 	if !ok {
@@ -118,10 +122,11 @@ func (a *workloadAllocator) ReleaseSlot(slotID uuid.UUID) error {
 		Str("slot_id", slot.ID.String()).
 		Str("model_name", slot.ModelName().String()).
 		Uint64("total_memory", slot.Memory()).
+		Str("request_id", workID).
 		Msg("releasing slot")
 
 	// Release the slot.
-	slot.Release()
+	slot.Release(workID)
 
 	return nil
 }
@@ -248,15 +253,9 @@ func (a *workloadAllocator) WarmSlots(req *Workload) []*Slot {
 			return true
 		}
 
-		// If the slot is already running another job, skip
-		if slot.IsActive() {
-			l.Trace().Msg("skipping warm slot, already active")
-			return true
-		}
-
-		// If the slot is scheduled to run another job, skip
-		if slot.IsScheduled() {
-			l.Trace().Msg("skipping warm slot, already scheduled")
+		// If the slot is already running or scheduled to run as many jobs as it can, skip
+		if slot.IsFull() {
+			l.Trace().Msg("skipping warm slot, already full")
 			return true
 		}
 
@@ -309,25 +308,47 @@ func (a *workloadAllocator) DeadSlots(deadRunnerIDs []string) []*Slot {
 }
 
 // StartSlot marks scheduled work as in progress
-func (a *workloadAllocator) StartSlot(slotID uuid.UUID) error {
+func (a *workloadAllocator) StartSlot(slotID uuid.UUID, workID string) error {
 	// Find the slot.
 	slot, ok := a.slots.Load(slotID)
 	if !ok {
 		return fmt.Errorf("slot not found: %s", slotID.String())
 	}
 
-	// Log something when it first becomes active
-	if slot.IsScheduled() {
+	// Log something when the work first becomes active
+	if !slot.IsStarted(workID) {
 		log.Trace().
 			Str("runner_id", slot.RunnerID).
 			Str("slot_id", slot.ID.String()).
 			Str("model_name", slot.ModelName().String()).
 			Uint64("total_memory", slot.Memory()).
+			Str("request_id", workID).
 			Msg("starting slot")
 	}
 
 	// Always mark the slot as active
-	slot.Start()
+	slot.Start(workID)
+
+	return nil
+}
+
+// CancelSlot marks work on a slot as cancelled so that the runner can stop it
+func (a *workloadAllocator) CancelSlot(slotID uuid.UUID, workID string) error {
+	slot, ok := a.slots.Load(slotID)
+	if !ok {
+		return fmt.Errorf("slot not found: %s", slotID.String())
+	}
+
+	if !slot.Cancel(workID) {
+		return fmt.Errorf("work %s not found on slot: %s", workID, slotID.String())
+	}
+
+	log.Trace().
+		Str("runner_id", slot.RunnerID).
+		Str("slot_id", slot.ID.String()).
+		Str("model_name", slot.ModelName().String()).
+		Str("request_id", workID).
+		Msg("cancelling work on slot")
 
 	return nil
 }
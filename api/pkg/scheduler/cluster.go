@@ -1,6 +1,7 @@
 package scheduler
 
 import (
+	"slices"
 	"time"
 
 	"github.com/helixml/helix/api/pkg/types"
@@ -12,12 +13,18 @@ type Cluster interface {
 	UpdateRunner(props *types.RunnerState)
 	DeadRunnerIDs() []string
 	RunnerIDs() []string
+	SchedulableRunnerIDs() []string
 	TotalMemory(runnerID string) uint64
+	HasModelCached(runnerID string, modelName string) bool
+	HasArtifactCached(runnerID string, key string) bool
+	MaintenanceState(runnerID string) types.RunnerMaintenanceState
+	SetMaintenanceState(runnerID string, state types.RunnerMaintenanceState)
 }
 
 type cluster struct {
-	runners           *xsync.MapOf[string, *runner] // Maps a runner ID to its properties.
-	runnerTimeoutFunc TimeoutFunc                   // Function to check if runners have timed out.
+	runners           *xsync.MapOf[string, *runner]                      // Maps a runner ID to its properties.
+	runnerTimeoutFunc TimeoutFunc                                        // Function to check if runners have timed out.
+	maintenance       *xsync.MapOf[string, types.RunnerMaintenanceState] // Runners taken out of rotation. Survives runners restarting.
 }
 
 var _ Cluster = &cluster{}
@@ -29,6 +36,7 @@ func NewCluster(runnerTimeoutFunc TimeoutFunc) *cluster {
 	return &cluster{
 		runners:           xsync.NewMapOf[string, *runner](),
 		runnerTimeoutFunc: runnerTimeoutFunc,
+		maintenance:       xsync.NewMapOf[string, types.RunnerMaintenanceState](),
 	}
 }
 
@@ -71,6 +79,34 @@ func (c *cluster) RunnerIDs() []string {
 	return Keys(c.runners)
 }
 
+// SchedulableRunnerIDs returns the live runners that can accept new slots.
+func (c *cluster) SchedulableRunnerIDs() []string {
+	return Filter(c.RunnerIDs(), func(runnerID string) bool {
+		return c.MaintenanceState(runnerID) == types.RunnerMaintenanceStateActive
+	})
+}
+
+func (c *cluster) MaintenanceState(runnerID string) types.RunnerMaintenanceState {
+	state, ok := c.maintenance.Load(runnerID)
+	if !ok {
+		return types.RunnerMaintenanceStateActive
+	}
+	return state
+}
+
+func (c *cluster) SetMaintenanceState(runnerID string, state types.RunnerMaintenanceState) {
+	log.Info().
+		Str("runner_id", runnerID).
+		Str("maintenance_state", string(state)).
+		Msg("updating runner maintenance state")
+
+	if state == types.RunnerMaintenanceStateActive {
+		c.maintenance.Delete(runnerID)
+		return
+	}
+	c.maintenance.Store(runnerID, state)
+}
+
 func (c *cluster) TotalMemory(runnerID string) uint64 {
 	runner, ok := c.runners.Load(runnerID)
 	if !ok {
@@ -79,6 +115,25 @@ func (c *cluster) TotalMemory(runnerID string) uint64 {
 	return runner.TotalMemory()
 }
 
+// HasModelCached returns true if the runner reported the model's weights on disk.
+func (c *cluster) HasModelCached(runnerID string, modelName string) bool {
+	runner, ok := c.runners.Load(runnerID)
+	if !ok {
+		return false
+	}
+	return slices.Contains(runner.RunnerProperties.CachedModels, modelName)
+}
+
+// HasArtifactCached returns true if the runner reported the filestore path, e.g. a LoRA dir, in
+// its artifact cache.
+func (c *cluster) HasArtifactCached(runnerID string, key string) bool {
+	runner, ok := c.runners.Load(runnerID)
+	if !ok {
+		return false
+	}
+	return slices.Contains(runner.RunnerProperties.CachedArtifacts, key)
+}
+
 type runner struct {
 	RunnerProperties   *types.RunnerState
 	RunnerLastActivity time.Time
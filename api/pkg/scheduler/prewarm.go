@@ -0,0 +1,341 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/helixml/helix/api/pkg/config"
+	"github.com/helixml/helix/api/pkg/model"
+	"github.com/helixml/helix/api/pkg/types"
+	"github.com/rs/zerolog/log"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const (
+	// PrewarmRequestIDPrefix marks synthetic requests created by the prewarmer. They are excluded
+	// from demand history and their responses are not delivered to anyone.
+	PrewarmRequestIDPrefix = "prewarm-"
+	// PrewarmOwnerID is the owner of synthetic prewarm requests.
+	PrewarmOwnerID = "helix-prewarm"
+
+	// maxServiceTimeSamples bounds how many completed requests per model are used to estimate
+	// the average time a request occupies a slot.
+	maxServiceTimeSamples = 100
+)
+
+// IsPrewarmRequest returns true if the request ID belongs to a synthetic prewarm request.
+func IsPrewarmRequest(requestID string) bool {
+	return strings.HasPrefix(requestID, PrewarmRequestIDPrefix)
+}
+
+// prewarmer learns per-model demand from scheduling history and decides how many warm slots
+// each model should have. It doesn't touch the allocator itself, the scheduler does that.
+type prewarmer struct {
+	cfg      config.Prewarm
+	floors   map[model.Name]int
+	ceilings map[model.Name]int
+
+	mu           sync.Mutex
+	arrivals     map[model.Name][]time.Time     // When requests for each model were enqueued, within the demand window
+	serviceTimes map[model.Name][]time.Duration // How long recent requests occupied a slot
+	started      map[string]startedWork         // Request ID to when it was scheduled
+	upcoming     map[string]upcomingPrewarm     // Key (e.g. app ID) to a known future demand, such as a cron trigger
+}
+
+type startedWork struct {
+	modelName model.Name
+	at        time.Time
+}
+
+type upcomingPrewarm struct {
+	modelName model.Name
+	at        time.Time
+}
+
+func newPrewarmer(cfg config.Prewarm) (*prewarmer, error) {
+	if cfg.DemandWindow == 0 {
+		cfg.DemandWindow = 24 * time.Hour
+	}
+	if cfg.Interval == 0 {
+		cfg.Interval = 30 * time.Second
+	}
+	if cfg.CronLeadTime == 0 {
+		cfg.CronLeadTime = 2 * time.Minute
+	}
+	floors, err := parseModelCounts(cfg.Floors)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prewarm floors: %w", err)
+	}
+	ceilings, err := parseModelCounts(cfg.Ceilings)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prewarm ceilings: %w", err)
+	}
+	return &prewarmer{
+		cfg:          cfg,
+		floors:       floors,
+		ceilings:     ceilings,
+		arrivals:     make(map[model.Name][]time.Time),
+		serviceTimes: make(map[model.Name][]time.Duration),
+		started:      make(map[string]startedWork),
+		upcoming:     make(map[string]upcomingPrewarm),
+	}, nil
+}
+
+// parseModelCounts parses entries of the form "model=count". Model names can contain colons,
+// so the count is taken from the last equals sign.
+func parseModelCounts(entries []string) (map[model.Name]int, error) {
+	counts := make(map[model.Name]int, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		idx := strings.LastIndex(entry, "=")
+		if idx <= 0 {
+			return nil, fmt.Errorf("expected model=count, got %q", entry)
+		}
+		name := strings.TrimSpace(entry[:idx])
+		count, err := strconv.Atoi(strings.TrimSpace(entry[idx+1:]))
+		if err != nil || count < 0 {
+			return nil, fmt.Errorf("invalid count for model %s: %q", name, entry[idx+1:])
+		}
+		if _, err := model.GetModel(name); err != nil {
+			return nil, fmt.Errorf("unable to get model (%s): %v", name, err)
+		}
+		counts[model.Name(name)] = count
+	}
+	return counts, nil
+}
+
+// RecordArrival records that a workload was requested.
+func (p *prewarmer) RecordArrival(work *Workload, now time.Time) {
+	if IsPrewarmRequest(work.ID()) {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.arrivals[work.ModelName()] = append(p.trimArrivals(work.ModelName(), now), now)
+}
+
+// RecordScheduled records that a workload has been placed on a slot.
+func (p *prewarmer) RecordScheduled(work *Workload, now time.Time) {
+	if IsPrewarmRequest(work.ID()) {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.started[work.ID()] = startedWork{modelName: work.ModelName(), at: now}
+}
+
+// RecordReleased records that a workload has released its slot.
+func (p *prewarmer) RecordReleased(requestID string, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	started, ok := p.started[requestID]
+	if !ok {
+		return
+	}
+	delete(p.started, requestID)
+
+	samples := append(p.serviceTimes[started.modelName], now.Sub(started.at))
+	if len(samples) > maxServiceTimeSamples {
+		samples = samples[len(samples)-maxServiceTimeSamples:]
+	}
+	p.serviceTimes[started.modelName] = samples
+}
+
+// SchedulePrewarm records that a model will be needed at a known time. Scheduling again with the
+// same key replaces the previous entry.
+func (p *prewarmer) SchedulePrewarm(key string, modelName model.Name, at time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.upcoming[key] = upcomingPrewarm{modelName: modelName, at: at}
+}
+
+// DesiredSlots returns the number of warm slots each model should have right now.
+func (p *prewarmer) DesiredSlots(now time.Time) map[model.Name]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	desired := make(map[model.Name]int)
+
+	// Estimate concurrency from demand history using Little's law: average number of requests in
+	// flight is the arrival rate multiplied by the average time each request holds a slot.
+	for name := range p.arrivals {
+		arrivals := p.trimArrivals(name, now)
+		if len(arrivals) == 0 {
+			delete(p.arrivals, name)
+			continue
+		}
+		p.arrivals[name] = arrivals
+
+		rate := float64(len(arrivals)) / p.cfg.DemandWindow.Seconds()
+		concurrency := rate * averageDuration(p.serviceTimes[name]).Seconds()
+		// Any recent demand justifies keeping at least one slot warm
+		desired[name] = max(1, int(math.Ceil(concurrency)))
+	}
+
+	// Forget work that was never released, e.g. because its runner died
+	for id, started := range p.started {
+		if now.Sub(started.at) > p.cfg.DemandWindow {
+			delete(p.started, id)
+		}
+	}
+
+	// Known upcoming demand, e.g. cron triggers, needs a slot during the lead time
+	for key, upcoming := range p.upcoming {
+		if now.After(upcoming.at) {
+			delete(p.upcoming, key)
+			continue
+		}
+		if upcoming.at.Sub(now) <= p.cfg.CronLeadTime {
+			desired[upcoming.modelName] = max(desired[upcoming.modelName], 1)
+		}
+	}
+
+	// Apply ceilings first so that explicitly configured floors always win
+	for name, count := range desired {
+		ceiling, ok := p.ceilings[name]
+		if !ok {
+			ceiling = p.cfg.DefaultCeiling
+		}
+		desired[name] = min(count, ceiling)
+	}
+	for name, floor := range p.floors {
+		desired[name] = max(desired[name], floor)
+	}
+
+	for name, count := range desired {
+		if count == 0 {
+			delete(desired, name)
+		}
+	}
+
+	return desired
+}
+
+// trimArrivals drops arrivals that fall outside of the demand window. Must hold the lock.
+func (p *prewarmer) trimArrivals(name model.Name, now time.Time) []time.Time {
+	arrivals := p.arrivals[name]
+	cutoff := now.Add(-p.cfg.DemandWindow)
+	idx := 0
+	for idx < len(arrivals) && arrivals[idx].Before(cutoff) {
+		idx++
+	}
+	return arrivals[idx:]
+}
+
+func averageDuration(durations []time.Duration) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+	return total / time.Duration(len(durations))
+}
+
+// NewPrewarmWorkload creates a minimal LLM inference request that makes a runner load the model
+// into a slot. The response is discarded.
+func NewPrewarmWorkload(modelName model.Name) (*Workload, error) {
+	switch modelName.InferenceRuntime() {
+	case types.InferenceRuntimeOllama, types.InferenceRuntimeVLLM:
+	default:
+		return nil, fmt.Errorf("prewarming is only supported for ollama and vLLM models, got %s", modelName)
+	}
+	now := time.Now()
+	return NewLLMWorkload(&types.RunnerLLMInferenceRequest{
+		RequestID: PrewarmRequestIDPrefix + uuid.New().String(),
+		CreatedAt: now,
+		OwnerID:   PrewarmOwnerID,
+		Request: &openai.ChatCompletionRequest{
+			Model: modelName.String(),
+			Messages: []openai.ChatCompletionMessage{
+				{
+					Role:    openai.ChatMessageRoleUser,
+					Content: "hi",
+				},
+			},
+			MaxTokens: 1,
+		},
+	})
+}
+
+// prewarm runs in a goroutine and periodically tops up warm slots.
+func (s *scheduler) prewarm(ctx context.Context) {
+	ticker := time.NewTicker(s.prewarmer.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.prewarmOnce()
+		}
+	}
+}
+
+func (s *scheduler) prewarmOnce() {
+	desired := s.prewarmer.DesiredSlots(time.Now())
+	for name, count := range desired {
+		have := s.modelSlotCount(name) + s.queuedPrewarmCount(name)
+		for i := have; i < count; i++ {
+			work, err := NewPrewarmWorkload(name)
+			if err != nil {
+				log.Warn().Err(err).Str("model_name", name.String()).Msg("unable to create prewarm workload")
+				break
+			}
+			log.Debug().
+				Str("model_name", name.String()).
+				Int("desired_slots", count).
+				Int("current_slots", have).
+				Msg("prewarming model")
+			if err := s.enqueue(work); err != nil {
+				log.Warn().Err(err).Str("model_name", name.String()).Msg("unable to enqueue prewarm workload")
+				break
+			}
+		}
+	}
+}
+
+// modelSlotCount returns the number of slots on live runners that have the model loaded. Slots on
+// runners that are being drained don't count, so replacements are warmed up elsewhere.
+func (s *scheduler) modelSlotCount(name model.Name) int {
+	count := 0
+	for _, runnerID := range s.cluster.RunnerIDs() {
+		if s.isDraining(runnerID) {
+			continue
+		}
+		for _, slot := range s.allocator.RunnerSlots(runnerID) {
+			if slot.ModelName() == name && slot.LoraDir() == "" {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// queuedPrewarmCount returns the number of prewarm workloads for the model still waiting in the queue.
+func (s *scheduler) queuedPrewarmCount(name model.Name) int {
+	s.queueMtx.Lock()
+	defer s.queueMtx.Unlock()
+
+	count := 0
+	for _, work := range s.queue {
+		if IsPrewarmRequest(work.ID()) && work.ModelName() == name {
+			count++
+		}
+	}
+	return count
+}
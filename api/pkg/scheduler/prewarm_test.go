@@ -0,0 +1,124 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/helixml/helix/api/pkg/config"
+	"github.com/helixml/helix/api/pkg/model"
+	"github.com/helixml/helix/api/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testModelName = model.Name(testModelStr)
+
+func TestPrewarm_ParseModelCounts(t *testing.T) {
+	counts, err := parseModelCounts([]string{model.ModelOllamaLlama38b + "=2", " " + model.ModelOllamaPhi3 + " = 1 "})
+	require.NoError(t, err)
+	assert.Equal(t, 2, counts[model.Name(model.ModelOllamaLlama38b)])
+	assert.Equal(t, 1, counts[model.Name(model.ModelOllamaPhi3)])
+
+	_, err = parseModelCounts([]string{model.ModelOllamaLlama38b})
+	assert.Error(t, err)
+
+	_, err = parseModelCounts([]string{"not-a-model=1"})
+	assert.Error(t, err)
+}
+
+func TestPrewarm_DesiredSlotsFromDemand(t *testing.T) {
+	p, err := newPrewarmer(config.Prewarm{
+		DemandWindow:   time.Minute,
+		DefaultCeiling: 2,
+	})
+	require.NoError(t, err)
+
+	now := time.Now()
+	assert.Empty(t, p.DesiredSlots(now))
+
+	// A single request warrants a single warm slot
+	work := createPlacementWork("request-1", model.NewModel(testModelStr))
+	p.RecordArrival(work, now)
+	p.RecordScheduled(work, now)
+	p.RecordReleased(work.ID(), now.Add(30*time.Second))
+	assert.Equal(t, 1, p.DesiredSlots(now)[testModelName])
+
+	// Lots of long requests are capped by the ceiling
+	for i := 0; i < 10; i++ {
+		p.RecordArrival(work, now)
+	}
+	assert.Equal(t, 2, p.DesiredSlots(now)[testModelName])
+
+	// Demand outside of the window is forgotten
+	assert.Empty(t, p.DesiredSlots(now.Add(2*time.Minute)))
+}
+
+func TestPrewarm_FloorsAndCeilings(t *testing.T) {
+	p, err := newPrewarmer(config.Prewarm{
+		DefaultCeiling: 1,
+		Floors:         []string{model.ModelOllamaPhi3 + "=2"},
+		Ceilings:       []string{model.ModelOllamaLlama38b + "=0"},
+	})
+	require.NoError(t, err)
+
+	now := time.Now()
+	p.RecordArrival(createPlacementWork("request-1", model.NewModel(testModelStr)), now)
+
+	desired := p.DesiredSlots(now)
+	assert.Equal(t, map[model.Name]int{model.Name(model.ModelOllamaPhi3): 2}, desired)
+}
+
+func TestPrewarm_IgnoresPrewarmRequests(t *testing.T) {
+	p, err := newPrewarmer(config.Prewarm{DefaultCeiling: 1})
+	require.NoError(t, err)
+
+	work, err := NewPrewarmWorkload(testModelName)
+	require.NoError(t, err)
+	p.RecordArrival(work, time.Now())
+
+	assert.Empty(t, p.DesiredSlots(time.Now()))
+}
+
+func TestPrewarm_UpcomingCron(t *testing.T) {
+	p, err := newPrewarmer(config.Prewarm{
+		DefaultCeiling: 1,
+		CronLeadTime:   time.Minute,
+	})
+	require.NoError(t, err)
+
+	now := time.Now()
+	p.SchedulePrewarm("app-1", testModelName, now.Add(5*time.Minute))
+
+	// Too early
+	assert.Empty(t, p.DesiredSlots(now))
+
+	// Within the lead time
+	assert.Equal(t, 1, p.DesiredSlots(now.Add(4*time.Minute + 30*time.Second))[testModelName])
+
+	// After the trigger has fired
+	assert.Empty(t, p.DesiredSlots(now.Add(6*time.Minute)))
+}
+
+func TestScheduler_PrewarmOnce(t *testing.T) {
+	cfg, _ := config.LoadServerConfig()
+	cfg.Providers.Helix.Prewarm.Floors = []string{testModelStr + "=1"}
+	scheduler := newSchedulerWithoutGoroutines(&cfg, func(*Workload, error) {})
+
+	scheduler.UpdateRunner(&types.RunnerState{
+		ID:          "test-runner",
+		TotalMemory: testModel.GetMemoryRequirements(types.SessionModeInference) * 2,
+	})
+
+	// The floor should enqueue a single prewarm request
+	scheduler.prewarmOnce()
+	scheduler.prewarmOnce()
+	require.Len(t, scheduler.queue, 1)
+	assert.True(t, IsPrewarmRequest(scheduler.queue[0].ID()))
+
+	// Once scheduled, the slot counts towards the floor
+	scheduler.processQueueOnce()
+	assert.Len(t, scheduler.queue, 0)
+	scheduler.prewarmOnce()
+	assert.Len(t, scheduler.queue, 0)
+	assert.Equal(t, 1, scheduler.modelSlotCount(testModelName))
+}
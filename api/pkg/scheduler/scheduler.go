@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"slices"
 	"sync"
 	"time"
 
@@ -31,23 +32,47 @@ type Scheduler interface {
 	Enqueue(work *Workload) error
 	DashboardData() ([]*types.SessionSummary, error)
 	DashboardSlotsData() []types.DesiredSlots
+
+	// SchedulePrewarm warms a model ahead of known demand, e.g. a cron trigger. Calling it again
+	// with the same key replaces the previous time.
+	SchedulePrewarm(key string, modelName string, at time.Time) error
+
+	// Cancel stops a request. Queued work is dropped, scheduled work is marked as cancelled so
+	// that the runner can stop it and release the slot.
+	Cancel(requestID string) error
+
+	// CordonRunner stops new slots being created on a runner. Warm slots keep serving requests.
+	CordonRunner(runnerID string) error
+	// DrainRunner stops new work going to a runner and releases its slots as their work finishes.
+	DrainRunner(runnerID string) error
+	// UncordonRunner puts a cordoned or drained runner back into rotation.
+	UncordonRunner(runnerID string) error
+	// RunnerMaintenanceState returns whether a runner is in rotation.
+	RunnerMaintenanceState(runnerID string) types.RunnerMaintenanceState
 }
 
 // scheduler is a struct implementing the Scheduler interface.
 // It includes a workload allocator (allocator) and a thread-safe map (workStore) to store scheduled workloads.
 type scheduler struct {
-	allocator         WorkloadAllocator                  // Interface to allocate workload to different slots/models.
-	workStore         *xsync.MapOf[uuid.UUID, *Workload] // Map to store the work associated with a slot.
-	cluster           Cluster                            // Cluster to manage runner state.
+	allocator         WorkloadAllocator                    // Interface to allocate workload to different slots/models.
+	workStore         *xsync.MapOf[string, *scheduledWork] // Map of workload ID to the work and the slot it is scheduled on.
+	cluster           Cluster                              // Cluster to manage runner state.
 	placementStrategy SchedulingStrategyFunc
 	queue             []*Workload
 	queueMtx          *sync.Mutex
 	queueSize         int
 	onSchedulingErr   func(work *Workload, err error)
+	prewarmer         *prewarmer // Learns per-model demand and keeps models warm
 }
 
 var _ Scheduler = &scheduler{}
 
+// scheduledWork is a workload that has been allocated to a slot
+type scheduledWork struct {
+	slotID uuid.UUID
+	work   *Workload
+}
+
 // NewScheduler creates a new scheduler with a workload allocator.
 // This also starts a goroutine to process the queue in the background.
 //
@@ -67,6 +92,13 @@ func NewScheduler(ctx context.Context, cfg *config.ServerConfig, onSchedulingErr
 		scheduler.checkForDeadRunners(ctx)
 	}()
 
+	// Start a goroutine that keeps models warm ahead of demand
+	if cfg.Providers.Helix.Prewarm.Enabled {
+		go func() {
+			scheduler.prewarm(ctx)
+		}()
+	}
+
 	return scheduler
 }
 
@@ -84,6 +116,9 @@ func newSchedulerWithoutGoroutines(cfg *config.ServerConfig, onSchedulingErr fun
 		NewTimeoutFunc(modelTTL),
 		NewTimeoutFunc(slotTTL),
 	)
+	if cfg.Providers.Helix.MaxBatchSize > 1 {
+		allocator.maxBatchSize = cfg.Providers.Helix.MaxBatchSize
+	}
 	cluster := NewCluster(
 		NewTimeoutFunc(cfg.Providers.Helix.RunnerTTL),
 	)
@@ -109,15 +144,25 @@ func newSchedulerWithoutGoroutines(cfg *config.ServerConfig, onSchedulingErr fun
 	default:
 		log.Warn().Str("strategy", cfg.Providers.Helix.SchedulingStrategy).Msg("unknown scheduling strategy, defaulting to max utilization")
 	}
+	prewarmCfg := cfg.Providers.Helix.Prewarm
+	prewarmer, err := newPrewarmer(prewarmCfg)
+	if err != nil {
+		log.Error().Err(err).Msg("invalid prewarm configuration, ignoring per-model floors and ceilings")
+		prewarmCfg.Floors = nil
+		prewarmCfg.Ceilings = nil
+		prewarmer, _ = newPrewarmer(prewarmCfg)
+	}
+
 	scheduler := &scheduler{
 		allocator:         allocator,
 		cluster:           cluster,
-		workStore:         xsync.NewMapOf[uuid.UUID, *Workload](),
+		workStore:         xsync.NewMapOf[string, *scheduledWork](),
 		placementStrategy: schedStratFunc,
 		queue:             make([]*Workload, 0, queueSize),
 		queueMtx:          &sync.Mutex{},
 		queueSize:         queueSize,
 		onSchedulingErr:   onSchedulingErr,
+		prewarmer:         prewarmer,
 	}
 
 	return scheduler
@@ -149,8 +194,11 @@ func (s *scheduler) Schedule(work *Workload) (err error) {
 
 	var slot *Slot // Holds the slot where the work will be scheduled.
 
-	// Try to find warm slots, which are ready to take new work.
-	slots := s.allocator.WarmSlots(work)
+	// Try to find warm slots, which are ready to take new work. Runners that are draining don't
+	// take any more work, even on warm slots.
+	slots := Filter(s.allocator.WarmSlots(work), func(slot *Slot) bool {
+		return !s.isDraining(slot.RunnerID)
+	})
 
 	// If warm slots are available, select a random one.
 	if len(slots) > 0 {
@@ -192,7 +240,8 @@ func (s *scheduler) Schedule(work *Workload) (err error) {
 		return fmt.Errorf("slot is nil")
 	}
 
-	s.workStore.Store(slot.ID, work)
+	s.workStore.Store(work.ID(), &scheduledWork{slotID: slot.ID, work: work})
+	s.prewarmer.RecordScheduled(work, time.Now())
 
 	return nil
 }
@@ -208,14 +257,15 @@ func (s *scheduler) Release(id string) error {
 	}
 
 	// Release the resources allocated to the slot.
-	err := s.allocator.ReleaseSlot(slotID)
+	err := s.allocator.ReleaseSlot(slotID, id)
 	if err != nil {
 		// If there is an error during deallocation, return it.
 		return fmt.Errorf("problem deallocating: %w", err)
 	}
 
 	// Remove the work associated with the slot from the store.
-	s.workStore.Delete(slotID)
+	s.workStore.Delete(id)
+	s.prewarmer.RecordReleased(id, time.Now())
 
 	return nil
 }
@@ -227,11 +277,18 @@ func (s *scheduler) WorkForRunner(id string, workType WorkloadType, newWorkOnly
 	// Iterate through the slots assigned to the runner.
 	for _, slot := range s.allocator.RunnerSlots(id) {
 		// If the slot is ready for scheduling, retrieve the associated work.
-		if slot.IsScheduled() {
-			work, ok := s.workStore.Load(slot.ID)
+		if !slot.IsScheduled() {
+			continue
+		}
+		for _, workID := range slot.WorkIDs() {
+			if slot.IsStarted(workID) {
+				continue // Work already handed to the runner.
+			}
+			scheduled, ok := s.workStore.Load(workID)
 			if !ok {
 				continue // Work not owned by this scheduler, ignore it.
 			}
+			work := scheduled.work
 			// Check if request model type matches the model type of the slot.
 			if !newWorkOnly && model != "" && work.ModelName().String() != model {
 				continue // Work is not of the requested model type, ignore it.
@@ -242,7 +299,7 @@ func (s *scheduler) WorkForRunner(id string, workType WorkloadType, newWorkOnly
 			if newWorkOnly && !slot.IsNew() {
 				continue // Work is not new, ignore it.
 			}
-			slot.Start() // Mark the work in the slot as started.
+			slot.Start(workID) // Mark the work in the slot as started.
 			return work, nil
 		}
 	}
@@ -260,22 +317,29 @@ func (s *scheduler) UpdateRunner(props *types.RunnerState) {
 
 // find searches for the slot ID associated with a given workload ID.
 func (s *scheduler) find(id string) (uuid.UUID, bool) {
-	var result uuid.UUID
-	// Iterate through the workStore to find the matching workload ID.
-	s.workStore.Range(func(slotID uuid.UUID, w *Workload) bool {
-		if w.ID() == id {
-			result = slotID
-			return false
-		}
-		return true
-	})
-	return result, result != uuid.Nil
+	scheduled, ok := s.workStore.Load(id)
+	if !ok {
+		return uuid.Nil, false
+	}
+	return scheduled.slotID, true
 }
 
 // Enqueue adds a workload to the scheduler's queue.
 // TODO(Phil): Previous implementations saved the session queue in the database to requeue on
 // restarts. I don't think this is a particularly useful feature ATM. But may be in the future.
 func (s *scheduler) Enqueue(work *Workload) error {
+	err := s.enqueue(work)
+	if err != nil {
+		return err
+	}
+
+	// Only count new requests towards demand, not rescheduled or prewarm work
+	s.prewarmer.RecordArrival(work, time.Now())
+
+	return nil
+}
+
+func (s *scheduler) enqueue(work *Workload) error {
 	s.queueMtx.Lock()
 	defer s.queueMtx.Unlock()
 
@@ -363,6 +427,53 @@ func (s *scheduler) DashboardSlotsData() []types.DesiredSlots {
 	return desiredSlots
 }
 
+// SchedulePrewarm records that a model will be needed at a known time so that the prewarmer can
+// load it ahead of the request.
+func (s *scheduler) SchedulePrewarm(key string, modelName string, at time.Time) error {
+	if _, err := model.GetModel(modelName); err != nil {
+		return fmt.Errorf("unable to get model (%s): %v", modelName, err)
+	}
+	s.prewarmer.SchedulePrewarm(key, model.Name(modelName), at)
+	return nil
+}
+
+// Cancel stops a request. If it is still queued it is removed from the queue. If it has already
+// been scheduled, the work is marked as cancelled and the runner is told to stop it through the
+// slots API. The slot is released when the runner responds.
+func (s *scheduler) Cancel(requestID string) error {
+	if s.dequeue(requestID) {
+		log.Debug().Str("request_id", requestID).Msg("cancelled queued work")
+		return nil
+	}
+
+	slotID, ok := s.find(requestID)
+	if !ok {
+		// If the request is not found, it has probably already been released
+		return nil
+	}
+
+	err := s.allocator.CancelSlot(slotID, requestID)
+	if err != nil {
+		return fmt.Errorf("problem cancelling work: %w", err)
+	}
+
+	return nil
+}
+
+// dequeue removes work from the queue, returning true if it was found
+func (s *scheduler) dequeue(requestID string) bool {
+	s.queueMtx.Lock()
+	defer s.queueMtx.Unlock()
+
+	for i, w := range s.queue {
+		if w.ID() == requestID {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
 func (s *scheduler) Begin(requestID string) error {
 	// Find the slot ID associated with the request.
 	slotID, ok := s.find(requestID)
@@ -372,7 +483,7 @@ func (s *scheduler) Begin(requestID string) error {
 	}
 
 	// Set the slot as started
-	err := s.allocator.StartSlot(slotID)
+	err := s.allocator.StartSlot(slotID, requestID)
 	if err != nil {
 		return fmt.Errorf("problem starting slot: %w", err)
 	}
@@ -380,17 +491,95 @@ func (s *scheduler) Begin(requestID string) error {
 	return nil
 }
 
+func (s *scheduler) CordonRunner(runnerID string) error {
+	if !s.isKnownRunner(runnerID) {
+		return fmt.Errorf("runner not found: %s", runnerID)
+	}
+	if s.isDraining(runnerID) {
+		return fmt.Errorf("runner %s is %s, uncordon it first", runnerID, s.cluster.MaintenanceState(runnerID))
+	}
+	s.cluster.SetMaintenanceState(runnerID, types.RunnerMaintenanceStateCordoned)
+	return nil
+}
+
+func (s *scheduler) DrainRunner(runnerID string) error {
+	if !s.isKnownRunner(runnerID) {
+		return fmt.Errorf("runner not found: %s", runnerID)
+	}
+	if s.cluster.MaintenanceState(runnerID) == types.RunnerMaintenanceStateDrained {
+		return nil
+	}
+	s.cluster.SetMaintenanceState(runnerID, types.RunnerMaintenanceStateDraining)
+	return nil
+}
+
+func (s *scheduler) UncordonRunner(runnerID string) error {
+	if !s.isKnownRunner(runnerID) {
+		return fmt.Errorf("runner not found: %s", runnerID)
+	}
+	s.cluster.SetMaintenanceState(runnerID, types.RunnerMaintenanceStateActive)
+	return nil
+}
+
+func (s *scheduler) RunnerMaintenanceState(runnerID string) types.RunnerMaintenanceState {
+	return s.cluster.MaintenanceState(runnerID)
+}
+
+// isKnownRunner returns true if the runner is connected or has been taken out of rotation.
+func (s *scheduler) isKnownRunner(runnerID string) bool {
+	return slices.Contains(s.cluster.RunnerIDs(), runnerID) ||
+		s.cluster.MaintenanceState(runnerID) != types.RunnerMaintenanceStateActive
+}
+
+func (s *scheduler) isDraining(runnerID string) bool {
+	state := s.cluster.MaintenanceState(runnerID)
+	return state == types.RunnerMaintenanceStateDraining || state == types.RunnerMaintenanceStateDrained
+}
+
+// drainRunnersOnce releases idle slots on draining runners. Once a runner has no slots left it is
+// marked as drained so that it can be safely stopped.
+func (s *scheduler) drainRunnersOnce() {
+	for _, runnerID := range s.cluster.RunnerIDs() {
+		if s.cluster.MaintenanceState(runnerID) != types.RunnerMaintenanceStateDraining {
+			continue
+		}
+		remaining := 0
+		for _, slot := range s.allocator.RunnerSlots(runnerID) {
+			if len(slot.WorkIDs()) > 0 {
+				remaining++
+				continue
+			}
+			log.Debug().
+				Str("runner_id", runnerID).
+				Str("slot_id", slot.ID.String()).
+				Msg("releasing idle slot on draining runner")
+			s.allocator.DeleteSlot(slot.ID)
+		}
+		if remaining == 0 {
+			s.cluster.SetMaintenanceState(runnerID, types.RunnerMaintenanceStateDrained)
+		}
+	}
+}
+
 func (s *scheduler) SlotsForRunner(runnerID string) []types.DesiredRunnerSlot {
 	slots := s.allocator.RunnerSlots(runnerID)
 	desiredRunnerSlots := make([]types.DesiredRunnerSlot, 0, len(slots))
 	for _, slot := range slots {
 		attr := types.DesiredRunnerSlotAttributes{
-			Mode:  string(slot.Mode()),
-			Model: string(slot.ModelName()),
+			Mode:                string(slot.Mode()),
+			Model:               string(slot.ModelName()),
+			CancelledRequestIDs: slot.CancelledWorkIDs(),
 		}
-		slotWork, ok := s.workStore.Load(slot.ID)
-		if ok {
-			attr.Workload = slotWork.ToRunnerWorkload()
+		for _, workID := range slot.WorkIDs() {
+			scheduled, ok := s.workStore.Load(workID)
+			if !ok {
+				continue
+			}
+			// Workload is the oldest work on the slot, Workloads is everything that can be batched
+			if attr.Workload == nil {
+				attr.Workload = scheduled.work.ToRunnerWorkload()
+			}
+			attr.Workloads = append(attr.Workloads, scheduled.work.ToRunnerWorkload())
 		}
 		desiredRunnerSlot := types.DesiredRunnerSlot{
 			ID:         slot.ID,
@@ -453,6 +642,7 @@ func (s *scheduler) checkForDeadRunners(ctx context.Context) {
 			return
 		default:
 			s.checkForDeadRunnersOnce()
+			s.drainRunnersOnce()
 
 			// Sleep for a while to allow others to access the scheduler
 			time.Sleep(10 * time.Millisecond)
@@ -465,25 +655,35 @@ func (s *scheduler) checkForDeadRunnersOnce() {
 	for _, id := range deadRunnerIDs {
 		deadSlots := s.allocator.DeadSlots([]string{id})
 		for _, dead := range deadSlots {
-			// Load and delete that work from the store
-			work, ok := s.workStore.LoadAndDelete(dead.ID)
-			if !ok {
-				continue // No work to reschedule
-			}
-
-			// Attempt to reschedule the work.
-			log.Trace().
-				Str("runner_id", id).
-				Str("slot_id", dead.ID.String()).
-				Msg("rescheduling work for dead slot")
-			err := s.Enqueue(work)
-			if err != nil {
-				log.Error().
-					Err(err).
+			cancelled := dead.CancelledWorkIDs()
+			for _, workID := range dead.WorkIDs() {
+				// Load and delete that work from the store
+				scheduled, ok := s.workStore.LoadAndDelete(workID)
+				if !ok {
+					continue // No work to reschedule
+				}
+
+				// Nobody is waiting for cancelled work, so don't reschedule it
+				if slices.Contains(cancelled, workID) {
+					continue
+				}
+
+				// Attempt to reschedule the work.
+				log.Trace().
 					Str("runner_id", id).
 					Str("slot_id", dead.ID.String()).
-					Msg("failed to reschedule work for dead slot")
-				continue
+					Str("request_id", workID).
+					Msg("rescheduling work for dead slot")
+				err := s.enqueue(scheduled.work)
+				if err != nil {
+					log.Error().
+						Err(err).
+						Str("runner_id", id).
+						Str("slot_id", dead.ID.String()).
+						Str("request_id", workID).
+						Msg("failed to reschedule work for dead slot")
+					continue
+				}
 			}
 		}
 	}
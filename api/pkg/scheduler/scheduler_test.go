@@ -571,6 +571,155 @@ func TestScheduler_ChangingRunnerName(t *testing.T) {
 	assert.False(t, ok)
 }
 
+func TestScheduler_BatchesLLMRequests(t *testing.T) {
+	config, _ := config.LoadServerConfig()
+	config.Providers.Helix.MaxBatchSize = 2
+	scheduler := newSchedulerWithoutGoroutines(&config, nil)
+	m, _ := model.GetModel(model.ModelOllamaLlama38b)
+	scheduler.UpdateRunner(&types.RunnerState{
+		ID:          "test-runner",
+		TotalMemory: m.GetMemoryRequirements(types.SessionModeInference),
+	})
+
+	// Both requests share the single slot
+	err := scheduleTestLLMWorkload(scheduler, "test-request-1", model.ModelOllamaLlama38b)
+	assert.NoError(t, err)
+	err = scheduleTestLLMWorkload(scheduler, "test-request-2", model.ModelOllamaLlama38b)
+	assert.NoError(t, err)
+	err = scheduleTestLLMWorkload(scheduler, "test-request-3", model.ModelOllamaLlama38b)
+	assert.ErrorContains(t, err, "full")
+
+	slots := scheduler.SlotsForRunner("test-runner")
+	assert.Len(t, slots, 1)
+	assert.Equal(t, "test-request-1", slots[0].Attributes.Workload.LLMInferenceRequest.RequestID)
+	assert.Len(t, slots[0].Attributes.Workloads, 2)
+
+	// Releasing one request makes room for another
+	err = scheduler.Release("test-request-1")
+	assert.NoError(t, err)
+	err = scheduleTestLLMWorkload(scheduler, "test-request-3", model.ModelOllamaLlama38b)
+	assert.NoError(t, err)
+
+	slots = scheduler.SlotsForRunner("test-runner")
+	assert.Len(t, slots, 1)
+	assert.Equal(t, "test-request-2", slots[0].Attributes.Workload.LLMInferenceRequest.RequestID)
+}
+
+func TestScheduler_CancelQueuedRequest(t *testing.T) {
+	config, _ := config.LoadServerConfig()
+	scheduler := newSchedulerWithoutGoroutines(&config, nil)
+
+	err := enqueueTestLLMWorkload(scheduler, "test-request-1", model.ModelOllamaLlama38b)
+	assert.NoError(t, err)
+	assert.Len(t, scheduler.queue, 1)
+
+	err = scheduler.Cancel("test-request-1")
+	assert.NoError(t, err)
+	assert.Len(t, scheduler.queue, 0)
+}
+
+func TestScheduler_CancelScheduledRequest(t *testing.T) {
+	config, _ := config.LoadServerConfig()
+	scheduler := newSchedulerWithoutGoroutines(&config, nil)
+	m, _ := model.GetModel(model.ModelOllamaLlama38b)
+	scheduler.UpdateRunner(&types.RunnerState{
+		ID:          "test-runner",
+		TotalMemory: m.GetMemoryRequirements(types.SessionModeInference),
+	})
+
+	err := scheduleTestLLMWorkload(scheduler, "test-request-1", model.ModelOllamaLlama38b)
+	assert.NoError(t, err)
+
+	err = scheduler.Cancel("test-request-1")
+	assert.NoError(t, err)
+
+	// The runner is told to stop the request, but the slot is held until it acknowledges
+	slots := scheduler.SlotsForRunner("test-runner")
+	assert.Len(t, slots, 1)
+	assert.Equal(t, []string{"test-request-1"}, slots[0].Attributes.CancelledRequestIDs)
+
+	err = scheduler.Release("test-request-1")
+	assert.NoError(t, err)
+	slots = scheduler.SlotsForRunner("test-runner")
+	assert.Len(t, slots, 1)
+	assert.Nil(t, slots[0].Attributes.Workload)
+	assert.Empty(t, slots[0].Attributes.CancelledRequestIDs)
+
+	// Cancelling a request that has already finished is a no-op
+	err = scheduler.Cancel("test-request-1")
+	assert.NoError(t, err)
+}
+
+func TestScheduler_CordonRunner(t *testing.T) {
+	config, _ := config.LoadServerConfig()
+	scheduler := newSchedulerWithoutGoroutines(&config, nil)
+	m, _ := model.GetModel(model.ModelOllamaLlama38b)
+	scheduler.UpdateRunner(&types.RunnerState{
+		ID:          "test-runner",
+		TotalMemory: m.GetMemoryRequirements(types.SessionModeInference) * 2,
+	})
+
+	err := scheduler.CordonRunner("unknown-runner")
+	assert.Error(t, err)
+
+	// Warm up a slot before cordoning
+	err = scheduleTestLLMWorkload(scheduler, "test-request-1", model.ModelOllamaLlama38b)
+	assert.NoError(t, err)
+	err = scheduler.Release("test-request-1")
+	assert.NoError(t, err)
+
+	err = scheduler.CordonRunner("test-runner")
+	assert.NoError(t, err)
+	assert.Equal(t, types.RunnerMaintenanceStateCordoned, scheduler.RunnerMaintenanceState("test-runner"))
+
+	// The warm slot keeps serving, but no new slots are created
+	err = scheduleTestLLMWorkload(scheduler, "test-request-2", model.ModelOllamaLlama38b)
+	assert.NoError(t, err)
+	err = scheduleTestLLMWorkload(scheduler, "test-request-3", model.ModelOllamaLlama38b)
+	assert.ErrorContains(t, err, "no runners available")
+
+	err = scheduler.UncordonRunner("test-runner")
+	assert.NoError(t, err)
+	err = scheduleTestLLMWorkload(scheduler, "test-request-3", model.ModelOllamaLlama38b)
+	assert.NoError(t, err)
+}
+
+func TestScheduler_DrainRunner(t *testing.T) {
+	config, _ := config.LoadServerConfig()
+	scheduler := newSchedulerWithoutGoroutines(&config, nil)
+	m, _ := model.GetModel(model.ModelOllamaLlama38b)
+	scheduler.UpdateRunner(&types.RunnerState{
+		ID:          "test-runner",
+		TotalMemory: m.GetMemoryRequirements(types.SessionModeInference) * 2,
+	})
+
+	err := scheduleTestLLMWorkload(scheduler, "test-request-1", model.ModelOllamaLlama38b)
+	assert.NoError(t, err)
+	err = scheduleTestLLMWorkload(scheduler, "test-request-2", model.ModelOllamaLlama38b)
+	assert.NoError(t, err)
+	err = scheduler.Release("test-request-2")
+	assert.NoError(t, err)
+
+	err = scheduler.DrainRunner("test-runner")
+	assert.NoError(t, err)
+
+	// The idle slot is released, the busy one is kept until its work finishes
+	scheduler.drainRunnersOnce()
+	assert.Len(t, scheduler.allocator.RunnerSlots("test-runner"), 1)
+	assert.Equal(t, types.RunnerMaintenanceStateDraining, scheduler.RunnerMaintenanceState("test-runner"))
+
+	// No new work is accepted, even though there is room
+	err = scheduleTestLLMWorkload(scheduler, "test-request-3", model.ModelOllamaLlama38b)
+	assert.Error(t, err)
+
+	err = scheduler.Release("test-request-1")
+	assert.NoError(t, err)
+	scheduler.drainRunnersOnce()
+	assert.Len(t, scheduler.allocator.RunnerSlots("test-runner"), 0)
+	assert.Equal(t, types.RunnerMaintenanceStateDrained, scheduler.RunnerMaintenanceState("test-runner"))
+	assert.Len(t, scheduler.SlotsForRunner("test-runner"), 0)
+}
+
 func enqueueTestLLMWorkload(scheduler Scheduler, name string, model string) error {
 	req := &types.RunnerLLMInferenceRequest{
 		RequestID: name,
@@ -11,18 +11,25 @@ import (
 )
 
 type Slot struct {
-	ID               uuid.UUID // An ID representing this unique model on a runner
-	RunnerID         string    // The runner that this slot is assigned to
-	work             *Workload // The work that is currently assigned to this slot
-	lastActivityTime time.Time // Private because I don't want people misinterpreting this
-	isActive         bool      // Private because I don't want people misinterpreting this
-	isScheduled      bool      // Private because I don't want people misinterpreting this
+	ID               uuid.UUID   // An ID representing this unique model on a runner
+	RunnerID         string      // The runner that this slot is assigned to
+	work             *Workload   // The work that caused this slot to be created
+	works            []*slotWork // The work currently assigned to this slot, in scheduling order
+	capacity         int         // How many workloads can share this slot at once
+	lastActivityTime time.Time   // Private because I don't want people misinterpreting this
 	mu               *sync.RWMutex
 	isStaleFunc      TimeoutFunc
 	isErrorFunc      TimeoutFunc
 	isNew            bool
 }
 
+// slotWork records the state of a single workload assigned to a slot
+type slotWork struct {
+	id        string
+	started   bool
+	cancelled bool
+}
+
 // NewSlot creates a new slot with the given runnerID and work
 // staleTimeout is a function that determines if a slot is stale
 // errorTimeout is a function that determines if a slot has errored
@@ -31,9 +38,9 @@ func NewSlot(runnerID string, work *Workload, staleTimeout TimeoutFunc, errorTim
 		ID:               uuid.New(),
 		RunnerID:         runnerID,
 		work:             work,
+		works:            []*slotWork{},
+		capacity:         1,
 		lastActivityTime: time.Now(),
-		isActive:         false,
-		isScheduled:      false,
 		isNew:            true, // Is new when slot is created
 		mu:               &sync.RWMutex{},
 		isStaleFunc:      staleTimeout,
@@ -46,26 +53,19 @@ func (s *Slot) IsStale() bool {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	// First if the work is active, or is scheduled...
-	if s.isActive || s.isScheduled {
+	// First if there is work active or scheduled...
+	if len(s.works) > 0 {
 		// ... then check if the slot has timed out due to an error
 		if s.isErrorFunc(s.RunnerID, s.lastActivityTime) {
 			log.Warn().Str("runner_id", s.RunnerID).Str("slot_id", s.ID.String()).Msg("slot has timed out due to an unknown error, releasing slot")
 			s.mu.RUnlock()
-			s.Release()
+			s.ReleaseAll()
 			s.mu.RLock()
 			// If it has errored, then it is stale
 			return true
 		}
-	}
-
-	// If work is active, not stale
-	if s.isActive {
-		return false
-	}
 
-	// If work is scheduled, it is not stale
-	if s.isScheduled {
+		// If work is active or scheduled, it is not stale
 		return false
 	}
 
@@ -78,47 +78,140 @@ func (s *Slot) IsActive() bool {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	return s.isActive
+	for _, w := range s.works {
+		if w.started {
+			return true
+		}
+	}
+	return false
 }
 
-// Schedule sets a slot ready for scheduling
-func (s *Slot) Schedule() {
+// True if the slot can't take any more work
+func (s *Slot) IsFull() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.works) >= s.capacity
+}
+
+// SetCapacity sets how many workloads can share this slot at once
+func (s *Slot) SetCapacity(capacity int) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.isScheduled = true
+	s.capacity = max(1, capacity)
+}
+
+// Schedule assigns work to the slot
+func (s *Slot) Schedule(workID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.works = append(s.works, &slotWork{id: workID})
 	s.lastActivityTime = time.Now()
 }
 
-// True if work is scheduled on this slot
+// True if work is scheduled on this slot but hasn't started yet
 func (s *Slot) IsScheduled() bool {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	return s.isScheduled
+	for _, w := range s.works {
+		if !w.started {
+			return true
+		}
+	}
+	return false
+}
+
+// Release removes the work from the slot
+func (s *Slot) Release(workID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.works = Filter(s.works, func(w *slotWork) bool {
+		return w.id != workID
+	})
+	s.lastActivityTime = time.Now()
 }
 
-// Sets a slot as no longer active
-func (s *Slot) Release() {
+// ReleaseAll removes all work from the slot
+func (s *Slot) ReleaseAll() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.isActive = false
-	s.isScheduled = false
+	s.works = []*slotWork{}
 	s.lastActivityTime = time.Now()
 }
 
 // Marks the work as started
-func (s *Slot) Start() {
+func (s *Slot) Start(workID string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.isScheduled = false
+	for _, w := range s.works {
+		if w.id == workID {
+			w.started = true
+		}
+	}
 	s.lastActivityTime = time.Now()
-	s.isActive = true
 	s.isNew = false
 }
 
+// True if the work has been started on this slot
+func (s *Slot) IsStarted(workID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, w := range s.works {
+		if w.id == workID {
+			return w.started
+		}
+	}
+	return false
+}
+
+// Cancel marks the work as cancelled. The work stays on the slot until the runner acknowledges
+// the cancellation, so that the slot isn't reused while the runner is still generating.
+func (s *Slot) Cancel(workID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, w := range s.works {
+		if w.id == workID {
+			w.cancelled = true
+			return true
+		}
+	}
+	return false
+}
+
+// WorkIDs returns the IDs of the work assigned to this slot, in scheduling order
+func (s *Slot) WorkIDs() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make([]string, 0, len(s.works))
+	for _, w := range s.works {
+		ids = append(ids, w.id)
+	}
+	return ids
+}
+
+// CancelledWorkIDs returns the IDs of the work that has been cancelled but not yet released
+func (s *Slot) CancelledWorkIDs() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make([]string, 0)
+	for _, w := range s.works {
+		if w.cancelled {
+			ids = append(ids, w.id)
+		}
+	}
+	return ids
+}
+
 func (s *Slot) Mode() types.SessionMode {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
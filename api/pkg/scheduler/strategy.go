@@ -25,6 +25,8 @@ func MaxUtilizationStrategy(c Cluster, a WorkloadAllocator, req *Workload) (stri
 
 	// Prioritize runners to minimize utilization.
 	prioritizedRunners := runnersByMaxUtilisation(c, a)
+	// Runners that don't need to download anything come first.
+	prioritizedRunners = preferCachedRunners(c, req, prioritizedRunners)
 
 	// Max available memory across all runners.
 	maxMemory := maxMemory(c)
@@ -46,6 +48,8 @@ func MaxSpreadStrategy(c Cluster, a WorkloadAllocator, req *Workload) (string, e
 
 	// Prioritize runners to minimize utilization.
 	prioritizedRunners := Reverse(runnersByMaxUtilisation(c, a))
+	// Runners that don't need to download anything come first.
+	prioritizedRunners = preferCachedRunners(c, req, prioritizedRunners)
 
 	// Max available memory across all runners.
 	maxMemory := maxMemory(c)
@@ -97,7 +101,7 @@ func memoryUsed(slots []*Slot) uint64 {
 // runnersByMaxUtilisation sorts runners by their available memory in descending order,
 // prioritizing runners with the least free memory for better utilization.
 func runnersByMaxUtilisation(c Cluster, a WorkloadAllocator) []string {
-	runners := c.RunnerIDs()
+	runners := c.SchedulableRunnerIDs()
 	// Sort runners based on available memory, from least available to most available.
 	slices.SortFunc(
 		runners,
@@ -122,6 +126,26 @@ func runnersByMaxUtilisation(c Cluster, a WorkloadAllocator) []string {
 	return runners
 }
 
+// preferCachedRunners stably moves runners that already have the workload's LoRA dir or model
+// weights on disk to the front. A cached LoRA dir matters most since it is downloaded from the
+// control plane.
+func preferCachedRunners(c Cluster, req *Workload, runners []string) []string {
+	score := func(runnerID string) int {
+		s := 0
+		if loraDir := req.LoraDir(); loraDir != "" && c.HasArtifactCached(runnerID, loraDir) {
+			s += 2
+		}
+		if c.HasModelCached(runnerID, req.ModelName().String()) {
+			s++
+		}
+		return s
+	}
+	slices.SortStableFunc(runners, func(i, j string) int {
+		return score(j) - score(i)
+	})
+	return runners
+}
+
 // availableMemory calculates the available memory for a specific runner by subtracting
 // the memory usage of all non-stale slots assigned to that runner.
 func availableMemory(c Cluster, a WorkloadAllocator, runnerID string) (uint64, error) {
@@ -183,7 +207,7 @@ func firstRunnerThatCanFit(c Cluster, a WorkloadAllocator, modelRequirement uint
 // maxMemory finds the largest amount of possible GPU memory across all runners
 func maxMemory(c Cluster) uint64 {
 	maxAvailable := float64(0)
-	for _, runner := range c.RunnerIDs() {
+	for _, runner := range c.SchedulableRunnerIDs() {
 		maxAvailable = math.Max(float64(c.TotalMemory(runner)), maxAvailable)
 	}
 	return uint64(maxAvailable)
@@ -76,3 +76,34 @@ func createPlacementWork(name string, model model.Name) *Workload {
 	work, _ := NewLLMWorkload(req)
 	return work
 }
+
+func TestPlacement_PrefersCachedRunner(t *testing.T) {
+	c := NewCluster(dummyTimeout)
+	c.UpdateRunner(&types.RunnerState{
+		ID:          "test-runner-1",
+		TotalMemory: 2 * testModel.GetMemoryRequirements(types.SessionModeInference),
+	})
+	c.UpdateRunner(&types.RunnerState{
+		ID:           "test-runner-2",
+		TotalMemory:  2 * testModel.GetMemoryRequirements(types.SessionModeInference),
+		CachedModels: []string{testModelStr},
+	})
+	a := NewWorkloadAllocator(dummyTimeout, dummyTimeout)
+	req := createPlacementWork("test", model.NewModel(testModelStr))
+
+	// Runner 1 has more free memory, but runner 2 already has the weights
+	_, err := a.AllocateNewSlot("test-runner-2", createPlacementWork("other", model.NewModel(testModelStr)))
+	assert.NoError(t, err)
+
+	runnerID, err := MaxSpreadStrategy(c, a, req)
+	assert.NoError(t, err)
+	assert.Equal(t, "test-runner-2", runnerID)
+
+	// Unless it can't fit
+	_, err = a.AllocateNewSlot("test-runner-2", createPlacementWork("another", model.NewModel(testModelStr)))
+	assert.NoError(t, err)
+
+	runnerID, err = MaxSpreadStrategy(c, a, req)
+	assert.NoError(t, err)
+	assert.Equal(t, "test-runner-1", runnerID)
+}
@@ -0,0 +1,264 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/gorilla/mux"
+
+	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/system"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+var appEnvironmentNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// listAppEnvironments godoc
+// @Summary List app environments
+// @Description List the app's environments and the revisions they serve, secret values are not returned
+// @Tags    apps
+
+// @Success 200 {array} types.AppEnvironment
+// @Param id path string true "App ID"
+// @Router /api/v1/apps/{id}/environments [get]
+// @Security BearerAuth
+func (s *HelixAPIServer) listAppEnvironments(_ http.ResponseWriter, r *http.Request) ([]*types.AppEnvironment, *system.HTTPError) {
+	app, httpErr := s.getOwnedApp(r)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	environments, err := s.Store.ListAppEnvironments(r.Context(), app.ID)
+	if err != nil {
+		return nil, system.NewHTTPError500(err.Error())
+	}
+
+	for _, environment := range environments {
+		redactAppEnvironmentSecrets(environment)
+	}
+
+	return environments, nil
+}
+
+// updateAppEnvironment godoc
+// @Summary Create or update an app environment
+// @Description Pin the environment to a revision, zero follows the latest revision, and set its secrets. Secrets without a value keep their current value.
+// @Tags    apps
+
+// @Success 200 {object} types.AppEnvironment
+// @Param request body types.AppEnvironment true "Revision and secrets of the environment"
+// @Param id path string true "App ID"
+// @Param environment path string true "Environment name"
+// @Router /api/v1/apps/{id}/environments/{environment} [put]
+// @Security BearerAuth
+func (s *HelixAPIServer) updateAppEnvironment(_ http.ResponseWriter, r *http.Request) (*types.AppEnvironment, *system.HTTPError) {
+	user := getRequestUser(r)
+
+	app, httpErr := s.getOwnedApp(r)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	var update types.AppEnvironment
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		return nil, system.NewHTTPError400(fmt.Sprintf("failed to decode request body, error: %s", err))
+	}
+
+	if update.Revision > 0 {
+		if _, httpErr := s.loadAppRevision(r, app.ID, update.Revision); httpErr != nil {
+			return nil, httpErr
+		}
+	}
+
+	environment, httpErr := s.getOrNewAppEnvironment(r, app.ID, mux.Vars(r)["environment"])
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	secrets := types.AppEnvironmentSecrets{}
+	for name, value := range update.Secrets {
+		if value == "" {
+			value = environment.Secrets[name]
+		}
+		secrets[name] = value
+	}
+
+	environment.Revision = update.Revision
+	environment.Secrets = secrets
+	environment.UpdatedBy = user.ID
+
+	return s.saveAppEnvironment(r, environment)
+}
+
+// promoteAppEnvironment godoc
+// @Summary Promote a revision to an app environment
+// @Description Copy the revision that another environment serves, by default the one before it in dev, staging, prod, or a given revision to the environment
+// @Tags    apps
+
+// @Success 200 {object} types.AppEnvironment
+// @Param request body types.PromoteAppEnvironmentRequest true "Environment or revision to promote"
+// @Param id path string true "App ID"
+// @Param environment path string true "Environment to promote to"
+// @Router /api/v1/apps/{id}/environments/{environment}/promote [post]
+// @Security BearerAuth
+func (s *HelixAPIServer) promoteAppEnvironment(_ http.ResponseWriter, r *http.Request) (*types.AppEnvironment, *system.HTTPError) {
+	user := getRequestUser(r)
+
+	app, httpErr := s.getOwnedApp(r)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	var req types.PromoteAppEnvironmentRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return nil, system.NewHTTPError400(fmt.Sprintf("failed to decode request body, error: %s", err))
+		}
+	}
+
+	name := mux.Vars(r)["environment"]
+
+	if req.Revision == 0 && req.From == "" {
+		req.From = previousAppEnvironment(name)
+		if req.From == "" {
+			return nil, system.NewHTTPError400(fmt.Sprintf("the environment to promote to %s from is required", name))
+		}
+	}
+
+	if req.From == name {
+		return nil, system.NewHTTPError400("can't promote an environment to itself")
+	}
+
+	revision := req.Revision
+	if revision == 0 {
+		var httpErr *system.HTTPError
+		revision, httpErr = s.appEnvironmentRevision(r, app, req.From)
+		if httpErr != nil {
+			return nil, httpErr
+		}
+	} else if _, httpErr := s.loadAppRevision(r, app.ID, revision); httpErr != nil {
+		return nil, httpErr
+	}
+
+	environment, httpErr := s.getOrNewAppEnvironment(r, app.ID, name)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	environment.Revision = revision
+	environment.PromotedFrom = req.From
+	environment.UpdatedBy = user.ID
+
+	return s.saveAppEnvironment(r, environment)
+}
+
+// deleteAppEnvironment godoc
+// @Summary Delete an app environment
+// @Description Delete the environment, its API keys will be served the latest revision
+// @Tags    apps
+
+// @Success 200
+// @Param id path string true "App ID"
+// @Param environment path string true "Environment name"
+// @Router /api/v1/apps/{id}/environments/{environment} [delete]
+// @Security BearerAuth
+func (s *HelixAPIServer) deleteAppEnvironment(_ http.ResponseWriter, r *http.Request) (*types.AppEnvironment, *system.HTTPError) {
+	app, httpErr := s.getOwnedApp(r)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	name := mux.Vars(r)["environment"]
+
+	environment, err := s.Store.GetAppEnvironment(r.Context(), app.ID, name)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, system.NewHTTPError404(store.ErrNotFound.Error())
+		}
+		return nil, system.NewHTTPError500(err.Error())
+	}
+
+	if err := s.Store.DeleteAppEnvironment(r.Context(), app.ID, name); err != nil {
+		return nil, system.NewHTTPError500(err.Error())
+	}
+
+	redactAppEnvironmentSecrets(environment)
+
+	return environment, nil
+}
+
+// appEnvironmentRevision returns the revision that the environment serves, the dev environment
+// doesn't need to exist and environments that aren't pinned serve the latest revision
+func (s *HelixAPIServer) appEnvironmentRevision(r *http.Request, app *types.App, name string) (int, *system.HTTPError) {
+	environment, err := s.Store.GetAppEnvironment(r.Context(), app.ID, name)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) && name == types.AppEnvironmentDev {
+			return app.Revision, nil
+		}
+		if errors.Is(err, store.ErrNotFound) {
+			return 0, system.NewHTTPError404(fmt.Sprintf("environment %s not found", name))
+		}
+		return 0, system.NewHTTPError500(err.Error())
+	}
+
+	if environment.Revision == 0 {
+		return app.Revision, nil
+	}
+
+	return environment.Revision, nil
+}
+
+func (s *HelixAPIServer) getOrNewAppEnvironment(r *http.Request, appID, name string) (*types.AppEnvironment, *system.HTTPError) {
+	if !appEnvironmentNameRegex.MatchString(name) {
+		return nil, system.NewHTTPError400("environment names can only have lowercase letters, numbers and dashes")
+	}
+
+	environment, err := s.Store.GetAppEnvironment(r.Context(), appID, name)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return &types.AppEnvironment{AppID: appID, Name: name}, nil
+		}
+		return nil, system.NewHTTPError500(err.Error())
+	}
+
+	return environment, nil
+}
+
+func (s *HelixAPIServer) saveAppEnvironment(r *http.Request, environment *types.AppEnvironment) (*types.AppEnvironment, *system.HTTPError) {
+	var (
+		saved *types.AppEnvironment
+		err   error
+	)
+
+	if environment.ID == "" {
+		saved, err = s.Store.CreateAppEnvironment(r.Context(), environment)
+	} else {
+		saved, err = s.Store.UpdateAppEnvironment(r.Context(), environment)
+	}
+	if err != nil {
+		return nil, system.NewHTTPError500(err.Error())
+	}
+
+	redactAppEnvironmentSecrets(saved)
+
+	return saved, nil
+}
+
+// previousAppEnvironment returns the environment before this one in dev, staging, prod
+func previousAppEnvironment(name string) string {
+	for i, environment := range types.AppEnvironmentPipeline {
+		if environment == name && i > 0 {
+			return types.AppEnvironmentPipeline[i-1]
+		}
+	}
+	return ""
+}
+
+func redactAppEnvironmentSecrets(environment *types.AppEnvironment) {
+	for name := range environment.Secrets {
+		environment.Secrets[name] = ""
+	}
+}
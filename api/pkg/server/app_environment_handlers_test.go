@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+func TestAppEnvironments_PromoteFromPrevious(t *testing.T) {
+	ts, st := newAppManagementTestServer(t, types.User{ID: "user_1"})
+
+	st.EXPECT().GetApp(gomock.Any(), "app_1").Return(&types.App{ID: "app_1", Owner: "user_1", Revision: 7}, nil)
+	st.EXPECT().GetAppEnvironment(gomock.Any(), "app_1", types.AppEnvironmentStaging).Return(&types.AppEnvironment{
+		ID: "appenv_1", AppID: "app_1", Name: types.AppEnvironmentStaging, Revision: 4,
+	}, nil)
+	st.EXPECT().GetAppEnvironment(gomock.Any(), "app_1", types.AppEnvironmentProduction).Return(&types.AppEnvironment{
+		ID: "appenv_2", AppID: "app_1", Name: types.AppEnvironmentProduction, Revision: 2,
+		Secrets: types.AppEnvironmentSecrets{"API_KEY": "prod-key"},
+	}, nil)
+	st.EXPECT().UpdateAppEnvironment(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, environment *types.AppEnvironment) (*types.AppEnvironment, error) {
+		assert.Equal(t, 4, environment.Revision)
+		assert.Equal(t, types.AppEnvironmentStaging, environment.PromotedFrom)
+		assert.Equal(t, "prod-key", environment.Secrets["API_KEY"])
+		return environment, nil
+	})
+
+	resp, err := http.Post(ts.URL+"/api/v1/apps/app_1/environments/prod/promote", "application/json", nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var environment types.AppEnvironment
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&environment))
+	assert.Equal(t, 4, environment.Revision)
+	// Secret values aren't returned
+	assert.Equal(t, "", environment.Secrets["API_KEY"])
+}
+
+func TestAppEnvironments_PromoteFromDev(t *testing.T) {
+	ts, st := newAppManagementTestServer(t, types.User{ID: "user_1"})
+
+	st.EXPECT().GetApp(gomock.Any(), "app_1").Return(&types.App{ID: "app_1", Owner: "user_1", Revision: 7}, nil)
+	st.EXPECT().GetAppEnvironment(gomock.Any(), "app_1", types.AppEnvironmentDev).Return(nil, store.ErrNotFound)
+	st.EXPECT().GetAppEnvironment(gomock.Any(), "app_1", types.AppEnvironmentStaging).Return(nil, store.ErrNotFound)
+	st.EXPECT().CreateAppEnvironment(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, environment *types.AppEnvironment) (*types.AppEnvironment, error) {
+		assert.Equal(t, "app_1", environment.AppID)
+		assert.Equal(t, types.AppEnvironmentStaging, environment.Name)
+		assert.Equal(t, 7, environment.Revision)
+		assert.Equal(t, "user_1", environment.UpdatedBy)
+		return environment, nil
+	})
+
+	resp, err := http.Post(ts.URL+"/api/v1/apps/app_1/environments/staging/promote", "application/json", nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestAppEnvironments_UpdateKeepsSecrets(t *testing.T) {
+	ts, st := newAppManagementTestServer(t, types.User{ID: "user_1"})
+
+	st.EXPECT().GetApp(gomock.Any(), "app_1").Return(&types.App{ID: "app_1", Owner: "user_1", Revision: 7}, nil)
+	st.EXPECT().GetAppEnvironment(gomock.Any(), "app_1", types.AppEnvironmentProduction).Return(&types.AppEnvironment{
+		ID: "appenv_2", AppID: "app_1", Name: types.AppEnvironmentProduction, Revision: 2,
+		Secrets: types.AppEnvironmentSecrets{"API_KEY": "prod-key", "OLD": "old"},
+	}, nil)
+	st.EXPECT().UpdateAppEnvironment(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, environment *types.AppEnvironment) (*types.AppEnvironment, error) {
+		assert.Equal(t, types.AppEnvironmentSecrets{"API_KEY": "prod-key", "NEW": "new"}, environment.Secrets)
+		return environment, nil
+	})
+
+	req, err := http.NewRequest(http.MethodPut, ts.URL+"/api/v1/apps/app_1/environments/prod",
+		strings.NewReader(`{"secrets": {"API_KEY": "", "NEW": "new"}}`))
+	require.NoError(t, err)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestAppEnvironments_InvalidName(t *testing.T) {
+	ts, st := newAppManagementTestServer(t, types.User{ID: "user_1"})
+
+	st.EXPECT().GetApp(gomock.Any(), "app_1").Return(&types.App{ID: "app_1", Owner: "user_1", Revision: 7}, nil)
+
+	req, err := http.NewRequest(http.MethodPut, ts.URL+"/api/v1/apps/app_1/environments/Prod_1", strings.NewReader(`{}`))
+	require.NoError(t, err)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
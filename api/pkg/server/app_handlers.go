@@ -8,14 +8,24 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"slices"
 	"time"
 
 	"github.com/helixml/helix/api/pkg/apps"
 	"github.com/helixml/helix/api/pkg/controller/knowledge"
+	"github.com/helixml/helix/api/pkg/data"
+	oai "github.com/helixml/helix/api/pkg/openai"
 	"github.com/helixml/helix/api/pkg/store"
 	"github.com/helixml/helix/api/pkg/system"
 	"github.com/helixml/helix/api/pkg/tools"
+	"github.com/helixml/helix/api/pkg/trigger/discord"
+	"github.com/helixml/helix/api/pkg/trigger/email"
+	"github.com/helixml/helix/api/pkg/trigger/event"
+	"github.com/helixml/helix/api/pkg/trigger/sink"
+	"github.com/helixml/helix/api/pkg/trigger/slack"
+	"github.com/helixml/helix/api/pkg/trigger/webhook"
 	"github.com/helixml/helix/api/pkg/types"
+	"github.com/helixml/helix/api/pkg/workflow"
 	"github.com/robfig/cron/v3"
 	"github.com/rs/zerolog/log"
 )
@@ -128,7 +138,50 @@ func (s *HelixAPIServer) createApp(_ http.ResponseWriter, r *http.Request) (*typ
 	// if this is a github app - then initialise it
 	switch app.AppSource {
 	case types.AppSourceHelix:
-		err = s.validateTriggers(app.Config.Helix.Triggers)
+		err = s.validateTriggers(ctx, app.Owner, app.Config.Helix.Triggers)
+		if err != nil {
+			return nil, system.NewHTTPError400(err.Error())
+		}
+
+		err = data.ValidateExperiments(&app.Config.Helix)
+		if err != nil {
+			return nil, system.NewHTTPError400(err.Error())
+		}
+
+		err = data.ValidateResponseSchemas(&app.Config.Helix)
+		if err != nil {
+			return nil, system.NewHTTPError400(err.Error())
+		}
+
+		err = data.ValidateContexts(&app.Config.Helix)
+		if err != nil {
+			return nil, system.NewHTTPError400(err.Error())
+		}
+
+		err = data.ValidateGuardrails(&app.Config.Helix)
+		if err != nil {
+			return nil, system.NewHTTPError400(err.Error())
+		}
+
+		err = slack.Validate(&app.Config.Helix)
+		if err != nil {
+			return nil, system.NewHTTPError400(err.Error())
+		}
+
+		if httpErr := s.validateTriggerBindings(ctx, &app); httpErr != nil {
+			return nil, httpErr
+		}
+
+		if httpErr := s.validateWebhookSecrets(ctx, app.Owner, &app); httpErr != nil {
+			return nil, httpErr
+		}
+
+		err = discord.Validate(&app.Config.Helix)
+		if err != nil {
+			return nil, system.NewHTTPError400(err.Error())
+		}
+
+		err = workflow.Validate(&app.Config.Helix)
 		if err != nil {
 			return nil, system.NewHTTPError400(err.Error())
 		}
@@ -226,7 +279,12 @@ func (s *HelixAPIServer) validateKnowledge(k *types.AssistantKnowledge) error {
 	return knowledge.Validate(s.Cfg, k)
 }
 
-func (s *HelixAPIServer) validateTriggers(triggers []types.Trigger) error {
+func (s *HelixAPIServer) validateTriggers(ctx context.Context, owner string, triggers []types.Trigger) error {
+	recipients, err := s.sinkRecipients(ctx, owner, triggers)
+	if err != nil {
+		return err
+	}
+
 	// If it's cron, check that it runs not more than once every 90 seconds
 	for _, trigger := range triggers {
 		if trigger.Cron != nil && trigger.Cron.Schedule != "" {
@@ -240,8 +298,74 @@ func (s *HelixAPIServer) validateTriggers(triggers []types.Trigger) error {
 			if secondRun.Sub(nextRun) < 90*time.Second {
 				return fmt.Errorf("cron trigger must not run more than once per 90 seconds")
 			}
+
+			if err := sink.Validate(trigger.Cron.Sinks, recipients); err != nil {
+				return fmt.Errorf("invalid cron trigger: %w", err)
+			}
+		}
+	}
+	if err := email.Validate(s.Cfg, triggers); err != nil {
+		return err
+	}
+	if err := event.Validate(triggers, recipients); err != nil {
+		return err
+	}
+	return webhook.Validate(triggers, recipients)
+}
+
+// sinkRecipients returns who the email sinks of the owner's triggers can send to, the owner is
+// only looked up when there are email sinks
+func (s *HelixAPIServer) sinkRecipients(ctx context.Context, owner string, triggers []types.Trigger) (sink.Recipients, error) {
+	hasEmail := func(sinks []types.TriggerSink) bool {
+		return slices.ContainsFunc(sinks, func(sink types.TriggerSink) bool { return sink.Email != nil })
+	}
+
+	for _, trigger := range triggers {
+		switch {
+		case trigger.Cron != nil && hasEmail(trigger.Cron.Sinks),
+			trigger.Webhook != nil && hasEmail(trigger.Webhook.Sinks),
+			trigger.Event != nil && hasEmail(trigger.Event.Sinks):
+			return sink.LoadRecipients(ctx, s.Cfg, s.Controller.Options.Authenticator, owner)
 		}
 	}
+
+	return sink.Recipients{}, nil
+}
+
+// validateTriggerBindings rejects triggers for Slack workspaces and inbox addresses that other
+// apps are already bound to
+func (s *HelixAPIServer) validateTriggerBindings(ctx context.Context, app *types.App) *system.HTTPError {
+	apps, err := s.Store.ListApps(ctx, &store.ListAppsQuery{})
+	if err != nil {
+		return system.NewHTTPError500(err.Error())
+	}
+
+	if err := slack.ValidateWorkspace(app, apps); err != nil {
+		return system.NewHTTPError409(err.Error())
+	}
+	if err := email.ValidateAddresses(app, apps); err != nil {
+		return system.NewHTTPError409(err.Error())
+	}
+
+	return nil
+}
+
+// validateWebhookSecrets rejects webhook triggers whose secrets the owner doesn't have, the
+// webhook would be signed with the secret's name otherwise
+func (s *HelixAPIServer) validateWebhookSecrets(ctx context.Context, owner string, app *types.App) *system.HTTPError {
+	if !slices.ContainsFunc(app.Config.Helix.Triggers, func(t types.Trigger) bool { return t.Webhook != nil }) {
+		return nil
+	}
+
+	evaluated, err := s.Controller.EvaluateSecrets(ctx, &types.User{ID: owner}, app)
+	if err != nil {
+		return system.NewHTTPError500(err.Error())
+	}
+
+	if err := webhook.ValidateSecrets(evaluated.Config.Helix.Triggers); err != nil {
+		return system.NewHTTPError400(err.Error())
+	}
+
 	return nil
 }
 
@@ -406,12 +530,56 @@ func (s *HelixAPIServer) updateApp(_ http.ResponseWriter, r *http.Request) (*typ
 		}
 	}
 
-	err = s.validateTriggers(update.Config.Helix.Triggers)
+	err = s.validateTriggers(r.Context(), existing.Owner, update.Config.Helix.Triggers)
+	if err != nil {
+		return nil, system.NewHTTPError400(err.Error())
+	}
+
+	err = data.ValidateExperiments(&update.Config.Helix)
+	if err != nil {
+		return nil, system.NewHTTPError400(err.Error())
+	}
+
+	err = data.ValidateResponseSchemas(&update.Config.Helix)
+	if err != nil {
+		return nil, system.NewHTTPError400(err.Error())
+	}
+
+	err = data.ValidateContexts(&update.Config.Helix)
+	if err != nil {
+		return nil, system.NewHTTPError400(err.Error())
+	}
+
+	err = data.ValidateGuardrails(&update.Config.Helix)
+	if err != nil {
+		return nil, system.NewHTTPError400(err.Error())
+	}
+
+	err = slack.Validate(&update.Config.Helix)
+	if err != nil {
+		return nil, system.NewHTTPError400(err.Error())
+	}
+
+	if httpErr := s.validateTriggerBindings(r.Context(), &update); httpErr != nil {
+		return nil, httpErr
+	}
+
+	if httpErr := s.validateWebhookSecrets(r.Context(), existing.Owner, &update); httpErr != nil {
+		return nil, httpErr
+	}
+
+	err = discord.Validate(&update.Config.Helix)
+	if err != nil {
+		return nil, system.NewHTTPError400(err.Error())
+	}
+
+	err = workflow.Validate(&update.Config.Helix)
 	if err != nil {
 		return nil, system.NewHTTPError400(err.Error())
 	}
 
 	update.Updated = time.Now()
+	update.UpdatedBy = user.ID
 
 	// Validate and default tools
 	for idx := range update.Config.Helix.Assistants {
@@ -531,6 +699,7 @@ func (s *HelixAPIServer) updateGithubApp(_ http.ResponseWriter, r *http.Request)
 	existing.Config.AllowedDomains = appUpdate.AllowedDomains
 	existing.Shared = appUpdate.Shared
 	existing.Global = appUpdate.Global
+	existing.UpdatedBy = user.ID
 
 	// Updating the app
 	updated, err := s.Store.UpdateApp(r.Context(), existing)
@@ -713,7 +882,7 @@ func (s *HelixAPIServer) appRunScript(w http.ResponseWriter, r *http.Request) (*
 
 // appRunAPIAction godoc
 // @Summary Run an API action with parameters
-// @Description Run an API action with parameters
+// @Description Run an API action with parameters. Actions that need approval return the approval ID instead of running until the user approves them.
 // @Tags    apps
 
 // @Success 200 {object} types.RunAPIActionResponse
@@ -724,7 +893,7 @@ func (s *HelixAPIServer) appRunAPIAction(_ http.ResponseWriter, r *http.Request)
 	user := getRequestUser(r)
 	id := getID(r)
 
-	app, err := s.Store.GetAppWithTools(r.Context(), id)
+	app, err := s.Controller.LoadApp(r.Context(), user, id)
 	if err != nil {
 		if errors.Is(err, store.ErrNotFound) {
 			return nil, system.NewHTTPError404("app not found")
@@ -736,6 +905,12 @@ func (s *HelixAPIServer) appRunAPIAction(_ http.ResponseWriter, r *http.Request)
 		return nil, system.NewHTTPError403("you do not have permission to run this action")
 	}
 
+	// Tool credentials can reference the user's secrets
+	app, err = s.Controller.EvaluateSecrets(r.Context(), user, app)
+	if err != nil {
+		return nil, system.NewHTTPError500(err.Error())
+	}
+
 	// load the body of the request
 	var req types.RunAPIActionRequest
 	err = json.NewDecoder(r.Body).Decode(&req)
@@ -760,8 +935,19 @@ func (s *HelixAPIServer) appRunAPIAction(_ http.ResponseWriter, r *http.Request)
 
 	req.Tool = tool
 
-	response, err := s.Controller.ToolsPlanner.RunAPIActionWithParameters(r.Context(), &req)
+	// Tools that act on behalf of users need to know who is calling, and the app they linked their
+	// account for
+	ctx := oai.SetContextValues(r.Context(), &oai.ContextValues{
+		OwnerID: user.ID,
+	})
+	ctx = oai.SetContextAppID(ctx, app.ID)
+
+	// Actions that need approval return the approval ID rather than running
+	response, err := s.Controller.RunAPIActionWithParameters(ctx, user, app.ID, "0", &req)
 	if err != nil {
+		if errors.Is(err, tools.ErrActionDenied) {
+			return nil, system.NewHTTPError403(err.Error())
+		}
 		return nil, system.NewHTTPError500(err.Error())
 	}
 
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/helixml/helix/api/pkg/apps"
+	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/system"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+// listAppRevisions godoc
+// @Summary List app revisions
+// @Description List the stored revisions of the app's config, newest first
+// @Tags    apps
+
+// @Success 200 {array} types.AppRevision
+// @Param id path string true "App ID"
+// @Router /api/v1/apps/{id}/revisions [get]
+// @Security BearerAuth
+func (s *HelixAPIServer) listAppRevisions(_ http.ResponseWriter, r *http.Request) ([]*types.AppRevision, *system.HTTPError) {
+	app, httpErr := s.getOwnedApp(r)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	revisions, err := s.Store.ListAppRevisions(r.Context(), app.ID)
+	if err != nil {
+		return nil, system.NewHTTPError500(err.Error())
+	}
+
+	return revisions, nil
+}
+
+// getAppRevision godoc
+// @Summary Get an app revision
+// @Description Get the app's config as it was at the revision
+// @Tags    apps
+
+// @Success 200 {object} types.AppRevision
+// @Param id path string true "App ID"
+// @Param revision path int true "Revision number"
+// @Router /api/v1/apps/{id}/revisions/{revision} [get]
+// @Security BearerAuth
+func (s *HelixAPIServer) getAppRevision(_ http.ResponseWriter, r *http.Request) (*types.AppRevision, *system.HTTPError) {
+	app, httpErr := s.getOwnedApp(r)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	revision, err := strconv.Atoi(mux.Vars(r)["revision"])
+	if err != nil {
+		return nil, system.NewHTTPError400("invalid revision")
+	}
+
+	return s.loadAppRevision(r, app.ID, revision)
+}
+
+// diffAppRevisions godoc
+// @Summary Diff app revisions
+// @Description List the changes to the app's helix config between two revisions
+// @Tags    apps
+
+// @Success 200 {object} types.AppRevisionDiff
+// @Param id path string true "App ID"
+// @Param from query int true "Revision to compare from"
+// @Param to query int false "Revision to compare to, defaults to the current revision"
+// @Router /api/v1/apps/{id}/revisions/diff [get]
+// @Security BearerAuth
+func (s *HelixAPIServer) diffAppRevisions(_ http.ResponseWriter, r *http.Request) (*types.AppRevisionDiff, *system.HTTPError) {
+	app, httpErr := s.getOwnedApp(r)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	fromRevision, err := strconv.Atoi(r.URL.Query().Get("from"))
+	if err != nil {
+		return nil, system.NewHTTPError400("from must be a revision number")
+	}
+
+	toRevision := app.Revision
+	if to := r.URL.Query().Get("to"); to != "" {
+		toRevision, err = strconv.Atoi(to)
+		if err != nil {
+			return nil, system.NewHTTPError400("to must be a revision number")
+		}
+	}
+
+	from, httpErr := s.loadAppRevision(r, app.ID, fromRevision)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	to, httpErr := s.loadAppRevision(r, app.ID, toRevision)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	changes, err := apps.DiffConfigs(&from.Config.Helix, &to.Config.Helix)
+	if err != nil {
+		return nil, system.NewHTTPError500(err.Error())
+	}
+
+	return &types.AppRevisionDiff{
+		AppID:   app.ID,
+		From:    from.Revision,
+		To:      to.Revision,
+		Changes: changes,
+	}, nil
+}
+
+// rollbackApp godoc
+// @Summary Roll back an app
+// @Description Restore the app's helix config from a revision. The rollback is stored as a new revision, secrets, allowed domains and the GitHub connection are kept as they are.
+// @Tags    apps
+
+// @Success 200 {object} types.App
+// @Param id path string true "App ID"
+// @Param revision path int true "Revision to restore"
+// @Router /api/v1/apps/{id}/revisions/{revision}/rollback [post]
+// @Security BearerAuth
+func (s *HelixAPIServer) rollbackApp(_ http.ResponseWriter, r *http.Request) (*types.App, *system.HTTPError) {
+	user := getRequestUser(r)
+
+	app, httpErr := s.getOwnedApp(r)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	if app.Global && !isAdmin(user) {
+		return nil, system.NewHTTPError403("only admin users can update global apps")
+	}
+
+	revision, err := strconv.Atoi(mux.Vars(r)["revision"])
+	if err != nil {
+		return nil, system.NewHTTPError400("invalid revision")
+	}
+
+	appRevision, httpErr := s.loadAppRevision(r, app.ID, revision)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	app.Config.Helix = appRevision.Config.Helix
+	app.UpdatedBy = user.ID
+
+	updated, err := s.Store.UpdateApp(r.Context(), app)
+	if err != nil {
+		return nil, system.NewHTTPError500(err.Error())
+	}
+
+	err = s.ensureKnowledge(r.Context(), updated)
+	if err != nil {
+		return nil, system.NewHTTPError500(err.Error())
+	}
+
+	return updated, nil
+}
+
+// getOwnedApp loads the app for changes that only its owner and admins can see, such as
+// previous versions of the config
+func (s *HelixAPIServer) getOwnedApp(r *http.Request) (*types.App, *system.HTTPError) {
+	user := getRequestUser(r)
+
+	app, err := s.Store.GetApp(r.Context(), getID(r))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, system.NewHTTPError404(store.ErrNotFound.Error())
+		}
+		return nil, system.NewHTTPError500(err.Error())
+	}
+
+	if app.Owner != user.ID && !isAdmin(user) {
+		return nil, system.NewHTTPError403("you do not have permission to manage this app")
+	}
+
+	return app, nil
+}
+
+func (s *HelixAPIServer) loadAppRevision(r *http.Request, appID string, revision int) (*types.AppRevision, *system.HTTPError) {
+	appRevision, err := s.Store.GetAppRevision(r.Context(), appID, revision)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, system.NewHTTPError404("revision " + strconv.Itoa(revision) + " not found")
+		}
+		return nil, system.NewHTTPError500(err.Error())
+	}
+
+	return appRevision, nil
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/helixml/helix/api/pkg/config"
+	"github.com/helixml/helix/api/pkg/controller"
+	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/system"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+func newAppManagementTestServer(t *testing.T, user types.User) (*httptest.Server, *store.MockStore) {
+	ctrl := gomock.NewController(t)
+	st := store.NewMockStore(ctrl)
+
+	apiServer := &HelixAPIServer{
+		Cfg:   &config.ServerConfig{},
+		Store: st,
+		Controller: &controller.Controller{
+			Options: controller.Options{Store: st},
+		},
+	}
+
+	router := mux.NewRouter()
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(setRequestUser(r.Context(), user)))
+		})
+	})
+	router.HandleFunc("/api/v1/apps/{id}/revisions", system.Wrapper(apiServer.listAppRevisions)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/apps/{id}/revisions/diff", system.Wrapper(apiServer.diffAppRevisions)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/apps/{id}/revisions/{revision:[0-9]+}/rollback", system.Wrapper(apiServer.rollbackApp)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/apps/{id}/environments/{environment}", system.Wrapper(apiServer.updateAppEnvironment)).Methods(http.MethodPut)
+	router.HandleFunc("/api/v1/apps/{id}/environments/{environment}/promote", system.Wrapper(apiServer.promoteAppEnvironment)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/apps/{id}/experiments/{experiment}/report", system.Wrapper(apiServer.getExperimentReport)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/apps/{id}/trigger-runs", system.Wrapper(apiServer.listTriggerRuns)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/apps/{id}/trigger-runs/{run_id}", system.Wrapper(apiServer.getTriggerRun)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/apps/{id}/webhooks", system.Wrapper(apiServer.listAppWebhooks)).Methods(http.MethodGet)
+
+	ts := httptest.NewServer(router)
+	t.Cleanup(ts.Close)
+
+	return ts, st
+}
+
+func revisionTestConfig(prompt string) types.AppConfig {
+	return types.AppConfig{
+		Helix: types.AppHelixConfig{
+			Name:       "support-bot",
+			Assistants: []types.AssistantConfig{{Name: "helper", SystemPrompt: prompt}},
+		},
+	}
+}
+
+func TestAppRevisions_Diff(t *testing.T) {
+	ts, st := newAppManagementTestServer(t, types.User{ID: "user_1"})
+
+	st.EXPECT().GetApp(gomock.Any(), "app_1").Return(&types.App{
+		ID: "app_1", Owner: "user_1", Revision: 2, Config: revisionTestConfig("Be rude."),
+	}, nil)
+	st.EXPECT().GetAppRevision(gomock.Any(), "app_1", 1).Return(&types.AppRevision{
+		AppID: "app_1", Revision: 1, Config: revisionTestConfig("Be polite."),
+	}, nil)
+	st.EXPECT().GetAppRevision(gomock.Any(), "app_1", 2).Return(&types.AppRevision{
+		AppID: "app_1", Revision: 2, Config: revisionTestConfig("Be rude."),
+	}, nil)
+
+	resp, err := http.Get(ts.URL + "/api/v1/apps/app_1/revisions/diff?from=1")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var diff types.AppRevisionDiff
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&diff))
+	assert.Equal(t, 1, diff.From)
+	assert.Equal(t, 2, diff.To)
+	require.Len(t, diff.Changes, 1)
+	assert.Equal(t, "assistants[0].system_prompt", diff.Changes[0].Path)
+	assert.Equal(t, "Be polite.", diff.Changes[0].From)
+	assert.Equal(t, "Be rude.", diff.Changes[0].To)
+}
+
+func TestAppRevisions_Rollback(t *testing.T) {
+	ts, st := newAppManagementTestServer(t, types.User{ID: "user_1"})
+
+	st.EXPECT().GetApp(gomock.Any(), "app_1").Return(&types.App{
+		ID: "app_1", Owner: "user_1", Revision: 2, Config: revisionTestConfig("Be rude."),
+	}, nil)
+	st.EXPECT().GetAppRevision(gomock.Any(), "app_1", 1).Return(&types.AppRevision{
+		AppID: "app_1", Revision: 1, Config: revisionTestConfig("Be polite."),
+	}, nil)
+	st.EXPECT().UpdateApp(gomock.Any(), gomock.Any()).DoAndReturn(func(_ interface{}, app *types.App) (*types.App, error) {
+		assert.Equal(t, "Be polite.", app.Config.Helix.Assistants[0].SystemPrompt)
+		assert.Equal(t, "user_1", app.UpdatedBy)
+		app.Revision = 3
+		return app, nil
+	})
+	st.EXPECT().ListKnowledge(gomock.Any(), &store.ListKnowledgeQuery{AppID: "app_1"}).Return(nil, nil)
+
+	resp, err := http.Post(ts.URL+"/api/v1/apps/app_1/revisions/1/rollback", "application/json", nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var app types.App
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&app))
+	assert.Equal(t, 3, app.Revision)
+}
+
+func TestAppRevisions_OnlyOwner(t *testing.T) {
+	ts, st := newAppManagementTestServer(t, types.User{ID: "user_2"})
+
+	st.EXPECT().GetApp(gomock.Any(), "app_1").Return(&types.App{
+		ID: "app_1", Owner: "user_1", Shared: true, Revision: 2,
+	}, nil)
+
+	resp, err := http.Get(ts.URL + "/api/v1/apps/app_1/revisions")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
@@ -15,10 +15,14 @@ import (
 
 var (
 	// Allowed paths for app API keys. Currently we support
-	// only OpenAI compatible chat completions API
+	// the OpenAI compatible chat completions API and the
+	// app's Model Context Protocol server
 	AppAPIKeyPaths = map[string]bool{
 		"/v1/chat/completions":  true,
 		"/api/v1/sessions/chat": true,
+		"/api/v1/mcp":           true,
+		"/api/v1/mcp/sse":       true,
+		"/api/v1/mcp/message":   true,
 	}
 )
 
@@ -104,6 +108,7 @@ func (auth *authMiddleware) getUserFromToken(ctx context.Context, token string)
 		user.Admin = auth.isUserAdmin(user.ID)
 		if apiKey.AppID != nil && apiKey.AppID.Valid {
 			user.AppID = apiKey.AppID.String
+			user.AppEnvironment = apiKey.Environment
 		}
 
 		return user, nil
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/helixml/helix/api/pkg/system"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+// getExperimentReport godoc
+// @Summary Get an experiment report
+// @Description Compare the variants of the app's experiment by latency, token usage, user feedback and eval scores
+// @Tags    apps
+
+// @Success 200 {array} types.ExperimentVariantReport
+// @Param id path string true "App ID"
+// @Param experiment path string true "Experiment name"
+// @Router /api/v1/apps/{id}/experiments/{experiment}/report [get]
+// @Security BearerAuth
+func (s *HelixAPIServer) getExperimentReport(_ http.ResponseWriter, r *http.Request) ([]*types.ExperimentVariantReport, *system.HTTPError) {
+	app, httpErr := s.getOwnedApp(r)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	name := mux.Vars(r)["experiment"]
+
+	var experiment *types.Experiment
+	for i := range app.Config.Helix.Experiments {
+		if app.Config.Helix.Experiments[i].Name == name {
+			experiment = &app.Config.Helix.Experiments[i]
+			break
+		}
+	}
+	if experiment == nil {
+		return nil, system.NewHTTPError404(fmt.Sprintf("experiment %s not found", name))
+	}
+
+	stats, err := s.Store.GetExperimentReport(r.Context(), app.ID, name)
+	if err != nil {
+		return nil, system.NewHTTPError500(err.Error())
+	}
+
+	byVariant := make(map[string]*types.ExperimentVariantReport, len(stats))
+	for _, report := range stats {
+		byVariant[report.Variant] = report
+	}
+
+	// Variants follow the config, including the ones that haven't had any traffic yet. Variants
+	// that were removed from the config are still reported after them.
+	reports := make([]*types.ExperimentVariantReport, 0, len(experiment.Variants))
+	for _, variant := range experiment.Variants {
+		report, ok := byVariant[variant.ID]
+		if !ok {
+			report = &types.ExperimentVariantReport{Variant: variant.ID}
+		}
+		delete(byVariant, variant.ID)
+		reports = append(reports, report)
+	}
+	for _, report := range stats {
+		if _, ok := byVariant[report.Variant]; ok {
+			reports = append(reports, report)
+		}
+	}
+
+	return reports, nil
+}
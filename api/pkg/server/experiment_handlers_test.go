@@ -0,0 +1,61 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+func TestExperimentReport_FollowsConfigVariants(t *testing.T) {
+	ts, st := newAppManagementTestServer(t, types.User{ID: "user_1"})
+
+	app := &types.App{ID: "app_1", Owner: "user_1"}
+	app.Config.Helix.Experiments = []types.Experiment{
+		{
+			Name: "tone",
+			Variants: []types.ExperimentVariant{
+				{ID: "polite", Assistant: "0", Weight: 50},
+				{ID: "rude", Assistant: "1", Weight: 50},
+			},
+		},
+	}
+
+	st.EXPECT().GetApp(gomock.Any(), "app_1").Return(app, nil)
+	st.EXPECT().GetExperimentReport(gomock.Any(), "app_1", "tone").Return([]*types.ExperimentVariantReport{
+		{Variant: "removed", LLMCalls: 3},
+		{Variant: "rude", LLMCalls: 2, ThumbsDown: 1},
+	}, nil)
+
+	resp, err := http.Get(ts.URL + "/api/v1/apps/app_1/experiments/tone/report")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var reports []*types.ExperimentVariantReport
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&reports))
+	require.Len(t, reports, 3)
+	assert.Equal(t, "polite", reports[0].Variant)
+	assert.Equal(t, int64(0), reports[0].LLMCalls)
+	assert.Equal(t, "rude", reports[1].Variant)
+	assert.Equal(t, int64(1), reports[1].ThumbsDown)
+	assert.Equal(t, "removed", reports[2].Variant)
+}
+
+func TestExperimentReport_UnknownExperiment(t *testing.T) {
+	ts, st := newAppManagementTestServer(t, types.User{ID: "user_1"})
+
+	st.EXPECT().GetApp(gomock.Any(), "app_1").Return(&types.App{ID: "app_1", Owner: "user_1"}, nil)
+
+	resp, err := http.Get(ts.URL + "/api/v1/apps/app_1/experiments/tone/report")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
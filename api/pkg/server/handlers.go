@@ -3,7 +3,9 @@ package server
 import (
 	"archive/tar"
 	"bytes"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -321,12 +323,7 @@ func (apiServer *HelixAPIServer) runnerSessionDownloadFile(res http.ResponseWrit
 		res.Header().Set("Content-Type", http.DetectContentType([]byte(filename)))
 
 		// Write the file to the http.ResponseWriter
-		_, err = io.Copy(res, reader)
-		if err != nil {
-			return err
-		}
-
-		return nil
+		return writeWithDigest(res, reader)
 	}()
 
 	if err != nil {
@@ -335,6 +332,21 @@ func (apiServer *HelixAPIServer) runnerSessionDownloadFile(res http.ResponseWrit
 	}
 }
 
+// writeWithDigest copies the body to the response and sends its sha256 in a trailer, so that
+// runners can check their download is intact before caching it
+func writeWithDigest(res http.ResponseWriter, body io.Reader) error {
+	res.Header().Set("Trailer", types.RunnerContentDigestTrailer)
+
+	hash := sha256.New()
+	_, err := io.Copy(io.MultiWriter(res, hash), body)
+	if err != nil {
+		return err
+	}
+
+	res.Header().Set(types.RunnerContentDigestTrailer, hex.EncodeToString(hash.Sum(nil)))
+	return nil
+}
+
 func (apiServer *HelixAPIServer) runnerSessionDownloadFolder(res http.ResponseWriter, req *http.Request) {
 	vars := mux.Vars(req)
 	sessionid := vars["sessionid"]
@@ -359,12 +371,7 @@ func (apiServer *HelixAPIServer) runnerSessionDownloadFolder(res http.ResponseWr
 		res.Header().Set("Content-Type", "application/x-tar")
 
 		// Write the file to the http.ResponseWriter
-		_, err = io.Copy(res, tarStream)
-		if err != nil {
-			return err
-		}
-
-		return nil
+		return writeWithDigest(res, tarStream)
 	}()
 
 	if err != nil {
@@ -645,6 +652,43 @@ func (apiServer *HelixAPIServer) updateSessionMeta(_ http.ResponseWriter, req *h
 	return system.DefaultController(apiServer.Store.UpdateSessionMeta(ctx, *update))
 }
 
+// pinSessionInteraction godoc
+// @Summary Pin a session message
+// @Description Pinned messages are always sent to the model, however long the session gets.
+// @Tags    sessions
+// @Success 200 {object} types.Interaction
+// @Param request body types.PinInteractionRequest true "Whether the message is pinned."
+// @Param id path string true "Session ID"
+// @Param interaction path string true "Interaction ID"
+// @Router /api/v1/sessions/{id}/interactions/{interaction}/pin [put]
+// @Security BearerAuth
+func (apiServer *HelixAPIServer) pinSessionInteraction(_ http.ResponseWriter, req *http.Request) (*types.Interaction, *system.HTTPError) {
+	session, httpError := apiServer.sessionLoader(req, true)
+	if httpError != nil {
+		return nil, httpError
+	}
+
+	var pin types.PinInteractionRequest
+	err := json.NewDecoder(req.Body).Decode(&pin)
+	if err != nil {
+		return nil, system.NewHTTPError400(err.Error())
+	}
+
+	interaction, err := data.GetInteraction(session, mux.Vars(req)["interaction"])
+	if err != nil {
+		return nil, system.NewHTTPError404(err.Error())
+	}
+
+	interaction.Pinned = pin.Pinned
+
+	err = apiServer.Controller.WriteSession(req.Context(), session)
+	if err != nil {
+		return nil, system.NewHTTPError500(err.Error())
+	}
+
+	return interaction, nil
+}
+
 func (apiServer *HelixAPIServer) isAdmin(req *http.Request) bool {
 	user := getRequestUser(req)
 	adminUserIDs := strings.Split(os.Getenv("ADMIN_USER_IDS"), ",")
@@ -714,6 +758,21 @@ func (apiServer *HelixAPIServer) createAPIKey(_ http.ResponseWriter, req *http.R
 		newAPIKey.Name = nameStr
 		newAPIKey.Type = types.APIKeyType(typeStr)
 		newAPIKey.AppID = &sql.NullString{String: apiKeyStr, Valid: true}
+
+		// Keys can be for one of the app's environments
+		if environment, ok := objmap["environment"]; ok {
+			err = json.Unmarshal(environment, &newAPIKey.Environment)
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+
+	if newAPIKey.Environment != "" && newAPIKey.Environment != types.AppEnvironmentDev {
+		_, err := apiServer.Store.GetAppEnvironment(ctx, newAPIKey.AppID.String, newAPIKey.Environment)
+		if err != nil {
+			return "", fmt.Errorf("failed to get app environment %s: %w", newAPIKey.Environment, err)
+		}
 	}
 
 	createdKey, err := apiServer.Controller.CreateAPIKey(ctx, user, newAPIKey)
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"sync"
@@ -34,17 +35,28 @@ func (s *HelixAPIServer) knowledgeSearch(_ http.ResponseWriter, r *http.Request)
 		return nil, system.NewHTTPError500(err.Error())
 	}
 
-	var (
-		results   []*types.KnowledgeSearchResult
-		resultsMu sync.Mutex
-	)
-
 	if len(knowledges) == 0 {
 		// Make an empty results list
 		log.Warn().Msg("no knowledges found for app")
 		return []*types.KnowledgeSearchResult{}, nil
 	}
 
+	results, err := s.searchKnowledges(ctx, knowledges, prompt)
+	if err != nil {
+		return nil, system.NewHTTPError500(err.Error())
+	}
+
+	return results, nil
+}
+
+// searchKnowledges queries each knowledge for the prompt, the knowledges with the most results
+// come first
+func (s *HelixAPIServer) searchKnowledges(ctx context.Context, knowledges []*types.Knowledge, prompt string) ([]*types.KnowledgeSearchResult, error) {
+	var (
+		results   []*types.KnowledgeSearchResult
+		resultsMu sync.Mutex
+	)
+
 	pool := pool.New().
 		WithMaxGoroutines(20).
 		WithErrors()
@@ -55,7 +67,7 @@ func (s *HelixAPIServer) knowledgeSearch(_ http.ResponseWriter, r *http.Request)
 		client, err := s.Controller.GetRagClient(ctx, knowledge)
 		if err != nil {
 			log.Error().Err(err).Msgf("error getting RAG client for knowledge %s", knowledge.ID)
-			return nil, system.NewHTTPError500(err.Error())
+			return nil, err
 		}
 
 		pool.Go(func() error {
@@ -88,10 +100,10 @@ func (s *HelixAPIServer) knowledgeSearch(_ http.ResponseWriter, r *http.Request)
 		})
 	}
 
-	err = pool.Wait()
+	err := pool.Wait()
 	if err != nil {
 		log.Error().Err(err).Msg("error waiting for RAG queries")
-		return nil, system.NewHTTPError500(err.Error())
+		return nil, err
 	}
 
 	// Sort the results
@@ -127,3 +127,37 @@ func (s *HelixAPIServer) listAppLLMCalls(_ http.ResponseWriter, r *http.Request)
 
 	return response, nil
 }
+
+// listAppGuardrailOutcomes godoc
+// @Summary List guardrail outcomes
+// @Description List what the app's guardrails caught, newest first. Blocked requests never reach the model so they aren't in the LLM calls.
+// @Tags    llm_calls
+// @Produce json
+// @Param   id         path     string  true   "App ID"
+// @Param   session_id query    string  false  "Filter by session ID"
+// @Param   limit      query    int     false  "Maximum number of outcomes, defaults to 100"
+// @Success 200 {array} types.GuardrailOutcome
+// @Router /api/v1/apps/{id}/guardrail-outcomes [get]
+// @Security BearerAuth
+func (s *HelixAPIServer) listAppGuardrailOutcomes(_ http.ResponseWriter, r *http.Request) ([]*types.GuardrailOutcome, *system.HTTPError) {
+	app, httpErr := s.getOwnedApp(r)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 {
+		limit = 100
+	}
+
+	outcomes, err := s.Store.ListGuardrailOutcomes(r.Context(), &store.ListGuardrailOutcomesQuery{
+		AppID:     app.ID,
+		SessionID: r.URL.Query().Get("session_id"),
+		Limit:     limit,
+	})
+	if err != nil {
+		return nil, system.NewHTTPError500(err.Error())
+	}
+
+	return outcomes, nil
+}
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	mcpserver "github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+
+	"github.com/helixml/helix/api/pkg/system"
+)
+
+// mcpKeepAliveInterval is how often an idle event stream gets a comment, so that proxies don't
+// close it
+const mcpKeepAliveInterval = 30 * time.Second
+
+// mcpSession is an MCP client connected over SSE. Its messages are posted to the message endpoint
+// and the responses are sent on the event stream.
+type mcpSession struct {
+	userID string
+	appID  string
+	server *mcpserver.MCPServer
+	events chan []byte
+	done   chan struct{}
+}
+
+// mcpStreamableHTTP godoc
+// @Summary Model Context Protocol endpoint
+// @Description Serves the app's tools, knowledge and prompts over the MCP streamable HTTP transport. Needs an app API key, each request gets its response in the body.
+// @Tags    mcp
+
+// @Success 200 {object} object
+// @Router /api/v1/mcp [post]
+// @Security BearerAuth
+func (apiServer *HelixAPIServer) mcpStreamableHTTP(w http.ResponseWriter, r *http.Request) {
+	user := getRequestUser(r)
+	if user.AppID == "" {
+		http.Error(w, "MCP needs an app API key", http.StatusForbidden)
+		return
+	}
+
+	var message json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode message: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	srv, err := apiServer.newAppMCPServer(r.Context(), user)
+	if err != nil {
+		log.Error().Err(err).Str("app_id", user.AppID).Msg("failed to create MCP server")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := srv.HandleMessage(r.Context(), message)
+	if response == nil {
+		// Notifications don't have a response
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Error().Err(err).Msg("failed to write MCP response")
+	}
+}
+
+// mcpSSE godoc
+// @Summary Model Context Protocol event stream
+// @Description Opens an MCP session over the SSE transport. Needs an app API key, the first event is the endpoint to post messages to.
+// @Tags    mcp
+
+// @Success 200
+// @Router /api/v1/mcp/sse [get]
+// @Security BearerAuth
+func (apiServer *HelixAPIServer) mcpSSE(w http.ResponseWriter, r *http.Request) {
+	user := getRequestUser(r)
+	if user.AppID == "" {
+		http.Error(w, "MCP needs an app API key", http.StatusForbidden)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	srv, err := apiServer.newAppMCPServer(r.Context(), user)
+	if err != nil {
+		log.Error().Err(err).Str("app_id", user.AppID).Msg("failed to create MCP server")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sessionID := system.GenerateUUID()
+	session := &mcpSession{
+		userID: user.ID,
+		appID:  user.AppID,
+		server: srv,
+		events: make(chan []byte, 16),
+		done:   make(chan struct{}),
+	}
+
+	apiServer.mcpSessions.Store(sessionID, session)
+	defer func() {
+		apiServer.mcpSessions.Delete(sessionID)
+		close(session.done)
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	fmt.Fprintf(w, "event: endpoint\ndata: %s%s/mcp/message?sessionId=%s\n\n", apiServer.Cfg.WebServer.URL, APIPrefix, sessionID)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(mcpKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case event := <-session.events:
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", event)
+			flusher.Flush()
+		}
+	}
+}
+
+// mcpMessage godoc
+// @Summary Model Context Protocol message
+// @Description Posts a message to an MCP session opened with /api/v1/mcp/sse, the response is sent on the session's event stream.
+// @Tags    mcp
+
+// @Success 202
+// @Param sessionId query string true "Session ID from the endpoint event"
+// @Router /api/v1/mcp/message [post]
+// @Security BearerAuth
+func (apiServer *HelixAPIServer) mcpMessage(w http.ResponseWriter, r *http.Request) {
+	user := getRequestUser(r)
+
+	value, ok := apiServer.mcpSessions.Load(r.URL.Query().Get("sessionId"))
+	if !ok {
+		http.Error(w, "MCP session not found", http.StatusNotFound)
+		return
+	}
+	session := value.(*mcpSession)
+
+	// Sessions belong to the key that opened them
+	if session.userID != user.ID || session.appID != user.AppID {
+		http.Error(w, "MCP session not found", http.StatusNotFound)
+		return
+	}
+
+	var message json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode message: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	response := session.server.HandleMessage(r.Context(), message)
+	if response != nil {
+		bts, err := json.Marshal(response)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		select {
+		case session.events <- bts:
+		case <-session.done:
+			http.Error(w, "MCP session closed", http.StatusGone)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
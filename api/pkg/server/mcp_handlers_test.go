@@ -0,0 +1,210 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/helixml/helix/api/pkg/config"
+	"github.com/helixml/helix/api/pkg/controller"
+	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/tools"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+const mcpTestSchema = `openapi: 3.0.0
+info:
+  title: Weather
+  version: 1.0.0
+paths:
+  /weather:
+    get:
+      operationId: getWeather
+      summary: Get the weather for a city
+      parameters:
+        - name: city
+          in: query
+          required: true
+          schema:
+            type: string
+      responses:
+        "200":
+          description: OK
+`
+
+// mcpTestPlanner runs API actions without calling the API
+type mcpTestPlanner struct {
+	tools.Planner
+	requests []*types.RunAPIActionRequest
+}
+
+func (p *mcpTestPlanner) RunAPIActionWithParameters(_ context.Context, req *types.RunAPIActionRequest) (*types.RunAPIActionResponse, error) {
+	p.requests = append(p.requests, req)
+	return &types.RunAPIActionResponse{Response: `{"weather": "sunny"}`}, nil
+}
+
+func newMCPTestServer(t *testing.T, user types.User) (*httptest.Server, *mcpTestPlanner) {
+	ctrl := gomock.NewController(t)
+	st := store.NewMockStore(ctrl)
+
+	app := &types.App{
+		ID:    "app_1",
+		Owner: "user_1",
+		Config: types.AppConfig{
+			Helix: types.AppHelixConfig{
+				Name: "weather-app",
+				Assistants: []types.AssistantConfig{
+					{
+						Name:         "forecaster",
+						Description:  "Tells the weather",
+						SystemPrompt: "You are a weather forecaster.",
+						Tools: []*types.Tool{
+							{
+								Name:     "weather",
+								ToolType: types.ToolTypeAPI,
+								Config: types.ToolConfig{
+									API: &types.ToolAPIConfig{
+										URL:    "https://weather.example.com",
+										Schema: mcpTestSchema,
+										Actions: []*types.ToolAPIAction{
+											{Name: "getWeather", Description: "Get the weather for a city", Method: "GET", Path: "/weather"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	st.EXPECT().GetAppWithTools(gomock.Any(), "app_1").Return(app, nil).AnyTimes()
+	st.EXPECT().ListSecrets(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+	st.EXPECT().ListKnowledge(gomock.Any(), &store.ListKnowledgeQuery{AppID: "app_1"}).Return([]*types.Knowledge{
+		{ID: "kno_1", Name: "docs", Description: "weather station manuals"},
+	}, nil).AnyTimes()
+
+	planner := &mcpTestPlanner{}
+	apiServer := &HelixAPIServer{
+		Cfg:   &config.ServerConfig{},
+		Store: st,
+		Controller: &controller.Controller{
+			Options:      controller.Options{Store: st},
+			ToolsPlanner: planner,
+		},
+	}
+
+	router := mux.NewRouter()
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(setRequestUser(r.Context(), user)))
+		})
+	})
+	router.HandleFunc("/api/v1/mcp", apiServer.mcpStreamableHTTP).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/mcp/sse", apiServer.mcpSSE).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/mcp/message", apiServer.mcpMessage).Methods(http.MethodPost)
+
+	ts := httptest.NewServer(router)
+	t.Cleanup(ts.Close)
+
+	apiServer.Cfg.WebServer.URL = ts.URL
+
+	return ts, planner
+}
+
+func TestMCP_SSE(t *testing.T) {
+	ts, planner := newMCPTestServer(t, types.User{ID: "user_1", AppID: "app_1"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client, err := mcpclient.NewSSEMCPClient(ts.URL + "/api/v1/mcp/sse")
+	require.NoError(t, err)
+	require.NoError(t, client.Start(ctx))
+	defer client.Close()
+
+	var initReq mcp.InitializeRequest
+	initReq.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	initResp, err := client.Initialize(ctx, initReq)
+	require.NoError(t, err)
+	assert.Equal(t, "weather-app", initResp.ServerInfo.Name)
+
+	list, err := client.ListTools(ctx, mcp.ListToolsRequest{})
+	require.NoError(t, err)
+
+	var names []string
+	for _, tool := range list.Tools {
+		names = append(names, tool.Name)
+	}
+	assert.ElementsMatch(t, []string{"getWeather", "docs"}, names)
+
+	var callReq mcp.CallToolRequest
+	callReq.Params.Name = "getWeather"
+	callReq.Params.Arguments = map[string]interface{}{"city": "London"}
+	result, err := client.CallTool(ctx, callReq)
+	require.NoError(t, err)
+	require.Len(t, result.Content, 1)
+	assert.Equal(t, `{"weather": "sunny"}`, result.Content[0].(mcp.TextContent).Text)
+
+	require.Len(t, planner.requests, 1)
+	assert.Equal(t, "getWeather", planner.requests[0].Action)
+	assert.Equal(t, "London", planner.requests[0].Parameters["city"])
+
+	var promptReq mcp.GetPromptRequest
+	promptReq.Params.Name = "forecaster"
+	prompt, err := client.GetPrompt(ctx, promptReq)
+	require.NoError(t, err)
+	require.Len(t, prompt.Messages, 1)
+	assert.Equal(t, "You are a weather forecaster.", prompt.Messages[0].Content.(mcp.TextContent).Text)
+}
+
+func TestMCP_StreamableHTTP(t *testing.T) {
+	ts, _ := newMCPTestServer(t, types.User{ID: "user_1", AppID: "app_1"})
+
+	resp, err := http.Post(ts.URL+"/api/v1/mcp", "application/json",
+		strings.NewReader(`{"jsonrpc": "2.0", "id": 1, "method": "prompts/list"}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var message struct {
+		Result mcp.ListPromptsResult `json:"result"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&message))
+	require.Len(t, message.Result.Prompts, 1)
+	assert.Equal(t, "forecaster", message.Result.Prompts[0].Name)
+}
+
+func TestMCP_NeedsAppAPIKey(t *testing.T) {
+	ts, _ := newMCPTestServer(t, types.User{ID: "user_1"})
+
+	resp, err := http.Post(ts.URL+"/api/v1/mcp", "application/json",
+		strings.NewReader(`{"jsonrpc": "2.0", "id": 1, "method": "tools/list"}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestMCP_MessageForUnknownSession(t *testing.T) {
+	ts, _ := newMCPTestServer(t, types.User{ID: "user_1", AppID: "app_1"})
+
+	resp, err := http.Post(ts.URL+"/api/v1/mcp/message?sessionId=someone-elses", "application/json",
+		strings.NewReader(`{"jsonrpc": "2.0", "id": 1, "method": "tools/list"}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
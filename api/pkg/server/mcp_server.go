@@ -0,0 +1,206 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	mcpserver "github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	openai "github.com/sashabaranov/go-openai"
+
+	oai "github.com/helixml/helix/api/pkg/openai"
+	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/tools"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+// newAppMCPServer builds an MCP server for the app that the user's API key belongs to. It offers
+// the app's API, GPTScript and Zapier tools, a search tool for each knowledge and the assistants'
+// system prompts.
+func (apiServer *HelixAPIServer) newAppMCPServer(ctx context.Context, user *types.User) (*mcpserver.MCPServer, error) {
+	app, err := apiServer.Controller.LoadApp(ctx, user, user.AppID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get app: %w", err)
+	}
+
+	// Tool credentials can reference the user's secrets
+	app, err = apiServer.Controller.EvaluateSecrets(ctx, user, app)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate secrets: %w", err)
+	}
+
+	name := app.Config.Helix.Name
+	if name == "" {
+		name = "Helix ML"
+	}
+
+	srv := mcpserver.NewMCPServer(name, "1.0.0",
+		mcpserver.WithPromptCapabilities(false),
+		mcpserver.WithLogging(),
+	)
+
+	// Assistants can share tools, the first one with a name wins
+	added := make(map[string]bool)
+	addTool := func(tool mcp.Tool, handler mcpserver.ToolHandlerFunc) {
+		if added[tool.Name] {
+			log.Warn().
+				Str("app_id", app.ID).
+				Str("tool", tool.Name).
+				Msg("MCP tool with this name already added, skipping it")
+			return
+		}
+		added[tool.Name] = true
+		srv.AddTool(tool, handler)
+	}
+
+	for i, assistant := range app.Config.Helix.Assistants {
+		for _, tool := range assistant.Tools {
+			switch tool.ToolType {
+			case types.ToolTypeAPI:
+				for _, mcpTool := range tools.NewMCPToolsFromAPI(tool) {
+					addTool(mcpTool, apiServer.mcpAPIToolHandler(user, app.ID, strconv.Itoa(i), tool, mcpTool.Name))
+				}
+			case types.ToolTypeGPTScript, types.ToolTypeZapier:
+				addTool(mcp.NewTool(tool.Name,
+					mcp.WithDescription(tool.Description),
+					mcp.WithString("input",
+						mcp.Required(),
+						mcp.Description("What the tool should do, in plain language"),
+					),
+				), apiServer.mcpInputToolHandler(tool))
+			}
+		}
+
+		if assistant.SystemPrompt != "" {
+			addAssistantPrompt(srv, assistant)
+		}
+	}
+
+	knowledges, err := apiServer.Store.ListKnowledge(ctx, &store.ListKnowledgeQuery{
+		AppID: app.ID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list knowledge: %w", err)
+	}
+
+	for _, knowledge := range knowledges {
+		addTool(mcp.NewTool(knowledge.Name,
+			mcp.WithDescription(fmt.Sprintf("Knowledge tool to search for: '%s'. Returns fragments from the database", knowledge.Description)),
+			mcp.WithString("prompt",
+				mcp.Required(),
+				mcp.Description("The prompt to search knowledge with, use concise, main keywords as the engine is performing both semantic and full text search"),
+			),
+		), apiServer.mcpKnowledgeToolHandler(knowledge))
+	}
+
+	return srv, nil
+}
+
+func (apiServer *HelixAPIServer) mcpAPIToolHandler(user *types.User, appID, assistantID string, tool *types.Tool, action string) mcpserver.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		// Tools that act on behalf of users need to know who is calling, and the app they linked
+		// their account for
+		ctx = oai.SetContextValues(ctx, &oai.ContextValues{
+			OwnerID: user.ID,
+		})
+		ctx = oai.SetContextAppID(ctx, appID)
+
+		// Actions that need approval reply with what the user is approving
+		resp, err := apiServer.Controller.RunAPIActionWithParameters(ctx, user, appID, assistantID, &types.RunAPIActionRequest{
+			Action:     action,
+			Parameters: request.Params.Arguments,
+			Tool:       tool,
+		})
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Str("tool", tool.Name).
+				Str("action", action).
+				Msg("failed to run API action for MCP client")
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		return mcp.NewToolResultText(resp.Response), nil
+	}
+}
+
+// mcpInputToolHandler runs tools that take a plain language instruction, such as GPTScript and
+// Zapier tools
+func (apiServer *HelixAPIServer) mcpInputToolHandler(tool *types.Tool) mcpserver.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		input, _ := request.Params.Arguments["input"].(string)
+		if input == "" {
+			return mcp.NewToolResultError("input is required"), nil
+		}
+
+		resp, err := apiServer.Controller.ToolsPlanner.RunAction(ctx, "", "", tool, []*types.ToolHistoryMessage{
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: input,
+			},
+		}, tool.Name)
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Str("tool", tool.Name).
+				Msg("failed to run tool for MCP client")
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		return mcp.NewToolResultText(resp.Message), nil
+	}
+}
+
+func (apiServer *HelixAPIServer) mcpKnowledgeToolHandler(knowledge *types.Knowledge) mcpserver.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		prompt, _ := request.Params.Arguments["prompt"].(string)
+		if prompt == "" {
+			return mcp.NewToolResultError("prompt is required"), nil
+		}
+
+		results, err := apiServer.searchKnowledges(ctx, []*types.Knowledge{knowledge}, prompt)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		var fragments []*types.SessionRAGResult
+		for _, result := range results {
+			fragments = append(fragments, result.Results...)
+		}
+
+		bts, err := json.Marshal(fragments)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		return mcp.NewToolResultText(string(bts)), nil
+	}
+}
+
+// addAssistantPrompt offers the assistant's system prompt, so that clients can take on the
+// assistant's instructions
+func addAssistantPrompt(srv *mcpserver.MCPServer, assistant types.AssistantConfig) {
+	name := assistant.Name
+	if name == "" {
+		name = "assistant"
+	}
+
+	description := assistant.Description
+	if description == "" {
+		description = fmt.Sprintf("Instructions of the %s assistant", name)
+	}
+
+	srv.AddPrompt(mcp.NewPrompt(name,
+		mcp.WithPromptDescription(description),
+	), func(_ context.Context, _ mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		return &mcp.GetPromptResult{
+			Description: description,
+			Messages: []mcp.PromptMessage{
+				mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(assistant.SystemPrompt)),
+			},
+		}, nil
+	})
+}
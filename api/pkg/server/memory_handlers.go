@@ -0,0 +1,183 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	openai "github.com/sashabaranov/go-openai"
+
+	"github.com/helixml/helix/api/pkg/controller"
+	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/system"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+// listMemories godoc
+// @Summary List memories
+// @Description List what the assistants remember about the user, newest first.
+// @Tags    memories
+// @Success 200 {array} types.Memory
+// @Param app_id query string false "App ID"
+// @Router /api/v1/memories [get]
+// @Security BearerAuth
+func (s *HelixAPIServer) listMemories(_ http.ResponseWriter, r *http.Request) ([]*types.Memory, *system.HTTPError) {
+	user := getRequestUser(r)
+	if user == nil {
+		return nil, system.NewHTTPError401("user not found")
+	}
+
+	memories, err := s.Store.ListMemories(r.Context(), &store.ListMemoriesQuery{
+		AppID:  r.URL.Query().Get("app_id"),
+		UserID: user.ID,
+	})
+	if err != nil {
+		return nil, system.NewHTTPError500(err.Error())
+	}
+
+	return memories, nil
+}
+
+// updateMemory godoc
+// @Summary Update a memory
+// @Description Change what the assistants remember about the user.
+// @Tags    memories
+// @Success 200 {object} types.Memory
+// @Param request body types.UpdateMemoryRequest true "Request body with the memory's content."
+// @Param id path string true "Memory ID"
+// @Router /api/v1/memories/{id} [put]
+// @Security BearerAuth
+func (s *HelixAPIServer) updateMemory(_ http.ResponseWriter, r *http.Request) (*types.Memory, *system.HTTPError) {
+	user := getRequestUser(r)
+	if user == nil {
+		return nil, system.NewHTTPError401("user not found")
+	}
+
+	var req types.UpdateMemoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, system.NewHTTPError400(err.Error())
+	}
+
+	req.Content = strings.TrimSpace(req.Content)
+	if req.Content == "" {
+		return nil, system.NewHTTPError400("memory content cannot be empty")
+	}
+
+	memory, httpErr := s.getUserMemory(r, user)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	memory.Content = req.Content
+
+	updated, err := s.Controller.UpdateMemory(r.Context(), memory)
+	if err != nil {
+		return nil, system.NewHTTPError500(err.Error())
+	}
+
+	return updated, nil
+}
+
+// deleteMemory godoc
+// @Summary Delete a memory
+// @Description Make the assistants forget the memory.
+// @Tags    memories
+// @Success 200 {object} types.Memory
+// @Param id path string true "Memory ID"
+// @Router /api/v1/memories/{id} [delete]
+// @Security BearerAuth
+func (s *HelixAPIServer) deleteMemory(_ http.ResponseWriter, r *http.Request) (*types.Memory, *system.HTTPError) {
+	user := getRequestUser(r)
+	if user == nil {
+		return nil, system.NewHTTPError401("user not found")
+	}
+
+	memory, httpErr := s.getUserMemory(r, user)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	if err := s.Controller.DeleteMemory(r.Context(), memory); err != nil {
+		return nil, system.NewHTTPError500(err.Error())
+	}
+
+	return memory, nil
+}
+
+// deleteMemories godoc
+// @Summary Delete memories
+// @Description Make the assistants forget everything they remember about the user, of the app when it's set.
+// @Tags    memories
+// @Success 200 {array} types.Memory
+// @Param app_id query string false "App ID"
+// @Router /api/v1/memories [delete]
+// @Security BearerAuth
+func (s *HelixAPIServer) deleteMemories(_ http.ResponseWriter, r *http.Request) ([]*types.Memory, *system.HTTPError) {
+	ctx := r.Context()
+	user := getRequestUser(r)
+	if user == nil {
+		return nil, system.NewHTTPError401("user not found")
+	}
+
+	memories, err := s.Store.ListMemories(ctx, &store.ListMemoriesQuery{
+		AppID:  r.URL.Query().Get("app_id"),
+		UserID: user.ID,
+	})
+	if err != nil {
+		return nil, system.NewHTTPError500(err.Error())
+	}
+
+	// Memories are indexed by app
+	deleted := make(map[string]bool)
+	for _, memory := range memories {
+		if deleted[memory.AppID] {
+			continue
+		}
+		deleted[memory.AppID] = true
+
+		if err := s.Controller.DeleteMemories(ctx, memory.AppID, user.ID); err != nil {
+			return nil, system.NewHTTPError500(err.Error())
+		}
+	}
+
+	return memories, nil
+}
+
+// getUserMemory returns the memory in the request's path when it's the user's
+func (s *HelixAPIServer) getUserMemory(r *http.Request, user *types.User) (*types.Memory, *system.HTTPError) {
+	memory, err := s.Store.GetMemory(r.Context(), getID(r))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, system.NewHTTPError404("memory not found")
+		}
+		return nil, system.NewHTTPError500(err.Error())
+	}
+
+	if memory.UserID != user.ID {
+		return nil, system.NewHTTPError404("memory not found")
+	}
+
+	return memory, nil
+}
+
+// extractMemories remembers what the app's assistant learnt about the user from their message
+// and the answer to it, in the background
+func (s *HelixAPIServer) extractMemories(ctx context.Context, user *types.User, req openai.ChatCompletionRequest, answer string, options *controller.ChatCompletionOptions) {
+	if options.AppID == "" {
+		return
+	}
+
+	go func() {
+		err := s.Controller.ExtractMemories(context.WithoutCancel(ctx), user, req, answer, options)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("app_id", options.AppID).
+				Str("user_id", user.ID).
+				Msg("failed to extract memories")
+		}
+	}()
+}
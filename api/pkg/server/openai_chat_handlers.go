@@ -94,6 +94,9 @@ func (s *HelixAPIServer) createChatCompletion(rw http.ResponseWriter, r *http.Re
 		AppID:       r.URL.Query().Get("app_id"),
 		AssistantID: r.URL.Query().Get("assistant_id"),
 		RAGSourceID: r.URL.Query().Get("rag_source_id"),
+		// Apps serving many end users can pass who the request is for, so that each of
+		// them gets their own experiment variant
+		ExperimentSubject: chatCompletionRequest.User,
 		QueryParams: func() map[string]string {
 			params := make(map[string]string)
 			for key, values := range r.URL.Query() {
@@ -167,7 +170,7 @@ func (s *HelixAPIServer) createChatCompletion(rw http.ResponseWriter, r *http.Re
 		resp, _, err := s.Controller.ChatCompletion(ctx, user, chatCompletionRequest, options)
 		if err != nil {
 			log.Error().Err(err).Msg("error creating chat completion")
-			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			writeChatCompletionError(rw, err)
 			return
 		}
 
@@ -196,7 +199,7 @@ func (s *HelixAPIServer) createChatCompletion(rw http.ResponseWriter, r *http.Re
 	// Streaming request, receive and write the stream in chunks
 	stream, _, err := s.Controller.ChatCompletionStream(ctx, user, chatCompletionRequest, options)
 	if err != nil {
-		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		writeChatCompletionError(rw, err)
 		return
 	}
 	defer stream.Close()
@@ -229,6 +232,30 @@ func (s *HelixAPIServer) createChatCompletion(rw http.ResponseWriter, r *http.Re
 	}
 }
 
+// writeChatCompletionError writes responses that don't match the assistant's response schema as
+// OpenAI errors, so that clients can tell them apart from failures
+func writeChatCompletionError(rw http.ResponseWriter, err error) {
+	var schemaErr *controller.ResponseSchemaError
+	if !errors.As(err, &schemaErr) {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusUnprocessableEntity)
+
+	err = json.NewEncoder(rw).Encode(&types.ErrorResponse{
+		Error: &types.APIError{
+			Code:    "response_schema_mismatch",
+			Message: schemaErr.Error(),
+			Type:    "invalid_response_error",
+		},
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("error writing response")
+	}
+}
+
 func (s *HelixAPIServer) getAppLoraAssistant(ctx context.Context, appID string) (*types.AssistantConfig, error) {
 	app, err := s.Store.GetAppWithTools(ctx, appID)
 	if err != nil {
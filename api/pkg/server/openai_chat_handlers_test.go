@@ -1061,3 +1061,19 @@ func (suite *OpenAIChatSuite) TestChatCompletions_App_CustomQueryParams() {
 	err = json.Unmarshal(rec.Body.Bytes(), &resp)
 	suite.NoError(err)
 }
+
+func TestWriteChatCompletionError_ResponseSchema(t *testing.T) {
+	rec := httptest.NewRecorder()
+
+	writeChatCompletionError(rec, fmt.Errorf("error running LLM: %w", &controller.ResponseSchemaError{
+		Content:  "Ada is 30",
+		Problems: []string{"response is not valid JSON"},
+	}))
+
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	var resp types.ErrorResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	require.Equal(t, "response_schema_mismatch", resp.Error.Code)
+	require.Contains(t, resp.Error.Message, "response is not valid JSON")
+}
@@ -207,6 +207,7 @@ func (s *HelixAPIServer) getDesiredRunnerSlots(_ http.ResponseWriter, req *http.
 	}
 
 	return &types.GetDesiredRunnerSlotsResponse{
-		Data: s.scheduler.SlotsForRunner(runnerID),
+		Data:             s.scheduler.SlotsForRunner(runnerID),
+		MaintenanceState: s.scheduler.RunnerMaintenanceState(runnerID),
 	}, nil
 }
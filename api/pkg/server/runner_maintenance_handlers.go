@@ -0,0 +1,77 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/helixml/helix/api/pkg/system"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+// cordonRunner godoc
+// @Summary Cordon a runner
+// @Description Stop new slots being created on a runner. Warm slots keep serving requests.
+// @Tags    runners
+// @Produce json
+// @Param   id  path  string  true  "Runner ID"
+// @Success 200 {object} types.RunnerMaintenanceStatus
+// @Router /api/v1/runners/{id}/cordon [post]
+// @Security BearerAuth
+func (s *HelixAPIServer) cordonRunner(_ http.ResponseWriter, r *http.Request) (*types.RunnerMaintenanceStatus, *system.HTTPError) {
+	runnerID := mux.Vars(r)["id"]
+	if err := s.scheduler.CordonRunner(runnerID); err != nil {
+		return nil, system.NewHTTPError400(err.Error())
+	}
+	return s.runnerMaintenanceStatus(runnerID), nil
+}
+
+// drainRunner godoc
+// @Summary Drain a runner
+// @Description Stop new work going to a runner and release its slots once their work has finished
+// @Tags    runners
+// @Produce json
+// @Param   id  path  string  true  "Runner ID"
+// @Success 200 {object} types.RunnerMaintenanceStatus
+// @Router /api/v1/runners/{id}/drain [post]
+// @Security BearerAuth
+func (s *HelixAPIServer) drainRunner(_ http.ResponseWriter, r *http.Request) (*types.RunnerMaintenanceStatus, *system.HTTPError) {
+	runnerID := mux.Vars(r)["id"]
+	if err := s.scheduler.DrainRunner(runnerID); err != nil {
+		return nil, system.NewHTTPError400(err.Error())
+	}
+	return s.runnerMaintenanceStatus(runnerID), nil
+}
+
+// uncordonRunner godoc
+// @Summary Uncordon a runner
+// @Description Put a cordoned or drained runner back into rotation
+// @Tags    runners
+// @Produce json
+// @Param   id  path  string  true  "Runner ID"
+// @Success 200 {object} types.RunnerMaintenanceStatus
+// @Router /api/v1/runners/{id}/uncordon [post]
+// @Security BearerAuth
+func (s *HelixAPIServer) uncordonRunner(_ http.ResponseWriter, r *http.Request) (*types.RunnerMaintenanceStatus, *system.HTTPError) {
+	runnerID := mux.Vars(r)["id"]
+	if err := s.scheduler.UncordonRunner(runnerID); err != nil {
+		return nil, system.NewHTTPError400(err.Error())
+	}
+	return s.runnerMaintenanceStatus(runnerID), nil
+}
+
+// drainSelf is called by a runner that is shutting down, e.g. on SIGTERM
+func (s *HelixAPIServer) drainSelf(_ http.ResponseWriter, r *http.Request) (*types.RunnerMaintenanceStatus, error) {
+	runnerID := mux.Vars(r)["runnerid"]
+	if err := s.scheduler.DrainRunner(runnerID); err != nil {
+		return nil, err
+	}
+	return s.runnerMaintenanceStatus(runnerID), nil
+}
+
+func (s *HelixAPIServer) runnerMaintenanceStatus(runnerID string) *types.RunnerMaintenanceStatus {
+	return &types.RunnerMaintenanceStatus{
+		RunnerID: runnerID,
+		State:    s.scheduler.RunnerMaintenanceState(runnerID),
+	}
+}
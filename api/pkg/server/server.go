@@ -7,6 +7,7 @@ import (
 	"net/http/httputil"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -26,6 +27,9 @@ import (
 	"github.com/helixml/helix/api/pkg/store"
 	"github.com/helixml/helix/api/pkg/stripe"
 	"github.com/helixml/helix/api/pkg/system"
+	"github.com/helixml/helix/api/pkg/tools"
+	"github.com/helixml/helix/api/pkg/trigger/webhook"
+	"github.com/helixml/helix/api/pkg/workflow"
 
 	_ "net/http/pprof" // enable profiling
 )
@@ -68,6 +72,10 @@ type HelixAPIServer struct {
 	knowledgeManager  knowledge.Manager
 	router            *mux.Router
 	scheduler         scheduler.Scheduler
+	toolOAuth         *tools.OAuthManager
+	mcpSessions       sync.Map // MCP sessions over SSE by session ID
+	webhookTrigger    *webhook.Webhook
+	workflows         *workflow.Engine
 }
 
 func NewServer(
@@ -100,6 +108,16 @@ func NewServer(
 		return nil, fmt.Errorf("runner token is required")
 	}
 
+	toolOAuth, err := tools.NewOAuthManager(store, http.DefaultClient, cfg.Tools.OAuthEncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tool oauth manager: %w", err)
+	}
+
+	webhookTrigger, err := webhook.New(cfg, store, controller)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook trigger: %w", err)
+	}
+
 	return &HelixAPIServer{
 		Cfg:               cfg,
 		Store:             store,
@@ -120,6 +138,9 @@ func NewServer(
 		pubsub:           ps,
 		knowledgeManager: knowledgeManager,
 		scheduler:        scheduler,
+		toolOAuth:        toolOAuth,
+		webhookTrigger:   webhookTrigger,
+		workflows:        workflow.New(store, controller),
 	}, nil
 }
 
@@ -205,6 +226,20 @@ func (apiServer *HelixAPIServer) registerRoutes(_ context.Context) (*mux.Router,
 	authRouter.HandleFunc("/github/repos", system.DefaultWrapper(apiServer.listGithubRepos)).Methods(http.MethodGet)
 	subRouter.HandleFunc("/github/webhook", apiServer.githubWebhook).Methods(http.MethodPost)
 
+	// app webhook triggers are authenticated by the trigger's secret
+	subRouter.Handle("/webhooks/{app_id}/{name}", apiServer.webhookTrigger).Methods(http.MethodPost)
+
+	// linking user accounts for API tools that act on their behalf, the callback
+	// is authenticated by the encrypted state and the browser's nonce cookie
+	authRouter.HandleFunc("/tools/oauth/status", system.Wrapper(apiServer.toolOAuthStatus)).Methods(http.MethodGet)
+	authRouter.HandleFunc("/tools/oauth", system.Wrapper(apiServer.toolOAuthDisconnect)).Methods(http.MethodDelete)
+	subRouter.HandleFunc("/tools/oauth/callback", apiServer.toolOAuthCallback).Methods(http.MethodGet)
+
+	// side-effecting tool actions waiting for the user's approval, and the audit trail of decisions
+	authRouter.HandleFunc("/tool_approvals", system.Wrapper(apiServer.listToolApprovals)).Methods(http.MethodGet)
+	authRouter.HandleFunc("/tool_approvals/{id}", system.Wrapper(apiServer.getToolApproval)).Methods(http.MethodGet)
+	authRouter.HandleFunc("/tool_approvals/{id}/decision", system.Wrapper(apiServer.decideToolApproval)).Methods(http.MethodPost)
+
 	authRouter.HandleFunc("/status", system.DefaultWrapper(apiServer.status)).Methods(http.MethodGet)
 
 	// the auth here is handled because we prefix the user path based on the auth context
@@ -297,6 +332,7 @@ func (apiServer *HelixAPIServer) registerRoutes(_ context.Context) (*mux.Router,
 	authRouter.HandleFunc("/sessions/{id}/config", system.Wrapper(apiServer.updateSessionConfig)).Methods(http.MethodPut)
 
 	authRouter.HandleFunc("/sessions/{id}/meta", system.Wrapper(apiServer.updateSessionMeta)).Methods(http.MethodPut)
+	authRouter.HandleFunc("/sessions/{id}/interactions/{interaction}/pin", system.Wrapper(apiServer.pinSessionInteraction)).Methods(http.MethodPut)
 	authRouter.HandleFunc("/sessions/{id}/finetune/start", system.Wrapper(apiServer.startSessionFinetune)).Methods(http.MethodPost)
 	authRouter.HandleFunc("/sessions/{id}/finetune/documents", system.Wrapper(apiServer.finetuneAddDocuments)).Methods(http.MethodPut)
 	authRouter.HandleFunc("/sessions/{id}/finetune/clone/{interaction}/{mode}", system.Wrapper(apiServer.cloneFinetuneInteraction)).Methods(http.MethodPost)
@@ -309,6 +345,11 @@ func (apiServer *HelixAPIServer) registerRoutes(_ context.Context) (*mux.Router,
 	authRouter.HandleFunc("/secrets/{id}", system.Wrapper(apiServer.updateSecret)).Methods(http.MethodPut)
 	authRouter.HandleFunc("/secrets/{id}", system.Wrapper(apiServer.deleteSecret)).Methods(http.MethodDelete)
 
+	authRouter.HandleFunc("/memories", system.Wrapper(apiServer.listMemories)).Methods(http.MethodGet)
+	authRouter.HandleFunc("/memories", system.Wrapper(apiServer.deleteMemories)).Methods(http.MethodDelete)
+	authRouter.HandleFunc("/memories/{id}", system.Wrapper(apiServer.updateMemory)).Methods(http.MethodPut)
+	authRouter.HandleFunc("/memories/{id}", system.Wrapper(apiServer.deleteMemory)).Methods(http.MethodDelete)
+
 	authRouter.HandleFunc("/apps", system.Wrapper(apiServer.listApps)).Methods(http.MethodGet)
 	authRouter.HandleFunc("/apps", system.Wrapper(apiServer.createApp)).Methods(http.MethodPost)
 	authRouter.HandleFunc("/apps/{id}", system.Wrapper(apiServer.getApp)).Methods(http.MethodGet)
@@ -316,6 +357,23 @@ func (apiServer *HelixAPIServer) registerRoutes(_ context.Context) (*mux.Router,
 	authRouter.HandleFunc("/apps/github/{id}", system.Wrapper(apiServer.updateGithubApp)).Methods(http.MethodPut)
 	authRouter.HandleFunc("/apps/{id}", system.Wrapper(apiServer.deleteApp)).Methods(http.MethodDelete)
 	authRouter.HandleFunc("/apps/{id}/llm-calls", system.Wrapper(apiServer.listAppLLMCalls)).Methods(http.MethodGet)
+	authRouter.HandleFunc("/apps/{id}/guardrail-outcomes", system.Wrapper(apiServer.listAppGuardrailOutcomes)).Methods(http.MethodGet)
+	authRouter.HandleFunc("/apps/{id}/revisions", system.Wrapper(apiServer.listAppRevisions)).Methods(http.MethodGet)
+	authRouter.HandleFunc("/apps/{id}/revisions/diff", system.Wrapper(apiServer.diffAppRevisions)).Methods(http.MethodGet)
+	authRouter.HandleFunc("/apps/{id}/revisions/{revision:[0-9]+}", system.Wrapper(apiServer.getAppRevision)).Methods(http.MethodGet)
+	authRouter.HandleFunc("/apps/{id}/revisions/{revision:[0-9]+}/rollback", system.Wrapper(apiServer.rollbackApp)).Methods(http.MethodPost)
+	authRouter.HandleFunc("/apps/{id}/environments", system.Wrapper(apiServer.listAppEnvironments)).Methods(http.MethodGet)
+	authRouter.HandleFunc("/apps/{id}/environments/{environment}", system.Wrapper(apiServer.updateAppEnvironment)).Methods(http.MethodPut)
+	authRouter.HandleFunc("/apps/{id}/environments/{environment}", system.Wrapper(apiServer.deleteAppEnvironment)).Methods(http.MethodDelete)
+	authRouter.HandleFunc("/apps/{id}/environments/{environment}/promote", system.Wrapper(apiServer.promoteAppEnvironment)).Methods(http.MethodPost)
+	authRouter.HandleFunc("/apps/{id}/experiments/{experiment}/report", system.Wrapper(apiServer.getExperimentReport)).Methods(http.MethodGet)
+	authRouter.HandleFunc("/apps/{id}/trigger-runs", system.Wrapper(apiServer.listTriggerRuns)).Methods(http.MethodGet)
+	authRouter.HandleFunc("/apps/{id}/trigger-runs/{run_id}", system.Wrapper(apiServer.getTriggerRun)).Methods(http.MethodGet)
+	authRouter.HandleFunc("/apps/{id}/workflows/{workflow}/runs", system.Wrapper(apiServer.runWorkflow)).Methods(http.MethodPost)
+	authRouter.HandleFunc("/apps/{id}/workflow-runs", system.Wrapper(apiServer.listWorkflowRuns)).Methods(http.MethodGet)
+	authRouter.HandleFunc("/apps/{id}/workflow-runs/{run_id}", system.Wrapper(apiServer.getWorkflowRun)).Methods(http.MethodGet)
+	authRouter.HandleFunc("/apps/{id}/workflow-runs/{run_id}/resume", system.Wrapper(apiServer.resumeWorkflowRun)).Methods(http.MethodPost)
+	authRouter.HandleFunc("/apps/{id}/webhooks", system.Wrapper(apiServer.listAppWebhooks)).Methods(http.MethodGet)
 	authRouter.HandleFunc("/apps/{id}/api-actions", system.Wrapper(apiServer.appRunAPIAction)).Methods(http.MethodPost)
 
 	authRouter.HandleFunc("/search", system.Wrapper(apiServer.knowledgeSearch)).Methods(http.MethodGet)
@@ -331,8 +389,16 @@ func (apiServer *HelixAPIServer) registerRoutes(_ context.Context) (*mux.Router,
 	// and can auto-connect to this endpoint
 	// we handle CORs by loading the app from the token.app_id and it knowing which domains are allowed
 	authRouter.HandleFunc("/apps/script", system.Wrapper(apiServer.appRunScript)).Methods(http.MethodPost, http.MethodOptions)
+
+	// Model Context Protocol, for the app of the API key
+	authRouter.HandleFunc("/mcp", apiServer.mcpStreamableHTTP).Methods(http.MethodPost)
+	authRouter.HandleFunc("/mcp/sse", apiServer.mcpSSE).Methods(http.MethodGet)
+	authRouter.HandleFunc("/mcp/message", apiServer.mcpMessage).Methods(http.MethodPost)
 	adminRouter.HandleFunc("/dashboard", system.DefaultWrapper(apiServer.dashboard)).Methods(http.MethodGet)
 	adminRouter.HandleFunc("/llm_calls", system.Wrapper(apiServer.listLLMCalls)).Methods(http.MethodGet)
+	adminRouter.HandleFunc("/runners/{id}/cordon", system.Wrapper(apiServer.cordonRunner)).Methods(http.MethodPost)
+	adminRouter.HandleFunc("/runners/{id}/drain", system.Wrapper(apiServer.drainRunner)).Methods(http.MethodPost)
+	adminRouter.HandleFunc("/runners/{id}/uncordon", system.Wrapper(apiServer.uncordonRunner)).Methods(http.MethodPost)
 
 	// all these routes are secured via runner tokens
 	runnerRouter.HandleFunc("/runner/{runnerid}/nextsession", system.DefaultWrapper(apiServer.getNextRunnerSession)).Methods(http.MethodGet)
@@ -346,6 +412,7 @@ func (apiServer *HelixAPIServer) registerRoutes(_ context.Context) (*mux.Router,
 	runnerRouter.HandleFunc("/runner/{runnerid}/llm-inference-request", system.DefaultWrapper(apiServer.runnerLLMInferenceRequestHandler)).Methods(http.MethodGet)
 
 	runnerRouter.HandleFunc("/runner/{runnerid}/slots", system.DefaultWrapper(apiServer.getDesiredRunnerSlots)).Methods(http.MethodGet)
+	runnerRouter.HandleFunc("/runner/{runnerid}/drain", system.DefaultWrapper(apiServer.drainSelf)).Methods(http.MethodPost)
 
 	// register pprof routes
 	router.PathPrefix("/debug/pprof/").Handler(http.DefaultServeMux)
@@ -70,11 +70,15 @@ func (s *HelixAPIServer) startChatSessionHandler(rw http.ResponseWriter, req *ht
 		startReq.AssistantID = assistantID
 	}
 
+	// the revision of the app that serves this request
+	var app *types.App
+
 	// if the app specifies a model, override startReq.Model so that we display
 	// the correct model in the UI (and some things may rely on it)
 	if startReq.AppID != "" {
 		// load the app
-		app, err := s.Store.GetAppWithTools(req.Context(), startReq.AppID)
+		var err error
+		app, err = s.Controller.LoadApp(req.Context(), user, startReq.AppID)
 		if err != nil {
 			log.Error().Err(err).Str("app_id", startReq.AppID).Msg("Failed to load app")
 			http.Error(rw, "Failed to load app: "+err.Error(), http.StatusInternalServerError)
@@ -186,6 +190,18 @@ func (s *HelixAPIServer) startChatSessionHandler(rw http.ResponseWriter, req *ht
 		}
 	}
 
+	// Sessions point at the latest revision of the app that answered in them, and at the
+	// experiment variant that the user is assigned to
+	if app != nil && app.ID == startReq.AppID {
+		session.Metadata.AppRevision = app.Revision
+
+		experiment, variant := data.AssignExperimentVariant(app, startReq.AssistantID, user.ID)
+		if variant != nil {
+			session.Metadata.Experiment = experiment.Name
+			session.Metadata.ExperimentVariant = variant.ID
+		}
+	}
+
 	session.Interactions = append(session.Interactions,
 		&types.Interaction{
 			ID:        system.GenerateUUID(),
@@ -277,13 +293,14 @@ func (s *HelixAPIServer) startChatSessionHandler(rw http.ResponseWriter, req *ht
 		}
 	)
 
-	// Convert interactions (except the last one) to messages
-	for _, interaction := range session.Interactions[:len(session.Interactions)-1] {
-		chatCompletionRequest.Messages = append(chatCompletionRequest.Messages, openai.ChatCompletionMessage{
-			Role:    string(interaction.Creator),
-			Content: interaction.Message,
-		})
+	// Convert interactions (except the last one) to messages, the conversation summary is saved
+	// with the session once it's answered
+	messages, err := s.Controller.SessionMessages(ctx, user, session, len(session.Interactions)-1, modelName, options)
+	if err != nil {
+		http.Error(rw, "failed to get session messages: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
+	chatCompletionRequest.Messages = append(chatCompletionRequest.Messages, messages...)
 
 	if !startReq.Stream {
 		err := s.handleBlockingSession(ctx, user, session, chatCompletionRequest, options, rw)
@@ -348,11 +365,10 @@ func (s *HelixAPIServer) restartChatSessionHandler(rw http.ResponseWriter, req *
 			QueryParams: session.Metadata.AppQueryParams,
 		}
 	)
-	for _, interaction := range session.Interactions[:len(session.Interactions)-1] {
-		chatCompletionRequest.Messages = append(chatCompletionRequest.Messages, openai.ChatCompletionMessage{
-			Role:    string(interaction.Creator),
-			Content: interaction.Message,
-		})
+	chatCompletionRequest.Messages, err = s.Controller.SessionMessages(ctx, user, session, len(session.Interactions)-1, modelName, options)
+	if err != nil {
+		http.Error(rw, "failed to get session messages: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
 
 	ctx = oai.SetContextAppID(ctx, session.ParentApp)
@@ -475,7 +491,7 @@ func (s *HelixAPIServer) handleBlockingSession(
 			return fmt.Errorf("error writing session: %w", writeErr)
 		}
 
-		http.Error(rw, fmt.Sprintf("error running LLM: %s", err.Error()), http.StatusInternalServerError)
+		writeChatCompletionError(rw, fmt.Errorf("error running LLM: %w", err))
 		return nil
 	}
 
@@ -494,6 +510,8 @@ func (s *HelixAPIServer) handleBlockingSession(
 		return err
 	}
 
+	s.extractMemories(ctx, user, chatCompletionRequest, chatCompletionResponse.Choices[0].Message.Content, options)
+
 	chatCompletionResponse.ID = session.ID
 
 	rw.Header().Set("Content-Type", "application/json")
@@ -541,7 +559,7 @@ func (s *HelixAPIServer) handleStreamingSession(ctx context.Context, user *types
 			log.Error().Err(err).Msg("failed to write session")
 		}
 
-		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		writeChatCompletionError(rw, err)
 		return nil
 	}
 	defer stream.Close()
@@ -584,5 +602,11 @@ func (s *HelixAPIServer) handleStreamingSession(ctx context.Context, user *types
 	session.Interactions[len(session.Interactions)-1].State = types.InteractionStateComplete
 	session.Interactions[len(session.Interactions)-1].Finished = true
 
-	return s.Controller.WriteSession(ctx, session)
+	if err := s.Controller.WriteSession(ctx, session); err != nil {
+		return err
+	}
+
+	s.extractMemories(ctx, user, chatCompletionRequest, fullResponse, options)
+
+	return nil
 }
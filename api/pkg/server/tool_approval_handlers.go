@@ -0,0 +1,99 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/helixml/helix/api/pkg/controller"
+	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/system"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+// listToolApprovals godoc
+// @Summary List tool action approvals
+// @Description List the user's tool actions that are waiting for approval or have been decided, newest first.
+// @Tags    tools
+
+// @Success 200 {array} types.ToolApproval
+// @Param session_id query string false "Session ID"
+// @Param app_id query string false "App ID"
+// @Param state query string false "pending, approved, denied or expired"
+// @Router /api/v1/tool_approvals [get]
+// @Security BearerAuth
+func (apiServer *HelixAPIServer) listToolApprovals(_ http.ResponseWriter, r *http.Request) ([]*types.ToolApproval, *system.HTTPError) {
+	user := getRequestUser(r)
+	q := r.URL.Query()
+
+	approvals, err := apiServer.Store.ListToolApprovals(r.Context(), &store.ListToolApprovalsQuery{
+		Owner:     user.ID,
+		AppID:     q.Get("app_id"),
+		SessionID: q.Get("session_id"),
+		State:     types.ToolApprovalState(q.Get("state")),
+	})
+	if err != nil {
+		return nil, system.NewHTTPError500(err.Error())
+	}
+
+	return approvals, nil
+}
+
+// getToolApproval godoc
+// @Summary Get a tool action approval
+// @Description Get the request a tool action will make, or made, and its decision.
+// @Tags    tools
+
+// @Success 200 {object} types.ToolApproval
+// @Param id path string true "Approval ID"
+// @Router /api/v1/tool_approvals/{id} [get]
+// @Security BearerAuth
+func (apiServer *HelixAPIServer) getToolApproval(_ http.ResponseWriter, r *http.Request) (*types.ToolApproval, *system.HTTPError) {
+	user := getRequestUser(r)
+
+	approval, err := apiServer.Store.GetToolApproval(r.Context(), getID(r))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, system.NewHTTPError404(store.ErrNotFound.Error())
+		}
+		return nil, system.NewHTTPError500(err.Error())
+	}
+
+	if approval.Owner != user.ID {
+		return nil, system.NewHTTPError404(store.ErrNotFound.Error())
+	}
+
+	return approval, nil
+}
+
+// decideToolApproval godoc
+// @Summary Approve or deny a tool action
+// @Description Approve or deny a pending tool action. Approved actions run straight away and the result is returned and added to the session.
+// @Tags    tools
+
+// @Success 200 {object} types.ToolApproval
+// @Param id path string true "Approval ID"
+// @Param request body types.ToolApprovalDecision true "Decision"
+// @Router /api/v1/tool_approvals/{id}/decision [post]
+// @Security BearerAuth
+func (apiServer *HelixAPIServer) decideToolApproval(_ http.ResponseWriter, r *http.Request) (*types.ToolApproval, *system.HTTPError) {
+	user := getRequestUser(r)
+
+	var decision types.ToolApprovalDecision
+	if err := json.NewDecoder(r.Body).Decode(&decision); err != nil {
+		return nil, system.NewHTTPError400("failed to decode request body: " + err.Error())
+	}
+
+	approval, err := apiServer.Controller.DecideToolApproval(r.Context(), user, getID(r), &decision)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return nil, system.NewHTTPError404(store.ErrNotFound.Error())
+		case errors.Is(err, controller.ErrToolApprovalDecided), errors.Is(err, controller.ErrToolApprovalExpired):
+			return nil, system.NewHTTPError409(err.Error())
+		}
+		return nil, system.NewHTTPError500(err.Error())
+	}
+
+	return approval, nil
+}
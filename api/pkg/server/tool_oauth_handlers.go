@@ -0,0 +1,281 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/system"
+	"github.com/helixml/helix/api/pkg/tools"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+// toolOAuthNonceCookie ties the authorization flow to the browser that started it
+const toolOAuthNonceCookie = "helix_tool_oauth_nonce"
+
+func (apiServer *HelixAPIServer) toolOAuthRedirectURL() string {
+	return apiServer.Cfg.WebServer.URL + APIPrefix + "/tools/oauth/callback"
+}
+
+// toolOAuthNonce returns the browser's nonce for linking accounts, reusing the one in its cookie so
+// that links for several tools can be open at once, and sets the cookie for another state TTL
+func (apiServer *HelixAPIServer) toolOAuthNonce(w http.ResponseWriter, req *http.Request) (string, error) {
+	var nonce string
+	if cookie, err := req.Cookie(toolOAuthNonceCookie); err == nil && cookie.Value != "" {
+		nonce = cookie.Value
+	} else {
+		bts := make([]byte, 32)
+		if _, err := rand.Read(bts); err != nil {
+			return "", fmt.Errorf("failed to generate nonce: %w", err)
+		}
+		nonce = hex.EncodeToString(bts)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     toolOAuthNonceCookie,
+		Value:    nonce,
+		Path:     APIPrefix + "/tools/oauth",
+		MaxAge:   int(tools.OAuthStateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(apiServer.Cfg.WebServer.URL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return nonce, nil
+}
+
+// validToolOAuthPageURL checks that the page the user goes back to after linking their account is
+// part of Helix, either a path or a URL on the web server's origin
+func (apiServer *HelixAPIServer) validToolOAuthPageURL(pageURL string) bool {
+	if pageURL == "" {
+		return true
+	}
+
+	// Browsers treat backslashes as slashes, so "/\example.com" would go to another host
+	if strings.Contains(pageURL, "\\") {
+		return false
+	}
+
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return false
+	}
+
+	if u.Scheme == "" && u.Host == "" {
+		return strings.HasPrefix(pageURL, "/") && !strings.HasPrefix(pageURL, "//")
+	}
+
+	base, err := url.Parse(apiServer.Cfg.WebServer.URL)
+	if err != nil {
+		return false
+	}
+	return u.Scheme == base.Scheme && u.Host == base.Host
+}
+
+// getToolOAuth loads an API tool that acts on behalf of users, either from an app (by tool name)
+// or a standalone tool (by ID), and resolves its OAuth2 config with the user's secrets. It also
+// returns what the user's account is linked for, see tools.OAuthLinkedFor.
+func (apiServer *HelixAPIServer) getToolOAuth(ctx context.Context, user *types.User, appID, toolName, toolID string) (*types.ToolAPIAuth, string, *system.HTTPError) {
+	var tool *types.Tool
+
+	switch {
+	case appID != "":
+		app, err := apiServer.Store.GetAppWithTools(ctx, appID)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return nil, "", system.NewHTTPError404("app not found")
+			}
+			return nil, "", system.NewHTTPError500(err.Error())
+		}
+
+		if (!app.Global && !app.Shared) && app.Owner != user.ID {
+			return nil, "", system.NewHTTPError403("you do not have access to this app")
+		}
+
+		app, err = apiServer.Controller.EvaluateSecrets(ctx, user, app)
+		if err != nil {
+			return nil, "", system.NewHTTPError500(err.Error())
+		}
+
+		for _, assistant := range app.Config.Helix.Assistants {
+			for _, t := range assistant.Tools {
+				if t.ToolType == types.ToolTypeAPI && t.Name == toolName {
+					tool = t
+				}
+			}
+		}
+		if tool == nil {
+			return nil, "", system.NewHTTPError404(fmt.Sprintf("tool %s not found in app", toolName))
+		}
+	case toolID != "":
+		t, err := apiServer.Store.GetTool(ctx, toolID)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return nil, "", system.NewHTTPError404("tool not found")
+			}
+			return nil, "", system.NewHTTPError500(err.Error())
+		}
+		if !t.Global && t.Owner != user.ID {
+			return nil, "", system.NewHTTPError403("you do not have access to this tool")
+		}
+		tool = t
+	default:
+		return nil, "", system.NewHTTPError400("app_id and tool, or tool_id are required")
+	}
+
+	auth, err := tools.ResolveAuth(tool)
+	if err != nil {
+		return nil, "", system.NewHTTPError400(fmt.Sprintf("invalid auth config: %s", err))
+	}
+	if auth == nil || auth.Type != types.ToolAPIAuthTypeOAuth2User {
+		return nil, "", system.NewHTTPError400(fmt.Sprintf("tool %s does not act on behalf of users", tool.Name))
+	}
+
+	linkedFor := appID
+	if linkedFor == "" {
+		linkedFor = tool.ID
+	}
+
+	return auth, linkedFor, nil
+}
+
+// toolOAuthStatus godoc
+// @Summary Get whether the user has linked their account for an API tool
+// @Description Returns whether the user has linked their account with the tool's OAuth2 provider, and if not, where to send them to do so.
+// @Tags    tools
+
+// @Success 200 {object} types.ToolOAuthStatus
+// @Param app_id query string false "App ID"
+// @Param tool query string false "Tool name within the app"
+// @Param tool_id query string false "Tool ID, for tools that aren't part of an app"
+// @Param pageURL query string false "Page in Helix to return the user to after linking, a path or a URL on the web server"
+// @Router /api/v1/tools/oauth/status [get]
+// @Security BearerAuth
+func (apiServer *HelixAPIServer) toolOAuthStatus(w http.ResponseWriter, req *http.Request) (*types.ToolOAuthStatus, *system.HTTPError) {
+	ctx := req.Context()
+	user := getRequestUser(req)
+	q := req.URL.Query()
+
+	auth, linkedFor, httpErr := apiServer.getToolOAuth(ctx, user, q.Get("app_id"), q.Get("tool"), q.Get("tool_id"))
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	if !apiServer.validToolOAuthPageURL(q.Get("pageURL")) {
+		return nil, system.NewHTTPError400("pageURL must be a page in Helix")
+	}
+
+	connected, err := apiServer.toolOAuth.Connected(ctx, user.ID, linkedFor, auth)
+	if err != nil {
+		return nil, system.NewHTTPError500(err.Error())
+	}
+	if connected {
+		return &types.ToolOAuthStatus{Connected: true}, nil
+	}
+
+	nonce, err := apiServer.toolOAuthNonce(w, req)
+	if err != nil {
+		return nil, system.NewHTTPError500(err.Error())
+	}
+
+	state, err := apiServer.toolOAuth.EncodeState(&tools.OAuthState{
+		UserID:   user.ID,
+		UserType: user.Type,
+		AppID:    q.Get("app_id"),
+		ToolName: q.Get("tool"),
+		ToolID:   q.Get("tool_id"),
+		PageURL:  q.Get("pageURL"),
+		Nonce:    nonce,
+	})
+	if err != nil {
+		return nil, system.NewHTTPError500(err.Error())
+	}
+
+	return &types.ToolOAuthStatus{
+		RedirectURL: apiServer.toolOAuth.AuthCodeURL(auth, state, apiServer.toolOAuthRedirectURL()),
+	}, nil
+}
+
+// toolOAuthDisconnect godoc
+// @Summary Unlink the user's account for an API tool
+// @Description Forgets the user's OAuth2 token for the tool's provider.
+// @Tags    tools
+
+// @Success 200 {object} types.ToolOAuthStatus
+// @Param app_id query string false "App ID"
+// @Param tool query string false "Tool name within the app"
+// @Param tool_id query string false "Tool ID, for tools that aren't part of an app"
+// @Router /api/v1/tools/oauth [delete]
+// @Security BearerAuth
+func (apiServer *HelixAPIServer) toolOAuthDisconnect(_ http.ResponseWriter, req *http.Request) (*types.ToolOAuthStatus, *system.HTTPError) {
+	ctx := req.Context()
+	user := getRequestUser(req)
+	q := req.URL.Query()
+
+	auth, linkedFor, httpErr := apiServer.getToolOAuth(ctx, user, q.Get("app_id"), q.Get("tool"), q.Get("tool_id"))
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	if err := apiServer.toolOAuth.Disconnect(ctx, user.ID, linkedFor, auth); err != nil {
+		return nil, system.NewHTTPError500(err.Error())
+	}
+
+	return &types.ToolOAuthStatus{Connected: false}, nil
+}
+
+// toolOAuthCallback is where the provider sends the user back to after they authorize Helix. The
+// state says who they are, the nonce cookie that they were the one who asked.
+func (apiServer *HelixAPIServer) toolOAuthCallback(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	q := req.URL.Query()
+
+	if errCode := q.Get("error"); errCode != "" {
+		http.Error(w, fmt.Sprintf("authorization failed: %s %s", errCode, q.Get("error_description")), http.StatusBadRequest)
+		return
+	}
+
+	state, err := apiServer.toolOAuth.DecodeState(q.Get("state"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Only the browser that asked for the link can complete it, otherwise someone could send their
+	// link to another user and have that user's token stored under their own account
+	cookie, err := req.Cookie(toolOAuthNonceCookie)
+	if err != nil || state.Nonce == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state.Nonce)) != 1 {
+		http.Error(w, "this link was made for another browser, connect your account from Helix again", http.StatusForbidden)
+		return
+	}
+
+	user := &types.User{ID: state.UserID, Type: state.UserType}
+
+	auth, linkedFor, httpErr := apiServer.getToolOAuth(ctx, user, state.AppID, state.ToolName, state.ToolID)
+	if httpErr != nil {
+		http.Error(w, httpErr.Error(), httpErr.StatusCode)
+		return
+	}
+
+	err = apiServer.toolOAuth.Exchange(ctx, user, linkedFor, auth, q.Get("code"), apiServer.toolOAuthRedirectURL())
+	if err != nil {
+		log.Error().Err(err).Str("user_id", user.ID).Msg("failed to link tool account")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if state.PageURL == "" || !apiServer.validToolOAuthPageURL(state.PageURL) {
+		_, _ = w.Write([]byte("Account connected, you can close this page."))
+		return
+	}
+	http.Redirect(w, req, state.PageURL, http.StatusFound)
+}
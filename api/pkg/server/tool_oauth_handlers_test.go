@@ -0,0 +1,196 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/helixml/helix/api/pkg/config"
+	"github.com/helixml/helix/api/pkg/controller"
+	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/system"
+	"github.com/helixml/helix/api/pkg/tools"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+const toolOAuthTestSpec = `openapi: 3.0.0
+info:
+  title: Contacts
+  version: "1"
+paths:
+  /contacts:
+    get:
+      operationId: listContacts
+      responses:
+        "200":
+          description: OK
+`
+
+func newToolOAuthTestServer(t *testing.T, user types.User) (*httptest.Server, *store.MockStore) {
+	ctrl := gomock.NewController(t)
+	st := store.NewMockStore(ctrl)
+
+	tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token": "token-1", "token_type": "Bearer", "expires_in": 3600}`))
+	}))
+	t.Cleanup(tokens.Close)
+
+	toolOAuth, err := tools.NewOAuthManager(st, tokens.Client(), "encryption-key")
+	require.NoError(t, err)
+
+	apiServer := &HelixAPIServer{
+		Cfg:   &config.ServerConfig{},
+		Store: st,
+		Controller: &controller.Controller{
+			Options: controller.Options{Store: st},
+		},
+		toolOAuth: toolOAuth,
+	}
+
+	router := mux.NewRouter()
+	authRouter := router.PathPrefix(APIPrefix).Subrouter()
+	authRouter.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(setRequestUser(r.Context(), user)))
+		})
+	})
+	authRouter.HandleFunc("/tools/oauth/status", system.Wrapper(apiServer.toolOAuthStatus)).Methods(http.MethodGet)
+	router.HandleFunc(APIPrefix+"/tools/oauth/callback", apiServer.toolOAuthCallback).Methods(http.MethodGet)
+
+	ts := httptest.NewServer(router)
+	t.Cleanup(ts.Close)
+	apiServer.Cfg.WebServer.URL = ts.URL
+
+	st.EXPECT().GetTool(gomock.Any(), "tool_1").Return(&types.Tool{
+		ID:       "tool_1",
+		Name:     "contacts",
+		Owner:    user.ID,
+		ToolType: types.ToolTypeAPI,
+		Config: types.ToolConfig{
+			API: &types.ToolAPIConfig{
+				URL:    "https://contacts.example.com",
+				Schema: toolOAuthTestSpec,
+				Auth: &types.ToolAPIAuth{
+					Type:     types.ToolAPIAuthTypeOAuth2User,
+					ClientID: "helix",
+					AuthURL:  "https://auth.example.com/authorize",
+					TokenURL: tokens.URL + "/token",
+				},
+			},
+		},
+	}, nil).AnyTimes()
+
+	return ts, st
+}
+
+// linkToolAccount asks for the link to the provider and returns the state it carries
+func linkToolAccount(t *testing.T, client *http.Client, ts *httptest.Server) string {
+	resp, err := client.Get(ts.URL + "/api/v1/tools/oauth/status?tool_id=tool_1")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var status types.ToolOAuthStatus
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&status))
+	require.False(t, status.Connected)
+
+	redirectURL, err := url.Parse(status.RedirectURL)
+	require.NoError(t, err)
+	return redirectURL.Query().Get("state")
+}
+
+func callToolOAuthCallback(t *testing.T, client *http.Client, ts *httptest.Server, state string) (int, string) {
+	resp, err := client.Get(ts.URL + "/api/v1/tools/oauth/callback?code=code-1&state=" + url.QueryEscape(state))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(body)
+}
+
+func TestToolOAuthCallback_SameBrowser(t *testing.T) {
+	ts, st := newToolOAuthTestServer(t, types.User{ID: "user_1"})
+
+	st.EXPECT().GetToolOAuthToken(gomock.Any(), gomock.Any()).Return(nil, store.ErrNotFound)
+	st.EXPECT().UpsertToolOAuthToken(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, token *types.ToolOAuthToken) (*types.ToolOAuthToken, error) {
+			assert.Equal(t, "user_1", token.Owner)
+			assert.Equal(t, "tool_1", token.LinkedFor)
+			return token, nil
+		})
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	browser := &http.Client{Jar: jar}
+
+	state := linkToolAccount(t, browser, ts)
+
+	code, body := callToolOAuthCallback(t, browser, ts, state)
+	assert.Equal(t, http.StatusOK, code, body)
+}
+
+func TestToolOAuthCallback_OtherBrowser(t *testing.T) {
+	ts, st := newToolOAuthTestServer(t, types.User{ID: "attacker"})
+
+	st.EXPECT().GetToolOAuthToken(gomock.Any(), gomock.Any()).Return(nil, store.ErrNotFound)
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	state := linkToolAccount(t, &http.Client{Jar: jar}, ts)
+
+	// The victim's browser has no nonce cookie
+	code, _ := callToolOAuthCallback(t, http.DefaultClient, ts, state)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	// Or one of its own
+	victimJar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	tsURL, err := url.Parse(ts.URL + "/api/v1/tools/oauth")
+	require.NoError(t, err)
+	victimJar.SetCookies(tsURL, []*http.Cookie{{Name: toolOAuthNonceCookie, Value: "victim-nonce", Path: "/api/v1/tools/oauth"}})
+
+	code, _ = callToolOAuthCallback(t, &http.Client{Jar: victimJar}, ts, state)
+	assert.Equal(t, http.StatusForbidden, code)
+}
+
+func TestValidToolOAuthPageURL(t *testing.T) {
+	apiServer := &HelixAPIServer{Cfg: &config.ServerConfig{}}
+	apiServer.Cfg.WebServer.URL = "https://helix.example.com"
+
+	for pageURL, valid := range map[string]bool{
+		"":                                    true,
+		"/app/app_1":                          true,
+		"https://helix.example.com/session/1": true,
+		"https://evil.example.com/":           false,
+		"http://helix.example.com/":           false,
+		"//evil.example.com":                  false,
+		"/\\evil.example.com":                 false,
+		"https:evil.example.com":              false,
+		"javascript:alert(1)":                 false,
+		"app/app_1":                           false,
+	} {
+		assert.Equal(t, valid, apiServer.validToolOAuthPageURL(pageURL), pageURL)
+	}
+}
+
+func TestToolOAuthStatus_RejectsOtherPages(t *testing.T) {
+	ts, _ := newToolOAuthTestServer(t, types.User{ID: "user_1"})
+
+	resp, err := http.Get(ts.URL + "/api/v1/tools/oauth/status?tool_id=tool_1&pageURL=" + url.QueryEscape("https://evil.example.com/"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
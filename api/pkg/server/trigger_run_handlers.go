@@ -0,0 +1,95 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/system"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+// listTriggerRuns godoc
+// @Summary List trigger runs
+// @Description List the app's trigger runs, newest first
+// @Tags    apps
+
+// @Success 200 {array} types.TriggerRun
+// @Param id path string true "App ID"
+// @Param type query string false "Trigger type, e.g. cron"
+// @Param limit query int false "Number of runs to return, defaults to 100"
+// @Router /api/v1/apps/{id}/trigger-runs [get]
+// @Security BearerAuth
+func (s *HelixAPIServer) listTriggerRuns(_ http.ResponseWriter, r *http.Request) ([]*types.TriggerRun, *system.HTTPError) {
+	app, httpErr := s.getOwnedApp(r)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	q := &store.ListTriggerRunsQuery{
+		AppID: app.ID,
+		Type:  types.TriggerType(r.URL.Query().Get("type")),
+	}
+
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		var err error
+		q.Limit, err = strconv.Atoi(limit)
+		if err != nil || q.Limit < 0 {
+			return nil, system.NewHTTPError400("invalid limit")
+		}
+	}
+
+	runs, err := s.Store.ListTriggerRuns(r.Context(), q)
+	if err != nil {
+		return nil, system.NewHTTPError500(err.Error())
+	}
+
+	return runs, nil
+}
+
+// getTriggerRun godoc
+// @Summary Get a trigger run
+// @Description Get the trigger run along with the LLM calls it made
+// @Tags    apps
+
+// @Success 200 {object} types.TriggerRun
+// @Param id path string true "App ID"
+// @Param run_id path string true "Trigger run ID"
+// @Router /api/v1/apps/{id}/trigger-runs/{run_id} [get]
+// @Security BearerAuth
+func (s *HelixAPIServer) getTriggerRun(_ http.ResponseWriter, r *http.Request) (*types.TriggerRun, *system.HTTPError) {
+	app, httpErr := s.getOwnedApp(r)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	run, err := s.Store.GetTriggerRun(r.Context(), mux.Vars(r)["run_id"])
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, system.NewHTTPError404("trigger run not found")
+		}
+		return nil, system.NewHTTPError500(err.Error())
+	}
+
+	if run.AppID != app.ID {
+		return nil, system.NewHTTPError404("trigger run not found")
+	}
+
+	if run.SessionID != "" {
+		calls, _, err := s.Store.ListLLMCalls(r.Context(), &store.ListLLMCallsQuery{
+			AppID:         app.ID,
+			SessionFilter: run.SessionID,
+			Page:          1,
+			PerPage:       100,
+		})
+		if err != nil {
+			return nil, system.NewHTTPError500(err.Error())
+		}
+		run.LLMCalls = calls
+	}
+
+	return run, nil
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+func TestTriggerRuns_List(t *testing.T) {
+	ts, st := newAppManagementTestServer(t, types.User{ID: "user_1"})
+
+	st.EXPECT().GetApp(gomock.Any(), "app_1").Return(&types.App{ID: "app_1", Owner: "user_1"}, nil)
+	st.EXPECT().ListTriggerRuns(gomock.Any(), &store.ListTriggerRunsQuery{
+		AppID: "app_1",
+		Type:  types.TriggerTypeCron,
+		Limit: 5,
+	}).Return([]*types.TriggerRun{
+		{ID: "trun_2", AppID: "app_1", Status: types.TriggerRunStatusFailed},
+		{ID: "trun_1", AppID: "app_1", Status: types.TriggerRunStatusSuccess},
+	}, nil)
+
+	resp, err := http.Get(ts.URL + "/api/v1/apps/app_1/trigger-runs?type=cron&limit=5")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var runs []*types.TriggerRun
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&runs))
+	require.Len(t, runs, 2)
+	assert.Equal(t, "trun_2", runs[0].ID)
+}
+
+func TestTriggerRuns_GetIncludesLLMCalls(t *testing.T) {
+	ts, st := newAppManagementTestServer(t, types.User{ID: "user_1"})
+
+	st.EXPECT().GetApp(gomock.Any(), "app_1").Return(&types.App{ID: "app_1", Owner: "user_1"}, nil)
+	st.EXPECT().GetTriggerRun(gomock.Any(), "trun_1").Return(&types.TriggerRun{
+		ID: "trun_1", AppID: "app_1", SessionID: "ses_1", Output: "Not much",
+	}, nil)
+	st.EXPECT().ListLLMCalls(gomock.Any(), &store.ListLLMCallsQuery{
+		AppID:         "app_1",
+		SessionFilter: "ses_1",
+		Page:          1,
+		PerPage:       100,
+	}).Return([]*types.LLMCall{{ID: "llm_1", SessionID: "ses_1"}}, int64(1), nil)
+
+	resp, err := http.Get(ts.URL + "/api/v1/apps/app_1/trigger-runs/trun_1")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var run types.TriggerRun
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&run))
+	assert.Equal(t, "Not much", run.Output)
+	require.Len(t, run.LLMCalls, 1)
+	assert.Equal(t, "llm_1", run.LLMCalls[0].ID)
+}
+
+func TestTriggerRuns_GetOtherAppsRun(t *testing.T) {
+	ts, st := newAppManagementTestServer(t, types.User{ID: "user_1"})
+
+	st.EXPECT().GetApp(gomock.Any(), "app_1").Return(&types.App{ID: "app_1", Owner: "user_1"}, nil)
+	st.EXPECT().GetTriggerRun(gomock.Any(), "trun_1").Return(&types.TriggerRun{ID: "trun_1", AppID: "app_2"}, nil)
+
+	resp, err := http.Get(ts.URL + "/api/v1/apps/app_1/trigger-runs/trun_1")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
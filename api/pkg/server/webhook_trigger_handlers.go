@@ -0,0 +1,49 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/helixml/helix/api/pkg/system"
+	"github.com/helixml/helix/api/pkg/trigger/webhook"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+// listAppWebhooks godoc
+// @Summary List app webhook URLs
+// @Description List the signed URLs of the app's webhook triggers. Callers can either use the URL as it is or sign the request body with the trigger's secret.
+// @Tags    apps
+
+// @Success 200 {array} types.WebhookTriggerURL
+// @Param id path string true "App ID"
+// @Router /api/v1/apps/{id}/webhooks [get]
+// @Security BearerAuth
+func (s *HelixAPIServer) listAppWebhooks(_ http.ResponseWriter, r *http.Request) ([]*types.WebhookTriggerURL, *system.HTTPError) {
+	app, httpErr := s.getOwnedApp(r)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	// URLs are signed with the secrets as the webhook sees them
+	app, err := s.Controller.EvaluateSecrets(r.Context(), &types.User{ID: app.Owner}, app)
+	if err != nil {
+		return nil, system.NewHTTPError500(err.Error())
+	}
+
+	// Signing with a secret that isn't set would hand out URLs anyone can sign
+	if err := webhook.ValidateSecrets(app.Config.Helix.Triggers); err != nil {
+		return nil, system.NewHTTPError400(err.Error())
+	}
+
+	urls := []*types.WebhookTriggerURL{}
+	for _, trigger := range app.Config.Helix.Triggers {
+		if trigger.Webhook == nil {
+			continue
+		}
+		urls = append(urls, &types.WebhookTriggerURL{
+			Name: trigger.Webhook.Name,
+			URL:  webhook.SignedURL(s.Cfg.WebServer.URL, app.ID, trigger.Webhook),
+		})
+	}
+
+	return urls, nil
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/helixml/helix/api/pkg/trigger/webhook"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+func TestAppWebhooks_SignedWithEvaluatedSecret(t *testing.T) {
+	ts, st := newAppManagementTestServer(t, types.User{ID: "user_1"})
+
+	app := &types.App{ID: "app_1", Owner: "user_1"}
+	app.Config.Helix.Triggers = []types.Trigger{
+		{Cron: &types.CronTrigger{Schedule: "0 9 * * *"}},
+		{Webhook: &types.WebhookTrigger{Name: "alertmanager", Secret: "${ALERTS_SECRET}"}},
+	}
+
+	st.EXPECT().GetApp(gomock.Any(), "app_1").Return(app, nil)
+	st.EXPECT().ListSecrets(gomock.Any(), gomock.Any()).Return([]*types.Secret{
+		{Name: "ALERTS_SECRET", Value: []byte("s3cret")},
+	}, nil)
+
+	resp, err := http.Get(ts.URL + "/api/v1/apps/app_1/webhooks")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var urls []*types.WebhookTriggerURL
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&urls))
+	require.Len(t, urls, 1)
+	assert.Equal(t, "alertmanager", urls[0].Name)
+	assert.Equal(t, webhook.SignedURL("", "app_1", &types.WebhookTrigger{Name: "alertmanager", Secret: "s3cret"}), urls[0].URL)
+}
+
+func TestAppWebhooks_SecretNotSet(t *testing.T) {
+	ts, st := newAppManagementTestServer(t, types.User{ID: "user_1"})
+
+	app := &types.App{ID: "app_1", Owner: "user_1"}
+	app.Config.Helix.Triggers = []types.Trigger{
+		{Webhook: &types.WebhookTrigger{Name: "alertmanager", Secret: "${ALERTS_SECRET}"}},
+	}
+
+	st.EXPECT().GetApp(gomock.Any(), "app_1").Return(app, nil)
+	st.EXPECT().ListSecrets(gomock.Any(), gomock.Any()).Return(nil, nil)
+
+	resp, err := http.Get(ts.URL + "/api/v1/apps/app_1/webhooks")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
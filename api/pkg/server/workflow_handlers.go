@@ -0,0 +1,167 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/system"
+	"github.com/helixml/helix/api/pkg/types"
+	"github.com/helixml/helix/api/pkg/workflow"
+)
+
+// runWorkflow godoc
+// @Summary Run a workflow
+// @Description Start a run of the app's workflow, the run is returned while it's running unless wait is set
+// @Tags    apps
+
+// @Success 200 {object} types.WorkflowRun
+// @Param id path string true "App ID"
+// @Param workflow path string true "Workflow name"
+// @Param wait query bool false "Wait for the run to finish"
+// @Param request body types.WorkflowRunRequest true "Workflow inputs"
+// @Router /api/v1/apps/{id}/workflows/{workflow}/runs [post]
+// @Security BearerAuth
+func (s *HelixAPIServer) runWorkflow(_ http.ResponseWriter, r *http.Request) (*types.WorkflowRun, *system.HTTPError) {
+	app, httpErr := s.getOwnedApp(r)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	var req types.WorkflowRunRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, system.NewHTTPError400(fmt.Sprintf("failed to decode request body, error: %s", err))
+	}
+
+	name := mux.Vars(r)["workflow"]
+
+	var run *types.WorkflowRun
+	if wait, _ := strconv.ParseBool(r.URL.Query().Get("wait")); wait {
+		run, err = s.workflows.Run(r.Context(), app, name, req.Inputs, nil)
+	} else {
+		run, err = s.workflows.Start(r.Context(), app, name, req.Inputs, nil)
+	}
+	if err != nil {
+		if errors.Is(err, workflow.ErrWorkflowNotFound) {
+			return nil, system.NewHTTPError404(err.Error())
+		}
+		return nil, system.NewHTTPError400(err.Error())
+	}
+
+	return run, nil
+}
+
+// listWorkflowRuns godoc
+// @Summary List workflow runs
+// @Description List the app's workflow runs, newest first
+// @Tags    apps
+
+// @Success 200 {array} types.WorkflowRun
+// @Param id path string true "App ID"
+// @Param workflow query string false "Workflow name"
+// @Param limit query int false "Number of runs to return, defaults to 100"
+// @Router /api/v1/apps/{id}/workflow-runs [get]
+// @Security BearerAuth
+func (s *HelixAPIServer) listWorkflowRuns(_ http.ResponseWriter, r *http.Request) ([]*types.WorkflowRun, *system.HTTPError) {
+	app, httpErr := s.getOwnedApp(r)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	q := &store.ListWorkflowRunsQuery{
+		AppID:    app.ID,
+		Workflow: r.URL.Query().Get("workflow"),
+	}
+
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		var err error
+		q.Limit, err = strconv.Atoi(limit)
+		if err != nil || q.Limit < 0 {
+			return nil, system.NewHTTPError400("invalid limit")
+		}
+	}
+
+	runs, err := s.Store.ListWorkflowRuns(r.Context(), q)
+	if err != nil {
+		return nil, system.NewHTTPError500(err.Error())
+	}
+
+	return runs, nil
+}
+
+// getWorkflowRun godoc
+// @Summary Get a workflow run
+// @Description Get the workflow run with the trace of its steps and the LLM calls it made
+// @Tags    apps
+
+// @Success 200 {object} types.WorkflowRun
+// @Param id path string true "App ID"
+// @Param run_id path string true "Workflow run ID"
+// @Router /api/v1/apps/{id}/workflow-runs/{run_id} [get]
+// @Security BearerAuth
+func (s *HelixAPIServer) getWorkflowRun(_ http.ResponseWriter, r *http.Request) (*types.WorkflowRun, *system.HTTPError) {
+	app, httpErr := s.getOwnedApp(r)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	run, err := s.Store.GetWorkflowRun(r.Context(), mux.Vars(r)["run_id"])
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, system.NewHTTPError404("workflow run not found")
+		}
+		return nil, system.NewHTTPError500(err.Error())
+	}
+
+	if run.AppID != app.ID {
+		return nil, system.NewHTTPError404("workflow run not found")
+	}
+
+	// The steps' LLM calls are logged with the run's ID as their session
+	calls, _, err := s.Store.ListLLMCalls(r.Context(), &store.ListLLMCallsQuery{
+		AppID:         app.ID,
+		SessionFilter: run.ID,
+		Page:          1,
+		PerPage:       100,
+	})
+	if err != nil {
+		return nil, system.NewHTTPError500(err.Error())
+	}
+	run.LLMCalls = calls
+
+	return run, nil
+}
+
+// resumeWorkflowRun godoc
+// @Summary Resume a workflow run
+// @Description Run a failed workflow run again from the steps that didn't finish
+// @Tags    apps
+
+// @Success 200 {object} types.WorkflowRun
+// @Param id path string true "App ID"
+// @Param run_id path string true "Workflow run ID"
+// @Router /api/v1/apps/{id}/workflow-runs/{run_id}/resume [post]
+// @Security BearerAuth
+func (s *HelixAPIServer) resumeWorkflowRun(_ http.ResponseWriter, r *http.Request) (*types.WorkflowRun, *system.HTTPError) {
+	app, httpErr := s.getOwnedApp(r)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	run, err := s.workflows.Resume(r.Context(), app, mux.Vars(r)["run_id"])
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, system.NewHTTPError404("workflow run not found")
+		}
+		return nil, system.NewHTTPError400(err.Error())
+	}
+
+	return run, nil
+}
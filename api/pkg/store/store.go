@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/helixml/helix/api/pkg/types"
 )
@@ -43,6 +44,20 @@ type ListSecretsQuery struct {
 	OwnerType types.OwnerType `json:"owner_type"`
 }
 
+type GetToolOAuthTokenQuery struct {
+	Owner     string `json:"owner"`
+	LinkedFor string `json:"linked_for"`
+	TokenURL  string `json:"token_url"`
+	ClientID  string `json:"client_id"`
+}
+
+type ListToolApprovalsQuery struct {
+	Owner     string                  `json:"owner"`
+	AppID     string                  `json:"app_id"`
+	SessionID string                  `json:"session_id"`
+	State     types.ToolApprovalState `json:"state"`
+}
+
 type ListAppsQuery struct {
 	Owner     string          `json:"owner"`
 	OwnerType types.OwnerType `json:"owner_type"`
@@ -92,6 +107,18 @@ type Store interface {
 	ListSecrets(ctx context.Context, q *ListSecretsQuery) ([]*types.Secret, error)
 	DeleteSecret(ctx context.Context, id string) error
 
+	// per-user OAuth2 tokens for API tools
+	UpsertToolOAuthToken(ctx context.Context, token *types.ToolOAuthToken) (*types.ToolOAuthToken, error)
+	GetToolOAuthToken(ctx context.Context, q *GetToolOAuthTokenQuery) (*types.ToolOAuthToken, error)
+	DeleteToolOAuthToken(ctx context.Context, id string) error
+
+	// approvals of API tool actions, kept as an audit log
+	CreateToolApproval(ctx context.Context, approval *types.ToolApproval) (*types.ToolApproval, error)
+	GetToolApproval(ctx context.Context, id string) (*types.ToolApproval, error)
+	ListToolApprovals(ctx context.Context, q *ListToolApprovalsQuery) ([]*types.ToolApproval, error)
+	DecideToolApproval(ctx context.Context, approval *types.ToolApproval) (*types.ToolApproval, error)
+	UpdateToolApproval(ctx context.Context, approval *types.ToolApproval) (*types.ToolApproval, error)
+
 	CreateSessionToolBinding(ctx context.Context, sessionID, toolID string) error
 	ListSessionTools(ctx context.Context, sessionID string) ([]*types.Tool, error)
 	DeleteSessionToolBinding(ctx context.Context, sessionID, toolID string) error
@@ -101,9 +128,49 @@ type Store interface {
 	UpdateApp(ctx context.Context, tool *types.App) (*types.App, error)
 	GetApp(ctx context.Context, id string) (*types.App, error)
 	GetAppWithTools(ctx context.Context, id string) (*types.App, error)
+	GetAppWithToolsAtRevision(ctx context.Context, id string, revision int) (*types.App, error)
 	ListApps(ctx context.Context, q *ListAppsQuery) ([]*types.App, error)
 	DeleteApp(ctx context.Context, id string) error
 
+	// app revisions
+	ListAppRevisions(ctx context.Context, appID string) ([]*types.AppRevision, error)
+	GetAppRevision(ctx context.Context, appID string, revision int) (*types.AppRevision, error)
+
+	// app environments
+	CreateAppEnvironment(ctx context.Context, environment *types.AppEnvironment) (*types.AppEnvironment, error)
+	UpdateAppEnvironment(ctx context.Context, environment *types.AppEnvironment) (*types.AppEnvironment, error)
+	GetAppEnvironment(ctx context.Context, appID, name string) (*types.AppEnvironment, error)
+	ListAppEnvironments(ctx context.Context, appID string) ([]*types.AppEnvironment, error)
+	DeleteAppEnvironment(ctx context.Context, appID, name string) error
+
+	// experiments
+	GetExperimentReport(ctx context.Context, appID, experiment string) ([]*types.ExperimentVariantReport, error)
+
+	// trigger runs
+	CreateTriggerRun(ctx context.Context, run *types.TriggerRun) (*types.TriggerRun, error)
+	UpdateTriggerRun(ctx context.Context, run *types.TriggerRun) (*types.TriggerRun, error)
+	GetTriggerRun(ctx context.Context, id string) (*types.TriggerRun, error)
+	ListTriggerRuns(ctx context.Context, q *ListTriggerRunsQuery) ([]*types.TriggerRun, error)
+
+	// workflow runs
+	CreateWorkflowRun(ctx context.Context, run *types.WorkflowRun) (*types.WorkflowRun, error)
+	UpdateWorkflowRun(ctx context.Context, run *types.WorkflowRun) (*types.WorkflowRun, error)
+	GetWorkflowRun(ctx context.Context, id string) (*types.WorkflowRun, error)
+	ClaimWorkflowRun(ctx context.Context, id string, staleBefore time.Time) (bool, error)
+	ListWorkflowRuns(ctx context.Context, q *ListWorkflowRunsQuery) ([]*types.WorkflowRun, error)
+
+	// memories
+	CreateMemory(ctx context.Context, memory *types.Memory) (*types.Memory, error)
+	UpdateMemory(ctx context.Context, memory *types.Memory) (*types.Memory, error)
+	GetMemory(ctx context.Context, id string) (*types.Memory, error)
+	ListMemories(ctx context.Context, q *ListMemoriesQuery) ([]*types.Memory, error)
+	DeleteMemory(ctx context.Context, id string) error
+	DeleteMemories(ctx context.Context, q *ListMemoriesQuery) error
+
+	// guardrail outcomes
+	CreateGuardrailOutcome(ctx context.Context, outcome *types.GuardrailOutcome) (*types.GuardrailOutcome, error)
+	ListGuardrailOutcomes(ctx context.Context, q *ListGuardrailOutcomesQuery) ([]*types.GuardrailOutcome, error)
+
 	// data entities
 	CreateDataEntity(ctx context.Context, dataEntity *types.DataEntity) (*types.DataEntity, error)
 	UpdateDataEntity(ctx context.Context, dataEntity *types.DataEntity) (*types.DataEntity, error)
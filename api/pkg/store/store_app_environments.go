@@ -0,0 +1,98 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/helixml/helix/api/pkg/system"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+func (s *PostgresStore) CreateAppEnvironment(ctx context.Context, environment *types.AppEnvironment) (*types.AppEnvironment, error) {
+	if environment.AppID == "" {
+		return nil, fmt.Errorf("app id not specified")
+	}
+
+	if environment.Name == "" {
+		return nil, fmt.Errorf("name not specified")
+	}
+
+	if environment.ID == "" {
+		environment.ID = system.GenerateAppEnvironmentID()
+	}
+
+	environment.Created = time.Now()
+	environment.Updated = time.Now()
+
+	err := s.gdb.WithContext(ctx).Create(environment).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetAppEnvironment(ctx, environment.AppID, environment.Name)
+}
+
+func (s *PostgresStore) UpdateAppEnvironment(ctx context.Context, environment *types.AppEnvironment) (*types.AppEnvironment, error) {
+	if environment.ID == "" {
+		return nil, fmt.Errorf("id not specified")
+	}
+
+	environment.Updated = time.Now()
+
+	err := s.gdb.WithContext(ctx).Save(environment).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetAppEnvironment(ctx, environment.AppID, environment.Name)
+}
+
+func (s *PostgresStore) GetAppEnvironment(ctx context.Context, appID, name string) (*types.AppEnvironment, error) {
+	if appID == "" {
+		return nil, fmt.Errorf("app id not specified")
+	}
+
+	var environment types.AppEnvironment
+	err := s.gdb.WithContext(ctx).
+		Where("app_id = ? AND name = ?", appID, name).
+		First(&environment).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &environment, nil
+}
+
+func (s *PostgresStore) ListAppEnvironments(ctx context.Context, appID string) ([]*types.AppEnvironment, error) {
+	if appID == "" {
+		return nil, fmt.Errorf("app id not specified")
+	}
+
+	var environments []*types.AppEnvironment
+	err := s.gdb.WithContext(ctx).
+		Where("app_id = ?", appID).
+		Order("created ASC").
+		Find(&environments).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return environments, nil
+}
+
+func (s *PostgresStore) DeleteAppEnvironment(ctx context.Context, appID, name string) error {
+	if appID == "" {
+		return fmt.Errorf("app id not specified")
+	}
+
+	return s.gdb.WithContext(ctx).
+		Where("app_id = ? AND name = ?", appID, name).
+		Delete(&types.AppEnvironment{}).Error
+}
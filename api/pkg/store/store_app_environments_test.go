@@ -0,0 +1,59 @@
+package store
+
+import (
+	"github.com/helixml/helix/api/pkg/system"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+func (suite *PostgresStoreTestSuite) TestAppEnvironments() {
+	ownerID := "test-" + system.GenerateUUID()
+
+	app, err := suite.db.CreateApp(suite.ctx, &types.App{
+		Owner:     ownerID,
+		OwnerType: types.OwnerTypeUser,
+		Config: types.AppConfig{
+			Helix: types.AppHelixConfig{
+				Assistants: []types.AssistantConfig{{SystemPrompt: "You are helpful."}},
+			},
+		},
+	})
+	suite.Require().NoError(err)
+
+	suite.T().Cleanup(func() {
+		err := suite.db.DeleteApp(suite.ctx, app.ID)
+		suite.NoError(err)
+	})
+
+	app.Config.Helix.Assistants[0].SystemPrompt = "You are rude."
+	_, err = suite.db.UpdateApp(suite.ctx, app)
+	suite.Require().NoError(err)
+
+	environment, err := suite.db.CreateAppEnvironment(suite.ctx, &types.AppEnvironment{
+		AppID:    app.ID,
+		Name:     types.AppEnvironmentProduction,
+		Revision: 1,
+		Secrets:  types.AppEnvironmentSecrets{"API_KEY": "prod-key"},
+	})
+	suite.Require().NoError(err)
+	suite.NotEmpty(environment.ID)
+	suite.Equal("prod-key", environment.Secrets["API_KEY"])
+
+	pinned, err := suite.db.GetAppWithToolsAtRevision(suite.ctx, app.ID, environment.Revision)
+	suite.Require().NoError(err)
+	suite.Equal(1, pinned.Revision)
+	suite.Equal("You are helpful.", pinned.Config.Helix.Assistants[0].SystemPrompt)
+
+	environment.Revision = 2
+	_, err = suite.db.UpdateAppEnvironment(suite.ctx, environment)
+	suite.Require().NoError(err)
+
+	environments, err := suite.db.ListAppEnvironments(suite.ctx, app.ID)
+	suite.Require().NoError(err)
+	suite.Require().Len(environments, 1)
+	suite.Equal(2, environments[0].Revision)
+
+	suite.Require().NoError(suite.db.DeleteAppEnvironment(suite.ctx, app.ID, types.AppEnvironmentProduction))
+
+	_, err = suite.db.GetAppEnvironment(suite.ctx, app.ID, types.AppEnvironmentProduction)
+	suite.ErrorIs(err, ErrNotFound)
+}
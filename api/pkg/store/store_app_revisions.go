@@ -0,0 +1,105 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/helixml/helix/api/pkg/system"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+// nextAppRevision returns the number of the revision an update to the app will create. Apps
+// created before revisions were stored get their current config recorded as the first revision,
+// so that the update can be rolled back.
+func nextAppRevision(tx *gorm.DB, appID string) (int, error) {
+	var latest int
+	err := tx.Model(&types.AppRevision{}).
+		Where("app_id = ?", appID).
+		Select("COALESCE(MAX(revision), 0)").
+		Scan(&latest).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to get latest app revision: %w", err)
+	}
+
+	if latest > 0 {
+		return latest + 1, nil
+	}
+
+	var existing types.App
+	err = tx.Where("id = ?", appID).First(&existing).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, ErrNotFound
+		}
+		return 0, err
+	}
+
+	existing.Revision = 1
+	if err := createAppRevision(tx, &existing); err != nil {
+		return 0, err
+	}
+
+	return 2, nil
+}
+
+func createAppRevision(tx *gorm.DB, app *types.App) error {
+	author := app.UpdatedBy
+	if author == "" {
+		author = app.Owner
+	}
+
+	err := tx.Create(&types.AppRevision{
+		ID:       system.GenerateAppRevisionID(),
+		AppID:    app.ID,
+		Revision: app.Revision,
+		Created:  time.Now(),
+		Author:   author,
+		Config:   app.Config,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to create app revision: %w", err)
+	}
+
+	return nil
+}
+
+// ListAppRevisions returns the app's revisions, newest first
+func (s *PostgresStore) ListAppRevisions(ctx context.Context, appID string) ([]*types.AppRevision, error) {
+	if appID == "" {
+		return nil, fmt.Errorf("app id not specified")
+	}
+
+	var revisions []*types.AppRevision
+	err := s.gdb.WithContext(ctx).
+		Where("app_id = ?", appID).
+		Order("revision DESC").
+		Find(&revisions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+func (s *PostgresStore) GetAppRevision(ctx context.Context, appID string, revision int) (*types.AppRevision, error) {
+	if appID == "" {
+		return nil, fmt.Errorf("app id not specified")
+	}
+
+	var appRevision types.AppRevision
+	err := s.gdb.WithContext(ctx).
+		Where("app_id = ? AND revision = ?", appID, revision).
+		First(&appRevision).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &appRevision, nil
+}
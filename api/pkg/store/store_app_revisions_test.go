@@ -0,0 +1,50 @@
+package store
+
+import (
+	"github.com/helixml/helix/api/pkg/system"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+func (suite *PostgresStoreTestSuite) TestAppRevisions() {
+	ownerID := "test-" + system.GenerateUUID()
+
+	app, err := suite.db.CreateApp(suite.ctx, &types.App{
+		Owner:     ownerID,
+		OwnerType: types.OwnerTypeUser,
+		Config: types.AppConfig{
+			Helix: types.AppHelixConfig{
+				Assistants: []types.AssistantConfig{{SystemPrompt: "You are helpful."}},
+			},
+		},
+	})
+	suite.Require().NoError(err)
+	suite.Equal(1, app.Revision)
+
+	suite.T().Cleanup(func() {
+		err := suite.db.DeleteApp(suite.ctx, app.ID)
+		suite.NoError(err)
+	})
+
+	app.Config.Helix.Assistants[0].SystemPrompt = "You are rude."
+	app.UpdatedBy = "editor-" + ownerID
+
+	updated, err := suite.db.UpdateApp(suite.ctx, app)
+	suite.Require().NoError(err)
+	suite.Equal(2, updated.Revision)
+
+	revisions, err := suite.db.ListAppRevisions(suite.ctx, app.ID)
+	suite.Require().NoError(err)
+	suite.Require().Len(revisions, 2)
+	suite.Equal(2, revisions[0].Revision)
+	suite.Equal("editor-"+ownerID, revisions[0].Author)
+	suite.Equal("You are rude.", revisions[0].Config.Helix.Assistants[0].SystemPrompt)
+	suite.Equal(1, revisions[1].Revision)
+	suite.Equal(ownerID, revisions[1].Author)
+
+	first, err := suite.db.GetAppRevision(suite.ctx, app.ID, 1)
+	suite.Require().NoError(err)
+	suite.Equal("You are helpful.", first.Config.Helix.Assistants[0].SystemPrompt)
+
+	_, err = suite.db.GetAppRevision(suite.ctx, app.ID, 3)
+	suite.ErrorIs(err, ErrNotFound)
+}
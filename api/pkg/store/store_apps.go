@@ -37,6 +37,7 @@ func RectifyApp(app *types.App) {
 		existingAPIs := make(map[string]bool)
 		existingGPTScripts := make(map[string]bool)
 		existingZapier := make(map[string]bool)
+		existingMCPs := make(map[string]bool)
 
 		// First mark all existing non-Tools items
 		for _, api := range assistant.APIs {
@@ -48,6 +49,9 @@ func RectifyApp(app *types.App) {
 		for _, zapier := range assistant.Zapier {
 			existingZapier[zapier.Name] = true
 		}
+		for _, mcp := range assistant.MCPs {
+			existingMCPs[mcp.Name] = true
+		}
 
 		// Convert tools to their appropriate fields
 		// but only if they don't already exist in the non-Tools fields
@@ -88,6 +92,18 @@ func RectifyApp(app *types.App) {
 					})
 					existingZapier[tool.Name] = true
 				}
+			case types.ToolTypeMCP:
+				if !existingMCPs[tool.Name] && tool.Config.MCP != nil {
+					assistant.MCPs = append(assistant.MCPs, types.AssistantMCP{
+						Name:        tool.Name,
+						Description: tool.Description,
+						Command:     tool.Config.MCP.Command,
+						Args:        tool.Config.MCP.Args,
+						Env:         tool.Config.MCP.Env,
+						URL:         tool.Config.MCP.URL,
+					})
+					existingMCPs[tool.Name] = true
+				}
 			}
 		}
 
@@ -110,7 +126,13 @@ func (s *PostgresStore) CreateApp(ctx context.Context, app *types.App) (*types.A
 	setAppDefaults(app)
 	RectifyApp(app)
 
-	err := s.gdb.WithContext(ctx).Create(app).Error
+	err := s.gdb.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		app.Revision = 1
+		if err := tx.Create(app).Error; err != nil {
+			return err
+		}
+		return createAppRevision(tx, app)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -130,7 +152,18 @@ func (s *PostgresStore) UpdateApp(ctx context.Context, app *types.App) (*types.A
 
 	RectifyApp(app)
 
-	err := s.gdb.WithContext(ctx).Save(&app).Error
+	err := s.gdb.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		revision, err := nextAppRevision(tx, app.ID)
+		if err != nil {
+			return err
+		}
+		app.Revision = revision
+
+		if err := tx.Save(&app).Error; err != nil {
+			return err
+		}
+		return createAppRevision(tx, app)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -217,6 +250,9 @@ func ConvertAPIToTool(api types.AssistantAPI) (*types.Tool, error) {
 				Schema:                  api.Schema,
 				Headers:                 api.Headers,
 				Query:                   api.Query,
+				Auth:                    api.Auth,
+				ActionPolicies:          api.ActionPolicies,
+				ResponseProcessing:      api.ResponseProcessing,
 				RequestPrepTemplate:     api.RequestPrepTemplate,
 				ResponseSuccessTemplate: api.ResponseSuccessTemplate,
 				ResponseErrorTemplate:   api.ResponseErrorTemplate,
@@ -233,6 +269,25 @@ func ConvertAPIToTool(api types.AssistantAPI) (*types.Tool, error) {
 	return t, nil
 }
 
+// ConvertMCPToTool converts an AssistantMCP to a Tool
+func ConvertMCPToTool(mcp types.AssistantMCP) *types.Tool {
+	return &types.Tool{
+		Name:        mcp.Name,
+		Description: mcp.Description,
+		ToolType:    types.ToolTypeMCP,
+		Config: types.ToolConfig{
+			MCP: &types.ToolMCPConfig{
+				Command: mcp.Command,
+				Args:    mcp.Args,
+				Env:     mcp.Env,
+				URL:     mcp.URL,
+
+				ActionPolicies: mcp.ActionPolicies,
+			},
+		},
+	}
+}
+
 // BACKWARD COMPATIBILITY ONLY: return an app with the apis, gptscripts, and zapier
 // transformed into the deprecated (or at least internal) Tools field
 func (s *PostgresStore) GetAppWithTools(ctx context.Context, id string) (*types.App, error) {
@@ -241,6 +296,32 @@ func (s *PostgresStore) GetAppWithTools(ctx context.Context, id string) (*types.
 		return nil, err
 	}
 
+	return appWithTools(app)
+}
+
+// GetAppWithToolsAtRevision is GetAppWithTools with the helix config of an earlier revision, it's
+// used to serve app environments that are pinned to a revision
+func (s *PostgresStore) GetAppWithToolsAtRevision(ctx context.Context, id string, revision int) (*types.App, error) {
+	app, err := s.GetApp(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if revision != app.Revision {
+		appRevision, err := s.GetAppRevision(ctx, id, revision)
+		if err != nil {
+			return nil, err
+		}
+
+		app.Config.Helix = appRevision.Config.Helix
+		app.Revision = appRevision.Revision
+		setAppDefaults(app)
+	}
+
+	return appWithTools(app)
+}
+
+func appWithTools(app *types.App) (*types.App, error) {
 	// Convert each assistant's specific tool fields into the deprecated Tools field
 	for i := range app.Config.Helix.Assistants {
 		assistant := &app.Config.Helix.Assistants[i]
@@ -285,12 +366,18 @@ func (s *PostgresStore) GetAppWithTools(ctx context.Context, id string) (*types.
 			})
 		}
 
+		// Convert MCP servers to Tools, their tools and resources are discovered when they're used
+		for _, mcp := range assistant.MCPs {
+			tools = append(tools, ConvertMCPToTool(mcp))
+		}
+
 		assistant.Tools = tools
 		// empty out the canonical fields to avoid confusion. Callers of this
 		// function should ONLY use the internal Tools field
 		assistant.APIs = nil
 		assistant.GPTScripts = nil
 		assistant.Zapier = nil
+		assistant.MCPs = nil
 	}
 
 	return app, nil
@@ -0,0 +1,108 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+// GetExperimentReport compares the variants of an app's experiment, by the LLM calls they made
+// and the feedback and eval scores of the sessions they served
+func (s *PostgresStore) GetExperimentReport(ctx context.Context, appID, experiment string) ([]*types.ExperimentVariantReport, error) {
+	if appID == "" {
+		return nil, fmt.Errorf("app id not specified")
+	}
+
+	if experiment == "" {
+		return nil, fmt.Errorf("experiment not specified")
+	}
+
+	var callStats []struct {
+		Variant          string
+		LLMCalls         int64
+		AvgDurationMs    float64
+		PromptTokens     int64
+		CompletionTokens int64
+		TotalTokens      int64
+	}
+
+	err := s.gdb.WithContext(ctx).
+		Model(&types.LLMCall{}).
+		Select(`experiment_variant AS variant,
+			COUNT(*) AS llm_calls,
+			COALESCE(AVG(duration_ms), 0) AS avg_duration_ms,
+			COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens,
+			COALESCE(SUM(completion_tokens), 0) AS completion_tokens,
+			COALESCE(SUM(total_tokens), 0) AS total_tokens`).
+		Where("app_id = ? AND experiment = ?", appID, experiment).
+		Group("experiment_variant").
+		Scan(&callStats).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get LLM call stats: %w", err)
+	}
+
+	var sessionStats []struct {
+		Variant               string
+		Sessions              int64
+		ThumbsUp              int64
+		ThumbsDown            int64
+		AvgEvalManualScore    *float64
+		AvgEvalAutomaticScore *float64
+	}
+
+	err = s.gdb.WithContext(ctx).
+		Model(&types.Session{}).
+		Select(`config->>'experiment_variant' AS variant,
+			COUNT(*) AS sessions,
+			COUNT(*) FILTER (WHERE config->>'eval_user_score' = '1.0') AS thumbs_up,
+			COUNT(*) FILTER (WHERE config->>'eval_user_score' = '0.0') AS thumbs_down,
+			AVG(NULLIF(config->>'eval_manual_score', '')::float) AS avg_eval_manual_score,
+			AVG(NULLIF(config->>'eval_automatic_score', '')::float) AS avg_eval_automatic_score`).
+		Where("parent_app = ? AND config->>'experiment' = ?", appID, experiment).
+		Group("config->>'experiment_variant'").
+		Scan(&sessionStats).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session stats: %w", err)
+	}
+
+	reports := make(map[string]*types.ExperimentVariantReport)
+	report := func(variant string) *types.ExperimentVariantReport {
+		if _, ok := reports[variant]; !ok {
+			reports[variant] = &types.ExperimentVariantReport{Variant: variant}
+		}
+		return reports[variant]
+	}
+
+	for _, stats := range callStats {
+		r := report(stats.Variant)
+		r.LLMCalls = stats.LLMCalls
+		r.AvgDurationMs = stats.AvgDurationMs
+		r.PromptTokens = stats.PromptTokens
+		r.CompletionTokens = stats.CompletionTokens
+		r.TotalTokens = stats.TotalTokens
+		if stats.LLMCalls > 0 {
+			r.AvgTotalTokens = float64(stats.TotalTokens) / float64(stats.LLMCalls)
+		}
+	}
+
+	for _, stats := range sessionStats {
+		r := report(stats.Variant)
+		r.Sessions = stats.Sessions
+		r.ThumbsUp = stats.ThumbsUp
+		r.ThumbsDown = stats.ThumbsDown
+		r.AvgEvalManualScore = stats.AvgEvalManualScore
+		r.AvgEvalAutomaticScore = stats.AvgEvalAutomaticScore
+	}
+
+	result := make([]*types.ExperimentVariantReport, 0, len(reports))
+	for _, r := range reports {
+		result = append(result, r)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Variant < result[j].Variant
+	})
+
+	return result, nil
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"time"
+
+	"github.com/helixml/helix/api/pkg/system"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+func (suite *PostgresStoreTestSuite) TestGetExperimentReport() {
+	appID := "app_" + system.GenerateUUID()
+
+	for _, call := range []*types.LLMCall{
+		{ID: system.GenerateUUID(), AppID: appID, Experiment: "tone", ExperimentVariant: "polite", DurationMs: 100, TotalTokens: 10},
+		{ID: system.GenerateUUID(), AppID: appID, Experiment: "tone", ExperimentVariant: "polite", DurationMs: 300, TotalTokens: 30},
+		{ID: system.GenerateUUID(), AppID: appID, Experiment: "tone", ExperimentVariant: "rude", DurationMs: 50, TotalTokens: 5},
+		{ID: system.GenerateUUID(), AppID: appID, Experiment: "other", ExperimentVariant: "rude", DurationMs: 50, TotalTokens: 5},
+	} {
+		_, err := suite.db.CreateLLMCall(suite.ctx, call)
+		suite.Require().NoError(err)
+	}
+
+	for _, metadata := range []types.SessionMetadata{
+		{Experiment: "tone", ExperimentVariant: "polite", EvalUserScore: "1.0", EvalManualScore: "0.8"},
+		{Experiment: "tone", ExperimentVariant: "polite", EvalUserScore: "0.0"},
+		{Experiment: "tone", ExperimentVariant: "rude", EvalUserScore: "0.0"},
+	} {
+		session, err := suite.db.CreateSession(suite.ctx, types.Session{
+			ID:        system.GenerateSessionID(),
+			Owner:     "user_id",
+			ParentApp: appID,
+			Created:   time.Now(),
+			Updated:   time.Now(),
+			Metadata:  metadata,
+		})
+		suite.Require().NoError(err)
+
+		suite.T().Cleanup(func() {
+			_, err := suite.db.DeleteSession(suite.ctx, session.ID)
+			suite.NoError(err)
+		})
+	}
+
+	reports, err := suite.db.GetExperimentReport(suite.ctx, appID, "tone")
+	suite.Require().NoError(err)
+	suite.Require().Len(reports, 2)
+
+	polite := reports[0]
+	suite.Equal("polite", polite.Variant)
+	suite.Equal(int64(2), polite.LLMCalls)
+	suite.Equal(float64(200), polite.AvgDurationMs)
+	suite.Equal(int64(40), polite.TotalTokens)
+	suite.Equal(float64(20), polite.AvgTotalTokens)
+	suite.Equal(int64(2), polite.Sessions)
+	suite.Equal(int64(1), polite.ThumbsUp)
+	suite.Equal(int64(1), polite.ThumbsDown)
+	suite.Require().NotNil(polite.AvgEvalManualScore)
+	suite.InDelta(0.8, *polite.AvgEvalManualScore, 0.001)
+	suite.Nil(polite.AvgEvalAutomaticScore)
+
+	rude := reports[1]
+	suite.Equal("rude", rude.Variant)
+	suite.Equal(int64(1), rude.LLMCalls)
+	suite.Equal(int64(1), rude.Sessions)
+	suite.Equal(int64(1), rude.ThumbsDown)
+}
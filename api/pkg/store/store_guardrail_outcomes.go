@@ -0,0 +1,67 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/helixml/helix/api/pkg/system"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+type ListGuardrailOutcomesQuery struct {
+	AppID     string
+	SessionID string
+	// LLMCallIDs lists the outcomes of any of the calls
+	LLMCallIDs []string
+	// Limit defaults to all of the outcomes
+	Limit int
+}
+
+func (s *PostgresStore) CreateGuardrailOutcome(ctx context.Context, outcome *types.GuardrailOutcome) (*types.GuardrailOutcome, error) {
+	if outcome.AppID == "" {
+		return nil, fmt.Errorf("app id not specified")
+	}
+
+	if outcome.ID == "" {
+		outcome.ID = system.GenerateGuardrailOutcomeID()
+	}
+
+	outcome.Created = time.Now()
+
+	err := s.gdb.WithContext(ctx).Create(outcome).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return outcome, nil
+}
+
+// ListGuardrailOutcomes lists the outcomes, newest first
+func (s *PostgresStore) ListGuardrailOutcomes(ctx context.Context, q *ListGuardrailOutcomesQuery) ([]*types.GuardrailOutcome, error) {
+	query := s.gdb.WithContext(ctx)
+
+	if q.AppID != "" {
+		query = query.Where("app_id = ?", q.AppID)
+	}
+
+	if q.SessionID != "" {
+		query = query.Where("session_id = ?", q.SessionID)
+	}
+
+	if len(q.LLMCallIDs) > 0 {
+		query = query.Where("llm_call_id IN ?", q.LLMCallIDs)
+	}
+
+	if q.Limit > 0 {
+		query = query.Limit(q.Limit)
+	}
+
+	var outcomes []*types.GuardrailOutcome
+	err := query.Order("created DESC").Find(&outcomes).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return outcomes, nil
+}
@@ -0,0 +1,58 @@
+package store
+
+import (
+	"github.com/helixml/helix/api/pkg/system"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+func (suite *PostgresStoreTestSuite) TestGuardrailOutcomes() {
+	app, err := suite.db.CreateApp(suite.ctx, &types.App{
+		Owner:     "test-" + system.GenerateUUID(),
+		OwnerType: types.OwnerTypeUser,
+	})
+	suite.Require().NoError(err)
+
+	suite.T().Cleanup(func() {
+		err := suite.db.DeleteApp(suite.ctx, app.ID)
+		suite.NoError(err)
+	})
+
+	_, err = suite.db.CreateGuardrailOutcome(suite.ctx, &types.GuardrailOutcome{})
+	suite.Error(err)
+
+	sessionID := system.GenerateSessionID()
+	llmCallID := system.GenerateLLMCallID()
+
+	redacted, err := suite.db.CreateGuardrailOutcome(suite.ctx, &types.GuardrailOutcome{
+		AppID:     app.ID,
+		SessionID: sessionID,
+		LLMCallID: llmCallID,
+		Stage:     types.GuardrailStageInput,
+		Type:      types.GuardrailTypePII,
+		Action:    types.GuardrailActionRedact,
+		Findings:  types.GuardrailFindings{"email"},
+	})
+	suite.Require().NoError(err)
+	suite.NotEmpty(redacted.ID)
+
+	blocked, err := suite.db.CreateGuardrailOutcome(suite.ctx, &types.GuardrailOutcome{
+		AppID:     app.ID,
+		SessionID: sessionID,
+		Stage:     types.GuardrailStageInput,
+		Type:      types.GuardrailTypeTopics,
+		Action:    types.GuardrailActionBlock,
+		Findings:  types.GuardrailFindings{"denied topic: elections"},
+	})
+	suite.Require().NoError(err)
+
+	outcomes, err := suite.db.ListGuardrailOutcomes(suite.ctx, &ListGuardrailOutcomesQuery{AppID: app.ID, SessionID: sessionID})
+	suite.Require().NoError(err)
+	suite.Require().Len(outcomes, 2)
+	suite.Equal(blocked.ID, outcomes[0].ID)
+	suite.Equal(types.GuardrailFindings{"email"}, outcomes[1].Findings)
+
+	outcomes, err = suite.db.ListGuardrailOutcomes(suite.ctx, &ListGuardrailOutcomesQuery{LLMCallIDs: []string{llmCallID}})
+	suite.Require().NoError(err)
+	suite.Require().Len(outcomes, 1)
+	suite.Equal(redacted.ID, outcomes[0].ID)
+}
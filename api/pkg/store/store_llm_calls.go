@@ -2,6 +2,7 @@ package store
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/helixml/helix/api/pkg/system"
@@ -66,5 +67,37 @@ func (s *PostgresStore) ListLLMCalls(ctx context.Context, q *ListLLMCallsQuery)
 		return nil, 0, err
 	}
 
+	if err := s.attachGuardrailOutcomes(ctx, calls); err != nil {
+		return nil, 0, err
+	}
+
 	return calls, totalCount, nil
 }
+
+// attachGuardrailOutcomes adds what the guardrails caught to the calls
+func (s *PostgresStore) attachGuardrailOutcomes(ctx context.Context, calls []*types.LLMCall) error {
+	if len(calls) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(calls))
+	for _, call := range calls {
+		ids = append(ids, call.ID)
+	}
+
+	outcomes, err := s.ListGuardrailOutcomes(ctx, &ListGuardrailOutcomesQuery{LLMCallIDs: ids})
+	if err != nil {
+		return fmt.Errorf("failed to list guardrail outcomes: %w", err)
+	}
+
+	byCall := make(map[string][]*types.GuardrailOutcome)
+	for _, outcome := range outcomes {
+		byCall[outcome.LLMCallID] = append(byCall[outcome.LLMCallID], outcome)
+	}
+
+	for _, call := range calls {
+		call.GuardrailOutcomes = byCall[call.ID]
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/helixml/helix/api/pkg/system"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+type ListMemoriesQuery struct {
+	AppID  string
+	UserID string
+	// Limit defaults to all of the memories
+	Limit int
+}
+
+func (s *PostgresStore) CreateMemory(ctx context.Context, memory *types.Memory) (*types.Memory, error) {
+	if memory.AppID == "" {
+		return nil, fmt.Errorf("app id not specified")
+	}
+
+	if memory.UserID == "" {
+		return nil, fmt.Errorf("user id not specified")
+	}
+
+	if memory.ID == "" {
+		memory.ID = system.GenerateMemoryID()
+	}
+
+	memory.Created = time.Now()
+	memory.Updated = memory.Created
+
+	err := s.gdb.WithContext(ctx).Create(memory).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetMemory(ctx, memory.ID)
+}
+
+func (s *PostgresStore) UpdateMemory(ctx context.Context, memory *types.Memory) (*types.Memory, error) {
+	if memory.ID == "" {
+		return nil, fmt.Errorf("id not specified")
+	}
+
+	memory.Updated = time.Now()
+
+	err := s.gdb.WithContext(ctx).Save(memory).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetMemory(ctx, memory.ID)
+}
+
+func (s *PostgresStore) GetMemory(ctx context.Context, id string) (*types.Memory, error) {
+	if id == "" {
+		return nil, fmt.Errorf("id not specified")
+	}
+
+	var memory types.Memory
+	err := s.gdb.WithContext(ctx).Where("id = ?", id).First(&memory).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &memory, nil
+}
+
+// ListMemories lists the user's memories, newest first
+func (s *PostgresStore) ListMemories(ctx context.Context, q *ListMemoriesQuery) ([]*types.Memory, error) {
+	if q.UserID == "" {
+		return nil, fmt.Errorf("user id not specified")
+	}
+
+	query := s.gdb.WithContext(ctx).Where("user_id = ?", q.UserID)
+	if q.AppID != "" {
+		query = query.Where("app_id = ?", q.AppID)
+	}
+	if q.Limit > 0 {
+		query = query.Limit(q.Limit)
+	}
+
+	var memories []*types.Memory
+	err := query.Order("created DESC").Find(&memories).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return memories, nil
+}
+
+func (s *PostgresStore) DeleteMemory(ctx context.Context, id string) error {
+	if id == "" {
+		return fmt.Errorf("id not specified")
+	}
+
+	return s.gdb.WithContext(ctx).Delete(&types.Memory{ID: id}).Error
+}
+
+// DeleteMemories deletes the user's memories, of the app when it's set
+func (s *PostgresStore) DeleteMemories(ctx context.Context, q *ListMemoriesQuery) error {
+	if q.UserID == "" {
+		return fmt.Errorf("user id not specified")
+	}
+
+	query := s.gdb.WithContext(ctx).Where("user_id = ?", q.UserID)
+	if q.AppID != "" {
+		query = query.Where("app_id = ?", q.AppID)
+	}
+
+	return query.Delete(&types.Memory{}).Error
+}
@@ -0,0 +1,72 @@
+package store
+
+import (
+	"github.com/helixml/helix/api/pkg/system"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+func (suite *PostgresStoreTestSuite) TestMemories() {
+	app, err := suite.db.CreateApp(suite.ctx, &types.App{
+		Owner:     "test-" + system.GenerateUUID(),
+		OwnerType: types.OwnerTypeUser,
+	})
+	suite.Require().NoError(err)
+
+	suite.T().Cleanup(func() {
+		err := suite.db.DeleteApp(suite.ctx, app.ID)
+		suite.NoError(err)
+	})
+
+	userID := "user-" + system.GenerateUUID()
+
+	first, err := suite.db.CreateMemory(suite.ctx, &types.Memory{
+		AppID:     app.ID,
+		UserID:    userID,
+		SessionID: "ses_1",
+		Content:   "Prefers answers in French",
+	})
+	suite.Require().NoError(err)
+	suite.NotEmpty(first.ID)
+
+	second, err := suite.db.CreateMemory(suite.ctx, &types.Memory{
+		AppID:   app.ID,
+		UserID:  userID,
+		Content: "Works on the billing team",
+	})
+	suite.Require().NoError(err)
+
+	_, err = suite.db.CreateMemory(suite.ctx, &types.Memory{
+		AppID:   app.ID,
+		UserID:  "other-" + userID,
+		Content: "Likes cats",
+	})
+	suite.Require().NoError(err)
+
+	first.Content = "Prefers answers in German"
+	_, err = suite.db.UpdateMemory(suite.ctx, first)
+	suite.Require().NoError(err)
+
+	memories, err := suite.db.ListMemories(suite.ctx, &ListMemoriesQuery{AppID: app.ID, UserID: userID})
+	suite.Require().NoError(err)
+	suite.Require().Len(memories, 2)
+	suite.Equal(second.ID, memories[0].ID)
+	suite.Equal("Prefers answers in German", memories[1].Content)
+
+	err = suite.db.DeleteMemory(suite.ctx, second.ID)
+	suite.Require().NoError(err)
+
+	_, err = suite.db.GetMemory(suite.ctx, second.ID)
+	suite.ErrorIs(err, ErrNotFound)
+
+	err = suite.db.DeleteMemories(suite.ctx, &ListMemoriesQuery{AppID: app.ID, UserID: userID})
+	suite.Require().NoError(err)
+
+	memories, err = suite.db.ListMemories(suite.ctx, &ListMemoriesQuery{UserID: userID})
+	suite.Require().NoError(err)
+	suite.Empty(memories)
+
+	// Other users' memories are kept
+	memories, err = suite.db.ListMemories(suite.ctx, &ListMemoriesQuery{AppID: app.ID, UserID: "other-" + userID})
+	suite.Require().NoError(err)
+	suite.Len(memories, 1)
+}
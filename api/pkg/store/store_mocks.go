@@ -12,6 +12,7 @@ package store
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	types "github.com/helixml/helix/api/pkg/types"
 	gomock "go.uber.org/mock/gomock"
@@ -20,8 +21,8 @@ import (
 // MockStore is a mock of Store interface.
 type MockStore struct {
 	ctrl     *gomock.Controller
-	recorder *MockStoreMockRecorder
 	isgomock struct{}
+	recorder *MockStoreMockRecorder
 }
 
 // MockStoreMockRecorder is the mock recorder for MockStore.
@@ -41,6 +42,21 @@ func (m *MockStore) EXPECT() *MockStoreMockRecorder {
 	return m.recorder
 }
 
+// ClaimWorkflowRun mocks base method.
+func (m *MockStore) ClaimWorkflowRun(ctx context.Context, id string, staleBefore time.Time) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimWorkflowRun", ctx, id, staleBefore)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimWorkflowRun indicates an expected call of ClaimWorkflowRun.
+func (mr *MockStoreMockRecorder) ClaimWorkflowRun(ctx, id, staleBefore any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimWorkflowRun", reflect.TypeOf((*MockStore)(nil).ClaimWorkflowRun), ctx, id, staleBefore)
+}
+
 // CreateAPIKey mocks base method.
 func (m *MockStore) CreateAPIKey(ctx context.Context, apiKey *types.APIKey) (*types.APIKey, error) {
 	m.ctrl.T.Helper()
@@ -71,6 +87,21 @@ func (mr *MockStoreMockRecorder) CreateApp(ctx, tool any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateApp", reflect.TypeOf((*MockStore)(nil).CreateApp), ctx, tool)
 }
 
+// CreateAppEnvironment mocks base method.
+func (m *MockStore) CreateAppEnvironment(ctx context.Context, environment *types.AppEnvironment) (*types.AppEnvironment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAppEnvironment", ctx, environment)
+	ret0, _ := ret[0].(*types.AppEnvironment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAppEnvironment indicates an expected call of CreateAppEnvironment.
+func (mr *MockStoreMockRecorder) CreateAppEnvironment(ctx, environment any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAppEnvironment", reflect.TypeOf((*MockStore)(nil).CreateAppEnvironment), ctx, environment)
+}
+
 // CreateDataEntity mocks base method.
 func (m *MockStore) CreateDataEntity(ctx context.Context, dataEntity *types.DataEntity) (*types.DataEntity, error) {
 	m.ctrl.T.Helper()
@@ -86,6 +117,21 @@ func (mr *MockStoreMockRecorder) CreateDataEntity(ctx, dataEntity any) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateDataEntity", reflect.TypeOf((*MockStore)(nil).CreateDataEntity), ctx, dataEntity)
 }
 
+// CreateGuardrailOutcome mocks base method.
+func (m *MockStore) CreateGuardrailOutcome(ctx context.Context, outcome *types.GuardrailOutcome) (*types.GuardrailOutcome, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateGuardrailOutcome", ctx, outcome)
+	ret0, _ := ret[0].(*types.GuardrailOutcome)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateGuardrailOutcome indicates an expected call of CreateGuardrailOutcome.
+func (mr *MockStoreMockRecorder) CreateGuardrailOutcome(ctx, outcome any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateGuardrailOutcome", reflect.TypeOf((*MockStore)(nil).CreateGuardrailOutcome), ctx, outcome)
+}
+
 // CreateKnowledge mocks base method.
 func (m *MockStore) CreateKnowledge(ctx context.Context, knowledge *types.Knowledge) (*types.Knowledge, error) {
 	m.ctrl.T.Helper()
@@ -131,6 +177,21 @@ func (mr *MockStoreMockRecorder) CreateLLMCall(ctx, call any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateLLMCall", reflect.TypeOf((*MockStore)(nil).CreateLLMCall), ctx, call)
 }
 
+// CreateMemory mocks base method.
+func (m *MockStore) CreateMemory(ctx context.Context, memory *types.Memory) (*types.Memory, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateMemory", ctx, memory)
+	ret0, _ := ret[0].(*types.Memory)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateMemory indicates an expected call of CreateMemory.
+func (mr *MockStoreMockRecorder) CreateMemory(ctx, memory any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateMemory", reflect.TypeOf((*MockStore)(nil).CreateMemory), ctx, memory)
+}
+
 // CreateScriptRun mocks base method.
 func (m *MockStore) CreateScriptRun(ctx context.Context, task *types.ScriptRun) (*types.ScriptRun, error) {
 	m.ctrl.T.Helper()
@@ -205,6 +266,36 @@ func (mr *MockStoreMockRecorder) CreateTool(ctx, tool any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTool", reflect.TypeOf((*MockStore)(nil).CreateTool), ctx, tool)
 }
 
+// CreateToolApproval mocks base method.
+func (m *MockStore) CreateToolApproval(ctx context.Context, approval *types.ToolApproval) (*types.ToolApproval, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateToolApproval", ctx, approval)
+	ret0, _ := ret[0].(*types.ToolApproval)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateToolApproval indicates an expected call of CreateToolApproval.
+func (mr *MockStoreMockRecorder) CreateToolApproval(ctx, approval any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateToolApproval", reflect.TypeOf((*MockStore)(nil).CreateToolApproval), ctx, approval)
+}
+
+// CreateTriggerRun mocks base method.
+func (m *MockStore) CreateTriggerRun(ctx context.Context, run *types.TriggerRun) (*types.TriggerRun, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateTriggerRun", ctx, run)
+	ret0, _ := ret[0].(*types.TriggerRun)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateTriggerRun indicates an expected call of CreateTriggerRun.
+func (mr *MockStoreMockRecorder) CreateTriggerRun(ctx, run any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTriggerRun", reflect.TypeOf((*MockStore)(nil).CreateTriggerRun), ctx, run)
+}
+
 // CreateUserMeta mocks base method.
 func (m *MockStore) CreateUserMeta(ctx context.Context, UserMeta types.UserMeta) (*types.UserMeta, error) {
 	m.ctrl.T.Helper()
@@ -220,6 +311,36 @@ func (mr *MockStoreMockRecorder) CreateUserMeta(ctx, UserMeta any) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUserMeta", reflect.TypeOf((*MockStore)(nil).CreateUserMeta), ctx, UserMeta)
 }
 
+// CreateWorkflowRun mocks base method.
+func (m *MockStore) CreateWorkflowRun(ctx context.Context, run *types.WorkflowRun) (*types.WorkflowRun, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWorkflowRun", ctx, run)
+	ret0, _ := ret[0].(*types.WorkflowRun)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateWorkflowRun indicates an expected call of CreateWorkflowRun.
+func (mr *MockStoreMockRecorder) CreateWorkflowRun(ctx, run any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWorkflowRun", reflect.TypeOf((*MockStore)(nil).CreateWorkflowRun), ctx, run)
+}
+
+// DecideToolApproval mocks base method.
+func (m *MockStore) DecideToolApproval(ctx context.Context, approval *types.ToolApproval) (*types.ToolApproval, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DecideToolApproval", ctx, approval)
+	ret0, _ := ret[0].(*types.ToolApproval)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DecideToolApproval indicates an expected call of DecideToolApproval.
+func (mr *MockStoreMockRecorder) DecideToolApproval(ctx, approval any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DecideToolApproval", reflect.TypeOf((*MockStore)(nil).DecideToolApproval), ctx, approval)
+}
+
 // DeleteAPIKey mocks base method.
 func (m *MockStore) DeleteAPIKey(ctx context.Context, apiKey string) error {
 	m.ctrl.T.Helper()
@@ -248,6 +369,20 @@ func (mr *MockStoreMockRecorder) DeleteApp(ctx, id any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteApp", reflect.TypeOf((*MockStore)(nil).DeleteApp), ctx, id)
 }
 
+// DeleteAppEnvironment mocks base method.
+func (m *MockStore) DeleteAppEnvironment(ctx context.Context, appID, name string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAppEnvironment", ctx, appID, name)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAppEnvironment indicates an expected call of DeleteAppEnvironment.
+func (mr *MockStoreMockRecorder) DeleteAppEnvironment(ctx, appID, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAppEnvironment", reflect.TypeOf((*MockStore)(nil).DeleteAppEnvironment), ctx, appID, name)
+}
+
 // DeleteDataEntity mocks base method.
 func (m *MockStore) DeleteDataEntity(ctx context.Context, id string) error {
 	m.ctrl.T.Helper()
@@ -290,6 +425,34 @@ func (mr *MockStoreMockRecorder) DeleteKnowledgeVersion(ctx, id any) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteKnowledgeVersion", reflect.TypeOf((*MockStore)(nil).DeleteKnowledgeVersion), ctx, id)
 }
 
+// DeleteMemories mocks base method.
+func (m *MockStore) DeleteMemories(ctx context.Context, q *ListMemoriesQuery) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteMemories", ctx, q)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteMemories indicates an expected call of DeleteMemories.
+func (mr *MockStoreMockRecorder) DeleteMemories(ctx, q any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteMemories", reflect.TypeOf((*MockStore)(nil).DeleteMemories), ctx, q)
+}
+
+// DeleteMemory mocks base method.
+func (m *MockStore) DeleteMemory(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteMemory", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteMemory indicates an expected call of DeleteMemory.
+func (mr *MockStoreMockRecorder) DeleteMemory(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteMemory", reflect.TypeOf((*MockStore)(nil).DeleteMemory), ctx, id)
+}
+
 // DeleteScriptRun mocks base method.
 func (m *MockStore) DeleteScriptRun(ctx context.Context, id string) error {
 	m.ctrl.T.Helper()
@@ -361,6 +524,20 @@ func (mr *MockStoreMockRecorder) DeleteTool(ctx, id any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTool", reflect.TypeOf((*MockStore)(nil).DeleteTool), ctx, id)
 }
 
+// DeleteToolOAuthToken mocks base method.
+func (m *MockStore) DeleteToolOAuthToken(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteToolOAuthToken", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteToolOAuthToken indicates an expected call of DeleteToolOAuthToken.
+func (mr *MockStoreMockRecorder) DeleteToolOAuthToken(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteToolOAuthToken", reflect.TypeOf((*MockStore)(nil).DeleteToolOAuthToken), ctx, id)
+}
+
 // EnsureUserMeta mocks base method.
 func (m *MockStore) EnsureUserMeta(ctx context.Context, UserMeta types.UserMeta) (*types.UserMeta, error) {
 	m.ctrl.T.Helper()
@@ -406,6 +583,36 @@ func (mr *MockStoreMockRecorder) GetApp(ctx, id any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetApp", reflect.TypeOf((*MockStore)(nil).GetApp), ctx, id)
 }
 
+// GetAppEnvironment mocks base method.
+func (m *MockStore) GetAppEnvironment(ctx context.Context, appID, name string) (*types.AppEnvironment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAppEnvironment", ctx, appID, name)
+	ret0, _ := ret[0].(*types.AppEnvironment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAppEnvironment indicates an expected call of GetAppEnvironment.
+func (mr *MockStoreMockRecorder) GetAppEnvironment(ctx, appID, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAppEnvironment", reflect.TypeOf((*MockStore)(nil).GetAppEnvironment), ctx, appID, name)
+}
+
+// GetAppRevision mocks base method.
+func (m *MockStore) GetAppRevision(ctx context.Context, appID string, revision int) (*types.AppRevision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAppRevision", ctx, appID, revision)
+	ret0, _ := ret[0].(*types.AppRevision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAppRevision indicates an expected call of GetAppRevision.
+func (mr *MockStoreMockRecorder) GetAppRevision(ctx, appID, revision any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAppRevision", reflect.TypeOf((*MockStore)(nil).GetAppRevision), ctx, appID, revision)
+}
+
 // GetAppWithTools mocks base method.
 func (m *MockStore) GetAppWithTools(ctx context.Context, id string) (*types.App, error) {
 	m.ctrl.T.Helper()
@@ -421,6 +628,21 @@ func (mr *MockStoreMockRecorder) GetAppWithTools(ctx, id any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAppWithTools", reflect.TypeOf((*MockStore)(nil).GetAppWithTools), ctx, id)
 }
 
+// GetAppWithToolsAtRevision mocks base method.
+func (m *MockStore) GetAppWithToolsAtRevision(ctx context.Context, id string, revision int) (*types.App, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAppWithToolsAtRevision", ctx, id, revision)
+	ret0, _ := ret[0].(*types.App)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAppWithToolsAtRevision indicates an expected call of GetAppWithToolsAtRevision.
+func (mr *MockStoreMockRecorder) GetAppWithToolsAtRevision(ctx, id, revision any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAppWithToolsAtRevision", reflect.TypeOf((*MockStore)(nil).GetAppWithToolsAtRevision), ctx, id, revision)
+}
+
 // GetDataEntity mocks base method.
 func (m *MockStore) GetDataEntity(ctx context.Context, id string) (*types.DataEntity, error) {
 	m.ctrl.T.Helper()
@@ -436,6 +658,21 @@ func (mr *MockStoreMockRecorder) GetDataEntity(ctx, id any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDataEntity", reflect.TypeOf((*MockStore)(nil).GetDataEntity), ctx, id)
 }
 
+// GetExperimentReport mocks base method.
+func (m *MockStore) GetExperimentReport(ctx context.Context, appID, experiment string) ([]*types.ExperimentVariantReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetExperimentReport", ctx, appID, experiment)
+	ret0, _ := ret[0].([]*types.ExperimentVariantReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetExperimentReport indicates an expected call of GetExperimentReport.
+func (mr *MockStoreMockRecorder) GetExperimentReport(ctx, appID, experiment any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetExperimentReport", reflect.TypeOf((*MockStore)(nil).GetExperimentReport), ctx, appID, experiment)
+}
+
 // GetKnowledge mocks base method.
 func (m *MockStore) GetKnowledge(ctx context.Context, id string) (*types.Knowledge, error) {
 	m.ctrl.T.Helper()
@@ -466,6 +703,21 @@ func (mr *MockStoreMockRecorder) GetKnowledgeVersion(ctx, id any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetKnowledgeVersion", reflect.TypeOf((*MockStore)(nil).GetKnowledgeVersion), ctx, id)
 }
 
+// GetMemory mocks base method.
+func (m *MockStore) GetMemory(ctx context.Context, id string) (*types.Memory, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMemory", ctx, id)
+	ret0, _ := ret[0].(*types.Memory)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMemory indicates an expected call of GetMemory.
+func (mr *MockStoreMockRecorder) GetMemory(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMemory", reflect.TypeOf((*MockStore)(nil).GetMemory), ctx, id)
+}
+
 // GetSecret mocks base method.
 func (m *MockStore) GetSecret(ctx context.Context, id string) (*types.Secret, error) {
 	m.ctrl.T.Helper()
@@ -541,6 +793,51 @@ func (mr *MockStoreMockRecorder) GetTool(ctx, id any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTool", reflect.TypeOf((*MockStore)(nil).GetTool), ctx, id)
 }
 
+// GetToolApproval mocks base method.
+func (m *MockStore) GetToolApproval(ctx context.Context, id string) (*types.ToolApproval, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetToolApproval", ctx, id)
+	ret0, _ := ret[0].(*types.ToolApproval)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetToolApproval indicates an expected call of GetToolApproval.
+func (mr *MockStoreMockRecorder) GetToolApproval(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetToolApproval", reflect.TypeOf((*MockStore)(nil).GetToolApproval), ctx, id)
+}
+
+// GetToolOAuthToken mocks base method.
+func (m *MockStore) GetToolOAuthToken(ctx context.Context, q *GetToolOAuthTokenQuery) (*types.ToolOAuthToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetToolOAuthToken", ctx, q)
+	ret0, _ := ret[0].(*types.ToolOAuthToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetToolOAuthToken indicates an expected call of GetToolOAuthToken.
+func (mr *MockStoreMockRecorder) GetToolOAuthToken(ctx, q any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetToolOAuthToken", reflect.TypeOf((*MockStore)(nil).GetToolOAuthToken), ctx, q)
+}
+
+// GetTriggerRun mocks base method.
+func (m *MockStore) GetTriggerRun(ctx context.Context, id string) (*types.TriggerRun, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTriggerRun", ctx, id)
+	ret0, _ := ret[0].(*types.TriggerRun)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTriggerRun indicates an expected call of GetTriggerRun.
+func (mr *MockStoreMockRecorder) GetTriggerRun(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTriggerRun", reflect.TypeOf((*MockStore)(nil).GetTriggerRun), ctx, id)
+}
+
 // GetUserMeta mocks base method.
 func (m *MockStore) GetUserMeta(ctx context.Context, id string) (*types.UserMeta, error) {
 	m.ctrl.T.Helper()
@@ -556,6 +853,21 @@ func (mr *MockStoreMockRecorder) GetUserMeta(ctx, id any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserMeta", reflect.TypeOf((*MockStore)(nil).GetUserMeta), ctx, id)
 }
 
+// GetWorkflowRun mocks base method.
+func (m *MockStore) GetWorkflowRun(ctx context.Context, id string) (*types.WorkflowRun, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWorkflowRun", ctx, id)
+	ret0, _ := ret[0].(*types.WorkflowRun)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWorkflowRun indicates an expected call of GetWorkflowRun.
+func (mr *MockStoreMockRecorder) GetWorkflowRun(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWorkflowRun", reflect.TypeOf((*MockStore)(nil).GetWorkflowRun), ctx, id)
+}
+
 // ListAPIKeys mocks base method.
 func (m *MockStore) ListAPIKeys(ctx context.Context, query *ListAPIKeysQuery) ([]*types.APIKey, error) {
 	m.ctrl.T.Helper()
@@ -571,6 +883,36 @@ func (mr *MockStoreMockRecorder) ListAPIKeys(ctx, query any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAPIKeys", reflect.TypeOf((*MockStore)(nil).ListAPIKeys), ctx, query)
 }
 
+// ListAppEnvironments mocks base method.
+func (m *MockStore) ListAppEnvironments(ctx context.Context, appID string) ([]*types.AppEnvironment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAppEnvironments", ctx, appID)
+	ret0, _ := ret[0].([]*types.AppEnvironment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAppEnvironments indicates an expected call of ListAppEnvironments.
+func (mr *MockStoreMockRecorder) ListAppEnvironments(ctx, appID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAppEnvironments", reflect.TypeOf((*MockStore)(nil).ListAppEnvironments), ctx, appID)
+}
+
+// ListAppRevisions mocks base method.
+func (m *MockStore) ListAppRevisions(ctx context.Context, appID string) ([]*types.AppRevision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAppRevisions", ctx, appID)
+	ret0, _ := ret[0].([]*types.AppRevision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAppRevisions indicates an expected call of ListAppRevisions.
+func (mr *MockStoreMockRecorder) ListAppRevisions(ctx, appID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAppRevisions", reflect.TypeOf((*MockStore)(nil).ListAppRevisions), ctx, appID)
+}
+
 // ListApps mocks base method.
 func (m *MockStore) ListApps(ctx context.Context, q *ListAppsQuery) ([]*types.App, error) {
 	m.ctrl.T.Helper()
@@ -601,6 +943,21 @@ func (mr *MockStoreMockRecorder) ListDataEntities(ctx, q any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDataEntities", reflect.TypeOf((*MockStore)(nil).ListDataEntities), ctx, q)
 }
 
+// ListGuardrailOutcomes mocks base method.
+func (m *MockStore) ListGuardrailOutcomes(ctx context.Context, q *ListGuardrailOutcomesQuery) ([]*types.GuardrailOutcome, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListGuardrailOutcomes", ctx, q)
+	ret0, _ := ret[0].([]*types.GuardrailOutcome)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListGuardrailOutcomes indicates an expected call of ListGuardrailOutcomes.
+func (mr *MockStoreMockRecorder) ListGuardrailOutcomes(ctx, q any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListGuardrailOutcomes", reflect.TypeOf((*MockStore)(nil).ListGuardrailOutcomes), ctx, q)
+}
+
 // ListKnowledge mocks base method.
 func (m *MockStore) ListKnowledge(ctx context.Context, q *ListKnowledgeQuery) ([]*types.Knowledge, error) {
 	m.ctrl.T.Helper()
@@ -647,6 +1004,21 @@ func (mr *MockStoreMockRecorder) ListLLMCalls(ctx, q any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListLLMCalls", reflect.TypeOf((*MockStore)(nil).ListLLMCalls), ctx, q)
 }
 
+// ListMemories mocks base method.
+func (m *MockStore) ListMemories(ctx context.Context, q *ListMemoriesQuery) ([]*types.Memory, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListMemories", ctx, q)
+	ret0, _ := ret[0].([]*types.Memory)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListMemories indicates an expected call of ListMemories.
+func (mr *MockStoreMockRecorder) ListMemories(ctx, q any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListMemories", reflect.TypeOf((*MockStore)(nil).ListMemories), ctx, q)
+}
+
 // ListScriptRuns mocks base method.
 func (m *MockStore) ListScriptRuns(ctx context.Context, q *types.GptScriptRunsQuery) ([]*types.ScriptRun, error) {
 	m.ctrl.T.Helper()
@@ -692,6 +1064,21 @@ func (mr *MockStoreMockRecorder) ListSessionTools(ctx, sessionID any) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSessionTools", reflect.TypeOf((*MockStore)(nil).ListSessionTools), ctx, sessionID)
 }
 
+// ListToolApprovals mocks base method.
+func (m *MockStore) ListToolApprovals(ctx context.Context, q *ListToolApprovalsQuery) ([]*types.ToolApproval, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListToolApprovals", ctx, q)
+	ret0, _ := ret[0].([]*types.ToolApproval)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListToolApprovals indicates an expected call of ListToolApprovals.
+func (mr *MockStoreMockRecorder) ListToolApprovals(ctx, q any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListToolApprovals", reflect.TypeOf((*MockStore)(nil).ListToolApprovals), ctx, q)
+}
+
 // ListTools mocks base method.
 func (m *MockStore) ListTools(ctx context.Context, q *ListToolsQuery) ([]*types.Tool, error) {
 	m.ctrl.T.Helper()
@@ -707,6 +1094,36 @@ func (mr *MockStoreMockRecorder) ListTools(ctx, q any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTools", reflect.TypeOf((*MockStore)(nil).ListTools), ctx, q)
 }
 
+// ListTriggerRuns mocks base method.
+func (m *MockStore) ListTriggerRuns(ctx context.Context, q *ListTriggerRunsQuery) ([]*types.TriggerRun, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTriggerRuns", ctx, q)
+	ret0, _ := ret[0].([]*types.TriggerRun)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTriggerRuns indicates an expected call of ListTriggerRuns.
+func (mr *MockStoreMockRecorder) ListTriggerRuns(ctx, q any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTriggerRuns", reflect.TypeOf((*MockStore)(nil).ListTriggerRuns), ctx, q)
+}
+
+// ListWorkflowRuns mocks base method.
+func (m *MockStore) ListWorkflowRuns(ctx context.Context, q *ListWorkflowRunsQuery) ([]*types.WorkflowRun, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListWorkflowRuns", ctx, q)
+	ret0, _ := ret[0].([]*types.WorkflowRun)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListWorkflowRuns indicates an expected call of ListWorkflowRuns.
+func (mr *MockStoreMockRecorder) ListWorkflowRuns(ctx, q any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListWorkflowRuns", reflect.TypeOf((*MockStore)(nil).ListWorkflowRuns), ctx, q)
+}
+
 // LookupKnowledge mocks base method.
 func (m *MockStore) LookupKnowledge(ctx context.Context, q *LookupKnowledgeQuery) (*types.Knowledge, error) {
 	m.ctrl.T.Helper()
@@ -737,6 +1154,21 @@ func (mr *MockStoreMockRecorder) UpdateApp(ctx, tool any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateApp", reflect.TypeOf((*MockStore)(nil).UpdateApp), ctx, tool)
 }
 
+// UpdateAppEnvironment mocks base method.
+func (m *MockStore) UpdateAppEnvironment(ctx context.Context, environment *types.AppEnvironment) (*types.AppEnvironment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAppEnvironment", ctx, environment)
+	ret0, _ := ret[0].(*types.AppEnvironment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateAppEnvironment indicates an expected call of UpdateAppEnvironment.
+func (mr *MockStoreMockRecorder) UpdateAppEnvironment(ctx, environment any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAppEnvironment", reflect.TypeOf((*MockStore)(nil).UpdateAppEnvironment), ctx, environment)
+}
+
 // UpdateDataEntity mocks base method.
 func (m *MockStore) UpdateDataEntity(ctx context.Context, dataEntity *types.DataEntity) (*types.DataEntity, error) {
 	m.ctrl.T.Helper()
@@ -781,6 +1213,21 @@ func (mr *MockStoreMockRecorder) UpdateKnowledgeState(ctx, id, state, message, p
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateKnowledgeState", reflect.TypeOf((*MockStore)(nil).UpdateKnowledgeState), ctx, id, state, message, percent)
 }
 
+// UpdateMemory mocks base method.
+func (m *MockStore) UpdateMemory(ctx context.Context, memory *types.Memory) (*types.Memory, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateMemory", ctx, memory)
+	ret0, _ := ret[0].(*types.Memory)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateMemory indicates an expected call of UpdateMemory.
+func (mr *MockStoreMockRecorder) UpdateMemory(ctx, memory any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateMemory", reflect.TypeOf((*MockStore)(nil).UpdateMemory), ctx, memory)
+}
+
 // UpdateSecret mocks base method.
 func (m *MockStore) UpdateSecret(ctx context.Context, secret *types.Secret) (*types.Secret, error) {
 	m.ctrl.T.Helper()
@@ -855,6 +1302,36 @@ func (mr *MockStoreMockRecorder) UpdateTool(ctx, tool any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTool", reflect.TypeOf((*MockStore)(nil).UpdateTool), ctx, tool)
 }
 
+// UpdateToolApproval mocks base method.
+func (m *MockStore) UpdateToolApproval(ctx context.Context, approval *types.ToolApproval) (*types.ToolApproval, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateToolApproval", ctx, approval)
+	ret0, _ := ret[0].(*types.ToolApproval)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateToolApproval indicates an expected call of UpdateToolApproval.
+func (mr *MockStoreMockRecorder) UpdateToolApproval(ctx, approval any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateToolApproval", reflect.TypeOf((*MockStore)(nil).UpdateToolApproval), ctx, approval)
+}
+
+// UpdateTriggerRun mocks base method.
+func (m *MockStore) UpdateTriggerRun(ctx context.Context, run *types.TriggerRun) (*types.TriggerRun, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateTriggerRun", ctx, run)
+	ret0, _ := ret[0].(*types.TriggerRun)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateTriggerRun indicates an expected call of UpdateTriggerRun.
+func (mr *MockStoreMockRecorder) UpdateTriggerRun(ctx, run any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTriggerRun", reflect.TypeOf((*MockStore)(nil).UpdateTriggerRun), ctx, run)
+}
+
 // UpdateUserMeta mocks base method.
 func (m *MockStore) UpdateUserMeta(ctx context.Context, UserMeta types.UserMeta) (*types.UserMeta, error) {
 	m.ctrl.T.Helper()
@@ -869,3 +1346,33 @@ func (mr *MockStoreMockRecorder) UpdateUserMeta(ctx, UserMeta any) *gomock.Call
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserMeta", reflect.TypeOf((*MockStore)(nil).UpdateUserMeta), ctx, UserMeta)
 }
+
+// UpdateWorkflowRun mocks base method.
+func (m *MockStore) UpdateWorkflowRun(ctx context.Context, run *types.WorkflowRun) (*types.WorkflowRun, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateWorkflowRun", ctx, run)
+	ret0, _ := ret[0].(*types.WorkflowRun)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateWorkflowRun indicates an expected call of UpdateWorkflowRun.
+func (mr *MockStoreMockRecorder) UpdateWorkflowRun(ctx, run any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateWorkflowRun", reflect.TypeOf((*MockStore)(nil).UpdateWorkflowRun), ctx, run)
+}
+
+// UpsertToolOAuthToken mocks base method.
+func (m *MockStore) UpsertToolOAuthToken(ctx context.Context, token *types.ToolOAuthToken) (*types.ToolOAuthToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertToolOAuthToken", ctx, token)
+	ret0, _ := ret[0].(*types.ToolOAuthToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpsertToolOAuthToken indicates an expected call of UpsertToolOAuthToken.
+func (mr *MockStoreMockRecorder) UpsertToolOAuthToken(ctx, token any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertToolOAuthToken", reflect.TypeOf((*MockStore)(nil).UpsertToolOAuthToken), ctx, token)
+}
@@ -0,0 +1,104 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/helixml/helix/api/pkg/system"
+	"github.com/helixml/helix/api/pkg/types"
+	"gorm.io/gorm"
+)
+
+func (s *PostgresStore) CreateToolApproval(ctx context.Context, approval *types.ToolApproval) (*types.ToolApproval, error) {
+	if approval.ID == "" {
+		approval.ID = system.GenerateToolApprovalID()
+	}
+
+	if approval.Owner == "" {
+		return nil, fmt.Errorf("owner not specified")
+	}
+
+	approval.Created = time.Now()
+	approval.Updated = approval.Created
+
+	err := s.gdb.WithContext(ctx).Create(approval).Error
+	if err != nil {
+		return nil, err
+	}
+	return s.GetToolApproval(ctx, approval.ID)
+}
+
+func (s *PostgresStore) GetToolApproval(ctx context.Context, id string) (*types.ToolApproval, error) {
+	if id == "" {
+		return nil, fmt.Errorf("id not specified")
+	}
+
+	var approval types.ToolApproval
+	err := s.gdb.WithContext(ctx).Where("id = ?", id).First(&approval).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &approval, nil
+}
+
+func (s *PostgresStore) ListToolApprovals(ctx context.Context, q *ListToolApprovalsQuery) ([]*types.ToolApproval, error) {
+	if q.Owner == "" && q.AppID == "" {
+		return nil, fmt.Errorf("owner or app not specified")
+	}
+
+	var approvals []*types.ToolApproval
+	err := s.gdb.WithContext(ctx).Where(&types.ToolApproval{
+		Owner:     q.Owner,
+		AppID:     q.AppID,
+		SessionID: q.SessionID,
+		State:     q.State,
+	}).Order("created DESC").Find(&approvals).Error
+	if err != nil {
+		return nil, err
+	}
+	return approvals, nil
+}
+
+// DecideToolApproval records the decision on a pending approval. Returns ErrNotFound if the
+// approval has already been decided so that an action can't run twice.
+func (s *PostgresStore) DecideToolApproval(ctx context.Context, approval *types.ToolApproval) (*types.ToolApproval, error) {
+	if approval.ID == "" {
+		return nil, fmt.Errorf("id not specified")
+	}
+
+	res := s.gdb.WithContext(ctx).Model(&types.ToolApproval{}).
+		Where("id = ? AND state = ?", approval.ID, types.ToolApprovalStatePending).
+		Updates(map[string]interface{}{
+			"updated":    time.Now(),
+			"state":      approval.State,
+			"decided_by": approval.DecidedBy,
+			"decided_at": approval.DecidedAt,
+			"reason":     approval.Reason,
+		})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrNotFound
+	}
+	return s.GetToolApproval(ctx, approval.ID)
+}
+
+func (s *PostgresStore) UpdateToolApproval(ctx context.Context, approval *types.ToolApproval) (*types.ToolApproval, error) {
+	if approval.ID == "" {
+		return nil, fmt.Errorf("id not specified")
+	}
+
+	approval.Updated = time.Now()
+
+	err := s.gdb.WithContext(ctx).Save(approval).Error
+	if err != nil {
+		return nil, err
+	}
+	return s.GetToolApproval(ctx, approval.ID)
+}
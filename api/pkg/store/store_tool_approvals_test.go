@@ -0,0 +1,48 @@
+package store
+
+import (
+	"time"
+
+	"github.com/helixml/helix/api/pkg/system"
+	"github.com/helixml/helix/api/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (suite *PostgresStoreTestSuite) TestToolApprovalDecideOnce() {
+	owner := "test-owner-" + system.GenerateUUID()
+
+	created, err := suite.db.CreateToolApproval(suite.ctx, &types.ToolApproval{
+		Owner:    owner,
+		ToolName: "contacts",
+		Action:   "createContact",
+		State:    types.ToolApprovalStatePending,
+		Request: types.ToolApprovalRequest{
+			Method:     "POST",
+			URL:        "https://example.com/contacts",
+			Parameters: map[string]interface{}{"name": "Ada"},
+		},
+	})
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "Ada", created.Request.Parameters["name"])
+
+	pending, err := suite.db.ListToolApprovals(suite.ctx, &ListToolApprovalsQuery{
+		Owner: owner,
+		State: types.ToolApprovalStatePending,
+	})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), pending, 1)
+
+	created.State = types.ToolApprovalStateApproved
+	created.DecidedBy = owner
+	created.DecidedAt = time.Now()
+
+	decided, err := suite.db.DecideToolApproval(suite.ctx, created)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), types.ToolApprovalStateApproved, decided.State)
+
+	// An approval can only be decided once, so the action can't run twice
+	created.State = types.ToolApprovalStateDenied
+	_, err = suite.db.DecideToolApproval(suite.ctx, created)
+	require.ErrorIs(suite.T(), err, ErrNotFound)
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/helixml/helix/api/pkg/system"
+	"github.com/helixml/helix/api/pkg/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UpsertToolOAuthToken stores the user's token for the provider, replacing any existing one
+func (s *PostgresStore) UpsertToolOAuthToken(ctx context.Context, token *types.ToolOAuthToken) (*types.ToolOAuthToken, error) {
+	if token.Owner == "" {
+		return nil, fmt.Errorf("owner not specified")
+	}
+
+	if token.LinkedFor == "" {
+		return nil, fmt.Errorf("linked for not specified")
+	}
+
+	if token.TokenURL == "" {
+		return nil, fmt.Errorf("token URL not specified")
+	}
+
+	if token.ID == "" {
+		token.ID = system.GenerateToolOAuthTokenID()
+	}
+
+	now := time.Now()
+	if token.Created.IsZero() {
+		token.Created = now
+	}
+	token.Updated = now
+
+	err := s.gdb.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "owner"}, {Name: "linked_for"}, {Name: "token_url"}, {Name: "client_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"updated", "owner_type", "access_token", "refresh_token", "token_type", "expiry"}),
+	}).Create(token).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetToolOAuthToken(ctx, &GetToolOAuthTokenQuery{
+		Owner:     token.Owner,
+		LinkedFor: token.LinkedFor,
+		TokenURL:  token.TokenURL,
+		ClientID:  token.ClientID,
+	})
+}
+
+func (s *PostgresStore) GetToolOAuthToken(ctx context.Context, q *GetToolOAuthTokenQuery) (*types.ToolOAuthToken, error) {
+	if q.Owner == "" {
+		return nil, fmt.Errorf("owner not specified")
+	}
+
+	if q.LinkedFor == "" {
+		return nil, fmt.Errorf("linked for not specified")
+	}
+
+	var token types.ToolOAuthToken
+	err := s.gdb.WithContext(ctx).
+		Where("owner = ? AND linked_for = ? AND token_url = ? AND client_id = ?", q.Owner, q.LinkedFor, q.TokenURL, q.ClientID).
+		First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (s *PostgresStore) DeleteToolOAuthToken(ctx context.Context, id string) error {
+	if id == "" {
+		return fmt.Errorf("id not specified")
+	}
+
+	return s.gdb.WithContext(ctx).Delete(&types.ToolOAuthToken{
+		ID: id,
+	}).Error
+}
@@ -0,0 +1,68 @@
+package store
+
+import (
+	"time"
+
+	"github.com/helixml/helix/api/pkg/system"
+	"github.com/helixml/helix/api/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (suite *PostgresStoreTestSuite) TestToolOAuthTokenUpsert() {
+	owner := "test-owner-" + system.GenerateUUID()
+	query := &GetToolOAuthTokenQuery{
+		Owner:     owner,
+		LinkedFor: "app_1",
+		TokenURL:  "https://auth.example.com/token",
+		ClientID:  "helix",
+	}
+
+	_, err := suite.db.GetToolOAuthToken(suite.ctx, query)
+	require.ErrorIs(suite.T(), err, ErrNotFound)
+
+	created, err := suite.db.UpsertToolOAuthToken(suite.ctx, &types.ToolOAuthToken{
+		Owner:       owner,
+		LinkedFor:   query.LinkedFor,
+		TokenURL:    query.TokenURL,
+		ClientID:    query.ClientID,
+		AccessToken: []byte("first"),
+		Expiry:      time.Now().Add(time.Hour),
+	})
+	require.NoError(suite.T(), err)
+	assert.NotEmpty(suite.T(), created.ID)
+
+	// A second link replaces the first token
+	updated, err := suite.db.UpsertToolOAuthToken(suite.ctx, &types.ToolOAuthToken{
+		Owner:       owner,
+		LinkedFor:   query.LinkedFor,
+		TokenURL:    query.TokenURL,
+		ClientID:    query.ClientID,
+		AccessToken: []byte("second"),
+	})
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), created.ID, updated.ID)
+	assert.Equal(suite.T(), []byte("second"), updated.AccessToken)
+
+	// Linking the same provider for another app keeps a token of its own
+	other, err := suite.db.UpsertToolOAuthToken(suite.ctx, &types.ToolOAuthToken{
+		Owner:       owner,
+		LinkedFor:   "app_2",
+		TokenURL:    query.TokenURL,
+		ClientID:    query.ClientID,
+		AccessToken: []byte("other"),
+	})
+	require.NoError(suite.T(), err)
+	assert.NotEqual(suite.T(), created.ID, other.ID)
+
+	token, err := suite.db.GetToolOAuthToken(suite.ctx, query)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []byte("second"), token.AccessToken)
+
+	suite.T().Cleanup(func() {
+		err := suite.db.DeleteToolOAuthToken(suite.ctx, created.ID)
+		assert.NoError(suite.T(), err)
+		err = suite.db.DeleteToolOAuthToken(suite.ctx, other.ID)
+		assert.NoError(suite.T(), err)
+	})
+}
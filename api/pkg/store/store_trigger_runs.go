@@ -0,0 +1,96 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/helixml/helix/api/pkg/system"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+type ListTriggerRunsQuery struct {
+	AppID string
+	Type  types.TriggerType
+	// ThreadID only lists the runs of the thread
+	ThreadID string
+	// Limit defaults to 100
+	Limit int
+}
+
+func (s *PostgresStore) CreateTriggerRun(ctx context.Context, run *types.TriggerRun) (*types.TriggerRun, error) {
+	if run.AppID == "" {
+		return nil, fmt.Errorf("app id not specified")
+	}
+
+	if run.ID == "" {
+		run.ID = system.GenerateTriggerRunID()
+	}
+
+	err := s.gdb.WithContext(ctx).Create(run).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetTriggerRun(ctx, run.ID)
+}
+
+func (s *PostgresStore) UpdateTriggerRun(ctx context.Context, run *types.TriggerRun) (*types.TriggerRun, error) {
+	if run.ID == "" {
+		return nil, fmt.Errorf("id not specified")
+	}
+
+	err := s.gdb.WithContext(ctx).Save(run).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetTriggerRun(ctx, run.ID)
+}
+
+func (s *PostgresStore) GetTriggerRun(ctx context.Context, id string) (*types.TriggerRun, error) {
+	if id == "" {
+		return nil, fmt.Errorf("id not specified")
+	}
+
+	var run types.TriggerRun
+	err := s.gdb.WithContext(ctx).Where("id = ?", id).First(&run).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &run, nil
+}
+
+// ListTriggerRuns lists the app's trigger runs, newest first
+func (s *PostgresStore) ListTriggerRuns(ctx context.Context, q *ListTriggerRunsQuery) ([]*types.TriggerRun, error) {
+	if q.AppID == "" {
+		return nil, fmt.Errorf("app id not specified")
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+
+	query := s.gdb.WithContext(ctx).Where("app_id = ?", q.AppID)
+	if q.Type != "" {
+		query = query.Where("type = ?", q.Type)
+	}
+	if q.ThreadID != "" {
+		query = query.Where("thread_id = ?", q.ThreadID)
+	}
+
+	var runs []*types.TriggerRun
+	err := query.Order("started DESC").Limit(limit).Find(&runs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return runs, nil
+}
@@ -0,0 +1,61 @@
+package store
+
+import (
+	"time"
+
+	"github.com/helixml/helix/api/pkg/system"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+func (suite *PostgresStoreTestSuite) TestTriggerRuns() {
+	app, err := suite.db.CreateApp(suite.ctx, &types.App{
+		Owner:     "test-" + system.GenerateUUID(),
+		OwnerType: types.OwnerTypeUser,
+	})
+	suite.Require().NoError(err)
+
+	suite.T().Cleanup(func() {
+		err := suite.db.DeleteApp(suite.ctx, app.ID)
+		suite.NoError(err)
+	})
+
+	first, err := suite.db.CreateTriggerRun(suite.ctx, &types.TriggerRun{
+		AppID:   app.ID,
+		Type:    types.TriggerTypeCron,
+		Status:  types.TriggerRunStatusRunning,
+		Started: time.Now().Add(-time.Hour),
+		Input:   "What's new?",
+	})
+	suite.Require().NoError(err)
+	suite.NotEmpty(first.ID)
+
+	first.Status = types.TriggerRunStatusSuccess
+	first.Output = "Nothing"
+	first.Deliveries = types.TriggerRunDeliveries{{Sink: "webhook"}, {Sink: "email", Error: "no SMTP server"}}
+	_, err = suite.db.UpdateTriggerRun(suite.ctx, first)
+	suite.Require().NoError(err)
+
+	second, err := suite.db.CreateTriggerRun(suite.ctx, &types.TriggerRun{
+		AppID:    app.ID,
+		Type:     types.TriggerTypeCron,
+		Status:   types.TriggerRunStatusRunning,
+		Started:  time.Now(),
+		ThreadID: "<thread@example.com>",
+	})
+	suite.Require().NoError(err)
+
+	runs, err := suite.db.ListTriggerRuns(suite.ctx, &ListTriggerRunsQuery{AppID: app.ID, Type: types.TriggerTypeCron})
+	suite.Require().NoError(err)
+	suite.Require().Len(runs, 2)
+	suite.Equal(second.ID, runs[0].ID)
+	suite.Equal(types.TriggerRunStatusSuccess, runs[1].Status)
+	suite.Equal("no SMTP server", runs[1].Deliveries[1].Error)
+
+	runs, err = suite.db.ListTriggerRuns(suite.ctx, &ListTriggerRunsQuery{AppID: app.ID, ThreadID: "<thread@example.com>"})
+	suite.Require().NoError(err)
+	suite.Require().Len(runs, 1)
+	suite.Equal(second.ID, runs[0].ID)
+
+	_, err = suite.db.GetTriggerRun(suite.ctx, "trun_missing")
+	suite.ErrorIs(err, ErrNotFound)
+}
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/helixml/helix/api/pkg/system"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+type ListWorkflowRunsQuery struct {
+	// AppID is required unless the runs are listed by status
+	AppID    string
+	Workflow string
+	Status   types.WorkflowRunStatus
+	// Limit defaults to 100
+	Limit int
+}
+
+func (s *PostgresStore) CreateWorkflowRun(ctx context.Context, run *types.WorkflowRun) (*types.WorkflowRun, error) {
+	if run.AppID == "" {
+		return nil, fmt.Errorf("app id not specified")
+	}
+
+	if run.ID == "" {
+		run.ID = system.GenerateWorkflowRunID()
+	}
+
+	err := s.gdb.WithContext(ctx).Create(run).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetWorkflowRun(ctx, run.ID)
+}
+
+func (s *PostgresStore) UpdateWorkflowRun(ctx context.Context, run *types.WorkflowRun) (*types.WorkflowRun, error) {
+	if run.ID == "" {
+		return nil, fmt.Errorf("id not specified")
+	}
+
+	err := s.gdb.WithContext(ctx).Save(run).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetWorkflowRun(ctx, run.ID)
+}
+
+// ClaimWorkflowRun marks the running run as updated now if it wasn't updated since staleBefore.
+// It's how a replica takes over a run that was interrupted without another replica taking it
+// over too, and how the replica that executes a run keeps it. It returns false when the run was
+// updated since or isn't running.
+func (s *PostgresStore) ClaimWorkflowRun(ctx context.Context, id string, staleBefore time.Time) (bool, error) {
+	if id == "" {
+		return false, fmt.Errorf("id not specified")
+	}
+
+	result := s.gdb.WithContext(ctx).
+		Model(&types.WorkflowRun{}).
+		Where("id = ? AND status = ? AND updated < ?", id, types.WorkflowRunStatusRunning, staleBefore).
+		Update("updated", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+func (s *PostgresStore) GetWorkflowRun(ctx context.Context, id string) (*types.WorkflowRun, error) {
+	if id == "" {
+		return nil, fmt.Errorf("id not specified")
+	}
+
+	var run types.WorkflowRun
+	err := s.gdb.WithContext(ctx).Where("id = ?", id).First(&run).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &run, nil
+}
+
+// ListWorkflowRuns lists workflow runs, newest first
+func (s *PostgresStore) ListWorkflowRuns(ctx context.Context, q *ListWorkflowRunsQuery) ([]*types.WorkflowRun, error) {
+	if q.AppID == "" && q.Status == "" {
+		return nil, fmt.Errorf("app id not specified")
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+
+	query := s.gdb.WithContext(ctx)
+	if q.AppID != "" {
+		query = query.Where("app_id = ?", q.AppID)
+	}
+	if q.Workflow != "" {
+		query = query.Where("workflow = ?", q.Workflow)
+	}
+	if q.Status != "" {
+		query = query.Where("status = ?", q.Status)
+	}
+
+	var runs []*types.WorkflowRun
+	err := query.Order("started DESC").Limit(limit).Find(&runs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return runs, nil
+}
@@ -0,0 +1,92 @@
+package store
+
+import (
+	"time"
+
+	"github.com/helixml/helix/api/pkg/system"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+func (suite *PostgresStoreTestSuite) TestWorkflowRuns() {
+	app, err := suite.db.CreateApp(suite.ctx, &types.App{
+		Owner:     "test-" + system.GenerateUUID(),
+		OwnerType: types.OwnerTypeUser,
+	})
+	suite.Require().NoError(err)
+
+	suite.T().Cleanup(func() {
+		err := suite.db.DeleteApp(suite.ctx, app.ID)
+		suite.NoError(err)
+	})
+
+	first, err := suite.db.CreateWorkflowRun(suite.ctx, &types.WorkflowRun{
+		AppID:    app.ID,
+		Workflow: "summarise",
+		Status:   types.WorkflowRunStatusRunning,
+		Started:  time.Now().Add(-time.Hour),
+		Inputs:   types.WorkflowValues{"url": "https://docs.helix.ml"},
+		Steps: types.WorkflowStepRuns{
+			{Name: "fetch", Status: types.WorkflowStepStatusSuccess, Output: []interface{}{"a", "b"}},
+			{Name: "summarise", Status: types.WorkflowStepStatusPending},
+		},
+	})
+	suite.Require().NoError(err)
+	suite.NotEmpty(first.ID)
+	suite.Equal("https://docs.helix.ml", first.Inputs["url"])
+	suite.Equal([]interface{}{"a", "b"}, first.Steps[0].Output)
+
+	first.Status = types.WorkflowRunStatusFailed
+	first.Steps[1].Status = types.WorkflowStepStatusFailed
+	first.Steps[1].Error = "model is overloaded"
+	_, err = suite.db.UpdateWorkflowRun(suite.ctx, first)
+	suite.Require().NoError(err)
+
+	second, err := suite.db.CreateWorkflowRun(suite.ctx, &types.WorkflowRun{
+		AppID:    app.ID,
+		Workflow: "triage",
+		Status:   types.WorkflowRunStatusRunning,
+		Started:  time.Now(),
+	})
+	suite.Require().NoError(err)
+
+	runs, err := suite.db.ListWorkflowRuns(suite.ctx, &ListWorkflowRunsQuery{AppID: app.ID})
+	suite.Require().NoError(err)
+	suite.Require().Len(runs, 2)
+	suite.Equal(second.ID, runs[0].ID)
+	suite.Equal("model is overloaded", runs[1].Steps[1].Error)
+
+	runs, err = suite.db.ListWorkflowRuns(suite.ctx, &ListWorkflowRunsQuery{AppID: app.ID, Workflow: "summarise"})
+	suite.Require().NoError(err)
+	suite.Require().Len(runs, 1)
+	suite.Equal(first.ID, runs[0].ID)
+
+	runs, err = suite.db.ListWorkflowRuns(suite.ctx, &ListWorkflowRunsQuery{Status: types.WorkflowRunStatusRunning})
+	suite.Require().NoError(err)
+	suite.Contains(workflowRunIDs(runs), second.ID)
+	suite.NotContains(workflowRunIDs(runs), first.ID)
+
+	// Only one replica claims a run that stopped being updated
+	staleBefore := time.Now().Add(time.Minute)
+	claimed, err := suite.db.ClaimWorkflowRun(suite.ctx, second.ID, staleBefore)
+	suite.Require().NoError(err)
+	suite.True(claimed)
+
+	claimed, err = suite.db.ClaimWorkflowRun(suite.ctx, second.ID, time.Now().Add(-time.Minute))
+	suite.Require().NoError(err)
+	suite.False(claimed)
+
+	claimed, err = suite.db.ClaimWorkflowRun(suite.ctx, first.ID, staleBefore)
+	suite.Require().NoError(err)
+	suite.False(claimed)
+
+	_, err = suite.db.GetWorkflowRun(suite.ctx, "wfrun_missing")
+	suite.ErrorIs(err, ErrNotFound)
+}
+
+func workflowRunIDs(runs []*types.WorkflowRun) []string {
+	var ids []string
+	for _, run := range runs {
+		ids = append(ids, run.ID)
+	}
+	return ids
+}
@@ -87,12 +87,17 @@ func GetTarStream(localPath string) (io.Reader, error) {
 }
 
 func ExpandTarBuffer(buf *bytes.Buffer, localPath string) error {
+	return ExpandTarReader(buf, localPath)
+}
+
+// ExpandTarReader replaces localPath with the contents of the tar stream
+func ExpandTarReader(r io.Reader, localPath string) error {
 	err := os.RemoveAll(localPath)
 	if err != nil {
 		return err
 	}
 	// Create a new tar reader
-	tr := tar.NewReader(buf)
+	tr := tar.NewReader(r)
 
 	// Iterate through tar headers (files)
 	for {
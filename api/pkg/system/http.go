@@ -86,6 +86,13 @@ func NewHTTPError404(message string) *HTTPError {
 	}
 }
 
+func NewHTTPError409(message string) *HTTPError {
+	return &HTTPError{
+		StatusCode: http.StatusConflict,
+		Message:    message,
+	}
+}
+
 func NewHTTPError500(message string) *HTTPError {
 	return &HTTPError{
 		StatusCode: http.StatusInternalServerError,
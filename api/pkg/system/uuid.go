@@ -21,6 +21,14 @@ const (
 	KnowledgeVersionPrefix    = "knov_"
 	SecretPrefix              = "sec_"
 	TestRunPrefix             = "testrun_"
+	ToolOAuthTokenPrefix      = "oat_"
+	ToolApprovalPrefix        = "tap_"
+	AppRevisionPrefix         = "apprev_"
+	AppEnvironmentPrefix      = "appenv_"
+	TriggerRunPrefix          = "trun_"
+	WorkflowRunPrefix         = "wfrun_"
+	MemoryPrefix              = "mem_"
+	GuardrailOutcomePrefix    = "gro_"
 )
 
 func GenerateUUID() string {
@@ -71,6 +79,22 @@ func GenerateKnowledgeVersionID() string {
 	return fmt.Sprintf("%s%s", KnowledgeVersionPrefix, newID())
 }
 
+func GenerateToolOAuthTokenID() string {
+	return fmt.Sprintf("%s%s", ToolOAuthTokenPrefix, newID())
+}
+
+func GenerateToolApprovalID() string {
+	return fmt.Sprintf("%s%s", ToolApprovalPrefix, newID())
+}
+
+func GenerateAppRevisionID() string {
+	return fmt.Sprintf("%s%s", AppRevisionPrefix, newID())
+}
+
+func GenerateAppEnvironmentID() string {
+	return fmt.Sprintf("%s%s", AppEnvironmentPrefix, newID())
+}
+
 func GenerateSecretID() string {
 	return fmt.Sprintf("%s%s", SecretPrefix, newID())
 }
@@ -85,3 +109,19 @@ func GenerateVersion() string {
 func GenerateTestRunID() string {
 	return fmt.Sprintf("%s%s", TestRunPrefix, newID())
 }
+
+func GenerateTriggerRunID() string {
+	return fmt.Sprintf("%s%s", TriggerRunPrefix, newID())
+}
+
+func GenerateWorkflowRunID() string {
+	return fmt.Sprintf("%s%s", WorkflowRunPrefix, newID())
+}
+
+func GenerateMemoryID() string {
+	return fmt.Sprintf("%s%s", MemoryPrefix, newID())
+}
+
+func GenerateGuardrailOutcomeID() string {
+	return fmt.Sprintf("%s%s", GuardrailOutcomePrefix, newID())
+}
@@ -80,6 +80,10 @@ func (c *ChainStrategy) isActionable(ctx context.Context, sessionID, interaction
 		}, nil
 	}
 
+	if c.mcp != nil {
+		c.discoverMCPTools(ctx, tools)
+	}
+
 	started := time.Now()
 
 	systemPrompt, err := c.getActionableSystemPrompt(tools, opts)
@@ -206,6 +210,25 @@ func (c *ChainStrategy) getActionableSystemPrompt(tools []*types.Tool, options O
 				Description: tool.Description,
 				ToolType:    string(tool.ToolType),
 			})
+		case types.ToolTypeMCP:
+			if tool.Config.MCP == nil {
+				continue
+			}
+			// Each of the server's tools and resources is an action, like the operations of an API
+			for _, t := range tool.Config.MCP.Tools {
+				modelTools = append(modelTools, &modelTool{
+					Name:        t.Name,
+					Description: t.Description,
+					ToolType:    string(tool.ToolType),
+				})
+			}
+			for _, r := range tool.Config.MCP.Resources {
+				modelTools = append(modelTools, &modelTool{
+					Name:        r.Name,
+					Description: fmt.Sprintf("Read %s. %s", r.URI, r.Description),
+					ToolType:    string(tool.ToolType),
+				})
+			}
 		}
 
 	}
@@ -0,0 +1,461 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+const (
+	// mcpIdleTimeout is how long a connection to an MCP server, and for stdio servers the
+	// process, is kept after it was last used
+	mcpIdleTimeout = 10 * time.Minute
+	mcpCallTimeout = 2 * time.Minute
+	// mcpConnectTimeout covers connecting to the server, or starting it, and discovering its tools
+	mcpConnectTimeout = time.Minute
+)
+
+// ErrMCPStdioDisabled is returned for MCP servers that would run as a command on the control plane
+// when that isn't allowed
+var ErrMCPStdioDisabled = errors.New("running MCP servers as commands is disabled, set TOOLS_MCP_ALLOW_STDIO=true to allow it")
+
+type mcpDialer func(ctx context.Context, cfg *types.ToolMCPConfig) (mcpclient.MCPClient, error)
+
+// MCPClients keeps connections to MCP servers open between calls, along with the tools and
+// resources they offer
+type MCPClients struct {
+	allowStdio bool
+	dial       mcpDialer
+
+	mu      sync.Mutex
+	clients map[string]*mcpConnection
+	dials   singleflight.Group // Connections being made, by key
+}
+
+type mcpConnection struct {
+	client    mcpclient.MCPClient
+	tools     []*types.ToolMCPTool
+	resources []*types.ToolMCPResource
+	lastUsed  time.Time
+}
+
+func NewMCPClients(allowStdio bool) *MCPClients {
+	return &MCPClients{
+		allowStdio: allowStdio,
+		dial:       dialMCP,
+		clients:    make(map[string]*mcpConnection),
+	}
+}
+
+func dialMCP(ctx context.Context, cfg *types.ToolMCPConfig) (mcpclient.MCPClient, error) {
+	var client mcpclient.MCPClient
+
+	switch {
+	case cfg.URL != "":
+		sse, err := mcpclient.NewSSEMCPClient(cfg.URL)
+		if err != nil {
+			return nil, err
+		}
+		// The event stream outlives this request, it's closed with the client
+		streamCtx, cancel := context.WithCancel(context.Background())
+		// Connecting is still bounded by the dial's context
+		stop := context.AfterFunc(ctx, cancel)
+		err = sse.Start(streamCtx)
+		if !stop() && err == nil {
+			err = ctx.Err()
+		}
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to connect to MCP server: %w", err)
+		}
+		client = &sseMCPClient{SSEMCPClient: sse, cancel: cancel}
+	case cfg.Command != "":
+		var env []string
+		for k, v := range cfg.Env {
+			env = append(env, k+"="+v)
+		}
+		stdio, err := mcpclient.NewStdioMCPClient(cfg.Command, env, cfg.Args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start MCP server: %w", err)
+		}
+		client = stdio
+	default:
+		return nil, fmt.Errorf("MCP server needs a command or URL")
+	}
+
+	var req mcp.InitializeRequest
+	req.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	req.Params.ClientInfo = mcp.Implementation{
+		Name:    "helix",
+		Version: "1.0.0",
+	}
+
+	if _, err := client.Initialize(ctx, req); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("failed to initialize MCP server: %w", err)
+	}
+
+	return client, nil
+}
+
+// sseMCPClient ends the event stream when closed, the client itself only stops waiting for responses
+type sseMCPClient struct {
+	*mcpclient.SSEMCPClient
+	cancel context.CancelFunc
+}
+
+func (c *sseMCPClient) Close() error {
+	c.cancel()
+	return c.SSEMCPClient.Close()
+}
+
+func mcpConnectionKey(cfg *types.ToolMCPConfig) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s\n", cfg.URL, cfg.Command, strings.Join(cfg.Args, "\x00"))
+
+	names := make([]string, 0, len(cfg.Env))
+	for name := range cfg.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(h, "%s=%s\n", name, cfg.Env[name])
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// connect returns an open connection to the server, connecting and discovering its tools and
+// resources if there isn't one. Connecting, and for stdio servers starting the server, can take a
+// while, so it's done without the lock and requests for the same server wait for the same
+// connection.
+func (m *MCPClients) connect(ctx context.Context, cfg *types.ToolMCPConfig) (*mcpConnection, error) {
+	if cfg.URL == "" && cfg.Command != "" && !m.allowStdio {
+		return nil, ErrMCPStdioDisabled
+	}
+
+	key := mcpConnectionKey(cfg)
+
+	if conn, ok := m.connection(key); ok {
+		return conn, nil
+	}
+
+	result := m.dials.DoChan(key, func() (interface{}, error) {
+		// Made by a request that was waiting for the lock rather than the dial
+		if conn, ok := m.connection(key); ok {
+			return conn, nil
+		}
+		return m.dialConnection(key, cfg)
+	})
+
+	select {
+	case res := <-result:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*mcpConnection), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// connection returns the open connection for the key, closing idle ones first
+func (m *MCPClients) connection(key string) (*mcpConnection, bool) {
+	m.mu.Lock()
+	idle := m.removeIdle()
+	conn, ok := m.clients[key]
+	if ok {
+		conn.lastUsed = time.Now()
+	}
+	m.mu.Unlock()
+
+	// Closing can wait on the server, e.g. for a stdio server to exit, so it's done without the lock
+	for _, idleConn := range idle {
+		if err := idleConn.client.Close(); err != nil {
+			log.Warn().Err(err).Msg("failed to close idle MCP client")
+		}
+	}
+
+	return conn, ok
+}
+
+// dialConnection connects to the server and discovers its tools. The connection is shared by
+// every request waiting for it, so it isn't tied to any one of them.
+func (m *MCPClients) dialConnection(key string, cfg *types.ToolMCPConfig) (*mcpConnection, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), mcpConnectTimeout)
+	defer cancel()
+
+	client, err := m.dial(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &mcpConnection{
+		client:   client,
+		lastUsed: time.Now(),
+	}
+
+	if err := conn.discover(ctx); err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+
+	m.mu.Lock()
+	m.clients[key] = conn
+	m.mu.Unlock()
+
+	return conn, nil
+}
+
+// removeIdle removes connections that haven't been used for a while and returns them so that
+// they can be closed, must be called with the lock held
+func (m *MCPClients) removeIdle() []*mcpConnection {
+	var idle []*mcpConnection
+	for key, conn := range m.clients {
+		if time.Since(conn.lastUsed) > mcpIdleTimeout {
+			idle = append(idle, conn)
+			delete(m.clients, key)
+		}
+	}
+	return idle
+}
+
+// disconnect drops a connection that failed, so that the next call reconnects
+func (m *MCPClients) disconnect(cfg *types.ToolMCPConfig) {
+	key := mcpConnectionKey(cfg)
+
+	m.mu.Lock()
+	conn, ok := m.clients[key]
+	delete(m.clients, key)
+	m.mu.Unlock()
+
+	if ok {
+		_ = conn.client.Close()
+	}
+}
+
+// Close closes all connections, stopping stdio servers
+func (m *MCPClients) Close() {
+	m.mu.Lock()
+	clients := m.clients
+	m.clients = make(map[string]*mcpConnection)
+	m.mu.Unlock()
+
+	for _, conn := range clients {
+		_ = conn.client.Close()
+	}
+}
+
+func (conn *mcpConnection) discover(ctx context.Context) error {
+	tools, err := conn.client.ListTools(ctx, mcp.ListToolsRequest{})
+	if err != nil {
+		return fmt.Errorf("failed to list MCP tools: %w", err)
+	}
+
+	for _, t := range tools.Tools {
+		schema := map[string]interface{}{
+			"type":       t.InputSchema.Type,
+			"properties": t.InputSchema.Properties,
+		}
+		if len(t.InputSchema.Required) > 0 {
+			schema["required"] = t.InputSchema.Required
+		}
+
+		conn.tools = append(conn.tools, &types.ToolMCPTool{
+			Name:        t.Name,
+			Description: t.Description,
+			InputSchema: schema,
+		})
+	}
+
+	// Resources are optional, plenty of servers only have tools
+	resources, err := conn.client.ListResources(ctx, mcp.ListResourcesRequest{})
+	if err != nil {
+		log.Debug().Err(err).Msg("MCP server has no resources")
+		return nil
+	}
+
+	for _, r := range resources.Resources {
+		conn.resources = append(conn.resources, &types.ToolMCPResource{
+			URI:         r.URI,
+			Name:        r.Name,
+			Description: r.Description,
+			MIMEType:    r.MIMEType,
+		})
+	}
+
+	return nil
+}
+
+// Discover fills in the tools and resources the MCP server offers
+func (m *MCPClients) Discover(ctx context.Context, tool *types.Tool) error {
+	conn, err := m.connect(ctx, tool.Config.MCP)
+	if err != nil {
+		return err
+	}
+
+	tool.Config.MCP.Tools = conn.tools
+	tool.Config.MCP.Resources = conn.resources
+	return nil
+}
+
+// CallTool calls a tool on the MCP server and returns its text content
+func (m *MCPClients) CallTool(ctx context.Context, cfg *types.ToolMCPConfig, name string, args map[string]interface{}) (string, error) {
+	conn, err := m.connect(ctx, cfg)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, mcpCallTimeout)
+	defer cancel()
+
+	var req mcp.CallToolRequest
+	req.Params.Name = name
+	req.Params.Arguments = args
+
+	result, err := conn.client.CallTool(ctx, req)
+	if err != nil {
+		m.disconnect(cfg)
+		return "", fmt.Errorf("failed to call MCP tool %s: %w", name, err)
+	}
+
+	contents := make([]interface{}, 0, len(result.Content))
+	for _, content := range result.Content {
+		contents = append(contents, content)
+	}
+
+	text := mcpContentText(contents)
+	if result.IsError {
+		return "", fmt.Errorf("MCP tool %s failed: %s", name, text)
+	}
+	return text, nil
+}
+
+// ReadResource reads a resource from the MCP server and returns its text content
+func (m *MCPClients) ReadResource(ctx context.Context, cfg *types.ToolMCPConfig, uri string) (string, error) {
+	conn, err := m.connect(ctx, cfg)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, mcpCallTimeout)
+	defer cancel()
+
+	var req mcp.ReadResourceRequest
+	req.Params.URI = uri
+
+	result, err := conn.client.ReadResource(ctx, req)
+	if err != nil {
+		m.disconnect(cfg)
+		return "", fmt.Errorf("failed to read MCP resource %s: %w", uri, err)
+	}
+
+	return mcpContentText(result.Contents), nil
+}
+
+// mcpContent covers the fields of text, image and embedded resource content that can be given to
+// the model
+type mcpContent struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	URI      string `json:"uri"`
+	MIMEType string `json:"mimeType"`
+	Resource *struct {
+		URI      string `json:"uri"`
+		MIMEType string `json:"mimeType"`
+		Text     string `json:"text"`
+	} `json:"resource"`
+}
+
+func mcpContentText(contents []interface{}) string {
+	var parts []string
+
+	for _, c := range contents {
+		bts, err := json.Marshal(c)
+		if err != nil {
+			continue
+		}
+		var content mcpContent
+		if err := json.Unmarshal(bts, &content); err != nil {
+			continue
+		}
+
+		switch {
+		case content.Text != "":
+			parts = append(parts, content.Text)
+		case content.Resource != nil && content.Resource.Text != "":
+			parts = append(parts, content.Resource.Text)
+		case content.Type == "image":
+			parts = append(parts, fmt.Sprintf("[image %s]", content.MIMEType))
+		case content.URI != "":
+			parts = append(parts, fmt.Sprintf("[%s %s]", content.MIMEType, content.URI))
+		}
+	}
+
+	return strings.Join(parts, "\n\n")
+}
+
+// NewMCPToolsFromAPI describes each action of an API tool as an MCP tool, so that the actions can
+// be offered to MCP clients. Actions whose parameters can't be read from the schema are left out.
+func NewMCPToolsFromAPI(tool *types.Tool) []mcp.Tool {
+	var mcpTools []mcp.Tool
+
+	for _, action := range tool.Config.API.Actions {
+		parameters, err := GetParametersFromSchema(tool.Config.API.Schema, action.Name)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("tool", tool.Name).
+				Str("action", action.Name).
+				Msg("failed to get parameters from schema")
+			continue
+		}
+
+		opts := []mcp.ToolOption{mcp.WithDescription(action.Description)}
+		for _, param := range parameters {
+			opts = append(opts, withMCPParameter(param))
+		}
+
+		mcpTools = append(mcpTools, mcp.NewTool(action.Name, opts...))
+	}
+
+	return mcpTools
+}
+
+// withMCPParameter adds an API action parameter to the tool's input schema. The parameter's own
+// schema is used so that body fields keep their types, nested objects and arrays.
+func withMCPParameter(param *Parameter) mcp.ToolOption {
+	return func(t *mcp.Tool) {
+		property := map[string]interface{}{
+			"type": string(param.Type),
+		}
+		if param.Schema != nil {
+			bts, err := json.Marshal(param.Schema)
+			if err == nil {
+				_ = json.Unmarshal(bts, &property)
+			}
+		}
+		if param.Description != "" {
+			property["description"] = param.Description
+		}
+		t.InputSchema.Properties[param.Name] = property
+
+		if param.Required {
+			t.InputSchema.Required = append(t.InputSchema.Required, param.Name)
+		}
+	}
+}
@@ -0,0 +1,378 @@
+package tools
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+
+	oai "github.com/helixml/helix/api/pkg/openai"
+	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+// ErrOAuthAccountNotLinked is returned when a tool acts on behalf of a user who hasn't linked
+// their account with the tool's OAuth2 provider
+var ErrOAuthAccountNotLinked = errors.New("account not linked, connect it to use this tool")
+
+// OAuthStateTTL is how long the user has to complete the OAuth2 authorization flow
+const OAuthStateTTL = 15 * time.Minute
+
+// OAuthManager gets OAuth2 tokens for API tools. Client credentials tokens are cached in memory
+// until they expire, per-user tokens are kept encrypted in the store and refreshed as needed.
+type OAuthManager struct {
+	store      store.Store
+	httpClient *http.Client
+	gcm        cipher.AEAD // nil when no encryption key is configured
+
+	mu           sync.Mutex
+	clientTokens map[string]oauth2.TokenSource
+	userLocks    map[string]*sync.Mutex
+}
+
+// NewOAuthManager creates an OAuth manager. Without an encryption key users can't link accounts,
+// but client credentials still work.
+func NewOAuthManager(store store.Store, httpClient *http.Client, encryptionKey string) (*OAuthManager, error) {
+	m := &OAuthManager{
+		store:        store,
+		httpClient:   httpClient,
+		clientTokens: make(map[string]oauth2.TokenSource),
+		userLocks:    make(map[string]*sync.Mutex),
+	}
+
+	if encryptionKey != "" {
+		key := sha256.Sum256([]byte(encryptionKey))
+		block, err := aes.NewCipher(key[:])
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cipher: %w", err)
+		}
+		m.gcm, err = cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cipher: %w", err)
+		}
+	}
+
+	return m, nil
+}
+
+// httpContext makes the oauth2 package use our HTTP client
+func (m *OAuthManager) httpContext(ctx context.Context) context.Context {
+	if m.httpClient == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, oauth2.HTTPClient, m.httpClient)
+}
+
+// ClientCredentialsToken returns a token for the tool itself, reusing it until it expires
+func (m *OAuthManager) ClientCredentialsToken(ctx context.Context, auth *types.ToolAPIAuth) (*oauth2.Token, error) {
+	// The secret is part of the key so that rotating it takes effect straight away
+	secret := sha256.Sum256([]byte(auth.ClientSecret))
+	key := strings.Join([]string{auth.TokenURL, auth.ClientID, hex.EncodeToString(secret[:]), strings.Join(auth.Scopes, " ")}, "|")
+
+	m.mu.Lock()
+	source, ok := m.clientTokens[key]
+	if !ok {
+		config := &clientcredentials.Config{
+			ClientID:     auth.ClientID,
+			ClientSecret: auth.ClientSecret,
+			TokenURL:     auth.TokenURL,
+			Scopes:       auth.Scopes,
+		}
+		// The source outlives this request, so it mustn't use its context
+		source = config.TokenSource(m.httpContext(context.Background()))
+		m.clientTokens[key] = source
+	}
+	m.mu.Unlock()
+
+	token, err := source.Token()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get oauth2 token: %w", err)
+	}
+	return token, nil
+}
+
+func (m *OAuthManager) userConfig(auth *types.ToolAPIAuth, redirectURL string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     auth.ClientID,
+		ClientSecret: auth.ClientSecret,
+		Scopes:       auth.Scopes,
+		RedirectURL:  redirectURL,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  auth.AuthURL,
+			TokenURL: auth.TokenURL,
+		},
+	}
+}
+
+func (m *OAuthManager) userLock(userID, linkedFor string, auth *types.ToolAPIAuth) *sync.Mutex {
+	key := strings.Join([]string{userID, linkedFor, auth.TokenURL, auth.ClientID}, "|")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	lock, ok := m.userLocks[key]
+	if !ok {
+		lock = &sync.Mutex{}
+		m.userLocks[key] = lock
+	}
+	return lock
+}
+
+// OAuthLinkedFor is what users link their accounts for when they use the tool: the tool itself when
+// it's a standalone tool, otherwise the app that it's part of
+func OAuthLinkedFor(ctx context.Context, tool *types.Tool) string {
+	if tool.ID != "" {
+		return tool.ID
+	}
+	appID, _ := oai.GetContextAppID(ctx)
+	return appID
+}
+
+// UserToken returns the token of the account the user linked for the app or standalone tool,
+// refreshing it if it has expired. Returns ErrOAuthAccountNotLinked if the user hasn't linked it.
+func (m *OAuthManager) UserToken(ctx context.Context, userID, linkedFor string, auth *types.ToolAPIAuth) (*oauth2.Token, error) {
+	if m.gcm == nil {
+		return nil, fmt.Errorf("per-user oauth2 requires TOOLS_OAUTH_ENCRYPTION_KEY to be set")
+	}
+
+	// Refresh tokens can be single use, so don't refresh the same token concurrently
+	lock := m.userLock(userID, linkedFor, auth)
+	lock.Lock()
+	defer lock.Unlock()
+
+	stored, err := m.store.GetToolOAuthToken(ctx, &store.GetToolOAuthTokenQuery{
+		Owner:     userID,
+		LinkedFor: linkedFor,
+		TokenURL:  auth.TokenURL,
+		ClientID:  auth.ClientID,
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrOAuthAccountNotLinked
+		}
+		return nil, fmt.Errorf("failed to get oauth2 token: %w", err)
+	}
+
+	token, err := m.decryptToken(stored)
+	if err != nil {
+		return nil, err
+	}
+
+	if token.Valid() {
+		return token, nil
+	}
+
+	if token.RefreshToken == "" {
+		return nil, fmt.Errorf("%w: the token has expired", ErrOAuthAccountNotLinked)
+	}
+
+	refreshed, err := m.userConfig(auth, "").TokenSource(m.httpContext(ctx), token).Token()
+	if err != nil {
+		var retrieveErr *oauth2.RetrieveError
+		if errors.As(err, &retrieveErr) && retrieveErr.ErrorCode == "invalid_grant" {
+			// The user revoked access or the refresh token expired
+			return nil, fmt.Errorf("%w: %s", ErrOAuthAccountNotLinked, retrieveErr.ErrorCode)
+		}
+		return nil, fmt.Errorf("failed to refresh oauth2 token: %w", err)
+	}
+
+	if err := m.saveToken(ctx, &types.User{ID: userID, Type: stored.OwnerType}, linkedFor, auth, refreshed); err != nil {
+		return nil, err
+	}
+
+	return refreshed, nil
+}
+
+// Connected returns whether the user has linked their account for the app or standalone tool
+func (m *OAuthManager) Connected(ctx context.Context, userID, linkedFor string, auth *types.ToolAPIAuth) (bool, error) {
+	_, err := m.store.GetToolOAuthToken(ctx, &store.GetToolOAuthTokenQuery{
+		Owner:     userID,
+		LinkedFor: linkedFor,
+		TokenURL:  auth.TokenURL,
+		ClientID:  auth.ClientID,
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Disconnect forgets the account the user linked for the app or standalone tool
+func (m *OAuthManager) Disconnect(ctx context.Context, userID, linkedFor string, auth *types.ToolAPIAuth) error {
+	stored, err := m.store.GetToolOAuthToken(ctx, &store.GetToolOAuthTokenQuery{
+		Owner:     userID,
+		LinkedFor: linkedFor,
+		TokenURL:  auth.TokenURL,
+		ClientID:  auth.ClientID,
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	return m.store.DeleteToolOAuthToken(ctx, stored.ID)
+}
+
+// AuthCodeURL returns the provider's page where the user authorizes Helix
+func (m *OAuthManager) AuthCodeURL(auth *types.ToolAPIAuth, state, redirectURL string) string {
+	return m.userConfig(auth, redirectURL).AuthCodeURL(state, oauth2.AccessTypeOffline)
+}
+
+// Exchange completes the authorization flow and stores the user's token for the app or standalone
+// tool
+func (m *OAuthManager) Exchange(ctx context.Context, user *types.User, linkedFor string, auth *types.ToolAPIAuth, code, redirectURL string) error {
+	if m.gcm == nil {
+		return fmt.Errorf("per-user oauth2 requires TOOLS_OAUTH_ENCRYPTION_KEY to be set")
+	}
+
+	token, err := m.userConfig(auth, redirectURL).Exchange(m.httpContext(ctx), code)
+	if err != nil {
+		return fmt.Errorf("failed to exchange code for token: %w", err)
+	}
+
+	return m.saveToken(ctx, user, linkedFor, auth, token)
+}
+
+func (m *OAuthManager) saveToken(ctx context.Context, user *types.User, linkedFor string, auth *types.ToolAPIAuth, token *oauth2.Token) error {
+	accessToken, err := m.encrypt([]byte(token.AccessToken))
+	if err != nil {
+		return err
+	}
+	refreshToken, err := m.encrypt([]byte(token.RefreshToken))
+	if err != nil {
+		return err
+	}
+
+	_, err = m.store.UpsertToolOAuthToken(ctx, &types.ToolOAuthToken{
+		Owner:        user.ID,
+		OwnerType:    user.Type,
+		LinkedFor:    linkedFor,
+		TokenURL:     auth.TokenURL,
+		ClientID:     auth.ClientID,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    token.TokenType,
+		Expiry:       token.Expiry,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store oauth2 token: %w", err)
+	}
+	return nil
+}
+
+func (m *OAuthManager) decryptToken(stored *types.ToolOAuthToken) (*oauth2.Token, error) {
+	accessToken, err := m.decrypt(stored.AccessToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt oauth2 token: %w", err)
+	}
+	refreshToken, err := m.decrypt(stored.RefreshToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt oauth2 token: %w", err)
+	}
+
+	return &oauth2.Token{
+		AccessToken:  string(accessToken),
+		RefreshToken: string(refreshToken),
+		TokenType:    stored.TokenType,
+		Expiry:       stored.Expiry,
+	}, nil
+}
+
+// OAuthState is carried through the provider's authorization page so that the callback knows who
+// is linking which tool. It's encrypted, so it can't be forged.
+type OAuthState struct {
+	UserID   string          `json:"user_id"`
+	UserType types.OwnerType `json:"user_type"`
+	AppID    string          `json:"app_id,omitempty"`
+	ToolID   string          `json:"tool_id,omitempty"`
+	ToolName string          `json:"tool_name,omitempty"`
+	PageURL  string          `json:"page_url,omitempty"`
+	// Nonce is also kept in a cookie in the browser that started the flow, the callback checks
+	// that they match so that the authorization link can't be sent to someone else
+	Nonce     string    `json:"nonce"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (m *OAuthManager) EncodeState(state *OAuthState) (string, error) {
+	if m.gcm == nil {
+		return "", fmt.Errorf("per-user oauth2 requires TOOLS_OAUTH_ENCRYPTION_KEY to be set")
+	}
+
+	state.ExpiresAt = time.Now().Add(OAuthStateTTL)
+	bts, err := json.Marshal(state)
+	if err != nil {
+		return "", err
+	}
+	encrypted, err := m.encrypt(bts)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(encrypted), nil
+}
+
+func (m *OAuthManager) DecodeState(encoded string) (*OAuthState, error) {
+	if m.gcm == nil {
+		return nil, fmt.Errorf("per-user oauth2 requires TOOLS_OAUTH_ENCRYPTION_KEY to be set")
+	}
+
+	encrypted, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid state: %w", err)
+	}
+	bts, err := m.decrypt(encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("invalid state: %w", err)
+	}
+
+	var state OAuthState
+	if err := json.Unmarshal(bts, &state); err != nil {
+		return nil, fmt.Errorf("invalid state: %w", err)
+	}
+	if time.Now().After(state.ExpiresAt) {
+		return nil, fmt.Errorf("state has expired, try connecting again")
+	}
+	return &state, nil
+}
+
+// encrypt seals the plaintext with AES-GCM, prefixing the nonce
+func (m *OAuthManager) encrypt(plaintext []byte) ([]byte, error) {
+	if m.gcm == nil {
+		return nil, fmt.Errorf("no encryption key configured")
+	}
+
+	nonce := make([]byte, m.gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return m.gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (m *OAuthManager) decrypt(ciphertext []byte) ([]byte, error) {
+	if m.gcm == nil {
+		return nil, fmt.Errorf("no encryption key configured")
+	}
+
+	nonceSize := m.gcm.NonceSize()
+	if len(ciphertext) < nonceSize {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	return m.gcm.Open(nil, ciphertext[:nonceSize], ciphertext[nonceSize:], nil)
+}
@@ -3,10 +3,12 @@ package tools
 import (
 	"context"
 	"encoding/base64"
+	"fmt"
 	"net/http"
 	"os"
 	"sync"
 
+	lru "github.com/hashicorp/golang-lru/v2"
 	"github.com/rs/zerolog/log"
 	oai "github.com/sashabaranov/go-openai"
 
@@ -27,6 +29,13 @@ type Planner interface {
 	// Validation and defaulting
 	ValidateAndDefault(ctx context.Context, tool *types.Tool) (*types.Tool, error)
 
+	// Approval of actions with side effects
+	PrepareAPIAction(ctx context.Context, sessionID, interactionID string, tool *types.Tool, history []*types.ToolHistoryMessage, action string) (*types.ToolApprovalRequest, error)
+	PrepareAPIActionWithParameters(ctx context.Context, tool *types.Tool, action string, params map[string]interface{}) (*types.ToolApprovalRequest, error)
+	RunApprovedAPIAction(ctx context.Context, sessionID, interactionID string, tool *types.Tool, action string, approved *types.ToolApprovalRequest) (*RunActionResponse, error)
+	PrepareMCPAction(ctx context.Context, sessionID, interactionID string, tool *types.Tool, history []*types.ToolHistoryMessage, action string) (*types.ToolApprovalRequest, error)
+	RunApprovedMCPAction(ctx context.Context, sessionID, interactionID string, tool *types.Tool, action string, approved *types.ToolApprovalRequest) (*RunActionResponse, error)
+
 	// Low level methods for Model Context Protocol (MCP)
 	RunAPIActionWithParameters(ctx context.Context, req *types.RunAPIActionRequest) (*types.RunAPIActionResponse, error)
 	// TODO: GPTScript, Zapier.
@@ -40,6 +49,9 @@ type ChainStrategy struct {
 	store                store.Store
 	apiClient            openai.Client
 	httpClient           *http.Client
+	oauth                *OAuthManager
+	responseCache        *lru.Cache[string, *cachedResponse]
+	mcp                  *MCPClients
 	gptScriptExecutor    gptscript.Executor
 	isActionableTemplate string
 	wg                   sync.WaitGroup
@@ -54,12 +66,22 @@ func NewChainStrategy(cfg *config.ServerConfig, store store.Store, gptScriptExec
 	}
 
 	retryClient := system.NewRetryClient(3)
+	httpClient := retryClient.StandardClient()
+
+	oauth, err := NewOAuthManager(store, httpClient, cfg.Tools.OAuthEncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create oauth manager: %w", err)
+	}
+
 	return &ChainStrategy{
 		cfg:                  cfg,
 		apiClient:            client,
 		store:                store,
 		gptScriptExecutor:    gptScriptExecutor,
-		httpClient:           retryClient.StandardClient(),
+		httpClient:           httpClient,
+		oauth:                oauth,
+		responseCache:        newResponseCache(),
+		mcp:                  NewMCPClients(cfg.Tools.MCPAllowStdio),
 		isActionableTemplate: isActionableTemplate,
 	}, nil
 }
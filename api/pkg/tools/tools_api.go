@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"html/template"
 	"net/http"
+	"slices"
+	"sort"
 	"strings"
 
 	"github.com/getkin/kin-openapi/openapi3"
@@ -16,65 +18,92 @@ import (
 	openai "github.com/sashabaranov/go-openai"
 )
 
-func (c *ChainStrategy) prepareRequest(ctx context.Context, tool *types.Tool, action string, params map[string]string) (*http.Request, error) {
+func (c *ChainStrategy) prepareRequest(ctx context.Context, tool *types.Tool, action string, params map[string]interface{}) (*http.Request, error) {
+	req, schema, operation, err := buildRequest(ctx, tool, action, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.authorizeRequest(ctx, schema, operation, tool, req); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// buildRequest creates the request for the action without credentials, so it's safe to show
+func buildRequest(ctx context.Context, tool *types.Tool, action string, params map[string]interface{}) (*http.Request, *openapi3.T, *openapi3.Operation, error) {
 	loader := openapi3.NewLoader()
 
 	schema, err := loader.LoadFromData([]byte(tool.Config.API.Schema))
 	if err != nil {
-		return nil, fmt.Errorf("failed to load openapi spec: %w", err)
+		return nil, nil, nil, fmt.Errorf("failed to load openapi spec: %w", err)
 	}
 
 	// Based on the operationId get the path and method
-	var path, method string
+	path, method, operation := findOperation(schema, action)
+	if operation == nil {
+		return nil, nil, nil, fmt.Errorf("failed to find path and method for action %s", action)
+	}
 
 	queryParams := make(map[string]bool)
 	pathParams := make(map[string]bool)
+	headerParams := make(map[string]bool)
+
+	for _, param := range operation.Parameters {
+		switch param.Value.In {
+		case openapi3.ParameterInQuery:
+			queryParams[param.Value.Name] = true
+		case openapi3.ParameterInPath:
+			pathParams[param.Value.Name] = true
+		case openapi3.ParameterInHeader:
+			headerParams[param.Value.Name] = true
+		}
+	}
 
-	for p, pathItem := range schema.Paths.Map() {
-		for m, operation := range pathItem.Operations() {
-			if operation.OperationID == action {
-				path = p
-				method = m
-
-				for _, param := range operation.Parameters {
-
-					switch param.Value.In {
-					case "query":
-						queryParams[param.Value.Name] = true
-					case "path":
-						pathParams[param.Value.Name] = true
-					}
-				}
-
-				break
-			}
+	// Everything that isn't a path, query or header parameter belongs to the body
+	used := make(map[string]bool)
+	for k := range params {
+		if pathParams[k] || queryParams[k] || headerParams[k] {
+			used[k] = true
 		}
 	}
 
-	if path == "" || method == "" {
-		return nil, fmt.Errorf("failed to find path and method for action %s", action)
+	body, contentType, err := buildRequestBody(operation, params, used)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to prepare request body: %w", err)
 	}
 
 	// Prepare request
-	req, err := http.NewRequestWithContext(ctx, method, tool.Config.API.URL+path, nil)
+	req, err := http.NewRequestWithContext(ctx, method, tool.Config.API.URL+path, body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, nil, nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	for k, v := range tool.Config.API.Headers {
 		req.Header.Set(k, v)
 	}
 
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
 	q := req.URL.Query()
 
 	// Add path params
 	for k, v := range params {
 		if pathParams[k] {
-			req.URL.Path = strings.Replace(req.URL.Path, "{"+k+"}", v, -1)
+			req.URL.Path = strings.Replace(req.URL.Path, "{"+k+"}", paramString(v), -1)
 		}
 
 		if queryParams[k] {
-			q.Add(k, v)
+			for _, value := range paramStrings(v) {
+				q.Add(k, value)
+			}
+		}
+
+		if headerParams[k] {
+			req.Header.Set(k, paramString(v))
 		}
 	}
 
@@ -93,12 +122,22 @@ func (c *ChainStrategy) prepareRequest(ctx context.Context, tool *types.Tool, ac
 	req.Header.Set("X-Helix-Tool-Id", tool.ID)
 	req.Header.Set("X-Helix-Action-Id", action)
 
-	// TODO: Add body
+	return req, schema, operation, nil
+}
 
-	return req, nil
+// findOperation returns the path, method and operation for an operation ID
+func findOperation(schema *openapi3.T, operationID string) (string, string, *openapi3.Operation) {
+	for path, pathItem := range schema.Paths.Map() {
+		for method, operation := range pathItem.Operations() {
+			if operation.OperationID == operationID {
+				return path, method, operation
+			}
+		}
+	}
+	return "", "", nil
 }
 
-func (c *ChainStrategy) getAPIRequestParameters(ctx context.Context, sessionID, interactionID string, tool *types.Tool, history []*types.ToolHistoryMessage, action string) (map[string]string, error) {
+func (c *ChainStrategy) getAPIRequestParameters(ctx context.Context, sessionID, interactionID string, tool *types.Tool, history []*types.ToolHistoryMessage, action string) (map[string]interface{}, error) {
 	systemPrompt, err := c.getAPISystemPrompt(tool, action)
 	if err != nil {
 		return nil, fmt.Errorf("failed to prepare system prompt: %w", err)
@@ -165,7 +204,6 @@ func (c *ChainStrategy) getAPIRequestParameters(ctx context.Context, sessionID,
 
 	answer := resp.Choices[0].Message.Content
 
-	// var params map[string]string
 	params, err := unmarshalParams(answer)
 	if err != nil {
 		return nil, err
@@ -174,21 +212,19 @@ func (c *ChainStrategy) getAPIRequestParameters(ctx context.Context, sessionID,
 	return params, nil
 }
 
-func unmarshalParams(data string) (map[string]string, error) {
-	var initial map[string]interface{}
-	err := unmarshalJSON(data, &initial)
+// unmarshalParams parses the parameters from the LLM's answer, keeping their JSON types so that
+// request bodies can have numbers, booleans, arrays and nested objects
+func unmarshalParams(data string) (map[string]interface{}, error) {
+	var params map[string]interface{}
+	err := unmarshalJSON(data, &params)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal response from inference API: %w (%s)", err, data)
 	}
 
-	params := make(map[string]string)
-
-	for k, v := range initial {
-		// Convert any type of value to string
+	for k, v := range params {
+		// Null means the parameter isn't set
 		if v == nil {
-			params[k] = "" // Set empty string if value is nil
-		} else {
-			params[k] = fmt.Sprintf("%v", v)
+			delete(params, k)
 		}
 	}
 
@@ -231,7 +267,7 @@ func (c *ChainStrategy) getAPISystemPrompt(tool *types.Tool, action string) (ope
 	}, nil
 }
 
-const apiSystemPrompt = `You are an intelligent machine learning model that can produce REST API's params / query params in json format, given the json schema, user input, data from previous api calls, and current application state.`
+const apiSystemPrompt = `You are an intelligent machine learning model that can produce REST API's params / query params / request bodies in json format, given the json schema, user input, data from previous api calls, and current application state.`
 
 const apiUserPrompt = `
 Your output must be a valid json, without any commentary or additional formatting.
@@ -293,6 +329,27 @@ Examples:
 }
 ` + "```" + `
 
+**User Input:** Open a high priority ticket "Printer on floor 3 is jammed" tagged hardware and office
+**OpenAPI schema path:** /tickets
+**OpenAPI schema request body:** {
+	"type": "object",
+	"required": ["title"],
+	"properties": {
+		"title": { "type": "string" },
+		"priority": { "type": "integer", "description": "1 is the highest" },
+		"tags": { "type": "array", "items": { "type": "string" } }
+	}
+}
+**Verdict:** request body fields go at the top level, keeping their JSON types and nesting:
+
+` + "```" + `json
+{
+  "title": "Printer on floor 3 is jammed",
+  "priority": 1,
+  "tags": ["hardware", "office"]
+}
+` + "```" + `
+
 **Response Format:** Always respond with JSON without any commentary, wrapped in markdown json tags, for example:
 ` + "```" + `json
 {
@@ -309,7 +366,7 @@ OpenAPI schema:
 
 ===END OPENAPI SCHEMA===
 
-Based on conversation below, construct a valid JSON object. In cases where user input does not contain information for a query, DO NOT add that specific query parameter to the output. If a user doesn't provide a required parameter, use sensible defaults for required params, and leave optional params out. Do not pass parameters as null, instead just don't include them.
+Based on conversation below, construct a valid JSON object. In cases where user input does not contain information for a query, DO NOT add that specific query parameter to the output. If a user doesn't provide a required parameter, use sensible defaults for required params, and leave optional params out. Do not pass parameters as null, instead just don't include them. If the request body is not an object, for example an array, put the whole body under a "body" key.
 ONLY use search parameters from the user messages below - do NOT use search parameters provided in the examples.
 `
 
@@ -331,7 +388,7 @@ func filterOpenAPISchema(tool *types.Tool, operationID string) (string, error) {
 	filtered.Paths = &openapi3.Paths{}
 	filtered.Components = &openapi3.Components{}
 
-	var usedRefs []string
+	usedRefs := make(map[string]bool)
 
 	for path, pathItem := range schema.Paths.Map() {
 		for method, operation := range pathItem.Operations() {
@@ -352,7 +409,22 @@ func filterOpenAPISchema(tool *types.Tool, operationID string) (string, error) {
 					if jsonBody.Schema.Ref != "" {
 						parts := strings.Split(jsonBody.Schema.Ref, "/")
 						if len(parts) > 0 {
-							usedRefs = append(usedRefs, parts[len(parts)-1])
+							usedRefs[parts[len(parts)-1]] = true
+						}
+					}
+				}
+
+				// The LLM needs the full request body schema to construct the body
+				if operation.RequestBody != nil {
+					if operation.RequestBody.Ref != "" {
+						name := refName(operation.RequestBody.Ref)
+						filtered.Components.RequestBodies = openapi3.RequestBodies{
+							name: schema.Components.RequestBodies[name],
+						}
+					}
+					if operation.RequestBody.Value != nil {
+						for _, mt := range operation.RequestBody.Value.Content {
+							collectSchemaRefs(mt.Schema, usedRefs)
 						}
 					}
 				}
@@ -363,7 +435,7 @@ func filterOpenAPISchema(tool *types.Tool, operationID string) (string, error) {
 	if len(usedRefs) > 0 {
 		filtered.Components.Schemas = make(map[string]*openapi3.SchemaRef)
 
-		for _, ref := range usedRefs {
+		for ref := range usedRefs {
 			filtered.Components.Schemas[ref] = schema.Components.Schemas[ref]
 		}
 	}
@@ -376,6 +448,40 @@ func filterOpenAPISchema(tool *types.Tool, operationID string) (string, error) {
 	return string(jsonSpec), nil
 }
 
+// collectSchemaRefs records the names of all component schemas a schema refers to, however deeply
+func collectSchemaRefs(schema *openapi3.SchemaRef, refs map[string]bool) {
+	if schema == nil {
+		return
+	}
+	if schema.Ref != "" {
+		name := refName(schema.Ref)
+		if refs[name] {
+			// Already visited, schemas can be recursive
+			return
+		}
+		refs[name] = true
+	}
+	if schema.Value == nil {
+		return
+	}
+
+	for _, prop := range schema.Value.Properties {
+		collectSchemaRefs(prop, refs)
+	}
+	collectSchemaRefs(schema.Value.Items, refs)
+	collectSchemaRefs(schema.Value.AdditionalProperties.Schema, refs)
+	for _, group := range []openapi3.SchemaRefs{schema.Value.AllOf, schema.Value.AnyOf, schema.Value.OneOf} {
+		for _, s := range group {
+			collectSchemaRefs(s, refs)
+		}
+	}
+}
+
+func refName(ref string) string {
+	parts := strings.Split(ref, "/")
+	return parts[len(parts)-1]
+}
+
 func GetActionsFromSchema(spec string) ([]*types.ToolAPIAction, error) {
 	loader := openapi3.NewLoader()
 
@@ -415,6 +521,8 @@ type Parameter struct {
 	Required    bool
 	Type        ParameterType
 	Description string
+	In          string           // path, query, header or body
+	Schema      *openapi3.Schema // Full schema, for nested objects and arrays
 }
 
 type ParameterType string
@@ -422,11 +530,15 @@ type ParameterType string
 const (
 	ParameterTypeString  ParameterType = "string"
 	ParameterTypeInteger ParameterType = "integer"
+	ParameterTypeNumber  ParameterType = "number"
 	ParameterTypeBoolean ParameterType = "boolean"
 	ParameterTypeArray   ParameterType = "array"
 	ParameterTypeObject  ParameterType = "object"
 )
 
+// ParameterInBody marks parameters that are sent in the request body
+const ParameterInBody = "body"
+
 func GetParametersFromSchema(spec string, action string) ([]*Parameter, error) {
 	loader := openapi3.NewLoader()
 
@@ -437,33 +549,83 @@ func GetParametersFromSchema(spec string, action string) ([]*Parameter, error) {
 
 	var parameters []*Parameter
 
-	for _, pathItem := range schema.Paths.Map() {
-		for _, operation := range pathItem.Operations() {
-			if operation.OperationID == action {
-				for _, param := range operation.Parameters {
-					parameters = append(parameters, &Parameter{
-						Name:        param.Value.Name,
-						Required:    param.Value.Required,
-						Type:        getParameterType(param.Value.Schema),
-						Description: param.Value.Description,
-					})
-				}
-
-				// if operation.RequestBody != nil {
-				// 	// TODO: Add body parameter. Need to lookup the schema in components
-				// 	// and then add it to the parameters list all items.
-				// }
-			}
+	_, _, operation := findOperation(schema, action)
+	if operation == nil {
+		return parameters, nil
+	}
+
+	for _, param := range operation.Parameters {
+		parameters = append(parameters, &Parameter{
+			Name:        param.Value.Name,
+			Required:    param.Value.Required,
+			Type:        getParameterType(param.Value.Schema),
+			Description: param.Value.Description,
+			In:          param.Value.In,
+			Schema:      schemaValue(param.Value.Schema),
+		})
+	}
+
+	mediaType, mt := requestBodyMediaType(operation)
+	if mediaType == "" || mt.Schema == nil || mt.Schema.Value == nil {
+		return parameters, nil
+	}
+	bodySchema := mt.Schema.Value
+	bodyRequired := operation.RequestBody.Value.Required
+
+	// Object bodies have a parameter per property, see buildRequestBody
+	if !isObjectSchema(bodySchema) {
+		return append(parameters, &Parameter{
+			Name:        requestBodyParamName,
+			Required:    bodyRequired,
+			Type:        getParameterType(mt.Schema),
+			Description: operation.RequestBody.Value.Description,
+			In:          ParameterInBody,
+			Schema:      bodySchema,
+		}), nil
+	}
+
+	names := make([]string, 0, len(bodySchema.Properties))
+	for name := range bodySchema.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		prop := bodySchema.Properties[name]
+		if prop.Value == nil || prop.Value.ReadOnly {
+			continue
 		}
+		parameters = append(parameters, &Parameter{
+			Name:        name,
+			Required:    bodyRequired && slices.Contains(bodySchema.Required, name),
+			Type:        getParameterType(prop),
+			Description: prop.Value.Description,
+			In:          ParameterInBody,
+			Schema:      prop.Value,
+		})
 	}
 
 	return parameters, nil
 }
 
 func getParameterType(schema *openapi3.SchemaRef) ParameterType {
+	if schema == nil || schema.Value == nil {
+		return ParameterTypeString
+	}
+
 	if len(schema.Value.Type.Slice()) > 0 {
 		return ParameterType(schema.Value.Type.Slice()[0])
 	}
 
+	if isObjectSchema(schema.Value) {
+		return ParameterTypeObject
+	}
+
 	return ParameterTypeString
 }
+
+func schemaValue(schema *openapi3.SchemaRef) *openapi3.Schema {
+	if schema == nil {
+		return nil
+	}
+	return schema.Value
+}
@@ -0,0 +1,160 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+var (
+	// ErrActionDenied is returned for actions whose policy doesn't allow them to run
+	ErrActionDenied = errors.New("action is not allowed")
+	// ErrActionNeedsApproval is returned for actions that need the user's approval when there's no
+	// user to ask
+	ErrActionNeedsApproval = errors.New("action needs approval")
+)
+
+// ActionPolicy returns whether an action runs straight away, needs the user's approval or isn't
+// allowed. API and MCP actions can change things: of API actions only GET requests are assumed
+// to be safe, and of MCP actions only reading resources, unless the tool says otherwise.
+func ActionPolicy(tool *types.Tool, action string) types.ToolActionPolicy {
+	switch {
+	case tool.ToolType == types.ToolTypeAPI && tool.Config.API != nil:
+		return apiActionPolicy(tool.Config.API, action)
+	case tool.ToolType == types.ToolTypeMCP && tool.Config.MCP != nil:
+		return mcpActionPolicy(tool.Config.MCP, action)
+	}
+	return types.ToolActionPolicyAuto
+}
+
+func apiActionPolicy(api *types.ToolAPIConfig, action string) types.ToolActionPolicy {
+	if policy, ok := api.ActionPolicies[action]; ok && policy != "" {
+		return policy
+	}
+
+	for _, a := range api.Actions {
+		if a.Name != action {
+			continue
+		}
+		switch strings.ToUpper(a.Method) {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			return types.ToolActionPolicyAuto
+		}
+	}
+
+	return types.ToolActionPolicyConfirm
+}
+
+func mcpActionPolicy(mcp *types.ToolMCPConfig, action string) types.ToolActionPolicy {
+	if policy, ok := mcp.ActionPolicies[action]; ok && policy != "" {
+		return policy
+	}
+
+	// Tools win over resources of the same name, as they do when the action runs
+	for _, t := range mcp.Tools {
+		if t.Name == action {
+			return types.ToolActionPolicyConfirm
+		}
+	}
+	for _, r := range mcp.Resources {
+		if r.Name == action {
+			return types.ToolActionPolicyAuto
+		}
+	}
+
+	return types.ToolActionPolicyConfirm
+}
+
+// PrepareAPIAction works out the request an API action would make without making it, so that the
+// user can approve it first
+func (c *ChainStrategy) PrepareAPIAction(ctx context.Context, sessionID, interactionID string, tool *types.Tool, history []*types.ToolHistoryMessage, action string) (*types.ToolApprovalRequest, error) {
+	if tool.Config.API == nil {
+		return nil, fmt.Errorf("tool %s is not an API tool", tool.Name)
+	}
+
+	params, err := c.getAPIRequestParameters(ctx, sessionID, interactionID, tool, history, action)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api request parameters: %w", err)
+	}
+
+	approval, err := prepareRequest(ctx, tool, action, params)
+	if err != nil {
+		return nil, err
+	}
+	approval.Model = tool.Config.API.Model
+	approval.History = history
+
+	return approval, nil
+}
+
+// PrepareAPIActionWithParameters works out the request an API action would make with the given
+// parameters. Once approved, the API's response is returned as is, as RunAPIActionWithParameters
+// would.
+func (c *ChainStrategy) PrepareAPIActionWithParameters(ctx context.Context, tool *types.Tool, action string, params map[string]interface{}) (*types.ToolApprovalRequest, error) {
+	if tool.Config.API == nil {
+		return nil, fmt.Errorf("tool %s is not an API tool", tool.Name)
+	}
+
+	if params == nil {
+		params = make(map[string]interface{})
+	}
+
+	approval, err := prepareRequest(ctx, tool, action, params)
+	if err != nil {
+		return nil, err
+	}
+	approval.Raw = true
+
+	return approval, nil
+}
+
+// prepareRequest is the approval request for the request that the action makes with the parameters
+func prepareRequest(ctx context.Context, tool *types.Tool, action string, params map[string]interface{}) (*types.ToolApprovalRequest, error) {
+	req, _, _, err := buildRequest(ctx, tool, action, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare request: %w", err)
+	}
+
+	return &types.ToolApprovalRequest{
+		Method:     req.Method,
+		URL:        req.URL.String(),
+		Parameters: params,
+	}, nil
+}
+
+// RunApprovedAPIAction makes the request the user approved and interprets the response. The
+// request has to be the one that was approved, in case the tool has changed since.
+func (c *ChainStrategy) RunApprovedAPIAction(ctx context.Context, sessionID, interactionID string, tool *types.Tool, action string, approved *types.ToolApprovalRequest) (*RunActionResponse, error) {
+	if tool.Config.API == nil {
+		return nil, fmt.Errorf("tool %s is not an API tool", tool.Name)
+	}
+
+	req, schema, operation, err := buildRequest(ctx, tool, action, approved.Parameters)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare request: %w", err)
+	}
+
+	if req.Method != approved.Method || req.URL.String() != approved.URL {
+		return nil, fmt.Errorf("the request no longer matches the approved %s %s", approved.Method, approved.URL)
+	}
+
+	if err := c.authorizeRequest(ctx, schema, operation, tool, req); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.fetch(ctx, tool, action, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if approved.Raw {
+		body := string(rawResponse(tool, action, resp))
+		return &RunActionResponse{Message: body, RawMessage: body}, nil
+	}
+
+	return c.interpretResponse(ctx, sessionID, interactionID, tool, action, approved.History, resp)
+}
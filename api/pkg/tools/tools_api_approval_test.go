@@ -0,0 +1,240 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/helixml/helix/api/pkg/config"
+	oai "github.com/helixml/helix/api/pkg/openai"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+func Test_ActionPolicy(t *testing.T) {
+	apiTool := func(policies map[string]types.ToolActionPolicy) *types.Tool {
+		return &types.Tool{
+			Name:     "contacts",
+			ToolType: types.ToolTypeAPI,
+			Config: types.ToolConfig{
+				API: &types.ToolAPIConfig{
+					Actions: []*types.ToolAPIAction{
+						{Name: "listContacts", Method: "GET", Path: "/contacts"},
+						{Name: "createContact", Method: "POST", Path: "/contacts"},
+					},
+					ActionPolicies: policies,
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		tool   *types.Tool
+		action string
+		want   types.ToolActionPolicy
+	}{
+		{
+			name:   "GET runs straight away",
+			tool:   apiTool(nil),
+			action: "listContacts",
+			want:   types.ToolActionPolicyAuto,
+		},
+		{
+			name:   "POST needs approval",
+			tool:   apiTool(nil),
+			action: "createContact",
+			want:   types.ToolActionPolicyConfirm,
+		},
+		{
+			name:   "unknown action needs approval",
+			tool:   apiTool(nil),
+			action: "deleteContact",
+			want:   types.ToolActionPolicyConfirm,
+		},
+		{
+			name:   "policy overrides POST",
+			tool:   apiTool(map[string]types.ToolActionPolicy{"createContact": types.ToolActionPolicyAuto}),
+			action: "createContact",
+			want:   types.ToolActionPolicyAuto,
+		},
+		{
+			name:   "policy overrides GET",
+			tool:   apiTool(map[string]types.ToolActionPolicy{"listContacts": types.ToolActionPolicyDeny}),
+			action: "listContacts",
+			want:   types.ToolActionPolicyDeny,
+		},
+		{
+			name:   "non-API tools run straight away",
+			tool:   &types.Tool{Name: "script", ToolType: types.ToolTypeGPTScript},
+			action: "script",
+			want:   types.ToolActionPolicyAuto,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ActionPolicy(tt.tool, tt.action))
+		})
+	}
+}
+
+func Test_RunApprovedAPIAction(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/contacts", r.URL.Path)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id": "c_1"}`))
+	}))
+	defer ts.Close()
+
+	ctrl := gomock.NewController(t)
+	apiClient := oai.NewMockClient(ctrl)
+
+	strategy := &ChainStrategy{
+		cfg:        &config.ServerConfig{},
+		apiClient:  apiClient,
+		httpClient: ts.Client(),
+	}
+
+	tool := contactsTool(t, nil)
+	tool.Config.API.URL = ts.URL
+
+	t.Run("runs the approved request", func(t *testing.T) {
+		apiClient.EXPECT().CreateChatCompletion(gomock.Any(), gomock.Any()).Return(openai.ChatCompletionResponse{
+			Choices: []openai.ChatCompletionChoice{
+				{Message: openai.ChatCompletionMessage{Content: "Contact created"}},
+			},
+		}, nil)
+
+		resp, err := strategy.RunApprovedAPIAction(context.Background(), "ses_1", "int_1", tool, "createContact", &types.ToolApprovalRequest{
+			Method: http.MethodPost,
+			URL:    ts.URL + "/contacts",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "Contact created", resp.Message)
+		assert.Equal(t, int32(1), requests.Load())
+	})
+
+	t.Run("refuses a request that doesn't match the approval", func(t *testing.T) {
+		_, err := strategy.RunApprovedAPIAction(context.Background(), "ses_1", "int_1", tool, "createContact", &types.ToolApprovalRequest{
+			Method: http.MethodPost,
+			URL:    "https://elsewhere.example.com/contacts",
+		})
+		require.ErrorContains(t, err, "no longer matches")
+		assert.Equal(t, int32(1), requests.Load())
+	})
+}
+
+func Test_PrepareAndRunApprovedAPIAction(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id": "c_1"}`))
+	}))
+	defer ts.Close()
+
+	ctrl := gomock.NewController(t)
+	apiClient := oai.NewMockClient(ctrl)
+
+	strategy := &ChainStrategy{
+		cfg:        &config.ServerConfig{},
+		apiClient:  apiClient,
+		httpClient: ts.Client(),
+	}
+
+	tool := contactsTool(t, nil)
+	tool.Config.API.URL = ts.URL
+	tool.Config.API.Model = "tools-model"
+
+	history := []*types.ToolHistoryMessage{
+		{Role: openai.ChatMessageRoleUser, Content: "Add Jane to my contacts"},
+	}
+
+	// The model works out the parameters, and interprets the response once the request is approved
+	gomock.InOrder(
+		apiClient.EXPECT().CreateChatCompletion(gomock.Any(), gomock.Any()).Return(openai.ChatCompletionResponse{
+			Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: `{"name": "Jane"}`}}},
+		}, nil),
+		apiClient.EXPECT().CreateChatCompletion(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+				assert.Equal(t, "tools-model", req.Model)
+				assert.Contains(t, req.Messages[1].Content, "Add Jane to my contacts")
+				return openai.ChatCompletionResponse{
+					Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: "Jane has been added"}}},
+				}, nil
+			}),
+	)
+
+	approval, err := strategy.PrepareAPIAction(context.Background(), "ses_1", "int_1", tool, history, "createContact")
+	require.NoError(t, err)
+	assert.Equal(t, http.MethodPost, approval.Method)
+	assert.Equal(t, "tools-model", approval.Model)
+	assert.False(t, approval.Raw)
+
+	resp, err := strategy.RunApprovedAPIAction(context.Background(), "ses_1", "int_1", tool, "createContact", approval)
+	require.NoError(t, err)
+	assert.Equal(t, "Jane has been added", resp.Message)
+}
+
+func Test_RunAPIActionWithParameters_Policy(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id": "c_1"}`))
+	}))
+	defer ts.Close()
+
+	// No model interprets these responses, so none is expected
+	strategy := &ChainStrategy{
+		cfg:        &config.ServerConfig{},
+		apiClient:  oai.NewMockClient(gomock.NewController(t)),
+		httpClient: ts.Client(),
+	}
+
+	tool := contactsTool(t, nil)
+	tool.Config.API.URL = ts.URL
+
+	t.Run("refuses actions that need approval", func(t *testing.T) {
+		_, err := strategy.RunAPIActionWithParameters(context.Background(), &types.RunAPIActionRequest{
+			Action: "createContact",
+			Tool:   tool,
+		})
+		require.ErrorIs(t, err, ErrActionNeedsApproval)
+		assert.Equal(t, int32(0), requests.Load())
+	})
+
+	t.Run("runs the approved request and returns the response as is", func(t *testing.T) {
+		approval, err := strategy.PrepareAPIActionWithParameters(context.Background(), tool, "createContact", nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.MethodPost, approval.Method)
+		assert.True(t, approval.Raw)
+
+		resp, err := strategy.RunApprovedAPIAction(context.Background(), "", "", tool, "createContact", approval)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"id": "c_1"}`, resp.Message)
+		assert.Equal(t, int32(1), requests.Load())
+	})
+
+	t.Run("runs actions set to auto", func(t *testing.T) {
+		autoTool := contactsTool(t, nil)
+		autoTool.Config.API.URL = ts.URL
+		autoTool.Config.API.ActionPolicies = map[string]types.ToolActionPolicy{"createContact": types.ToolActionPolicyAuto}
+
+		resp, err := strategy.RunAPIActionWithParameters(context.Background(), &types.RunAPIActionRequest{
+			Action: "createContact",
+			Tool:   autoTool,
+		})
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"id": "c_1"}`, resp.Response)
+		assert.Equal(t, int32(2), requests.Load())
+	})
+}
@@ -0,0 +1,268 @@
+package tools
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+
+	oai "github.com/helixml/helix/api/pkg/openai"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+// apiAuth is a tool's auth config with the gaps filled in from the security scheme in its spec
+type apiAuth struct {
+	types.ToolAPIAuth
+
+	// httpScheme is set when the API key is sent as HTTP auth, e.g. "bearer" or "basic"
+	httpScheme string
+}
+
+// ResolveAuth fills in the tool's auth config from the security schemes in its OpenAPI spec, e.g.
+// the token and authorization URLs for OAuth2. Returns nil if the tool has no auth configured.
+func ResolveAuth(tool *types.Tool) (*types.ToolAPIAuth, error) {
+	if tool.Config.API == nil || tool.Config.API.Auth == nil {
+		return nil, nil
+	}
+
+	spec, err := openapi3.NewLoader().LoadFromData([]byte(tool.Config.API.Schema))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load openapi spec: %w", err)
+	}
+
+	auth, err := resolveAuth(spec, nil, tool.Config.API.Auth)
+	if err != nil {
+		return nil, err
+	}
+	return &auth.ToolAPIAuth, nil
+}
+
+func resolveAuth(spec *openapi3.T, operation *openapi3.Operation, config *types.ToolAPIAuth) (*apiAuth, error) {
+	auth := &apiAuth{ToolAPIAuth: *config}
+
+	name, scheme, err := findSecurityScheme(spec, operation, config)
+	if err != nil {
+		return nil, err
+	}
+
+	if scheme != nil {
+		switch scheme.Type {
+		case "apiKey":
+			if auth.In == "" {
+				auth.In = scheme.In
+			}
+			if auth.Name == "" {
+				auth.Name = scheme.Name
+			}
+		case "http":
+			auth.In = openapi3.ParameterInHeader
+			auth.Name = "Authorization"
+			auth.httpScheme = strings.ToLower(scheme.Scheme)
+		case "oauth2":
+			var flow *openapi3.OAuthFlow
+			if scheme.Flows != nil {
+				if auth.Type == types.ToolAPIAuthTypeOAuth2ClientCredentials {
+					flow = scheme.Flows.ClientCredentials
+				} else {
+					flow = scheme.Flows.AuthorizationCode
+				}
+			}
+			if flow != nil {
+				if auth.TokenURL == "" {
+					auth.TokenURL = flow.TokenURL
+				}
+				if auth.AuthURL == "" {
+					auth.AuthURL = flow.AuthorizationURL
+				}
+			}
+			if len(auth.Scopes) == 0 {
+				auth.Scopes = requiredScopes(spec, name)
+			}
+		}
+	}
+
+	switch auth.Type {
+	case types.ToolAPIAuthTypeAPIKey:
+		if auth.APIKey == "" {
+			return nil, fmt.Errorf("api_key is required")
+		}
+		if auth.In != openapi3.ParameterInHeader && auth.In != openapi3.ParameterInQuery {
+			return nil, fmt.Errorf("api key must be sent in a header or query parameter, got %q", auth.In)
+		}
+		if auth.Name == "" {
+			return nil, fmt.Errorf("api key header or query parameter name is required")
+		}
+	case types.ToolAPIAuthTypeOAuth2ClientCredentials, types.ToolAPIAuthTypeOAuth2User:
+		if auth.ClientID == "" {
+			return nil, fmt.Errorf("client_id is required")
+		}
+		if auth.TokenURL == "" {
+			return nil, fmt.Errorf("token_url is required")
+		}
+		if auth.Type == types.ToolAPIAuthTypeOAuth2User && auth.AuthURL == "" {
+			return nil, fmt.Errorf("auth_url is required")
+		}
+	default:
+		return nil, fmt.Errorf("unknown auth type %q", auth.Type)
+	}
+
+	return auth, nil
+}
+
+// findSecurityScheme returns the security scheme the auth config is for. Unless the config names
+// one, this is the first the operation accepts that matches the auth type, then the first in the
+// spec. Specs without security schemes rely on the config alone.
+func findSecurityScheme(spec *openapi3.T, operation *openapi3.Operation, auth *types.ToolAPIAuth) (string, *openapi3.SecurityScheme, error) {
+	var schemes openapi3.SecuritySchemes
+	if spec.Components != nil {
+		schemes = spec.Components.SecuritySchemes
+	}
+
+	if auth.Scheme != "" {
+		ref, ok := schemes[auth.Scheme]
+		if !ok || ref.Value == nil {
+			return "", nil, fmt.Errorf("security scheme %s not found in the spec", auth.Scheme)
+		}
+		return auth.Scheme, ref.Value, nil
+	}
+
+	requirements := spec.Security
+	if operation != nil && operation.Security != nil {
+		requirements = *operation.Security
+	}
+
+	var candidates []string
+	for _, requirement := range requirements {
+		names := make([]string, 0, len(requirement))
+		for name := range requirement {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		candidates = append(candidates, names...)
+	}
+	names := make([]string, 0, len(schemes))
+	for name := range schemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	candidates = append(candidates, names...)
+
+	for _, name := range candidates {
+		ref, ok := schemes[name]
+		if !ok || ref.Value == nil {
+			continue
+		}
+		if schemeMatches(ref.Value, auth.Type) {
+			return name, ref.Value, nil
+		}
+	}
+
+	return "", nil, nil
+}
+
+func schemeMatches(scheme *openapi3.SecurityScheme, authType types.ToolAPIAuthType) bool {
+	switch authType {
+	case types.ToolAPIAuthTypeAPIKey:
+		return scheme.Type == "apiKey" || scheme.Type == "http"
+	case types.ToolAPIAuthTypeOAuth2ClientCredentials:
+		return scheme.Type == "oauth2" && scheme.Flows != nil && scheme.Flows.ClientCredentials != nil
+	case types.ToolAPIAuthTypeOAuth2User:
+		return scheme.Type == "oauth2" && scheme.Flows != nil && scheme.Flows.AuthorizationCode != nil
+	}
+	return false
+}
+
+// requiredScopes returns every scope the spec asks for with the scheme. The same token is used for
+// all of a tool's operations, so it needs all of them.
+func requiredScopes(spec *openapi3.T, schemeName string) []string {
+	seen := make(map[string]bool)
+	add := func(requirements openapi3.SecurityRequirements) {
+		for _, requirement := range requirements {
+			for _, scope := range requirement[schemeName] {
+				seen[scope] = true
+			}
+		}
+	}
+
+	add(spec.Security)
+	if spec.Paths != nil {
+		for _, pathItem := range spec.Paths.Map() {
+			for _, operation := range pathItem.Operations() {
+				if operation.Security != nil {
+					add(*operation.Security)
+				}
+			}
+		}
+	}
+
+	scopes := make([]string, 0, len(seen))
+	for scope := range seen {
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+	return scopes
+}
+
+// authorizeRequest adds the tool's credentials to the request. Per-user OAuth2 acts on behalf of the
+// owner in the request context.
+func (c *ChainStrategy) authorizeRequest(ctx context.Context, spec *openapi3.T, operation *openapi3.Operation, tool *types.Tool, req *http.Request) error {
+	if tool.Config.API.Auth == nil {
+		return nil
+	}
+
+	auth, err := resolveAuth(spec, operation, tool.Config.API.Auth)
+	if err != nil {
+		return fmt.Errorf("invalid auth config for tool %s: %w", tool.Name, err)
+	}
+
+	switch auth.Type {
+	case types.ToolAPIAuthTypeAPIKey:
+		value := auth.APIKey
+		switch auth.httpScheme {
+		case "bearer":
+			value = "Bearer " + value
+		case "basic":
+			value = "Basic " + base64.StdEncoding.EncodeToString([]byte(value))
+		}
+
+		if auth.In == openapi3.ParameterInQuery {
+			q := req.URL.Query()
+			q.Set(auth.Name, value)
+			req.URL.RawQuery = q.Encode()
+		} else {
+			req.Header.Set(auth.Name, value)
+		}
+	case types.ToolAPIAuthTypeOAuth2ClientCredentials:
+		if c.oauth == nil {
+			return fmt.Errorf("oauth2 is not configured")
+		}
+		token, err := c.oauth.ClientCredentialsToken(ctx, &auth.ToolAPIAuth)
+		if err != nil {
+			return err
+		}
+		token.SetAuthHeader(req)
+	case types.ToolAPIAuthTypeOAuth2User:
+		if c.oauth == nil {
+			return fmt.Errorf("oauth2 is not configured")
+		}
+		vals, ok := oai.GetContextValues(ctx)
+		if !ok || vals.OwnerID == "" || vals.OwnerID == oai.SystemID {
+			return fmt.Errorf("tool %s acts on behalf of a user, but there is no user", tool.Name)
+		}
+		linkedFor := OAuthLinkedFor(ctx, tool)
+		if linkedFor == "" {
+			return fmt.Errorf("tool %s acts on behalf of a user, but isn't part of an app", tool.Name)
+		}
+		token, err := c.oauth.UserToken(ctx, vals.OwnerID, linkedFor, &auth.ToolAPIAuth)
+		if err != nil {
+			return fmt.Errorf("tool %s: %w", tool.Name, err)
+		}
+		token.SetAuthHeader(req)
+	}
+
+	return nil
+}
@@ -0,0 +1,343 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"golang.org/x/oauth2"
+
+	oai "github.com/helixml/helix/api/pkg/openai"
+	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+func contactsTool(t *testing.T, auth *types.ToolAPIAuth) *types.Tool {
+	spec, err := os.ReadFile("./testdata/contacts.yaml")
+	require.NoError(t, err)
+
+	return &types.Tool{
+		Name:     "contacts",
+		ToolType: types.ToolTypeAPI,
+		Config: types.ToolConfig{
+			API: &types.ToolAPIConfig{
+				URL:    "https://example.com",
+				Schema: string(spec),
+				Auth:   auth,
+			},
+		},
+	}
+}
+
+// tokenServer issues numbered tokens, expiring after expiresIn seconds
+func tokenServer(t *testing.T, expiresIn int, requests *atomic.Int32) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		n := requests.Add(1)
+
+		w.Header().Set("Content-Type", "application/json")
+		if r.Form.Get("grant_type") == "refresh_token" && r.Form.Get("refresh_token") == "revoked" {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": "invalid_grant"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  fmt.Sprintf("token-%d", n),
+			"refresh_token": fmt.Sprintf("refresh-%d", n),
+			"token_type":    "Bearer",
+			"expires_in":    expiresIn,
+			"scope":         r.Form.Get("scope"),
+		})
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func Test_prepareRequest_APIKey(t *testing.T) {
+	strategy := &ChainStrategy{}
+
+	tests := []struct {
+		name   string
+		auth   *types.ToolAPIAuth
+		action string
+		check  func(t *testing.T, req *http.Request)
+	}{
+		{
+			name:   "header from operation security",
+			auth:   &types.ToolAPIAuth{Type: types.ToolAPIAuthTypeAPIKey, APIKey: "secret"},
+			action: "listContacts",
+			check: func(t *testing.T, req *http.Request) {
+				assert.Equal(t, "secret", req.Header.Get("X-API-Key"))
+			},
+		},
+		{
+			name:   "query parameter",
+			auth:   &types.ToolAPIAuth{Type: types.ToolAPIAuthTypeAPIKey, APIKey: "secret"},
+			action: "searchContacts",
+			check: func(t *testing.T, req *http.Request) {
+				assert.Equal(t, "secret", req.URL.Query().Get("api_key"))
+			},
+		},
+		{
+			name:   "named bearer scheme",
+			auth:   &types.ToolAPIAuth{Type: types.ToolAPIAuthTypeAPIKey, Scheme: "bearerAuth", APIKey: "secret"},
+			action: "listContacts",
+			check: func(t *testing.T, req *http.Request) {
+				assert.Equal(t, "Bearer secret", req.Header.Get("Authorization"))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := strategy.prepareRequest(context.Background(), contactsTool(t, tt.auth), tt.action, map[string]interface{}{})
+			require.NoError(t, err)
+			tt.check(t, req)
+		})
+	}
+}
+
+func Test_prepareRequest_InvalidAuth(t *testing.T) {
+	strategy := &ChainStrategy{}
+
+	tests := []struct {
+		name string
+		auth *types.ToolAPIAuth
+	}{
+		{name: "missing key", auth: &types.ToolAPIAuth{Type: types.ToolAPIAuthTypeAPIKey}},
+		{name: "unknown scheme", auth: &types.ToolAPIAuth{Type: types.ToolAPIAuthTypeAPIKey, Scheme: "nope", APIKey: "secret"}},
+		{name: "missing client id", auth: &types.ToolAPIAuth{Type: types.ToolAPIAuthTypeOAuth2ClientCredentials}},
+		{name: "unknown type", auth: &types.ToolAPIAuth{Type: "magic"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := strategy.prepareRequest(context.Background(), contactsTool(t, tt.auth), "listContacts", map[string]interface{}{})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func Test_prepareRequest_ClientCredentialsCachesToken(t *testing.T) {
+	var requests atomic.Int32
+	ts := tokenServer(t, 3600, &requests)
+
+	oauth, err := NewOAuthManager(nil, ts.Client(), "")
+	require.NoError(t, err)
+	strategy := &ChainStrategy{oauth: oauth}
+
+	tool := contactsTool(t, &types.ToolAPIAuth{
+		Type:         types.ToolAPIAuthTypeOAuth2ClientCredentials,
+		ClientID:     "helix",
+		ClientSecret: "shh",
+		TokenURL:     ts.URL + "/token",
+	})
+
+	for i := 0; i < 2; i++ {
+		req, err := strategy.prepareRequest(context.Background(), tool, "listContacts", map[string]interface{}{})
+		require.NoError(t, err)
+		assert.Equal(t, "Bearer token-1", req.Header.Get("Authorization"))
+	}
+	assert.Equal(t, int32(1), requests.Load())
+
+	// Scopes default to everything the spec asks for with the scheme
+	resolved, err := ResolveAuth(tool)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"contacts.read", "contacts.write"}, resolved.Scopes)
+}
+
+func Test_prepareRequest_ClientCredentialsRefreshesExpiredToken(t *testing.T) {
+	var requests atomic.Int32
+	// Tokens are treated as expired a little early, so this one is never reused
+	ts := tokenServer(t, 1, &requests)
+
+	oauth, err := NewOAuthManager(nil, ts.Client(), "")
+	require.NoError(t, err)
+	strategy := &ChainStrategy{oauth: oauth}
+
+	tool := contactsTool(t, &types.ToolAPIAuth{
+		Type:     types.ToolAPIAuthTypeOAuth2ClientCredentials,
+		ClientID: "helix",
+		TokenURL: ts.URL + "/token",
+	})
+
+	for i := 1; i <= 2; i++ {
+		req, err := strategy.prepareRequest(context.Background(), tool, "listContacts", map[string]interface{}{})
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("Bearer token-%d", i), req.Header.Get("Authorization"))
+	}
+}
+
+// memoryTokenStore keeps per-user tokens in a mock store
+func memoryTokenStore(ctrl *gomock.Controller) (*store.MockStore, *types.ToolOAuthToken) {
+	stored := &types.ToolOAuthToken{}
+	mockStore := store.NewMockStore(ctrl)
+
+	mockStore.EXPECT().UpsertToolOAuthToken(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, token *types.ToolOAuthToken) (*types.ToolOAuthToken, error) {
+			*stored = *token
+			return stored, nil
+		}).AnyTimes()
+	mockStore.EXPECT().GetToolOAuthToken(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, q *store.GetToolOAuthTokenQuery) (*types.ToolOAuthToken, error) {
+			if stored.Owner != q.Owner || stored.LinkedFor != q.LinkedFor || stored.TokenURL != q.TokenURL || stored.ClientID != q.ClientID {
+				return nil, store.ErrNotFound
+			}
+			token := *stored
+			return &token, nil
+		}).AnyTimes()
+
+	return mockStore, stored
+}
+
+func Test_OAuthManager_UserToken(t *testing.T) {
+	var requests atomic.Int32
+	ts := tokenServer(t, 3600, &requests)
+
+	mockStore, stored := memoryTokenStore(gomock.NewController(t))
+	oauth, err := NewOAuthManager(mockStore, ts.Client(), "encryption-key")
+	require.NoError(t, err)
+
+	auth := &types.ToolAPIAuth{
+		Type:     types.ToolAPIAuthTypeOAuth2User,
+		ClientID: "helix",
+		AuthURL:  ts.URL + "/authorize",
+		TokenURL: ts.URL + "/token",
+	}
+	user := &types.User{ID: "user-1", Type: types.OwnerTypeUser}
+
+	_, err = oauth.UserToken(context.Background(), user.ID, "app-1", auth)
+	require.ErrorIs(t, err, ErrOAuthAccountNotLinked)
+
+	require.NoError(t, oauth.Exchange(context.Background(), user, "app-1", auth, "code", "https://helix.example.com/callback"))
+
+	// Tokens are encrypted at rest
+	assert.NotContains(t, string(stored.AccessToken), "token-1")
+	assert.NotContains(t, string(stored.RefreshToken), "refresh-1")
+
+	token, err := oauth.UserToken(context.Background(), user.ID, "app-1", auth)
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token.AccessToken)
+
+	connected, err := oauth.Connected(context.Background(), user.ID, "app-1", auth)
+	require.NoError(t, err)
+	assert.True(t, connected)
+
+	// Expired tokens are refreshed and the new token is kept
+	stored.Expiry = time.Now().Add(-time.Minute)
+	token, err = oauth.UserToken(context.Background(), user.ID, "app-1", auth)
+	require.NoError(t, err)
+	assert.Equal(t, "token-2", token.AccessToken)
+	assert.True(t, stored.Expiry.After(time.Now()))
+
+	// Other users haven't linked their accounts
+	_, err = oauth.UserToken(context.Background(), "user-2", "app-1", auth)
+	assert.ErrorIs(t, err, ErrOAuthAccountNotLinked)
+
+	// And other apps don't get the account, even with the same provider config
+	_, err = oauth.UserToken(context.Background(), user.ID, "app-2", auth)
+	assert.ErrorIs(t, err, ErrOAuthAccountNotLinked)
+}
+
+func Test_OAuthManager_RevokedRefreshToken(t *testing.T) {
+	var requests atomic.Int32
+	ts := tokenServer(t, 3600, &requests)
+
+	mockStore, _ := memoryTokenStore(gomock.NewController(t))
+	oauth, err := NewOAuthManager(mockStore, ts.Client(), "encryption-key")
+	require.NoError(t, err)
+
+	auth := &types.ToolAPIAuth{Type: types.ToolAPIAuthTypeOAuth2User, ClientID: "helix", TokenURL: ts.URL + "/token"}
+	user := &types.User{ID: "user-1"}
+	require.NoError(t, oauth.saveToken(context.Background(), user, "app-1", auth, &oauth2.Token{
+		AccessToken:  "old",
+		RefreshToken: "revoked",
+		Expiry:       time.Now().Add(-time.Minute),
+	}))
+
+	_, err = oauth.UserToken(context.Background(), user.ID, "app-1", auth)
+	assert.ErrorIs(t, err, ErrOAuthAccountNotLinked)
+}
+
+func Test_prepareRequest_UserOAuth(t *testing.T) {
+	var requests atomic.Int32
+	ts := tokenServer(t, 3600, &requests)
+
+	mockStore, _ := memoryTokenStore(gomock.NewController(t))
+	oauth, err := NewOAuthManager(mockStore, ts.Client(), "encryption-key")
+	require.NoError(t, err)
+	strategy := &ChainStrategy{oauth: oauth}
+
+	tool := contactsTool(t, &types.ToolAPIAuth{
+		Type:     types.ToolAPIAuthTypeOAuth2User,
+		ClientID: "helix",
+		TokenURL: ts.URL + "/token",
+	})
+	auth, err := ResolveAuth(tool)
+	require.NoError(t, err)
+	// The authorization URL comes from the spec
+	assert.Equal(t, "https://auth.example.com/authorize", auth.AuthURL)
+
+	// Without a user there's nobody to act for
+	_, err = strategy.prepareRequest(context.Background(), tool, "createContact", map[string]interface{}{})
+	assert.Error(t, err)
+
+	ctx := oai.SetContextValues(context.Background(), &oai.ContextValues{OwnerID: "user-1"})
+
+	// Nor an app to have linked the account for
+	_, err = strategy.prepareRequest(ctx, tool, "createContact", map[string]interface{}{})
+	assert.ErrorContains(t, err, "isn't part of an app")
+
+	ctx = oai.SetContextAppID(ctx, "app-1")
+	_, err = strategy.prepareRequest(ctx, tool, "createContact", map[string]interface{}{})
+	assert.ErrorIs(t, err, ErrOAuthAccountNotLinked)
+
+	require.NoError(t, oauth.Exchange(ctx, &types.User{ID: "user-1"}, "app-1", auth, "code", ""))
+
+	req, err := strategy.prepareRequest(ctx, tool, "createContact", map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer token-1", req.Header.Get("Authorization"))
+
+	// Another app with the same provider config doesn't get the token
+	_, err = strategy.prepareRequest(oai.SetContextAppID(ctx, "app-2"), tool, "createContact", map[string]interface{}{})
+	assert.ErrorIs(t, err, ErrOAuthAccountNotLinked)
+}
+
+func Test_OAuthManager_State(t *testing.T) {
+	oauth, err := NewOAuthManager(nil, nil, "encryption-key")
+	require.NoError(t, err)
+
+	encoded, err := oauth.EncodeState(&OAuthState{UserID: "user-1", AppID: "app-1", ToolName: "contacts"})
+	require.NoError(t, err)
+	assert.NotContains(t, encoded, "user-1")
+
+	state, err := oauth.DecodeState(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", state.UserID)
+	assert.Equal(t, "contacts", state.ToolName)
+
+	// Tampering is detected
+	tampered := []byte(encoded)
+	tampered[len(tampered)/2] ^= 1
+	_, err = oauth.DecodeState(string(tampered))
+	assert.Error(t, err)
+
+	// As is a state from another key
+	other, err := NewOAuthManager(nil, nil, "another-key")
+	require.NoError(t, err)
+	_, err = other.DecodeState(encoded)
+	assert.Error(t, err)
+
+	// Linking accounts needs a key
+	noKey, err := NewOAuthManager(nil, nil, "")
+	require.NoError(t, err)
+	_, err = noKey.EncodeState(&OAuthState{UserID: "user-1"})
+	assert.ErrorContains(t, err, "TOOLS_OAUTH_ENCRYPTION_KEY")
+}
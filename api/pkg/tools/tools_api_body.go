@@ -0,0 +1,244 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// requestBodyParamName holds the whole request body when its schema isn't an object, e.g. an
+// array. Object bodies have their properties as top level parameters instead.
+const requestBodyParamName = "body"
+
+const (
+	mediaTypeJSON      = "application/json"
+	mediaTypeForm      = "application/x-www-form-urlencoded"
+	mediaTypeMultipart = "multipart/form-data"
+)
+
+// requestBodyMediaType picks the request body content type to send, preferring JSON. Returns an
+// empty string if the operation has no body we know how to encode.
+func requestBodyMediaType(operation *openapi3.Operation) (string, *openapi3.MediaType) {
+	if operation.RequestBody == nil || operation.RequestBody.Value == nil {
+		return "", nil
+	}
+	content := operation.RequestBody.Value.Content
+
+	for _, mediaType := range []string{mediaTypeJSON, mediaTypeForm, mediaTypeMultipart} {
+		if mt := content.Get(mediaType); mt != nil {
+			return mediaType, mt
+		}
+	}
+	// Vendor JSON types, e.g. application/merge-patch+json
+	names := make([]string, 0, len(content))
+	for name := range content {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if strings.HasSuffix(name, "+json") {
+			return name, content[name]
+		}
+	}
+	return "", nil
+}
+
+func isObjectSchema(schema *openapi3.Schema) bool {
+	return schema.Type.Is(openapi3.TypeObject) || (schema.Type == nil && len(schema.Properties) > 0)
+}
+
+// buildRequestBody encodes the parameters that aren't path, query or header parameters as the
+// request body. The body is checked against its schema before sending so that the LLM gets a
+// useful error rather than the API rejecting it.
+func buildRequestBody(operation *openapi3.Operation, params map[string]interface{}, used map[string]bool) (io.Reader, string, error) {
+	mediaType, mt := requestBodyMediaType(operation)
+	if mediaType == "" {
+		return nil, "", nil
+	}
+
+	schema := &openapi3.Schema{}
+	if mt.Schema != nil && mt.Schema.Value != nil {
+		schema = mt.Schema.Value
+	}
+
+	var body interface{}
+	if isObjectSchema(schema) {
+		fields := make(map[string]interface{})
+		// The LLM sometimes nests the fields under "body" anyway
+		if nested, ok := params[requestBodyParamName].(map[string]interface{}); ok && schema.Properties[requestBodyParamName] == nil {
+			for k, v := range nested {
+				fields[k] = v
+			}
+		}
+		for k, v := range params {
+			if used[k] || (k == requestBodyParamName && schema.Properties[requestBodyParamName] == nil) {
+				continue
+			}
+			fields[k] = v
+		}
+		if len(fields) > 0 {
+			body = fields
+		}
+	} else {
+		body = params[requestBodyParamName]
+	}
+
+	if body == nil {
+		if operation.RequestBody.Value.Required {
+			return nil, "", fmt.Errorf("request body is required")
+		}
+		return nil, "", nil
+	}
+
+	body = coerceToSchema(body, schema)
+	if err := schema.VisitJSON(body, openapi3.VisitAsRequest(), openapi3.MultiErrors()); err != nil {
+		return nil, "", fmt.Errorf("request body does not match schema: %w", err)
+	}
+
+	switch mediaType {
+	case mediaTypeForm:
+		fields, ok := body.(map[string]interface{})
+		if !ok {
+			return nil, "", fmt.Errorf("form request body must be an object")
+		}
+		values := url.Values{}
+		for k, v := range fields {
+			for _, s := range paramStrings(v) {
+				values.Add(k, s)
+			}
+		}
+		return strings.NewReader(values.Encode()), mediaType, nil
+	case mediaTypeMultipart:
+		fields, ok := body.(map[string]interface{})
+		if !ok {
+			return nil, "", fmt.Errorf("multipart request body must be an object")
+		}
+		var buf bytes.Buffer
+		writer := multipart.NewWriter(&buf)
+		keys := make([]string, 0, len(fields))
+		for k := range fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			for _, s := range paramStrings(fields[k]) {
+				if err := writer.WriteField(k, s); err != nil {
+					return nil, "", fmt.Errorf("failed to write multipart field %s: %w", k, err)
+				}
+			}
+		}
+		if err := writer.Close(); err != nil {
+			return nil, "", err
+		}
+		return &buf, writer.FormDataContentType(), nil
+	default:
+		bts, err := json.Marshal(body)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to marshal request body: %w", err)
+		}
+		return bytes.NewReader(bts), mediaType, nil
+	}
+}
+
+// coerceToSchema converts values to the types the schema expects where that's unambiguous, e.g.
+// "5" for an integer. LLMs and callers that only deal in strings get this wrong a lot.
+func coerceToSchema(value interface{}, schema *openapi3.Schema) interface{} {
+	if schema == nil {
+		return value
+	}
+
+	switch v := value.(type) {
+	case string:
+		switch {
+		case schema.Type.Is(openapi3.TypeInteger), schema.Type.Is(openapi3.TypeNumber):
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				return f
+			}
+		case schema.Type.Is(openapi3.TypeBoolean):
+			if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+				return b
+			}
+		case schema.Type.Is(openapi3.TypeArray), isObjectSchema(schema):
+			var decoded interface{}
+			if err := json.Unmarshal([]byte(v), &decoded); err == nil {
+				return coerceToSchema(decoded, schema)
+			}
+			if schema.Type.Is(openapi3.TypeArray) {
+				// A single item
+				return []interface{}{coerceToSchema(v, itemSchema(schema))}
+			}
+		}
+	case []interface{}:
+		items := itemSchema(schema)
+		coerced := make([]interface{}, len(v))
+		for i, item := range v {
+			coerced[i] = coerceToSchema(item, items)
+		}
+		return coerced
+	case map[string]interface{}:
+		coerced := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			var propSchema *openapi3.Schema
+			if prop, ok := schema.Properties[k]; ok && prop != nil {
+				propSchema = prop.Value
+			}
+			coerced[k] = coerceToSchema(item, propSchema)
+		}
+		return coerced
+	default:
+		if schema.Type.Is(openapi3.TypeArray) {
+			return []interface{}{coerceToSchema(v, itemSchema(schema))}
+		}
+	}
+	return value
+}
+
+func itemSchema(schema *openapi3.Schema) *openapi3.Schema {
+	if schema.Items == nil {
+		return nil
+	}
+	return schema.Items.Value
+}
+
+// paramString formats a parameter value for a path, query string or header.
+func paramString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case map[string]interface{}, []interface{}:
+		bts, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(bts)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// paramStrings formats a parameter value that may be repeated, such as an array in a query string.
+func paramStrings(value interface{}) []string {
+	items, ok := value.([]interface{})
+	if !ok {
+		return []string{paramString(value)}
+	}
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		values = append(values, paramString(item))
+	}
+	return values
+}
@@ -0,0 +1,172 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/helixml/helix/api/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ticketsTool(t *testing.T) *types.Tool {
+	spec, err := os.ReadFile("./testdata/tickets.yaml")
+	require.NoError(t, err)
+
+	return &types.Tool{
+		Name:     "tickets",
+		ToolType: types.ToolTypeAPI,
+		Config: types.ToolConfig{
+			API: &types.ToolAPIConfig{
+				URL:    "https://example.com",
+				Schema: string(spec),
+			},
+		},
+	}
+}
+
+func Test_prepareRequest_JSONBody(t *testing.T) {
+	strategy := &ChainStrategy{}
+
+	params, err := unmarshalParams(`{
+		"title": "Printer jammed",
+		"priority": "2",
+		"tags": ["hardware", "office"],
+		"reporter": {"email": "jo@example.com"}
+	}`)
+	require.NoError(t, err)
+
+	req, err := strategy.prepareRequest(context.Background(), ticketsTool(t), "createTicket", params)
+	require.NoError(t, err)
+
+	assert.Equal(t, "POST", req.Method)
+	assert.Equal(t, "https://example.com/tickets", req.URL.String())
+	assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+
+	var body map[string]interface{}
+	require.NoError(t, json.NewDecoder(req.Body).Decode(&body))
+	assert.Equal(t, map[string]interface{}{
+		"title":    "Printer jammed",
+		"priority": float64(2), // Coerced from a string
+		"tags":     []interface{}{"hardware", "office"},
+		"reporter": map[string]interface{}{"email": "jo@example.com"},
+	}, body)
+}
+
+func Test_prepareRequest_ArrayBody(t *testing.T) {
+	strategy := &ChainStrategy{}
+
+	params, err := unmarshalParams(`{"body": [{"title": "One"}, {"title": "Two"}]}`)
+	require.NoError(t, err)
+
+	req, err := strategy.prepareRequest(context.Background(), ticketsTool(t), "createTickets", params)
+	require.NoError(t, err)
+
+	bts, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"title": "One"}, {"title": "Two"}]`, string(bts))
+}
+
+func Test_prepareRequest_InvalidBody(t *testing.T) {
+	strategy := &ChainStrategy{}
+	tool := ticketsTool(t)
+
+	tests := []struct {
+		name   string
+		params string
+	}{
+		{name: "missing required field", params: `{"priority": 1}`},
+		{name: "out of range", params: `{"title": "A", "priority": 10}`},
+		{name: "wrong nested type", params: `{"title": "A", "reporter": {"email": 5}}`},
+		{name: "read only field", params: `{"title": "A", "id": 5}`},
+		{name: "no body", params: `{}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := unmarshalParams(tt.params)
+			require.NoError(t, err)
+
+			_, err = strategy.prepareRequest(context.Background(), tool, "createTicket", params)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func Test_prepareRequest_FormBody(t *testing.T) {
+	strategy := &ChainStrategy{}
+
+	params, err := unmarshalParams(`{"ticketId": 42, "status": "closed", "watchers": ["a", "b"]}`)
+	require.NoError(t, err)
+
+	req, err := strategy.prepareRequest(context.Background(), ticketsTool(t), "updateTicketStatus", params)
+	require.NoError(t, err)
+
+	assert.Equal(t, "PUT", req.Method)
+	assert.Equal(t, "https://example.com/tickets/42", req.URL.String())
+	assert.Equal(t, "application/x-www-form-urlencoded", req.Header.Get("Content-Type"))
+
+	bts, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	values, err := url.ParseQuery(string(bts))
+	require.NoError(t, err)
+	assert.Equal(t, url.Values{"status": {"closed"}, "watchers": {"a", "b"}}, values)
+}
+
+func Test_prepareRequest_MultipartBody(t *testing.T) {
+	strategy := &ChainStrategy{}
+
+	params, err := unmarshalParams(`{"ticketId": 42, "text": "Fixed it"}`)
+	require.NoError(t, err)
+
+	req, err := strategy.prepareRequest(context.Background(), ticketsTool(t), "commentOnTicket", params)
+	require.NoError(t, err)
+
+	mediaType, mediaParams, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/form-data", mediaType)
+
+	form, err := multipart.NewReader(req.Body, mediaParams["boundary"]).ReadForm(1024)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Fixed it"}, form.Value["text"])
+}
+
+func Test_GetParametersFromSchema_Body(t *testing.T) {
+	tool := ticketsTool(t)
+
+	params, err := GetParametersFromSchema(tool.Config.API.Schema, "createTicket")
+	require.NoError(t, err)
+
+	byName := make(map[string]*Parameter)
+	for _, p := range params {
+		byName[p.Name] = p
+	}
+	// Read only fields are set by the server
+	assert.NotContains(t, byName, "id")
+	require.Contains(t, byName, "title")
+	assert.True(t, byName["title"].Required)
+	assert.Equal(t, ParameterInBody, byName["title"].In)
+	assert.Equal(t, ParameterTypeArray, byName["tags"].Type)
+	assert.Equal(t, ParameterTypeObject, byName["reporter"].Type)
+	assert.Contains(t, byName["reporter"].Schema.Properties, "email")
+
+	params, err = GetParametersFromSchema(tool.Config.API.Schema, "createTickets")
+	require.NoError(t, err)
+	require.Len(t, params, 1)
+	assert.Equal(t, requestBodyParamName, params[0].Name)
+	assert.Equal(t, ParameterTypeArray, params[0].Type)
+}
+
+func Test_filterOpenAPISchema_IncludesBodySchemas(t *testing.T) {
+	spec, err := filterOpenAPISchema(ticketsTool(t), "createTicket")
+	require.NoError(t, err)
+
+	// Nested schemas are needed to construct the body
+	assert.Contains(t, spec, `"NewTicket"`)
+	assert.Contains(t, spec, `"Person"`)
+}
@@ -3,27 +3,28 @@ package tools
 import (
 	"context"
 	"fmt"
-	"io"
-	"net/http"
 	"time"
 
+	"github.com/helixml/helix/api/pkg/guardrails"
 	oai "github.com/helixml/helix/api/pkg/openai"
 	"github.com/helixml/helix/api/pkg/types"
 	"github.com/rs/zerolog/log"
 	openai "github.com/sashabaranov/go-openai"
 )
 
-func (c *ChainStrategy) interpretResponse(ctx context.Context, sessionID, interactionID string, tool *types.Tool, history []*types.ToolHistoryMessage, resp *http.Response) (*RunActionResponse, error) {
-	bts, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
-	}
+func (c *ChainStrategy) interpretResponse(ctx context.Context, sessionID, interactionID string, tool *types.Tool, action string, history []*types.ToolHistoryMessage, resp *apiResponse) (*RunActionResponse, error) {
+	resp.Body = checkResponseContent(ctx, tool, resp.Body)
 
 	if resp.StatusCode >= 400 {
-		return c.handleErrorResponse(ctx, sessionID, interactionID, tool, resp.StatusCode, bts)
+		return c.handleErrorResponse(ctx, sessionID, interactionID, tool, resp.StatusCode, resp.Body)
+	}
+
+	body, err := c.shapeResponse(ctx, sessionID, interactionID, tool, action, history, resp.Body)
+	if err != nil {
+		return nil, err
 	}
 
-	return c.handleSuccessResponse(ctx, sessionID, interactionID, tool, history, bts)
+	return c.handleSuccessResponse(ctx, sessionID, interactionID, tool, history, body)
 }
 
 func (c *ChainStrategy) handleSuccessResponse(ctx context.Context, sessionID, interactionID string, tool *types.Tool, history []*types.ToolHistoryMessage, body []byte) (*RunActionResponse, error) {
@@ -117,22 +118,31 @@ func (c *ChainStrategy) handleErrorResponse(ctx context.Context, sessionID, inte
 	}, nil
 }
 
-func (c *ChainStrategy) interpretResponseStream(ctx context.Context, sessionID, interactionID string, tool *types.Tool, history []*types.ToolHistoryMessage, resp *http.Response) (*openai.ChatCompletionStream, error) {
-	bts, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
-	}
+func (c *ChainStrategy) interpretResponseStream(ctx context.Context, sessionID, interactionID string, tool *types.Tool, action string, history []*types.ToolHistoryMessage, resp *apiResponse) (*openai.ChatCompletionStream, error) {
+	resp.Body = checkResponseContent(ctx, tool, resp.Body)
 
 	if resp.StatusCode >= 400 {
-		return c.handleSuccessResponseStream(ctx, sessionID, interactionID, tool, history, bts)
+		return c.handleSuccessResponseStream(ctx, sessionID, interactionID, tool, history, resp.Body)
+	}
+
+	body, err := c.shapeResponse(ctx, sessionID, interactionID, tool, action, history, resp.Body)
+	if err != nil {
+		return nil, err
 	}
 
-	return c.handleSuccessResponseStream(ctx, sessionID, interactionID, tool, history, bts)
+	return c.handleSuccessResponseStream(ctx, sessionID, interactionID, tool, history, body)
+}
+
+// checkResponseContent runs the app's guardrails on the API's response before a model reads it, a
+// blocked response is replaced with the guardrail's message
+func checkResponseContent(ctx context.Context, tool *types.Tool, body []byte) []byte {
+	content, _ := guardrails.CheckContent(ctx, tool.Name, string(body))
+	return []byte(content)
 }
 
 func (c *ChainStrategy) prepareSuccessMessages(tool *types.Tool, history []*types.ToolHistoryMessage, body []byte) []openai.ChatCompletionMessage {
 	systemPrompt := successResponsePrompt
-	if tool.Config.API.ResponseSuccessTemplate != "" {
+	if tool.Config.API != nil && tool.Config.API.ResponseSuccessTemplate != "" {
 		systemPrompt = tool.Config.API.ResponseSuccessTemplate
 	}
 
@@ -0,0 +1,422 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/rs/zerolog/log"
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/tidwall/gjson"
+
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+const (
+	// defaultMaxResponseBytes is roughly 8k tokens, leaving room for the rest of the conversation
+	defaultMaxResponseBytes = 32 * 1024
+	// maxSummarisedChunks bounds the LLM calls spent on a single response, anything after is dropped
+	maxSummarisedChunks = 8
+	defaultMaxPages     = 5
+	responseCacheSize   = 1024
+)
+
+// apiResponse is a response that has been read, and merged if it was paginated
+type apiResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+type cachedResponse struct {
+	response *apiResponse
+	expires  time.Time
+}
+
+func newResponseCache() *lru.Cache[string, *cachedResponse] {
+	cache, err := lru.New[string, *cachedResponse](responseCacheSize)
+	if err != nil {
+		// Only fails for a non-positive size
+		panic(err)
+	}
+	return cache
+}
+
+func responseProcessing(tool *types.Tool, action string) *types.ToolAPIResponseProcessing {
+	if tool.Config.API == nil {
+		return &types.ToolAPIResponseProcessing{}
+	}
+	if processing, ok := tool.Config.API.ResponseProcessing[action]; ok && processing != nil {
+		return processing
+	}
+	return &types.ToolAPIResponseProcessing{}
+}
+
+// fetch makes the request, following pages and using the cache as configured for the action
+func (c *ChainStrategy) fetch(ctx context.Context, tool *types.Tool, action string, req *http.Request) (*apiResponse, error) {
+	processing := responseProcessing(tool, action)
+
+	cacheable := c.responseCache != nil && processing.CacheTTL > 0 && req.Method == http.MethodGet
+	var key string
+	if cacheable {
+		key = responseCacheKey(req)
+		if cached, ok := c.responseCache.Get(key); ok {
+			if time.Now().Before(cached.expires) {
+				log.Debug().Str("tool", tool.Name).Str("action", action).Msg("using cached API response")
+				return cached.response, nil
+			}
+			c.responseCache.Remove(key)
+		}
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if processing.Pagination != nil && resp.StatusCode < 400 {
+		resp, err = c.paginate(ctx, req, resp, processing.Pagination)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cacheable && resp.StatusCode < 400 {
+		c.responseCache.Add(key, &cachedResponse{
+			response: resp,
+			expires:  time.Now().Add(time.Duration(processing.CacheTTL)),
+		})
+	}
+
+	return resp, nil
+}
+
+func (c *ChainStrategy) do(req *http.Request) (*apiResponse, error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make api call: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	return &apiResponse{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+	}, nil
+}
+
+// responseCacheKey identifies a request by everything that's sent, credentials included, so that
+// users never see responses meant for someone else
+func responseCacheKey(req *http.Request) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", req.Method, req.URL.String())
+
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(h, "%s: %s\n", name, strings.Join(req.Header[name], ","))
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// paginate follows next pages up to the limit and merges their items into a single JSON array
+func (c *ChainStrategy) paginate(ctx context.Context, req *http.Request, first *apiResponse, pagination *types.ToolAPIPagination) (*apiResponse, error) {
+	if !gjson.ValidBytes(first.Body) {
+		return first, nil
+	}
+
+	maxPages := pagination.MaxPages
+	if maxPages <= 0 {
+		maxPages = defaultMaxPages
+	}
+
+	var items []json.RawMessage
+	page := first
+	pageReq := req
+
+	for n := 1; ; n++ {
+		items = append(items, pageItems(page.Body, pagination.ItemsPath)...)
+
+		if n >= maxPages {
+			break
+		}
+
+		next, err := nextPageRequest(ctx, pageReq, page, pagination)
+		if err != nil {
+			return nil, err
+		}
+		if next == nil {
+			break
+		}
+
+		page, err = c.do(next)
+		if err != nil {
+			return nil, err
+		}
+		if page.StatusCode >= 400 {
+			// Keep what we have rather than losing every page for the sake of one
+			log.Warn().
+				Str("url", next.URL.String()).
+				Int("status_code", page.StatusCode).
+				Msg("failed to get next page, returning the pages so far")
+			break
+		}
+		pageReq = next
+	}
+
+	body, err := json.Marshal(items)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge pages: %w", err)
+	}
+
+	return &apiResponse{
+		StatusCode: first.StatusCode,
+		Header:     first.Header,
+		Body:       body,
+	}, nil
+}
+
+// pageItems returns the items in a page, or the page itself if it doesn't have a list of items
+func pageItems(body []byte, itemsPath string) []json.RawMessage {
+	result := gjson.ParseBytes(body)
+	if itemsPath != "" {
+		result = result.Get(itemsPath)
+	}
+
+	if !result.IsArray() {
+		if !result.Exists() {
+			return nil
+		}
+		return []json.RawMessage{json.RawMessage(result.Raw)}
+	}
+
+	var items []json.RawMessage
+	result.ForEach(func(_, value gjson.Result) bool {
+		items = append(items, json.RawMessage(value.Raw))
+		return true
+	})
+	return items
+}
+
+func nextPageRequest(ctx context.Context, req *http.Request, page *apiResponse, pagination *types.ToolAPIPagination) (*http.Request, error) {
+	var next *http.Request
+
+	switch pagination.Type {
+	case types.ToolAPIPaginationTypeLink:
+		link := nextLink(page.Header.Get("Link"))
+		if link == "" {
+			return nil, nil
+		}
+
+		u, err := req.URL.Parse(link)
+		if err != nil {
+			return nil, fmt.Errorf("invalid next page link %q: %w", link, err)
+		}
+		// The request carries the tool's credentials, so they're only sent back to the same API
+		if u.Host != req.URL.Host {
+			log.Warn().Str("link", link).Msg("not following next page link to a different host")
+			return nil, nil
+		}
+
+		next = req.Clone(ctx)
+		next.URL = u
+		next.Host = u.Host
+	case types.ToolAPIPaginationTypeCursor:
+		if pagination.CursorPath == "" || pagination.CursorParam == "" {
+			return nil, fmt.Errorf("cursor pagination needs cursor_path and cursor_param")
+		}
+
+		cursor := gjson.GetBytes(page.Body, pagination.CursorPath)
+		if !cursor.Exists() || cursor.String() == "" {
+			return nil, nil
+		}
+
+		next = req.Clone(ctx)
+		u := *req.URL
+		q := u.Query()
+		q.Set(pagination.CursorParam, cursor.String())
+		u.RawQuery = q.Encode()
+		next.URL = &u
+	default:
+		return nil, fmt.Errorf("unknown pagination type: %s", pagination.Type)
+	}
+
+	// Next pages are always fetched, whatever the first request was
+	next.Method = http.MethodGet
+	next.Body = nil
+	next.GetBody = nil
+	next.ContentLength = 0
+
+	return next, nil
+}
+
+var linkPattern = regexp.MustCompile(`<([^>]*)>\s*((?:;\s*[^;,]+)*)`)
+
+// nextLink returns the rel="next" URL from a Link header (RFC 8288)
+func nextLink(header string) string {
+	for _, match := range linkPattern.FindAllStringSubmatch(header, -1) {
+		for _, param := range strings.Split(match[2], ";") {
+			name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || !strings.EqualFold(strings.TrimSpace(name), "rel") {
+				continue
+			}
+			for _, rel := range strings.Fields(strings.Trim(strings.TrimSpace(value), `"`)) {
+				if strings.EqualFold(rel, "next") {
+					return match[1]
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// selectResponse projects the response with the action's select expression
+func selectResponse(body []byte, expr string) []byte {
+	if expr == "" || !gjson.ValidBytes(body) {
+		return body
+	}
+
+	result := gjson.GetBytes(body, jsonPathToGJSON(expr))
+	if !result.Exists() {
+		log.Warn().Str("select", expr).Msg("select expression didn't match the API response, using the whole response")
+		return body
+	}
+	return []byte(result.Raw)
+}
+
+var (
+	jsonPathWildcard = regexp.MustCompile(`\[\*\]`)
+	jsonPathIndex    = regexp.MustCompile(`\[(\d+)\]`)
+	jsonPathQuoted   = regexp.MustCompile(`\[['"]([^'"]+)['"]\]`)
+)
+
+// jsonPathToGJSON translates the common subset of JSONPath (child names, indexes and wildcards)
+// into a GJSON path. Anything else is assumed to be GJSON already.
+func jsonPathToGJSON(expr string) string {
+	if !strings.HasPrefix(expr, "$") {
+		return expr
+	}
+
+	path := strings.TrimPrefix(expr, "$")
+	path = jsonPathWildcard.ReplaceAllString(path, ".#")
+	path = jsonPathIndex.ReplaceAllString(path, ".$1")
+	path = jsonPathQuoted.ReplaceAllString(path, ".$1")
+	path = strings.TrimPrefix(path, ".")
+
+	if path == "" {
+		return "@this"
+	}
+	return path
+}
+
+// shapeResponse gets a successful response ready for the model: projected, and summarised if
+// it's still too big
+func (c *ChainStrategy) shapeResponse(ctx context.Context, sessionID, interactionID string, tool *types.Tool, action string, history []*types.ToolHistoryMessage, body []byte) ([]byte, error) {
+	processing := responseProcessing(tool, action)
+
+	body = selectResponse(body, processing.Select)
+
+	maxBytes := processing.MaxBytes
+	if maxBytes == 0 {
+		maxBytes = defaultMaxResponseBytes
+	}
+	if maxBytes < 0 || len(body) <= maxBytes {
+		return body, nil
+	}
+
+	return c.summariseResponse(ctx, sessionID, interactionID, tool, history, body, maxBytes)
+}
+
+// summariseResponse summarises each chunk of an oversized response with the user's request in
+// mind, so that the model is given what's relevant rather than a truncated response
+func (c *ChainStrategy) summariseResponse(ctx context.Context, sessionID, interactionID string, tool *types.Tool, history []*types.ToolHistoryMessage, body []byte, maxBytes int) ([]byte, error) {
+	var request string
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role == openai.ChatMessageRoleUser {
+			request = history[i].Content
+			break
+		}
+	}
+
+	chunks := chunkBytes(body, maxBytes)
+	if len(chunks) > maxSummarisedChunks {
+		log.Warn().
+			Str("tool", tool.Name).
+			Int("size", len(body)).
+			Int("chunks", len(chunks)).
+			Msg("API response is too big to summarise, dropping the end of it")
+		chunks = chunks[:maxSummarisedChunks]
+	}
+
+	// Each chunk's summary has to fit alongside the others
+	maxTokens := max(maxBytes/len(chunks)/4, 256)
+
+	ctx = c.setContextAndStep(ctx, sessionID, interactionID, types.LLMCallStepSummariseResponse)
+
+	var summary bytes.Buffer
+	for i, chunk := range chunks {
+		req := c.prepareChatCompletionRequest([]openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: summariseResponsePrompt,
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: fmt.Sprintf("The user asked: %s\n\nPart %d of %d of the response:\n%s", request, i+1, len(chunks), chunk),
+			},
+		}, false, tool.Config.API.Model)
+		req.MaxTokens = maxTokens
+
+		resp, err := c.apiClient.CreateChatCompletion(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to summarise API response: %w", err)
+		}
+		if len(resp.Choices) == 0 {
+			return nil, fmt.Errorf("no response from inference API")
+		}
+
+		fmt.Fprintf(&summary, "Part %d of %d:\n%s\n\n", i+1, len(chunks), resp.Choices[0].Message.Content)
+	}
+
+	return bytes.TrimSpace(summary.Bytes()), nil
+}
+
+// chunkBytes splits the body into chunks of at most size bytes, preferring to split at new lines
+func chunkBytes(body []byte, size int) [][]byte {
+	var chunks [][]byte
+	for len(body) > size {
+		cut := bytes.LastIndexByte(body[:size], '\n') + 1
+		if cut <= size/2 {
+			cut = size
+		}
+		chunks = append(chunks, body[:cut])
+		body = body[cut:]
+	}
+	if len(body) > 0 {
+		chunks = append(chunks, body)
+	}
+	return chunks
+}
+
+const summariseResponsePrompt = `You are given part of a large API response. Extract the information in it that is relevant to the user's request, keeping names, identifiers, numbers, dates and links exactly as they are.
+Leave out anything irrelevant. If nothing in this part is relevant, reply with "Nothing relevant".
+Reply with the extracted information only.`
@@ -0,0 +1,210 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/helixml/helix/api/pkg/config"
+	oai "github.com/helixml/helix/api/pkg/openai"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+func Test_jsonPathToGJSON(t *testing.T) {
+	tests := map[string]string{
+		"$":                   "@this",
+		"$.items":             "items",
+		"$.items[*].name":     "items.#.name",
+		"$.items[0].name":     "items.0.name",
+		"$['items'][*]['id']": "items.#.id",
+		"items.#.{name,url}":  "items.#.{name,url}",
+	}
+
+	for expr, want := range tests {
+		t.Run(expr, func(t *testing.T) {
+			assert.Equal(t, want, jsonPathToGJSON(expr))
+		})
+	}
+}
+
+func Test_selectResponse(t *testing.T) {
+	body := []byte(`{"total": 2, "items": [{"name": "a", "url": "https://a"}, {"name": "b", "url": "https://b"}]}`)
+
+	assert.JSONEq(t, `["a", "b"]`, string(selectResponse(body, "$.items[*].name")))
+	assert.JSONEq(t, `[{"name": "a"}, {"name": "b"}]`, string(selectResponse(body, "items.#.{name}")))
+
+	// Responses that don't match are kept whole rather than lost
+	assert.Equal(t, body, selectResponse(body, "$.missing"))
+	assert.Equal(t, []byte("not json"), selectResponse([]byte("not json"), "$.items"))
+}
+
+func Test_nextLink(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{header: `<https://api.example.com/items?page=2>; rel="next", <https://api.example.com/items?page=5>; rel="last"`, want: "https://api.example.com/items?page=2"},
+		{header: `<https://api.example.com/items?page=1>; rel="prev"`, want: ""},
+		{header: `</items?page=3>; rel="next last"`, want: "/items?page=3"},
+		{header: ``, want: ""},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, nextLink(tt.header), tt.header)
+	}
+}
+
+func pagedTool(url string, processing *types.ToolAPIResponseProcessing) *types.Tool {
+	return &types.Tool{
+		Name:     "items",
+		ToolType: types.ToolTypeAPI,
+		Config: types.ToolConfig{
+			API: &types.ToolAPIConfig{
+				URL: url,
+				ResponseProcessing: map[string]*types.ToolAPIResponseProcessing{
+					"listItems": processing,
+				},
+			},
+		},
+	}
+}
+
+func Test_fetch_LinkPagination(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret", r.Header.Get("Authorization"))
+
+		page := r.URL.Query().Get("page")
+		switch page {
+		case "", "1":
+			w.Header().Set("Link", `</items?page=2>; rel="next"`)
+		case "2":
+			w.Header().Set("Link", `</items?page=3>; rel="next"`)
+		case "3":
+			// A link elsewhere isn't followed with the tool's credentials
+			w.Header().Set("Link", `<https://elsewhere.example.com/items?page=4>; rel="next"`)
+		default:
+			t.Errorf("unexpected page %s", page)
+		}
+		_, _ = fmt.Fprintf(w, `{"items": [{"page": %q}]}`, page)
+	}))
+	defer ts.Close()
+
+	strategy := &ChainStrategy{httpClient: ts.Client()}
+	tool := pagedTool(ts.URL, &types.ToolAPIResponseProcessing{
+		Pagination: &types.ToolAPIPagination{
+			Type:      types.ToolAPIPaginationTypeLink,
+			ItemsPath: "items",
+		},
+	})
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/items", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "secret")
+
+	resp, err := strategy.fetch(context.Background(), tool, "listItems", req)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"page": ""}, {"page": "2"}, {"page": "3"}]`, string(resp.Body))
+}
+
+func Test_fetch_CursorPagination(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		assert.Equal(t, "10", r.URL.Query().Get("limit"))
+		_, _ = fmt.Fprintf(w, `{"data": [%d, %d], "meta": {"next": "c%d"}}`, n*2-1, n*2, n)
+	}))
+	defer ts.Close()
+
+	strategy := &ChainStrategy{httpClient: ts.Client()}
+	tool := pagedTool(ts.URL, &types.ToolAPIResponseProcessing{
+		Pagination: &types.ToolAPIPagination{
+			Type:        types.ToolAPIPaginationTypeCursor,
+			CursorPath:  "meta.next",
+			CursorParam: "cursor",
+			ItemsPath:   "data",
+			MaxPages:    3,
+		},
+	})
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/items?limit=10", nil)
+	require.NoError(t, err)
+
+	resp, err := strategy.fetch(context.Background(), tool, "listItems", req)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[1, 2, 3, 4, 5, 6]`, string(resp.Body))
+	assert.Equal(t, int32(3), requests.Load())
+}
+
+func Test_fetch_Cache(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		_, _ = fmt.Fprintf(w, `{"user": %q, "n": %d}`, r.Header.Get("Authorization"), n)
+	}))
+	defer ts.Close()
+
+	strategy := &ChainStrategy{httpClient: ts.Client(), responseCache: newResponseCache()}
+	tool := pagedTool(ts.URL, &types.ToolAPIResponseProcessing{
+		CacheTTL: types.Duration(time.Minute),
+	})
+
+	get := func(auth string) string {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+"/items", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", auth)
+
+		resp, err := strategy.fetch(context.Background(), tool, "listItems", req)
+		require.NoError(t, err)
+		return string(resp.Body)
+	}
+
+	first := get("alice")
+	assert.Equal(t, first, get("alice"))
+	assert.Equal(t, int32(1), requests.Load())
+
+	// Someone else's credentials never get a cached response
+	assert.JSONEq(t, `{"user": "bob", "n": 2}`, get("bob"))
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func Test_shapeResponse_Summarises(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	apiClient := oai.NewMockClient(ctrl)
+
+	strategy := &ChainStrategy{
+		cfg:       &config.ServerConfig{},
+		apiClient: apiClient,
+	}
+
+	tool := pagedTool("https://example.com", &types.ToolAPIResponseProcessing{MaxBytes: 100})
+	body := []byte(strings.Repeat(`{"name": "item"}`+"\n", 15))
+
+	apiClient.EXPECT().CreateChatCompletion(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+			assert.Contains(t, req.Messages[1].Content, "The user asked: list the items")
+			return openai.ChatCompletionResponse{
+				Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: "items"}}},
+			}, nil
+		}).Times(3)
+
+	shaped, err := strategy.shapeResponse(context.Background(), "ses_1", "int_1", tool, "listItems", []*types.ToolHistoryMessage{
+		{Role: openai.ChatMessageRoleUser, Content: "list the items"},
+	}, body)
+	require.NoError(t, err)
+	assert.Equal(t, "Part 1 of 3:\nitems\n\nPart 2 of 3:\nitems\n\nPart 3 of 3:\nitems", string(shaped))
+
+	// Small responses are given to the model as they are
+	shaped, err = strategy.shapeResponse(context.Background(), "ses_1", "int_1", tool, "listItems", nil, []byte(`{"name": "item"}`))
+	require.NoError(t, err)
+	assert.Equal(t, `{"name": "item"}`, string(shaped))
+}
@@ -2,9 +2,8 @@ package tools
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	"time"
 
 	"github.com/avast/retry-go/v4"
@@ -36,9 +35,15 @@ func (c *ChainStrategy) RunAction(ctx context.Context, sessionID, interactionID
 			retry.Attempts(apiActionRetries),
 			retry.Delay(delayBetweenAPIRetries),
 			retry.Context(ctx),
+			retry.RetryIf(func(err error) bool {
+				// The user has to link their account first
+				return !errors.Is(err, ErrOAuthAccountNotLinked)
+			}),
 		)
 	case types.ToolTypeZapier:
 		return c.RunZapierAction(ctx, tool, history, action)
+	case types.ToolTypeMCP:
+		return c.RunMCPAction(ctx, sessionID, interactionID, tool, history, action)
 	default:
 		return nil, fmt.Errorf("unknown tool type: %s", tool.ToolType)
 	}
@@ -52,6 +57,8 @@ func (c *ChainStrategy) RunActionStream(ctx context.Context, sessionID, interact
 		return c.runAPIActionStream(ctx, sessionID, interactionID, tool, history, action)
 	case types.ToolTypeZapier:
 		return c.RunZapierActionStream(ctx, tool, history, action)
+	case types.ToolTypeMCP:
+		return c.RunMCPActionStream(ctx, sessionID, interactionID, tool, history, action)
 	default:
 		return nil, fmt.Errorf("unknown tool type: %s", tool.ToolType)
 	}
@@ -62,9 +69,8 @@ func (c *ChainStrategy) runAPIAction(ctx context.Context, sessionID, interaction
 	if err != nil {
 		return nil, fmt.Errorf("failed to call api: %w", err)
 	}
-	defer resp.Body.Close()
 
-	return c.interpretResponse(ctx, sessionID, interactionID, tool, history, resp)
+	return c.interpretResponse(ctx, sessionID, interactionID, tool, action, history, resp)
 }
 
 func (c *ChainStrategy) runAPIActionStream(ctx context.Context, sessionID, interactionID string, tool *types.Tool, history []*types.ToolHistoryMessage, action string) (*openai.ChatCompletionStream, error) {
@@ -72,12 +78,11 @@ func (c *ChainStrategy) runAPIActionStream(ctx context.Context, sessionID, inter
 	if err != nil {
 		return nil, fmt.Errorf("failed to call api: %w", err)
 	}
-	defer resp.Body.Close()
 
-	return c.interpretResponseStream(ctx, sessionID, interactionID, tool, history, resp)
+	return c.interpretResponseStream(ctx, sessionID, interactionID, tool, action, history, resp)
 }
 
-func (c *ChainStrategy) callAPI(ctx context.Context, sessionID, interactionID string, tool *types.Tool, history []*types.ToolHistoryMessage, action string) (*http.Response, error) {
+func (c *ChainStrategy) callAPI(ctx context.Context, sessionID, interactionID string, tool *types.Tool, history []*types.ToolHistoryMessage, action string) (*apiResponse, error) {
 	// Validate whether action is valid
 	if action == "" {
 		return nil, fmt.Errorf("action is required")
@@ -126,9 +131,9 @@ func (c *ChainStrategy) callAPI(ctx context.Context, sessionID, interactionID st
 	started = time.Now()
 
 	// Make API call
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.fetch(ctx, tool, action, req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to make api call: %w", err)
+		return nil, err
 	}
 
 	log.Info().
@@ -153,9 +158,19 @@ func (c *ChainStrategy) RunAPIActionWithParameters(ctx context.Context, req *typ
 		return nil, fmt.Errorf("action is required")
 	}
 
+	// There's no user to ask here, so actions that need approval go through
+	// PrepareAPIActionWithParameters instead
+	switch ActionPolicy(req.Tool, req.Action) {
+	case types.ToolActionPolicyAuto:
+	case types.ToolActionPolicyDeny:
+		return nil, fmt.Errorf("%w: %s", ErrActionDenied, req.Action)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrActionNeedsApproval, req.Action)
+	}
+
 	if req.Parameters == nil {
 		// Initialize empty parameters map, some API actions don't require parameters
-		req.Parameters = make(map[string]string)
+		req.Parameters = make(map[string]interface{})
 	}
 
 	log.Info().
@@ -168,16 +183,18 @@ func (c *ChainStrategy) RunAPIActionWithParameters(ctx context.Context, req *typ
 		return nil, fmt.Errorf("failed to prepare request: %w", err)
 	}
 
-	resp, err := c.httpClient.Do(httpRequest)
+	resp, err := c.fetch(ctx, req.Tool, req.Action, httpRequest)
 	if err != nil {
-		return nil, fmt.Errorf("failed to make api call: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
-	}
+	return &types.RunAPIActionResponse{Response: string(rawResponse(req.Tool, req.Action, resp))}, nil
+}
 
-	return &types.RunAPIActionResponse{Response: string(body)}, nil
+// rawResponse is the API's response for callers that don't have a model interpret it
+func rawResponse(tool *types.Tool, action string, resp *apiResponse) []byte {
+	if resp.StatusCode >= 400 {
+		return resp.Body
+	}
+	return selectResponse(resp.Body, responseProcessing(tool, action).Select)
 }
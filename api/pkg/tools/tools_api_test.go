@@ -233,7 +233,7 @@ func (suite *ActionTestSuite) Test_prepareRequest_Path() {
 		},
 	}
 
-	params := map[string]string{
+	params := map[string]interface{}{
 		"petId": "99944",
 	}
 
@@ -264,7 +264,7 @@ func (suite *ActionTestSuite) Test_prepareRequest_Path_ProvidedQuery() {
 		},
 	}
 
-	params := map[string]string{
+	params := map[string]interface{}{
 		"petId": "99944",
 	}
 
@@ -295,7 +295,7 @@ func (suite *ActionTestSuite) Test_prepareRequest_Query() {
 		},
 	}
 
-	params := map[string]string{
+	params := map[string]interface{}{
 		"q": "London",
 	}
 
@@ -575,7 +575,7 @@ func Test_unmarshalParams(t *testing.T) {
 	tests := []struct {
 		name    string
 		args    args
-		want    map[string]string
+		want    map[string]interface{}
 		wantErr bool
 	}{
 		{
@@ -583,8 +583,8 @@ func Test_unmarshalParams(t *testing.T) {
 			args: args{
 				data: `{"id": 1000}`,
 			},
-			want: map[string]string{
-				"id": "1000",
+			want: map[string]interface{}{
+				"id": float64(1000),
 			},
 		},
 		{
@@ -592,7 +592,7 @@ func Test_unmarshalParams(t *testing.T) {
 			args: args{
 				data: `{"id": "1000"}`,
 			},
-			want: map[string]string{
+			want: map[string]interface{}{
 				"id": "1000",
 			},
 		},
@@ -601,8 +601,8 @@ func Test_unmarshalParams(t *testing.T) {
 			args: args{
 				data: `{"id": 1005.0}`,
 			},
-			want: map[string]string{
-				"id": "1005",
+			want: map[string]interface{}{
+				"id": float64(1005),
 			},
 		},
 		{
@@ -610,8 +610,8 @@ func Test_unmarshalParams(t *testing.T) {
 			args: args{
 				data: `{"id": 1005.5}`,
 			},
-			want: map[string]string{
-				"id": "1005.5",
+			want: map[string]interface{}{
+				"id": 1005.5,
 			},
 		},
 		{
@@ -619,8 +619,8 @@ func Test_unmarshalParams(t *testing.T) {
 			args: args{
 				data: `{"yes": true}`,
 			},
-			want: map[string]string{
-				"yes": "true",
+			want: map[string]interface{}{
+				"yes": true,
 			},
 		},
 		{
@@ -628,8 +628,8 @@ func Test_unmarshalParams(t *testing.T) {
 			args: args{
 				data: "```json{\"id\": 1000}```",
 			},
-			want: map[string]string{
-				"id": "1000",
+			want: map[string]interface{}{
+				"id": float64(1000),
 			},
 		},
 		{
@@ -637,8 +637,8 @@ func Test_unmarshalParams(t *testing.T) {
 			args: args{
 				data: "```json{\"id\": 1000}```blah blah blah I am very smart LLM",
 			},
-			want: map[string]string{
-				"id": "1000",
+			want: map[string]interface{}{
+				"id": float64(1000),
 			},
 		},
 		{
@@ -646,8 +646,8 @@ func Test_unmarshalParams(t *testing.T) {
 			args: args{
 				data: "```\n{\"id\": 1000}```blah blah blah I am very stupid LLM that cannot follow instructions about backticks",
 			},
-			want: map[string]string{
-				"id": "1000",
+			want: map[string]interface{}{
+				"id": float64(1000),
 			},
 		},
 	}
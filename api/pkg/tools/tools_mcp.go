@@ -0,0 +1,253 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	openai "github.com/sashabaranov/go-openai"
+
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+// discoverMCPTools fills in the tools and resources of MCP servers so that the planner can choose
+// between them. Servers that can't be reached are left out rather than failing the request.
+func (c *ChainStrategy) discoverMCPTools(ctx context.Context, tools []*types.Tool) {
+	for _, tool := range tools {
+		if tool.ToolType != types.ToolTypeMCP || tool.Config.MCP == nil {
+			continue
+		}
+
+		if err := c.mcp.Discover(ctx, tool); err != nil {
+			log.Warn().
+				Err(err).
+				Str("tool", tool.Name).
+				Msg("failed to discover MCP server tools, skipping it")
+		}
+	}
+}
+
+func findMCPTool(tool *types.Tool, action string) (*types.ToolMCPTool, bool) {
+	for _, t := range tool.Config.MCP.Tools {
+		if t.Name == action {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+func findMCPResource(tool *types.Tool, action string) (*types.ToolMCPResource, bool) {
+	for _, r := range tool.Config.MCP.Resources {
+		if r.Name == action {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+func (c *ChainStrategy) RunMCPAction(ctx context.Context, sessionID, interactionID string, tool *types.Tool, history []*types.ToolHistoryMessage, action string) (*RunActionResponse, error) {
+	result, err := c.callMCP(ctx, sessionID, interactionID, tool, history, action)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.interpretMCPResult(ctx, sessionID, interactionID, tool, history, result)
+}
+
+func (c *ChainStrategy) interpretMCPResult(ctx context.Context, sessionID, interactionID string, tool *types.Tool, history []*types.ToolHistoryMessage, result string) (*RunActionResponse, error) {
+	messages := c.prepareSuccessMessages(tool, history, []byte(result))
+	req := c.prepareChatCompletionRequest(messages, false, tool.Config.MCP.Model)
+
+	ctx = c.setContextAndStep(ctx, sessionID, interactionID, types.LLMCallStepInterpretResponse)
+
+	resp, err := c.apiClient.CreateChatCompletion(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get response from inference API: %w", err)
+	}
+
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("no response from inference API")
+	}
+
+	return &RunActionResponse{
+		Message:    resp.Choices[0].Message.Content,
+		RawMessage: result,
+	}, nil
+}
+
+func (c *ChainStrategy) RunMCPActionStream(ctx context.Context, sessionID, interactionID string, tool *types.Tool, history []*types.ToolHistoryMessage, action string) (*openai.ChatCompletionStream, error) {
+	result, err := c.callMCP(ctx, sessionID, interactionID, tool, history, action)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := c.prepareSuccessMessages(tool, history, []byte(result))
+	req := c.prepareChatCompletionRequest(messages, true, tool.Config.MCP.Model)
+
+	ctx = c.setContextAndStep(ctx, sessionID, interactionID, types.LLMCallStepInterpretResponse)
+
+	started := time.Now()
+
+	stream, err := c.apiClient.CreateChatCompletionStream(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get response from inference API: %w", err)
+	}
+
+	c.logLLMCallAsync(sessionID, interactionID, started)
+
+	return stream, nil
+}
+
+// callMCP calls the MCP tool, or reads the resource, that the action names
+func (c *ChainStrategy) callMCP(ctx context.Context, sessionID, interactionID string, tool *types.Tool, history []*types.ToolHistoryMessage, action string) (string, error) {
+	if tool.Config.MCP == nil {
+		return "", fmt.Errorf("tool %s is not an MCP tool", tool.Name)
+	}
+
+	if err := c.mcp.Discover(ctx, tool); err != nil {
+		return "", err
+	}
+
+	if mcpTool, ok := findMCPTool(tool, action); ok {
+		args, err := c.getMCPArguments(ctx, sessionID, interactionID, tool, mcpTool, history)
+		if err != nil {
+			return "", err
+		}
+
+		log.Info().
+			Str("tool", tool.Name).
+			Str("action", action).
+			Msg("calling MCP tool")
+
+		return c.mcp.CallTool(ctx, tool.Config.MCP, mcpTool.Name, args)
+	}
+
+	if resource, ok := findMCPResource(tool, action); ok {
+		log.Info().
+			Str("tool", tool.Name).
+			Str("uri", resource.URI).
+			Msg("reading MCP resource")
+
+		return c.mcp.ReadResource(ctx, tool.Config.MCP, resource.URI)
+	}
+
+	return "", fmt.Errorf("action %s is not found in the tool %s", action, tool.Name)
+}
+
+// PrepareMCPAction works out the arguments of an MCP tool call without making it, so that the
+// user can approve it first
+func (c *ChainStrategy) PrepareMCPAction(ctx context.Context, sessionID, interactionID string, tool *types.Tool, history []*types.ToolHistoryMessage, action string) (*types.ToolApprovalRequest, error) {
+	if tool.Config.MCP == nil {
+		return nil, fmt.Errorf("tool %s is not an MCP tool", tool.Name)
+	}
+
+	if err := c.mcp.Discover(ctx, tool); err != nil {
+		return nil, err
+	}
+
+	mcpTool, ok := findMCPTool(tool, action)
+	if !ok {
+		return nil, fmt.Errorf("action %s is not found in the tool %s", action, tool.Name)
+	}
+
+	args, err := c.getMCPArguments(ctx, sessionID, interactionID, tool, mcpTool, history)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.ToolApprovalRequest{
+		Parameters: args,
+		Model:      tool.Config.MCP.Model,
+		History:    history,
+	}, nil
+}
+
+// RunApprovedMCPAction calls the MCP tool with the arguments the user approved and interprets the
+// result
+func (c *ChainStrategy) RunApprovedMCPAction(ctx context.Context, sessionID, interactionID string, tool *types.Tool, action string, approved *types.ToolApprovalRequest) (*RunActionResponse, error) {
+	if tool.Config.MCP == nil {
+		return nil, fmt.Errorf("tool %s is not an MCP tool", tool.Name)
+	}
+
+	if err := c.mcp.Discover(ctx, tool); err != nil {
+		return nil, err
+	}
+
+	mcpTool, ok := findMCPTool(tool, action)
+	if !ok {
+		return nil, fmt.Errorf("action %s is not found in the tool %s", action, tool.Name)
+	}
+
+	log.Info().
+		Str("tool", tool.Name).
+		Str("action", action).
+		Msg("calling approved MCP tool")
+
+	result, err := c.mcp.CallTool(ctx, tool.Config.MCP, mcpTool.Name, approved.Parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.interpretMCPResult(ctx, sessionID, interactionID, tool, approved.History, result)
+}
+
+// getMCPArguments asks the model for the tool's arguments, following its input schema
+func (c *ChainStrategy) getMCPArguments(ctx context.Context, sessionID, interactionID string, tool *types.Tool, mcpTool *types.ToolMCPTool, history []*types.ToolHistoryMessage) (map[string]interface{}, error) {
+	schema, err := json.MarshalIndent(mcpTool.InputSchema, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal input schema: %w", err)
+	}
+
+	messages := []openai.ChatCompletionMessage{
+		{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: fmt.Sprintf(mcpArgumentsPrompt, mcpTool.Name, mcpTool.Description, schema),
+		},
+	}
+
+	for _, msg := range history {
+		if msg.Role != openai.ChatMessageRoleSystem {
+			messages = append(messages, openai.ChatCompletionMessage{
+				Role:    msg.Role,
+				Content: msg.Content,
+			})
+		}
+	}
+
+	messages = append(messages, openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleUser,
+		Content: "Return the corresponding json for the last user input",
+	})
+
+	req := c.prepareChatCompletionRequest(messages, false, tool.Config.MCP.Model)
+
+	ctx = c.setContextAndStep(ctx, sessionID, interactionID, types.LLMCallStepPrepareAPIRequest)
+
+	resp, err := c.apiClient.CreateChatCompletion(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get response from inference API: %w", err)
+	}
+
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("no response from inference API")
+	}
+
+	answer := strings.TrimSpace(resp.Choices[0].Message.Content)
+	if answer == "" || answer == "{}" {
+		return map[string]interface{}{}, nil
+	}
+
+	return unmarshalParams(answer)
+}
+
+const mcpArgumentsPrompt = `You are an AI that prepares the arguments for a tool call from the conversation with the user.
+The tool is called %s: %s
+
+Its arguments must match this JSON schema:
+%s
+
+Only use values that the user has given or that follow directly from the conversation, leave out optional arguments you don't know.
+Reply with a single JSON object of arguments and nothing else.`
@@ -0,0 +1,261 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/helixml/helix/api/pkg/config"
+	oai "github.com/helixml/helix/api/pkg/openai"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+// weatherMCPTool runs an MCP server with a tool and a resource over SSE
+func weatherMCPTool(t *testing.T) *types.Tool {
+	s := server.NewMCPServer("weather", "1.0.0", server.WithResourceCapabilities(false, false))
+
+	s.AddTool(mcp.NewTool("getForecast",
+		mcp.WithDescription("Get the weather forecast for a city"),
+		mcp.WithString("city", mcp.Required()),
+	), func(_ context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText(fmt.Sprintf("Sunny in %s", req.Params.Arguments["city"])), nil
+	})
+
+	s.AddResource(mcp.NewResource("weather://stations", "weatherStations",
+		mcp.WithResourceDescription("The list of weather stations"),
+		mcp.WithMIMEType("text/plain"),
+	), func(_ context.Context, _ mcp.ReadResourceRequest) ([]interface{}, error) {
+		return []interface{}{
+			mcp.TextResourceContents{
+				ResourceContents: mcp.ResourceContents{URI: "weather://stations", MIMEType: "text/plain"},
+				Text:             "London, Paris",
+			},
+		}, nil
+	})
+
+	ts := server.NewTestServer(s)
+	t.Cleanup(ts.Close)
+
+	return &types.Tool{
+		Name:     "weather",
+		ToolType: types.ToolTypeMCP,
+		Config: types.ToolConfig{
+			MCP: &types.ToolMCPConfig{URL: ts.URL + "/sse"},
+		},
+	}
+}
+
+func Test_MCPClients_Discover(t *testing.T) {
+	tool := weatherMCPTool(t)
+
+	// Closed before the server, which waits for the event stream to end
+	clients := NewMCPClients(false)
+	t.Cleanup(clients.Close)
+
+	require.NoError(t, clients.Discover(context.Background(), tool))
+	require.Len(t, tool.Config.MCP.Tools, 1)
+	assert.Equal(t, "getForecast", tool.Config.MCP.Tools[0].Name)
+	assert.Equal(t, []string{"city"}, tool.Config.MCP.Tools[0].InputSchema["required"])
+	require.Len(t, tool.Config.MCP.Resources, 1)
+	assert.Equal(t, "weather://stations", tool.Config.MCP.Resources[0].URI)
+
+	found, ok := GetToolFromAction([]*types.Tool{tool}, "weatherStations")
+	require.True(t, ok)
+	assert.Equal(t, tool, found)
+
+	text, err := clients.CallTool(context.Background(), tool.Config.MCP, "getForecast", map[string]interface{}{"city": "London"})
+	require.NoError(t, err)
+	assert.Equal(t, "Sunny in London", text)
+
+	text, err = clients.ReadResource(context.Background(), tool.Config.MCP, "weather://stations")
+	require.NoError(t, err)
+	assert.Equal(t, "London, Paris", text)
+}
+
+// fakeMCPClient is a server with no tools, for tests of connecting
+type fakeMCPClient struct {
+	mcpclient.MCPClient
+}
+
+func (fakeMCPClient) ListTools(context.Context, mcp.ListToolsRequest) (*mcp.ListToolsResult, error) {
+	return &mcp.ListToolsResult{}, nil
+}
+
+func (fakeMCPClient) ListResources(context.Context, mcp.ListResourcesRequest) (*mcp.ListResourcesResult, error) {
+	return &mcp.ListResourcesResult{}, nil
+}
+
+func (fakeMCPClient) Close() error { return nil }
+
+func Test_MCPClients_ConcurrentConnect(t *testing.T) {
+	slow := &types.ToolMCPConfig{URL: "http://slow.example.com/sse"}
+	fast := &types.ToolMCPConfig{URL: "http://fast.example.com/sse"}
+
+	release := make(chan struct{})
+	var dials atomic.Int32
+
+	clients := NewMCPClients(false)
+	clients.dial = func(_ context.Context, cfg *types.ToolMCPConfig) (mcpclient.MCPClient, error) {
+		dials.Add(1)
+		if cfg == slow {
+			<-release
+		}
+		return fakeMCPClient{}, nil
+	}
+
+	var wg sync.WaitGroup
+	conns := make([]*mcpConnection, 5)
+	for i := range conns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := clients.connect(context.Background(), slow)
+			assert.NoError(t, err)
+			conns[i] = conn
+		}()
+	}
+
+	// Another server connects while the slow one is still dialing
+	_, err := clients.connect(context.Background(), fast)
+	require.NoError(t, err)
+
+	// Callers stop waiting when their request ends, the dial carries on for the others
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = clients.connect(ctx, slow)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(2), dials.Load())
+	for _, conn := range conns {
+		assert.Same(t, conns[0], conn)
+	}
+}
+
+func Test_MCPClients_StdioDisabled(t *testing.T) {
+	clients := NewMCPClients(false)
+
+	err := clients.Discover(context.Background(), &types.Tool{
+		Name:     "local",
+		ToolType: types.ToolTypeMCP,
+		Config: types.ToolConfig{
+			MCP: &types.ToolMCPConfig{Command: "npx", Args: []string{"some-mcp-server"}},
+		},
+	})
+	require.ErrorIs(t, err, ErrMCPStdioDisabled)
+}
+
+func Test_RunMCPAction(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	apiClient := oai.NewMockClient(ctrl)
+
+	tool := weatherMCPTool(t)
+
+	clients := NewMCPClients(false)
+	t.Cleanup(clients.Close)
+
+	strategy := &ChainStrategy{
+		cfg:       &config.ServerConfig{},
+		apiClient: apiClient,
+		mcp:       clients,
+	}
+
+	gomock.InOrder(
+		// Arguments for the tool
+		apiClient.EXPECT().CreateChatCompletion(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+				assert.Contains(t, req.Messages[0].Content, `"city"`)
+				return openai.ChatCompletionResponse{
+					Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: `{"city": "Paris"}`}}},
+				}, nil
+			}),
+		// Interpretation of the result
+		apiClient.EXPECT().CreateChatCompletion(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+				assert.Contains(t, req.Messages[len(req.Messages)-2].Content, "Sunny in Paris")
+				return openai.ChatCompletionResponse{
+					Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: "It's sunny in Paris"}}},
+				}, nil
+			}),
+	)
+
+	resp, err := strategy.RunAction(context.Background(), "ses_1", "int_1", tool, []*types.ToolHistoryMessage{
+		{Role: openai.ChatMessageRoleUser, Content: "What's the weather in Paris?"},
+	}, "getForecast")
+	require.NoError(t, err)
+	assert.Equal(t, "It's sunny in Paris", resp.Message)
+	assert.Equal(t, "Sunny in Paris", resp.RawMessage)
+}
+
+func Test_MCPActionPolicy(t *testing.T) {
+	tool := weatherMCPTool(t)
+
+	clients := NewMCPClients(false)
+	t.Cleanup(clients.Close)
+	require.NoError(t, clients.Discover(context.Background(), tool))
+
+	assert.Equal(t, types.ToolActionPolicyConfirm, ActionPolicy(tool, "getForecast"))
+	assert.Equal(t, types.ToolActionPolicyAuto, ActionPolicy(tool, "weatherStations"))
+	assert.Equal(t, types.ToolActionPolicyConfirm, ActionPolicy(tool, "unknownTool"))
+
+	tool.Config.MCP.ActionPolicies = map[string]types.ToolActionPolicy{
+		"getForecast":     types.ToolActionPolicyAuto,
+		"weatherStations": types.ToolActionPolicyDeny,
+	}
+	assert.Equal(t, types.ToolActionPolicyAuto, ActionPolicy(tool, "getForecast"))
+	assert.Equal(t, types.ToolActionPolicyDeny, ActionPolicy(tool, "weatherStations"))
+}
+
+func Test_PrepareAndRunApprovedMCPAction(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	apiClient := oai.NewMockClient(ctrl)
+
+	tool := weatherMCPTool(t)
+
+	clients := NewMCPClients(false)
+	t.Cleanup(clients.Close)
+
+	strategy := &ChainStrategy{
+		cfg:       &config.ServerConfig{},
+		apiClient: apiClient,
+		mcp:       clients,
+	}
+
+	history := []*types.ToolHistoryMessage{
+		{Role: openai.ChatMessageRoleUser, Content: "What's the weather in Paris?"},
+	}
+
+	apiClient.EXPECT().CreateChatCompletion(gomock.Any(), gomock.Any()).Return(openai.ChatCompletionResponse{
+		Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: `{"city": "Paris"}`}}},
+	}, nil)
+
+	approval, err := strategy.PrepareMCPAction(context.Background(), "ses_1", "int_1", tool, history, "getForecast")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"city": "Paris"}, approval.Parameters)
+
+	// The approved arguments are used as they are, without asking the model again
+	apiClient.EXPECT().CreateChatCompletion(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+			assert.Contains(t, req.Messages[len(req.Messages)-2].Content, "Sunny in Paris")
+			return openai.ChatCompletionResponse{
+				Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: "It's sunny in Paris"}}},
+			}, nil
+		})
+
+	resp, err := strategy.RunApprovedMCPAction(context.Background(), "ses_1", "int_1", tool, "getForecast", approval)
+	require.NoError(t, err)
+	assert.Equal(t, "It's sunny in Paris", resp.Message)
+}
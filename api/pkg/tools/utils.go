@@ -45,6 +45,16 @@ func GetToolFromAction(tools []*types.Tool, action string) (*types.Tool, bool) {
 			if tool.Name == action {
 				return tool, true
 			}
+		case types.ToolTypeMCP:
+			if tool.Config.MCP == nil {
+				continue
+			}
+			if _, ok := findMCPTool(tool, action); ok {
+				return tool, true
+			}
+			if _, ok := findMCPResource(tool, action); ok {
+				return tool, true
+			}
 		}
 	}
 	return nil, false
@@ -118,6 +118,19 @@ func ValidateTool(tool *types.Tool, planner Planner, strict bool) error {
 			return system.NewHTTPError400(fmt.Sprintf("failed to validate and default tool, error: %s", err))
 		}
 
+	case types.ToolTypeMCP:
+		if tool.Config.MCP == nil {
+			return system.NewHTTPError400("MCP config is required for MCP tools")
+		}
+
+		if tool.Config.MCP.Command == "" && tool.Config.MCP.URL == "" {
+			return system.NewHTTPError400("command or URL is required for MCP tools")
+		}
+
+		if tool.Config.MCP.Command != "" && tool.Config.MCP.URL != "" {
+			return system.NewHTTPError400("only one of command or URL is allowed for MCP tools")
+		}
+
 	case types.ToolTypeZapier:
 		if tool.Config.Zapier == nil {
 			return system.NewHTTPError400("Zapier config is required for Zapier tools")
@@ -10,11 +10,12 @@ import (
 	"github.com/go-co-op/gocron/v2"
 	cronv3 "github.com/robfig/cron/v3"
 	"github.com/rs/zerolog/log"
-	openai "github.com/sashabaranov/go-openai"
 
 	"github.com/helixml/helix/api/pkg/config"
 	"github.com/helixml/helix/api/pkg/controller"
 	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/trigger/run"
+	"github.com/helixml/helix/api/pkg/trigger/sink"
 	"github.com/helixml/helix/api/pkg/types"
 )
 
@@ -22,6 +23,7 @@ type Cron struct {
 	cfg        *config.ServerConfig
 	store      store.Store
 	controller *controller.Controller
+	runner     *run.Runner
 	cron       gocron.Scheduler
 }
 
@@ -31,10 +33,16 @@ func New(cfg *config.ServerConfig, store store.Store, controller *controller.Con
 		return nil, fmt.Errorf("failed to create scheduler: %w", err)
 	}
 
+	sinks, err := sink.New(cfg, controller.Options.Notifier, controller.Options.Authenticator, controller.Options.Filestore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sinks: %w", err)
+	}
+
 	return &Cron{
 		cfg:        cfg,
 		store:      store,
 		controller: controller,
+		runner:     run.New(store, controller, sinks),
 		cron:       s,
 	}, nil
 }
@@ -165,6 +173,8 @@ func (c *Cron) createOrDeleteCronApps(ctx context.Context, apps []*types.App, jo
 			continue
 		}
 
+		c.schedulePrewarm(app, nextRun)
+
 		job, ok := jobsMap[app.ID]
 		if !ok {
 
@@ -219,6 +229,28 @@ func (c *Cron) createOrDeleteCronApps(ctx context.Context, apps []*types.App, jo
 	return nil
 }
 
+// schedulePrewarm asks the scheduler to load the app's model ahead of the next run so that
+// the cron job doesn't wait for a cold start
+func (c *Cron) schedulePrewarm(app *types.App, nextRun time.Time) {
+	if c.controller == nil || c.controller.Options.Scheduler == nil {
+		return
+	}
+
+	modelName, ok := getAppHelixModel(app)
+	if !ok {
+		return
+	}
+
+	err := c.controller.Options.Scheduler.SchedulePrewarm(app.ID, modelName, nextRun)
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Str("app_id", app.ID).
+			Str("model", modelName).
+			Msg("unable to schedule prewarm for cron app")
+	}
+}
+
 func (c *Cron) getCronAppTask(ctx context.Context, appID string) gocron.Task {
 	return gocron.NewTask(func() {
 		log.Info().
@@ -242,39 +274,18 @@ func (c *Cron) getCronAppTask(ctx context.Context, appID string) gocron.Task {
 			return
 		}
 
-		messages := []openai.ChatCompletionMessage{
-			{
-				Role:    openai.ChatMessageRoleUser,
-				Content: trigger.Input,
-			},
-		}
-
-		resp, _, err := c.controller.ChatCompletion(ctx, &types.User{
-			ID: app.Owner,
-		}, openai.ChatCompletionRequest{
-			Stream:   false,
-			Messages: messages,
-		},
-			&controller.ChatCompletionOptions{
-				AppID: app.ID,
-			})
-		if err != nil {
-			log.Error().
-				Err(err).
-				Str("app_id", app.ID).
-				Msg("failed to run app cron job")
-			return
-		}
-
-		var respContent string
-		if len(resp.Choices) > 0 {
-			respContent = resp.Choices[0].Message.Content
-		}
+		c.runCronApp(ctx, app, trigger)
+	})
+}
 
-		log.Info().
-			Str("app_id", app.ID).
-			Str("resp_content", respContent).
-			Msg("app cron job completed")
+// runCronApp runs the app with the trigger's input and records the run
+func (c *Cron) runCronApp(ctx context.Context, app *types.App, trigger *types.CronTrigger) *types.TriggerRun {
+	return c.runner.Run(ctx, app, &run.Request{
+		Type:        types.TriggerTypeCron,
+		SessionName: fmt.Sprintf("Scheduled run %s", time.Now().Format(time.DateTime)),
+		Input:       trigger.Input,
+		Sinks:       trigger.Sinks,
+		Workflow:    trigger.Workflow,
 	})
 }
 
@@ -323,6 +334,21 @@ func getAppSchedule(app *types.App) (*types.CronTrigger, bool) {
 	return nil, false
 }
 
+// getAppHelixModel returns the model of the first assistant if it runs on Helix runners
+func getAppHelixModel(app *types.App) (string, bool) {
+	for _, assistant := range app.Config.Helix.Assistants {
+		if assistant.Model == "" {
+			continue
+		}
+		if assistant.Provider != "" && assistant.Provider != types.ProviderHelix {
+			return "", false
+		}
+		return assistant.Model, true
+	}
+
+	return "", false
+}
+
 func getCronJobSchedule(job gocron.Job) string {
 	tags := job.Tags()
 
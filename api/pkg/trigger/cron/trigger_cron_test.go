@@ -0,0 +1,144 @@
+package cron
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/helixml/helix/api/pkg/config"
+	"github.com/helixml/helix/api/pkg/controller"
+	"github.com/helixml/helix/api/pkg/extract"
+	"github.com/helixml/helix/api/pkg/filestore"
+	"github.com/helixml/helix/api/pkg/janitor"
+	oai "github.com/helixml/helix/api/pkg/openai"
+	"github.com/helixml/helix/api/pkg/openai/manager"
+	"github.com/helixml/helix/api/pkg/pubsub"
+	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+func newTestCron(t *testing.T) (*Cron, *store.MockStore, *oai.MockClient) {
+	ctrl := gomock.NewController(t)
+	st := store.NewMockStore(ctrl)
+	client := oai.NewMockClient(ctrl)
+
+	providerManager := manager.NewMockProviderManager(ctrl)
+	providerManager.EXPECT().GetClient(gomock.Any(), gomock.Any()).Return(client, nil).AnyTimes()
+
+	ps, err := pubsub.New(t.TempDir())
+	require.NoError(t, err)
+
+	cfg := &config.ServerConfig{}
+	cfg.Inference.Provider = types.ProviderTogetherAI
+
+	c, err := controller.NewController(context.Background(), controller.Options{
+		Config:          cfg,
+		Store:           st,
+		Janitor:         janitor.NewJanitor(config.Janitor{}),
+		ProviderManager: providerManager,
+		Filestore:       filestore.NewMockFileStore(ctrl),
+		Extractor:       extract.NewMockExtractor(ctrl),
+		PubSub:          ps,
+	})
+	require.NoError(t, err)
+
+	cron, err := New(cfg, st, c)
+	require.NoError(t, err)
+
+	return cron, st, client
+}
+
+func cronTestApp(sinkURL string) *types.App {
+	app := &types.App{ID: "app_1", Owner: "user_1", Revision: 4}
+	app.Config.Helix.Name = "daily-digest"
+	app.Config.Helix.Assistants = []types.AssistantConfig{{SystemPrompt: "Summarise the news"}}
+	app.Config.Helix.Triggers = []types.Trigger{
+		{
+			Cron: &types.CronTrigger{
+				Schedule: "0 9 * * *",
+				Input:    "What happened yesterday?",
+				Sinks:    []types.TriggerSink{{Webhook: &types.TriggerSinkWebhook{URL: sinkURL}}},
+			},
+		},
+	}
+	return app
+}
+
+func TestRunCronApp_RecordsRun(t *testing.T) {
+	cron, st, client := newTestCron(t)
+
+	app := cronTestApp("http://127.0.0.1:1/unreachable")
+
+	var sessions []types.Session
+	st.EXPECT().GetAppWithTools(gomock.Any(), "app_1").Return(app, nil).AnyTimes()
+	st.EXPECT().ListSecrets(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+	st.EXPECT().CreateTriggerRun(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, run *types.TriggerRun) (*types.TriggerRun, error) {
+		assert.Equal(t, types.TriggerRunStatusRunning, run.Status)
+		return run, nil
+	})
+	st.EXPECT().UpdateSession(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, session types.Session) (*types.Session, error) {
+		sessions = append(sessions, session)
+		return &session, nil
+	}).Times(2)
+	st.EXPECT().UpdateTriggerRun(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, run *types.TriggerRun) (*types.TriggerRun, error) {
+		return run, nil
+	})
+
+	client.EXPECT().CreateChatCompletion(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+			vals, ok := oai.GetContextValues(ctx)
+			require.True(t, ok)
+			assert.Equal(t, "user_1", vals.OwnerID)
+			assert.NotEmpty(t, vals.SessionID)
+			assert.Equal(t, "What happened yesterday?", req.Messages[len(req.Messages)-1].Content)
+			return openai.ChatCompletionResponse{
+				Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: "Not much"}}},
+			}, nil
+		})
+
+	run := cron.runCronApp(context.Background(), app, app.Config.Helix.Triggers[0].Cron)
+	require.NotNil(t, run)
+
+	assert.Equal(t, types.TriggerRunStatusSuccess, run.Status)
+	assert.Equal(t, "Not much", run.Output)
+	assert.False(t, run.Finished.IsZero())
+
+	require.Len(t, sessions, 2)
+	assert.Equal(t, run.SessionID, sessions[1].ID)
+	assert.Equal(t, "app_1", sessions[1].ParentApp)
+	assert.Equal(t, "Not much", sessions[1].Interactions[1].Message)
+
+	// Sinks that fail are recorded with the run
+	require.Len(t, run.Deliveries, 1)
+	assert.Equal(t, "webhook", run.Deliveries[0].Sink)
+	assert.NotEmpty(t, run.Deliveries[0].Error)
+}
+
+func TestRunCronApp_RecordsFailure(t *testing.T) {
+	cron, st, client := newTestCron(t)
+
+	app := cronTestApp("http://127.0.0.1:1/unreachable")
+
+	st.EXPECT().GetAppWithTools(gomock.Any(), "app_1").Return(app, nil).AnyTimes()
+	st.EXPECT().ListSecrets(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+	st.EXPECT().CreateTriggerRun(gomock.Any(), gomock.Any()).Return(nil, errors.New("database is down"))
+	st.EXPECT().UpdateSession(gomock.Any(), gomock.Any()).Return(&types.Session{}, nil).Times(2)
+	st.EXPECT().UpdateTriggerRun(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, run *types.TriggerRun) (*types.TriggerRun, error) {
+		return run, nil
+	})
+
+	client.EXPECT().CreateChatCompletion(gomock.Any(), gomock.Any()).Return(openai.ChatCompletionResponse{}, errors.New("model is overloaded"))
+
+	run := cron.runCronApp(context.Background(), app, app.Config.Helix.Triggers[0].Cron)
+	require.NotNil(t, run)
+
+	assert.Equal(t, types.TriggerRunStatusFailed, run.Status)
+	assert.Contains(t, run.Error, "model is overloaded")
+	// Failed runs have nothing to deliver
+	assert.Empty(t, run.Deliveries)
+}
@@ -3,14 +3,19 @@ package discord
 import (
 	"context"
 	"fmt"
+	"regexp"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/helixml/helix/api/pkg/config"
 	"github.com/helixml/helix/api/pkg/controller"
+	"github.com/helixml/helix/api/pkg/data"
 	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/trigger/run"
 	"github.com/helixml/helix/api/pkg/types"
+	"github.com/helixml/helix/api/pkg/util/split"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/rs/zerolog/log"
@@ -22,30 +27,76 @@ const (
 	discordModel = string("meta-llama/Meta-Llama-3.1-8B-Instruct-Turbo")
 	// Users will be redirected to this URL to install the bot
 	installationDocsURL = "https://docs.helix.ml/helix/"
-	// history limit
-	historyLimit = 30
+	// maxMessageLength is the longest message that Discord accepts
+	maxMessageLength = 2000
+	// editInterval is how often the reply is edited while it's streamed, Discord rate limits edits
+	editInterval = time.Second
+	// resetCommand starts the conversation in a thread over
+	resetCommand = "!reset"
+	// resetPermissions allow resetting conversations, any of them will do
+	resetPermissions = discordgo.PermissionAdministrator | discordgo.PermissionManageThreads | discordgo.PermissionManageMessages
 )
 
+var mentionRegexp = regexp.MustCompile(`<@!?[0-9]+>`)
+
+// discordAPI is the part of the Discord API that the trigger uses
+type discordAPI interface {
+	Guild(guildID string, options ...discordgo.RequestOption) (*discordgo.Guild, error)
+	Channel(channelID string, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+	UserChannelPermissions(userID, channelID string, options ...discordgo.RequestOption) (int64, error)
+	ChannelMessageSend(channelID, content string, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageSendReply(channelID, content string, reference *discordgo.MessageReference, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageEdit(channelID, messageID, content string, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	MessageThreadStartComplex(channelID, messageID string, data *discordgo.ThreadStart, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+}
+
+// stateSession looks channels up in the state before asking the API
+type stateSession struct {
+	*discordgo.Session
+}
+
+func (s stateSession) Channel(channelID string, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	if ch, err := s.State.Channel(channelID); err == nil {
+		return ch, nil
+	}
+	return s.Session.Channel(channelID, options...)
+}
+
+// route is an app's trigger for a guild
+type route struct {
+	app     *types.App
+	trigger *types.DiscordTrigger
+}
+
+// Discord answers mentions of the bot in threads. Each thread is a session, so conversations show
+// up with the app's other sessions and keep their context across restarts.
 type Discord struct {
 	cfg        *config.ServerConfig
 	store      store.Store
 	controller *controller.Controller
+	runner     *run.Runner
 
+	api   discordAPI
 	botID string
+	// editInterval is how often replies are edited while they're streamed
+	editInterval time.Duration
 
 	threadsMu sync.Mutex
-	threads   map[string]bool
+	threads   map[string]route // Thread ID -> route the thread started with
 
 	appsMu sync.Mutex
-	apps   map[string]*types.App // Guild name -> App
+	apps   map[string][]route // Guild name -> routes
 }
 
 func New(cfg *config.ServerConfig, store store.Store, controller *controller.Controller) *Discord {
 	return &Discord{
-		cfg:        cfg,
-		store:      store,
-		controller: controller,
-		threads:    make(map[string]bool), // TODO: store this in the database
+		cfg:          cfg,
+		store:        store,
+		controller:   controller,
+		runner:       run.New(store, controller, nil),
+		editInterval: editInterval,
+		threads:      make(map[string]route),
+		apps:         make(map[string][]route),
 	}
 }
 
@@ -60,12 +111,19 @@ func (d *Discord) Start(ctx context.Context) error {
 		return fmt.Errorf("error obtaining account details: %w", err)
 	}
 	d.botID = u.ID
+	d.api = stateSession{Session: s}
 
 	logger := log.With().Str("trigger", "discord").Logger()
 
 	logger.Info().Msg("starting Discord bot")
 
-	s.AddHandler(d.messageHandler)
+	if err := d.syncAppsOnce(ctx); err != nil {
+		return err
+	}
+
+	s.AddHandler(func(_ *discordgo.Session, m *discordgo.MessageCreate) {
+		d.handleMessage(ctx, m.Message)
+	})
 
 	s.Identify.Intents = discordgo.MakeIntent(discordgo.IntentsAllWithoutPrivileged)
 
@@ -111,12 +169,15 @@ func (d *Discord) syncAppsOnce(ctx context.Context) error {
 		return fmt.Errorf("failed to list apps: %w", err)
 	}
 
-	discordApps := make(map[string]*types.App)
+	discordApps := make(map[string][]route)
 
 	for _, app := range apps {
 		for _, trigger := range app.Config.Helix.Triggers {
 			if trigger.Discord != nil {
-				discordApps[trigger.Discord.ServerName] = app
+				discordApps[trigger.Discord.ServerName] = append(discordApps[trigger.Discord.ServerName], route{
+					app:     app,
+					trigger: trigger.Discord,
+				})
 			}
 		}
 	}
@@ -128,204 +189,355 @@ func (d *Discord) syncAppsOnce(ctx context.Context) error {
 	return nil
 }
 
-func (d *Discord) isDirectedAtBot(s *discordgo.Session, m *discordgo.MessageCreate) bool {
-	if strings.Contains(m.Content, "<@"+s.State.User.ID+">") {
-		return true
-	}
+func (d *Discord) getRoutes(guildName string) []route {
+	d.appsMu.Lock()
+	defer d.appsMu.Unlock()
 
-	// TODO: remove
-	log.Info().Str("content", m.Content).
-		Msg("message is directed at bot")
+	return d.apps[guildName]
+}
 
+func (d *Discord) getThreadRoute(threadID string) (route, bool) {
 	d.threadsMu.Lock()
 	defer d.threadsMu.Unlock()
 
-	if _, ok := d.threads[m.ChannelID]; ok {
-		return true
+	r, ok := d.threads[threadID]
+	return r, ok
+}
+
+func (d *Discord) setThreadRoute(threadID string, r route) {
+	d.threadsMu.Lock()
+	defer d.threadsMu.Unlock()
+
+	d.threads[threadID] = r
+}
+
+// isDirectedAtBot reports whether the bot should answer, it answers mentions, replies to its
+// messages and everything in the threads it started
+func (d *Discord) isDirectedAtBot(m *discordgo.Message, channel *discordgo.Channel) bool {
+	for _, user := range m.Mentions {
+		if user.ID == d.botID {
+			return true
+		}
 	}
 
-	if m.MessageReference != nil && m.MessageReference.MessageID != "" {
+	if channel.IsThread() && channel.OwnerID == d.botID {
 		return true
 	}
 
-	return false
+	return m.ReferencedMessage != nil && m.ReferencedMessage.Author != nil && m.ReferencedMessage.Author.ID == d.botID
 }
 
-func (d *Discord) messageHandler(s *discordgo.Session, m *discordgo.MessageCreate) {
+func (d *Discord) handleMessage(ctx context.Context, m *discordgo.Message) {
 	logger := log.With().Str("trigger", "discord").Logger()
-	if m.Author.ID == s.State.User.ID {
+
+	// Direct messages aren't routed to apps
+	if m.Author == nil || m.Author.ID == d.botID || m.Author.Bot || m.GuildID == "" {
 		return
 	}
 
-	guild, err := s.Guild(m.GuildID)
+	channel, err := d.api.Channel(m.ChannelID)
+	if err != nil {
+		logger.Err(err).Str("channel_id", m.ChannelID).Msg("failed to get channel")
+		return
+	}
+
+	if !d.isDirectedAtBot(m, channel) {
+		return
+	}
+
+	guild, err := d.api.Guild(m.GuildID)
 	if err != nil || guild == nil {
-		log.
-			Err(err).
+		logger.Err(err).
 			Str("guild_id", m.GuildID).
 			Msg("failed to get guild")
 
-		_, err = s.ChannelMessageSendReply(m.ChannelID, "Failed to get guild, maybe I am lacking permissions?", m.Reference())
-		if err != nil {
-			log.Err(err).Msg("failed to send message")
-		}
+		d.reply(m, "Failed to get guild, maybe I am lacking permissions?")
 		return
 	}
 
-	d.appsMu.Lock()
-	app, ok := d.apps[guild.Name]
+	r, ok := d.findMessageRoute(guild, channel, m)
 	if !ok {
-		d.appsMu.Unlock()
-		log.Warn().Str("guild_name", guild.Name).Msg("no app configured for guild")
-
-		_, err = s.ChannelMessageSendReply(
-			m.ChannelID,
-			fmt.Sprintf("I am not yet configured to respond in this Discord channel. Please visit %s to install me.", installationDocsURL),
-			m.Reference(),
-		)
-		if err != nil {
-			log.Err(err).Msg("failed to send message")
-		}
+		logger.Warn().
+			Str("guild_name", guild.Name).
+			Str("channel_id", channel.ID).
+			Msg("no app configured for message")
+
+		d.reply(m, fmt.Sprintf("I am not yet configured to respond in this Discord channel. Please visit %s to install me.", installationDocsURL))
 		return
 	}
-	d.appsMu.Unlock()
+
+	// Threads keep their route, everyone who talks in them needs its roles too
+	if !hasRole(r.trigger, memberRoles(guild, m.Member)) {
+		d.reply(m, "Sorry, you don't have a role that I answer to in this thread.")
+		return
+	}
+
+	text := strings.TrimSpace(mentionRegexp.ReplaceAllString(m.Content, ""))
 
 	logger.Info().
-		Str("content", m.Content).
-		Str("app_id", app.ID).
-		Str("bot_id", d.botID).
-		Str("state_user_id", s.State.User.ID).
-		Str("message_author_id", m.Author.ID).
-		Str("guild_id", m.GuildID).
+		Str("app_id", r.app.ID).
 		Str("guild_name", guild.Name).
+		Str("channel_id", channel.ID).
+		Str("message_author_id", m.Author.ID).
 		Msg("received message")
 
-	if !d.isDirectedAtBot(s, m) {
-		logger.Info().Msg("message not directed at bot")
+	if text == resetCommand {
+		d.resetThread(ctx, r, channel, m)
 		return
 	}
 
-	if ch, err := s.State.Channel(m.ChannelID); err != nil || !ch.IsThread() {
-		// Creating a new thread
-		threadName, err := d.getThreadName(context.Background(), m)
+	// Threads are the conversations, a mention outside of one starts a thread
+	if channel.IsThread() {
+		placeholder, err := d.api.ChannelMessageSendReply(channel.ID, "Thinking...", m.Reference())
 		if err != nil {
-			log.Err(err).Msg("failed to get thread name")
+			logger.Err(err).Msg("failed to send message")
 			return
 		}
 
-		thread, err := s.MessageThreadStartComplex(m.ChannelID, m.ID, &discordgo.ThreadStart{
-			Name:                threadName,
-			AutoArchiveDuration: 60,
-			Invitable:           false,
-			RateLimitPerUser:    10,
-		})
-		if err != nil {
-			log.Err(err).Msg("failed to create thread")
-			return
-		}
+		d.answer(ctx, r, channel.ID, channel.Name, placeholder, text)
+		return
+	}
 
-		resp, err := d.startChat(context.Background(), app, s, []*discordgo.Message{}, m)
-		if err != nil {
-			log.Err(err).Msg("failed to get response from inference API")
-			_, _ = s.ChannelMessageSend(thread.ID, fmt.Sprintf("Failed to get response: %s", err))
-			return
+	threadName, err := d.getThreadName(ctx, m)
+	if err != nil {
+		logger.Err(err).Msg("failed to get thread name")
+		return
+	}
+
+	thread, err := d.api.MessageThreadStartComplex(channel.ID, m.ID, &discordgo.ThreadStart{
+		Name:                threadName,
+		AutoArchiveDuration: 60,
+		Invitable:           false,
+		RateLimitPerUser:    10,
+	})
+	if err != nil {
+		logger.Err(err).Msg("failed to create thread")
+		return
+	}
+
+	d.setThreadRoute(thread.ID, r)
+
+	placeholder, err := d.api.ChannelMessageSend(thread.ID, "Thinking...")
+	if err != nil {
+		logger.Err(err).Msg("failed to send message")
+		return
+	}
+
+	d.answer(ctx, r, thread.ID, threadName, placeholder, text)
+}
+
+// findMessageRoute returns the route of the thread, or the best route for the channel and the
+// author's roles. Threads keep the route they were started with, whoever is talking.
+func (d *Discord) findMessageRoute(guild *discordgo.Guild, channel *discordgo.Channel, m *discordgo.Message) (route, bool) {
+	if channel.IsThread() {
+		if r, ok := d.getThreadRoute(channel.ID); ok {
+			return r, true
 		}
 
-		_, err = s.ChannelMessageSend(thread.ID, resp)
+		parent, err := d.api.Channel(channel.ParentID)
 		if err != nil {
-			log.Err(err).Msg("failed to send message")
+			log.Err(err).Str("channel_id", channel.ParentID).Msg("failed to get parent channel")
+			return route{}, false
 		}
+		channel = parent
+	}
+
+	return findRoute(d.getRoutes(guild.Name), channel, memberRoles(guild, m.Member))
+}
 
-		m.ChannelID = thread.ID
+// answer runs the app and streams the answer into the placeholder message
+func (d *Discord) answer(ctx context.Context, r route, threadID, threadName string, placeholder *discordgo.Message, text string) {
+	// Validated when the app is saved, the first assistant answers if it's gone since
+	assistantID, _ := data.ResolveAssistant(r.app, r.trigger.Assistant)
+
+	reply := &streamingReply{
+		api:       d.api,
+		channelID: placeholder.ChannelID,
+		messageID: placeholder.ID,
+		interval:  d.editInterval,
+		lastEdit:  time.Now(),
+	}
 
-		d.threadsMu.Lock()
-		d.threads[thread.ID] = true
-		d.threadsMu.Unlock()
+	triggerRun := d.runner.Run(ctx, r.app, &run.Request{
+		Type:        types.TriggerTypeDiscord,
+		SessionName: threadName,
+		Input:       text,
+		ThreadID:    threadID,
+		AssistantID: assistantID,
+		OnUpdate:    reply.update,
+	})
+
+	if triggerRun.Status != types.TriggerRunStatusSuccess {
+		reply.finish(fmt.Sprintf("Failed to get response: %s", triggerRun.Error))
 		return
 	}
 
-	// Existing thread
+	reply.finish(triggerRun.Output)
+}
 
-	history, err := s.ChannelMessages(m.ChannelID, historyLimit, m.ID, "", "")
-	if err != nil {
-		// TODO: maybe reply directly?
-		log.Err(err).Msg("failed to get messages from thread")
+// resetThread starts the thread's conversation over, only moderators can do it
+func (d *Discord) resetThread(ctx context.Context, r route, channel *discordgo.Channel, m *discordgo.Message) {
+	if !channel.IsThread() {
+		d.reply(m, fmt.Sprintf("Conversations are kept in threads, use %s in the thread you want to start over.", resetCommand))
 		return
 	}
 
-	// Trim the last message as it's the current message
-	if len(history) > 0 {
-		history = history[:len(history)-1]
+	permissions, err := d.api.UserChannelPermissions(m.Author.ID, channel.ID)
+	if err != nil || permissions&resetPermissions == 0 {
+		d.reply(m, "Only moderators can reset the conversation.")
+		return
 	}
 
-	resp, err := d.startChat(context.Background(), app, s, history, m)
+	err = d.runner.ResetThread(ctx, r.app, types.TriggerTypeDiscord, channel.ID, fmt.Sprintf("Conversation reset by %s", m.Author.Username))
 	if err != nil {
-		log.Err(err).Msg("failed to get response from inference API")
+		log.Err(err).Str("app_id", r.app.ID).Str("thread_id", channel.ID).Msg("failed to reset thread")
+		d.reply(m, "Failed to reset the conversation, please try again.")
 		return
 	}
 
-	_, err = s.ChannelMessageSendReply(m.ChannelID, resp, m.Reference())
+	d.reply(m, "Conversation reset, I've forgotten everything before this message.")
+}
+
+func (d *Discord) reply(m *discordgo.Message, content string) {
+	_, err := d.api.ChannelMessageSendReply(m.ChannelID, content, m.Reference())
 	if err != nil {
 		log.Err(err).Msg("failed to send message")
 	}
+}
+
+// findRoute returns the most specific route for the channel and the author's roles. Routes for
+// the channel come before routes for the roles, which come before routes for the whole guild.
+func findRoute(routes []route, channel *discordgo.Channel, roles []*discordgo.Role) (route, bool) {
+	var (
+		best      route
+		bestScore = -1
+	)
 
+	for _, r := range routes {
+		score := 0
+
+		if len(r.trigger.Channels) > 0 {
+			if !slices.Contains(r.trigger.Channels, channel.ID) && !slices.Contains(r.trigger.Channels, channel.Name) {
+				continue
+			}
+			score += 2
+		}
+
+		if len(r.trigger.Roles) > 0 {
+			if !hasRole(r.trigger, roles) {
+				continue
+			}
+			score++
+		}
+
+		if score > bestScore {
+			best, bestScore = r, score
+		}
+	}
+
+	return best, bestScore >= 0
 }
 
-func (d *Discord) startChat(ctx context.Context, app *types.App, s *discordgo.Session, history []*discordgo.Message, m *discordgo.MessageCreate) (string, error) {
-	system := openai.ChatCompletionMessage{
-		Role:    openai.ChatMessageRoleSystem,
-		Content: `You are an AI assistant Discord bot. Be concise with the replies, keep them short but informative.`,
+// hasRole returns whether one of the roles can use the trigger, triggers without roles are for
+// everyone
+func hasRole(trigger *types.DiscordTrigger, roles []*discordgo.Role) bool {
+	if len(trigger.Roles) == 0 {
+		return true
 	}
 
-	messages := []openai.ChatCompletionMessage{
-		system,
+	return slices.ContainsFunc(roles, func(role *discordgo.Role) bool {
+		return slices.Contains(trigger.Roles, role.ID) || slices.Contains(trigger.Roles, role.Name)
+	})
+}
+
+// memberRoles returns the guild's roles that the member has
+func memberRoles(guild *discordgo.Guild, member *discordgo.Member) []*discordgo.Role {
+	if member == nil {
+		return nil
 	}
 
-	for _, msg := range history {
-		switch {
-		case msg.Author.ID == s.State.User.ID:
-			messages = append(messages, openai.ChatCompletionMessage{
-				Role:    openai.ChatMessageRoleAssistant,
-				Content: msg.Content,
-			})
-		default:
-			messages = append(messages, openai.ChatCompletionMessage{
-				Role:    openai.ChatMessageRoleUser,
-				Content: msg.Content,
-			})
+	var roles []*discordgo.Role
+	for _, role := range guild.Roles {
+		if slices.Contains(member.Roles, role.ID) {
+			roles = append(roles, role)
 		}
 	}
+	return roles
+}
+
+// streamingReply edits the reply as the answer streams in, at most once per interval
+type streamingReply struct {
+	api       discordAPI
+	channelID string
+	messageID string
+	interval  time.Duration
+	lastEdit  time.Time
+	content   string
+}
 
-	userMessage := openai.ChatCompletionMessage{
-		Role:    openai.ChatMessageRoleUser,
-		Content: m.Content,
+func (r *streamingReply) update(content string) {
+	if time.Since(r.lastEdit) < r.interval {
+		return
 	}
 
-	messages = append(messages, userMessage)
+	r.edit(replyMessages(content)[0])
+}
 
-	resp, _, err := d.controller.ChatCompletion(
-		ctx,
-		&types.User{},
-		openai.ChatCompletionRequest{
-			Stream:   false,
-			Model:    discordModel,
-			Messages: messages,
-		},
-		&controller.ChatCompletionOptions{
-			AppID: app.ID,
-		},
-	)
-	if err != nil {
-		return "", fmt.Errorf("failed to get response from inference API: %w", err)
+// finish edits the reply to the full answer, answers that don't fit in one message continue in
+// new messages
+func (r *streamingReply) finish(content string) {
+	chunks := replyMessages(content)
+
+	if chunks[0] != r.content {
+		r.edit(chunks[0])
 	}
 
-	if len(resp.Choices) == 0 {
-		return "", fmt.Errorf("no choices in response")
+	for _, chunk := range chunks[1:] {
+		if _, err := r.api.ChannelMessageSend(r.channelID, chunk); err != nil {
+			log.Err(err).Msg("failed to send message")
+		}
+	}
+}
+
+func (r *streamingReply) edit(content string) {
+	r.lastEdit = time.Now()
+	r.content = content
+
+	if _, err := r.api.ChannelMessageEdit(r.channelID, r.messageID, content); err != nil {
+		log.Err(err).Msg("failed to edit message")
+	}
+}
+
+// replyMessages splits the answer into messages that Discord accepts
+func replyMessages(content string) []string {
+	if content == "" {
+		return []string{"I don't have an answer for that."}
+	}
+	return split.Message(content, maxMessageLength)
+}
+
+// Validate checks the app's Discord triggers
+func Validate(cfg *types.AppHelixConfig) error {
+	app := &types.App{Config: types.AppConfig{Helix: *cfg}}
+
+	for _, trigger := range cfg.Triggers {
+		if trigger.Discord == nil {
+			continue
+		}
+
+		if trigger.Discord.ServerName == "" {
+			return fmt.Errorf("discord trigger needs a server_name")
+		}
+
+		if _, ok := data.ResolveAssistant(app, trigger.Discord.Assistant); !ok {
+			return fmt.Errorf("discord trigger refers to assistant '%s' that does not exist", trigger.Discord.Assistant)
+		}
 	}
 
-	return resp.Choices[0].Message.Content, nil
+	return nil
 }
 
-func (d *Discord) getThreadName(ctx context.Context, m *discordgo.MessageCreate) (string, error) {
+func (d *Discord) getThreadName(ctx context.Context, m *discordgo.Message) (string, error) {
 	req := openai.ChatCompletionRequest{
 		Model:     discordModel,
 		MaxTokens: int(50),
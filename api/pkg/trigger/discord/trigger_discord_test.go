@@ -0,0 +1,390 @@
+package discord
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/helixml/helix/api/pkg/config"
+	"github.com/helixml/helix/api/pkg/controller"
+	"github.com/helixml/helix/api/pkg/extract"
+	"github.com/helixml/helix/api/pkg/filestore"
+	"github.com/helixml/helix/api/pkg/janitor"
+	oai "github.com/helixml/helix/api/pkg/openai"
+	"github.com/helixml/helix/api/pkg/openai/manager"
+	"github.com/helixml/helix/api/pkg/pubsub"
+	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+type sentMessage struct {
+	channelID string
+	content   string
+}
+
+// testDiscordAPI serves the guild and its channels and records what the bot sends
+type testDiscordAPI struct {
+	guild       *discordgo.Guild
+	channels    map[string]*discordgo.Channel
+	permissions int64
+
+	threads []*discordgo.ThreadStart
+	sent    []sentMessage
+	edits   []string
+}
+
+func (a *testDiscordAPI) Guild(string, ...discordgo.RequestOption) (*discordgo.Guild, error) {
+	return a.guild, nil
+}
+
+func (a *testDiscordAPI) Channel(channelID string, _ ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	ch, ok := a.channels[channelID]
+	if !ok {
+		return nil, fmt.Errorf("unknown channel %s", channelID)
+	}
+	return ch, nil
+}
+
+func (a *testDiscordAPI) UserChannelPermissions(string, string, ...discordgo.RequestOption) (int64, error) {
+	return a.permissions, nil
+}
+
+func (a *testDiscordAPI) ChannelMessageSend(channelID, content string, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
+	a.sent = append(a.sent, sentMessage{channelID: channelID, content: content})
+	return &discordgo.Message{ID: fmt.Sprintf("msg_%d", len(a.sent)), ChannelID: channelID, Content: content}, nil
+}
+
+func (a *testDiscordAPI) ChannelMessageSendReply(channelID, content string, _ *discordgo.MessageReference, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	return a.ChannelMessageSend(channelID, content, options...)
+}
+
+func (a *testDiscordAPI) ChannelMessageEdit(channelID, messageID, content string, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
+	a.edits = append(a.edits, content)
+	return &discordgo.Message{ID: messageID, ChannelID: channelID, Content: content}, nil
+}
+
+func (a *testDiscordAPI) MessageThreadStartComplex(channelID, _ string, data *discordgo.ThreadStart, _ ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	a.threads = append(a.threads, data)
+	thread := &discordgo.Channel{
+		ID:       "thread_1",
+		Name:     data.Name,
+		Type:     discordgo.ChannelTypeGuildPublicThread,
+		ParentID: channelID,
+		OwnerID:  "1000",
+	}
+	a.channels[thread.ID] = thread
+	return thread, nil
+}
+
+func companyApp() *types.App {
+	app := &types.App{ID: "app_1", Owner: "user_1"}
+	app.Config.Helix.Name = "company-bot"
+	app.Config.Helix.Assistants = []types.AssistantConfig{
+		{Name: "general", SystemPrompt: "You answer general questions"},
+		{Name: "recruiters", SystemPrompt: "You help recruiters"},
+	}
+	app.Config.Helix.Triggers = []types.Trigger{
+		{Discord: &types.DiscordTrigger{ServerName: "Company"}},
+		{Discord: &types.DiscordTrigger{ServerName: "Company", Channels: []string{"hiring"}, Assistant: "recruiters"}},
+	}
+	return app
+}
+
+func newTestDiscord(t *testing.T, app *types.App) (*Discord, *testDiscordAPI, *store.MockStore, *oai.MockClient) {
+	ctrl := gomock.NewController(t)
+	st := store.NewMockStore(ctrl)
+	client := oai.NewMockClient(ctrl)
+
+	providerManager := manager.NewMockProviderManager(ctrl)
+	providerManager.EXPECT().GetClient(gomock.Any(), gomock.Any()).Return(client, nil).AnyTimes()
+
+	ps, err := pubsub.New(t.TempDir())
+	require.NoError(t, err)
+
+	cfg := &config.ServerConfig{}
+	cfg.Inference.Provider = types.ProviderTogetherAI
+
+	c, err := controller.NewController(context.Background(), controller.Options{
+		Config:          cfg,
+		Store:           st,
+		Janitor:         janitor.NewJanitor(config.Janitor{}),
+		ProviderManager: providerManager,
+		Filestore:       filestore.NewMockFileStore(ctrl),
+		Extractor:       extract.NewMockExtractor(ctrl),
+		PubSub:          ps,
+	})
+	require.NoError(t, err)
+
+	st.EXPECT().ListApps(gomock.Any(), gomock.Any()).Return([]*types.App{app}, nil).AnyTimes()
+	st.EXPECT().GetAppWithTools(gomock.Any(), "app_1").Return(app, nil).AnyTimes()
+	st.EXPECT().ListSecrets(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+
+	api := &testDiscordAPI{
+		guild: &discordgo.Guild{
+			ID:    "guild_1",
+			Name:  "Company",
+			Roles: []*discordgo.Role{{ID: "role_1", Name: "Recruiter"}},
+		},
+		channels: map[string]*discordgo.Channel{
+			"general": {ID: "general", Name: "general", Type: discordgo.ChannelTypeGuildText},
+			"hiring":  {ID: "hiring", Name: "hiring", Type: discordgo.ChannelTypeGuildText},
+		},
+	}
+
+	d := New(cfg, st, c)
+	d.api = api
+	d.botID = "1000"
+	d.editInterval = 0
+	require.NoError(t, d.syncAppsOnce(context.Background()))
+
+	return d, api, st, client
+}
+
+func mention(channelID, content string) *discordgo.Message {
+	return &discordgo.Message{
+		ID:        "msg_in",
+		ChannelID: channelID,
+		GuildID:   "guild_1",
+		Content:   "<@1000> " + content,
+		Author:    &discordgo.User{ID: "user_1", Username: "alice"},
+		Mentions:  []*discordgo.User{{ID: "1000"}},
+		Member:    &discordgo.Member{Roles: []string{}},
+	}
+}
+
+func expectThreadName(client *oai.MockClient) {
+	client.EXPECT().CreateChatCompletion(gomock.Any(), gomock.Any()).
+		Return(openai.ChatCompletionResponse{
+			Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: "Screening candidates"}}},
+		}, nil)
+}
+
+// expectStream streams the chunks as the answer, check sees the request
+func expectStream(t *testing.T, client *oai.MockClient, chunks []string, check func(req openai.ChatCompletionRequest)) {
+	client.EXPECT().CreateChatCompletionStream(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, req openai.ChatCompletionRequest) (*openai.ChatCompletionStream, error) {
+			check(req)
+
+			stream, writer, err := oai.NewOpenAIStreamingAdapter(openai.ChatCompletionRequest{})
+			require.NoError(t, err)
+
+			go func() {
+				defer writer.Close()
+
+				for _, chunk := range chunks {
+					bts, err := json.Marshal(openai.ChatCompletionStreamResponse{
+						Choices: []openai.ChatCompletionStreamChoice{{Delta: openai.ChatCompletionStreamChoiceDelta{Content: chunk}}},
+					})
+					require.NoError(t, err)
+
+					_, err = fmt.Fprintf(writer, "data: %s\n\n", string(bts))
+					require.NoError(t, err)
+				}
+
+				_, err = writer.Write([]byte("data: [DONE]\n\n"))
+				require.NoError(t, err)
+			}()
+
+			return stream, nil
+		})
+}
+
+func expectRun(st *store.MockStore, done func(run *types.TriggerRun)) {
+	st.EXPECT().ListTriggerRuns(gomock.Any(), &store.ListTriggerRunsQuery{
+		AppID:    "app_1",
+		Type:     types.TriggerTypeDiscord,
+		ThreadID: "thread_1",
+		Limit:    1,
+	}).Return(nil, nil)
+	st.EXPECT().CreateTriggerRun(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, run *types.TriggerRun) (*types.TriggerRun, error) {
+		return run, nil
+	})
+	st.EXPECT().UpdateSession(gomock.Any(), gomock.Any()).Return(&types.Session{}, nil).Times(2)
+	st.EXPECT().UpdateTriggerRun(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, run *types.TriggerRun) (*types.TriggerRun, error) {
+		done(run)
+		return run, nil
+	})
+}
+
+func TestDiscord_ChannelRouteStreamsAnswerInThread(t *testing.T) {
+	d, api, st, client := newTestDiscord(t, companyApp())
+
+	expectThreadName(client)
+	expectStream(t, client, []string{"Ask for ", "their portfolio"}, func(req openai.ChatCompletionRequest) {
+		assert.Equal(t, "You help recruiters", req.Messages[0].Content)
+		assert.Equal(t, "how do I screen designers?", req.Messages[len(req.Messages)-1].Content)
+	})
+
+	var finished *types.TriggerRun
+	expectRun(st, func(run *types.TriggerRun) { finished = run })
+
+	d.handleMessage(context.Background(), mention("hiring", "how do I screen designers?"))
+
+	require.Len(t, api.threads, 1)
+	assert.Equal(t, "Conversation with alice about Screening candidates", api.threads[0].Name)
+
+	require.Len(t, api.sent, 1)
+	assert.Equal(t, sentMessage{channelID: "thread_1", content: "Thinking..."}, api.sent[0])
+	assert.Equal(t, []string{"Ask for ", "Ask for their portfolio"}, api.edits)
+
+	require.NotNil(t, finished)
+	assert.Equal(t, types.TriggerTypeDiscord, finished.Type)
+	assert.Equal(t, "thread_1", finished.ThreadID)
+	assert.Equal(t, types.TriggerRunStatusSuccess, finished.Status)
+}
+
+func TestDiscord_IgnoresMessagesNotForTheBot(t *testing.T) {
+	d, api, _, _ := newTestDiscord(t, companyApp())
+
+	msg := mention("general", "lunch?")
+	msg.Mentions = nil
+	d.handleMessage(context.Background(), msg)
+
+	bot := mention("general", "beep")
+	bot.Author.Bot = true
+	d.handleMessage(context.Background(), bot)
+
+	assert.Empty(t, api.sent)
+	assert.Empty(t, api.threads)
+}
+
+func TestDiscord_NotConfiguredGuild(t *testing.T) {
+	d, api, _, _ := newTestDiscord(t, companyApp())
+	api.guild.Name = "Other"
+
+	d.handleMessage(context.Background(), mention("general", "hi"))
+
+	require.Len(t, api.sent, 1)
+	assert.Contains(t, api.sent[0].content, "not yet configured")
+}
+
+func TestDiscord_Reset(t *testing.T) {
+	d, api, st, _ := newTestDiscord(t, companyApp())
+	api.channels["thread_1"] = &discordgo.Channel{
+		ID:       "thread_1",
+		Type:     discordgo.ChannelTypeGuildPublicThread,
+		ParentID: "general",
+		OwnerID:  "1000",
+	}
+
+	reset := mention("thread_1", "!reset")
+	reset.Mentions = nil
+
+	// Members can't reset the conversation
+	d.handleMessage(context.Background(), reset)
+	require.Len(t, api.sent, 1)
+	assert.Equal(t, "Only moderators can reset the conversation.", api.sent[0].content)
+
+	api.permissions = discordgo.PermissionManageThreads
+	st.EXPECT().CreateTriggerRun(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, run *types.TriggerRun) (*types.TriggerRun, error) {
+		assert.Equal(t, "thread_1", run.ThreadID)
+		assert.Empty(t, run.SessionID)
+		assert.Equal(t, "Conversation reset by alice", run.Input)
+		return run, nil
+	})
+
+	d.handleMessage(context.Background(), reset)
+	require.Len(t, api.sent, 2)
+	assert.Contains(t, api.sent[1].content, "Conversation reset")
+}
+
+func TestDiscord_ThreadNeedsRouteRoles(t *testing.T) {
+	app := companyApp()
+	app.Config.Helix.Triggers[1].Discord.Roles = []string{"Recruiter"}
+
+	d, api, st, client := newTestDiscord(t, app)
+	api.channels["thread_1"] = &discordgo.Channel{
+		ID:       "thread_1",
+		Name:     "Screening candidates",
+		Type:     discordgo.ChannelTypeGuildPublicThread,
+		ParentID: "hiring",
+		OwnerID:  "1000",
+	}
+	d.setThreadRoute("thread_1", d.getRoutes("Company")[1])
+
+	// Members without the route's role can't continue a recruiter's thread
+	d.handleMessage(context.Background(), mention("thread_1", "what's the salary range?"))
+
+	require.Len(t, api.sent, 1)
+	assert.Contains(t, api.sent[0].content, "don't have a role")
+
+	expectStream(t, client, []string{"Ask for their portfolio"}, func(req openai.ChatCompletionRequest) {
+		assert.Equal(t, "You help recruiters", req.Messages[0].Content)
+	})
+	var finished *types.TriggerRun
+	expectRun(st, func(run *types.TriggerRun) { finished = run })
+
+	recruiter := mention("thread_1", "how do I screen designers?")
+	recruiter.Member.Roles = []string{"role_1"}
+	d.handleMessage(context.Background(), recruiter)
+
+	require.Len(t, api.sent, 2)
+	assert.Equal(t, sentMessage{channelID: "thread_1", content: "Thinking..."}, api.sent[1])
+	require.NotNil(t, finished)
+	assert.Equal(t, types.TriggerRunStatusSuccess, finished.Status)
+}
+
+func TestFindRoute(t *testing.T) {
+	app := companyApp()
+	app.Config.Helix.Triggers = append(app.Config.Helix.Triggers, types.Trigger{
+		Discord: &types.DiscordTrigger{ServerName: "Company", Roles: []string{"Recruiter"}, Assistant: "1"},
+	})
+
+	var routes []route
+	for _, trigger := range app.Config.Helix.Triggers {
+		routes = append(routes, route{app: app, trigger: trigger.Discord})
+	}
+
+	general := &discordgo.Channel{ID: "1", Name: "general"}
+	hiring := &discordgo.Channel{ID: "2", Name: "hiring"}
+	recruiter := []*discordgo.Role{{ID: "role_1", Name: "Recruiter"}}
+
+	r, ok := findRoute(routes, general, nil)
+	require.True(t, ok)
+	assert.Equal(t, routes[0], r)
+
+	r, ok = findRoute(routes, hiring, nil)
+	require.True(t, ok)
+	assert.Equal(t, routes[1], r)
+
+	r, ok = findRoute(routes, general, recruiter)
+	require.True(t, ok)
+	assert.Equal(t, routes[2], r)
+
+	// The channel is more specific than the role
+	r, ok = findRoute(routes, hiring, recruiter)
+	require.True(t, ok)
+	assert.Equal(t, routes[1], r)
+
+	_, ok = findRoute(routes[1:2], general, nil)
+	assert.False(t, ok)
+}
+
+func TestReplyMessages(t *testing.T) {
+	assert.Equal(t, []string{"I don't have an answer for that."}, replyMessages(""))
+
+	messages := replyMessages(strings.Repeat("c", 4500))
+	require.Len(t, messages, 3)
+	for _, message := range messages {
+		assert.LessOrEqual(t, len(message), maxMessageLength)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	app := companyApp()
+	require.NoError(t, Validate(&app.Config.Helix))
+
+	app.Config.Helix.Triggers[1].Discord.Assistant = "sales"
+	require.ErrorContains(t, Validate(&app.Config.Helix), "assistant 'sales'")
+
+	app.Config.Helix.Triggers[1].Discord = &types.DiscordTrigger{}
+	require.ErrorContains(t, Validate(&app.Config.Helix), "server_name")
+}
@@ -0,0 +1,242 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-message/mail"
+
+	"github.com/helixml/helix/api/pkg/system"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+// message is the part of an email that the app answers
+type message struct {
+	messageID  string
+	inReplyTo  []string
+	references []string
+	from       []*mail.Address
+	replyToTo  []*mail.Address
+	subject    string
+	body       string
+	// autoSubmitted is the Auto-Submitted header, set by auto-replies and mailing lists
+	autoSubmitted string
+	// envelope is set for mail received over SMTP
+	envelope *envelope
+}
+
+// envelope is what the SMTP client said about the message, rather than what its headers say
+type envelope struct {
+	// from is the envelope sender, empty for bounces
+	from string
+}
+
+func parseMessage(raw []byte) (*message, error) {
+	r, err := mail.CreateReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse message: %w", err)
+	}
+	defer r.Close()
+
+	msg := &message{
+		autoSubmitted: r.Header.Get("Auto-Submitted"),
+	}
+
+	// Malformed headers are left empty, the message can still be answered
+	msg.messageID, _ = r.Header.MessageID()
+	msg.inReplyTo, _ = r.Header.MsgIDList("In-Reply-To")
+	msg.references, _ = r.Header.MsgIDList("References")
+	msg.from, _ = r.Header.AddressList("From")
+	msg.replyToTo, _ = r.Header.AddressList("Reply-To")
+	msg.subject, _ = r.Header.Subject()
+
+	var html string
+	for {
+		part, err := r.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read message part: %w", err)
+		}
+
+		header, ok := part.Header.(*mail.InlineHeader)
+		if !ok {
+			continue
+		}
+
+		contentType, _, _ := header.ContentType()
+		if contentType != "text/plain" && contentType != "text/html" && contentType != "" {
+			continue
+		}
+
+		body, err := io.ReadAll(part.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read message body: %w", err)
+		}
+
+		if contentType == "text/html" {
+			if html == "" {
+				html = string(body)
+			}
+			continue
+		}
+		if msg.body == "" {
+			msg.body = string(body)
+		}
+	}
+
+	// HTML only mail is answered from its markup, models read it well enough
+	if msg.body == "" {
+		msg.body = html
+	}
+	msg.body = stripQuoted(msg.body)
+
+	return msg, nil
+}
+
+// threadID is the first message of the thread and who the replies go to. Replies reference the
+// first message first, so every message of the thread has the same ID. Message IDs aren't secret,
+// the replies' address keeps anyone else who replies to the thread from getting its history.
+func (m *message) threadID() string {
+	root := m.messageID
+	if len(m.references) > 0 {
+		root = m.references[0]
+	} else if len(m.inReplyTo) > 0 {
+		root = m.inReplyTo[0]
+	}
+	return root + " " + strings.ToLower(strings.Join(m.replyTo(), ","))
+}
+
+// replyTo is who the reply is sent to. Anyone can send mail to the SMTP listener, with any
+// headers, so mail received over SMTP is only answered at its envelope sender. Otherwise the
+// replies could be sent to whoever the headers name.
+func (m *message) replyTo() []string {
+	if m.envelope != nil {
+		if m.envelope.from == "" {
+			return nil
+		}
+		return []string{m.envelope.from}
+	}
+
+	addresses := m.replyToTo
+	if len(addresses) == 0 {
+		addresses = m.from
+	}
+
+	var to []string
+	for _, address := range addresses {
+		to = append(to, address.Address)
+	}
+	return to
+}
+
+// input is the prompt for the app, with the sender so that it can address them
+func (m *message) input() string {
+	var from []string
+	for _, address := range m.from {
+		from = append(from, address.String())
+	}
+
+	return fmt.Sprintf("From: %s\nSubject: %s\n\n%s", strings.Join(from, ", "), m.subject, m.body)
+}
+
+// shouldSkip reports whether the app shouldn't answer the message, so that it doesn't reply to
+// its own replies or to other auto-replies
+func shouldSkip(trigger *types.EmailTrigger, msg *message) (bool, string) {
+	if len(msg.replyTo()) == 0 {
+		return true, "no sender"
+	}
+
+	if msg.autoSubmitted != "" && !strings.EqualFold(msg.autoSubmitted, "no") {
+		return true, "auto-submitted"
+	}
+
+	for _, address := range msg.from {
+		if strings.EqualFold(address.Address, trigger.Address) {
+			return true, "sent by the inbox"
+		}
+	}
+
+	return false, ""
+}
+
+// buildReply creates the reply to the message. Replies are in the message's thread and are
+// marked as auto-replied, drafts aren't as someone sends them.
+func buildReply(from string, msg *message, body string, autoReplied bool) ([]byte, error) {
+	var header mail.Header
+	header.SetDate(time.Now())
+	header.SetAddressList("From", []*mail.Address{{Address: from}})
+
+	var to []*mail.Address
+	for _, address := range msg.replyTo() {
+		to = append(to, &mail.Address{Address: address})
+	}
+	header.SetAddressList("To", to)
+	header.SetSubject(replySubject(msg.subject))
+	header.SetMessageID(fmt.Sprintf("%s@%s", system.GenerateUUID(), domain(from)))
+
+	if msg.messageID != "" {
+		header.SetMsgIDList("In-Reply-To", []string{msg.messageID})
+		header.SetMsgIDList("References", append(append([]string{}, msg.references...), msg.messageID))
+	}
+	if autoReplied {
+		header.Set("Auto-Submitted", "auto-replied")
+	}
+	header.SetContentType("text/plain", map[string]string{"charset": "utf-8"})
+
+	var buf bytes.Buffer
+	w, err := mail.CreateSingleInlineWriter(&buf, header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create reply: %w", err)
+	}
+	if _, err := io.WriteString(w, body); err != nil {
+		return nil, fmt.Errorf("failed to write reply: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write reply: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func replySubject(subject string) string {
+	if strings.HasPrefix(strings.ToLower(subject), "re:") {
+		return subject
+	}
+	return "Re: " + subject
+}
+
+func domain(address string) string {
+	if idx := strings.LastIndex(address, "@"); idx >= 0 {
+		return address[idx+1:]
+	}
+	return "localhost"
+}
+
+// stripQuoted removes the quoted earlier messages from a reply, the session already has them
+func stripQuoted(body string) string {
+	lines := strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n")
+
+	for i, line := range lines {
+		if !strings.HasPrefix(line, ">") {
+			continue
+		}
+
+		end := i
+		// Drop the "On <date>, <someone> wrote:" line before the quote
+		for end > 0 && strings.TrimSpace(lines[end-1]) == "" {
+			end--
+		}
+		if end > 0 && strings.HasSuffix(strings.TrimSpace(lines[end-1]), "wrote:") {
+			end--
+		}
+
+		return strings.TrimSpace(strings.Join(lines[:end], "\n"))
+	}
+
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,653 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	_ "github.com/emersion/go-message/charset" // decodes mail that isn't UTF-8
+	"github.com/emersion/go-message/mail"
+	gosmtp "github.com/emersion/go-smtp"
+	"github.com/rs/zerolog/log"
+
+	"github.com/helixml/helix/api/pkg/config"
+	"github.com/helixml/helix/api/pkg/controller"
+	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/trigger/run"
+	"github.com/helixml/helix/api/pkg/trigger/sink"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+const (
+	defaultMailbox       = "INBOX"
+	defaultDraftsMailbox = "Drafts"
+
+	// maxMessageSize is the largest message that is accepted over SMTP
+	maxMessageSize = 10 << 20
+	// maxRecipients is how many of the inboxes a message sent over SMTP can be for
+	maxRecipients = 10
+	// maxConcurrentMessages is how many messages received over SMTP are answered at once. Senders
+	// are asked to try again later when there are more, they retry on their own.
+	maxConcurrentMessages = 16
+
+	// The deliveries that are recorded on the run for the reply
+	deliveryReply = "email_reply"
+	deliveryDraft = "email_draft"
+)
+
+// Email answers the mail sent to apps' support inboxes. Inboxes are polled over IMAP, or mail is
+// accepted over SMTP when a listen address is configured. Each thread is kept in a session and
+// the answer is sent as a reply with the notification SMTP settings, or saved as a draft.
+type Email struct {
+	cfg        *config.ServerConfig
+	store      store.Store
+	controller *controller.Controller
+	runner     *run.Runner
+
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+
+	// smtpSlots has a slot for each message received over SMTP that is being answered
+	smtpSlots chan struct{}
+
+	appsMu sync.Mutex
+	apps   map[string]*types.App // Inbox address -> App
+}
+
+func New(cfg *config.ServerConfig, store store.Store, controller *controller.Controller) (*Email, error) {
+	sinks, err := sink.New(cfg, controller.Options.Notifier, controller.Options.Authenticator, controller.Options.Filestore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sinks: %w", err)
+	}
+
+	return &Email{
+		cfg:        cfg,
+		store:      store,
+		controller: controller,
+		runner:     run.New(store, controller, sinks),
+		sendMail:   smtp.SendMail,
+		smtpSlots:  make(chan struct{}, maxConcurrentMessages),
+		apps:       make(map[string]*types.App),
+	}, nil
+}
+
+func (e *Email) Start(ctx context.Context) error {
+	logger := log.With().Str("trigger", "email").Logger()
+
+	// The SMTP server stops with the trigger, so that it can listen again when it's restarted
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if err := e.syncAppsOnce(ctx); err != nil {
+		return err
+	}
+
+	if e.cfg.Triggers.Email.SMTPListen != "" {
+		l, err := net.Listen("tcp", e.cfg.Triggers.Email.SMTPListen)
+		if err != nil {
+			return fmt.Errorf("failed to listen for SMTP on %s: %w", e.cfg.Triggers.Email.SMTPListen, err)
+		}
+
+		logger.Info().Str("address", l.Addr().String()).Msg("accepting mail over SMTP")
+
+		go func() {
+			if err := e.serveSMTP(ctx, l); err != nil {
+				logger.Error().Err(err).Msg("SMTP server exited with error")
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(e.cfg.Triggers.Email.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		e.pollAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := e.syncAppsOnce(ctx); err != nil {
+				return fmt.Errorf("failed syncing apps: %w", err)
+			}
+		}
+	}
+}
+
+func (e *Email) syncAppsOnce(ctx context.Context) error {
+	apps, err := e.store.ListApps(ctx, &store.ListAppsQuery{})
+	if err != nil {
+		return fmt.Errorf("failed to list apps: %w", err)
+	}
+
+	emailApps := bindAddresses(e.cfg.Triggers.Email.Domains, apps)
+
+	e.appsMu.Lock()
+	e.apps = emailApps
+	e.appsMu.Unlock()
+
+	return nil
+}
+
+// bindAddresses maps each inbox address to the app that claimed it first. Apps are checked when
+// they're saved, this keeps a later app from taking over an inbox if one slips through, and
+// leaves out addresses in domains the server no longer answers for.
+func bindAddresses(domains []string, apps []*types.App) map[string]*types.App {
+	sorted := make([]*types.App, len(apps))
+	copy(sorted, apps)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Created.Before(sorted[j].Created)
+	})
+
+	emailApps := make(map[string]*types.App)
+
+	for _, app := range sorted {
+		for _, trigger := range getEmailTriggers(app) {
+			address := strings.ToLower(trigger.Address)
+
+			logger := log.With().
+				Str("trigger", "email").
+				Str("address", address).
+				Str("app_id", app.ID).
+				Logger()
+
+			if !inDomains(domains, address) {
+				logger.Warn().Msg("email trigger address isn't in EMAIL_TRIGGER_DOMAINS, ignoring it")
+				continue
+			}
+			if bound, ok := emailApps[address]; ok {
+				logger.Warn().Str("bound_app_id", bound.ID).Msg("email address is already used by another app, ignoring this app's trigger")
+				continue
+			}
+			emailApps[address] = app
+		}
+	}
+
+	return emailApps
+}
+
+func inDomains(domains []string, address string) bool {
+	for _, d := range domains {
+		if strings.EqualFold(domain(address), strings.TrimSpace(d)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Email) getApp(address string) (*types.App, bool) {
+	e.appsMu.Lock()
+	defer e.appsMu.Unlock()
+
+	app, ok := e.apps[strings.ToLower(address)]
+	return app, ok
+}
+
+// getTrigger returns the app with the owner's secrets filled in and its trigger for the inbox
+func (e *Email) getTrigger(ctx context.Context, app *types.App, address string) (*types.App, *types.EmailTrigger, error) {
+	app, err := e.controller.EvaluateSecrets(ctx, &types.User{ID: app.Owner}, app)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to evaluate secrets: %w", err)
+	}
+
+	for _, trigger := range getEmailTriggers(app) {
+		if strings.EqualFold(trigger.Address, address) {
+			return app, trigger, nil
+		}
+	}
+
+	return nil, nil, fmt.Errorf("app %s has no email trigger for %s", app.ID, address)
+}
+
+func (e *Email) pollAll(ctx context.Context) {
+	e.appsMu.Lock()
+	apps := make(map[string]*types.App, len(e.apps))
+	for address, app := range e.apps {
+		apps[address] = app
+	}
+	e.appsMu.Unlock()
+
+	for address, app := range apps {
+		if ctx.Err() != nil {
+			return
+		}
+
+		evaluated, trigger, err := e.getTrigger(ctx, app, address)
+		if err != nil {
+			log.Error().Err(err).Str("app_id", app.ID).Msg("failed to load email trigger")
+			continue
+		}
+
+		if trigger.IMAP == nil {
+			continue
+		}
+
+		if err := e.poll(ctx, evaluated, trigger); err != nil {
+			log.Error().
+				Err(err).
+				Str("app_id", app.ID).
+				Str("address", trigger.Address).
+				Msg("failed to poll mailbox")
+		}
+	}
+}
+
+// poll answers the unread mail in the trigger's mailbox. Mail is marked as read once it has been
+// answered, failed runs are kept in the run history and aren't retried.
+func (e *Email) poll(ctx context.Context, app *types.App, trigger *types.EmailTrigger) error {
+	c, err := dialIMAP(trigger.IMAP)
+	if err != nil {
+		return err
+	}
+	defer c.Logout() //nolint:errcheck
+
+	mailbox := trigger.IMAP.Mailbox
+	if mailbox == "" {
+		mailbox = defaultMailbox
+	}
+
+	if _, err := c.Select(mailbox, false); err != nil {
+		return fmt.Errorf("failed to select %s: %w", mailbox, err)
+	}
+
+	criteria := imap.NewSearchCriteria()
+	criteria.WithoutFlags = []string{imap.SeenFlag}
+
+	uids, err := c.UidSearch(criteria)
+	if err != nil {
+		return fmt.Errorf("failed to search for unread mail: %w", err)
+	}
+	if len(uids) == 0 {
+		return nil
+	}
+
+	seqSet := new(imap.SeqSet)
+	seqSet.AddNum(uids...)
+
+	section := &imap.BodySectionName{Peek: true}
+	messages := make(chan *imap.Message, len(uids))
+
+	if err := c.UidFetch(seqSet, []imap.FetchItem{imap.FetchUid, section.FetchItem()}, messages); err != nil {
+		return fmt.Errorf("failed to fetch unread mail: %w", err)
+	}
+
+	for msg := range messages {
+		body := msg.GetBody(section)
+		if body == nil {
+			continue
+		}
+
+		raw, err := io.ReadAll(body)
+		if err != nil {
+			return fmt.Errorf("failed to read message %d: %w", msg.Uid, err)
+		}
+
+		_, err = e.handleMessage(ctx, app, trigger, raw, nil)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("app_id", app.ID).
+				Uint32("uid", msg.Uid).
+				Msg("failed to handle message")
+		}
+
+		seen := new(imap.SeqSet)
+		seen.AddNum(msg.Uid)
+
+		err = c.UidStore(seen, imap.FormatFlagsOp(imap.AddFlags, true), []interface{}{imap.SeenFlag}, nil)
+		if err != nil {
+			return fmt.Errorf("failed to mark message %d as read: %w", msg.Uid, err)
+		}
+	}
+
+	return nil
+}
+
+// handleMessage runs the app for the message and replies to it. Nothing is run for mail that the
+// app shouldn't answer, like auto-replies, and the returned run is nil. The envelope is set for
+// mail received over SMTP.
+func (e *Email) handleMessage(ctx context.Context, app *types.App, trigger *types.EmailTrigger, raw []byte, envelope *envelope) (*types.TriggerRun, error) {
+	msg, err := parseMessage(raw)
+	if err != nil {
+		return nil, err
+	}
+	msg.envelope = envelope
+
+	if skip, reason := shouldSkip(trigger, msg); skip {
+		log.Info().
+			Str("app_id", app.ID).
+			Str("message_id", msg.messageID).
+			Str("reason", reason).
+			Msg("not answering email")
+		return nil, nil
+	}
+
+	triggerRun := e.runner.Run(ctx, app, &run.Request{
+		Type:        types.TriggerTypeEmail,
+		SessionName: msg.subject,
+		Input:       msg.input(),
+		ThreadID:    msg.threadID(),
+	})
+	if triggerRun.Status != types.TriggerRunStatusSuccess {
+		return triggerRun, nil
+	}
+
+	delivery := e.deliverReply(app, trigger, msg, triggerRun.Output)
+	triggerRun.Deliveries = append(triggerRun.Deliveries, delivery)
+
+	_, err = e.store.UpdateTriggerRun(ctx, triggerRun)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("app_id", app.ID).
+			Str("run_id", triggerRun.ID).
+			Msg("failed to record email reply")
+	}
+
+	return triggerRun, nil
+}
+
+// deliverReply sends the reply, or saves it as a draft in draft-only mode
+func (e *Email) deliverReply(app *types.App, trigger *types.EmailTrigger, msg *message, output string) types.TriggerRunDelivery {
+	from := trigger.Address
+	if from == "" {
+		from = e.cfg.Notifications.Email.SenderAddress
+	}
+
+	reply, err := buildReply(from, msg, output, !trigger.DraftOnly)
+	if err != nil {
+		return types.TriggerRunDelivery{Sink: deliveryReply, Error: err.Error()}
+	}
+
+	if trigger.DraftOnly {
+		delivery := types.TriggerRunDelivery{Sink: deliveryDraft}
+		// Without a mailbox the draft is only kept in the session and the run history
+		if trigger.IMAP != nil {
+			if err := saveDraft(trigger.IMAP, reply); err != nil {
+				delivery.Error = err.Error()
+			}
+		}
+		return delivery
+	}
+
+	delivery := types.TriggerRunDelivery{Sink: deliveryReply}
+	if err := e.send(from, msg.replyTo(), reply); err != nil {
+		log.Error().
+			Err(err).
+			Str("app_id", app.ID).
+			Str("message_id", msg.messageID).
+			Msg("failed to send email reply")
+		delivery.Error = err.Error()
+	}
+
+	return delivery
+}
+
+// send sends the message with the SMTP settings of the email notifications
+func (e *Email) send(from string, to []string, msg []byte) error {
+	cfg := e.cfg.Notifications.Email.SMTP
+	if cfg.Host == "" {
+		return errors.New("SMTP is not configured, set EMAIL_SMTP_HOST")
+	}
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth(cfg.Identity, cfg.Username, cfg.Password, cfg.Host)
+	}
+
+	return e.sendMail(fmt.Sprintf("%s:%s", cfg.Host, cfg.Port), auth, from, to, msg)
+}
+
+func dialIMAP(cfg *types.EmailTriggerIMAP) (*client.Client, error) {
+	var (
+		c   *client.Client
+		err error
+	)
+	if cfg.Insecure {
+		c, err = client.Dial(cfg.Host)
+	} else {
+		c, err = client.DialTLS(cfg.Host, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", cfg.Host, err)
+	}
+
+	if err := c.Login(cfg.Username, cfg.Password); err != nil {
+		_ = c.Logout()
+		return nil, fmt.Errorf("failed to log in to %s: %w", cfg.Host, err)
+	}
+
+	return c, nil
+}
+
+// saveDraft appends the reply to the drafts mailbox, creating it when it doesn't exist
+func saveDraft(cfg *types.EmailTriggerIMAP, reply []byte) error {
+	c, err := dialIMAP(cfg)
+	if err != nil {
+		return err
+	}
+	defer c.Logout() //nolint:errcheck
+
+	mailbox := cfg.DraftsMailbox
+	if mailbox == "" {
+		mailbox = defaultDraftsMailbox
+	}
+
+	// Fails when the mailbox exists, appending tells us if it really is missing
+	_ = c.Create(mailbox)
+
+	err = c.Append(mailbox, []string{imap.DraftFlag, imap.SeenFlag}, time.Now(), bytes.NewReader(reply))
+	if err != nil {
+		return fmt.Errorf("failed to save draft to %s: %w", mailbox, err)
+	}
+
+	return nil
+}
+
+func getEmailTriggers(app *types.App) []*types.EmailTrigger {
+	var triggers []*types.EmailTrigger
+	for _, trigger := range app.Config.Helix.Triggers {
+		if trigger.Email != nil && trigger.Email.Address != "" {
+			triggers = append(triggers, trigger.Email)
+		}
+	}
+	return triggers
+}
+
+// Validate checks the app's email triggers. Addresses have to be in the server's domains, or
+// replies would be sent from addresses nobody here owns.
+func Validate(cfg *config.ServerConfig, triggers []types.Trigger) error {
+	addresses := make(map[string]bool)
+
+	for _, trigger := range triggers {
+		if trigger.Email == nil {
+			continue
+		}
+
+		address := strings.ToLower(trigger.Email.Address)
+		if _, err := mail.ParseAddress(address); err != nil || address == "" {
+			return fmt.Errorf("email trigger needs a valid address, got '%s'", trigger.Email.Address)
+		}
+		if !inDomains(cfg.Triggers.Email.Domains, address) {
+			if len(cfg.Triggers.Email.Domains) == 0 {
+				return fmt.Errorf("email triggers aren't enabled on this server, the operator sets the domains they can use with EMAIL_TRIGGER_DOMAINS")
+			}
+			return fmt.Errorf("email trigger address '%s' must be in one of these domains: %s", trigger.Email.Address, strings.Join(cfg.Triggers.Email.Domains, ", "))
+		}
+		if addresses[address] {
+			return fmt.Errorf("email trigger address '%s' is used more than once", trigger.Email.Address)
+		}
+		addresses[address] = true
+
+		if imapCfg := trigger.Email.IMAP; imapCfg != nil {
+			if imapCfg.Host == "" {
+				return fmt.Errorf("email trigger '%s' needs an IMAP host, e.g. imap.example.com:993", trigger.Email.Address)
+			}
+			if imapCfg.Username == "" {
+				return fmt.Errorf("email trigger '%s' needs an IMAP username", trigger.Email.Address)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ValidateAddresses rejects inbox addresses that another app already uses, only one app answers
+// each inbox
+func ValidateAddresses(app *types.App, apps []*types.App) error {
+	for _, trigger := range getEmailTriggers(app) {
+		for _, other := range apps {
+			if other.ID == app.ID {
+				continue
+			}
+			for _, bound := range getEmailTriggers(other) {
+				if strings.EqualFold(bound.Address, trigger.Address) {
+					return fmt.Errorf("email address %s is already used by another app", trigger.Address)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// serveSMTP accepts mail for the apps' inboxes until the context is done
+func (e *Email) serveSMTP(ctx context.Context, l net.Listener) error {
+	server := gosmtp.NewServer(&smtpBackend{ctx: ctx, email: e})
+	server.Domain = "localhost"
+	server.MaxMessageBytes = maxMessageSize
+	server.MaxRecipients = maxRecipients
+	server.ReadTimeout = time.Minute
+	server.WriteTimeout = time.Minute
+
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+
+	err := server.Serve(l)
+	if err != nil && ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+type smtpBackend struct {
+	ctx   context.Context
+	email *Email
+}
+
+func (b *smtpBackend) Login(_ *gosmtp.ConnectionState, _, _ string) (gosmtp.Session, error) {
+	return nil, gosmtp.ErrAuthUnsupported
+}
+
+func (b *smtpBackend) AnonymousLogin(_ *gosmtp.ConnectionState) (gosmtp.Session, error) {
+	return &smtpSession{backend: b}, nil
+}
+
+// smtpSession receives a message for one or more inboxes
+type smtpSession struct {
+	backend    *smtpBackend
+	from       string
+	recipients []string
+}
+
+func (s *smtpSession) Reset() {
+	s.from = ""
+	s.recipients = nil
+}
+
+func (s *smtpSession) Logout() error {
+	return nil
+}
+
+func (s *smtpSession) Mail(from string, _ gosmtp.MailOptions) error {
+	s.from = from
+	return nil
+}
+
+func (s *smtpSession) Rcpt(to string) error {
+	if _, ok := s.backend.email.getApp(to); !ok {
+		return &gosmtp.SMTPError{
+			Code:         550,
+			EnhancedCode: gosmtp.EnhancedCode{5, 1, 1},
+			Message:      "No such mailbox",
+		}
+	}
+	s.recipients = append(s.recipients, to)
+	return nil
+}
+
+// Data accepts the message and answers it in the background, the sender doesn't wait for the app.
+// The message is turned away for now when too many are being answered already.
+func (s *smtpSession) Data(r io.Reader) error {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	e := s.backend.email
+	if !e.acquireSMTPSlots(len(s.recipients)) {
+		return &gosmtp.SMTPError{
+			Code:         451,
+			EnhancedCode: gosmtp.EnhancedCode{4, 3, 2},
+			Message:      "Too busy, try again later",
+		}
+	}
+
+	ctx, from := s.backend.ctx, s.from
+	for _, address := range s.recipients {
+		go func(address string) {
+			defer func() { <-e.smtpSlots }()
+			e.handleSMTPMessage(ctx, address, raw, &envelope{from: from})
+		}(address)
+	}
+
+	return nil
+}
+
+// acquireSMTPSlots takes a slot for each of the message's recipients, or none when there aren't
+// enough free
+func (e *Email) acquireSMTPSlots(n int) bool {
+	for i := 0; i < n; i++ {
+		select {
+		case e.smtpSlots <- struct{}{}:
+		default:
+			for ; i > 0; i-- {
+				<-e.smtpSlots
+			}
+			return false
+		}
+	}
+	return true
+}
+
+func (e *Email) handleSMTPMessage(ctx context.Context, address string, raw []byte, envelope *envelope) {
+	app, ok := e.getApp(address)
+	if !ok {
+		return
+	}
+
+	app, trigger, err := e.getTrigger(ctx, app, address)
+	if err != nil {
+		log.Error().Err(err).Str("address", address).Msg("failed to load email trigger")
+		return
+	}
+
+	if _, err := e.handleMessage(ctx, app, trigger, raw, envelope); err != nil {
+		log.Error().
+			Err(err).
+			Str("app_id", app.ID).
+			Str("address", address).
+			Msg("failed to handle message")
+	}
+}
@@ -0,0 +1,530 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/smtp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/backend/memory"
+	imapserver "github.com/emersion/go-imap/server"
+	"github.com/emersion/go-message/mail"
+	gosmtp "github.com/emersion/go-smtp"
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/helixml/helix/api/pkg/config"
+	"github.com/helixml/helix/api/pkg/controller"
+	"github.com/helixml/helix/api/pkg/extract"
+	"github.com/helixml/helix/api/pkg/filestore"
+	"github.com/helixml/helix/api/pkg/janitor"
+	oai "github.com/helixml/helix/api/pkg/openai"
+	"github.com/helixml/helix/api/pkg/openai/manager"
+	"github.com/helixml/helix/api/pkg/pubsub"
+	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+const customerMail = "From: Jane Doe <jane@customer.com>\r\n" +
+	"To: support@example.com\r\n" +
+	"Subject: Can't log in\r\n" +
+	"Date: Mon, 02 Sep 2024 10:00:00 +0000\r\n" +
+	"Message-ID: <second@customer.com>\r\n" +
+	"In-Reply-To: <reply@example.com>\r\n" +
+	"References: <first@customer.com> <reply@example.com>\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"\r\n" +
+	"It still says my password is wrong.\r\n" +
+	"\r\n" +
+	"On Mon, 2 Sep 2024 at 09:00, Support <support@example.com> wrote:\r\n" +
+	"> Have you tried resetting your password?\r\n"
+
+// testIMAPServer is a local IMAP server with the memory backend, its user is username/password
+func testIMAPServer(t *testing.T) (string, *memory.Backend) {
+	be := memory.New()
+
+	s := imapserver.New(be)
+	s.AllowInsecureAuth = true
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go s.Serve(l) //nolint:errcheck
+	t.Cleanup(func() { _ = s.Close() })
+
+	return l.Addr().String(), be
+}
+
+func testMailbox(t *testing.T, be *memory.Backend, name string) *memory.Mailbox {
+	user, err := be.Login(nil, "username", "password")
+	require.NoError(t, err)
+
+	mbox, err := user.GetMailbox(name)
+	require.NoError(t, err)
+
+	return mbox.(*memory.Mailbox)
+}
+
+type receivedMail struct {
+	from string
+	to   []string
+	data []byte
+}
+
+// testSMTPServer is a local SMTP server that records the mail it receives
+type testSMTPServer struct {
+	mu       sync.Mutex
+	received []receivedMail
+}
+
+func (s *testSMTPServer) Login(_ *gosmtp.ConnectionState, _, _ string) (gosmtp.Session, error) {
+	return nil, gosmtp.ErrAuthUnsupported
+}
+
+func (s *testSMTPServer) AnonymousLogin(_ *gosmtp.ConnectionState) (gosmtp.Session, error) {
+	return &testSMTPSession{server: s}, nil
+}
+
+func (s *testSMTPServer) mail() []receivedMail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]receivedMail{}, s.received...)
+}
+
+type testSMTPSession struct {
+	server *testSMTPServer
+	msg    receivedMail
+}
+
+func (s *testSMTPSession) Reset()        { s.msg = receivedMail{} }
+func (s *testSMTPSession) Logout() error { return nil }
+
+func (s *testSMTPSession) Mail(from string, _ gosmtp.MailOptions) error {
+	s.msg.from = from
+	return nil
+}
+
+func (s *testSMTPSession) Rcpt(to string) error {
+	s.msg.to = append(s.msg.to, to)
+	return nil
+}
+
+func (s *testSMTPSession) Data(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.msg.data = data
+
+	s.server.mu.Lock()
+	s.server.received = append(s.server.received, s.msg)
+	s.server.mu.Unlock()
+	return nil
+}
+
+func startTestSMTPServer(t *testing.T) (string, string, *testSMTPServer) {
+	backend := &testSMTPServer{}
+
+	s := gosmtp.NewServer(backend)
+	s.Domain = "localhost"
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go s.Serve(l) //nolint:errcheck
+	t.Cleanup(func() { _ = s.Close() })
+
+	host, port, err := net.SplitHostPort(l.Addr().String())
+	require.NoError(t, err)
+
+	return host, port, backend
+}
+
+func newTestEmail(t *testing.T, app *types.App) (*Email, *store.MockStore, *oai.MockClient) {
+	ctrl := gomock.NewController(t)
+	st := store.NewMockStore(ctrl)
+	client := oai.NewMockClient(ctrl)
+
+	providerManager := manager.NewMockProviderManager(ctrl)
+	providerManager.EXPECT().GetClient(gomock.Any(), gomock.Any()).Return(client, nil).AnyTimes()
+
+	ps, err := pubsub.New(t.TempDir())
+	require.NoError(t, err)
+
+	cfg := &config.ServerConfig{}
+	cfg.Inference.Provider = types.ProviderTogetherAI
+	cfg.Notifications.Email.SenderAddress = "noreply@example.com"
+	cfg.Triggers.Email.Domains = []string{"example.com"}
+
+	c, err := controller.NewController(context.Background(), controller.Options{
+		Config:          cfg,
+		Store:           st,
+		Janitor:         janitor.NewJanitor(config.Janitor{}),
+		ProviderManager: providerManager,
+		Filestore:       filestore.NewMockFileStore(ctrl),
+		Extractor:       extract.NewMockExtractor(ctrl),
+		PubSub:          ps,
+	})
+	require.NoError(t, err)
+
+	st.EXPECT().ListApps(gomock.Any(), gomock.Any()).Return([]*types.App{app}, nil).AnyTimes()
+	st.EXPECT().GetAppWithTools(gomock.Any(), app.ID).Return(app, nil).AnyTimes()
+	st.EXPECT().ListSecrets(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+
+	e, err := New(cfg, st, c)
+	require.NoError(t, err)
+	require.NoError(t, e.syncAppsOnce(context.Background()))
+
+	return e, st, client
+}
+
+func emailTestApp(trigger *types.EmailTrigger) *types.App {
+	app := &types.App{ID: "app_1", Owner: "user_1"}
+	app.Config.Helix.Name = "support"
+	app.Config.Helix.Assistants = []types.AssistantConfig{{SystemPrompt: "You answer support questions"}}
+	app.Config.Helix.Triggers = []types.Trigger{{Email: trigger}}
+	return app
+}
+
+// expectThreadRun expects a run that continues the thread's earlier session, the returned
+// channel is closed once the reply has been recorded
+func expectThreadRun(t *testing.T, st *store.MockStore, client *oai.MockClient, answer string) (<-chan *types.TriggerRun, *[]types.Session) {
+	var sessions []types.Session
+	done := make(chan *types.TriggerRun, 1)
+
+	st.EXPECT().ListTriggerRuns(gomock.Any(), &store.ListTriggerRunsQuery{
+		AppID:    "app_1",
+		Type:     types.TriggerTypeEmail,
+		ThreadID: "first@customer.com jane@customer.com",
+		Limit:    1,
+	}).Return([]*types.TriggerRun{{ID: "trun_1", SessionID: "ses_1"}}, nil)
+	st.EXPECT().GetSession(gomock.Any(), "ses_1").Return(&types.Session{
+		ID:        "ses_1",
+		ParentApp: "app_1",
+		Owner:     "user_1",
+		Interactions: []*types.Interaction{
+			{Creator: types.CreatorTypeUser, State: types.InteractionStateComplete, Message: "I can't log in"},
+			{Creator: types.CreatorTypeAssistant, State: types.InteractionStateComplete, Message: "Have you tried resetting your password?"},
+		},
+	}, nil)
+	st.EXPECT().CreateTriggerRun(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, run *types.TriggerRun) (*types.TriggerRun, error) {
+		assert.Equal(t, "ses_1", run.SessionID)
+		assert.Equal(t, "first@customer.com jane@customer.com", run.ThreadID)
+		return run, nil
+	})
+	st.EXPECT().UpdateSession(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, session types.Session) (*types.Session, error) {
+		sessions = append(sessions, session)
+		return &session, nil
+	}).Times(2)
+	st.EXPECT().UpdateTriggerRun(gomock.Any(), gomock.Any()).Return(&types.TriggerRun{}, nil)
+	// The reply is recorded once it's been delivered
+	st.EXPECT().UpdateTriggerRun(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, run *types.TriggerRun) (*types.TriggerRun, error) {
+		done <- run
+		return run, nil
+	})
+
+	client.EXPECT().CreateChatCompletion(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+			// The system prompt, the conversation so far and the new message
+			require.Len(t, req.Messages, 4)
+			assert.Equal(t, "I can't log in", req.Messages[1].Content)
+			assert.Equal(t, openai.ChatMessageRoleAssistant, req.Messages[2].Role)
+			assert.Equal(t, "From: \"Jane Doe\" <jane@customer.com>\nSubject: Can't log in\n\nIt still says my password is wrong.", req.Messages[3].Content)
+			return openai.ChatCompletionResponse{
+				Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: answer}}},
+			}, nil
+		})
+
+	return done, &sessions
+}
+
+func TestPoll_RepliesInThread(t *testing.T) {
+	imapAddr, be := testIMAPServer(t)
+	smtpHost, smtpPort, smtpServer := startTestSMTPServer(t)
+
+	inbox := testMailbox(t, be, "INBOX")
+	require.NoError(t, inbox.CreateMessage(nil, time.Now(), bytes.NewBufferString(customerMail)))
+
+	app := emailTestApp(&types.EmailTrigger{
+		Address: "support@example.com",
+		IMAP: &types.EmailTriggerIMAP{
+			Host:     imapAddr,
+			Username: "username",
+			Password: "password",
+			Insecure: true,
+		},
+	})
+
+	e, st, client := newTestEmail(t, app)
+	e.cfg.Notifications.Email.SMTP.Host = smtpHost
+	e.cfg.Notifications.Email.SMTP.Port = smtpPort
+
+	done, sessions := expectThreadRun(t, st, client, "Let's reset it for you.")
+
+	require.NoError(t, e.poll(context.Background(), app, app.Config.Helix.Triggers[0].Email))
+
+	run := <-done
+	assert.Equal(t, types.TriggerRunStatusSuccess, run.Status)
+	require.Len(t, run.Deliveries, 1)
+	assert.Equal(t, types.TriggerRunDelivery{Sink: deliveryReply}, run.Deliveries[0])
+
+	// The session has the whole thread
+	require.Len(t, *sessions, 2)
+	assert.Len(t, (*sessions)[1].Interactions, 4)
+	assert.Equal(t, "Let's reset it for you.", (*sessions)[1].Interactions[3].Message)
+
+	received := smtpServer.mail()
+	require.Len(t, received, 1)
+	assert.Equal(t, "support@example.com", received[0].from)
+	assert.Equal(t, []string{"jane@customer.com"}, received[0].to)
+
+	reply, err := mail.CreateReader(bytes.NewReader(received[0].data))
+	require.NoError(t, err)
+
+	subject, _ := reply.Header.Subject()
+	assert.Equal(t, "Re: Can't log in", subject)
+	inReplyTo, _ := reply.Header.MsgIDList("In-Reply-To")
+	assert.Equal(t, []string{"second@customer.com"}, inReplyTo)
+	references, _ := reply.Header.MsgIDList("References")
+	assert.Equal(t, []string{"first@customer.com", "reply@example.com", "second@customer.com"}, references)
+	assert.Equal(t, "auto-replied", reply.Header.Get("Auto-Submitted"))
+
+	// The message has been read, polling again doesn't answer it twice
+	assert.Contains(t, inbox.Messages[1].Flags, imap.SeenFlag)
+	require.NoError(t, e.poll(context.Background(), app, app.Config.Helix.Triggers[0].Email))
+}
+
+func TestPoll_DraftOnly(t *testing.T) {
+	imapAddr, be := testIMAPServer(t)
+
+	inbox := testMailbox(t, be, "INBOX")
+	require.NoError(t, inbox.CreateMessage(nil, time.Now(), bytes.NewBufferString(customerMail)))
+
+	app := emailTestApp(&types.EmailTrigger{
+		Address:   "support@example.com",
+		DraftOnly: true,
+		IMAP: &types.EmailTriggerIMAP{
+			Host:     imapAddr,
+			Username: "username",
+			Password: "password",
+			Insecure: true,
+		},
+	})
+
+	e, st, client := newTestEmail(t, app)
+	e.sendMail = func(_ string, _ smtp.Auth, _ string, _ []string, _ []byte) error {
+		t.Error("drafts must not be sent")
+		return nil
+	}
+
+	done, _ := expectThreadRun(t, st, client, "Let's reset it for you.")
+
+	require.NoError(t, e.poll(context.Background(), app, app.Config.Helix.Triggers[0].Email))
+
+	run := <-done
+	require.Len(t, run.Deliveries, 1)
+	assert.Equal(t, types.TriggerRunDelivery{Sink: deliveryDraft}, run.Deliveries[0])
+
+	drafts := testMailbox(t, be, "Drafts")
+	require.Len(t, drafts.Messages, 1)
+	assert.Contains(t, drafts.Messages[0].Flags, imap.DraftFlag)
+
+	draft, err := mail.CreateReader(bytes.NewReader(drafts.Messages[0].Body))
+	require.NoError(t, err)
+	assert.Empty(t, draft.Header.Get("Auto-Submitted"))
+
+	part, err := draft.NextPart()
+	require.NoError(t, err)
+	body, err := io.ReadAll(part.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "Let's reset it for you.", string(body))
+}
+
+func TestSMTP_AcceptsMailForInboxes(t *testing.T) {
+	smtpHost, smtpPort, smtpServer := startTestSMTPServer(t)
+
+	app := emailTestApp(&types.EmailTrigger{Address: "support@example.com"})
+
+	e, st, client := newTestEmail(t, app)
+	e.cfg.Notifications.Email.SMTP.Host = smtpHost
+	e.cfg.Notifications.Email.SMTP.Port = smtpPort
+
+	done, _ := expectThreadRun(t, st, client, "Let's reset it for you.")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go e.serveSMTP(ctx, l) //nolint:errcheck
+
+	err = smtp.SendMail(l.Addr().String(), nil, "jane@customer.com", []string{"nobody@example.com"}, []byte(customerMail))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "No such mailbox")
+
+	// The headers say to reply to someone else, the reply goes to the envelope sender
+	spoofed := strings.Replace(customerMail, "To:", "Reply-To: victim@elsewhere.com\r\nTo:", 1)
+	err = smtp.SendMail(l.Addr().String(), nil, "jane@customer.com", []string{"Support@example.com"}, []byte(spoofed))
+	require.NoError(t, err)
+
+	select {
+	case run := <-done:
+		assert.Equal(t, []types.TriggerRunDelivery{{Sink: deliveryReply}}, []types.TriggerRunDelivery(run.Deliveries))
+	case <-time.After(10 * time.Second):
+		t.Fatal("the mail wasn't answered")
+	}
+
+	require.Len(t, smtpServer.mail(), 1)
+	assert.Equal(t, []string{"jane@customer.com"}, smtpServer.mail()[0].to)
+}
+
+func TestSMTP_TooBusy(t *testing.T) {
+	app := emailTestApp(&types.EmailTrigger{Address: "support@example.com"})
+	e, _, _ := newTestEmail(t, app)
+
+	// Every slot is taken by messages that are still being answered
+	for i := 0; i < maxConcurrentMessages; i++ {
+		e.smtpSlots <- struct{}{}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go e.serveSMTP(ctx, l) //nolint:errcheck
+
+	err = smtp.SendMail(l.Addr().String(), nil, "jane@customer.com", []string{"support@example.com"}, []byte(customerMail))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "try again later")
+
+	// A slot frees up
+	<-e.smtpSlots
+	assert.True(t, e.acquireSMTPSlots(1))
+	assert.False(t, e.acquireSMTPSlots(1))
+}
+
+func TestSMTP_BouncesAreNotAnswered(t *testing.T) {
+	app := emailTestApp(&types.EmailTrigger{Address: "support@example.com"})
+	e, _, _ := newTestEmail(t, app)
+
+	run, err := e.handleMessage(context.Background(), app, app.Config.Helix.Triggers[0].Email, []byte(customerMail), &envelope{})
+	require.NoError(t, err)
+	assert.Nil(t, run)
+}
+
+func TestBindAddresses(t *testing.T) {
+	app := func(id, address string, created time.Time) *types.App {
+		a := emailTestApp(&types.EmailTrigger{Address: address})
+		a.ID = id
+		a.Created = created
+		return a
+	}
+
+	first := app("app_1", "support@example.com", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	later := app("app_2", "Support@example.com", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))
+	elsewhere := app("app_3", "ceo@bank.com", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))
+
+	apps := bindAddresses([]string{"example.com"}, []*types.App{later, elsewhere, first})
+
+	assert.Len(t, apps, 1)
+	assert.Equal(t, "app_1", apps["support@example.com"].ID)
+
+	assert.ErrorContains(t, ValidateAddresses(later, []*types.App{first}), "already used by another app")
+	assert.NoError(t, ValidateAddresses(first, []*types.App{first}))
+}
+
+func TestHandleMessage_SkipsAutoReplies(t *testing.T) {
+	app := emailTestApp(&types.EmailTrigger{Address: "support@example.com"})
+	e, _, _ := newTestEmail(t, app)
+
+	autoReply := strings.Replace(customerMail, "Content-Type:", "Auto-Submitted: auto-replied\r\nContent-Type:", 1)
+	run, err := e.handleMessage(context.Background(), app, app.Config.Helix.Triggers[0].Email, []byte(autoReply), nil)
+	require.NoError(t, err)
+	assert.Nil(t, run)
+
+	ownReply := strings.Replace(customerMail, "Jane Doe <jane@customer.com>", "support@example.com", 1)
+	run, err = e.handleMessage(context.Background(), app, app.Config.Helix.Triggers[0].Email, []byte(ownReply), nil)
+	require.NoError(t, err)
+	assert.Nil(t, run)
+}
+
+func TestThreadID(t *testing.T) {
+	jane := []*mail.Address{{Name: "Jane Doe", Address: "Jane@Customer.com"}}
+
+	assert.Equal(t, "first@customer.com jane@customer.com", (&message{
+		from:       jane,
+		messageID:  "third@customer.com",
+		inReplyTo:  []string{"second@customer.com"},
+		references: []string{"first@customer.com", "second@customer.com"},
+	}).threadID())
+	assert.Equal(t, "second@customer.com jane@customer.com", (&message{
+		from:      jane,
+		messageID: "third@customer.com",
+		inReplyTo: []string{"second@customer.com"},
+	}).threadID())
+	assert.Equal(t, "third@customer.com jane@customer.com", (&message{from: jane, messageID: "third@customer.com"}).threadID())
+
+	// Someone else replying to Jane's thread doesn't continue her conversation
+	assert.Equal(t, "first@customer.com mallory@elsewhere.com", (&message{
+		from:       []*mail.Address{{Address: "mallory@elsewhere.com"}},
+		messageID:  "fourth@elsewhere.com",
+		references: []string{"first@customer.com"},
+		envelope:   &envelope{from: "mallory@elsewhere.com"},
+	}).threadID())
+}
+
+func TestStripQuoted(t *testing.T) {
+	assert.Equal(t, "Thanks!", stripQuoted("Thanks!\r\n\r\nOn Monday, Support wrote:\r\n> Try again\r\n"))
+	assert.Equal(t, "Thanks!", stripQuoted("Thanks!\n> Try again"))
+	assert.Equal(t, "No quotes here", stripQuoted("No quotes here\n"))
+}
+
+func TestValidate(t *testing.T) {
+	cfg := &config.ServerConfig{}
+	cfg.Triggers.Email.Domains = []string{"example.com"}
+
+	valid := &types.EmailTrigger{
+		Address: "support@example.com",
+		IMAP:    &types.EmailTriggerIMAP{Host: "imap.example.com:993", Username: "support"},
+	}
+
+	tests := []struct {
+		name     string
+		triggers []types.Trigger
+		wantErr  string
+	}{
+		{name: "valid", triggers: []types.Trigger{{Email: valid}}},
+		{name: "smtp only", triggers: []types.Trigger{{Email: &types.EmailTrigger{Address: "help@example.com"}}}},
+		{name: "other domain", triggers: []types.Trigger{{Email: &types.EmailTrigger{Address: "ceo@bank.com"}}}, wantErr: "must be in one of these domains: example.com"},
+		{name: "no address", triggers: []types.Trigger{{Email: &types.EmailTrigger{}}}, wantErr: "valid address"},
+		{name: "duplicate address", triggers: []types.Trigger{{Email: valid}, {Email: &types.EmailTrigger{Address: "Support@example.com"}}}, wantErr: "more than once"},
+		{name: "no imap host", triggers: []types.Trigger{{Email: &types.EmailTrigger{Address: "a@example.com", IMAP: &types.EmailTriggerIMAP{Username: "a"}}}}, wantErr: "IMAP host"},
+		{name: "no imap username", triggers: []types.Trigger{{Email: &types.EmailTrigger{Address: "a@example.com", IMAP: &types.EmailTriggerIMAP{Host: "imap:993"}}}}, wantErr: "IMAP username"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(cfg, tt.triggers)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+
+	err := Validate(&config.ServerConfig{}, []types.Trigger{{Email: valid}})
+	assert.ErrorContains(t, err, "EMAIL_TRIGGER_DOMAINS")
+}
@@ -0,0 +1,199 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/helixml/helix/api/pkg/config"
+	"github.com/helixml/helix/api/pkg/controller"
+	"github.com/helixml/helix/api/pkg/pubsub"
+	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/trigger/run"
+	"github.com/helixml/helix/api/pkg/trigger/sink"
+	"github.com/helixml/helix/api/pkg/trigger/webhook"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+// Events runs apps when the events their triggers subscribe to are published, e.g. when their
+// knowledge has been indexed. The event is the input of the run.
+type Events struct {
+	cfg        *config.ServerConfig
+	store      store.Store
+	controller *controller.Controller
+	runner     *run.Runner
+}
+
+func New(cfg *config.ServerConfig, store store.Store, controller *controller.Controller) (*Events, error) {
+	sinks, err := sink.New(cfg, controller.Options.Notifier, controller.Options.Authenticator, controller.Options.Filestore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sinks: %w", err)
+	}
+
+	return &Events{
+		cfg:        cfg,
+		store:      store,
+		controller: controller,
+		runner:     run.New(store, controller, sinks),
+	}, nil
+}
+
+func (e *Events) Start(ctx context.Context) error {
+	ps := e.controller.Options.PubSub
+	if ps == nil {
+		return errors.New("pubsub is required for event triggers")
+	}
+
+	sub, err := ps.Subscribe(ctx, pubsub.EventsQueue, func(payload []byte) error {
+		var event types.Event
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return fmt.Errorf("failed to unmarshal event: %w", err)
+		}
+
+		// Runs take a while, the subscription mustn't wait for them
+		go e.handleEvent(ctx, &event)
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to events: %w", err)
+	}
+	defer sub.Unsubscribe() //nolint:errcheck
+
+	log.Info().Str("trigger", "event").Msg("listening for events")
+
+	<-ctx.Done()
+
+	return nil
+}
+
+// handleEvent runs the owner's apps that have a trigger for the event and returns the runs
+func (e *Events) handleEvent(ctx context.Context, event *types.Event) []*types.TriggerRun {
+	if event.Owner == "" {
+		return nil
+	}
+
+	apps, err := e.store.ListApps(ctx, &store.ListAppsQuery{Owner: event.Owner})
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("event_type", string(event.Type)).
+			Msg("failed to list apps for event")
+		return nil
+	}
+
+	var runs []*types.TriggerRun
+
+	for _, app := range apps {
+		for _, trigger := range app.Config.Helix.Triggers {
+			if trigger.Event == nil || !Matches(app, trigger.Event, event) {
+				continue
+			}
+
+			triggerRun, err := e.runApp(ctx, app, trigger.Event, event)
+			if err != nil {
+				log.Error().
+					Err(err).
+					Str("app_id", app.ID).
+					Str("event_type", string(event.Type)).
+					Msg("failed to run app for event")
+				continue
+			}
+
+			runs = append(runs, triggerRun)
+		}
+	}
+
+	return runs
+}
+
+func (e *Events) runApp(ctx context.Context, app *types.App, trigger *types.EventTrigger, event *types.Event) (*types.TriggerRun, error) {
+	app, err := e.controller.EvaluateSecrets(ctx, &types.User{ID: app.Owner}, app)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate secrets: %w", err)
+	}
+
+	input, err := RenderInput(trigger.Template, event)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.runner.Run(ctx, app, &run.Request{
+		Type:        types.TriggerTypeEvent,
+		SessionName: fmt.Sprintf("Event %s %s", event.Type, time.Now().Format(time.DateTime)),
+		Input:       input,
+		Sinks:       trigger.Sinks,
+		Workflow:    trigger.Workflow,
+	}), nil
+}
+
+// Matches reports whether the trigger runs the app for the event. Apps get the events of their
+// own knowledge and sessions, and of their owner's sessions that aren't part of an app.
+func Matches(app *types.App, trigger *types.EventTrigger, event *types.Event) bool {
+	if event.Owner != app.Owner {
+		return false
+	}
+
+	if event.AppID != "" && event.AppID != app.ID {
+		return false
+	}
+
+	if !slices.Contains(trigger.Events, event.Type) {
+		return false
+	}
+
+	if trigger.Knowledge != "" {
+		if event.Knowledge == nil {
+			return false
+		}
+		return event.Knowledge.Name == trigger.Knowledge || event.Knowledge.ID == trigger.Knowledge
+	}
+
+	return true
+}
+
+// RenderInput turns the event into the prompt with the trigger's template, or into indented JSON
+func RenderInput(tmpl string, event *types.Event) (string, error) {
+	payload, err := json.MarshalIndent(event, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	return webhook.RenderInput(tmpl, payload)
+}
+
+// Validate checks the app's event triggers, their email sinks can only send to the recipients
+func Validate(triggers []types.Trigger, recipients sink.Recipients) error {
+	for _, trigger := range triggers {
+		if trigger.Event == nil {
+			continue
+		}
+
+		if len(trigger.Event.Events) == 0 {
+			return fmt.Errorf("event trigger needs at least one event, one of %v", types.EventTypes)
+		}
+
+		for _, eventType := range trigger.Event.Events {
+			if !slices.Contains(types.EventTypes, eventType) {
+				return fmt.Errorf("unknown event '%s', must be one of %v", eventType, types.EventTypes)
+			}
+		}
+
+		if trigger.Event.Template != "" {
+			if _, err := webhook.ParseTemplate(trigger.Event.Template); err != nil {
+				return fmt.Errorf("invalid event trigger: %w", err)
+			}
+		}
+
+		if err := sink.Validate(trigger.Event.Sinks, recipients); err != nil {
+			return fmt.Errorf("invalid event trigger: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,205 @@
+package event
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/helixml/helix/api/pkg/config"
+	"github.com/helixml/helix/api/pkg/controller"
+	"github.com/helixml/helix/api/pkg/extract"
+	"github.com/helixml/helix/api/pkg/filestore"
+	"github.com/helixml/helix/api/pkg/janitor"
+	oai "github.com/helixml/helix/api/pkg/openai"
+	"github.com/helixml/helix/api/pkg/openai/manager"
+	"github.com/helixml/helix/api/pkg/pubsub"
+	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/trigger/sink"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+func newTestEvents(t *testing.T) (*Events, *store.MockStore, *oai.MockClient, pubsub.PubSub) {
+	ctrl := gomock.NewController(t)
+	st := store.NewMockStore(ctrl)
+	client := oai.NewMockClient(ctrl)
+
+	providerManager := manager.NewMockProviderManager(ctrl)
+	providerManager.EXPECT().GetClient(gomock.Any(), gomock.Any()).Return(client, nil).AnyTimes()
+
+	ps, err := pubsub.New(t.TempDir())
+	require.NoError(t, err)
+
+	cfg := &config.ServerConfig{}
+	cfg.Inference.Provider = types.ProviderTogetherAI
+
+	c, err := controller.NewController(context.Background(), controller.Options{
+		Config:          cfg,
+		Store:           st,
+		Janitor:         janitor.NewJanitor(config.Janitor{}),
+		ProviderManager: providerManager,
+		Filestore:       filestore.NewMockFileStore(ctrl),
+		Extractor:       extract.NewMockExtractor(ctrl),
+		PubSub:          ps,
+	})
+	require.NoError(t, err)
+
+	events, err := New(cfg, st, c)
+	require.NoError(t, err)
+
+	return events, st, client, ps
+}
+
+func docsApp() *types.App {
+	app := &types.App{ID: "app_1", Owner: "user_1"}
+	app.Config.Helix.Name = "docs-watcher"
+	app.Config.Helix.Assistants = []types.AssistantConfig{{SystemPrompt: "Summarise what changed"}}
+	app.Config.Helix.Triggers = []types.Trigger{
+		{
+			Event: &types.EventTrigger{
+				Events:    []types.EventType{types.EventTypeKnowledgeReady},
+				Knowledge: "docs",
+				Template:  "Knowledge {{ .knowledge.name }} was recrawled, {{ len .knowledge.urls }} pages",
+			},
+		},
+	}
+	return app
+}
+
+func knowledgeReady() *types.Event {
+	return types.NewKnowledgeEvent(types.EventTypeKnowledgeReady, &types.Knowledge{
+		ID:      "kno_1",
+		Name:    "docs",
+		Owner:   "user_1",
+		AppID:   "app_1",
+		State:   types.KnowledgeStateReady,
+		Version: "2024-09-02-10-00-00",
+		CrawledSources: &types.CrawledSources{
+			URLs: []*types.CrawledURL{{URL: "https://docs.helix.ml/a"}, {URL: "https://docs.helix.ml/b"}},
+		},
+	})
+}
+
+func TestEvents_RunsAppForPublishedEvent(t *testing.T) {
+	events, st, client, ps := newTestEvents(t)
+
+	app := docsApp()
+	// The owner's other app doesn't react to this app's knowledge
+	otherApp := docsApp()
+	otherApp.ID = "app_2"
+
+	done := make(chan *types.TriggerRun, 1)
+
+	st.EXPECT().ListApps(gomock.Any(), &store.ListAppsQuery{Owner: "user_1"}).Return([]*types.App{app, otherApp}, nil)
+	st.EXPECT().GetAppWithTools(gomock.Any(), "app_1").Return(app, nil).AnyTimes()
+	st.EXPECT().ListSecrets(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+	st.EXPECT().CreateTriggerRun(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, run *types.TriggerRun) (*types.TriggerRun, error) {
+		return run, nil
+	})
+	st.EXPECT().UpdateSession(gomock.Any(), gomock.Any()).Return(&types.Session{}, nil).Times(2)
+	st.EXPECT().UpdateTriggerRun(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, run *types.TriggerRun) (*types.TriggerRun, error) {
+		done <- run
+		return run, nil
+	})
+
+	client.EXPECT().CreateChatCompletion(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+			assert.Equal(t, "Knowledge docs was recrawled, 2 pages", req.Messages[len(req.Messages)-1].Content)
+			return openai.ChatCompletionResponse{
+				Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: "Two pages changed"}}},
+			}, nil
+		})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	started := make(chan struct{})
+	go func() {
+		close(started)
+		_ = events.Start(ctx)
+	}()
+	<-started
+
+	// Wait for the subscription before publishing, events aren't kept
+	require.Eventually(t, func() bool {
+		select {
+		case run := <-done:
+			assert.Equal(t, types.TriggerTypeEvent, run.Type)
+			assert.Equal(t, types.TriggerRunStatusSuccess, run.Status)
+			assert.Equal(t, "Two pages changed", run.Output)
+			return true
+		default:
+		}
+		_ = pubsub.PublishEvent(ctx, ps, knowledgeReady())
+		return false
+	}, 10*time.Second, 200*time.Millisecond)
+}
+
+func TestMatches(t *testing.T) {
+	app := docsApp()
+	trigger := app.Config.Helix.Triggers[0].Event
+
+	assert.True(t, Matches(app, trigger, knowledgeReady()))
+
+	otherKnowledge := knowledgeReady()
+	otherKnowledge.Knowledge.Name = "blog"
+	assert.False(t, Matches(app, trigger, otherKnowledge))
+
+	otherOwner := knowledgeReady()
+	otherOwner.Owner = "user_2"
+	assert.False(t, Matches(app, trigger, otherOwner))
+
+	otherApp := knowledgeReady()
+	otherApp.AppID = "app_2"
+	assert.False(t, Matches(app, trigger, otherApp))
+
+	failed := knowledgeReady()
+	failed.Type = types.EventTypeKnowledgeError
+	assert.False(t, Matches(app, trigger, failed))
+
+	// Fine-tunes aren't part of an app, the owner's apps get them
+	finetunes := &types.EventTrigger{Events: []types.EventType{types.EventTypeFinetuneCompleted}}
+	finetuned := types.NewSessionEvent(types.EventTypeFinetuneCompleted, &types.Session{ID: "ses_1", Owner: "user_1"}, "")
+	assert.True(t, Matches(app, finetunes, finetuned))
+}
+
+func TestRenderInput(t *testing.T) {
+	input, err := RenderInput("", knowledgeReady())
+	require.NoError(t, err)
+	assert.Contains(t, input, `"type": "knowledge.ready"`)
+	assert.Contains(t, input, `"https://docs.helix.ml/a"`)
+
+	input, err = RenderInput("{{ .session.name }} failed: {{ .session.error }}", types.NewSessionEvent(types.EventTypeSessionError, &types.Session{Name: "Weekly report"}, "model is overloaded"))
+	require.NoError(t, err)
+	assert.Equal(t, "Weekly report failed: model is overloaded", input)
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		trigger *types.EventTrigger
+		wantErr string
+	}{
+		{name: "valid", trigger: docsApp().Config.Helix.Triggers[0].Event},
+		{name: "no events", trigger: &types.EventTrigger{}, wantErr: "at least one event"},
+		{name: "unknown event", trigger: &types.EventTrigger{Events: []types.EventType{"knowledge.deleted"}}, wantErr: "unknown event"},
+		{name: "invalid template", trigger: &types.EventTrigger{Events: []types.EventType{types.EventTypeSessionError}, Template: "{{ .session"}, wantErr: "invalid template"},
+		{name: "invalid sink", trigger: &types.EventTrigger{Events: []types.EventType{types.EventTypeSessionError}, Sinks: []types.TriggerSink{{}}}, wantErr: "invalid event trigger"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate([]types.Trigger{{Event: tt.trigger}}, sink.Recipients{})
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,451 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	openai "github.com/sashabaranov/go-openai"
+
+	"github.com/helixml/helix/api/pkg/controller"
+	"github.com/helixml/helix/api/pkg/data"
+	oai "github.com/helixml/helix/api/pkg/openai"
+	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/system"
+	"github.com/helixml/helix/api/pkg/trigger/sink"
+	"github.com/helixml/helix/api/pkg/types"
+	"github.com/helixml/helix/api/pkg/workflow"
+)
+
+// Request is what a trigger asks the app to do
+type Request struct {
+	Type types.TriggerType
+	// SessionName is the name of the session that the conversation is kept in
+	SessionName string
+	Input       string
+	// Sinks get the output of the run when it succeeds
+	Sinks []types.TriggerSink
+	// ThreadID continues the session of the trigger's earlier runs with the same thread, the
+	// app sees the conversation so far
+	ThreadID string
+	// AssistantID is the ID or index of the assistant that answers, see ResolveAssistant. The
+	// first assistant answers by default.
+	AssistantID string
+	// OnUpdate streams the answer, it's called with the answer so far as it's generated
+	OnUpdate func(output string)
+	// Workflow is the name of the app's workflow that runs instead of the assistant, see
+	// workflow.ParseInputs for its inputs
+	Workflow string
+}
+
+// Runner runs apps for triggers and records the runs. The conversation is kept in a session, so
+// that it can be read like any other, and the output of successful runs is delivered to the
+// trigger's sinks.
+type Runner struct {
+	store      store.Store
+	controller *controller.Controller
+	sinks      *sink.Sinks
+	workflows  *workflow.Engine
+}
+
+func New(store store.Store, controller *controller.Controller, sinks *sink.Sinks) *Runner {
+	return &Runner{
+		store:      store,
+		controller: controller,
+		sinks:      sinks,
+		workflows:  workflow.New(store, controller),
+	}
+}
+
+// Run runs the app and returns the finished run
+func (r *Runner) Run(ctx context.Context, app *types.App, req *Request) *types.TriggerRun {
+	run, session := r.start(ctx, app, req)
+	return r.finish(ctx, app, req, run, session)
+}
+
+// Start records the run and runs the app in the background, the returned run is still running.
+// The run isn't cancelled with the context.
+func (r *Runner) Start(ctx context.Context, app *types.App, req *Request) *types.TriggerRun {
+	run, session := r.start(ctx, app, req)
+
+	started := *run
+
+	go r.finish(context.WithoutCancel(ctx), app, req, run, session)
+
+	return &started
+}
+
+func (r *Runner) start(ctx context.Context, app *types.App, req *Request) (*types.TriggerRun, *types.Session) {
+	session, ok := r.threadSession(ctx, app, req)
+	if ok {
+		session.Updated = time.Now()
+		session.Interactions = append(session.Interactions, newInteractions(req.Input)...)
+	} else {
+		session = newSession(app, req.SessionName, req.Input)
+	}
+
+	run := &types.TriggerRun{
+		ID:        system.GenerateTriggerRunID(),
+		AppID:     app.ID,
+		Type:      req.Type,
+		Status:    types.TriggerRunStatusRunning,
+		Started:   time.Now(),
+		SessionID: session.ID,
+		ThreadID:  req.ThreadID,
+		Input:     req.Input,
+		Sinks:     req.Sinks,
+	}
+
+	// The app still runs when the run can't be recorded, it's saved again when it's done
+	_, err := r.store.CreateTriggerRun(ctx, run)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("app_id", app.ID).
+			Str("trigger_type", string(req.Type)).
+			Msg("failed to create trigger run")
+	}
+
+	err = r.controller.WriteSession(ctx, session)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("app_id", app.ID).
+			Str("trigger_type", string(req.Type)).
+			Msg("failed to create trigger session")
+	}
+
+	return run, session
+}
+
+func (r *Runner) finish(ctx context.Context, app *types.App, req *Request, run *types.TriggerRun, session *types.Session) *types.TriggerRun {
+	userInteraction := session.Interactions[len(session.Interactions)-2]
+
+	ctx = oai.SetContextValues(ctx, &oai.ContextValues{
+		OwnerID:       app.Owner,
+		SessionID:     session.ID,
+		InteractionID: userInteraction.ID,
+	})
+
+	var (
+		output string
+		err    error
+	)
+	if req.Workflow != "" {
+		output, err = r.runWorkflow(ctx, app, req, run)
+	} else {
+		output, err = r.complete(ctx, app, req, session)
+	}
+
+	return r.record(ctx, app, run, session, output, err)
+}
+
+// record finishes the run with the app's output or error, the output is delivered to the run's
+// sinks when it succeeded
+func (r *Runner) record(ctx context.Context, app *types.App, run *types.TriggerRun, session *types.Session, output string, runErr error) *types.TriggerRun {
+	if runErr != nil {
+		log.Error().
+			Err(runErr).
+			Str("app_id", app.ID).
+			Str("trigger_type", string(run.Type)).
+			Msg("failed to run app for trigger")
+
+		run.Status = types.TriggerRunStatusFailed
+		run.Error = runErr.Error()
+	} else {
+		run.Status = types.TriggerRunStatusSuccess
+		run.Output = output
+	}
+
+	run.Finished = time.Now()
+
+	if session != nil && len(session.Interactions) > 0 {
+		assistantInteraction := session.Interactions[len(session.Interactions)-1]
+		assistantInteraction.Completed = run.Finished
+		assistantInteraction.Finished = true
+
+		if runErr != nil {
+			assistantInteraction.State = types.InteractionStateError
+			assistantInteraction.Error = runErr.Error()
+		} else {
+			assistantInteraction.State = types.InteractionStateComplete
+			assistantInteraction.Message = run.Output
+		}
+
+		err := r.controller.WriteSession(ctx, session)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("app_id", app.ID).
+				Str("trigger_type", string(run.Type)).
+				Msg("failed to update trigger session")
+		}
+	}
+
+	if run.Status == types.TriggerRunStatusSuccess && r.sinks != nil {
+		run.Deliveries = r.sinks.Deliver(ctx, app, run, run.Sinks)
+	}
+
+	_, err := r.store.UpdateTriggerRun(ctx, run)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("app_id", app.ID).
+			Str("run_id", run.ID).
+			Msg("failed to update trigger run")
+		return run
+	}
+
+	log.Info().
+		Str("app_id", app.ID).
+		Str("run_id", run.ID).
+		Str("trigger_type", string(run.Type)).
+		Str("status", string(run.Status)).
+		Msg("trigger run completed")
+
+	return run
+}
+
+// complete runs the app on the session's conversation before the run's interactions and the
+// run's input, streaming the answer to the request's OnUpdate when it's set
+func (r *Runner) complete(ctx context.Context, app *types.App, req *Request, session *types.Session) (string, error) {
+	user := &types.User{ID: app.Owner}
+	opts := &controller.ChatCompletionOptions{
+		AppID:       app.ID,
+		AssistantID: req.AssistantID,
+	}
+
+	messages, err := r.controller.SessionMessages(ctx, user, session, len(session.Interactions)-2, session.ModelName, opts)
+	if err != nil {
+		return "", err
+	}
+
+	chatReq := openai.ChatCompletionRequest{
+		Messages: append(messages, openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleUser,
+			Content: req.Input,
+		}),
+	}
+
+	if req.OnUpdate == nil {
+		resp, _, err := r.controller.ChatCompletion(ctx, user, chatReq, opts)
+		if err != nil {
+			return "", err
+		}
+		if len(resp.Choices) == 0 {
+			return "", errors.New("no data in the LLM response")
+		}
+		return resp.Choices[0].Message.Content, nil
+	}
+
+	stream, _, err := r.controller.ChatCompletionStream(ctx, user, chatReq, opts)
+	if err != nil {
+		return "", err
+	}
+	defer stream.Close()
+
+	var output strings.Builder
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return output.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if len(resp.Choices) > 0 && resp.Choices[0].Delta.Content != "" {
+			output.WriteString(resp.Choices[0].Delta.Content)
+			req.OnUpdate(output.String())
+		}
+	}
+}
+
+// runWorkflow runs the app's workflow with the input, the trigger run links to the workflow run
+func (r *Runner) runWorkflow(ctx context.Context, app *types.App, req *Request, run *types.TriggerRun) (string, error) {
+	workflowRun, err := r.workflows.Run(ctx, app, req.Workflow, workflow.ParseInputs(req.Input), run)
+	if err != nil {
+		return "", err
+	}
+
+	run.WorkflowRunID = workflowRun.ID
+
+	return workflowOutput(workflowRun)
+}
+
+func workflowOutput(workflowRun *types.WorkflowRun) (string, error) {
+	if workflowRun.Status != types.WorkflowRunStatusSuccess {
+		return "", errors.New(workflowRun.Error)
+	}
+	return workflowRun.Output, nil
+}
+
+// ResumeInterrupted resumes the workflow runs that were interrupted, e.g. because their server
+// stopped, every workflow.InterruptedAfter until the context is cancelled. The trigger runs that
+// were waiting for them are finished and delivered when they're done.
+func (r *Runner) ResumeInterrupted(ctx context.Context) {
+	ticker := time.NewTicker(workflow.InterruptedAfter)
+	defer ticker.Stop()
+
+	for {
+		if err := r.workflows.ResumeInterrupted(ctx, r.finishResumed); err != nil {
+			log.Error().Err(err).Msg("failed to resume workflow runs")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// finishResumed finishes the trigger run that was waiting for the workflow run before it was
+// interrupted, the way finish does for runs that weren't
+func (r *Runner) finishResumed(ctx context.Context, app *types.App, workflowRun *types.WorkflowRun) {
+	if workflowRun.TriggerRunID == "" {
+		return
+	}
+
+	run, err := r.store.GetTriggerRun(ctx, workflowRun.TriggerRunID)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("app_id", app.ID).
+			Str("run_id", workflowRun.TriggerRunID).
+			Msg("failed to get the trigger run of a resumed workflow run")
+		return
+	}
+	if run.Status != types.TriggerRunStatusRunning {
+		return
+	}
+
+	session, err := r.store.GetSession(ctx, run.SessionID)
+	if err != nil {
+		// The run is still finished and delivered
+		log.Error().
+			Err(err).
+			Str("app_id", app.ID).
+			Str("session_id", run.SessionID).
+			Msg("failed to get the session of a resumed trigger run")
+		session = nil
+	}
+
+	run.WorkflowRunID = workflowRun.ID
+	output, err := workflowOutput(workflowRun)
+
+	r.record(ctx, app, run, session, output, err)
+}
+
+// ResetThread starts the thread over, its next run gets a new session. The reset is recorded as a
+// run without a session, the reason says who reset it.
+func (r *Runner) ResetThread(ctx context.Context, app *types.App, triggerType types.TriggerType, threadID, reason string) error {
+	now := time.Now()
+
+	_, err := r.store.CreateTriggerRun(ctx, &types.TriggerRun{
+		ID:       system.GenerateTriggerRunID(),
+		AppID:    app.ID,
+		Type:     triggerType,
+		Status:   types.TriggerRunStatusSuccess,
+		Started:  now,
+		Finished: now,
+		ThreadID: threadID,
+		Input:    reason,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reset thread: %w", err)
+	}
+
+	return nil
+}
+
+// threadSession returns the session of the thread's latest run
+func (r *Runner) threadSession(ctx context.Context, app *types.App, req *Request) (*types.Session, bool) {
+	if req.ThreadID == "" {
+		return nil, false
+	}
+
+	runs, err := r.store.ListTriggerRuns(ctx, &store.ListTriggerRunsQuery{
+		AppID:    app.ID,
+		Type:     req.Type,
+		ThreadID: req.ThreadID,
+		Limit:    1,
+	})
+	// The thread was reset when its latest run has no session
+	if err != nil || len(runs) == 0 || runs[0].SessionID == "" {
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("app_id", app.ID).
+				Str("thread_id", req.ThreadID).
+				Msg("failed to find the thread's runs, starting a new session")
+		}
+		return nil, false
+	}
+
+	session, err := r.store.GetSession(ctx, runs[0].SessionID)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("app_id", app.ID).
+			Str("session_id", runs[0].SessionID).
+			Msg("failed to get the thread's session, starting a new session")
+		return nil, false
+	}
+
+	return session, true
+}
+
+// newSession creates the session that a run's conversation is kept in
+func newSession(app *types.App, name, input string) *types.Session {
+	return &types.Session{
+		ID:        system.GenerateSessionID(),
+		Name:      name,
+		Created:   time.Now(),
+		Updated:   time.Now(),
+		Mode:      types.SessionModeInference,
+		Type:      types.SessionTypeText,
+		ParentApp: app.ID,
+		Owner:     app.Owner,
+		OwnerType: app.OwnerType,
+		Metadata: types.SessionMetadata{
+			AppRevision: app.Revision,
+			Origin: types.SessionOrigin{
+				Type: types.SessionOriginTypeUserCreated,
+			},
+			HelixVersion: data.GetHelixVersion(),
+		},
+		Interactions: newInteractions(input),
+	}
+}
+
+// newInteractions are the run's question and the answer that's filled in once the app has run
+func newInteractions(input string) []*types.Interaction {
+	return []*types.Interaction{
+		{
+			ID:        system.GenerateUUID(),
+			Created:   time.Now(),
+			Updated:   time.Now(),
+			Scheduled: time.Now(),
+			Completed: time.Now(),
+			Mode:      types.SessionModeInference,
+			Creator:   types.CreatorTypeUser,
+			State:     types.InteractionStateComplete,
+			Finished:  true,
+			Message:   input,
+		},
+		{
+			ID:       system.GenerateUUID(),
+			Created:  time.Now(),
+			Updated:  time.Now(),
+			Creator:  types.CreatorTypeAssistant,
+			Mode:     types.SessionModeInference,
+			State:    types.InteractionStateWaiting,
+			Metadata: map[string]string{},
+		},
+	}
+}
@@ -0,0 +1,99 @@
+package run
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/helixml/helix/api/pkg/config"
+	"github.com/helixml/helix/api/pkg/controller"
+	"github.com/helixml/helix/api/pkg/pubsub"
+	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/trigger/sink"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+func TestFinishResumed(t *testing.T) {
+	posted := make(chan types.TriggerRun, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var run types.TriggerRun
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&run))
+		posted <- run
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	ctrl := gomock.NewController(t)
+	st := store.NewMockStore(ctrl)
+
+	ps, err := pubsub.New(t.TempDir())
+	require.NoError(t, err)
+
+	sinks, err := sink.New(&config.ServerConfig{}, nil, nil, nil)
+	require.NoError(t, err)
+
+	runner := New(st, &controller.Controller{Options: controller.Options{Store: st, PubSub: ps}}, sinks)
+
+	app := &types.App{ID: "app_1", Owner: "user_1"}
+
+	// The trigger run was waiting for the workflow run when the server stopped
+	st.EXPECT().GetTriggerRun(gomock.Any(), "trun_1").Return(&types.TriggerRun{
+		ID:        "trun_1",
+		AppID:     "app_1",
+		Type:      types.TriggerTypeCron,
+		Status:    types.TriggerRunStatusRunning,
+		Started:   time.Now().Add(-time.Hour),
+		SessionID: "ses_1",
+		Sinks:     types.TriggerSinks{{Webhook: &types.TriggerSinkWebhook{URL: ts.URL}}},
+	}, nil)
+	st.EXPECT().GetSession(gomock.Any(), "ses_1").Return(&types.Session{
+		ID:           "ses_1",
+		Owner:        "user_1",
+		Interactions: newInteractions("Process the invoices"),
+	}, nil)
+	st.EXPECT().UpdateSession(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, session types.Session) (*types.Session, error) {
+		assistant := session.Interactions[len(session.Interactions)-1]
+		assert.Equal(t, types.InteractionStateComplete, assistant.State)
+		assert.Equal(t, "invoice report", assistant.Message)
+		return &session, nil
+	})
+
+	var finished *types.TriggerRun
+	st.EXPECT().UpdateTriggerRun(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, run *types.TriggerRun) (*types.TriggerRun, error) {
+		finished = run
+		return run, nil
+	})
+
+	runner.finishResumed(context.Background(), app, &types.WorkflowRun{
+		ID:           "wfrun_1",
+		AppID:        "app_1",
+		Status:       types.WorkflowRunStatusSuccess,
+		Output:       "invoice report",
+		TriggerRunID: "trun_1",
+	})
+
+	require.NotNil(t, finished)
+	assert.Equal(t, types.TriggerRunStatusSuccess, finished.Status)
+	assert.Equal(t, "invoice report", finished.Output)
+	assert.Equal(t, "wfrun_1", finished.WorkflowRunID)
+	assert.False(t, finished.Finished.IsZero())
+	assert.Equal(t, types.TriggerRunDeliveries{{Sink: "webhook"}}, finished.Deliveries)
+
+	select {
+	case run := <-posted:
+		assert.Equal(t, "trun_1", run.ID)
+		assert.Equal(t, "invoice report", run.Output)
+	default:
+		t.Fatal("the output wasn't delivered")
+	}
+
+	// Runs started through the API don't have a trigger run
+	runner.finishResumed(context.Background(), app, &types.WorkflowRun{ID: "wfrun_2", Status: types.WorkflowRunStatusSuccess})
+}
@@ -0,0 +1,332 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/helixml/helix/api/pkg/auth"
+	"github.com/helixml/helix/api/pkg/config"
+	"github.com/helixml/helix/api/pkg/filestore"
+	"github.com/helixml/helix/api/pkg/notification"
+	"github.com/helixml/helix/api/pkg/types"
+	"github.com/helixml/helix/api/pkg/util/split"
+)
+
+const (
+	webhookTimeout = 30 * time.Second
+	// discordMessageLimit is the most characters a Discord message can have
+	discordMessageLimit = 2000
+)
+
+type discordAPI interface {
+	Channel(channelID string, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+	Guild(guildID string, options ...discordgo.RequestOption) (*discordgo.Guild, error)
+	ChannelMessageSend(channelID string, content string, options ...discordgo.RequestOption) (*discordgo.Message, error)
+}
+
+// Sinks delivers the output of trigger runs to where the app's trigger says it should go
+type Sinks struct {
+	cfg           *config.ServerConfig
+	notifier      notification.Notifier
+	authenticator auth.Authenticator
+	filestore     filestore.FileStore
+	httpClient    *http.Client
+	discord       discordAPI
+}
+
+func New(cfg *config.ServerConfig, notifier notification.Notifier, authenticator auth.Authenticator, fs filestore.FileStore) (*Sinks, error) {
+	s := &Sinks{
+		cfg:           cfg,
+		notifier:      notifier,
+		authenticator: authenticator,
+		filestore:     fs,
+		httpClient:    &http.Client{Timeout: webhookTimeout},
+	}
+
+	if cfg.Triggers.Discord.BotToken != "" {
+		discord, err := discordgo.New("Bot " + cfg.Triggers.Discord.BotToken)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create discord session: %w", err)
+		}
+		s.discord = discord
+	}
+
+	return s, nil
+}
+
+// Recipients are who email sinks can send to, the app owner's own address and the addresses in
+// the domains that the operator allows. Without them an app could email anyone from the
+// server's address.
+type Recipients struct {
+	// Owner is the owner's address, empty when they haven't verified it
+	Owner   string
+	Domains []string
+}
+
+// LoadRecipients returns who the email sinks of the owner's apps can send to
+func LoadRecipients(ctx context.Context, cfg *config.ServerConfig, authenticator auth.Authenticator, owner string) (Recipients, error) {
+	recipients := Recipients{Domains: cfg.Triggers.Sinks.EmailDomains}
+	if authenticator == nil || owner == "" {
+		return recipients, nil
+	}
+
+	user, err := authenticator.GetUserByID(ctx, owner)
+	if err != nil {
+		return recipients, fmt.Errorf("failed to get app owner '%s': %w", owner, err)
+	}
+	if user.EmailVerified {
+		recipients.Owner = user.Email
+	}
+
+	return recipients, nil
+}
+
+// Allowed returns whether email sinks can send to the address
+func (r Recipients) Allowed(address string) bool {
+	parsed, err := mail.ParseAddress(address)
+	if err != nil {
+		return false
+	}
+
+	if r.Owner != "" && strings.EqualFold(parsed.Address, r.Owner) {
+		return true
+	}
+
+	domain := parsed.Address[strings.LastIndex(parsed.Address, "@")+1:]
+	for _, allowed := range r.Domains {
+		if strings.EqualFold(domain, allowed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Validate checks that each sink has exactly one destination and the settings it needs, and
+// that email sinks only send to the recipients
+func Validate(sinks []types.TriggerSink, recipients Recipients) error {
+	for idx, sink := range sinks {
+		set := 0
+		for _, configured := range []bool{sink.Webhook != nil, sink.Email != nil, sink.Discord != nil, sink.File != nil} {
+			if configured {
+				set++
+			}
+		}
+		if set != 1 {
+			return fmt.Errorf("sink %d must have exactly one of webhook, email, discord or file", idx)
+		}
+
+		switch {
+		case sink.Webhook != nil:
+			u, err := url.Parse(sink.Webhook.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("sink %d: webhook url must be an http or https URL", idx)
+			}
+		case sink.Email != nil:
+			if len(sink.Email.To) == 0 {
+				return fmt.Errorf("sink %d: email needs at least one address in 'to'", idx)
+			}
+			for _, to := range sink.Email.To {
+				if !recipients.Allowed(to) {
+					return fmt.Errorf("sink %d: email can't be sent to %s, only to your verified address or to the domains the operator allows with TRIGGER_SINK_EMAIL_DOMAINS", idx, to)
+				}
+			}
+		case sink.Discord != nil:
+			if sink.Discord.ChannelID == "" {
+				return fmt.Errorf("sink %d: discord needs a channel_id", idx)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Deliver sends the run's output to each sink. A sink that fails doesn't stop the others, the
+// outcome of each is returned.
+func (s *Sinks) Deliver(ctx context.Context, app *types.App, run *types.TriggerRun, sinks []types.TriggerSink) types.TriggerRunDeliveries {
+	deliveries := make(types.TriggerRunDeliveries, 0, len(sinks))
+
+	for _, sink := range sinks {
+		var (
+			name string
+			err  error
+		)
+
+		switch {
+		case sink.Webhook != nil:
+			name = "webhook"
+			err = s.deliverWebhook(ctx, sink.Webhook, run)
+		case sink.Email != nil:
+			name = "email"
+			err = s.deliverEmail(ctx, sink.Email, app, run)
+		case sink.Discord != nil:
+			name = "discord"
+			err = s.deliverDiscord(sink.Discord, app, run)
+		case sink.File != nil:
+			name = "file"
+			err = s.deliverFile(ctx, sink.File, app, run)
+		default:
+			continue
+		}
+
+		delivery := types.TriggerRunDelivery{Sink: name}
+		if err != nil {
+			delivery.Error = err.Error()
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries
+}
+
+func (s *Sinks) deliverWebhook(ctx context.Context, webhook *types.TriggerSinkWebhook, run *types.TriggerRun) error {
+	bts, err := json.Marshal(run)
+	if err != nil {
+		return fmt.Errorf("failed to marshal run: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(bts))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range webhook.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post to webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func (s *Sinks) deliverEmail(ctx context.Context, email *types.TriggerSinkEmail, app *types.App, run *types.TriggerRun) error {
+	if s.notifier == nil {
+		return fmt.Errorf("notifications are not configured")
+	}
+
+	// The recipients are checked again as the owner's address or the operator's domains may have
+	// changed since the app was saved
+	recipients, err := LoadRecipients(ctx, s.cfg, s.authenticator, app.Owner)
+	if err != nil {
+		return err
+	}
+	for _, to := range email.To {
+		if !recipients.Allowed(to) {
+			return fmt.Errorf("email can't be sent to %s", to)
+		}
+	}
+
+	subject := email.Subject
+	if subject == "" {
+		subject = appName(app)
+	}
+
+	for _, to := range email.To {
+		err := s.notifier.Notify(ctx, &notification.Notification{
+			Event:   notification.EventTriggerRunOutput,
+			Email:   to,
+			Title:   subject,
+			Message: run.Output,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to email %s: %w", to, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Sinks) deliverDiscord(discord *types.TriggerSinkDiscord, app *types.App, run *types.TriggerRun) error {
+	if s.discord == nil {
+		return fmt.Errorf("discord bot token is not configured")
+	}
+
+	if err := s.checkDiscordChannel(app, discord.ChannelID); err != nil {
+		return err
+	}
+
+	for _, message := range split.Message(run.Output, discordMessageLimit) {
+		if _, err := s.discord.ChannelMessageSend(discord.ChannelID, message); err != nil {
+			return fmt.Errorf("failed to send discord message: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// checkDiscordChannel makes sure the channel is in a server the app has a Discord trigger for. The
+// bot can post in every server it's in, most of which belong to other apps.
+func (s *Sinks) checkDiscordChannel(app *types.App, channelID string) error {
+	channel, err := s.discord.Channel(channelID)
+	if err != nil {
+		return fmt.Errorf("failed to get discord channel %s: %w", channelID, err)
+	}
+	if channel.GuildID == "" {
+		return fmt.Errorf("discord channel %s is not in a server", channelID)
+	}
+
+	guild, err := s.discord.Guild(channel.GuildID)
+	if err != nil {
+		return fmt.Errorf("failed to get discord server of channel %s: %w", channelID, err)
+	}
+
+	for _, trigger := range app.Config.Helix.Triggers {
+		if trigger.Discord != nil && trigger.Discord.ServerName == guild.Name {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("discord channel %s is not in a server the app has a discord trigger for", channelID)
+}
+
+func (s *Sinks) deliverFile(ctx context.Context, file *types.TriggerSinkFile, app *types.App, run *types.TriggerRun) error {
+	if s.filestore == nil {
+		return fmt.Errorf("filestore is not configured")
+	}
+
+	folder := file.Path
+	if folder == "" {
+		folder = filepath.Join("triggers", app.ID)
+	}
+
+	// Paths stay inside the owner's filestore
+	folder = filepath.Join("/", folder)
+	path := filepath.Join(
+		filestore.GetUserPrefix(s.cfg.Controller.FilePrefixGlobal, app.Owner),
+		folder,
+		run.Started.UTC().Format("2006-01-02T15-04-05")+".md",
+	)
+
+	_, err := s.filestore.WriteFile(ctx, path, strings.NewReader(run.Output))
+	if err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return nil
+}
+
+func appName(app *types.App) string {
+	if app.Config.Helix.Name != "" {
+		return app.Config.Helix.Name
+	}
+	return app.ID
+}
@@ -0,0 +1,278 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/helixml/helix/api/pkg/auth"
+	"github.com/helixml/helix/api/pkg/config"
+	"github.com/helixml/helix/api/pkg/filestore"
+	"github.com/helixml/helix/api/pkg/notification"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+type testNotifier struct {
+	notifications []*notification.Notification
+}
+
+func (n *testNotifier) Notify(_ context.Context, notification *notification.Notification) error {
+	n.notifications = append(n.notifications, notification)
+	return nil
+}
+
+// testDiscord has channel 123 in the Acme server and channel 456 in another server
+type testDiscord struct {
+	messages []string
+}
+
+func (d *testDiscord) Channel(channelID string, _ ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	switch channelID {
+	case "123":
+		return &discordgo.Channel{ID: channelID, GuildID: "g_acme"}, nil
+	case "456":
+		return &discordgo.Channel{ID: channelID, GuildID: "g_other"}, nil
+	}
+	return nil, fmt.Errorf("unknown channel")
+}
+
+func (d *testDiscord) Guild(guildID string, _ ...discordgo.RequestOption) (*discordgo.Guild, error) {
+	names := map[string]string{"g_acme": "Acme", "g_other": "Other"}
+	return &discordgo.Guild{ID: guildID, Name: names[guildID]}, nil
+}
+
+func (d *testDiscord) ChannelMessageSend(_ string, content string, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
+	d.messages = append(d.messages, content)
+	return &discordgo.Message{}, nil
+}
+
+func TestDeliver(t *testing.T) {
+	var posted types.TriggerRun
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret", r.Header.Get("X-Token"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&posted))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	ctrl := gomock.NewController(t)
+	fs := filestore.NewMockFileStore(ctrl)
+
+	cfg := &config.ServerConfig{}
+	cfg.Controller.FilePrefixGlobal = "dev"
+	cfg.Triggers.Sinks.EmailDomains = []string{"example.com"}
+
+	notifier := &testNotifier{}
+	discord := &testDiscord{}
+
+	sinks, err := New(cfg, notifier, nil, fs)
+	require.NoError(t, err)
+	sinks.discord = discord
+
+	app := &types.App{ID: "app_1", Owner: "user_1"}
+	app.Config.Helix.Name = "daily-digest"
+	app.Config.Helix.Triggers = []types.Trigger{{Discord: &types.DiscordTrigger{ServerName: "Acme"}}}
+
+	run := &types.TriggerRun{
+		ID:      "trun_1",
+		AppID:   "app_1",
+		Status:  types.TriggerRunStatusSuccess,
+		Started: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC),
+		Output:  "Nothing happened today",
+	}
+
+	fs.EXPECT().WriteFile(gomock.Any(), "dev/users/user_1/digests/2024-05-01T09-00-00.md", gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, r io.Reader) (filestore.Item, error) {
+			bts, err := io.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, "Nothing happened today", string(bts))
+			return filestore.Item{}, nil
+		})
+
+	deliveries := sinks.Deliver(context.Background(), app, run, []types.TriggerSink{
+		{Webhook: &types.TriggerSinkWebhook{URL: ts.URL, Headers: map[string]string{"X-Token": "secret"}}},
+		{Email: &types.TriggerSinkEmail{To: []string{"team@example.com"}}},
+		{Discord: &types.TriggerSinkDiscord{ChannelID: "123"}},
+		{File: &types.TriggerSinkFile{Path: "../../digests"}},
+	})
+
+	assert.Equal(t, types.TriggerRunDeliveries{
+		{Sink: "webhook"}, {Sink: "email"}, {Sink: "discord"}, {Sink: "file"},
+	}, deliveries)
+
+	assert.Equal(t, "trun_1", posted.ID)
+
+	require.Len(t, notifier.notifications, 1)
+	assert.Equal(t, "team@example.com", notifier.notifications[0].Email)
+	assert.Equal(t, "daily-digest", notifier.notifications[0].Title)
+	assert.Equal(t, notification.EventTriggerRunOutput, notifier.notifications[0].Event)
+
+	assert.Equal(t, []string{"Nothing happened today"}, discord.messages)
+}
+
+func TestDeliver_RecordsFailures(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	sinks, err := New(&config.ServerConfig{}, nil, nil, nil)
+	require.NoError(t, err)
+
+	deliveries := sinks.Deliver(context.Background(), &types.App{ID: "app_1"}, &types.TriggerRun{}, []types.TriggerSink{
+		{Webhook: &types.TriggerSinkWebhook{URL: ts.URL}},
+		{Discord: &types.TriggerSinkDiscord{ChannelID: "123"}},
+	})
+
+	require.Len(t, deliveries, 2)
+	assert.Equal(t, "webhook responded with status 500", deliveries[0].Error)
+	assert.Equal(t, "discord bot token is not configured", deliveries[1].Error)
+}
+
+func TestDeliver_DiscordChannelInOtherServer(t *testing.T) {
+	discord := &testDiscord{}
+
+	sinks, err := New(&config.ServerConfig{}, nil, nil, nil)
+	require.NoError(t, err)
+	sinks.discord = discord
+
+	app := &types.App{ID: "app_1"}
+	app.Config.Helix.Triggers = []types.Trigger{{Discord: &types.DiscordTrigger{ServerName: "Acme"}}}
+
+	deliveries := sinks.Deliver(context.Background(), app, &types.TriggerRun{Output: "Hello"}, []types.TriggerSink{
+		{Discord: &types.TriggerSinkDiscord{ChannelID: "456"}},
+	})
+
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, "discord channel 456 is not in a server the app has a discord trigger for", deliveries[0].Error)
+	assert.Empty(t, discord.messages)
+
+	// Apps without a Discord trigger can't post anywhere
+	deliveries = sinks.Deliver(context.Background(), &types.App{ID: "app_2"}, &types.TriggerRun{Output: "Hello"}, []types.TriggerSink{
+		{Discord: &types.TriggerSinkDiscord{ChannelID: "123"}},
+	})
+
+	require.Len(t, deliveries, 1)
+	assert.NotEmpty(t, deliveries[0].Error)
+	assert.Empty(t, discord.messages)
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		sinks   []types.TriggerSink
+		wantErr string
+	}{
+		{
+			name: "valid",
+			sinks: []types.TriggerSink{
+				{Webhook: &types.TriggerSinkWebhook{URL: "https://example.com/hook"}},
+				{Email: &types.TriggerSinkEmail{To: []string{"ops@example.com"}}},
+				{Discord: &types.TriggerSinkDiscord{ChannelID: "123"}},
+				{File: &types.TriggerSinkFile{}},
+			},
+		},
+		{
+			name:    "empty sink",
+			sinks:   []types.TriggerSink{{}},
+			wantErr: "exactly one",
+		},
+		{
+			name: "two destinations",
+			sinks: []types.TriggerSink{{
+				Webhook: &types.TriggerSinkWebhook{URL: "https://example.com/hook"},
+				File:    &types.TriggerSinkFile{},
+			}},
+			wantErr: "exactly one",
+		},
+		{
+			name:    "webhook without scheme",
+			sinks:   []types.TriggerSink{{Webhook: &types.TriggerSinkWebhook{URL: "example.com/hook"}}},
+			wantErr: "http or https",
+		},
+		{
+			name:    "email without recipients",
+			sinks:   []types.TriggerSink{{Email: &types.TriggerSinkEmail{Subject: "Digest"}}},
+			wantErr: "'to'",
+		},
+		{
+			name:  "owner's address",
+			sinks: []types.TriggerSink{{Email: &types.TriggerSinkEmail{To: []string{"Jane <JANE@acme.com>"}}}},
+		},
+		{
+			name:    "email outside the allowed domains",
+			sinks:   []types.TriggerSink{{Email: &types.TriggerSinkEmail{To: []string{"ops@example.com", "someone@elsewhere.com"}}}},
+			wantErr: "someone@elsewhere.com",
+		},
+		{
+			name:    "email to a subdomain",
+			sinks:   []types.TriggerSink{{Email: &types.TriggerSinkEmail{To: []string{"ops@mail.example.com"}}}},
+			wantErr: "TRIGGER_SINK_EMAIL_DOMAINS",
+		},
+		{
+			name:    "more than one address in one entry",
+			sinks:   []types.TriggerSink{{Email: &types.TriggerSinkEmail{To: []string{"ops@example.com, someone@elsewhere.com"}}}},
+			wantErr: "can't be sent",
+		},
+		{
+			name:    "discord without channel",
+			sinks:   []types.TriggerSink{{Discord: &types.TriggerSinkDiscord{}}},
+			wantErr: "channel_id",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.sinks, Recipients{Owner: "jane@acme.com", Domains: []string{"example.com"}})
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestDeliver_EmailRecipients(t *testing.T) {
+	cfg := &config.ServerConfig{}
+	notifier := &testNotifier{}
+
+	owner := &types.User{ID: "user_1", Email: "jane@acme.com", EmailVerified: true}
+	sinks, err := New(cfg, notifier, auth.NewMockAuthenticator(owner), nil)
+	require.NoError(t, err)
+
+	app := &types.App{ID: "app_1", Owner: "user_1"}
+	run := &types.TriggerRun{Output: "Hello"}
+
+	deliveries := sinks.Deliver(context.Background(), app, run, []types.TriggerSink{
+		{Email: &types.TriggerSinkEmail{To: []string{"jane@acme.com"}}},
+		{Email: &types.TriggerSinkEmail{To: []string{"jane@acme.com", "someone@elsewhere.com"}}},
+	})
+
+	require.Len(t, deliveries, 2)
+	assert.Empty(t, deliveries[0].Error)
+	assert.Equal(t, "email can't be sent to someone@elsewhere.com", deliveries[1].Error)
+	require.Len(t, notifier.notifications, 1)
+	assert.Equal(t, "jane@acme.com", notifier.notifications[0].Email)
+
+	// Owners can't send to an address they haven't verified
+	owner.EmailVerified = false
+	deliveries = sinks.Deliver(context.Background(), app, run, []types.TriggerSink{
+		{Email: &types.TriggerSinkEmail{To: []string{"jane@acme.com"}}},
+	})
+
+	require.Len(t, deliveries, 1)
+	assert.NotEmpty(t, deliveries[0].Error)
+	assert.Len(t, notifier.notifications, 1)
+}
@@ -0,0 +1,559 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/rs/zerolog/log"
+	openai "github.com/sashabaranov/go-openai"
+	goslack "github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/slack-go/slack/socketmode"
+
+	"github.com/helixml/helix/api/pkg/config"
+	"github.com/helixml/helix/api/pkg/controller"
+	"github.com/helixml/helix/api/pkg/data"
+	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+const (
+	// Users will be redirected to this URL to install the bot
+	installationDocsURL = "https://docs.helix.ml/helix/"
+	// history limit
+	historyLimit = 30
+	// maxThreads is how many threads the bot remembers being in, replies in threads that it has
+	// forgotten need to mention it again
+	maxThreads = 10000
+)
+
+var mentionRegexp = regexp.MustCompile(`<@[A-Z0-9]+>`)
+
+// slackAPI is the part of the Slack Web API that the trigger uses
+type slackAPI interface {
+	PostMessageContext(ctx context.Context, channelID string, options ...goslack.MsgOption) (string, string, error)
+	GetConversationRepliesContext(ctx context.Context, params *goslack.GetConversationRepliesParameters) ([]goslack.Message, bool, string, error)
+	GetConversationHistoryContext(ctx context.Context, params *goslack.GetConversationHistoryParameters) (*goslack.GetConversationHistoryResponse, error)
+}
+
+type Slack struct {
+	cfg        *config.ServerConfig
+	store      store.Store
+	controller *controller.Controller
+
+	api         slackAPI
+	postWebhook func(ctx context.Context, url string, msg *goslack.WebhookMessage) error
+	botUserID   string
+
+	threads *lru.Cache[string, struct{}] // channel:thread timestamp of the threads the bot is in
+
+	appsMu sync.Mutex
+	apps   map[string]*types.App // Team ID -> App
+}
+
+func New(cfg *config.ServerConfig, store store.Store, controller *controller.Controller) *Slack {
+	return &Slack{
+		cfg:         cfg,
+		store:       store,
+		controller:  controller,
+		postWebhook: goslack.PostWebhookContext,
+		threads:     newThreads(maxThreads),
+		apps:        make(map[string]*types.App),
+	}
+}
+
+func (s *Slack) Start(ctx context.Context) error {
+	api := goslack.New(s.cfg.Triggers.Slack.BotToken, goslack.OptionAppLevelToken(s.cfg.Triggers.Slack.AppToken))
+
+	auth, err := api.AuthTestContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error obtaining account details: %w", err)
+	}
+	s.api = api
+	s.botUserID = auth.UserID
+
+	logger := log.With().Str("trigger", "slack").Logger()
+
+	logger.Info().Msg("starting Slack bot")
+
+	if err := s.syncAppsOnce(ctx); err != nil {
+		return err
+	}
+
+	// Keep the apps in sync so that we know which app is configured for which workspace
+	go func() {
+		if err := s.syncApps(ctx); err != nil {
+			logger.Error().Msgf("app sync loop exited with error: %v", err)
+		}
+	}()
+
+	client := socketmode.New(api)
+
+	go s.handleEvents(ctx, client)
+
+	return client.RunContext(ctx)
+}
+
+func (s *Slack) handleEvents(ctx context.Context, client *socketmode.Client) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case evt := <-client.Events:
+			switch evt.Type {
+			case socketmode.EventTypeEventsAPI:
+				event, ok := evt.Data.(slackevents.EventsAPIEvent)
+				if !ok {
+					continue
+				}
+				client.Ack(*evt.Request)
+
+				go s.handleEventsAPIEvent(ctx, event)
+			case socketmode.EventTypeSlashCommand:
+				cmd, ok := evt.Data.(goslack.SlashCommand)
+				if !ok {
+					continue
+				}
+				// Slack needs an answer within 3 seconds, the response is posted to the
+				// response URL once it's ready
+				client.Ack(*evt.Request)
+
+				go s.handleSlashCommand(ctx, cmd)
+			case socketmode.EventTypeConnectionError, socketmode.EventTypeInvalidAuth:
+				log.Warn().
+					Str("trigger", "slack").
+					Str("event_type", string(evt.Type)).
+					Msg("Slack connection problem")
+			}
+		}
+	}
+}
+
+func (s *Slack) syncApps(ctx context.Context) error {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := s.syncAppsOnce(ctx); err != nil {
+				return fmt.Errorf("failed syncing app: %v", err)
+			}
+		}
+	}
+}
+
+func (s *Slack) syncAppsOnce(ctx context.Context) error {
+	// Load all apps, check for slack configuration
+	apps, err := s.store.ListApps(ctx, &store.ListAppsQuery{})
+	if err != nil {
+		return fmt.Errorf("failed to list apps: %w", err)
+	}
+
+	slackApps := bindWorkspaces(apps)
+
+	s.appsMu.Lock()
+	s.apps = slackApps
+	s.appsMu.Unlock()
+
+	return nil
+}
+
+// bindWorkspaces maps each workspace to the app that was bound to it first. Apps are checked when
+// they're saved, this keeps a later app from taking over a workspace if one slips through.
+func bindWorkspaces(apps []*types.App) map[string]*types.App {
+	sorted := make([]*types.App, len(apps))
+	copy(sorted, apps)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Created.Before(sorted[j].Created)
+	})
+
+	slackApps := make(map[string]*types.App)
+
+	for _, app := range sorted {
+		trigger, ok := getSlackTrigger(app)
+		if !ok {
+			continue
+		}
+		if bound, ok := slackApps[trigger.TeamID]; ok {
+			log.Warn().
+				Str("trigger", "slack").
+				Str("team_id", trigger.TeamID).
+				Str("app_id", app.ID).
+				Str("bound_app_id", bound.ID).
+				Msg("Slack workspace is already bound to another app, ignoring this app's trigger")
+			continue
+		}
+		slackApps[trigger.TeamID] = app
+	}
+
+	return slackApps
+}
+
+func (s *Slack) getApp(teamID string) (*types.App, bool) {
+	s.appsMu.Lock()
+	defer s.appsMu.Unlock()
+
+	app, ok := s.apps[teamID]
+	return app, ok
+}
+
+// message is a message that the bot may answer, from a mention or a message event
+type message struct {
+	channel  string
+	user     string
+	botID    string
+	text     string
+	ts       string
+	threadTS string
+	// mention is set for app_mention events, which Slack also sends as message events
+	mention bool
+	direct  bool
+}
+
+func (s *Slack) handleEventsAPIEvent(ctx context.Context, event slackevents.EventsAPIEvent) {
+	switch ev := event.InnerEvent.Data.(type) {
+	case *slackevents.AppMentionEvent:
+		s.handleMessage(ctx, event.TeamID, &message{
+			channel:  ev.Channel,
+			user:     ev.User,
+			botID:    ev.BotID,
+			text:     ev.Text,
+			ts:       ev.TimeStamp,
+			threadTS: ev.ThreadTimeStamp,
+			mention:  true,
+		})
+	case *slackevents.MessageEvent:
+		// Edits, joins and the like
+		if ev.SubType != "" {
+			return
+		}
+		s.handleMessage(ctx, event.TeamID, &message{
+			channel:  ev.Channel,
+			user:     ev.User,
+			botID:    ev.BotID,
+			text:     ev.Text,
+			ts:       ev.TimeStamp,
+			threadTS: ev.ThreadTimeStamp,
+			direct:   ev.ChannelType == "im",
+		})
+	}
+}
+
+func (s *Slack) isDirectedAtBot(m *message) bool {
+	if m.mention || m.direct {
+		return true
+	}
+
+	// Mentions in threads are handled by the app_mention event
+	if strings.Contains(m.text, "<@"+s.botUserID+">") || m.threadTS == "" {
+		return false
+	}
+
+	return s.threads.Contains(m.channel + ":" + m.threadTS)
+}
+
+func (s *Slack) handleMessage(ctx context.Context, teamID string, m *message) {
+	logger := log.With().Str("trigger", "slack").Logger()
+
+	if m.botID != "" || m.user == s.botUserID {
+		return
+	}
+
+	if !s.isDirectedAtBot(m) {
+		return
+	}
+
+	// Channel messages are answered in a thread, direct messages only when they're in one
+	replyTS := m.threadTS
+	if replyTS == "" && !m.direct {
+		replyTS = m.ts
+	}
+
+	app, ok := s.getApp(teamID)
+	if !ok {
+		logger.Warn().Str("team_id", teamID).Msg("no app configured for workspace")
+
+		s.reply(ctx, m.channel, replyTS, fmt.Sprintf("I am not yet configured to respond in this Slack workspace. Please visit %s to install me.", installationDocsURL))
+		return
+	}
+
+	logger.Info().
+		Str("app_id", app.ID).
+		Str("team_id", teamID).
+		Str("channel", m.channel).
+		Str("user", m.user).
+		Msg("received message")
+
+	history, err := s.getHistory(ctx, m)
+	if err != nil {
+		logger.Err(err).Msg("failed to get message history")
+		return
+	}
+
+	resp, err := s.startChat(ctx, app, "", history, m.text)
+	if err != nil {
+		logger.Err(err).Msg("failed to get response from inference API")
+		s.reply(ctx, m.channel, replyTS, fmt.Sprintf("Failed to get response: %s", err))
+		return
+	}
+
+	s.reply(ctx, m.channel, replyTS, resp)
+
+	if !m.direct {
+		s.threads.Add(m.channel+":"+replyTS, struct{}{})
+	}
+}
+
+func newThreads(size int) *lru.Cache[string, struct{}] {
+	threads, err := lru.New[string, struct{}](size)
+	if err != nil {
+		// Only fails for a non-positive size
+		panic(err)
+	}
+	return threads
+}
+
+func (s *Slack) reply(ctx context.Context, channel, threadTS, text string) {
+	options := []goslack.MsgOption{goslack.MsgOptionText(text, false)}
+	if threadTS != "" {
+		options = append(options, goslack.MsgOptionTS(threadTS))
+	}
+
+	_, _, err := s.api.PostMessageContext(ctx, channel, options...)
+	if err != nil {
+		log.Err(err).Str("trigger", "slack").Msg("failed to send message")
+	}
+}
+
+// getHistory returns the earlier messages of the thread, or of the direct message conversation,
+// oldest first
+func (s *Slack) getHistory(ctx context.Context, m *message) ([]goslack.Message, error) {
+	var history []goslack.Message
+
+	switch {
+	case m.threadTS != "":
+		replies, _, _, err := s.api.GetConversationRepliesContext(ctx, &goslack.GetConversationRepliesParameters{
+			ChannelID: m.channel,
+			Timestamp: m.threadTS,
+			Limit:     historyLimit,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get thread replies: %w", err)
+		}
+		history = replies
+	case m.direct:
+		resp, err := s.api.GetConversationHistoryContext(ctx, &goslack.GetConversationHistoryParameters{
+			ChannelID: m.channel,
+			Latest:    m.ts,
+			Limit:     historyLimit,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get conversation history: %w", err)
+		}
+		// Newest first
+		for i := len(resp.Messages) - 1; i >= 0; i-- {
+			history = append(history, resp.Messages[i])
+		}
+	}
+
+	// The current message is answered separately
+	filtered := history[:0]
+	for _, msg := range history {
+		if msg.Timestamp != m.ts {
+			filtered = append(filtered, msg)
+		}
+	}
+
+	return filtered, nil
+}
+
+func (s *Slack) handleSlashCommand(ctx context.Context, cmd goslack.SlashCommand) {
+	logger := log.With().Str("trigger", "slack").Str("command", cmd.Command).Logger()
+
+	respond := func(responseType, text string) {
+		err := s.postWebhook(ctx, cmd.ResponseURL, &goslack.WebhookMessage{
+			ResponseType: responseType,
+			Text:         text,
+		})
+		if err != nil {
+			logger.Err(err).Msg("failed to respond to slash command")
+		}
+	}
+
+	app, ok := s.getApp(cmd.TeamID)
+	if !ok {
+		logger.Warn().Str("team_id", cmd.TeamID).Msg("no app configured for workspace")
+		respond(goslack.ResponseTypeEphemeral, fmt.Sprintf("I am not yet configured to respond in this Slack workspace. Please visit %s to install me.", installationDocsURL))
+		return
+	}
+
+	command, ok := getSlackCommand(app, cmd.Command)
+	if !ok {
+		respond(goslack.ResponseTypeEphemeral, fmt.Sprintf("The command %s is not configured for this app.", cmd.Command))
+		return
+	}
+
+	assistantID, ok := data.ResolveAssistant(app, command.Assistant)
+	if !ok {
+		respond(goslack.ResponseTypeEphemeral, fmt.Sprintf("The assistant %s of the command %s does not exist.", command.Assistant, cmd.Command))
+		return
+	}
+
+	if strings.TrimSpace(cmd.Text) == "" {
+		respond(goslack.ResponseTypeEphemeral, fmt.Sprintf("Usage: %s <question>", cmd.Command))
+		return
+	}
+
+	resp, err := s.startChat(ctx, app, assistantID, nil, cmd.Text)
+	if err != nil {
+		logger.Err(err).Msg("failed to get response from inference API")
+		respond(goslack.ResponseTypeEphemeral, fmt.Sprintf("Failed to get response: %s", err))
+		return
+	}
+
+	respond(goslack.ResponseTypeInChannel, fmt.Sprintf("<@%s> asked: %s\n\n%s", cmd.UserID, cmd.Text, resp))
+}
+
+func (s *Slack) startChat(ctx context.Context, app *types.App, assistantID string, history []goslack.Message, text string) (string, error) {
+	system := openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleSystem,
+		Content: `You are an AI assistant Slack bot. Be concise with the replies, keep them short but informative.`,
+	}
+
+	messages := []openai.ChatCompletionMessage{
+		system,
+	}
+
+	for _, msg := range history {
+		switch {
+		case msg.User == s.botUserID:
+			messages = append(messages, openai.ChatCompletionMessage{
+				Role:    openai.ChatMessageRoleAssistant,
+				Content: msg.Text,
+			})
+		case msg.BotID != "":
+			// Other bots aren't part of the conversation
+			continue
+		default:
+			messages = append(messages, openai.ChatCompletionMessage{
+				Role:    openai.ChatMessageRoleUser,
+				Content: stripMentions(msg.Text),
+			})
+		}
+	}
+
+	messages = append(messages, openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleUser,
+		Content: stripMentions(text),
+	})
+
+	resp, _, err := s.controller.ChatCompletion(
+		ctx,
+		&types.User{ID: app.Owner},
+		openai.ChatCompletionRequest{
+			Stream:   false,
+			Messages: messages,
+		},
+		&controller.ChatCompletionOptions{
+			AppID:       app.ID,
+			AssistantID: assistantID,
+		},
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to get response from inference API: %w", err)
+	}
+
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("no choices in response")
+	}
+
+	return resp.Choices[0].Message.Content, nil
+}
+
+func stripMentions(text string) string {
+	return strings.TrimSpace(mentionRegexp.ReplaceAllString(text, ""))
+}
+
+func getSlackTrigger(app *types.App) (*types.SlackTrigger, bool) {
+	for _, trigger := range app.Config.Helix.Triggers {
+		if trigger.Slack != nil && trigger.Slack.TeamID != "" {
+			return trigger.Slack, true
+		}
+	}
+	return nil, false
+}
+
+func getSlackCommand(app *types.App, command string) (*types.SlackCommand, bool) {
+	trigger, ok := getSlackTrigger(app)
+	if !ok {
+		return nil, false
+	}
+
+	for i := range trigger.Commands {
+		if trigger.Commands[i].Command == command {
+			return &trigger.Commands[i], true
+		}
+	}
+	return nil, false
+}
+
+// ValidateWorkspace rejects a Slack workspace that another app is already bound to, only one app
+// answers in each workspace
+func ValidateWorkspace(app *types.App, apps []*types.App) error {
+	trigger, ok := getSlackTrigger(app)
+	if !ok {
+		return nil
+	}
+
+	for _, other := range apps {
+		if other.ID == app.ID {
+			continue
+		}
+		if bound, ok := getSlackTrigger(other); ok && bound.TeamID == trigger.TeamID {
+			return fmt.Errorf("slack workspace %s is already bound to another app", trigger.TeamID)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks the app's Slack triggers
+func Validate(cfg *types.AppHelixConfig) error {
+	app := &types.App{Config: types.AppConfig{Helix: *cfg}}
+
+	for _, trigger := range cfg.Triggers {
+		if trigger.Slack == nil {
+			continue
+		}
+
+		if trigger.Slack.TeamID == "" {
+			return fmt.Errorf("slack trigger needs a team_id")
+		}
+
+		commands := make(map[string]bool)
+		for _, command := range trigger.Slack.Commands {
+			if !strings.HasPrefix(command.Command, "/") {
+				return fmt.Errorf("slack command '%s' must start with /", command.Command)
+			}
+			if commands[command.Command] {
+				return fmt.Errorf("slack command '%s' is used more than once", command.Command)
+			}
+			commands[command.Command] = true
+
+			if _, ok := data.ResolveAssistant(app, command.Assistant); !ok {
+				return fmt.Errorf("slack command '%s' refers to assistant '%s' that does not exist", command.Command, command.Assistant)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,361 @@
+package slack
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+	goslack "github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/helixml/helix/api/pkg/config"
+	"github.com/helixml/helix/api/pkg/controller"
+	"github.com/helixml/helix/api/pkg/extract"
+	"github.com/helixml/helix/api/pkg/filestore"
+	"github.com/helixml/helix/api/pkg/janitor"
+	oai "github.com/helixml/helix/api/pkg/openai"
+	"github.com/helixml/helix/api/pkg/openai/manager"
+	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+type postedMessage struct {
+	channel  string
+	text     string
+	threadTS string
+}
+
+// testSlackAPI records the messages the bot posts and serves the thread history
+type testSlackAPI struct {
+	posted  []postedMessage
+	replies []goslack.Message
+	history []goslack.Message
+}
+
+func (a *testSlackAPI) PostMessageContext(_ context.Context, channelID string, options ...goslack.MsgOption) (string, string, error) {
+	_, values, err := goslack.UnsafeApplyMsgOptions("", channelID, "", options...)
+	if err != nil {
+		return "", "", err
+	}
+	a.posted = append(a.posted, postedMessage{
+		channel:  channelID,
+		text:     values.Get("text"),
+		threadTS: values.Get("thread_ts"),
+	})
+	return channelID, "1700000100.000000", nil
+}
+
+func (a *testSlackAPI) GetConversationRepliesContext(_ context.Context, _ *goslack.GetConversationRepliesParameters) ([]goslack.Message, bool, string, error) {
+	return a.replies, false, "", nil
+}
+
+func (a *testSlackAPI) GetConversationHistoryContext(_ context.Context, _ *goslack.GetConversationHistoryParameters) (*goslack.GetConversationHistoryResponse, error) {
+	return &goslack.GetConversationHistoryResponse{Messages: a.history}, nil
+}
+
+func slackMessage(user, text, ts string) goslack.Message {
+	var msg goslack.Message
+	msg.User = user
+	msg.Text = text
+	msg.Timestamp = ts
+	return msg
+}
+
+func newTestSlack(t *testing.T) (*Slack, *testSlackAPI, *oai.MockClient) {
+	ctrl := gomock.NewController(t)
+	st := store.NewMockStore(ctrl)
+	client := oai.NewMockClient(ctrl)
+
+	providerManager := manager.NewMockProviderManager(ctrl)
+	providerManager.EXPECT().GetClient(gomock.Any(), gomock.Any()).Return(client, nil).AnyTimes()
+
+	cfg := &config.ServerConfig{}
+	cfg.Inference.Provider = types.ProviderTogetherAI
+
+	c, err := controller.NewController(context.Background(), controller.Options{
+		Config:          cfg,
+		Store:           st,
+		Janitor:         janitor.NewJanitor(config.Janitor{}),
+		ProviderManager: providerManager,
+		Filestore:       filestore.NewMockFileStore(ctrl),
+		Extractor:       extract.NewMockExtractor(ctrl),
+	})
+	require.NoError(t, err)
+
+	app := &types.App{ID: "app_1", Owner: "user_1"}
+	app.Config.Helix.Name = "company-bot"
+	app.Config.Helix.Assistants = []types.AssistantConfig{
+		{Name: "general", SystemPrompt: "You answer general questions"},
+		{Name: "hr", SystemPrompt: "You answer HR questions"},
+	}
+	app.Config.Helix.Triggers = []types.Trigger{
+		{
+			Slack: &types.SlackTrigger{
+				TeamID:   "T123",
+				Commands: []types.SlackCommand{{Command: "/ask-hr", Assistant: "hr"}},
+			},
+		},
+	}
+
+	st.EXPECT().ListApps(gomock.Any(), gomock.Any()).Return([]*types.App{app}, nil).AnyTimes()
+	st.EXPECT().GetAppWithTools(gomock.Any(), "app_1").Return(app, nil).AnyTimes()
+	st.EXPECT().ListSecrets(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+
+	api := &testSlackAPI{}
+
+	s := New(cfg, st, c)
+	s.api = api
+	s.botUserID = "UBOT"
+	require.NoError(t, s.syncAppsOnce(context.Background()))
+
+	return s, api, client
+}
+
+func expectCompletion(client *oai.MockClient, reply string, check func(req openai.ChatCompletionRequest)) {
+	client.EXPECT().CreateChatCompletion(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+			if check != nil {
+				check(req)
+			}
+			return openai.ChatCompletionResponse{
+				Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: reply}}},
+			}, nil
+		})
+}
+
+func TestSlack_MentionStartsThread(t *testing.T) {
+	s, api, client := newTestSlack(t)
+
+	expectCompletion(client, "Use the VPN", func(req openai.ChatCompletionRequest) {
+		last := req.Messages[len(req.Messages)-1]
+		assert.Equal(t, openai.ChatMessageRoleUser, last.Role)
+		assert.Equal(t, "how do I reach the wiki?", last.Content)
+	})
+
+	s.handleEventsAPIEvent(context.Background(), slackevents.EventsAPIEvent{
+		TeamID: "T123",
+		InnerEvent: slackevents.EventsAPIInnerEvent{
+			Data: &slackevents.AppMentionEvent{
+				User:      "U1",
+				Channel:   "C1",
+				Text:      "<@UBOT> how do I reach the wiki?",
+				TimeStamp: "1700000000.000100",
+			},
+		},
+	})
+
+	require.Len(t, api.posted, 1)
+	assert.Equal(t, postedMessage{channel: "C1", text: "Use the VPN", threadTS: "1700000000.000100"}, api.posted[0])
+
+	// Replies in the thread don't need a mention, the thread is the conversation
+	api.replies = []goslack.Message{
+		slackMessage("U1", "<@UBOT> how do I reach the wiki?", "1700000000.000100"),
+		slackMessage("UBOT", "Use the VPN", "1700000100.000000"),
+		slackMessage("U1", "which VPN?", "1700000200.000000"),
+	}
+
+	expectCompletion(client, "The corporate one", func(req openai.ChatCompletionRequest) {
+		n := len(req.Messages)
+		require.GreaterOrEqual(t, n, 3)
+		assert.Equal(t, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: "how do I reach the wiki?"}, req.Messages[n-3])
+		assert.Equal(t, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: "Use the VPN"}, req.Messages[n-2])
+		assert.Equal(t, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: "which VPN?"}, req.Messages[n-1])
+	})
+
+	s.handleEventsAPIEvent(context.Background(), slackevents.EventsAPIEvent{
+		TeamID: "T123",
+		InnerEvent: slackevents.EventsAPIInnerEvent{
+			Data: &slackevents.MessageEvent{
+				User:            "U1",
+				Channel:         "C1",
+				ChannelType:     "channel",
+				Text:            "which VPN?",
+				TimeStamp:       "1700000200.000000",
+				ThreadTimeStamp: "1700000000.000100",
+			},
+		},
+	})
+
+	require.Len(t, api.posted, 2)
+	assert.Equal(t, postedMessage{channel: "C1", text: "The corporate one", threadTS: "1700000000.000100"}, api.posted[1])
+}
+
+func TestSlack_ForgetsOldestThreads(t *testing.T) {
+	s, _, _ := newTestSlack(t)
+	s.threads = newThreads(2)
+
+	for _, ts := range []string{"1.0", "2.0", "3.0"} {
+		s.threads.Add("C1:"+ts, struct{}{})
+	}
+
+	reply := func(threadTS string) *message {
+		return &message{channel: "C1", user: "U1", text: "thanks", ts: "4.0", threadTS: threadTS}
+	}
+	assert.False(t, s.isDirectedAtBot(reply("1.0")))
+	assert.True(t, s.isDirectedAtBot(reply("2.0")))
+	assert.True(t, s.isDirectedAtBot(reply("3.0")))
+}
+
+func TestSlack_IgnoresOtherChannelMessages(t *testing.T) {
+	s, api, _ := newTestSlack(t)
+
+	for _, ev := range []*slackevents.MessageEvent{
+		// Not in a thread the bot is part of
+		{User: "U1", Channel: "C1", ChannelType: "channel", Text: "lunch?", TimeStamp: "1.0"},
+		// Mentions are handled by the app_mention event
+		{User: "U1", Channel: "C1", ChannelType: "channel", Text: "<@UBOT> hi", TimeStamp: "2.0"},
+		// Bots
+		{BotID: "B1", Channel: "D1", ChannelType: "im", Text: "beep", TimeStamp: "3.0"},
+	} {
+		s.handleEventsAPIEvent(context.Background(), slackevents.EventsAPIEvent{
+			TeamID:     "T123",
+			InnerEvent: slackevents.EventsAPIInnerEvent{Data: ev},
+		})
+	}
+
+	assert.Empty(t, api.posted)
+}
+
+func TestSlack_DirectMessageUsesHistory(t *testing.T) {
+	s, api, client := newTestSlack(t)
+
+	// Newest first, as Slack returns them
+	api.history = []goslack.Message{
+		slackMessage("U1", "what's my leave balance?", "3.0"),
+		slackMessage("UBOT", "Hello!", "2.0"),
+		slackMessage("U1", "hi", "1.0"),
+	}
+
+	expectCompletion(client, "12 days", func(req openai.ChatCompletionRequest) {
+		n := len(req.Messages)
+		assert.Equal(t, "hi", req.Messages[n-3].Content)
+		assert.Equal(t, openai.ChatMessageRoleAssistant, req.Messages[n-2].Role)
+		assert.Equal(t, "what's my leave balance?", req.Messages[n-1].Content)
+	})
+
+	s.handleEventsAPIEvent(context.Background(), slackevents.EventsAPIEvent{
+		TeamID: "T123",
+		InnerEvent: slackevents.EventsAPIInnerEvent{
+			Data: &slackevents.MessageEvent{User: "U1", Channel: "D1", ChannelType: "im", Text: "what's my leave balance?", TimeStamp: "3.0"},
+		},
+	})
+
+	require.Len(t, api.posted, 1)
+	assert.Equal(t, postedMessage{channel: "D1", text: "12 days"}, api.posted[0])
+}
+
+func TestSlack_UnknownWorkspace(t *testing.T) {
+	s, api, _ := newTestSlack(t)
+
+	s.handleEventsAPIEvent(context.Background(), slackevents.EventsAPIEvent{
+		TeamID: "T999",
+		InnerEvent: slackevents.EventsAPIInnerEvent{
+			Data: &slackevents.AppMentionEvent{User: "U1", Channel: "C1", Text: "<@UBOT> hi", TimeStamp: "1.0"},
+		},
+	})
+
+	require.Len(t, api.posted, 1)
+	assert.Contains(t, api.posted[0].text, "not yet configured")
+}
+
+func TestSlack_SlashCommandUsesAssistant(t *testing.T) {
+	s, _, client := newTestSlack(t)
+
+	var responses []*goslack.WebhookMessage
+	s.postWebhook = func(_ context.Context, url string, msg *goslack.WebhookMessage) error {
+		assert.Equal(t, "https://hooks.slack.com/commands/1", url)
+		responses = append(responses, msg)
+		return nil
+	}
+
+	expectCompletion(client, "25 days a year", func(req openai.ChatCompletionRequest) {
+		var prompts []string
+		for _, m := range req.Messages {
+			if m.Role == openai.ChatMessageRoleSystem {
+				prompts = append(prompts, m.Content)
+			}
+		}
+		assert.Contains(t, prompts, "You answer HR questions")
+	})
+
+	s.handleSlashCommand(context.Background(), goslack.SlashCommand{
+		TeamID:      "T123",
+		Command:     "/ask-hr",
+		Text:        "how much leave do I get?",
+		UserID:      "U1",
+		ResponseURL: "https://hooks.slack.com/commands/1",
+	})
+
+	require.Len(t, responses, 1)
+	assert.Equal(t, goslack.ResponseTypeInChannel, responses[0].ResponseType)
+	assert.Contains(t, responses[0].Text, "25 days a year")
+
+	// Commands that aren't in the config
+	s.handleSlashCommand(context.Background(), goslack.SlashCommand{
+		TeamID:      "T123",
+		Command:     "/ask-it",
+		Text:        "printer?",
+		ResponseURL: "https://hooks.slack.com/commands/1",
+	})
+
+	require.Len(t, responses, 2)
+	assert.Equal(t, goslack.ResponseTypeEphemeral, responses[1].ResponseType)
+}
+
+func TestValidate(t *testing.T) {
+	cfg := &types.AppHelixConfig{
+		Assistants: []types.AssistantConfig{{Name: "general"}, {Name: "hr"}},
+	}
+
+	cfg.Triggers = []types.Trigger{{Slack: &types.SlackTrigger{
+		TeamID:   "T123",
+		Commands: []types.SlackCommand{{Command: "/ask-hr", Assistant: "hr"}, {Command: "/ask", Assistant: "0"}},
+	}}}
+	require.NoError(t, Validate(cfg))
+
+	cfg.Triggers = []types.Trigger{{Slack: &types.SlackTrigger{}}}
+	assert.ErrorContains(t, Validate(cfg), "team_id")
+
+	cfg.Triggers = []types.Trigger{{Slack: &types.SlackTrigger{
+		TeamID:   "T123",
+		Commands: []types.SlackCommand{{Command: "ask-hr"}},
+	}}}
+	assert.ErrorContains(t, Validate(cfg), "must start with /")
+
+	cfg.Triggers = []types.Trigger{{Slack: &types.SlackTrigger{
+		TeamID:   "T123",
+		Commands: []types.SlackCommand{{Command: "/ask-it", Assistant: "it"}},
+	}}}
+	assert.ErrorContains(t, Validate(cfg), "does not exist")
+}
+
+func slackApp(id, owner, teamID string, created time.Time) *types.App {
+	app := &types.App{ID: id, Owner: owner, Created: created}
+	app.Config.Helix.Triggers = []types.Trigger{{Slack: &types.SlackTrigger{TeamID: teamID}}}
+	return app
+}
+
+func TestBindWorkspaces_FirstAppKeepsWorkspace(t *testing.T) {
+	first := slackApp("app_1", "user_1", "T123", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	later := slackApp("app_2", "user_2", "T123", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))
+	other := slackApp("app_3", "user_2", "T456", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))
+
+	// The later app is listed last, where it used to win
+	apps := bindWorkspaces([]*types.App{later, other, first})
+
+	assert.Equal(t, "app_1", apps["T123"].ID)
+	assert.Equal(t, "app_3", apps["T456"].ID)
+}
+
+func TestValidateWorkspace(t *testing.T) {
+	bound := slackApp("app_1", "user_1", "T123", time.Time{})
+
+	assert.ErrorContains(t, ValidateWorkspace(slackApp("app_2", "user_2", "T123", time.Time{}), []*types.App{bound}), "already bound to another app")
+	// Saving the app that's bound is fine
+	assert.NoError(t, ValidateWorkspace(slackApp("app_1", "user_1", "T123", time.Time{}), []*types.App{bound}))
+	assert.NoError(t, ValidateWorkspace(slackApp("app_2", "user_2", "T456", time.Time{}), []*types.App{bound}))
+}
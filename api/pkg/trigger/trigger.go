@@ -10,6 +10,9 @@ import (
 	"github.com/helixml/helix/api/pkg/store"
 	"github.com/helixml/helix/api/pkg/trigger/cron"
 	"github.com/helixml/helix/api/pkg/trigger/discord"
+	"github.com/helixml/helix/api/pkg/trigger/email"
+	"github.com/helixml/helix/api/pkg/trigger/event"
+	"github.com/helixml/helix/api/pkg/trigger/slack"
 
 	"github.com/rs/zerolog/log"
 )
@@ -41,6 +44,30 @@ func (t *Manager) Start(ctx context.Context) {
 		}()
 	}
 
+	if t.cfg.Triggers.Slack.Enabled && t.cfg.Triggers.Slack.BotToken != "" && t.cfg.Triggers.Slack.AppToken != "" {
+		t.wg.Add(1)
+		go func() {
+			defer t.wg.Done()
+			t.runSlack(ctx)
+		}()
+	}
+
+	if t.cfg.Triggers.Email.Enabled {
+		t.wg.Add(1)
+		go func() {
+			defer t.wg.Done()
+			t.runEmail(ctx)
+		}()
+	}
+
+	if t.cfg.Triggers.Event.Enabled {
+		t.wg.Add(1)
+		go func() {
+			defer t.wg.Done()
+			t.runEvents(ctx)
+		}()
+	}
+
 	t.wg.Add(1)
 	go func() {
 		defer t.wg.Done()
@@ -67,6 +94,23 @@ func (t *Manager) runDiscord(ctx context.Context) {
 	}
 }
 
+func (t *Manager) runSlack(ctx context.Context) {
+	slackTrigger := slack.New(t.cfg, t.store, t.controller)
+
+	for {
+		err := slackTrigger.Start(ctx)
+		if err != nil {
+			log.Err(err).Msg("failed to start Slack trigger, retrying in 10 seconds")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(10 * time.Second):
+		}
+	}
+}
+
 func (t *Manager) runCron(ctx context.Context) {
 	cronTrigger, err := cron.New(t.cfg, t.store, t.controller)
 	if err != nil {
@@ -87,3 +131,45 @@ func (t *Manager) runCron(ctx context.Context) {
 		}
 	}
 }
+
+func (t *Manager) runEmail(ctx context.Context) {
+	emailTrigger, err := email.New(t.cfg, t.store, t.controller)
+	if err != nil {
+		log.Err(err).Msg("failed to create email trigger")
+		return
+	}
+
+	for {
+		err := emailTrigger.Start(ctx)
+		if err != nil {
+			log.Err(err).Msg("failed to start email trigger, retrying in 10 seconds")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(10 * time.Second):
+		}
+	}
+}
+
+func (t *Manager) runEvents(ctx context.Context) {
+	eventTrigger, err := event.New(t.cfg, t.store, t.controller)
+	if err != nil {
+		log.Err(err).Msg("failed to create event trigger")
+		return
+	}
+
+	for {
+		err := eventTrigger.Start(ctx)
+		if err != nil {
+			log.Err(err).Msg("failed to start event trigger, retrying in 10 seconds")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(10 * time.Second):
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+
+	"github.com/helixml/helix/api/pkg/config"
+	"github.com/helixml/helix/api/pkg/controller"
+	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/trigger/run"
+	"github.com/helixml/helix/api/pkg/trigger/sink"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+const (
+	// DefaultSignatureHeader has the HMAC-SHA256 of the request body, as sha256=<hex>
+	DefaultSignatureHeader = "X-Helix-Signature-256"
+
+	// maxPayloadSize is the largest request body that is accepted
+	maxPayloadSize = 1 << 20
+)
+
+var nameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// Webhook runs apps when their webhook trigger's URL is called. Requests are verified with the
+// trigger's secret, either by the signature of the body or by the token in the signed URL.
+type Webhook struct {
+	cfg        *config.ServerConfig
+	store      store.Store
+	controller *controller.Controller
+	runner     *run.Runner
+}
+
+func New(cfg *config.ServerConfig, store store.Store, controller *controller.Controller) (*Webhook, error) {
+	sinks, err := sink.New(cfg, controller.Options.Notifier, controller.Options.Authenticator, controller.Options.Filestore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sinks: %w", err)
+	}
+
+	return &Webhook{
+		cfg:        cfg,
+		store:      store,
+		controller: controller,
+		runner:     run.New(store, controller, sinks),
+	}, nil
+}
+
+// ServeHTTP handles POST /api/v1/webhooks/{app_id}/{name}
+func (w *Webhook) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+
+	app, trigger, err := w.getTrigger(ctx, vars["app_id"], vars["name"])
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(rw, "webhook not found", http.StatusNotFound)
+			return
+		}
+		log.Error().
+			Err(err).
+			Str("app_id", vars["app_id"]).
+			Msg("failed to load webhook trigger")
+		http.Error(rw, "failed to load webhook trigger", http.StatusInternalServerError)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPayloadSize+1))
+	if err != nil {
+		http.Error(rw, fmt.Sprintf("failed to read body: %s", err), http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxPayloadSize {
+		http.Error(rw, "payload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if !verify(r, app.ID, trigger, body) {
+		http.Error(rw, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	input, err := RenderInput(trigger.Template, body)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sinks := trigger.Sinks
+	if trigger.CallbackURL != "" {
+		sinks = append([]types.TriggerSink{{Webhook: &types.TriggerSinkWebhook{URL: trigger.CallbackURL}}}, sinks...)
+	}
+
+	req := &run.Request{
+		Type:        types.TriggerTypeWebhook,
+		SessionName: fmt.Sprintf("Webhook %s %s", trigger.Name, time.Now().Format(time.DateTime)),
+		Input:       input,
+		Sinks:       sinks,
+		Workflow:    trigger.Workflow,
+	}
+
+	if trigger.Async {
+		writeRun(rw, http.StatusAccepted, w.runner.Start(ctx, app, req))
+		return
+	}
+
+	result := w.runner.Run(ctx, app, req)
+
+	status := http.StatusOK
+	if result.Status != types.TriggerRunStatusSuccess {
+		status = http.StatusBadGateway
+	}
+
+	writeRun(rw, status, result)
+}
+
+// getTrigger loads the app and its webhook trigger, with the owner's secrets filled in
+func (w *Webhook) getTrigger(ctx context.Context, appID, name string) (*types.App, *types.WebhookTrigger, error) {
+	app, err := w.store.GetAppWithTools(ctx, appID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	app, err = w.controller.EvaluateSecrets(ctx, &types.User{ID: app.Owner}, app)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	trigger, ok := FindTrigger(app, name)
+	if !ok {
+		return nil, nil, store.ErrNotFound
+	}
+
+	return app, trigger, nil
+}
+
+// FindTrigger returns the app's webhook trigger with the name
+func FindTrigger(app *types.App, name string) (*types.WebhookTrigger, bool) {
+	for _, trigger := range app.Config.Helix.Triggers {
+		if trigger.Webhook != nil && trigger.Webhook.Name == name {
+			return trigger.Webhook, true
+		}
+	}
+	return nil, false
+}
+
+func writeRun(rw http.ResponseWriter, status int, run *types.TriggerRun) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+
+	if err := json.NewEncoder(rw).Encode(run); err != nil {
+		log.Error().Err(err).Msg("failed to write trigger run")
+	}
+}
+
+// verify checks the signature of the body when the request has one, otherwise the token of the
+// signed URL
+func verify(r *http.Request, appID string, trigger *types.WebhookTrigger, body []byte) bool {
+	if !secretSet(trigger.Secret) {
+		return false
+	}
+
+	header := trigger.SignatureHeader
+	if header == "" {
+		header = DefaultSignatureHeader
+	}
+
+	if signature := r.Header.Get(header); signature != "" {
+		signature = strings.TrimPrefix(signature, "sha256=")
+		return hmac.Equal([]byte(signature), []byte(sign(trigger.Secret, body)))
+	}
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		return false
+	}
+
+	return hmac.Equal([]byte(token), []byte(sign(trigger.Secret, []byte(tokenMessage(appID, trigger.Name)))))
+}
+
+// secretSet is whether the owner's secrets filled in the secret. A reference to a secret the owner
+// doesn't have is left as it is, e.g. ${ALERTMANAGER_WEBHOOK_SECRET}, and anyone reading the app's
+// config could sign with it.
+func secretSet(secret string) bool {
+	return secret != "" && !strings.Contains(secret, "${")
+}
+
+// sign returns the hex encoded HMAC-SHA256 of the message
+func sign(secret string, message []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(message)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func tokenMessage(appID, name string) string {
+	return appID + "/" + name
+}
+
+// SignedURL is the URL of the app's webhook trigger with a token signed by the trigger's secret,
+// for callers that can't sign the body
+func SignedURL(baseURL, appID string, trigger *types.WebhookTrigger) string {
+	return fmt.Sprintf("%s/api/v1/webhooks/%s/%s?token=%s",
+		strings.TrimSuffix(baseURL, "/"),
+		url.PathEscape(appID),
+		url.PathEscape(trigger.Name),
+		sign(trigger.Secret, []byte(tokenMessage(appID, trigger.Name))),
+	)
+}
+
+// RenderInput turns the payload into the prompt with the template. The template gets the decoded
+// JSON payload, or the body as a string when it isn't JSON.
+func RenderInput(tmpl string, body []byte) (string, error) {
+	if tmpl == "" {
+		return string(body), nil
+	}
+
+	t, err := ParseTemplate(tmpl)
+	if err != nil {
+		return "", err
+	}
+
+	var payload interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		payload = string(body)
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, payload); err != nil {
+		return "", fmt.Errorf("failed to render template: %w", err)
+	}
+
+	return buf.String(), nil
+}
+
+// ParseTemplate parses an input template, templates can use the json function
+func ParseTemplate(tmpl string) (*template.Template, error) {
+	t, err := template.New("webhook").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			bts, err := json.Marshal(v)
+			return string(bts), err
+		},
+	}).Option("missingkey=zero").Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	return t, nil
+}
+
+// Validate checks the app's webhook triggers, their email sinks can only send to the recipients
+func Validate(triggers []types.Trigger, recipients sink.Recipients) error {
+	names := make(map[string]bool)
+
+	for _, trigger := range triggers {
+		if trigger.Webhook == nil {
+			continue
+		}
+		webhook := trigger.Webhook
+
+		if !nameRegexp.MatchString(webhook.Name) {
+			return fmt.Errorf("webhook trigger name '%s' must only have letters, numbers, dashes and underscores", webhook.Name)
+		}
+		if names[webhook.Name] {
+			return fmt.Errorf("webhook trigger name '%s' is used more than once", webhook.Name)
+		}
+		names[webhook.Name] = true
+
+		if webhook.Secret == "" {
+			return fmt.Errorf("webhook trigger '%s' needs a secret", webhook.Name)
+		}
+
+		if webhook.Template != "" {
+			if _, err := ParseTemplate(webhook.Template); err != nil {
+				return fmt.Errorf("webhook trigger '%s': %w", webhook.Name, err)
+			}
+		}
+
+		if webhook.CallbackURL != "" {
+			u, err := url.Parse(webhook.CallbackURL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("webhook trigger '%s': callback_url must be an http or https URL", webhook.Name)
+			}
+		}
+
+		if err := sink.Validate(webhook.Sinks, recipients); err != nil {
+			return fmt.Errorf("webhook trigger '%s': %w", webhook.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// ValidateSecrets checks that the app's webhook triggers have their secrets, the triggers have to
+// have the owner's secrets filled in
+func ValidateSecrets(triggers []types.Trigger) error {
+	for _, trigger := range triggers {
+		if trigger.Webhook != nil && !secretSet(trigger.Webhook.Secret) {
+			return fmt.Errorf("webhook trigger '%s' secret isn't set, add the secret it refers to", trigger.Webhook.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,322 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/helixml/helix/api/pkg/config"
+	"github.com/helixml/helix/api/pkg/controller"
+	"github.com/helixml/helix/api/pkg/extract"
+	"github.com/helixml/helix/api/pkg/filestore"
+	"github.com/helixml/helix/api/pkg/janitor"
+	oai "github.com/helixml/helix/api/pkg/openai"
+	"github.com/helixml/helix/api/pkg/openai/manager"
+	"github.com/helixml/helix/api/pkg/pubsub"
+	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/trigger/sink"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+const alertTemplate = `Investigate these alerts:
+{{ range .alerts }}- {{ .labels.alertname }} on {{ .labels.instance }}
+{{ end }}`
+
+func newTestWebhook(t *testing.T, trigger *types.WebhookTrigger) (*httptest.Server, *store.MockStore, *oai.MockClient) {
+	return newTestWebhookWithSecrets(t, trigger, []*types.Secret{
+		{Name: "ALERTS_SECRET", Value: []byte("s3cret")},
+	})
+}
+
+// newTestWebhookWithSecrets serves the webhook of an app whose owner has the secrets
+func newTestWebhookWithSecrets(t *testing.T, trigger *types.WebhookTrigger, secrets []*types.Secret) (*httptest.Server, *store.MockStore, *oai.MockClient) {
+	ctrl := gomock.NewController(t)
+	st := store.NewMockStore(ctrl)
+	client := oai.NewMockClient(ctrl)
+
+	providerManager := manager.NewMockProviderManager(ctrl)
+	providerManager.EXPECT().GetClient(gomock.Any(), gomock.Any()).Return(client, nil).AnyTimes()
+
+	ps, err := pubsub.New(t.TempDir())
+	require.NoError(t, err)
+
+	cfg := &config.ServerConfig{}
+	cfg.Inference.Provider = types.ProviderTogetherAI
+
+	c, err := controller.NewController(context.Background(), controller.Options{
+		Config:          cfg,
+		Store:           st,
+		Janitor:         janitor.NewJanitor(config.Janitor{}),
+		ProviderManager: providerManager,
+		Filestore:       filestore.NewMockFileStore(ctrl),
+		Extractor:       extract.NewMockExtractor(ctrl),
+		PubSub:          ps,
+	})
+	require.NoError(t, err)
+
+	wh, err := New(cfg, st, c)
+	require.NoError(t, err)
+
+	app := &types.App{ID: "app_1", Owner: "user_1"}
+	app.Config.Helix.Name = "on-call"
+	app.Config.Helix.Assistants = []types.AssistantConfig{{SystemPrompt: "You help the on-call engineer"}}
+	app.Config.Helix.Triggers = []types.Trigger{{Webhook: trigger}}
+
+	st.EXPECT().GetAppWithTools(gomock.Any(), "app_1").Return(app, nil).AnyTimes()
+	st.EXPECT().ListSecrets(gomock.Any(), &store.ListSecretsQuery{Owner: "user_1"}).Return(secrets, nil).AnyTimes()
+
+	router := mux.NewRouter()
+	router.Handle("/api/v1/webhooks/{app_id}/{name}", wh).Methods(http.MethodPost)
+
+	ts := httptest.NewServer(router)
+	t.Cleanup(ts.Close)
+
+	return ts, st, client
+}
+
+// expectRun expects a run to be recorded, the channel is closed once it's finished
+func expectRun(st *store.MockStore) chan struct{} {
+	done := make(chan struct{})
+	st.EXPECT().CreateTriggerRun(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, run *types.TriggerRun) (*types.TriggerRun, error) {
+		return run, nil
+	})
+	st.EXPECT().UpdateSession(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, session types.Session) (*types.Session, error) {
+		return &session, nil
+	}).Times(2)
+	st.EXPECT().UpdateTriggerRun(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, run *types.TriggerRun) (*types.TriggerRun, error) {
+		close(done)
+		return run, nil
+	})
+	return done
+}
+
+func TestWebhook_SignedBody(t *testing.T) {
+	ts, st, client := newTestWebhook(t, &types.WebhookTrigger{
+		Name:     "alertmanager",
+		Secret:   "${ALERTS_SECRET}",
+		Template: alertTemplate,
+	})
+
+	expectRun(st)
+
+	client.EXPECT().CreateChatCompletion(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+			assert.Equal(t, "Investigate these alerts:\n- HighLatency on api-1\n", req.Messages[len(req.Messages)-1].Content)
+			return openai.ChatCompletionResponse{
+				Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: "Restart api-1"}}},
+			}, nil
+		})
+
+	body := `{"alerts": [{"labels": {"alertname": "HighLatency", "instance": "api-1"}}]}`
+
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/api/v1/webhooks/app_1/alertmanager", strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set(DefaultSignatureHeader, "sha256="+sign("s3cret", []byte(body)))
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var run types.TriggerRun
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&run))
+	assert.Equal(t, types.TriggerTypeWebhook, run.Type)
+	assert.Equal(t, types.TriggerRunStatusSuccess, run.Status)
+	assert.Equal(t, "Restart api-1", run.Output)
+}
+
+func TestWebhook_SignedURL(t *testing.T) {
+	trigger := &types.WebhookTrigger{Name: "jira", Secret: "s3cret"}
+	ts, st, client := newTestWebhook(t, trigger)
+
+	expectRun(st)
+
+	client.EXPECT().CreateChatCompletion(gomock.Any(), gomock.Any()).Return(openai.ChatCompletionResponse{
+		Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: "Triaged"}}},
+	}, nil)
+
+	resp, err := http.Post(SignedURL(ts.URL, "app_1", trigger), "application/json", strings.NewReader(`{"issue": "HELIX-1"}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestWebhook_InvalidSignature(t *testing.T) {
+	trigger := &types.WebhookTrigger{Name: "github", Secret: "s3cret", SignatureHeader: "X-Hub-Signature-256"}
+	ts, _, _ := newTestWebhook(t, trigger)
+
+	body := `{"action": "opened"}`
+
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/api/v1/webhooks/app_1/github", strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("X-Hub-Signature-256", "sha256="+sign("wrong", []byte(body)))
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// Neither a signature nor a token
+	resp, err = http.Post(ts.URL+"/api/v1/webhooks/app_1/github", "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestWebhook_OwnerWithoutSecrets(t *testing.T) {
+	trigger := &types.WebhookTrigger{Name: "alertmanager", Secret: "${ALERTS_SECRET}"}
+	ts, _, _ := newTestWebhookWithSecrets(t, trigger, nil)
+
+	body := `{"alerts": []}`
+
+	// Signed with the reference itself, which is public in the app's config
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/api/v1/webhooks/app_1/alertmanager", strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set(DefaultSignatureHeader, "sha256="+sign("${ALERTS_SECRET}", []byte(body)))
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp, err = http.Post(SignedURL(ts.URL, "app_1", trigger), "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestWebhook_UnknownTrigger(t *testing.T) {
+	ts, _, _ := newTestWebhook(t, &types.WebhookTrigger{Name: "jira", Secret: "s3cret"})
+
+	resp, err := http.Post(ts.URL+"/api/v1/webhooks/app_1/github", "application/json", strings.NewReader(`{}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestWebhook_AsyncCallback(t *testing.T) {
+	callbacks := make(chan types.TriggerRun, 1)
+	callback := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		var run types.TriggerRun
+		_ = json.NewDecoder(r.Body).Decode(&run)
+		callbacks <- run
+	}))
+	defer callback.Close()
+
+	trigger := &types.WebhookTrigger{Name: "jira", Secret: "s3cret", Async: true, CallbackURL: callback.URL}
+	ts, st, client := newTestWebhook(t, trigger)
+
+	done := expectRun(st)
+
+	client.EXPECT().CreateChatCompletion(gomock.Any(), gomock.Any()).Return(openai.ChatCompletionResponse{
+		Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: "Triaged"}}},
+	}, nil)
+
+	resp, err := http.Post(SignedURL(ts.URL, "app_1", trigger), "application/json", strings.NewReader(`{"issue": "HELIX-1"}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	var started types.TriggerRun
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&started))
+	assert.Equal(t, types.TriggerRunStatusRunning, started.Status)
+
+	run := <-callbacks
+	assert.Equal(t, started.ID, run.ID)
+	assert.Equal(t, "Triaged", run.Output)
+
+	<-done
+}
+
+func TestRenderInput(t *testing.T) {
+	input, err := RenderInput("", []byte(`{"a": 1}`))
+	require.NoError(t, err)
+	assert.Equal(t, `{"a": 1}`, input)
+
+	input, err = RenderInput(`Issue {{ .issue.key }}: {{ json .issue.fields }}`, []byte(`{"issue": {"key": "HELIX-1", "fields": {"summary": "Broken"}}}`))
+	require.NoError(t, err)
+	assert.Equal(t, `Issue HELIX-1: {"summary":"Broken"}`, input)
+
+	input, err = RenderInput(`Got: {{ . }}`, []byte(`not json`))
+	require.NoError(t, err)
+	assert.Equal(t, `Got: not json`, input)
+}
+
+func TestValidate(t *testing.T) {
+	valid := &types.WebhookTrigger{Name: "alertmanager", Secret: "s3cret", Template: alertTemplate}
+	require.NoError(t, Validate([]types.Trigger{{Webhook: valid}}, sink.Recipients{}))
+
+	tests := []struct {
+		name     string
+		triggers []types.Trigger
+		wantErr  string
+	}{
+		{
+			name:     "invalid name",
+			triggers: []types.Trigger{{Webhook: &types.WebhookTrigger{Name: "alert manager", Secret: "s3cret"}}},
+			wantErr:  "must only have",
+		},
+		{
+			name:     "duplicate name",
+			triggers: []types.Trigger{{Webhook: valid}, {Webhook: valid}},
+			wantErr:  "more than once",
+		},
+		{
+			name:     "no secret",
+			triggers: []types.Trigger{{Webhook: &types.WebhookTrigger{Name: "jira"}}},
+			wantErr:  "needs a secret",
+		},
+		{
+			name:     "invalid template",
+			triggers: []types.Trigger{{Webhook: &types.WebhookTrigger{Name: "jira", Secret: "s3cret", Template: "{{ .issue"}}},
+			wantErr:  "invalid template",
+		},
+		{
+			name: "email sink to anyone",
+			triggers: []types.Trigger{{Webhook: &types.WebhookTrigger{Name: "jira", Secret: "s3cret", Sinks: []types.TriggerSink{
+				{Email: &types.TriggerSinkEmail{To: []string{"someone@elsewhere.com"}}},
+			}}}},
+			wantErr: "can't be sent",
+		},
+		{
+			name:     "invalid callback",
+			triggers: []types.Trigger{{Webhook: &types.WebhookTrigger{Name: "jira", Secret: "s3cret", CallbackURL: "ftp://example.com"}}},
+			wantErr:  "callback_url",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.triggers, sink.Recipients{Domains: []string{"example.com"}})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestValidateSecrets(t *testing.T) {
+	require.NoError(t, ValidateSecrets([]types.Trigger{{Webhook: &types.WebhookTrigger{Name: "jira", Secret: "s3cret"}}}))
+
+	for _, secret := range []string{"", "${JIRA_SECRET}", "prefix-${JIRA_SECRET}"} {
+		err := ValidateSecrets([]types.Trigger{{Webhook: &types.WebhookTrigger{Name: "jira", Secret: secret}}})
+		require.Error(t, err, secret)
+		assert.Contains(t, err.Error(), "secret isn't set")
+	}
+}
@@ -0,0 +1,70 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	AppEnvironmentDev        = "dev"
+	AppEnvironmentStaging    = "staging"
+	AppEnvironmentProduction = "prod"
+)
+
+// AppEnvironmentPipeline is the order that revisions are promoted in, by default a revision is
+// promoted from the environment before the target
+var AppEnvironmentPipeline = []string{AppEnvironmentDev, AppEnvironmentStaging, AppEnvironmentProduction}
+
+// AppEnvironment pins an app to a revision for the API keys that belong to it, so that changes
+// can be tested before they are promoted to production. The dev environment follows the latest
+// revision unless it's pinned too.
+type AppEnvironment struct {
+	ID      string    `json:"id" gorm:"primaryKey"`
+	AppID   string    `json:"app_id" gorm:"uniqueIndex:idx_app_environment"`
+	Name    string    `json:"name" gorm:"uniqueIndex:idx_app_environment"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+
+	// Revision of the app's config that is served, zero follows the latest revision
+	Revision int `json:"revision"`
+
+	// Secrets override the owner's secrets with the same name
+	Secrets AppEnvironmentSecrets `json:"secrets" gorm:"jsonb"`
+
+	// PromotedFrom is the environment the revision was promoted from
+	PromotedFrom string `json:"promoted_from"`
+	UpdatedBy    string `json:"updated_by"`
+}
+
+type AppEnvironmentSecrets map[string]string
+
+func (s AppEnvironmentSecrets) Value() (driver.Value, error) {
+	j, err := json.Marshal(s)
+	return j, err
+}
+
+func (s *AppEnvironmentSecrets) Scan(src interface{}) error {
+	source, ok := src.([]byte)
+	if !ok {
+		return errors.New("type assertion .([]byte) failed")
+	}
+	var result AppEnvironmentSecrets
+	if err := json.Unmarshal(source, &result); err != nil {
+		return err
+	}
+	*s = result
+	return nil
+}
+
+func (AppEnvironmentSecrets) GormDataType() string {
+	return "json"
+}
+
+// PromoteAppEnvironmentRequest copies a revision to an environment, either the revision that
+// another environment serves or a given one
+type PromoteAppEnvironmentRequest struct {
+	From     string `json:"from"`
+	Revision int    `json:"revision"`
+}
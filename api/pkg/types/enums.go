@@ -133,6 +133,7 @@ const (
 	WebsocketEventWorkerTaskResponse WebsocketEventType = "worker_task_response"
 	WebsocketLLMInferenceResponse    WebsocketEventType = "llm_inference_response"
 	WebsocketEventProcessingStepInfo WebsocketEventType = "step_info" // Helix tool use, rag search, etc
+	WebsocketEventToolApproval       WebsocketEventType = "tool_approval"
 )
 
 type WorkerTaskResponseType string
@@ -210,7 +211,7 @@ const (
 	InferenceRuntimeOllama    InferenceRuntime = "ollama"
 	InferenceRuntimeCog       InferenceRuntime = "cog"
 	InferenceRuntimeDiffusers InferenceRuntime = "diffusers"
-	// TODO: vllm
+	InferenceRuntimeVLLM      InferenceRuntime = "vllm"
 )
 
 func ValidateRuntime(runtime string) InferenceRuntime {
@@ -219,6 +220,8 @@ func ValidateRuntime(runtime string) InferenceRuntime {
 		return InferenceRuntimeAxolotl
 	case string(InferenceRuntimeOllama):
 		return InferenceRuntimeOllama
+	case string(InferenceRuntimeVLLM):
+		return InferenceRuntimeVLLM
 	default:
 		return ""
 	}
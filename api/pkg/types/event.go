@@ -0,0 +1,98 @@
+package types
+
+import "time"
+
+// EventType is an internal event that apps can be triggered by
+type EventType string
+
+const (
+	EventTypeKnowledgeReady    EventType = "knowledge.ready"
+	EventTypeKnowledgeError    EventType = "knowledge.error"
+	EventTypeSessionError      EventType = "session.error"
+	EventTypeFinetuneCompleted EventType = "finetune.completed"
+)
+
+// EventTypes are the events that can be subscribed to
+var EventTypes = []EventType{
+	EventTypeKnowledgeReady,
+	EventTypeKnowledgeError,
+	EventTypeSessionError,
+	EventTypeFinetuneCompleted,
+}
+
+// Event is published when something happens to a user's knowledge or sessions, only the
+// resource the event is about is set
+type Event struct {
+	Type    EventType `json:"type"`
+	Created time.Time `json:"created"`
+	Owner   string    `json:"owner"`
+	// AppID is the app that the knowledge or session belongs to, if any
+	AppID string `json:"app_id,omitempty"`
+
+	Knowledge *EventKnowledge `json:"knowledge,omitempty"`
+	Session   *EventSession   `json:"session,omitempty"`
+}
+
+type EventKnowledge struct {
+	ID      string         `json:"id"`
+	Name    string         `json:"name"`
+	State   KnowledgeState `json:"state"`
+	Message string         `json:"message,omitempty"`
+	Version string         `json:"version,omitempty"`
+	Size    int64          `json:"size,omitempty"`
+	// URLs are the pages that were crawled for web knowledge
+	URLs []string `json:"urls,omitempty"`
+}
+
+type EventSession struct {
+	ID        string      `json:"id"`
+	Name      string      `json:"name"`
+	Mode      SessionMode `json:"mode"`
+	ModelName string      `json:"model_name"`
+	Error     string      `json:"error,omitempty"`
+	// LoraDir is where the fine-tuned adapter is stored
+	LoraDir string `json:"lora_dir,omitempty"`
+}
+
+// NewKnowledgeEvent is the event about the knowledge in its current state
+func NewKnowledgeEvent(eventType EventType, k *Knowledge) *Event {
+	knowledge := &EventKnowledge{
+		ID:      k.ID,
+		Name:    k.Name,
+		State:   k.State,
+		Message: k.Message,
+		Version: k.Version,
+		Size:    k.Size,
+	}
+	if k.CrawledSources != nil {
+		for _, u := range k.CrawledSources.URLs {
+			knowledge.URLs = append(knowledge.URLs, u.URL)
+		}
+	}
+
+	return &Event{
+		Type:      eventType,
+		Created:   time.Now(),
+		Owner:     k.Owner,
+		AppID:     k.AppID,
+		Knowledge: knowledge,
+	}
+}
+
+// NewSessionEvent is the event about the session, errMessage is set for errors
+func NewSessionEvent(eventType EventType, session *Session, errMessage string) *Event {
+	return &Event{
+		Type:    eventType,
+		Created: time.Now(),
+		Owner:   session.Owner,
+		AppID:   session.ParentApp,
+		Session: &EventSession{
+			ID:        session.ID,
+			Name:      session.Name,
+			Mode:      session.Mode,
+			ModelName: session.ModelName,
+			Error:     errMessage,
+			LoraDir:   session.LoraDir,
+		},
+	}
+}
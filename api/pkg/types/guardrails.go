@@ -0,0 +1,125 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+type GuardrailType string
+
+const (
+	// GuardrailTypePII detects emails, phone numbers and card numbers
+	GuardrailTypePII GuardrailType = "pii"
+	// GuardrailTypePromptInjection detects instructions aimed at the model in tool responses and
+	// knowledge
+	GuardrailTypePromptInjection GuardrailType = "prompt_injection"
+	// GuardrailTypeTopics keeps the conversation to the allowed topics and away from the denied ones
+	GuardrailTypeTopics GuardrailType = "topics"
+	// GuardrailTypeClassifier asks a model whether the message breaks the policy
+	GuardrailTypeClassifier GuardrailType = "classifier"
+)
+
+type GuardrailAction string
+
+const (
+	// GuardrailActionBlock answers with the guardrail's message instead, tool responses and
+	// knowledge are withheld from the model
+	GuardrailActionBlock GuardrailAction = "block"
+	// GuardrailActionRedact removes what was detected and carries on
+	GuardrailActionRedact GuardrailAction = "redact"
+	// GuardrailActionFlag only records the outcome
+	GuardrailActionFlag GuardrailAction = "flag"
+)
+
+type GuardrailStage string
+
+const (
+	// GuardrailStageInput checks the user's message before anything else runs, and the earlier
+	// messages when the client sent them
+	GuardrailStageInput GuardrailStage = "input"
+	// GuardrailStageContext checks tool responses and knowledge before the model reads them
+	GuardrailStageContext GuardrailStage = "context"
+	// GuardrailStageOutput checks the answer before the user gets it
+	GuardrailStageOutput GuardrailStage = "output"
+)
+
+type PIIType string
+
+const (
+	PIITypeEmail PIIType = "email"
+	PIITypePhone PIIType = "phone"
+	PIITypeCard  PIIType = "card"
+)
+
+// Guardrail is a check that the assistant's messages go through on their way to and from the model
+type Guardrail struct {
+	Type GuardrailType `json:"type" yaml:"type"`
+	// Action defaults to redact for pii and to block for the others
+	Action GuardrailAction `json:"action,omitempty" yaml:"action,omitempty"`
+	// Stages default to input and output for pii, context for prompt_injection and input for the
+	// others
+	Stages []GuardrailStage `json:"stages,omitempty" yaml:"stages,omitempty"`
+	// Message is the answer when the guardrail blocks, defaults to a polite refusal. The model
+	// reads it instead of a withheld tool response or knowledge, which defaults to a neutral note.
+	Message string `json:"message,omitempty" yaml:"message,omitempty"`
+
+	// PII is what the pii guardrail detects, defaults to all of it
+	PII []PIIType `json:"pii,omitempty" yaml:"pii,omitempty"`
+
+	// Allow and Deny are the topics guardrail's words and phrases. Messages have to mention one of
+	// the allowed topics, when there are any, and none of the denied ones.
+	Allow []string `json:"allow,omitempty" yaml:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty" yaml:"deny,omitempty"`
+
+	// Policy tells the classifier what isn't allowed
+	Policy string `json:"policy,omitempty" yaml:"policy,omitempty"`
+	// Provider and Model run the classifier, default to the assistant's
+	Provider Provider `json:"provider,omitempty" yaml:"provider,omitempty"`
+	Model    string   `json:"model,omitempty" yaml:"model,omitempty"`
+}
+
+// GuardrailOutcome records that a guardrail caught something
+type GuardrailOutcome struct {
+	ID            string    `json:"id" gorm:"primaryKey"`
+	Created       time.Time `json:"created"`
+	AppID         string    `json:"app_id" gorm:"index"`
+	UserID        string    `json:"user_id"`
+	SessionID     string    `json:"session_id" gorm:"index"`
+	InteractionID string    `json:"interaction_id"`
+	// LLMCallID is the call that the guardrail checked the request or the answer of, blocked
+	// requests never reach the model so they don't have one
+	LLMCallID string          `json:"llm_call_id" gorm:"index"`
+	Stage     GuardrailStage  `json:"stage"`
+	Type      GuardrailType   `json:"type"`
+	Action    GuardrailAction `json:"action"`
+	// Source is the tool or the knowledge that the checked content came from
+	Source string `json:"source,omitempty"`
+	// Findings describe what was caught, without repeating it
+	Findings GuardrailFindings `json:"findings" gorm:"jsonb"`
+}
+
+type GuardrailFindings []string
+
+func (f GuardrailFindings) Value() (driver.Value, error) {
+	j, err := json.Marshal(f)
+	return j, err
+}
+
+func (f *GuardrailFindings) Scan(src interface{}) error {
+	source, ok := src.([]byte)
+	if !ok {
+		return errors.New("type assertion .([]byte) failed")
+	}
+	var result GuardrailFindings
+	if err := json.Unmarshal(source, &result); err != nil {
+		return err
+	}
+	*f = result
+	return nil
+}
+
+func (GuardrailFindings) GormDataType() string {
+	return "json"
+}
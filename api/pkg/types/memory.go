@@ -0,0 +1,29 @@
+package types
+
+import "time"
+
+// AssistantMemory makes the assistant remember facts about each user and their preferences across
+// sessions. Memories are extracted from the user's conversations with the assistant and the ones
+// that are relevant to the conversation are added to the system prompt. Only users who signed in
+// have memories, not callers with the app's API keys nor the app's triggers.
+type AssistantMemory struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// ResultsCount is how many memories are added to the system prompt, defaults to 5
+	ResultsCount int `json:"results_count,omitempty" yaml:"results_count,omitempty"`
+}
+
+// Memory is a fact about a user, or one of their preferences, that the app's assistants remember
+type Memory struct {
+	ID      string    `json:"id" gorm:"primaryKey"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+	AppID   string    `json:"app_id" gorm:"index"`
+	UserID  string    `json:"user_id" gorm:"index"`
+	// SessionID is the session that the memory was extracted from
+	SessionID string `json:"session_id,omitempty"`
+	Content   string `json:"content"`
+}
+
+type UpdateMemoryRequest struct {
+	Content string `json:"content"`
+}
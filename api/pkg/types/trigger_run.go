@@ -0,0 +1,116 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+type TriggerType string
+
+const (
+	TriggerTypeCron    TriggerType = "cron"
+	TriggerTypeWebhook TriggerType = "webhook"
+	TriggerTypeEmail   TriggerType = "email"
+	TriggerTypeEvent   TriggerType = "event"
+	TriggerTypeDiscord TriggerType = "discord"
+)
+
+type TriggerRunStatus string
+
+const (
+	TriggerRunStatusRunning TriggerRunStatus = "running"
+	TriggerRunStatusSuccess TriggerRunStatus = "success"
+	TriggerRunStatusFailed  TriggerRunStatus = "failed"
+)
+
+// TriggerRun is an execution of an app's trigger. The conversation is kept in the session, along
+// with the LLM calls it made.
+type TriggerRun struct {
+	ID       string           `json:"id" gorm:"primaryKey"`
+	AppID    string           `json:"app_id" gorm:"index"`
+	Type     TriggerType      `json:"type" gorm:"index"`
+	Status   TriggerRunStatus `json:"status"`
+	Started  time.Time        `json:"started"`
+	Finished time.Time        `json:"finished"`
+
+	SessionID string `json:"session_id"`
+	// ThreadID is the conversation that the run continues, e.g. an email thread. Runs of the
+	// same thread share the session.
+	ThreadID string `json:"thread_id,omitempty" gorm:"index"`
+	Input    string `json:"input"`
+	Output   string `json:"output"`
+	Error    string `json:"error"`
+	// WorkflowRunID is the run of the workflow that the trigger ran, if it ran one
+	WorkflowRunID string `json:"workflow_run_id,omitempty"`
+
+	Deliveries TriggerRunDeliveries `json:"deliveries" gorm:"jsonb"`
+	// Sinks are where the output goes, they're kept so that a workflow run that is resumed after
+	// its server stopped is delivered too. They aren't returned as webhook sinks can have
+	// credentials in their headers.
+	Sinks TriggerSinks `json:"-" gorm:"jsonb"`
+
+	// LLMCalls are filled in when a single run is requested
+	LLMCalls []*LLMCall `json:"llm_calls,omitempty" gorm:"-"`
+}
+
+// TriggerRunDelivery is the outcome of delivering the run's output to a sink
+type TriggerRunDelivery struct {
+	// Sink is the type of the sink, e.g. webhook
+	Sink  string `json:"sink"`
+	Error string `json:"error,omitempty"`
+}
+
+type TriggerRunDeliveries []TriggerRunDelivery
+
+func (d TriggerRunDeliveries) Value() (driver.Value, error) {
+	j, err := json.Marshal(d)
+	return j, err
+}
+
+func (d *TriggerRunDeliveries) Scan(src interface{}) error {
+	source, ok := src.([]byte)
+	if !ok {
+		return errors.New("type assertion .([]byte) failed")
+	}
+	var result TriggerRunDeliveries
+	if err := json.Unmarshal(source, &result); err != nil {
+		return err
+	}
+	*d = result
+	return nil
+}
+
+func (TriggerRunDeliveries) GormDataType() string {
+	return "json"
+}
+
+type TriggerSinks []TriggerSink
+
+func (s TriggerSinks) Value() (driver.Value, error) {
+	j, err := json.Marshal(s)
+	return j, err
+}
+
+func (s *TriggerSinks) Scan(src interface{}) error {
+	// Runs from before the sinks were kept don't have them
+	if src == nil {
+		*s = nil
+		return nil
+	}
+	source, ok := src.([]byte)
+	if !ok {
+		return errors.New("type assertion .([]byte) failed")
+	}
+	var result TriggerSinks
+	if err := json.Unmarshal(source, &result); err != nil {
+		return err
+	}
+	*s = result
+	return nil
+}
+
+func (TriggerSinks) GormDataType() string {
+	return "json"
+}
@@ -62,6 +62,9 @@ type Interaction struct {
 	ToolCallID string `json:"tool_call_id,omitempty"`
 
 	Usage Usage `json:"usage"`
+
+	// Pinned messages are always sent to the model, however long the session gets
+	Pinned bool `json:"pinned,omitempty"`
 }
 
 type ResponseFormatType string
@@ -222,6 +225,21 @@ type SessionMetadata struct {
 	// which assistant are we talking to?
 	AssistantID    string            `json:"assistant_id"`
 	AppQueryParams map[string]string `json:"app_query_params"` // Passing through user defined app params
+	// revision of the app that served the session
+	AppRevision int `json:"app_revision"`
+	// the experiment and the variant of it that served the session
+	Experiment        string `json:"experiment,omitempty"`
+	ExperimentVariant string `json:"experiment_variant,omitempty"`
+	// the rolling summary of the older interactions, for assistants that summarise long sessions
+	ConversationSummary *ConversationSummary `json:"conversation_summary,omitempty"`
+}
+
+// ConversationSummary stands in for a session's older interactions when they're sent to the model
+type ConversationSummary struct {
+	Content string `json:"content"`
+	// Interactions is how many of the session's first interactions the summary covers
+	Interactions int       `json:"interactions"`
+	Updated      time.Time `json:"updated"`
 }
 
 // the packet we put a list of sessions into so pagination is supported and we know the total amount
@@ -446,6 +464,10 @@ type SessionMetaUpdate struct {
 	OwnerType OwnerType `json:"owner_type"`
 }
 
+type PinInteractionRequest struct {
+	Pinned bool `json:"pinned"`
+}
+
 type SessionFilterModel struct {
 	Mode      SessionMode `json:"mode"`
 	ModelName string      `json:"model_name"`
@@ -476,6 +498,23 @@ func (d *Duration) UnmarshalJSON(b []byte) error {
 	}
 }
 
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return time.Duration(d).String(), nil
+}
+
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value string
+	if err := unmarshal(&value); err != nil {
+		return err
+	}
+	tmp, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(tmp)
+	return nil
+}
+
 type SessionFilter struct {
 	// e.g. inference, finetune
 	Mode SessionMode `json:"mode"`
@@ -514,6 +553,8 @@ type APIKey struct {
 	Name      string          `json:"name"`
 	Type      APIKeyType      `json:"type" gorm:"default:api"`
 	AppID     *sql.NullString `json:"app_id"`
+	// Environment of the app that the key is served by, empty for the latest revision
+	Environment string `json:"environment"`
 }
 
 func (APIKey) TableName() string {
@@ -563,14 +604,18 @@ type User struct {
 	Admin bool
 	// if the token is associated with an app
 	AppID string
+	// the environment of the app that the token belongs to, if any
+	AppEnvironment string
 	// these are set by the keycloak user based on the token
 	// if it's an app token - the keycloak user is loaded from the owner of the app
 	// if it's a runner token - these values will be empty
-	ID       string
-	Type     OwnerType
-	Email    string
-	Username string
-	FullName string
+	ID    string
+	Type  OwnerType
+	Email string
+	// EmailVerified is set when the user has confirmed that the email address is theirs
+	EmailVerified bool
+	Username      string
+	FullName      string
 }
 
 // a single envelope that is broadcast to users
@@ -583,6 +628,7 @@ type WebsocketEvent struct {
 	WorkerTaskResponse *RunnerTaskResponse         `json:"worker_task_response"`
 	InferenceResponse  *RunnerLLMInferenceResponse `json:"inference_response"`
 	StepInfo           *StepInfo                   `json:"step_info"`
+	ToolApproval       *ToolApproval               `json:"tool_approval,omitempty"`
 }
 
 type StepInfoType string
@@ -745,6 +791,31 @@ type RunnerState struct {
 	SchedulingDecisions []string              `json:"scheduling_decisions"`
 	Version             string                `json:"version"`
 	Slots               []RunnerActualSlot    `json:"slots"`
+	// CachedModels are models whose weights are on the runner's disk
+	CachedModels []string `json:"cached_models,omitempty"`
+	// CachedArtifacts are filestore paths, such as LoRA dirs, in the runner's artifact cache
+	CachedArtifacts []string `json:"cached_artifacts,omitempty"`
+	// MaintenanceState is set by the control plane, runners don't report it
+	MaintenanceState RunnerMaintenanceState `json:"maintenance_state,omitempty"`
+}
+
+// RunnerContentDigestTrailer is the HTTP trailer carrying the hex sha256 of a file or folder
+// downloaded by a runner, so that it can check the download is intact
+const RunnerContentDigestTrailer = "X-Helix-Content-Sha256"
+
+// RunnerMaintenanceState controls whether the scheduler places work on a runner
+type RunnerMaintenanceState string
+
+const (
+	RunnerMaintenanceStateActive   RunnerMaintenanceState = "active"   // Accepting new work
+	RunnerMaintenanceStateCordoned RunnerMaintenanceState = "cordoned" // No new slots, warm slots keep serving requests
+	RunnerMaintenanceStateDraining RunnerMaintenanceState = "draining" // No new work, slots are released as their work finishes
+	RunnerMaintenanceStateDrained  RunnerMaintenanceState = "drained"  // No slots left, safe to stop the runner
+)
+
+type RunnerMaintenanceStatus struct {
+	RunnerID string                 `json:"runner_id"`
+	State    RunnerMaintenanceState `json:"state"`
 }
 
 type DashboardData struct {
@@ -868,6 +939,7 @@ const (
 	ToolTypeAPI       ToolType = "api"
 	ToolTypeGPTScript ToolType = "gptscript"
 	ToolTypeZapier    ToolType = "zapier"
+	ToolTypeMCP       ToolType = "mcp"
 )
 
 type Tool struct {
@@ -889,6 +961,7 @@ type ToolConfig struct {
 	API       *ToolAPIConfig       `json:"api"`
 	GPTScript *ToolGPTScriptConfig `json:"gptscript"`
 	Zapier    *ToolZapierConfig    `json:"zapier"`
+	MCP       *ToolMCPConfig       `json:"mcp,omitempty"`
 }
 
 func (t ToolConfig) Value() (driver.Value, error) {
@@ -921,6 +994,15 @@ type ToolAPIConfig struct {
 	Headers map[string]string `json:"headers" yaml:"headers"` // Headers (authentication, etc)
 	Query   map[string]string `json:"query" yaml:"query"`     // Query parameters that will be always set
 
+	Auth *ToolAPIAuth `json:"auth,omitempty" yaml:"auth,omitempty"` // Credentials for the API's security scheme
+
+	// ActionPolicies decide, by action name, whether an action runs straight away, needs the
+	// user's approval or isn't allowed. Actions default to confirm unless they're GET requests
+	ActionPolicies map[string]ToolActionPolicy `json:"action_policies,omitempty" yaml:"action_policies,omitempty"`
+
+	// ResponseProcessing shapes, by action name, the responses that are given to the model
+	ResponseProcessing map[string]*ToolAPIResponseProcessing `json:"response_processing,omitempty" yaml:"response_processing,omitempty"`
+
 	RequestPrepTemplate     string `json:"request_prep_template" yaml:"request_prep_template"`         // Template for request preparation, leave empty for default
 	ResponseSuccessTemplate string `json:"response_success_template" yaml:"response_success_template"` // Template for successful response, leave empty for default
 	ResponseErrorTemplate   string `json:"response_error_template" yaml:"response_error_template"`     // Template for error response, leave empty for default
@@ -928,6 +1010,190 @@ type ToolAPIConfig struct {
 	Model string `json:"model" yaml:"model"`
 }
 
+// ToolAPIResponseProcessing shapes an action's responses so that large ones fit in the model's
+// context
+type ToolAPIResponseProcessing struct {
+	// Select projects JSON responses, either with a JSONPath expression ($.items[*].name) or a
+	// GJSON path (items.#.{name,url}). Responses that don't match are given to the model as is
+	Select string `json:"select,omitempty" yaml:"select,omitempty"`
+	// MaxBytes is the largest response given to the model as is, larger ones are summarised in
+	// chunks first. Defaults to 32KB, -1 turns summarisation off
+	MaxBytes int `json:"max_bytes,omitempty" yaml:"max_bytes,omitempty"`
+	// Pagination follows next pages and merges them into one response
+	Pagination *ToolAPIPagination `json:"pagination,omitempty" yaml:"pagination,omitempty"`
+	// CacheTTL caches successful GET responses for this long. Responses are only shared between
+	// requests with the same credentials
+	CacheTTL Duration `json:"cache_ttl,omitempty" yaml:"cache_ttl,omitempty"`
+}
+
+type ToolAPIPaginationType string
+
+const (
+	// ToolAPIPaginationTypeLink follows the rel="next" URL in the Link header
+	ToolAPIPaginationTypeLink ToolAPIPaginationType = "link"
+	// ToolAPIPaginationTypeCursor reads the next cursor from the response body and sends it back
+	// as a query parameter
+	ToolAPIPaginationTypeCursor ToolAPIPaginationType = "cursor"
+)
+
+type ToolAPIPagination struct {
+	Type        ToolAPIPaginationType `json:"type" yaml:"type"`
+	CursorPath  string                `json:"cursor_path,omitempty" yaml:"cursor_path,omitempty"`   // Where the next cursor is in the response, e.g. meta.next_cursor
+	CursorParam string                `json:"cursor_param,omitempty" yaml:"cursor_param,omitempty"` // Query parameter to send the cursor in
+	ItemsPath   string                `json:"items_path,omitempty" yaml:"items_path,omitempty"`     // Where the items are in each page, defaults to the page itself
+	MaxPages    int                   `json:"max_pages,omitempty" yaml:"max_pages,omitempty"`       // Defaults to 5
+}
+
+type ToolAPIAuthType string
+
+const (
+	// ToolAPIAuthTypeAPIKey sends a static key, either as an API key header or query parameter or
+	// as a bearer token, depending on the security scheme
+	ToolAPIAuthTypeAPIKey ToolAPIAuthType = "api_key"
+	// ToolAPIAuthTypeOAuth2ClientCredentials gets a token for the tool itself with the OAuth2
+	// client credentials grant
+	ToolAPIAuthTypeOAuth2ClientCredentials ToolAPIAuthType = "oauth2_client_credentials"
+	// ToolAPIAuthTypeOAuth2User acts on behalf of the Helix user, who links their account with the
+	// OAuth2 authorization code flow
+	ToolAPIAuthTypeOAuth2User ToolAPIAuthType = "oauth2_user"
+)
+
+// ToolAPIAuth configures how API tool requests are authenticated. Anything not set here is taken
+// from the securitySchemes in the OpenAPI spec. Secrets should be referenced as ${SECRET_NAME}
+// rather than written into helix.yaml.
+type ToolAPIAuth struct {
+	Type   ToolAPIAuthType `json:"type" yaml:"type"`
+	Scheme string          `json:"scheme,omitempty" yaml:"scheme,omitempty"` // Name of the security scheme in the spec, defaults to the first matching one
+
+	// API key
+	APIKey string `json:"api_key,omitempty" yaml:"api_key,omitempty"`
+	In     string `json:"in,omitempty" yaml:"in,omitempty"`     // header or query
+	Name   string `json:"name,omitempty" yaml:"name,omitempty"` // Header or query parameter name
+
+	// OAuth2
+	ClientID     string   `json:"client_id,omitempty" yaml:"client_id,omitempty"`
+	ClientSecret string   `json:"client_secret,omitempty" yaml:"client_secret,omitempty"`
+	AuthURL      string   `json:"auth_url,omitempty" yaml:"auth_url,omitempty"`
+	TokenURL     string   `json:"token_url,omitempty" yaml:"token_url,omitempty"`
+	Scopes       []string `json:"scopes,omitempty" yaml:"scopes,omitempty"`
+}
+
+// ToolOAuthToken is a user's linked account with an OAuth2 provider, used by API tools to act on
+// their behalf. Tokens are encrypted at rest.
+type ToolOAuthToken struct {
+	ID        string    `json:"id" gorm:"primaryKey"`
+	Created   time.Time `json:"created"`
+	Updated   time.Time `json:"updated"`
+	Owner     string    `json:"owner" gorm:"uniqueIndex:idx_tool_oauth_token"`
+	OwnerType OwnerType `json:"owner_type"`
+	// LinkedFor is the app, or the standalone tool, that the account was linked for. Only its tools
+	// get the token, other apps can't borrow it by copying the provider's token URL and client ID.
+	LinkedFor string `json:"linked_for" gorm:"uniqueIndex:idx_tool_oauth_token"`
+	// The provider is identified by its token URL and client ID so that the app's tools using the
+	// same OAuth2 app share the linked account
+	TokenURL string `json:"token_url" gorm:"uniqueIndex:idx_tool_oauth_token"`
+	ClientID string `json:"client_id" gorm:"uniqueIndex:idx_tool_oauth_token"`
+
+	AccessToken  []byte    `json:"-" gorm:"type:bytea"`
+	RefreshToken []byte    `json:"-" gorm:"type:bytea"`
+	TokenType    string    `json:"token_type"`
+	Expiry       time.Time `json:"expiry"`
+}
+
+type ToolActionPolicy string
+
+const (
+	ToolActionPolicyAuto    ToolActionPolicy = "auto"
+	ToolActionPolicyConfirm ToolActionPolicy = "confirm"
+	ToolActionPolicyDeny    ToolActionPolicy = "deny"
+)
+
+type ToolApprovalState string
+
+const (
+	ToolApprovalStatePending  ToolApprovalState = "pending"
+	ToolApprovalStateApproved ToolApprovalState = "approved"
+	ToolApprovalStateDenied   ToolApprovalState = "denied"
+	ToolApprovalStateExpired  ToolApprovalState = "expired"
+)
+
+// ToolApproval is an API tool call that waits for the user to approve it. Approvals are kept once
+// decided as an audit log, including actions denied by policy.
+type ToolApproval struct {
+	ID        string    `json:"id" gorm:"primaryKey"`
+	Created   time.Time `json:"created"`
+	Updated   time.Time `json:"updated"`
+	Owner     string    `json:"owner" gorm:"index"`
+	OwnerType OwnerType `json:"owner_type"`
+
+	// Where the tool comes from, so that it can be loaded again with the user's secrets
+	AppID       string `json:"app_id" gorm:"index"`
+	AssistantID string `json:"assistant_id"`
+	ToolID      string `json:"tool_id"`
+	ToolName    string `json:"tool_name"`
+	Action      string `json:"action"`
+
+	SessionID     string `json:"session_id" gorm:"index"`
+	InteractionID string `json:"interaction_id"`
+
+	Request ToolApprovalRequest `json:"request" gorm:"jsonb"`
+
+	State     ToolApprovalState `json:"state" gorm:"index"`
+	DecidedBy string            `json:"decided_by"` // User ID, or "policy"
+	DecidedAt time.Time         `json:"decided_at"`
+	Reason    string            `json:"reason"`
+
+	// Result of running the action once approved
+	Response string `json:"response"`
+	Error    string `json:"error"`
+}
+
+// ToolApprovalRequest is the request the user approves. Credentials aren't included, they're
+// added when the request is made.
+type ToolApprovalRequest struct {
+	Method      string                 `json:"method"`
+	URL         string                 `json:"url"`
+	Parameters  map[string]interface{} `json:"parameters"`
+	QueryParams map[string]string      `json:"query_params,omitempty"` // Per request overrides of the tool's query parameters
+	Model       string                 `json:"model,omitempty"`        // Model for interpreting the response
+	History     []*ToolHistoryMessage  `json:"history,omitempty"`
+	Raw         bool                   `json:"raw,omitempty"` // Return the API's response as is rather than have a model interpret it
+}
+
+func (t ToolApprovalRequest) Value() (driver.Value, error) {
+	j, err := json.Marshal(t)
+	return j, err
+}
+
+func (t *ToolApprovalRequest) Scan(src interface{}) error {
+	source, ok := src.([]byte)
+	if !ok {
+		return errors.New("type assertion .([]byte) failed")
+	}
+	var result ToolApprovalRequest
+	if err := json.Unmarshal(source, &result); err != nil {
+		return err
+	}
+	*t = result
+	return nil
+}
+
+func (ToolApprovalRequest) GormDataType() string {
+	return "json"
+}
+
+// ToolApprovalDecision approves or denies a pending tool approval
+type ToolApprovalDecision struct {
+	Approved bool   `json:"approved"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// ToolOAuthStatus tells the UI whether the user needs to link their account for an API tool
+type ToolOAuthStatus struct {
+	Connected   bool   `json:"connected"`
+	RedirectURL string `json:"redirect_url,omitempty"` // Where to send the user to link their account
+}
+
 // ToolApiConfig is parsed from the OpenAPI spec
 type ToolAPIAction struct {
 	Name        string `json:"name" yaml:"name"`
@@ -947,6 +1213,37 @@ type ToolZapierConfig struct {
 	MaxIterations int    `json:"max_iterations"`
 }
 
+// ToolMCPConfig connects to a Model Context Protocol server, either by running it as a command
+// and talking to it over stdio, or over SSE
+type ToolMCPConfig struct {
+	Command string            `json:"command,omitempty" yaml:"command,omitempty"`
+	Args    []string          `json:"args,omitempty" yaml:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+	URL     string            `json:"url,omitempty" yaml:"url,omitempty"` // SSE endpoint
+
+	Tools     []*ToolMCPTool     `json:"tools,omitempty" yaml:"tools,omitempty"`         // Read-only, discovered from the server
+	Resources []*ToolMCPResource `json:"resources,omitempty" yaml:"resources,omitempty"` // Read-only, discovered from the server
+
+	// ActionPolicies decide, by tool name, whether a tool runs straight away, needs the user's
+	// approval or isn't allowed. Tools default to confirm, reading resources is always allowed
+	ActionPolicies map[string]ToolActionPolicy `json:"action_policies,omitempty" yaml:"action_policies,omitempty"`
+
+	Model string `json:"model,omitempty" yaml:"model,omitempty"`
+}
+
+type ToolMCPTool struct {
+	Name        string                 `json:"name" yaml:"name"`
+	Description string                 `json:"description" yaml:"description"`
+	InputSchema map[string]interface{} `json:"input_schema,omitempty" yaml:"input_schema,omitempty"`
+}
+
+type ToolMCPResource struct {
+	URI         string `json:"uri" yaml:"uri"`
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	MIMEType    string `json:"mime_type,omitempty" yaml:"mime_type,omitempty"`
+}
+
 // SessionToolBinding used to add tools to sessions
 type SessionToolBinding struct {
 	SessionID string `gorm:"primaryKey;index"`
@@ -979,6 +1276,18 @@ type AssistantZapier struct {
 	MaxIterations int    `json:"max_iterations" yaml:"max_iterations"`
 }
 
+// AssistantMCP is a Model Context Protocol server whose tools and resources the assistant can use
+type AssistantMCP struct {
+	Name        string            `json:"name" yaml:"name"`
+	Description string            `json:"description" yaml:"description"`
+	Command     string            `json:"command,omitempty" yaml:"command,omitempty"`
+	Args        []string          `json:"args,omitempty" yaml:"args,omitempty"`
+	Env         map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+	URL         string            `json:"url,omitempty" yaml:"url,omitempty"`
+
+	ActionPolicies map[string]ToolActionPolicy `json:"action_policies,omitempty" yaml:"action_policies,omitempty"`
+}
+
 type AssistantAPI struct {
 	Name        string            `json:"name" yaml:"name"`
 	Description string            `json:"description" yaml:"description"`
@@ -986,6 +1295,10 @@ type AssistantAPI struct {
 	URL         string            `json:"url" yaml:"url"`
 	Headers     map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
 	Query       map[string]string `json:"query,omitempty" yaml:"query,omitempty"`
+	Auth        *ToolAPIAuth      `json:"auth,omitempty" yaml:"auth,omitempty"`
+
+	ActionPolicies     map[string]ToolActionPolicy           `json:"action_policies,omitempty" yaml:"action_policies,omitempty"`
+	ResponseProcessing map[string]*ToolAPIResponseProcessing `json:"response_processing,omitempty" yaml:"response_processing,omitempty"`
 
 	RequestPrepTemplate     string `json:"request_prep_template,omitempty" yaml:"request_prep_template,omitempty"`
 	ResponseSuccessTemplate string `json:"response_success_template,omitempty" yaml:"response_success_template,omitempty"`
@@ -1016,14 +1329,56 @@ type AssistantConfig struct {
 	APIs       []AssistantAPI       `json:"apis,omitempty" yaml:"apis,omitempty"`
 	GPTScripts []AssistantGPTScript `json:"gptscripts,omitempty" yaml:"gptscripts,omitempty"`
 	Zapier     []AssistantZapier    `json:"zapier,omitempty" yaml:"zapier,omitempty"`
+	MCPs       []AssistantMCP       `json:"mcps,omitempty" yaml:"mcps,omitempty"`
 	Tools      []*Tool              `json:"tools,omitempty" yaml:"tools,omitempty"`
 
+	ResponseSchema *AssistantResponseSchema `json:"response_schema,omitempty" yaml:"response_schema,omitempty"`
+
+	Memory *AssistantMemory `json:"memory,omitempty" yaml:"memory,omitempty"`
+
+	Context *AssistantContext `json:"context,omitempty" yaml:"context,omitempty"`
+
+	Guardrails []Guardrail `json:"guardrails,omitempty" yaml:"guardrails,omitempty"`
+
 	Tests []struct {
 		Name  string     `json:"name,omitempty" yaml:"name,omitempty"`
 		Steps []TestStep `json:"steps,omitempty" yaml:"steps,omitempty"`
 	} `json:"tests,omitempty" yaml:"tests,omitempty"`
 }
 
+// AssistantResponseSchema makes the assistant answer with JSON that matches a JSON Schema.
+// Responses are validated and the model is asked to fix the ones that don't match.
+type AssistantResponseSchema struct {
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// Schema is the JSON Schema, written as JSON or YAML
+	Schema string `json:"schema" yaml:"schema"`
+	// Retries is how many times the model gets to fix a response, defaults to 2
+	Retries int `json:"retries,omitempty" yaml:"retries,omitempty"`
+}
+
+type ContextStrategy string
+
+const (
+	// ContextStrategyFull sends the whole conversation
+	ContextStrategyFull ContextStrategy = "full"
+	// ContextStrategyTruncate leaves out the oldest messages that don't fit
+	ContextStrategyTruncate ContextStrategy = "truncate"
+	// ContextStrategySummarize replaces the oldest messages with a rolling summary of them
+	ContextStrategySummarize ContextStrategy = "summarize"
+)
+
+// AssistantContext decides which of a session's messages are sent to the model once the
+// conversation gets long. Pinned messages are always sent.
+type AssistantContext struct {
+	// Strategy defaults to full
+	Strategy ContextStrategy `json:"strategy,omitempty" yaml:"strategy,omitempty"`
+	// MaxTokens is the budget for the conversation's messages, defaults to half of the model's
+	// context length to leave room for the system prompt, knowledge and the answer
+	MaxTokens int `json:"max_tokens,omitempty" yaml:"max_tokens,omitempty"`
+	// KeepMessages is how many of the latest messages aren't summarised, defaults to 6
+	KeepMessages int `json:"keep_messages,omitempty" yaml:"keep_messages,omitempty"`
+}
+
 // Add this new type
 type TestStep struct {
 	Prompt         string `json:"prompt" yaml:"prompt"`
@@ -1038,6 +1393,45 @@ type AppHelixConfig struct {
 	ExternalURL string            `json:"external_url,omitempty" yaml:"external_url,omitempty"`
 	Assistants  []AssistantConfig `json:"assistants,omitempty" yaml:"assistants,omitempty"`
 	Triggers    []Trigger         `json:"triggers,omitempty" yaml:"triggers,omitempty"`
+	Experiments []Experiment      `json:"experiments,omitempty" yaml:"experiments,omitempty"`
+	Workflows   []Workflow        `json:"workflows,omitempty" yaml:"workflows,omitempty"`
+}
+
+// Experiment splits the requests for an assistant between variants, each user is always
+// served the same variant
+type Experiment struct {
+	Name string `json:"name" yaml:"name"`
+	// Assistant that the requests are for, by ID or index like assistant_id, defaults to the first one
+	Assistant string              `json:"assistant,omitempty" yaml:"assistant,omitempty"`
+	Variants  []ExperimentVariant `json:"variants" yaml:"variants"`
+}
+
+type ExperimentVariant struct {
+	ID string `json:"id" yaml:"id"`
+	// Assistant that serves the variant, by ID or index
+	Assistant string `json:"assistant" yaml:"assistant"`
+	// Weight is the percentage of users that get the variant, the weights add up to 100
+	Weight int `json:"weight" yaml:"weight"`
+}
+
+// ExperimentVariantReport is how a variant of an experiment performed
+type ExperimentVariantReport struct {
+	Variant string `json:"variant"`
+
+	LLMCalls         int64   `json:"llm_calls"`
+	AvgDurationMs    float64 `json:"avg_duration_ms"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	// AvgTotalTokens is the token cost of a call
+	AvgTotalTokens float64 `json:"avg_total_tokens"`
+
+	Sessions   int64 `json:"sessions"`
+	ThumbsUp   int64 `json:"thumbs_up"`
+	ThumbsDown int64 `json:"thumbs_down"`
+	// Average eval scores of the sessions that have them
+	AvgEvalManualScore    *float64 `json:"avg_eval_manual_score,omitempty"`
+	AvgEvalAutomaticScore *float64 `json:"avg_eval_automatic_score,omitempty"`
 }
 
 type AppHelixConfigMetadata struct {
@@ -1094,18 +1488,157 @@ func (AppConfig) GormDataType() string {
 	return "json"
 }
 
+// DiscordTrigger binds the app to a Discord server. Apps and their assistants can be routed to
+// channels and roles within the server, the most specific trigger answers: one for the channel
+// before one for the author's roles, before one for the whole server.
 type DiscordTrigger struct {
 	ServerName string `json:"server_name" yaml:"server_name"`
+	// Channels are the names or IDs of the channels the trigger answers in, threads belong to
+	// their parent channel
+	Channels []string `json:"channels,omitempty" yaml:"channels,omitempty"`
+	// Roles are the names or IDs of the roles the trigger answers, the author needs one of them
+	Roles []string `json:"roles,omitempty" yaml:"roles,omitempty"`
+	// Assistant is the name, ID or index of the assistant that answers, the first one by default
+	Assistant string `json:"assistant,omitempty" yaml:"assistant,omitempty"`
+}
+
+// SlackTrigger binds the app to a Slack workspace. The app answers mentions, direct messages and
+// replies in its threads, and slash commands run the assistant they're mapped to.
+type SlackTrigger struct {
+	// TeamID is the ID of the workspace, e.g. T0123ABCD
+	TeamID   string         `json:"team_id" yaml:"team_id"`
+	Commands []SlackCommand `json:"commands,omitempty" yaml:"commands,omitempty"`
+}
+
+type SlackCommand struct {
+	// Command is the slash command as configured in the Slack app, e.g. /ask-hr
+	Command string `json:"command" yaml:"command"`
+	// Assistant is the name, ID or index of the assistant that answers, the first one by default
+	Assistant string `json:"assistant,omitempty" yaml:"assistant,omitempty"`
 }
 
 type CronTrigger struct {
 	Schedule string `json:"schedule,omitempty"`
 	Input    string `json:"input,omitempty"`
+	// Workflow is the name of the app's workflow that runs instead of the assistant, the input
+	// is its inputs when it's a JSON object and its "input" input otherwise
+	Workflow string `json:"workflow,omitempty" yaml:"workflow,omitempty"`
+	// Sinks are where the output of each successful run is delivered
+	Sinks []TriggerSink `json:"sinks,omitempty" yaml:"sinks,omitempty"`
+}
+
+// WebhookTrigger runs the app when its URL is called, e.g. by Alertmanager, Jira or GitHub
+type WebhookTrigger struct {
+	// Name identifies the trigger in its URL, it's unique within the app
+	Name string `json:"name" yaml:"name"`
+	// Secret signs the URL and verifies the signature of the request body, it can reference the
+	// owner's secrets, e.g. ${ALERTMANAGER_WEBHOOK_SECRET}
+	Secret string `json:"secret" yaml:"secret"`
+	// SignatureHeader is the header with the HMAC-SHA256 of the body, X-Helix-Signature-256 by
+	// default. GitHub uses X-Hub-Signature-256.
+	SignatureHeader string `json:"signature_header,omitempty" yaml:"signature_header,omitempty"`
+	// Template is a Go template that turns the JSON payload into the prompt, the payload is
+	// used as it is when there isn't one
+	Template string `json:"template,omitempty" yaml:"template,omitempty"`
+	// Async responds straight away and delivers the output to the callback URL and sinks once
+	// the app has run
+	Async       bool   `json:"async,omitempty" yaml:"async,omitempty"`
+	CallbackURL string `json:"callback_url,omitempty" yaml:"callback_url,omitempty"`
+	// Workflow is the name of the app's workflow that runs instead of the assistant, the input
+	// is its inputs when it's a JSON object and its "input" input otherwise
+	Workflow string `json:"workflow,omitempty" yaml:"workflow,omitempty"`
+	// Sinks are where the output of each successful run is delivered
+	Sinks []TriggerSink `json:"sinks,omitempty" yaml:"sinks,omitempty"`
+}
+
+// EmailTrigger answers the mail sent to a support inbox. Each thread is a session, found by the
+// Message-ID and References headers, and the app's answer is sent as a reply.
+type EmailTrigger struct {
+	// Address is the inbox's address, mail sent to it over SMTP is routed to the app and replies
+	// are sent from it
+	Address string `json:"address" yaml:"address"`
+	// IMAP is the mailbox to poll for unread mail, mail is only accepted over SMTP without it
+	IMAP *EmailTriggerIMAP `json:"imap,omitempty" yaml:"imap,omitempty"`
+	// DraftOnly saves the replies as drafts for someone to review instead of sending them, they
+	// are kept in the IMAP drafts mailbox and the run history
+	DraftOnly bool `json:"draft_only,omitempty" yaml:"draft_only,omitempty"`
+}
+
+type EmailTriggerIMAP struct {
+	// Host is the IMAP server's host and port, e.g. imap.example.com:993
+	Host string `json:"host" yaml:"host"`
+	// Username and Password can reference the owner's secrets, e.g. ${SUPPORT_IMAP_PASSWORD}
+	Username string `json:"username" yaml:"username"`
+	Password string `json:"password" yaml:"password"`
+	// Mailbox is polled for unread mail, INBOX by default
+	Mailbox string `json:"mailbox,omitempty" yaml:"mailbox,omitempty"`
+	// DraftsMailbox is where drafts are saved, Drafts by default
+	DraftsMailbox string `json:"drafts_mailbox,omitempty" yaml:"drafts_mailbox,omitempty"`
+	// Insecure connects without TLS, e.g. to a local test server
+	Insecure bool `json:"insecure,omitempty" yaml:"insecure,omitempty"`
+}
+
+// EventTrigger runs the app when an event happens to the app's knowledge or sessions, or to the
+// owner's sessions that aren't part of an app, like fine-tunes
+type EventTrigger struct {
+	// Events are the event types that run the app, e.g. knowledge.ready
+	Events []EventType `json:"events" yaml:"events"`
+	// Knowledge only matches the events of the knowledge with this name or ID
+	Knowledge string `json:"knowledge,omitempty" yaml:"knowledge,omitempty"`
+	// Template is a Go template that turns the event into the prompt, the event's JSON is used
+	// when there isn't one
+	Template string `json:"template,omitempty" yaml:"template,omitempty"`
+	// Workflow is the name of the app's workflow that runs instead of the assistant, the input
+	// is its inputs when it's a JSON object and its "input" input otherwise
+	Workflow string `json:"workflow,omitempty" yaml:"workflow,omitempty"`
+	// Sinks are where the output of each successful run is delivered
+	Sinks []TriggerSink `json:"sinks,omitempty" yaml:"sinks,omitempty"`
+}
+
+// WebhookTriggerURL is the signed URL of the app's webhook trigger
+type WebhookTriggerURL struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// TriggerSink delivers the output of a trigger run, only one of the sinks is set
+type TriggerSink struct {
+	Webhook *TriggerSinkWebhook `json:"webhook,omitempty" yaml:"webhook,omitempty"`
+	Email   *TriggerSinkEmail   `json:"email,omitempty" yaml:"email,omitempty"`
+	Discord *TriggerSinkDiscord `json:"discord,omitempty" yaml:"discord,omitempty"`
+	File    *TriggerSinkFile    `json:"file,omitempty" yaml:"file,omitempty"`
+}
+
+// TriggerSinkWebhook posts the run, including its output, as JSON
+type TriggerSinkWebhook struct {
+	URL     string            `json:"url" yaml:"url"`
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+}
+
+type TriggerSinkEmail struct {
+	To []string `json:"to" yaml:"to"`
+	// Subject defaults to the app name
+	Subject string `json:"subject,omitempty" yaml:"subject,omitempty"`
+}
+
+// TriggerSinkDiscord posts the output to a channel with the Discord bot
+type TriggerSinkDiscord struct {
+	ChannelID string `json:"channel_id" yaml:"channel_id"`
+}
+
+// TriggerSinkFile writes the output to a markdown file in the app owner's filestore
+type TriggerSinkFile struct {
+	// Path is the folder to write to, defaults to triggers/<app id>
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
 }
 
 type Trigger struct {
 	Discord *DiscordTrigger `json:"discord,omitempty"`
+	Slack   *SlackTrigger   `json:"slack,omitempty" yaml:"slack,omitempty"`
 	Cron    *CronTrigger    `json:"cron,omitempty"`
+	Webhook *WebhookTrigger `json:"webhook,omitempty" yaml:"webhook,omitempty"`
+	Email   *EmailTrigger   `json:"email,omitempty" yaml:"email,omitempty"`
+	Event   *EventTrigger   `json:"event,omitempty" yaml:"event,omitempty"`
 }
 
 func (t Trigger) Value() (driver.Value, error) {
@@ -1166,6 +1699,47 @@ type App struct {
 	Global    bool      `json:"global"`
 	Shared    bool      `json:"shared"`
 	Config    AppConfig `json:"config" gorm:"jsonb"`
+	// Revision is the number of the app's latest revision, bumped on every update
+	Revision int `json:"revision"`
+	// UpdatedBy is the user that made the latest change
+	UpdatedBy string `json:"updated_by"`
+}
+
+// AppRevision is an immutable copy of the app's config, one is stored every time the app
+// is created or updated
+type AppRevision struct {
+	ID       string    `json:"id" gorm:"primaryKey"`
+	AppID    string    `json:"app_id" gorm:"uniqueIndex:idx_app_revision"`
+	Revision int       `json:"revision" gorm:"uniqueIndex:idx_app_revision"`
+	Created  time.Time `json:"created"`
+	// Author is the user that made the change
+	Author string    `json:"author"`
+	Config AppConfig `json:"config" gorm:"jsonb"`
+}
+
+type AppConfigChangeType string
+
+const (
+	AppConfigChangeAdded   AppConfigChangeType = "added"
+	AppConfigChangeRemoved AppConfigChangeType = "removed"
+	AppConfigChangeChanged AppConfigChangeType = "changed"
+)
+
+// AppConfigChange is a single difference between two revisions of an app's config, Path is
+// in the form of assistants[0].system_prompt
+type AppConfigChange struct {
+	Path string              `json:"path"`
+	Type AppConfigChangeType `json:"type"`
+	From interface{}         `json:"from,omitempty"`
+	To   interface{}         `json:"to,omitempty"`
+}
+
+// AppRevisionDiff is the difference between the helix config of two revisions of an app
+type AppRevisionDiff struct {
+	AppID   string             `json:"app_id"`
+	From    int                `json:"from"`
+	To      int                `json:"to"`
+	Changes []*AppConfigChange `json:"changes"`
 }
 
 type KeyPair struct {
@@ -1415,29 +1989,37 @@ const (
 	LLMCallStepIsActionable      LLMCallStep = "is_actionable"
 	LLMCallStepPrepareAPIRequest LLMCallStep = "prepare_api_request"
 	LLMCallStepInterpretResponse LLMCallStep = "interpret_response"
+	LLMCallStepSummariseResponse LLMCallStep = "summarise_response"
 	LLMCallStepGenerateTitle     LLMCallStep = "generate_title"
+	LLMCallStepSummariseSession  LLMCallStep = "summarise_session"
+	LLMCallStepGuardrail         LLMCallStep = "guardrail"
 )
 
 // LLMCall used to store the request and response of LLM calls
 // done by helix to LLM providers such as openai, togetherai or helix itself
 type LLMCall struct {
-	ID               string         `json:"id" gorm:"primaryKey"`
-	AppID            string         `json:"app_id" gorm:"index"`
-	UserID           string         `json:"user_id" gorm:"index"`
-	Created          time.Time      `json:"created"`
-	Updated          time.Time      `json:"updated"`
-	SessionID        string         `json:"session_id" gorm:"index"`
-	InteractionID    string         `json:"interaction_id" gorm:"index"`
-	Model            string         `json:"model"`
-	Provider         string         `json:"provider"`
-	Step             LLMCallStep    `json:"step" gorm:"index"`
-	OriginalRequest  datatypes.JSON `json:"original_request" gorm:"type:jsonb"`
-	Request          datatypes.JSON `json:"request" gorm:"type:jsonb"`
-	Response         datatypes.JSON `json:"response" gorm:"type:jsonb"`
-	DurationMs       int64          `json:"duration_ms"`
-	PromptTokens     int64
-	CompletionTokens int64
-	TotalTokens      int64
+	ID                string         `json:"id" gorm:"primaryKey"`
+	AppID             string         `json:"app_id" gorm:"index"`
+	UserID            string         `json:"user_id" gorm:"index"`
+	Created           time.Time      `json:"created"`
+	Updated           time.Time      `json:"updated"`
+	SessionID         string         `json:"session_id" gorm:"index"`
+	InteractionID     string         `json:"interaction_id" gorm:"index"`
+	AppRevision       int            `json:"app_revision"`
+	Experiment        string         `json:"experiment" gorm:"index"`
+	ExperimentVariant string         `json:"experiment_variant"`
+	Model             string         `json:"model"`
+	Provider          string         `json:"provider"`
+	Step              LLMCallStep    `json:"step" gorm:"index"`
+	OriginalRequest   datatypes.JSON `json:"original_request" gorm:"type:jsonb"`
+	Request           datatypes.JSON `json:"request" gorm:"type:jsonb"`
+	Response          datatypes.JSON `json:"response" gorm:"type:jsonb"`
+	DurationMs        int64          `json:"duration_ms"`
+	PromptTokens      int64
+	CompletionTokens  int64
+	TotalTokens       int64
+	// GuardrailOutcomes are what the app's guardrails caught in the request or the answer
+	GuardrailOutcomes []*GuardrailOutcome `json:"guardrail_outcomes,omitempty" gorm:"-"`
 }
 
 type CreateSecretRequest struct {
@@ -1458,7 +2040,8 @@ type Secret struct {
 }
 
 type GetDesiredRunnerSlotsResponse struct {
-	Data []DesiredRunnerSlot `json:"data"`
+	Data             []DesiredRunnerSlot    `json:"data"`
+	MaintenanceState RunnerMaintenanceState `json:"maintenance_state,omitempty"`
 }
 
 type DesiredSlots struct {
@@ -1479,9 +2062,11 @@ const (
 )
 
 type DesiredRunnerSlotAttributes struct {
-	Workload *RunnerWorkload `json:"workload,omitempty"`
-	Model    string          `json:"model"`
-	Mode     string          `json:"mode"`
+	Workload            *RunnerWorkload   `json:"workload,omitempty"`
+	Workloads           []*RunnerWorkload `json:"workloads,omitempty"`             // All work scheduled on the slot, including Workload, for batching
+	CancelledRequestIDs []string          `json:"cancelled_request_ids,omitempty"` // Work that the runner should stop
+	Model               string            `json:"model"`
+	Mode                string            `json:"mode"`
 }
 
 type RunnerWorkload struct {
@@ -1500,13 +2085,14 @@ type RunnerActualSlotAttributes struct {
 }
 
 type RunAPIActionRequest struct {
-	Action     string            `json:"action"`
-	Parameters map[string]string `json:"parameters"`
+	Action     string                 `json:"action"`
+	Parameters map[string]interface{} `json:"parameters"` // Path, query and header parameters and request body fields
 
 	Tool *Tool `json:"-"` // Set internally
 }
 
 type RunAPIActionResponse struct {
-	Response string `json:"response"` // Raw response from the API
-	Error    string `json:"error"`
+	Response   string `json:"response"` // Raw response from the API
+	Error      string `json:"error"`
+	ApprovalID string `json:"approval_id,omitempty"` // Set when the action waits for approval rather than running
 }
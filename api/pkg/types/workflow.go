@@ -0,0 +1,237 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Workflow chains the app's assistants and tools into steps with deterministic control flow. The
+// steps form a DAG: a step runs once the steps it needs have finished, and its templates get
+// the workflow's inputs and the outputs of the earlier steps.
+type Workflow struct {
+	// Name identifies the workflow within the app, it's used in the API and by triggers
+	Name        string          `json:"name" yaml:"name"`
+	Description string          `json:"description,omitempty" yaml:"description,omitempty"`
+	Inputs      []WorkflowInput `json:"inputs,omitempty" yaml:"inputs,omitempty"`
+	Steps       []WorkflowStep  `json:"steps" yaml:"steps"`
+	// Output is a Go template for the workflow's output, the output of the last step that ran
+	// by default
+	Output string `json:"output,omitempty" yaml:"output,omitempty"`
+}
+
+type WorkflowValueType string
+
+const (
+	WorkflowValueTypeString  WorkflowValueType = "string"
+	WorkflowValueTypeNumber  WorkflowValueType = "number"
+	WorkflowValueTypeBoolean WorkflowValueType = "boolean"
+	WorkflowValueTypeObject  WorkflowValueType = "object"
+	WorkflowValueTypeArray   WorkflowValueType = "array"
+)
+
+var WorkflowValueTypes = []WorkflowValueType{
+	WorkflowValueTypeString,
+	WorkflowValueTypeNumber,
+	WorkflowValueTypeBoolean,
+	WorkflowValueTypeObject,
+	WorkflowValueTypeArray,
+}
+
+// WorkflowInput is an input of the workflow, templates get it as {{ .inputs.<name> }}
+type WorkflowInput struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	// Type is string by default
+	Type     WorkflowValueType `json:"type,omitempty" yaml:"type,omitempty"`
+	Required bool              `json:"required,omitempty" yaml:"required,omitempty"`
+}
+
+// WorkflowStep is a step of the workflow, only one of the step types is set. Templates get the
+// step's output as {{ .steps.<name>.output }}.
+type WorkflowStep struct {
+	Name string `json:"name" yaml:"name"`
+	// Needs are the steps that finish before this one, the steps that the templates refer to
+	// are needed too. A step is skipped when all the steps it needs were skipped.
+	Needs []string `json:"needs,omitempty" yaml:"needs,omitempty"`
+	// Output is the type of the step's output, string by default. Other types are parsed from
+	// the JSON that the step returns.
+	Output WorkflowValueType `json:"output,omitempty" yaml:"output,omitempty"`
+
+	Assistant *WorkflowAssistantStep `json:"assistant,omitempty" yaml:"assistant,omitempty"`
+	API       *WorkflowAPIStep       `json:"api,omitempty" yaml:"api,omitempty"`
+	GPTScript *WorkflowGPTScriptStep `json:"gptscript,omitempty" yaml:"gptscript,omitempty"`
+	Knowledge *WorkflowKnowledgeStep `json:"knowledge,omitempty" yaml:"knowledge,omitempty"`
+	Branch    *WorkflowBranchStep    `json:"branch,omitempty" yaml:"branch,omitempty"`
+	Map       *WorkflowMapStep       `json:"map,omitempty" yaml:"map,omitempty"`
+}
+
+// WorkflowAssistantStep asks an assistant, with its knowledge and tools
+type WorkflowAssistantStep struct {
+	// Assistant is the name, ID or index of the assistant, the first one by default
+	Assistant string `json:"assistant,omitempty" yaml:"assistant,omitempty"`
+	// Prompt is a Go template
+	Prompt string `json:"prompt" yaml:"prompt"`
+}
+
+// WorkflowAPIStep calls an action of one of the assistant's APIs, without asking the LLM
+type WorkflowAPIStep struct {
+	Assistant string `json:"assistant,omitempty" yaml:"assistant,omitempty"`
+	// API is the name of the assistant's API
+	API string `json:"api" yaml:"api"`
+	// Action is the operation ID in the API's schema
+	Action string `json:"action" yaml:"action"`
+	// Parameters are Go templates for the path, query and header parameters and the body
+	// fields. Values that render to a JSON object or array are passed as JSON.
+	Parameters map[string]string `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+}
+
+// WorkflowGPTScriptStep runs one of the assistant's GPTScripts
+type WorkflowGPTScriptStep struct {
+	Assistant string `json:"assistant,omitempty" yaml:"assistant,omitempty"`
+	// Script is the name of the assistant's GPTScript
+	Script string `json:"script" yaml:"script"`
+	// Input is a Go template
+	Input string `json:"input,omitempty" yaml:"input,omitempty"`
+}
+
+// WorkflowKnowledgeStep searches the app's knowledge, its output is the list of results with
+// their content and source
+type WorkflowKnowledgeStep struct {
+	// Knowledge is the name of the app's knowledge
+	Knowledge string `json:"knowledge" yaml:"knowledge"`
+	// Query is a Go template
+	Query string `json:"query" yaml:"query"`
+	// Limit is the number of results, the knowledge's setting by default
+	Limit int `json:"limit,omitempty" yaml:"limit,omitempty"`
+}
+
+// WorkflowBranchStep runs one of two sets of steps. The steps of the other set are skipped, and
+// so are the steps that only need skipped steps.
+type WorkflowBranchStep struct {
+	// If is a Go template, the condition holds unless it renders to "", false, no or 0
+	If   string   `json:"if" yaml:"if"`
+	Then []string `json:"then,omitempty" yaml:"then,omitempty"`
+	Else []string `json:"else,omitempty" yaml:"else,omitempty"`
+}
+
+// WorkflowMapStep runs a step for each item of a list, its output is the list of the outputs
+type WorkflowMapStep struct {
+	// Over is a Go template that renders the list as JSON, e.g. {{ json .steps.split.output }}
+	Over string `json:"over" yaml:"over"`
+	// Step runs for each item, its templates get the item as {{ .item }} and its position as
+	// {{ .index }}. It can't be a branch.
+	Step *WorkflowStep `json:"step" yaml:"step"`
+}
+
+type WorkflowRunStatus string
+
+const (
+	WorkflowRunStatusRunning WorkflowRunStatus = "running"
+	WorkflowRunStatusSuccess WorkflowRunStatus = "success"
+	WorkflowRunStatusFailed  WorkflowRunStatus = "failed"
+)
+
+type WorkflowStepStatus string
+
+const (
+	WorkflowStepStatusPending WorkflowStepStatus = "pending"
+	WorkflowStepStatusRunning WorkflowStepStatus = "running"
+	WorkflowStepStatusSuccess WorkflowStepStatus = "success"
+	WorkflowStepStatusFailed  WorkflowStepStatus = "failed"
+	WorkflowStepStatusSkipped WorkflowStepStatus = "skipped"
+)
+
+// WorkflowRun is an execution of an app's workflow. It's saved after each step, so that a failed
+// or interrupted run can be resumed from the step that didn't finish.
+type WorkflowRun struct {
+	ID       string            `json:"id" gorm:"primaryKey"`
+	AppID    string            `json:"app_id" gorm:"index"`
+	Workflow string            `json:"workflow" gorm:"index"`
+	Status   WorkflowRunStatus `json:"status" gorm:"index"`
+	Started  time.Time         `json:"started"`
+	Updated  time.Time         `json:"updated"`
+	Finished time.Time         `json:"finished"`
+
+	// Trigger started the run, it's empty for runs started through the API
+	Trigger TriggerType `json:"trigger,omitempty"`
+	// TriggerRunID is the trigger run that is waiting for the run, it's finished with the run
+	// when the run is resumed after its server stopped
+	TriggerRunID string         `json:"trigger_run_id,omitempty"`
+	Inputs       WorkflowValues `json:"inputs" gorm:"jsonb"`
+	Output       string         `json:"output"`
+	Error        string         `json:"error"`
+
+	// Steps is the execution trace, with a run for each of the workflow's steps
+	Steps WorkflowStepRuns `json:"steps" gorm:"jsonb"`
+
+	// LLMCalls are filled in when a single run is requested
+	LLMCalls []*LLMCall `json:"llm_calls,omitempty" gorm:"-"`
+}
+
+// WorkflowStepRun is the execution of a step. Map steps have a run for each item.
+type WorkflowStepRun struct {
+	Name     string             `json:"name"`
+	Status   WorkflowStepStatus `json:"status"`
+	Started  time.Time          `json:"started,omitempty"`
+	Finished time.Time          `json:"finished,omitempty"`
+	// Input is what the step was asked, e.g. the rendered prompt
+	Input  string             `json:"input,omitempty"`
+	Output interface{}        `json:"output,omitempty"`
+	Error  string             `json:"error,omitempty"`
+	Items  []*WorkflowStepRun `json:"items,omitempty"`
+}
+
+// WorkflowRunRequest starts a workflow through the API
+type WorkflowRunRequest struct {
+	Inputs map[string]interface{} `json:"inputs"`
+}
+
+type WorkflowValues map[string]interface{}
+
+func (v WorkflowValues) Value() (driver.Value, error) {
+	j, err := json.Marshal(v)
+	return j, err
+}
+
+func (v *WorkflowValues) Scan(src interface{}) error {
+	source, ok := src.([]byte)
+	if !ok {
+		return errors.New("type assertion .([]byte) failed")
+	}
+	var result WorkflowValues
+	if err := json.Unmarshal(source, &result); err != nil {
+		return err
+	}
+	*v = result
+	return nil
+}
+
+func (WorkflowValues) GormDataType() string {
+	return "json"
+}
+
+type WorkflowStepRuns []*WorkflowStepRun
+
+func (s WorkflowStepRuns) Value() (driver.Value, error) {
+	j, err := json.Marshal(s)
+	return j, err
+}
+
+func (s *WorkflowStepRuns) Scan(src interface{}) error {
+	source, ok := src.([]byte)
+	if !ok {
+		return errors.New("type assertion .([]byte) failed")
+	}
+	var result WorkflowStepRuns
+	if err := json.Unmarshal(source, &result); err != nil {
+		return err
+	}
+	*s = result
+	return nil
+}
+
+func (WorkflowStepRuns) GormDataType() string {
+	return "json"
+}
@@ -0,0 +1,31 @@
+package split
+
+import "strings"
+
+// Message splits the message into parts of at most limit characters, for chat services that
+// limit the length of messages. It splits at the last line break of each part unless that would
+// leave the part less than half full, the line breaks between parts are dropped.
+func Message(message string, limit int) []string {
+	var parts []string
+
+	remaining := []rune(message)
+	for len(remaining) > limit {
+		cut := limit
+		if idx := strings.LastIndex(string(remaining[:limit]), "\n"); idx > 0 {
+			// The index is in bytes, count the runes before it
+			cut = len([]rune(string(remaining[:limit])[:idx]))
+		}
+		if cut < limit/2 {
+			cut = limit
+		}
+
+		parts = append(parts, string(remaining[:cut]))
+		remaining = []rune(strings.TrimLeft(string(remaining[cut:]), "\n"))
+	}
+
+	if len(remaining) > 0 {
+		parts = append(parts, string(remaining))
+	}
+
+	return parts
+}
@@ -0,0 +1,33 @@
+package split
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessage(t *testing.T) {
+	assert.Empty(t, Message("", 10))
+	assert.Equal(t, []string{"short"}, Message("short", 10))
+	assert.Equal(t, []string{"line one", "line two"}, Message("line one\nline two", 10))
+	assert.Equal(t, []string{strings.Repeat("a", 10), "aaa"}, Message(strings.Repeat("a", 13), 10))
+
+	long := strings.Repeat("a", 1500) + "\n" + strings.Repeat("b", 1500)
+	parts := Message(long, 2000)
+	require.Len(t, parts, 2)
+	assert.Equal(t, strings.Repeat("a", 1500), parts[0])
+	assert.Equal(t, strings.Repeat("b", 1500), parts[1])
+
+	// A line break near the start would leave the part almost empty
+	parts = Message("a\n"+strings.Repeat("b", 20), 10)
+	assert.Equal(t, []string{"a\nbbbbbbbb", strings.Repeat("b", 10), "bb"}, parts)
+
+	parts = Message(strings.Repeat("é", 4500), 2000)
+	require.Len(t, parts, 3)
+	for _, part := range parts {
+		assert.LessOrEqual(t, utf8.RuneCountInString(part), 2000)
+	}
+}
@@ -0,0 +1,307 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+
+	"github.com/helixml/helix/api/pkg/controller"
+	"github.com/helixml/helix/api/pkg/data"
+	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/tools"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+// runStep runs the step with the template values and returns its output. The step's input is
+// recorded in its trace.
+func (x *execution) runStep(ctx context.Context, step *types.WorkflowStep, values map[string]interface{}, stepRun *types.WorkflowStepRun) (interface{}, error) {
+	switch {
+	case step.Assistant != nil:
+		return x.runAssistant(ctx, step, values, stepRun)
+	case step.API != nil:
+		return x.runAPI(ctx, step, values, stepRun)
+	case step.GPTScript != nil:
+		return x.runGPTScript(ctx, step, values, stepRun)
+	case step.Knowledge != nil:
+		return x.runKnowledge(ctx, step, values, stepRun)
+	case step.Branch != nil:
+		condition, err := render(step.Branch.If, values)
+		if err != nil {
+			return nil, err
+		}
+		stepRun.Input = condition
+		return truthy(condition), nil
+	case step.Map != nil:
+		return x.runMap(ctx, step, values, stepRun)
+	}
+
+	return nil, errors.New("step has no type")
+}
+
+func (x *execution) runAssistant(ctx context.Context, step *types.WorkflowStep, values map[string]interface{}, stepRun *types.WorkflowStepRun) (interface{}, error) {
+	assistantID, ok := data.ResolveAssistant(x.app, step.Assistant.Assistant)
+	if !ok {
+		return nil, fmt.Errorf("assistant '%s' not found", step.Assistant.Assistant)
+	}
+
+	prompt, err := render(step.Assistant.Prompt, values)
+	if err != nil {
+		return nil, err
+	}
+	stepRun.Input = prompt
+
+	resp, _, err := x.engine.controller.ChatCompletion(ctx, x.user, openai.ChatCompletionRequest{
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: prompt,
+			},
+		},
+	}, &controller.ChatCompletionOptions{
+		AppID:       x.app.ID,
+		AssistantID: assistantID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Choices) == 0 {
+		return nil, errors.New("no data in the LLM response")
+	}
+
+	return parseOutput(step.Output, resp.Choices[0].Message.Content)
+}
+
+func (x *execution) runAPI(ctx context.Context, step *types.WorkflowStep, values map[string]interface{}, stepRun *types.WorkflowStepRun) (interface{}, error) {
+	tool, err := x.tool(step.API.Assistant, types.ToolTypeAPI, step.API.API)
+	if err != nil {
+		return nil, err
+	}
+
+	parameters := make(map[string]interface{})
+	for name, tmpl := range step.API.Parameters {
+		value, err := render(tmpl, values)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %s: %w", name, err)
+		}
+		parameters[name] = jsonOrString(value)
+	}
+
+	input, err := json.Marshal(parameters)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal parameters: %w", err)
+	}
+	stepRun.Input = string(input)
+
+	resp, err := x.engine.controller.ToolsPlanner.RunAPIActionWithParameters(ctx, &types.RunAPIActionRequest{
+		Action:     step.API.Action,
+		Parameters: parameters,
+		Tool:       tool,
+	})
+	if err != nil {
+		if errors.Is(err, tools.ErrActionNeedsApproval) {
+			return nil, fmt.Errorf("%w, but workflows have no one to ask: set the action's policy to auto to run it here", err)
+		}
+		return nil, err
+	}
+
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+
+	return parseOutput(step.Output, resp.Response)
+}
+
+func (x *execution) runGPTScript(ctx context.Context, step *types.WorkflowStep, values map[string]interface{}, stepRun *types.WorkflowStepRun) (interface{}, error) {
+	tool, err := x.tool(step.GPTScript.Assistant, types.ToolTypeGPTScript, step.GPTScript.Script)
+	if err != nil {
+		return nil, err
+	}
+
+	executor := x.engine.controller.Options.GPTScriptExecutor
+	if executor == nil {
+		return nil, errors.New("GPTScript isn't configured")
+	}
+
+	input, err := render(step.GPTScript.Input, values)
+	if err != nil {
+		return nil, err
+	}
+	stepRun.Input = input
+
+	result, err := executor.ExecuteScript(ctx, &types.GptScript{
+		Source: tool.Config.GPTScript.Script,
+		URL:    tool.Config.GPTScript.ScriptURL,
+		Input:  input,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to run gptscript: %w", err)
+	}
+
+	if result.Error != "" {
+		return nil, fmt.Errorf("failed to run gptscript: %s", result.Error)
+	}
+
+	return parseOutput(step.Output, result.Output)
+}
+
+func (x *execution) runKnowledge(ctx context.Context, step *types.WorkflowStep, values map[string]interface{}, stepRun *types.WorkflowStepRun) (interface{}, error) {
+	query, err := render(step.Knowledge.Query, values)
+	if err != nil {
+		return nil, err
+	}
+	stepRun.Input = query
+
+	knowledge, err := x.engine.store.LookupKnowledge(ctx, &store.LookupKnowledgeQuery{
+		Name:  step.Knowledge.Knowledge,
+		AppID: x.app.ID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting knowledge: %w", err)
+	}
+
+	// Content knowledge has nothing to search, it's the only result
+	if knowledge.Source.Content != nil {
+		return []interface{}{
+			map[string]interface{}{
+				"content": *knowledge.Source.Content,
+				"source":  knowledge.Name,
+			},
+		}, nil
+	}
+
+	ragClient, err := x.engine.controller.GetRagClient(ctx, knowledge)
+	if err != nil {
+		return nil, fmt.Errorf("error getting RAG client: %w", err)
+	}
+
+	limit := step.Knowledge.Limit
+	if limit == 0 {
+		limit = knowledge.RAGSettings.ResultsCount
+	}
+
+	results, err := ragClient.Query(ctx, &types.SessionRAGQuery{
+		Prompt:            query,
+		DataEntityID:      knowledge.GetDataEntityID(),
+		DistanceThreshold: knowledge.RAGSettings.Threshold,
+		DistanceFunction:  knowledge.RAGSettings.DistanceFunction,
+		MaxResults:        limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error querying RAG: %w", err)
+	}
+
+	output := make([]interface{}, 0, len(results))
+	for _, result := range results {
+		output = append(output, map[string]interface{}{
+			"content":     result.Content,
+			"source":      result.Source,
+			"document_id": result.DocumentID,
+		})
+	}
+
+	return output, nil
+}
+
+// runMap runs the map's step for each item. Items are traced by their position, the items that
+// succeeded aren't run again when the run is resumed.
+func (x *execution) runMap(ctx context.Context, step *types.WorkflowStep, values map[string]interface{}, stepRun *types.WorkflowStepRun) (interface{}, error) {
+	over, err := render(step.Map.Over, values)
+	if err != nil {
+		return nil, err
+	}
+	stepRun.Input = over
+
+	var items []interface{}
+	if err := json.Unmarshal([]byte(over), &items); err != nil {
+		return nil, fmt.Errorf("map over must render a JSON array: %w", err)
+	}
+
+	if len(stepRun.Items) > len(items) {
+		stepRun.Items = stepRun.Items[:len(items)]
+	}
+
+	outputs := make([]interface{}, len(items))
+
+	for i, item := range items {
+		if i == len(stepRun.Items) {
+			stepRun.Items = append(stepRun.Items, &types.WorkflowStepRun{
+				Name:   fmt.Sprintf("%s[%d]", step.Name, i),
+				Status: types.WorkflowStepStatusPending,
+			})
+		}
+		itemRun := stepRun.Items[i]
+
+		if itemRun.Status == types.WorkflowStepStatusSuccess {
+			outputs[i] = itemRun.Output
+			continue
+		}
+
+		itemRun.Status = types.WorkflowStepStatusRunning
+		itemRun.Started = time.Now()
+		itemRun.Error = ""
+		x.engine.save(ctx, x.run)
+
+		itemValues := make(map[string]interface{}, len(values)+2)
+		for k, v := range values {
+			itemValues[k] = v
+		}
+		itemValues["item"] = item
+		itemValues["index"] = i
+
+		output, err := x.runStep(ctx, step.Map.Step, itemValues, itemRun)
+		itemRun.Finished = time.Now()
+
+		if err != nil {
+			itemRun.Status = types.WorkflowStepStatusFailed
+			itemRun.Error = err.Error()
+			return nil, fmt.Errorf("item %d: %w", i, err)
+		}
+
+		itemRun.Status = types.WorkflowStepStatusSuccess
+		itemRun.Output = output
+		outputs[i] = output
+	}
+
+	return outputs, nil
+}
+
+// tool returns the assistant's tool with the type and name
+func (x *execution) tool(assistantRef string, toolType types.ToolType, name string) (*types.Tool, error) {
+	assistantID, ok := data.ResolveAssistant(x.app, assistantRef)
+	if !ok {
+		return nil, fmt.Errorf("assistant '%s' not found", assistantRef)
+	}
+
+	assistant := data.GetAssistant(x.app, assistantID)
+	if assistant == nil {
+		return nil, fmt.Errorf("assistant '%s' not found", assistantRef)
+	}
+
+	for _, tool := range assistant.Tools {
+		if tool.ToolType == toolType && tool.Name == name {
+			return tool, nil
+		}
+	}
+
+	return nil, fmt.Errorf("assistant has no %s tool '%s'", toolType, name)
+}
+
+// jsonOrString passes parameters that render to a JSON object or array as JSON
+func jsonOrString(value string) interface{} {
+	trimmed := strings.TrimSpace(value)
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		return value
+	}
+
+	var v interface{}
+	if err := json.Unmarshal([]byte(trimmed), &v); err != nil {
+		return value
+	}
+	return v
+}
@@ -0,0 +1,171 @@
+package workflow
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+// stepRefRegexp finds the steps that a template refers to, e.g. {{ .steps.summarise.output }}
+var stepRefRegexp = regexp.MustCompile(`\.steps\.([A-Za-z_][A-Za-z0-9_]*)`)
+
+// codeFenceRegexp finds the JSON in answers that wrap it in a markdown code block
+var codeFenceRegexp = regexp.MustCompile("(?s)^```[a-zA-Z]*\\s*(.*?)\\s*```$")
+
+// parseTemplate parses a step's template, templates can use the json function
+func parseTemplate(tmpl string) (*template.Template, error) {
+	t, err := template.New("workflow").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			bts, err := json.Marshal(v)
+			return string(bts), err
+		},
+	}).Option("missingkey=zero").Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	return t, nil
+}
+
+func render(tmpl string, values map[string]interface{}) (string, error) {
+	t, err := parseTemplate(tmpl)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, values); err != nil {
+		return "", fmt.Errorf("failed to render template: %w", err)
+	}
+
+	return buf.String(), nil
+}
+
+// truthy reports whether a branch's condition holds
+func truthy(condition string) bool {
+	switch strings.ToLower(strings.TrimSpace(condition)) {
+	case "", "false", "no", "0", "<no value>":
+		return false
+	}
+	return true
+}
+
+// parseOutput turns the text that a step returned into its output type
+func parseOutput(outputType types.WorkflowValueType, text string) (interface{}, error) {
+	switch outputType {
+	case "", types.WorkflowValueTypeString:
+		return text, nil
+	case types.WorkflowValueTypeNumber:
+		n, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+		if err != nil {
+			return nil, fmt.Errorf("output '%s' isn't a number", text)
+		}
+		return n, nil
+	case types.WorkflowValueTypeBoolean:
+		b, err := strconv.ParseBool(strings.ToLower(strings.TrimSpace(text)))
+		if err != nil {
+			return nil, fmt.Errorf("output '%s' isn't a boolean", text)
+		}
+		return b, nil
+	}
+
+	trimmed := strings.TrimSpace(text)
+	if match := codeFenceRegexp.FindStringSubmatch(trimmed); match != nil {
+		trimmed = match[1]
+	}
+
+	var v interface{}
+	if err := json.Unmarshal([]byte(trimmed), &v); err != nil {
+		return nil, fmt.Errorf("output isn't JSON: %w", err)
+	}
+
+	if !hasType(v, outputType) {
+		return nil, fmt.Errorf("output isn't a JSON %s", outputType)
+	}
+
+	return v, nil
+}
+
+// hasType reports whether the JSON value has the type
+func hasType(v interface{}, valueType types.WorkflowValueType) bool {
+	switch valueType {
+	case "", types.WorkflowValueTypeString:
+		_, ok := v.(string)
+		return ok
+	case types.WorkflowValueTypeNumber:
+		switch v.(type) {
+		case float64, float32, int, int64, json.Number:
+			return true
+		}
+		return false
+	case types.WorkflowValueTypeBoolean:
+		_, ok := v.(bool)
+		return ok
+	case types.WorkflowValueTypeObject:
+		_, ok := v.(map[string]interface{})
+		return ok
+	case types.WorkflowValueTypeArray:
+		_, ok := v.([]interface{})
+		return ok
+	}
+	return false
+}
+
+// toText is the workflow's output for a step's output, strings as they are and JSON otherwise
+func toText(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+
+	bts, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal output: %w", err)
+	}
+	return string(bts), nil
+}
+
+// templates returns the step's templates, with the templates of a map's step
+func templates(step *types.WorkflowStep) []string {
+	var tmpls []string
+
+	switch {
+	case step.Assistant != nil:
+		tmpls = append(tmpls, step.Assistant.Prompt)
+	case step.API != nil:
+		for _, tmpl := range step.API.Parameters {
+			tmpls = append(tmpls, tmpl)
+		}
+	case step.GPTScript != nil:
+		tmpls = append(tmpls, step.GPTScript.Input)
+	case step.Knowledge != nil:
+		tmpls = append(tmpls, step.Knowledge.Query)
+	case step.Branch != nil:
+		tmpls = append(tmpls, step.Branch.If)
+	case step.Map != nil:
+		tmpls = append(tmpls, step.Map.Over)
+		if step.Map.Step != nil {
+			tmpls = append(tmpls, templates(step.Map.Step)...)
+		}
+	}
+
+	return tmpls
+}
+
+// references returns the steps that the step's templates refer to
+func references(step *types.WorkflowStep) []string {
+	var refs []string
+	for _, tmpl := range templates(step) {
+		for _, match := range stepRefRegexp.FindAllStringSubmatch(tmpl, -1) {
+			refs = append(refs, match[1])
+		}
+	}
+	return refs
+}
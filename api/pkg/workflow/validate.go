@@ -0,0 +1,356 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/helixml/helix/api/pkg/data"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+var (
+	// nameRegexp is for workflow names, they're part of the API's URLs
+	nameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+	// stepNameRegexp is for step names, templates refer to them as fields
+	stepNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// Validate checks the app's workflows and the triggers that run them
+func Validate(cfg *types.AppHelixConfig) error {
+	app := &types.App{Config: types.AppConfig{Helix: *cfg}}
+	names := make(map[string]bool)
+
+	for i := range cfg.Workflows {
+		workflow := &cfg.Workflows[i]
+
+		if !nameRegexp.MatchString(workflow.Name) {
+			return fmt.Errorf("workflow name '%s' must only have letters, numbers, dashes and underscores", workflow.Name)
+		}
+		if names[workflow.Name] {
+			return fmt.Errorf("workflow name '%s' is used more than once", workflow.Name)
+		}
+		names[workflow.Name] = true
+
+		if err := validateWorkflow(app, workflow); err != nil {
+			return fmt.Errorf("invalid workflow '%s': %w", workflow.Name, err)
+		}
+	}
+
+	for _, trigger := range cfg.Triggers {
+		var name string
+		switch {
+		case trigger.Cron != nil:
+			name = trigger.Cron.Workflow
+		case trigger.Webhook != nil:
+			name = trigger.Webhook.Workflow
+		case trigger.Event != nil:
+			name = trigger.Event.Workflow
+		}
+
+		if name != "" && !names[name] {
+			return fmt.Errorf("trigger refers to workflow '%s' that does not exist", name)
+		}
+	}
+
+	return nil
+}
+
+func validateWorkflow(app *types.App, workflow *types.Workflow) error {
+	if len(workflow.Steps) == 0 {
+		return errors.New("workflow needs at least one step")
+	}
+
+	inputs := make(map[string]bool)
+	for _, input := range workflow.Inputs {
+		if input.Name == "" {
+			return errors.New("inputs need a name")
+		}
+		if inputs[input.Name] {
+			return fmt.Errorf("input '%s' is declared more than once", input.Name)
+		}
+		inputs[input.Name] = true
+
+		if input.Type != "" && !slices.Contains(types.WorkflowValueTypes, input.Type) {
+			return fmt.Errorf("input '%s' has unknown type '%s', must be one of %v", input.Name, input.Type, types.WorkflowValueTypes)
+		}
+	}
+
+	steps := make(map[string]bool)
+	for i := range workflow.Steps {
+		step := &workflow.Steps[i]
+
+		if !stepNameRegexp.MatchString(step.Name) {
+			return fmt.Errorf("step name '%s' must start with a letter and only have letters, numbers and underscores", step.Name)
+		}
+		if steps[step.Name] {
+			return fmt.Errorf("step name '%s' is used more than once", step.Name)
+		}
+		steps[step.Name] = true
+
+		if err := validateStep(app, step, false); err != nil {
+			return fmt.Errorf("step '%s': %w", step.Name, err)
+		}
+	}
+
+	for i := range workflow.Steps {
+		step := &workflow.Steps[i]
+
+		if step.Branch != nil {
+			for _, name := range append(slices.Clone(step.Branch.Then), step.Branch.Else...) {
+				if name == step.Name || !steps[name] {
+					return fmt.Errorf("step '%s' branches to step '%s' that does not exist", step.Name, name)
+				}
+			}
+		}
+	}
+
+	// Unknown and circular needs
+	if _, err := order(workflow); err != nil {
+		return err
+	}
+
+	if workflow.Output != "" {
+		if _, err := parseTemplate(workflow.Output); err != nil {
+			return fmt.Errorf("output: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func validateStep(app *types.App, step *types.WorkflowStep, inMap bool) error {
+	count := 0
+	for _, set := range []bool{step.Assistant != nil, step.API != nil, step.GPTScript != nil, step.Knowledge != nil, step.Branch != nil, step.Map != nil} {
+		if set {
+			count++
+		}
+	}
+	if count != 1 {
+		return errors.New("step needs exactly one of assistant, api, gptscript, knowledge, branch or map")
+	}
+
+	if step.Output != "" && !slices.Contains(types.WorkflowValueTypes, step.Output) {
+		return fmt.Errorf("unknown output type '%s', must be one of %v", step.Output, types.WorkflowValueTypes)
+	}
+
+	for _, tmpl := range templates(step) {
+		if _, err := parseTemplate(tmpl); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case step.Assistant != nil:
+		if step.Assistant.Prompt == "" {
+			return errors.New("assistant step needs a prompt")
+		}
+		if _, err := findAssistant(app, step.Assistant.Assistant); err != nil {
+			return err
+		}
+	case step.API != nil:
+		assistant, err := findAssistant(app, step.API.Assistant)
+		if err != nil {
+			return err
+		}
+		if !slices.ContainsFunc(assistant.APIs, func(api types.AssistantAPI) bool { return api.Name == step.API.API }) {
+			return fmt.Errorf("assistant has no API '%s'", step.API.API)
+		}
+		if step.API.Action == "" {
+			return errors.New("api step needs an action")
+		}
+	case step.GPTScript != nil:
+		assistant, err := findAssistant(app, step.GPTScript.Assistant)
+		if err != nil {
+			return err
+		}
+		if !slices.ContainsFunc(assistant.GPTScripts, func(script types.AssistantGPTScript) bool { return script.Name == step.GPTScript.Script }) {
+			return fmt.Errorf("assistant has no GPTScript '%s'", step.GPTScript.Script)
+		}
+	case step.Knowledge != nil:
+		if !hasKnowledge(app, step.Knowledge.Knowledge) {
+			return fmt.Errorf("app has no knowledge '%s'", step.Knowledge.Knowledge)
+		}
+		if step.Knowledge.Query == "" {
+			return errors.New("knowledge step needs a query")
+		}
+		if step.Output != "" && step.Output != types.WorkflowValueTypeArray {
+			return errors.New("the output of knowledge steps is an array")
+		}
+	case step.Branch != nil:
+		if inMap {
+			return errors.New("map steps can't branch")
+		}
+		if step.Branch.If == "" {
+			return errors.New("branch step needs a condition")
+		}
+		if step.Output != "" && step.Output != types.WorkflowValueTypeBoolean {
+			return errors.New("the output of branch steps is a boolean")
+		}
+	case step.Map != nil:
+		if step.Map.Over == "" {
+			return errors.New("map step needs a list to map over")
+		}
+		if step.Map.Step == nil {
+			return errors.New("map step needs a step to run for each item")
+		}
+		if step.Output != "" && step.Output != types.WorkflowValueTypeArray {
+			return errors.New("the output of map steps is an array")
+		}
+		if err := validateStep(app, step.Map.Step, true); err != nil {
+			return fmt.Errorf("map: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func findAssistant(app *types.App, ref string) (*types.AssistantConfig, error) {
+	assistantID, ok := data.ResolveAssistant(app, ref)
+	if !ok {
+		return nil, fmt.Errorf("assistant '%s' does not exist", ref)
+	}
+
+	assistant := data.GetAssistant(app, assistantID)
+	if assistant == nil {
+		return nil, fmt.Errorf("assistant '%s' does not exist", ref)
+	}
+
+	return assistant, nil
+}
+
+func hasKnowledge(app *types.App, name string) bool {
+	for _, assistant := range app.Config.Helix.Assistants {
+		for _, knowledge := range assistant.Knowledge {
+			if knowledge.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ValidateInputs checks the inputs of a run against the workflow's inputs
+func ValidateInputs(workflow *types.Workflow, inputs map[string]interface{}) (types.WorkflowValues, error) {
+	for name := range inputs {
+		if !slices.ContainsFunc(workflow.Inputs, func(input types.WorkflowInput) bool { return input.Name == name }) {
+			return nil, fmt.Errorf("unknown input '%s'", name)
+		}
+	}
+
+	values := make(types.WorkflowValues)
+
+	for _, input := range workflow.Inputs {
+		value, ok := inputs[input.Name]
+		if !ok || value == nil {
+			if input.Required {
+				return nil, fmt.Errorf("input '%s' is required", input.Name)
+			}
+			continue
+		}
+
+		inputType := input.Type
+		if inputType == "" {
+			inputType = types.WorkflowValueTypeString
+		}
+		if !hasType(value, inputType) {
+			return nil, fmt.Errorf("input '%s' must be a %s", input.Name, inputType)
+		}
+
+		values[input.Name] = value
+	}
+
+	return values, nil
+}
+
+// Needs returns the steps that finish before the step: the ones it needs, the ones its
+// templates refer to and the branches that choose it
+func Needs(steps []types.WorkflowStep, step *types.WorkflowStep) []string {
+	var needs []string
+
+	add := func(name string) {
+		if name != step.Name && !slices.Contains(needs, name) {
+			needs = append(needs, name)
+		}
+	}
+
+	for _, name := range step.Needs {
+		add(name)
+	}
+	if step.Map != nil && step.Map.Step != nil {
+		for _, name := range step.Map.Step.Needs {
+			add(name)
+		}
+	}
+	for _, name := range references(step) {
+		add(name)
+	}
+
+	for _, other := range steps {
+		if other.Branch != nil && (slices.Contains(other.Branch.Then, step.Name) || slices.Contains(other.Branch.Else, step.Name)) {
+			add(other.Name)
+		}
+	}
+
+	return needs
+}
+
+// order returns the steps in the order that they run: a step runs when the steps it needs have,
+// in the order that they're declared
+func order(workflow *types.Workflow) ([]*types.WorkflowStep, error) {
+	steps := make(map[string]bool, len(workflow.Steps))
+	for _, step := range workflow.Steps {
+		steps[step.Name] = true
+	}
+
+	needs := make(map[string][]string, len(workflow.Steps))
+	for i := range workflow.Steps {
+		step := &workflow.Steps[i]
+		needs[step.Name] = Needs(workflow.Steps, step)
+
+		for _, name := range needs[step.Name] {
+			if !steps[name] {
+				return nil, fmt.Errorf("step '%s' needs step '%s' that does not exist", step.Name, name)
+			}
+		}
+	}
+
+	var (
+		ordered []*types.WorkflowStep
+		done    = make(map[string]bool, len(workflow.Steps))
+	)
+
+	for len(ordered) < len(workflow.Steps) {
+		progress := false
+
+		for i := range workflow.Steps {
+			step := &workflow.Steps[i]
+			if done[step.Name] {
+				continue
+			}
+
+			ready := true
+			for _, name := range needs[step.Name] {
+				if !done[name] {
+					ready = false
+					break
+				}
+			}
+			if !ready {
+				continue
+			}
+
+			ordered = append(ordered, step)
+			done[step.Name] = true
+			progress = true
+			break
+		}
+
+		if !progress {
+			return nil, errors.New("steps need each other in a cycle")
+		}
+	}
+
+	return ordered, nil
+}
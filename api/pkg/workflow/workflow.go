@@ -0,0 +1,514 @@
+// Package workflow runs the workflows of apps: DAGs of assistant, API, GPTScript, knowledge,
+// branch and map steps. The steps run one at a time in the order of the DAG, so a run with the
+// same inputs takes the same path, and the run is saved after each step so that it can be resumed.
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/helixml/helix/api/pkg/controller"
+	oai "github.com/helixml/helix/api/pkg/openai"
+	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/system"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+var ErrWorkflowNotFound = errors.New("workflow not found")
+
+const (
+	// InterruptedAfter is how long a run goes without being updated before it's considered
+	// interrupted, e.g. because its server stopped, and another replica resumes it
+	InterruptedAfter = 5 * time.Minute
+	// claimInterval is how often a run that is executed is marked as updated, so that it isn't
+	// considered interrupted while a step takes a long time
+	claimInterval = time.Minute
+)
+
+// Engine runs the apps' workflows and records their runs
+type Engine struct {
+	store      store.Store
+	controller *controller.Controller
+}
+
+func New(store store.Store, controller *controller.Controller) *Engine {
+	return &Engine{
+		store:      store,
+		controller: controller,
+	}
+}
+
+// Run runs the app's workflow and returns the finished run, the error is only set when the run
+// couldn't start. The trigger run is the run that started it, nil for runs started through the
+// API.
+func (e *Engine) Run(ctx context.Context, app *types.App, name string, inputs map[string]interface{}, trigger *types.TriggerRun) (*types.WorkflowRun, error) {
+	run, err := e.create(ctx, app, name, inputs, trigger)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.execute(ctx, app, run), nil
+}
+
+// Start records the run and runs the workflow in the background, the returned run is still
+// running. The run isn't cancelled with the context.
+func (e *Engine) Start(ctx context.Context, app *types.App, name string, inputs map[string]interface{}, trigger *types.TriggerRun) (*types.WorkflowRun, error) {
+	run, err := e.create(ctx, app, name, inputs, trigger)
+	if err != nil {
+		return nil, err
+	}
+
+	started := clone(run)
+
+	go e.execute(context.WithoutCancel(ctx), app, run)
+
+	return started, nil
+}
+
+// Resume runs a failed run again in the background, from the steps that didn't finish. The
+// outputs of the steps that did are kept.
+func (e *Engine) Resume(ctx context.Context, app *types.App, runID string) (*types.WorkflowRun, error) {
+	run, err := e.store.GetWorkflowRun(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+
+	if run.AppID != app.ID {
+		return nil, store.ErrNotFound
+	}
+
+	if run.Status != types.WorkflowRunStatusFailed {
+		return nil, fmt.Errorf("only failed runs can be resumed, the run is %s", run.Status)
+	}
+
+	e.reset(run)
+	e.save(ctx, run)
+
+	started := clone(run)
+
+	go e.execute(context.WithoutCancel(ctx), app, run)
+
+	return started, nil
+}
+
+// ResumeInterrupted resumes the runs that haven't been updated for InterruptedAfter, e.g. because
+// their server stopped. Each run is claimed first so that only one replica resumes it. done is
+// called with each resumed run when it has finished.
+func (e *Engine) ResumeInterrupted(ctx context.Context, done func(ctx context.Context, app *types.App, run *types.WorkflowRun)) error {
+	runs, err := e.store.ListWorkflowRuns(ctx, &store.ListWorkflowRunsQuery{
+		Status: types.WorkflowRunStatusRunning,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list running workflow runs: %w", err)
+	}
+
+	staleBefore := time.Now().Add(-InterruptedAfter)
+
+	for _, run := range runs {
+		if run.Updated.After(staleBefore) {
+			continue
+		}
+
+		claimed, err := e.store.ClaimWorkflowRun(ctx, run.ID, staleBefore)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("app_id", run.AppID).
+				Str("run_id", run.ID).
+				Msg("failed to claim an interrupted workflow run")
+			continue
+		}
+		if !claimed {
+			// Another replica resumed it first
+			continue
+		}
+
+		app, err := e.store.GetApp(ctx, run.AppID)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("app_id", run.AppID).
+				Str("run_id", run.ID).
+				Msg("failed to get the app of an interrupted workflow run")
+			continue
+		}
+
+		log.Info().
+			Str("app_id", app.ID).
+			Str("run_id", run.ID).
+			Str("workflow", run.Workflow).
+			Msg("resuming interrupted workflow run")
+
+		e.reset(run)
+		go func() {
+			ctx := context.WithoutCancel(ctx)
+			run := e.execute(ctx, app, run)
+			if done != nil {
+				done(ctx, app, run)
+			}
+		}()
+	}
+
+	return nil
+}
+
+func (e *Engine) create(ctx context.Context, app *types.App, name string, inputs map[string]interface{}, trigger *types.TriggerRun) (*types.WorkflowRun, error) {
+	workflow := Find(app, name)
+	if workflow == nil {
+		return nil, fmt.Errorf("%w: %s", ErrWorkflowNotFound, name)
+	}
+
+	values, err := ValidateInputs(workflow, inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	run := &types.WorkflowRun{
+		ID:       system.GenerateWorkflowRunID(),
+		AppID:    app.ID,
+		Workflow: workflow.Name,
+		Status:   types.WorkflowRunStatusRunning,
+		Started:  now,
+		Updated:  now,
+		Inputs:   values,
+	}
+
+	if trigger != nil {
+		run.Trigger = trigger.Type
+		run.TriggerRunID = trigger.ID
+	}
+
+	for _, step := range workflow.Steps {
+		run.Steps = append(run.Steps, &types.WorkflowStepRun{
+			Name:   step.Name,
+			Status: types.WorkflowStepStatusPending,
+		})
+	}
+
+	_, err = e.store.CreateWorkflowRun(ctx, run)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create workflow run: %w", err)
+	}
+
+	return run, nil
+}
+
+// reset makes the steps that didn't finish pending again
+func (e *Engine) reset(run *types.WorkflowRun) {
+	run.Status = types.WorkflowRunStatusRunning
+	run.Error = ""
+	run.Finished = time.Time{}
+
+	for _, stepRun := range run.Steps {
+		if stepRun.Status == types.WorkflowStepStatusSuccess || stepRun.Status == types.WorkflowStepStatusSkipped {
+			continue
+		}
+		stepRun.Status = types.WorkflowStepStatusPending
+		stepRun.Error = ""
+		stepRun.Started = time.Time{}
+		stepRun.Finished = time.Time{}
+	}
+}
+
+func (e *Engine) execute(ctx context.Context, app *types.App, run *types.WorkflowRun) *types.WorkflowRun {
+	defer e.keepClaim(ctx, run.ID)()
+
+	workflow := Find(app, run.Workflow)
+	if workflow == nil {
+		return e.fail(ctx, run, fmt.Errorf("%w: %s", ErrWorkflowNotFound, run.Workflow))
+	}
+
+	steps, err := order(workflow)
+	if err != nil {
+		return e.fail(ctx, run, err)
+	}
+
+	// Tool steps need the app with its tools and the owner's secrets, assistant steps load it
+	// themselves
+	user := &types.User{ID: app.Owner}
+	toolsApp, err := e.controller.LoadApp(ctx, user, app.ID)
+	if err != nil {
+		return e.fail(ctx, run, err)
+	}
+	toolsApp, err = e.controller.EvaluateSecrets(ctx, user, toolsApp)
+	if err != nil {
+		return e.fail(ctx, run, fmt.Errorf("failed to evaluate secrets: %w", err))
+	}
+
+	// The LLM calls of the steps are listed with the run
+	ctx = oai.SetContextValues(ctx, &oai.ContextValues{
+		OwnerID:   app.Owner,
+		SessionID: run.ID,
+	})
+	ctx = oai.SetContextAppID(ctx, app.ID)
+
+	x := &execution{
+		engine:   e,
+		workflow: workflow,
+		app:      toolsApp,
+		user:     user,
+		run:      run,
+		stepRuns: stepRuns(workflow, run),
+		steps:    make(map[string]interface{}),
+		skip:     make(map[string]bool),
+	}
+
+	for _, step := range steps {
+		stepRun := x.stepRuns[step.Name]
+
+		switch stepRun.Status {
+		case types.WorkflowStepStatusSuccess:
+			x.done(step, stepRun.Output)
+			continue
+		case types.WorkflowStepStatusSkipped:
+			x.done(step, nil)
+			continue
+		}
+
+		if x.skipped(step) {
+			stepRun.Status = types.WorkflowStepStatusSkipped
+			x.done(step, nil)
+			e.save(ctx, run)
+			continue
+		}
+
+		stepRun.Status = types.WorkflowStepStatusRunning
+		stepRun.Started = time.Now()
+		e.save(ctx, run)
+
+		output, err := x.runStep(ctx, step, x.data(nil), stepRun)
+		stepRun.Finished = time.Now()
+
+		if err != nil {
+			stepRun.Status = types.WorkflowStepStatusFailed
+			stepRun.Error = err.Error()
+			return e.fail(ctx, run, fmt.Errorf("step %s failed: %w", step.Name, err))
+		}
+
+		stepRun.Status = types.WorkflowStepStatusSuccess
+		stepRun.Output = output
+		x.done(step, output)
+		e.save(ctx, run)
+	}
+
+	output, err := x.output(workflow, steps)
+	if err != nil {
+		return e.fail(ctx, run, err)
+	}
+
+	run.Status = types.WorkflowRunStatusSuccess
+	run.Output = output
+	run.Finished = time.Now()
+	e.save(ctx, run)
+
+	log.Info().
+		Str("app_id", app.ID).
+		Str("run_id", run.ID).
+		Str("workflow", run.Workflow).
+		Msg("workflow run completed")
+
+	return run
+}
+
+func (e *Engine) fail(ctx context.Context, run *types.WorkflowRun, err error) *types.WorkflowRun {
+	log.Error().
+		Err(err).
+		Str("app_id", run.AppID).
+		Str("run_id", run.ID).
+		Str("workflow", run.Workflow).
+		Msg("workflow run failed")
+
+	run.Status = types.WorkflowRunStatusFailed
+	run.Error = err.Error()
+	run.Finished = time.Now()
+	e.save(ctx, run)
+
+	return run
+}
+
+// keepClaim marks the run as updated every claimInterval until the returned function is called,
+// so that other replicas don't resume the run while a step takes a long time
+func (e *Engine) keepClaim(ctx context.Context, runID string) func() {
+	ctx, cancel := context.WithCancel(ctx)
+
+	go func() {
+		ticker := time.NewTicker(claimInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := e.store.ClaimWorkflowRun(ctx, runID, time.Now()); err != nil {
+					log.Error().
+						Err(err).
+						Str("run_id", runID).
+						Msg("failed to mark workflow run as updated")
+				}
+			}
+		}
+	}()
+
+	return cancel
+}
+
+// save records the progress of the run, the run carries on when it can't be saved
+func (e *Engine) save(ctx context.Context, run *types.WorkflowRun) {
+	run.Updated = time.Now()
+
+	_, err := e.store.UpdateWorkflowRun(ctx, run)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("app_id", run.AppID).
+			Str("run_id", run.ID).
+			Msg("failed to update workflow run")
+	}
+}
+
+// execution is the state of a run while it's executed
+type execution struct {
+	engine   *Engine
+	workflow *types.Workflow
+	// app has the assistants' tools and the owner's secrets
+	app      *types.App
+	user     *types.User
+	run      *types.WorkflowRun
+	stepRuns map[string]*types.WorkflowStepRun
+	// steps are the outputs of the finished steps
+	steps map[string]interface{}
+	// skip are the steps on the side of a branch that wasn't taken
+	skip map[string]bool
+}
+
+// data is what the templates get, with the item of a map step
+func (x *execution) data(item map[string]interface{}) map[string]interface{} {
+	data := map[string]interface{}{
+		"inputs": map[string]interface{}(x.run.Inputs),
+		"steps":  x.steps,
+	}
+	for k, v := range item {
+		data[k] = v
+	}
+	return data
+}
+
+// done records the step's output, skipped steps have no output so that templates can still refer
+// to them
+func (x *execution) done(step *types.WorkflowStep, output interface{}) {
+	x.steps[step.Name] = map[string]interface{}{"output": output}
+
+	if step.Branch == nil || output == nil {
+		return
+	}
+
+	skipped := step.Branch.Else
+	if taken, _ := output.(bool); !taken {
+		skipped = step.Branch.Then
+	}
+	for _, name := range skipped {
+		x.skip[name] = true
+	}
+}
+
+// skipped reports whether the step is on the side of a branch that wasn't taken, or whether all
+// the steps it needs were skipped
+func (x *execution) skipped(step *types.WorkflowStep) bool {
+	if x.skip[step.Name] {
+		return true
+	}
+
+	needs := Needs(x.workflow.Steps, step)
+	if len(needs) == 0 {
+		return false
+	}
+
+	for _, name := range needs {
+		if x.stepRuns[name].Status != types.WorkflowStepStatusSkipped {
+			return false
+		}
+	}
+	return true
+}
+
+// output is the workflow's output template, or the output of the last step that ran
+func (x *execution) output(workflow *types.Workflow, steps []*types.WorkflowStep) (string, error) {
+	if workflow.Output != "" {
+		return render(workflow.Output, x.data(nil))
+	}
+
+	for i := len(steps) - 1; i >= 0; i-- {
+		stepRun := x.stepRuns[steps[i].Name]
+		if stepRun.Status == types.WorkflowStepStatusSuccess {
+			return toText(stepRun.Output)
+		}
+	}
+
+	return "", nil
+}
+
+// stepRuns returns the trace of each step, the steps that were added to the workflow since the
+// run started are added to the trace
+func stepRuns(workflow *types.Workflow, run *types.WorkflowRun) map[string]*types.WorkflowStepRun {
+	runs := make(map[string]*types.WorkflowStepRun)
+	for _, stepRun := range run.Steps {
+		runs[stepRun.Name] = stepRun
+	}
+
+	for _, step := range workflow.Steps {
+		if _, ok := runs[step.Name]; ok {
+			continue
+		}
+		stepRun := &types.WorkflowStepRun{
+			Name:   step.Name,
+			Status: types.WorkflowStepStatusPending,
+		}
+		run.Steps = append(run.Steps, stepRun)
+		runs[step.Name] = stepRun
+	}
+
+	return runs
+}
+
+// Find returns the app's workflow with the name
+func Find(app *types.App, name string) *types.Workflow {
+	for i := range app.Config.Helix.Workflows {
+		if app.Config.Helix.Workflows[i].Name == name {
+			return &app.Config.Helix.Workflows[i]
+		}
+	}
+	return nil
+}
+
+// ParseInputs turns a trigger's input into the workflow's inputs, it's the inputs when it's a
+// JSON object and the "input" input otherwise
+func ParseInputs(input string) map[string]interface{} {
+	var inputs map[string]interface{}
+	if err := json.Unmarshal([]byte(input), &inputs); err == nil && inputs != nil {
+		return inputs
+	}
+	return map[string]interface{}{"input": input}
+}
+
+// clone copies the run, so that it can be returned while it's being executed
+func clone(run *types.WorkflowRun) *types.WorkflowRun {
+	c := *run
+	c.Steps = make(types.WorkflowStepRuns, 0, len(run.Steps))
+	for _, stepRun := range run.Steps {
+		s := *stepRun
+		s.Items = nil
+		for _, item := range stepRun.Items {
+			i := *item
+			s.Items = append(s.Items, &i)
+		}
+		c.Steps = append(c.Steps, &s)
+	}
+	return &c
+}
@@ -0,0 +1,466 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/helixml/helix/api/pkg/config"
+	"github.com/helixml/helix/api/pkg/controller"
+	"github.com/helixml/helix/api/pkg/extract"
+	"github.com/helixml/helix/api/pkg/filestore"
+	"github.com/helixml/helix/api/pkg/janitor"
+	oai "github.com/helixml/helix/api/pkg/openai"
+	"github.com/helixml/helix/api/pkg/openai/manager"
+	"github.com/helixml/helix/api/pkg/pubsub"
+	"github.com/helixml/helix/api/pkg/store"
+	"github.com/helixml/helix/api/pkg/types"
+)
+
+func documentApp() *types.App {
+	app := &types.App{ID: "app_1", Owner: "user_1"}
+	app.Config.Helix.Assistants = []types.AssistantConfig{
+		{Name: "extractor", SystemPrompt: "You extract fields from documents"},
+		{Name: "writer", SystemPrompt: "You write summaries"},
+	}
+	app.Config.Helix.Workflows = []types.Workflow{
+		{
+			Name:   "process-document",
+			Inputs: []types.WorkflowInput{{Name: "document", Type: types.WorkflowValueTypeString, Required: true}},
+			Steps: []types.WorkflowStep{
+				{
+					Name:   "extract",
+					Output: types.WorkflowValueTypeObject,
+					Assistant: &types.WorkflowAssistantStep{
+						Assistant: "extractor",
+						Prompt:    "Extract the lines of: {{ .inputs.document }}",
+					},
+				},
+				{
+					Name:   "is_invoice",
+					Branch: &types.WorkflowBranchStep{If: "{{ .steps.extract.output.invoice }}", Then: []string{"lines"}, Else: []string{"reject"}},
+				},
+				{
+					Name: "lines",
+					Map: &types.WorkflowMapStep{
+						Over: "{{ json .steps.extract.output.lines }}",
+						Step: &types.WorkflowStep{
+							Assistant: &types.WorkflowAssistantStep{Assistant: "writer", Prompt: "Summarise line {{ .index }}: {{ .item }}"},
+						},
+					},
+				},
+				{
+					Name:      "reject",
+					Assistant: &types.WorkflowAssistantStep{Assistant: "writer", Prompt: "Explain why this isn't an invoice"},
+				},
+				{
+					Name:      "report",
+					Needs:     []string{"lines", "reject"},
+					Assistant: &types.WorkflowAssistantStep{Assistant: "writer", Prompt: "Report on {{ json .steps.lines.output }}"},
+				},
+			},
+		},
+	}
+	return app
+}
+
+func newTestEngine(t *testing.T, app *types.App) (*Engine, *store.MockStore, *oai.MockClient) {
+	ctrl := gomock.NewController(t)
+	st := store.NewMockStore(ctrl)
+	client := oai.NewMockClient(ctrl)
+
+	providerManager := manager.NewMockProviderManager(ctrl)
+	providerManager.EXPECT().GetClient(gomock.Any(), gomock.Any()).Return(client, nil).AnyTimes()
+
+	ps, err := pubsub.New(t.TempDir())
+	require.NoError(t, err)
+
+	cfg := &config.ServerConfig{}
+	cfg.Inference.Provider = types.ProviderTogetherAI
+
+	c, err := controller.NewController(context.Background(), controller.Options{
+		Config:          cfg,
+		Store:           st,
+		Janitor:         janitor.NewJanitor(config.Janitor{}),
+		ProviderManager: providerManager,
+		Filestore:       filestore.NewMockFileStore(ctrl),
+		Extractor:       extract.NewMockExtractor(ctrl),
+		PubSub:          ps,
+	})
+	require.NoError(t, err)
+
+	st.EXPECT().GetAppWithTools(gomock.Any(), app.ID).Return(app, nil).AnyTimes()
+	st.EXPECT().ListSecrets(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+
+	return New(st, c), st, client
+}
+
+// recordRuns records the runs that are saved, the last one is the run as it finished
+type recordRuns struct {
+	mu   sync.Mutex
+	last *types.WorkflowRun
+	done chan struct{}
+}
+
+func expectRuns(st *store.MockStore) *recordRuns {
+	r := &recordRuns{done: make(chan struct{}, 10)}
+
+	st.EXPECT().CreateWorkflowRun(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, run *types.WorkflowRun) (*types.WorkflowRun, error) {
+		return run, nil
+	}).AnyTimes()
+	st.EXPECT().UpdateWorkflowRun(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, run *types.WorkflowRun) (*types.WorkflowRun, error) {
+		r.mu.Lock()
+		r.last = clone(run)
+		r.mu.Unlock()
+
+		if run.Status != types.WorkflowRunStatusRunning {
+			r.done <- struct{}{}
+		}
+		return run, nil
+	}).AnyTimes()
+
+	return r
+}
+
+func (r *recordRuns) wait(t *testing.T) *types.WorkflowRun {
+	select {
+	case <-r.done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("workflow run didn't finish")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.last
+}
+
+// expectAnswers answers each prompt with the answer of the first prompt prefix it starts with
+func expectAnswers(client *oai.MockClient, answers map[string]string, prompts *[]string) {
+	var mu sync.Mutex
+
+	client.EXPECT().CreateChatCompletion(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+			prompt := req.Messages[len(req.Messages)-1].Content
+
+			mu.Lock()
+			*prompts = append(*prompts, prompt)
+			mu.Unlock()
+
+			for prefix, answer := range answers {
+				if strings.HasPrefix(prompt, prefix) {
+					if answer == "" {
+						return openai.ChatCompletionResponse{}, errors.New("model is overloaded")
+					}
+					return openai.ChatCompletionResponse{
+						Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: answer}}},
+					}, nil
+				}
+			}
+			return openai.ChatCompletionResponse{}, errors.New("unexpected prompt: " + prompt)
+		}).AnyTimes()
+}
+
+func TestRun_ChainsStepsAndSkipsBranch(t *testing.T) {
+	app := documentApp()
+	engine, st, client := newTestEngine(t, app)
+	expectRuns(st)
+
+	var prompts []string
+	expectAnswers(client, map[string]string{
+		"Extract":   "```json\n{\"invoice\": true, \"lines\": [\"2 chairs\", \"1 desk\"]}\n```",
+		"Summarise": "a line",
+		"Report":    "invoice report",
+	}, &prompts)
+
+	run, err := engine.Run(context.Background(), app, "process-document", map[string]interface{}{"document": "INVOICE 42"}, &types.TriggerRun{ID: "trun_1", Type: types.TriggerTypeWebhook})
+	require.NoError(t, err)
+
+	assert.Equal(t, types.WorkflowRunStatusSuccess, run.Status, run.Error)
+	assert.Equal(t, "invoice report", run.Output)
+	assert.Equal(t, types.TriggerTypeWebhook, run.Trigger)
+	assert.Equal(t, "trun_1", run.TriggerRunID)
+
+	assert.Equal(t, []string{
+		"Extract the lines of: INVOICE 42",
+		"Summarise line 0: 2 chairs",
+		"Summarise line 1: 1 desk",
+		`Report on ["a line","a line"]`,
+	}, prompts)
+
+	statuses := make(map[string]types.WorkflowStepStatus)
+	for _, stepRun := range run.Steps {
+		statuses[stepRun.Name] = stepRun.Status
+	}
+	assert.Equal(t, map[string]types.WorkflowStepStatus{
+		"extract":    types.WorkflowStepStatusSuccess,
+		"is_invoice": types.WorkflowStepStatusSuccess,
+		"lines":      types.WorkflowStepStatusSuccess,
+		"reject":     types.WorkflowStepStatusSkipped,
+		"report":     types.WorkflowStepStatusSuccess,
+	}, statuses)
+
+	assert.Equal(t, map[string]interface{}{"invoice": true, "lines": []interface{}{"2 chairs", "1 desk"}}, run.Steps[0].Output)
+	require.Len(t, run.Steps[2].Items, 2)
+	assert.Equal(t, "lines[1]", run.Steps[2].Items[1].Name)
+}
+
+func TestRun_FailedStepIsResumed(t *testing.T) {
+	app := documentApp()
+	engine, st, client := newTestEngine(t, app)
+	runs := expectRuns(st)
+
+	var prompts []string
+	answers := map[string]string{
+		"Extract":   `{"invoice": true, "lines": ["2 chairs", "1 desk"]}`,
+		"Summarise": "a line",
+		"Report":    "",
+	}
+	expectAnswers(client, answers, &prompts)
+
+	run, err := engine.Run(context.Background(), app, "process-document", map[string]interface{}{"document": "INVOICE 42"}, nil)
+	require.NoError(t, err)
+	<-runs.done
+
+	require.Equal(t, types.WorkflowRunStatusFailed, run.Status)
+	assert.Contains(t, run.Error, "step report failed")
+	assert.Equal(t, types.WorkflowStepStatusFailed, run.Steps[4].Status)
+	assert.Len(t, prompts, 4)
+
+	answers["Report"] = "invoice report"
+	st.EXPECT().GetWorkflowRun(gomock.Any(), run.ID).Return(run, nil)
+
+	resumed, err := engine.Resume(context.Background(), app, run.ID)
+	require.NoError(t, err)
+	assert.Equal(t, types.WorkflowRunStatusRunning, resumed.Status)
+
+	finished := runs.wait(t)
+	assert.Equal(t, types.WorkflowRunStatusSuccess, finished.Status, finished.Error)
+	assert.Equal(t, "invoice report", finished.Output)
+
+	// Only the failed step ran again
+	assert.Len(t, prompts, 5)
+}
+
+func TestResumeInterrupted(t *testing.T) {
+	app := documentApp()
+	engine, st, client := newTestEngine(t, app)
+	runs := expectRuns(st)
+
+	var prompts []string
+	expectAnswers(client, map[string]string{"Report": "invoice report"}, &prompts)
+
+	interrupted := func(id string, updated time.Time) *types.WorkflowRun {
+		return &types.WorkflowRun{
+			ID:           id,
+			AppID:        app.ID,
+			Workflow:     "process-document",
+			Status:       types.WorkflowRunStatusRunning,
+			Updated:      updated,
+			TriggerRunID: "trun_" + id,
+			Inputs:       types.WorkflowValues{"document": "INVOICE 42"},
+			Steps: types.WorkflowStepRuns{
+				{Name: "extract", Status: types.WorkflowStepStatusSuccess, Output: map[string]interface{}{"invoice": true, "lines": []interface{}{}}},
+				{Name: "is_invoice", Status: types.WorkflowStepStatusSuccess},
+				{Name: "lines", Status: types.WorkflowStepStatusSuccess, Output: []interface{}{}},
+				{Name: "reject", Status: types.WorkflowStepStatusSkipped},
+				{Name: "report", Status: types.WorkflowStepStatusRunning},
+			},
+		}
+	}
+
+	stale := time.Now().Add(-time.Hour)
+	st.EXPECT().ListWorkflowRuns(gomock.Any(), &store.ListWorkflowRunsQuery{Status: types.WorkflowRunStatusRunning}).Return([]*types.WorkflowRun{
+		interrupted("wfrun_1", stale),
+		// Another replica claims it first
+		interrupted("wfrun_2", stale),
+		// Still being executed by another replica
+		interrupted("wfrun_3", time.Now()),
+	}, nil)
+	st.EXPECT().ClaimWorkflowRun(gomock.Any(), "wfrun_1", gomock.Any()).Return(true, nil)
+	st.EXPECT().ClaimWorkflowRun(gomock.Any(), "wfrun_2", gomock.Any()).Return(false, nil)
+	st.EXPECT().GetApp(gomock.Any(), app.ID).Return(app, nil)
+
+	done := make(chan *types.WorkflowRun, 1)
+	err := engine.ResumeInterrupted(context.Background(), func(_ context.Context, _ *types.App, run *types.WorkflowRun) {
+		done <- run
+	})
+	require.NoError(t, err)
+
+	runs.wait(t)
+
+	select {
+	case run := <-done:
+		assert.Equal(t, "wfrun_1", run.ID)
+		assert.Equal(t, "trun_wfrun_1", run.TriggerRunID)
+		assert.Equal(t, types.WorkflowRunStatusSuccess, run.Status, run.Error)
+		assert.Equal(t, "invoice report", run.Output)
+	case <-time.After(10 * time.Second):
+		t.Fatal("resumed run wasn't done")
+	}
+
+	// Only the step that was interrupted ran
+	assert.Equal(t, []string{"Report on []"}, prompts)
+}
+
+func TestRun_ElseBranch(t *testing.T) {
+	app := documentApp()
+	engine, st, client := newTestEngine(t, app)
+	expectRuns(st)
+
+	var prompts []string
+	expectAnswers(client, map[string]string{
+		"Extract": `{"invoice": false, "lines": []}`,
+		"Explain": "it's a letter",
+		"Report":  "not an invoice",
+	}, &prompts)
+
+	run, err := engine.Run(context.Background(), app, "process-document", map[string]interface{}{"document": "Dear Sir"}, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, types.WorkflowRunStatusSuccess, run.Status, run.Error)
+	assert.Equal(t, types.WorkflowStepStatusSkipped, run.Steps[2].Status)
+	assert.Equal(t, types.WorkflowStepStatusSuccess, run.Steps[3].Status)
+	assert.Equal(t, "not an invoice", run.Output)
+}
+
+func TestRun_InvalidInputs(t *testing.T) {
+	app := documentApp()
+	engine, _, _ := newTestEngine(t, app)
+
+	_, err := engine.Run(context.Background(), app, "process-document", map[string]interface{}{}, nil)
+	require.EqualError(t, err, "input 'document' is required")
+
+	_, err = engine.Run(context.Background(), app, "process-document", map[string]interface{}{"document": 42.0}, nil)
+	require.EqualError(t, err, "input 'document' must be a string")
+
+	_, err = engine.Run(context.Background(), app, "process-document", map[string]interface{}{"document": "x", "pages": 2.0}, nil)
+	require.EqualError(t, err, "unknown input 'pages'")
+
+	_, err = engine.Run(context.Background(), app, "missing", nil, nil)
+	require.ErrorIs(t, err, ErrWorkflowNotFound)
+}
+
+func TestValidate(t *testing.T) {
+	require.NoError(t, Validate(&documentApp().Config.Helix))
+
+	tests := []struct {
+		name   string
+		change func(cfg *types.AppHelixConfig)
+		err    string
+	}{
+		{
+			name:   "workflow name",
+			change: func(cfg *types.AppHelixConfig) { cfg.Workflows[0].Name = "process document" },
+			err:    "workflow name 'process document' must only have letters, numbers, dashes and underscores",
+		},
+		{
+			name: "duplicate step",
+			change: func(cfg *types.AppHelixConfig) {
+				cfg.Workflows[0].Steps[1].Name = "extract"
+			},
+			err: "step name 'extract' is used more than once",
+		},
+		{
+			name: "unknown assistant",
+			change: func(cfg *types.AppHelixConfig) {
+				cfg.Workflows[0].Steps[0].Assistant.Assistant = "reviewer"
+			},
+			err: "step 'extract': assistant 'reviewer' does not exist",
+		},
+		{
+			name: "two step types",
+			change: func(cfg *types.AppHelixConfig) {
+				cfg.Workflows[0].Steps[0].Knowledge = &types.WorkflowKnowledgeStep{Knowledge: "docs", Query: "q"}
+			},
+			err: "step 'extract': step needs exactly one of assistant, api, gptscript, knowledge, branch or map",
+		},
+		{
+			name: "unknown reference",
+			change: func(cfg *types.AppHelixConfig) {
+				cfg.Workflows[0].Steps[4].Assistant.Prompt = "Report on {{ .steps.summary.output }}"
+			},
+			err: "step 'report' needs step 'summary' that does not exist",
+		},
+		{
+			name: "cycle",
+			change: func(cfg *types.AppHelixConfig) {
+				cfg.Workflows[0].Steps[0].Needs = []string{"report"}
+			},
+			err: "steps need each other in a cycle",
+		},
+		{
+			name: "branch target",
+			change: func(cfg *types.AppHelixConfig) {
+				cfg.Workflows[0].Steps[1].Branch.Else = []string{"archive"}
+			},
+			err: "step 'is_invoice' branches to step 'archive' that does not exist",
+		},
+		{
+			name: "bad template",
+			change: func(cfg *types.AppHelixConfig) {
+				cfg.Workflows[0].Steps[0].Assistant.Prompt = "{{ .inputs.document"
+			},
+			err: "step 'extract': invalid template",
+		},
+		{
+			name: "trigger",
+			change: func(cfg *types.AppHelixConfig) {
+				cfg.Triggers = []types.Trigger{{Webhook: &types.WebhookTrigger{Workflow: "process"}}}
+			},
+			err: "trigger refers to workflow 'process' that does not exist",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := documentApp().Config.Helix
+			tt.change(&cfg)
+
+			err := Validate(&cfg)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestOrder(t *testing.T) {
+	steps, err := order(&documentApp().Config.Helix.Workflows[0])
+	require.NoError(t, err)
+
+	var names []string
+	for _, step := range steps {
+		names = append(names, step.Name)
+	}
+	assert.Equal(t, []string{"extract", "is_invoice", "lines", "reject", "report"}, names)
+}
+
+func TestParseInputs(t *testing.T) {
+	assert.Equal(t, map[string]interface{}{"document": "x"}, ParseInputs(`{"document": "x"}`))
+	assert.Equal(t, map[string]interface{}{"input": "hello"}, ParseInputs("hello"))
+	assert.Equal(t, map[string]interface{}{"input": "[1]"}, ParseInputs("[1]"))
+}
+
+func TestParseOutput(t *testing.T) {
+	v, err := parseOutput(types.WorkflowValueTypeArray, "```json\n[1, 2]\n```")
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{1.0, 2.0}, v)
+
+	v, err = parseOutput(types.WorkflowValueTypeBoolean, " Yes ")
+	require.Error(t, err)
+	assert.Nil(t, v)
+
+	v, err = parseOutput(types.WorkflowValueTypeNumber, "3.5\n")
+	require.NoError(t, err)
+	assert.Equal(t, 3.5, v)
+
+	_, err = parseOutput(types.WorkflowValueTypeObject, "[1]")
+	require.EqualError(t, err, "output isn't a JSON object")
+}
@@ -1,4 +1,5 @@
 import Box from '@mui/material/Box'
+import Chip from '@mui/material/Chip'
 import LinearProgress from '@mui/material/LinearProgress'
 import Typography from '@mui/material/Typography'
 import { FC } from 'react'
@@ -35,6 +36,17 @@ export const RunnerSummary: FC<{
         <Cell>
           <Typography variant="h6" sx={{mr: 2}}>{ runner.id } { runner.version }</Typography>
         </Cell>
+        {
+          runner.maintenance_state && runner.maintenance_state !== 'active' && (
+            <Cell>
+              <Chip
+                size="small"
+                label={ runner.maintenance_state }
+                color={ runner.maintenance_state === 'drained' ? 'default' : 'warning' }
+              />
+            </Cell>
+          )
+        }
         <Cell flexGrow={1} />
         <Cell>
           <Typography variant="caption" gutterBottom>{ Object.keys(runner.labels || {}).map(k => `${k}=${runner.labels[k]}`).join(', ') }</Typography>
@@ -313,8 +313,11 @@ export interface IRunnerState {
   model_instances: IModelInstanceState[],
   scheduling_decisions: string[],
   version?: string,
+  maintenance_state?: IRunnerMaintenanceState,
 }
 
+export type IRunnerMaintenanceState = 'active' | 'cordoned' | 'draining' | 'drained'
+
 export interface ISessionFilterModel {
   mode: ISessionMode,
   model_name?: string,